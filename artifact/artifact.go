@@ -0,0 +1,350 @@
+// Package artifact stores wrapped Helm charts in OCI registries as OCI artifacts, so they can be
+// transferred registry to registry
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+const (
+	// ConfigMediaType identifies the wrap artifacts. Their config is the wrap metadata
+	ConfigMediaType types.MediaType = "application/vnd.vmware.distribution-tooling.wrap.config.v1+json"
+	// ChartLayerMediaType is the media type of the layer holding the wrapped chart, without its images
+	ChartLayerMediaType types.MediaType = "application/vnd.vmware.distribution-tooling.wrap.chart.v1.tar+gzip"
+	// ImageLayerMediaType is the media type of the layers holding each of the image tarballs
+	ImageLayerMediaType types.MediaType = "application/vnd.vmware.distribution-tooling.wrap.image.v1.tar"
+
+	// titleAnnotation names the file stored in each layer
+	titleAnnotation = "org.opencontainers.image.title"
+	// chartLayerTitle is the title of the chart layer
+	chartLayerTitle = "chart.tgz"
+	// chartLayerPrefix is the directory the chart files are stored under in the chart layer
+	chartLayerPrefix = "wrap"
+	// imagesDir is the directory of the wrap holding the image tarballs
+	imagesDir = "images"
+)
+
+// Config defines the settings used to access the registry
+type Config struct {
+	Context context.Context
+	// Keychain resolves the credentials used to access the registry
+	Keychain authn.Keychain
+	// Transport, if not nil, is the HTTP transport used to access the registry
+	Transport http.RoundTripper
+}
+
+// Option defines a Config option
+type Option func(*Config)
+
+// WithContext provides an execution context
+func WithContext(ctx context.Context) Option {
+	return func(cfg *Config) {
+		cfg.Context = ctx
+	}
+}
+
+// WithKeychain sets the keychain used to authenticate against the registry
+func WithKeychain(k authn.Keychain) Option {
+	return func(cfg *Config) {
+		cfg.Keychain = k
+	}
+}
+
+// WithTransport sets the HTTP transport used to access the registry
+func WithTransport(tr http.RoundTripper) Option {
+	return func(cfg *Config) {
+		cfg.Transport = tr
+	}
+}
+
+func newConfig(opts ...Option) *Config {
+	cfg := &Config{Context: context.Background(), Keychain: authn.DefaultKeychain}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (cfg *Config) craneOptions() crane.Options {
+	opts := []crane.Option{crane.WithContext(cfg.Context), crane.WithAuthFromKeychain(cfg.Keychain)}
+	if cfg.Transport != nil {
+		opts = append(opts, crane.WithTransport(cfg.Transport))
+	}
+	return crane.GetOptions(opts...)
+}
+
+// Reference returns the reference of the artifact of a wrapped chart under the repository prefix
+// (oci://registry/namespace): <prefix>/<name>-wrap:<version>
+func Reference(prefix string, chartName string, chartVersion string) string {
+	return fmt.Sprintf("%s/%s-wrap:%s", strings.TrimSuffix(strings.TrimPrefix(prefix, "oci://"), "/"),
+		chartName, ChartVersionTag(chartVersion))
+}
+
+// ChartVersionTag returns the tag of the chart version in OCI registries. Tags do not allow "+", so it is
+// replaced with "_", as Helm does
+func ChartVersionTag(chartVersion string) string {
+	return strings.ReplaceAll(chartVersion, "+", "_")
+}
+
+// Push stores the wrapped chart in wrapDir as an artifact in ref: each image tarball is pushed as its own
+// layer and the rest of the chart as a compressed one. It returns the digest of the artifact manifest
+func Push(wrapDir string, ref string, opts ...Option) (digest.Digest, error) {
+	cfg := newConfig(opts...)
+	o := cfg.craneOptions()
+	r, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse artifact reference %q: %w", ref, err)
+	}
+	configData, err := artifactConfig(wrapDir)
+	if err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp("", "wrap-artifact-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layers, err := wrapLayers(cfg.Context, wrapDir, tmpDir)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := newManifest(static.NewLayer(configData, ConfigMediaType), layers, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.push(r, o.Remote...)
+}
+
+// IsWrapArtifact returns whether ref is the artifact of a wrapped chart
+func IsWrapArtifact(ref string, opts ...Option) bool {
+	_, m, err := fetchManifest(ref, newConfig(opts...))
+	return err == nil && m.Config.MediaType == ConfigMediaType
+}
+
+// Pull downloads the wrap artifact in ref into dir, restoring the wrapped chart directory
+func Pull(ref string, dir string, opts ...Option) error {
+	cfg := newConfig(opts...)
+	r, m, err := fetchManifest(ref, cfg)
+	if err != nil {
+		return err
+	}
+	if m.Config.MediaType != ConfigMediaType {
+		return fmt.Errorf("%q is not a wrap artifact: unexpected config media type %q", ref, m.Config.MediaType)
+	}
+	o := cfg.craneOptions()
+	for _, desc := range m.Layers {
+		layer, err := remote.Layer(r.Context().Digest(desc.Digest.String()), o.Remote...)
+		if err != nil {
+			return fmt.Errorf("failed to fetch artifact layer %s: %w", desc.Digest, err)
+		}
+		if err := extractLayer(cfg.Context, layer, desc, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetchManifest(ref string, cfg *Config) (name.Reference, *v1.Manifest, error) {
+	o := cfg.craneOptions()
+	r, err := name.ParseReference(ref, o.Name...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse artifact reference %q: %w", ref, err)
+	}
+	desc, err := remote.Get(r, o.Remote...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch artifact %q: %w", ref, err)
+	}
+	m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse artifact manifest: %w", err)
+	}
+	return r, m, nil
+}
+
+// extractLayer writes the contents of the layer described by desc into the wrapped chart directory
+func extractLayer(ctx context.Context, layer v1.Layer, desc v1.Descriptor, dir string) error {
+	switch desc.MediaType {
+	case ChartLayerMediaType:
+		chartFile := filepath.Join(dir, "."+chartLayerTitle)
+		if err := writeLayer(layer, chartFile); err != nil {
+			return err
+		}
+		defer os.Remove(chartFile)
+		if err := utils.UntarContext(ctx, chartFile, dir, utils.TarConfig{StripComponents: 1}); err != nil {
+			return fmt.Errorf("failed to uncompress chart: %w", err)
+		}
+		return nil
+	case ImageLayerMediaType:
+		// Never write outside of the images directory
+		title := filepath.Base(filepath.FromSlash(desc.Annotations[titleAnnotation]))
+		if filepath.Ext(title) != ".tar" {
+			return fmt.Errorf("invalid image layer title %q", desc.Annotations[titleAnnotation])
+		}
+		return writeLayer(layer, filepath.Join(dir, imagesDir, title))
+	default:
+		return fmt.Errorf("unsupported artifact layer media type %q", desc.MediaType)
+	}
+}
+
+// writeLayer downloads the layer into file, verifying its digest
+func writeLayer(layer v1.Layer, file string) error {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("failed to read artifact layer: %w", err)
+	}
+	defer rc.Close()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	fh, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", file, err)
+	}
+	defer fh.Close()
+	if _, err := io.Copy(fh, rc); err != nil {
+		return fmt.Errorf("failed to download artifact layer into %q: %w", file, err)
+	}
+	return fh.Close()
+}
+
+// artifactConfig returns the artifact config: the wrap metadata, if present
+func artifactConfig(wrapDir string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(wrapDir, metadata.FileName))
+	if os.IsNotExist(err) {
+		return []byte("{}"), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read wrap metadata: %w", err)
+	}
+	return data, nil
+}
+
+// annotatedLayer is a layer along with the annotations of its descriptor
+type annotatedLayer struct {
+	v1.Layer
+	annotations map[string]string
+}
+
+// wrapLayers returns the layers of the wrap in wrapDir, writing the compressed chart into tmpDir
+func wrapLayers(ctx context.Context, wrapDir string, tmpDir string) ([]annotatedLayer, error) {
+	isImageTar := func(f string) bool {
+		return filepath.Dir(f) == string(filepath.Separator)+imagesDir && filepath.Ext(f) == ".tar"
+	}
+	chartFile := filepath.Join(tmpDir, chartLayerTitle)
+	if err := utils.TarContext(ctx, wrapDir, chartFile, utils.TarConfig{Prefix: chartLayerPrefix, Skip: isImageTar}); err != nil {
+		return nil, fmt.Errorf("failed to compress chart: %w", err)
+	}
+	chartLayer, err := newFileLayer(chartFile, ChartLayerMediaType)
+	if err != nil {
+		return nil, err
+	}
+	layers := []annotatedLayer{{Layer: chartLayer, annotations: map[string]string{titleAnnotation: chartLayerTitle}}}
+
+	images, err := filepath.Glob(filepath.Join(wrapDir, imagesDir, "*.tar"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	for _, image := range images {
+		imageLayer, err := newFileLayer(image, ImageLayerMediaType)
+		if err != nil {
+			return nil, err
+		}
+		title := filepath.ToSlash(filepath.Join(imagesDir, filepath.Base(image)))
+		layers = append(layers, annotatedLayer{Layer: imageLayer, annotations: map[string]string{titleAnnotation: title}})
+	}
+	return layers, nil
+}
+
+// rawManifest is an artifact manifest ready to be pushed, along with its blobs
+type rawManifest struct {
+	data  []byte
+	blobs []v1.Layer
+}
+
+// RawManifest returns the serialized manifest
+func (m *rawManifest) RawManifest() ([]byte, error) {
+	return m.data, nil
+}
+
+// MediaType returns the manifest media type
+func (m *rawManifest) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+// Digest returns the manifest digest
+func (m *rawManifest) Digest() digest.Digest {
+	return digest.FromBytes(m.data)
+}
+
+// push pushes the manifest blobs and then the manifest into r, returning its digest
+func (m *rawManifest) push(r name.Reference, opts ...remote.Option) (digest.Digest, error) {
+	for _, l := range m.blobs {
+		if err := remote.WriteLayer(r.Context(), l, opts...); err != nil {
+			return "", fmt.Errorf("failed to push artifact layer: %w", err)
+		}
+	}
+	if err := remote.Put(r, m, opts...); err != nil {
+		return "", fmt.Errorf("failed to push artifact manifest: %w", err)
+	}
+	return m.Digest(), nil
+}
+
+// newManifest returns the manifest of an artifact with the given config and layers, referring to
+// subject if not nil
+func newManifest(config v1.Layer, layers []annotatedLayer, subject *v1.Descriptor) (*rawManifest, error) {
+	configDesc, err := layerDescriptor(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	m := &v1.Manifest{SchemaVersion: 2, MediaType: types.OCIManifestSchema1, Config: *configDesc, Subject: subject}
+	blobs := []v1.Layer{config}
+	for _, l := range layers {
+		desc, err := layerDescriptor(l, l.annotations)
+		if err != nil {
+			return nil, err
+		}
+		m.Layers = append(m.Layers, *desc)
+		blobs = append(blobs, l)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize artifact manifest: %w", err)
+	}
+	return &rawManifest{data: data, blobs: blobs}, nil
+}
+
+func layerDescriptor(l v1.Layer, annotations map[string]string) (*v1.Descriptor, error) {
+	h, err := l.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer digest: %w", err)
+	}
+	size, err := l.Size()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer size: %w", err)
+	}
+	mediaType, err := l.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer media type: %w", err)
+	}
+	return &v1.Descriptor{MediaType: mediaType, Digest: h, Size: size, Annotations: annotations}, nil
+}
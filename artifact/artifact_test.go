@@ -0,0 +1,110 @@
+package artifact
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir string, name string, data string) string {
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, os.WriteFile(file, []byte(data), 0644))
+	return file
+}
+
+// newSampleWrap creates a wrapped chart directory with two image tarballs
+func newSampleWrap(t *testing.T) string {
+	dir := t.TempDir()
+	writeFile(t, dir, "Chart.yaml", "name: test\nversion: 1.0.0\n")
+	writeFile(t, dir, "wrap.json", `{"chart": {"name": "test"}}`)
+	writeFile(t, dir, "images/aaaa.tar", "first image")
+	writeFile(t, dir, "images/bbbb.tar", "second image")
+	return dir
+}
+
+func newTestRegistry(t *testing.T) string {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	return u.Host
+}
+
+func TestReference(t *testing.T) {
+	assert.Equal(t, "localhost:5000/charts/mariadb-wrap:1.0.0", Reference("oci://localhost:5000/charts/", "mariadb", "1.0.0"))
+	assert.Equal(t, "localhost:5000/mariadb-wrap:1.0.0_build.1", Reference("localhost:5000", "mariadb", "1.0.0+build.1"))
+	assert.Equal(t, "1.0.0_build.1", ChartVersionTag("1.0.0+build.1"))
+}
+
+func TestPush(t *testing.T) {
+	ref := Reference(newTestRegistry(t), "test", "1.0.0")
+	dgst, err := Push(newSampleWrap(t), ref)
+	require.NoError(t, err)
+
+	r, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	desc, err := remote.Get(r)
+	require.NoError(t, err)
+	assert.Equal(t, dgst.String(), desc.Digest.String())
+
+	m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	require.NoError(t, err)
+	assert.Equal(t, ConfigMediaType, m.Config.MediaType)
+	titles := make([]string, 0)
+	for _, l := range m.Layers {
+		titles = append(titles, l.Annotations[titleAnnotation])
+	}
+	assert.Equal(t, []string{"chart.tgz", "images/aaaa.tar", "images/bbbb.tar"}, titles)
+	assert.Equal(t, ChartLayerMediaType, m.Layers[0].MediaType)
+	assert.Equal(t, ImageLayerMediaType, m.Layers[1].MediaType)
+	assert.Equal(t, int64(len("first image")), m.Layers[1].Size)
+
+	_, err = Push(newSampleWrap(t), "invalid reference:")
+	assert.ErrorContains(t, err, "failed to parse artifact reference")
+}
+
+func TestPull(t *testing.T) {
+	host := newTestRegistry(t)
+	ref := Reference(host, "test", "1.0.0")
+	_, err := Push(newSampleWrap(t), ref)
+	require.NoError(t, err)
+	assert.True(t, IsWrapArtifact(ref))
+
+	dir := t.TempDir()
+	require.NoError(t, Pull(ref, dir))
+	for file, data := range map[string]string{
+		"Chart.yaml":      "name: test\nversion: 1.0.0\n",
+		"wrap.json":       `{"chart": {"name": "test"}}`,
+		"images/aaaa.tar": "first image",
+		"images/bbbb.tar": "second image",
+	} {
+		contents, err := os.ReadFile(filepath.Join(dir, file))
+		require.NoError(t, err)
+		assert.Equal(t, data, string(contents))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, ".chart.tgz"))
+
+	// Regular images are not wrap artifacts
+	img, err := random.Image(16, 1)
+	require.NoError(t, err)
+	imgRef, err := name.ParseReference(host + "/image:latest")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(imgRef, img))
+	assert.False(t, IsWrapArtifact(imgRef.String()))
+	assert.ErrorContains(t, Pull(imgRef.String(), t.TempDir()), "is not a wrap artifact")
+	assert.False(t, IsWrapArtifact(host+"/missing:1.0.0"))
+}
@@ -0,0 +1,68 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// fileLayer is a layer streamed from a file, stored as is, so large image tarballs are not kept in memory
+type fileLayer struct {
+	file      string
+	mediaType types.MediaType
+	digest    v1.Hash
+	size      int64
+}
+
+func newFileLayer(file string, mediaType types.MediaType) (*fileLayer, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layer file: %w", err)
+	}
+	defer fh.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, fh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer file %q: %w", file, err)
+	}
+	return &fileLayer{
+		file:      file,
+		mediaType: mediaType,
+		digest:    v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", h.Sum(nil))},
+		size:      size,
+	}, nil
+}
+
+// Digest returns the digest of the file
+func (l *fileLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+// DiffID returns the digest of the file, as it is not compressed
+func (l *fileLayer) DiffID() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+// Compressed returns the file contents
+func (l *fileLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.file)
+}
+
+// Uncompressed returns the file contents
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) {
+	return os.Open(l.file)
+}
+
+// Size returns the size of the file
+func (l *fileLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+// MediaType returns the media type of the layer
+func (l *fileLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
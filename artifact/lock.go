@@ -0,0 +1,100 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// ImagesLockConfigMediaType identifies the Images.lock artifacts attached to Helm charts
+	ImagesLockConfigMediaType types.MediaType = "application/vnd.vmware.distribution-tooling.images-lock.config.v1+json"
+	// ImagesLockLayerMediaType is the media type of the layer holding the Images.lock file
+	ImagesLockLayerMediaType types.MediaType = "application/vnd.vmware.distribution-tooling.images-lock.v1+yaml"
+
+	// imagesLockTitle is the title of the Images.lock layer
+	imagesLockTitle = "Images.lock"
+)
+
+// AttachImagesLock attaches the Images.lock in lockFile to the Helm chart in chartRef as an OCI referrer of
+// its manifest, so it can be discovered without downloading the chart. It returns the digest of the
+// attached artifact
+func AttachImagesLock(chartRef string, lockFile string, opts ...Option) (digest.Digest, error) {
+	o := newConfig(opts...).craneOptions()
+	r, err := name.ParseReference(chartRef, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Helm chart reference %q: %w", chartRef, err)
+	}
+	desc, err := remote.Head(r, o.Remote...)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch Helm chart %q: %w", chartRef, err)
+	}
+	lockLayer, err := newFileLayer(lockFile, ImagesLockLayerMediaType)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := newManifest(
+		static.NewLayer([]byte("{}"), ImagesLockConfigMediaType),
+		[]annotatedLayer{{Layer: lockLayer, annotations: map[string]string{titleAnnotation: imagesLockTitle}}},
+		&v1.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size},
+	)
+	if err != nil {
+		return "", err
+	}
+	// Referrers are only addressed by digest
+	return manifest.push(r.Context().Digest(manifest.Digest().String()), o.Remote...)
+}
+
+// FetchImagesLock returns the contents of the Images.lock attached to the Helm chart in chartRef, or nil if
+// it has none. When several were attached, the last one listed by the registry is returned
+func FetchImagesLock(chartRef string, opts ...Option) ([]byte, error) {
+	cfg := newConfig(opts...)
+	o := cfg.craneOptions()
+	r, err := name.ParseReference(chartRef, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Helm chart reference %q: %w", chartRef, err)
+	}
+	desc, err := remote.Head(r, o.Remote...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Helm chart %q: %w", chartRef, err)
+	}
+	idx, err := remote.Referrers(r.Context().Digest(desc.Digest.String()),
+		append(o.Remote, remote.WithFilter("artifactType", string(ImagesLockConfigMediaType)))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Helm chart referrers: %w", err)
+	}
+	m, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Helm chart referrers: %w", err)
+	}
+	if len(m.Manifests) == 0 {
+		return nil, nil
+	}
+	lockRef := r.Context().Digest(m.Manifests[len(m.Manifests)-1].Digest.String())
+	_, lockManifest, err := fetchManifest(lockRef.String(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lockManifest.Layers {
+		if l.MediaType != ImagesLockLayerMediaType {
+			continue
+		}
+		layer, err := remote.Layer(r.Context().Digest(l.Digest.String()), o.Remote...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch Images.lock layer: %w", err)
+		}
+		rc, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Images.lock layer: %w", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("attached artifact %s does not include an Images.lock", lockRef)
+}
@@ -0,0 +1,62 @@
+package artifact
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachImagesLock(t *testing.T) {
+	host := newTestRegistry(t)
+	// Any manifest can be the subject, so use an image as the chart
+	chart, err := random.Image(16, 1)
+	require.NoError(t, err)
+	chartRef, err := name.ParseReference(host + "/charts/test:1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(chartRef, chart))
+	chartDigest, err := chart.Digest()
+	require.NoError(t, err)
+
+	lockFile := writeFile(t, t.TempDir(), "Images.lock", "apiVersion: v0\nkind: ImagesLock\n")
+	dgst, err := AttachImagesLock(chartRef.String(), lockFile)
+	require.NoError(t, err)
+
+	idx, err := remote.Referrers(chartRef.Context().Digest(chartDigest.String()))
+	require.NoError(t, err)
+	m, err := idx.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, m.Manifests, 1)
+	assert.Equal(t, dgst.String(), m.Manifests[0].Digest.String())
+	assert.Equal(t, string(ImagesLockConfigMediaType), m.Manifests[0].ArtifactType)
+
+	_, err = AttachImagesLock(host+"/charts/missing:1.0.0", lockFile)
+	assert.ErrorContains(t, err, "failed to fetch Helm chart")
+}
+
+func TestFetchImagesLock(t *testing.T) {
+	host := newTestRegistry(t)
+	chart, err := random.Image(16, 1)
+	require.NoError(t, err)
+	chartRef, err := name.ParseReference(host + "/charts/test:1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(chartRef, chart))
+
+	// Charts without attached Images.lock
+	data, err := FetchImagesLock(chartRef.String())
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	lockData := "apiVersion: v0\nkind: ImagesLock\n"
+	_, err = AttachImagesLock(chartRef.String(), writeFile(t, t.TempDir(), "Images.lock", lockData))
+	require.NoError(t, err)
+	data, err = FetchImagesLock(chartRef.String())
+	require.NoError(t, err)
+	assert.Equal(t, lockData, string(data))
+
+	_, err = FetchImagesLock(host + "/charts/missing:1.0.0")
+	assert.ErrorContains(t, err, "failed to fetch Helm chart")
+}
@@ -0,0 +1,139 @@
+// Package carvel implements the conversion of Helm charts and their Images.lock into Carvel imgpkg bundles
+package carvel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	// APIVersion is the imgpkg API version of the generated files
+	APIVersion = "imgpkg.carvel.dev/v1alpha1"
+	// BundleDir is the directory, relative to the bundle root, holding the imgpkg metadata
+	BundleDir = ".imgpkg"
+	// BundleFileName is the name of the bundle metadata file
+	BundleFileName = "bundle.yml"
+	// ImagesLockFileName is the name of the imgpkg images lock file
+	ImagesLockFileName = "images.yml"
+
+	// kbldIDAnnotation records the original reference of the locked images, as kbld does
+	kbldIDAnnotation = "kbld.carvel.dev/id"
+)
+
+// Bundle is the imgpkg bundle metadata
+type Bundle struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Metadata   Metadata  `yaml:"metadata"`
+	Authors    []Author  `yaml:"authors,omitempty"`
+	Websites   []Website `yaml:"websites,omitempty"`
+}
+
+// Metadata holds the bundle name
+type Metadata struct {
+	Name string `yaml:"name"`
+}
+
+// Author is a bundle author
+type Author struct {
+	Name  string `yaml:"name,omitempty"`
+	Email string `yaml:"email,omitempty"`
+}
+
+// Website is a bundle website
+type Website struct {
+	URL string `yaml:"url"`
+}
+
+// ImagesLock is the imgpkg lock of the images a bundle references
+type ImagesLock struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Images     []ImageRef `yaml:"images"`
+}
+
+// ImageRef is an image locked by digest
+type ImageRef struct {
+	Image       string            `yaml:"image"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// NewBundle returns the bundle metadata of the Helm chart: its maintainers become the bundle authors,
+// and its home and sources the bundle websites
+func NewBundle(md *chart.Metadata) *Bundle {
+	b := &Bundle{APIVersion: APIVersion, Kind: "Bundle", Metadata: Metadata{Name: md.Name}}
+	for _, m := range md.Maintainers {
+		b.Authors = append(b.Authors, Author{Name: m.Name, Email: m.Email})
+	}
+	for _, url := range append([]string{md.Home}, md.Sources...) {
+		if url != "" {
+			b.Websites = append(b.Websites, Website{URL: url})
+		}
+	}
+	return b
+}
+
+// NewImagesLock returns the imgpkg lock of the images in lock. Each platform digest of multi-platform
+// images is locked separately, so the bundle references exactly the locked images
+func NewImagesLock(lock *imagelock.ImagesLock) (*ImagesLock, error) {
+	il := &ImagesLock{APIVersion: APIVersion, Kind: "ImagesLock", Images: make([]ImageRef, 0)}
+	done := make(map[string]struct{})
+	for _, img := range lock.Images {
+		repo, err := repository(img.Image)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range img.Digests {
+			ref := fmt.Sprintf("%s@%s", repo, d.Digest)
+			if _, found := done[ref]; found {
+				continue
+			}
+			done[ref] = struct{}{}
+			il.Images = append(il.Images, ImageRef{Image: ref, Annotations: map[string]string{kbldIDAnnotation: img.Image}})
+		}
+	}
+	return il, nil
+}
+
+// WriteBundle writes the imgpkg metadata of the Helm chart in chartDir, using its lock, into the
+// .imgpkg directory of the chart, turning it into an imgpkg bundle
+func WriteBundle(chartDir string, md *chart.Metadata, lock *imagelock.ImagesLock) error {
+	il, err := NewImagesLock(lock)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(chartDir, BundleDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	if err := writeYAML(filepath.Join(dir, BundleFileName), NewBundle(md)); err != nil {
+		return err
+	}
+	return writeYAML(filepath.Join(dir, ImagesLockFileName), il)
+}
+
+func writeYAML(file string, data interface{}) error {
+	out, err := yaml.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %q: %w", filepath.Base(file), err)
+	}
+	if err := os.WriteFile(file, out, 0644); err != nil {
+		return fmt.Errorf("failed to write %q: %w", filepath.Base(file), err)
+	}
+	return nil
+}
+
+// repository returns the repository of the image reference
+func repository(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image reference %q: %w", image, err)
+	}
+	return ref.Context().Name(), nil
+}
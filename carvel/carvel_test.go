@@ -0,0 +1,81 @@
+package carvel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	amd64Digest = digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000001")
+	arm64Digest = digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000002")
+)
+
+func sampleLock() *imagelock.ImagesLock {
+	lock := imagelock.NewImagesLock()
+	lock.Images = imagelock.ImageList{
+		{Name: "mariadb", Chart: "mariadb", Image: "docker.io/bitnami/mariadb:11.0.2", Digests: []imagelock.DigestInfo{
+			{Digest: amd64Digest, Arch: "linux/amd64"},
+			{Digest: arm64Digest, Arch: "linux/arm64"},
+		}},
+		// Images shared by several charts are only locked once
+		{Name: "mariadb", Chart: "subchart", Image: "docker.io/bitnami/mariadb:11.0.2", Digests: []imagelock.DigestInfo{
+			{Digest: amd64Digest, Arch: "linux/amd64"},
+		}},
+	}
+	return lock
+}
+
+func TestNewBundle(t *testing.T) {
+	b := NewBundle(&chart.Metadata{
+		Name: "mariadb", Home: "https://mariadb.org",
+		Sources:     []string{"https://github.com/bitnami/charts"},
+		Maintainers: []*chart.Maintainer{{Name: "VMware", Email: "containers@example.com"}},
+	})
+	assert.Equal(t, &Bundle{
+		APIVersion: APIVersion, Kind: "Bundle", Metadata: Metadata{Name: "mariadb"},
+		Authors:  []Author{{Name: "VMware", Email: "containers@example.com"}},
+		Websites: []Website{{URL: "https://mariadb.org"}, {URL: "https://github.com/bitnami/charts"}},
+	}, b)
+	assert.Empty(t, NewBundle(&chart.Metadata{Name: "test"}).Websites)
+}
+
+func TestNewImagesLock(t *testing.T) {
+	il, err := NewImagesLock(sampleLock())
+	require.NoError(t, err)
+	assert.Equal(t, "ImagesLock", il.Kind)
+	assert.Equal(t, []ImageRef{
+		{Image: "index.docker.io/bitnami/mariadb@" + amd64Digest.String(), Annotations: map[string]string{kbldIDAnnotation: "docker.io/bitnami/mariadb:11.0.2"}},
+		{Image: "index.docker.io/bitnami/mariadb@" + arm64Digest.String(), Annotations: map[string]string{kbldIDAnnotation: "docker.io/bitnami/mariadb:11.0.2"}},
+	}, il.Images)
+
+	lock := imagelock.NewImagesLock()
+	lock.Images = imagelock.ImageList{{Name: "invalid", Image: "Invalid Image"}}
+	_, err = NewImagesLock(lock)
+	assert.ErrorContains(t, err, "failed to parse image reference")
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, WriteBundle(dir, &chart.Metadata{Name: "mariadb"}, sampleLock()))
+
+	data, err := os.ReadFile(filepath.Join(dir, BundleDir, BundleFileName))
+	require.NoError(t, err)
+	b := &Bundle{}
+	require.NoError(t, yaml.Unmarshal(data, b))
+	assert.Equal(t, "mariadb", b.Metadata.Name)
+
+	data, err = os.ReadFile(filepath.Join(dir, BundleDir, ImagesLockFileName))
+	require.NoError(t, err)
+	il := &ImagesLock{}
+	require.NoError(t, yaml.Unmarshal(data, il))
+	assert.Equal(t, APIVersion, il.APIVersion)
+	assert.Len(t, il.Images, 2)
+}
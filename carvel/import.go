@@ -0,0 +1,86 @@
+package carvel
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// IsBundle returns true if dir is an imgpkg bundle, that is, it includes an imgpkg images lock
+func IsBundle(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, BundleDir, ImagesLockFileName))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// ReadImagesLock reads the imgpkg images lock of the bundle in dir
+func ReadImagesLock(dir string) (*ImagesLock, error) {
+	data, err := os.ReadFile(filepath.Join(dir, BundleDir, ImagesLockFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imgpkg images lock: %w", err)
+	}
+	il := &ImagesLock{}
+	if err := yaml.Unmarshal(data, il); err != nil {
+		return nil, fmt.Errorf("failed to parse imgpkg images lock: %w", err)
+	}
+	if il.Kind != "ImagesLock" {
+		return nil, fmt.Errorf("unexpected imgpkg images lock kind %q", il.Kind)
+	}
+	return il, nil
+}
+
+// ToImagesLock translates the imgpkg images lock into the Images.lock of the Helm chart. The digests of
+// each locked image are read from its registry, so multi-platform images get the digest of each platform.
+// Images are named after their repository, and referenced by their original reference, recorded by kbld,
+// if known
+func (il *ImagesLock) ToImagesLock(md *chart.Metadata, opts ...imagelock.Option) (*imagelock.ImagesLock, error) {
+	cfg := imagelock.NewImagesLockConfig(opts...)
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = md.Name
+	lock.Chart.Version = md.Version
+	lock.Chart.AppVersion = md.AppVersion
+
+	images := make(map[string]*imagelock.ChartImage)
+	for _, ref := range il.Images {
+		r, err := name.NewDigest(ref.Image)
+		if err != nil {
+			return nil, fmt.Errorf("imgpkg images must be locked by digest: %w", err)
+		}
+		img := &imagelock.ChartImage{Image: ref.Image}
+		if err := img.FetchDigests(cfg); err != nil {
+			return nil, fmt.Errorf("failed to fetch image %q digests: %w", ref.Image, err)
+		}
+		originalRef := ref.Image
+		if id := ref.Annotations[kbldIDAnnotation]; id != "" {
+			originalRef = id
+		}
+		// Platforms locked separately are merged back into the same image
+		if existing, found := images[originalRef]; found {
+			existing.Digests = mergeDigests(existing.Digests, img.Digests)
+			continue
+		}
+		img.Name, img.Chart, img.Image = path.Base(r.Context().RepositoryStr()), md.Name, originalRef
+		images[originalRef] = img
+		lock.Images = append(lock.Images, img)
+	}
+	return lock, nil
+}
+
+// mergeDigests appends to digests the ones of the platforms it does not include
+func mergeDigests(digests []imagelock.DigestInfo, other []imagelock.DigestInfo) []imagelock.DigestInfo {
+	for _, d := range other {
+		found := false
+		for _, existing := range digests {
+			found = found || existing.Arch == d.Arch
+		}
+		if !found {
+			digests = append(digests, d)
+		}
+	}
+	return digests
+}
@@ -0,0 +1,68 @@
+package carvel
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestReadImagesLock(t *testing.T) {
+	dir := t.TempDir()
+	assert.False(t, IsBundle(dir))
+	require.NoError(t, WriteBundle(dir, &chart.Metadata{Name: "mariadb"}, sampleLock()))
+	assert.True(t, IsBundle(dir))
+	il, err := ReadImagesLock(dir)
+	require.NoError(t, err)
+	assert.Len(t, il.Images, 2)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, BundleDir, ImagesLockFileName), []byte("kind: Bundle\n"), 0644))
+	_, err = ReadImagesLock(dir)
+	assert.ErrorContains(t, err, `unexpected imgpkg images lock kind "Bundle"`)
+}
+
+func TestToImagesLock(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	// Lock one image for two platforms
+	imgData := &tu.ImageData{Name: "test", Image: fmt.Sprintf("%s/test:mytag", u.Host)}
+	chartImage := &imagelock.ChartImage{Name: "test", Chart: "mariadb", Image: imgData.Image}
+	for _, plat := range []string{"linux/amd64", "linux/arm64"} {
+		img, err := tu.CreateSingleArchImage(imgData, plat)
+		require.NoError(t, err)
+		d := imgData.Digests[len(imgData.Digests)-1]
+		ref, err := name.ParseReference(fmt.Sprintf("%s/test@%s", u.Host, d.Digest))
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+		chartImage.Digests = append(chartImage.Digests, imagelock.DigestInfo{Digest: d.Digest, Arch: d.Arch})
+	}
+	// Round trip a dt lock through the imgpkg format
+	lock := imagelock.NewImagesLock()
+	lock.Images = imagelock.ImageList{chartImage}
+	il, err := NewImagesLock(lock)
+	require.NoError(t, err)
+	translated, err := il.ToImagesLock(&chart.Metadata{Name: "mariadb", Version: "1.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, "mariadb", translated.Chart.Name)
+	assert.Equal(t, "1.0.0", translated.Chart.Version)
+	assert.NoError(t, translated.Validate(lock.Images))
+
+	il.Images = append(il.Images, ImageRef{Image: "example.com/test:latest"})
+	_, err = il.ToImagesLock(&chart.Metadata{Name: "mariadb"})
+	assert.ErrorContains(t, err, "imgpkg images must be locked by digest")
+}
@@ -0,0 +1,131 @@
+package chartutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// CachedImage describes an image stored in an images cache directory
+type CachedImage struct {
+	Digest digest.Digest
+	Size   int64
+	// LastUsed is the last time the image was stored in or restored from the cache
+	LastUsed time.Time
+}
+
+// restoreCachedImage copies the platform specific image from cacheDir into imagesDir, returning false if
+// it is not cached
+func restoreCachedImage(cacheDir string, imagesDir string, dgst imagelock.DigestInfo) bool {
+	if cacheDir == "" || verifyImageTar(cacheDir, dgst) != nil {
+		return false
+	}
+	cachedFile := getImageTarFile(cacheDir, dgst)
+	if linkOrCopyFile(cachedFile, getImageTarFile(imagesDir, dgst)) != nil {
+		return false
+	}
+	// The modification time tracks the last use, to prune the least recently used images first
+	now := time.Now()
+	_ = os.Chtimes(cachedFile, now, now)
+	return true
+}
+
+// cacheImage stores the platform specific image pulled into imagesDir in cacheDir, if not empty
+func cacheImage(cacheDir string, imagesDir string, dgst imagelock.DigestInfo) error {
+	if cacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create images cache: %w", err)
+	}
+	return linkOrCopyFile(getImageTarFile(imagesDir, dgst), getImageTarFile(cacheDir, dgst))
+}
+
+// linkOrCopyFile hard links src into dest, copying it if they are in different filesystems
+func linkOrCopyFile(src string, dest string) error {
+	_ = os.Remove(dest)
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dest), ".image-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
+
+// ListImagesCache returns the images stored in cacheDir, the least recently used first
+func ListImagesCache(cacheDir string) ([]CachedImage, error) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []CachedImage{}, nil
+		}
+		return nil, fmt.Errorf("failed to read images cache: %w", err)
+	}
+	images := make([]CachedImage, 0, len(entries))
+	for _, e := range entries {
+		hex, found := strings.CutSuffix(e.Name(), ".tar")
+		if !found || !e.Type().IsRegular() {
+			continue
+		}
+		dgst := digest.NewDigestFromEncoded(digest.SHA256, hex)
+		if dgst.Validate() != nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		images = append(images, CachedImage{Digest: dgst, Size: fi.Size(), LastUsed: fi.ModTime()})
+	}
+	sort.SliceStable(images, func(i, j int) bool { return images[i].LastUsed.Before(images[j].LastUsed) })
+	return images, nil
+}
+
+// PruneImagesCache removes the images in cacheDir not used for longer than maxAge, if not zero, and then the
+// least recently used ones until the cache fits in maxSize bytes, if not zero. It returns the removed images
+func PruneImagesCache(cacheDir string, maxAge time.Duration, maxSize int64) ([]CachedImage, error) {
+	images, err := ListImagesCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	var totalSize int64
+	for _, img := range images {
+		totalSize += img.Size
+	}
+	removed := make([]CachedImage, 0)
+	for _, img := range images {
+		expired := maxAge > 0 && time.Since(img.LastUsed) > maxAge
+		oversized := maxSize > 0 && totalSize > maxSize
+		if !expired && !oversized {
+			continue
+		}
+		if err := os.Remove(filepath.Join(cacheDir, img.Digest.Encoded()+".tar")); err != nil {
+			return removed, fmt.Errorf("failed to remove cached image %q: %w", img.Digest, err)
+		}
+		totalSize -= img.Size
+		removed = append(removed, img)
+	}
+	return removed, nil
+}
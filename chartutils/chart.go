@@ -2,10 +2,18 @@
 package chartutils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
+	"strings"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/sbom"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
@@ -28,6 +36,31 @@ func (c *Chart) ImagesDir() string {
 	return filepath.Join(c.RootDir(), "images")
 }
 
+// isWrapOnlyFile returns true for the files of the wrap that must not be included in the packaged Helm chart
+func isWrapOnlyFile(f string) bool {
+	if strings.HasPrefix(f, "/images/") || f == "/"+utils.ChecksumsFileName || f == "/"+metadata.FileName || f == "/"+metadata.DeltaFileName {
+		return true
+	}
+	for _, format := range sbom.SupportedFormats() {
+		if f == "/"+format.FileName() {
+			return true
+		}
+	}
+	// Provenance files of the original chart are no longer valid after relocating it
+	return path.Dir(f) == "/" && strings.HasSuffix(f, utils.ProvenanceExtension)
+}
+
+// Package writes the Helm chart, without the wrap specific files, into tarFile
+func (c *Chart) Package(tarFile string) error {
+	if err := utils.Tar(c.RootDir(), tarFile, utils.TarConfig{
+		Prefix: c.Name(),
+		Skip:   isWrapOnlyFile,
+	}); err != nil {
+		return fmt.Errorf("failed to untar filename %q: %w", c.RootDir(), err)
+	}
+	return nil
+}
+
 // File returns the chart.File for the provided name or nil if not found
 func (c *Chart) File(name string) *chart.File {
 	return getChartFile(c.Chart, name)
@@ -43,6 +76,12 @@ func (c *Chart) AbsFilePath(name string) string {
 	return filepath.Join(c.rootDir, name)
 }
 
+// AnnotationsKey returns the annotations key listing the chart images: the first of the configured keys, in
+// fallback order, present in the chart
+func (c *Chart) AnnotationsKey() string {
+	return imagelock.ResolveAnnotationsKey(c.Metadata.Annotations, c.annotationsKey)
+}
+
 // GetAnnotatedImages returns the chart images specified in the annotations
 func (c *Chart) GetAnnotatedImages() (imagelock.ImageList, error) {
 	return imagelock.GetImagesFromChartAnnotations(
@@ -65,6 +104,65 @@ func (c *Chart) Dependencies() []*Chart {
 	return deps
 }
 
+// MissingDependencies returns the names of the dependencies declared in Chart.yaml that are not
+// vendored under charts/
+func (c *Chart) MissingDependencies() []string {
+	vendored := make(map[string]bool)
+	for _, dep := range c.Chart.Dependencies() {
+		vendored[dep.Name()] = true
+	}
+	missing := make([]string, 0)
+	for _, dep := range c.Metadata.Dependencies {
+		if !vendored[dep.Name] {
+			missing = append(missing, dep.Name)
+		}
+	}
+	return missing
+}
+
+// VerifyLock checks the Chart.lock, if any, is in sync with the dependencies declared in Chart.yaml and
+// that the dependencies vendored under charts/ are the locked versions
+func (c *Chart) VerifyLock() error {
+	if c.Lock == nil {
+		return nil
+	}
+	if !c.lockInSync() {
+		return fmt.Errorf("the Chart.lock file is out of sync with the dependencies in Chart.yaml")
+	}
+	vendored := make(map[string]string)
+	for _, dep := range c.Chart.Dependencies() {
+		vendored[dep.Name()] = dep.Metadata.Version
+	}
+	var allErrors error
+	for _, dep := range c.Lock.Dependencies {
+		version, ok := vendored[dep.Name]
+		switch {
+		case !ok:
+			allErrors = errors.Join(allErrors, fmt.Errorf("dependency %q is not vendored under charts/", dep.Name))
+		case version != dep.Version:
+			allErrors = errors.Join(allErrors, fmt.Errorf("dependency %q is vendored with version %q but Chart.lock requires %q", dep.Name, version, dep.Version))
+		}
+	}
+	return allErrors
+}
+
+// lockInSync returns true if the Chart.lock digest matches the one "helm dependency update" computes
+// from the chart dependencies
+func (c *Chart) lockInSync() bool {
+	digested := []interface{}{[2][]*chart.Dependency{c.Metadata.Dependencies, c.Lock.Dependencies}}
+	if c.Metadata.APIVersion == chart.APIVersionV1 {
+		// requirements.lock files written by Helm v2 digest the requirements only
+		digested = append(digested, map[string][]*chart.Dependency{"dependencies": c.Metadata.Dependencies})
+	}
+	for _, v := range digested {
+		data, err := json.Marshal(v)
+		if err == nil && digest.FromBytes(data).String() == c.Lock.Digest {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadChart returns the Chart defined by path
 func LoadChart(path string, opts ...Option) (*Chart, error) {
 	cfg := NewConfiguration(opts...)
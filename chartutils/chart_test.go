@@ -2,13 +2,16 @@ package chartutils
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/go-digest"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 	"gopkg.in/yaml.v3"
+	helmchart "helm.sh/helm/v3/pkg/chart"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -90,6 +93,53 @@ func (suite *ChartUtilsTestSuite) TestLoadChart() {
 					assert.Fail(t, "cannot find dependant chart %q", depData.Name)
 				}
 			})
+			t.Run("VerifyLock", func(t *testing.T) {
+				assert.NoError(t, chart.VerifyLock(), "charts without Chart.lock are not verified")
+
+				// writeChartLock writes a Chart.lock locking the dependencies to versions, digested as
+				// "helm dependency update" does
+				writeChartLock := func(t *testing.T, versions map[string]string, digestedVersions map[string]string) *Chart {
+					dest := sb.TempFile()
+					require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": serverURL}))
+					chartDir := filepath.Join(dest, "chart1")
+					lockDeps := func(versions map[string]string) []*helmchart.Dependency {
+						deps := make([]*helmchart.Dependency, 0)
+						for _, dep := range chart.Metadata.Dependencies {
+							deps = append(deps, &helmchart.Dependency{Name: dep.Name, Repository: dep.Repository, Version: versions[dep.Name]})
+						}
+						return deps
+					}
+					digested, err := json.Marshal([2][]*helmchart.Dependency{chart.Metadata.Dependencies, lockDeps(digestedVersions)})
+					require.NoError(t, err)
+					// JSON is valid YAML
+					data, err := json.Marshal(&helmchart.Lock{Dependencies: lockDeps(versions), Digest: digest.FromBytes(digested).String()})
+					require.NoError(t, err)
+					require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.lock"), data, 0644))
+					lockedChart, err := LoadChart(chartDir)
+					require.NoError(t, err)
+					return lockedChart
+				}
+				vendoredVersions := map[string]string{"mariadb": "12.2.5", "common": "2.6.0"}
+
+				assert.NoError(t, writeChartLock(t, vendoredVersions, vendoredVersions).VerifyLock())
+
+				outdated := map[string]string{"mariadb": "12.2.4", "common": "2.6.0"}
+				assert.ErrorContains(t, writeChartLock(t, outdated, outdated).VerifyLock(),
+					`dependency "mariadb" is vendored with version "12.2.5" but Chart.lock requires "12.2.4"`)
+
+				assert.ErrorContains(t, writeChartLock(t, vendoredVersions, outdated).VerifyLock(),
+					"Chart.lock file is out of sync")
+			})
+			t.Run("MissingDependencies", func(t *testing.T) {
+				assert.Empty(t, chart.MissingDependencies())
+
+				dest := sb.TempFile()
+				require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": serverURL}))
+				require.NoError(t, os.RemoveAll(filepath.Join(dest, "chart1", "charts", "mariadb")))
+				partialChart, err := LoadChart(filepath.Join(dest, "chart1"))
+				require.NoError(t, err)
+				assert.Equal(t, []string{"mariadb"}, partialChart.MissingDependencies())
+			})
 
 			t.Run("GetImageAnnotations", func(t *testing.T) {
 				res, err := chart.GetAnnotatedImages()
@@ -124,3 +174,12 @@ func (suite *ChartUtilsTestSuite) TestLoadChart() {
 	})
 
 }
+
+func TestIsWrapOnlyFile(t *testing.T) {
+	for _, f := range []string{"/images/foo.tar", "/checksums.sha256", "/wrap.json", "/sbom.spdx.json", "/sbom.cdx.json", "/chart.tgz.prov"} {
+		assert.True(t, isWrapOnlyFile(f), f)
+	}
+	for _, f := range []string{"/Chart.yaml", "/values.yaml", "/templates/sbom.spdx.json", "/charts/sub/sbom.cdx.json"} {
+		assert.False(t, isWrapOnlyFile(f), f)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 	"gopkg.in/yaml.v3"
 
@@ -37,6 +39,10 @@ func AnnotateChart(chartPath string, opts ...Option) error {
 	// Make sure order is always the same
 	sort.Sort(res)
 
+	if err := checkUntaggedValuesImages(res, chart.Name(), cfg); err != nil {
+		return err
+	}
+
 	chartFile := filepath.Join(chartRoot, "Chart.yaml")
 
 	if err := writeAnnotationsToChart(res, chartFile, cfg); err != nil {
@@ -52,6 +58,29 @@ func AnnotateChart(chartPath string, opts ...Option) error {
 	return allErrors
 }
 
+// checkUntaggedValuesImages applies the untagged images policy to the images found in the chart values: they are
+// rejected with UntaggedError and reported with UntaggedAssumeLatest. UntaggedResolveDigest pins them when locking
+func checkUntaggedValuesImages(set ValuesImageElementList, chartName string, cfg *Configuration) error {
+	untagged := make([]string, 0)
+	for _, e := range set {
+		if url := e.URL(); imagelock.IsUntaggedImage(url) {
+			untagged = append(untagged, url)
+		}
+	}
+	if len(untagged) == 0 {
+		return nil
+	}
+	switch cfg.UntaggedPolicy {
+	case imagelock.UntaggedError:
+		return fmt.Errorf("found images without a tag or digest in Helm chart %q: %s", chartName, strings.Join(untagged, ", "))
+	case imagelock.UntaggedAssumeLatest:
+		for _, url := range untagged {
+			cfg.Log.Warnf("Image %q of Helm chart %q does not specify a tag, assuming %q", url, chartName, "latest")
+		}
+	}
+	return nil
+}
+
 // GetChartRoot returns the chart root directory to the chart provided (which may point to its Chart.yaml file)
 func GetChartRoot(chartPath string) (string, error) {
 	fi, err := os.Stat(chartPath)
@@ -65,6 +94,18 @@ func GetChartRoot(chartPath string) (string, error) {
 	return filepath.Abs(filepath.Dir(chartPath))
 }
 
+// annotationsKeyToWrite returns the first of the configured keys, in fallback order, already present in the
+// annotations, so it is updated, or the preferred key otherwise
+func annotationsKeyToWrite(annotations map[string]interface{}, key string) string {
+	keys := imagelock.AnnotationsKeys(key)
+	for _, k := range keys {
+		if _, ok := annotations[k]; ok {
+			return k
+		}
+	}
+	return keys[0]
+}
+
 func writeAnnotationsToChart(set ValuesImageElementList, chartFile string, cfg *Configuration) error {
 	// Nothing to write
 	if len(set) == 0 {
@@ -98,7 +139,7 @@ func writeAnnotationsToChart(set ValuesImageElementList, chartFile string, cfg *
 		data.Annotations = make(map[string]interface{})
 	}
 	// Do any necessary modifications to the annotations field
-	data.Annotations[cfg.AnnotationsKey] = string(imagesAnnotation)
+	data.Annotations[annotationsKeyToWrite(data.Annotations, cfg.AnnotationsKey)] = string(imagesAnnotation)
 	// Marshal the struct back into YAML
 	modifiedYAML, err := yaml.Marshal(&data)
 	if err != nil {
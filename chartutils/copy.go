@@ -0,0 +1,129 @@
+package chartutils
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CopyImages copies the images in the ImagesLock, with their locked platforms, from their registries into
+// the references in targets, keyed by the original image references, without storing them locally
+func CopyImages(lock *imagelock.ImagesLock, targets map[string]string, opts ...Option) error {
+	cfg := NewConfiguration(opts...)
+	ctx := cfg.Context
+
+	if err := cfg.RegistryFilter.Validate(lock.Images); err != nil {
+		return err
+	}
+
+	p, _ := cfg.ProgressBar.WithTotal(len(lock.Images)).UpdateTitle("Copying Images").Start()
+	defer p.Stop()
+
+	o := craneOptions(cfg)
+
+	for _, imgData := range lock.Images {
+		target, ok := targets[imgData.Image]
+		if !ok {
+			return fmt.Errorf("no target provided for image %q", imgData.Image)
+		}
+		select {
+		// Early abort if the context is done
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled execution")
+		default:
+			p.Add(1)
+			p.UpdateTitle(fmt.Sprintf("Copying image %q", imgData.Image))
+			if err := copyImageWithRetries(imgData, target, o, cfg, p); err != nil {
+				return fmt.Errorf("failed to copy image %q: %w", imgData.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyImageWithRetries copies the image into target, reporting its progress to the configured event handler
+func copyImageWithRetries(imgData *imagelock.ChartImage, target string, o crane.Options, cfg *Configuration, p widgets.ProgressBar) error {
+	ctx := cfg.Context
+	l := cfg.Log
+	maxRetries := cfg.MaxRetries
+
+	ev := newImageEvent("copy", imgData, ImageStarted)
+	cfg.ImageEventHandler(ev)
+	_, span := tracing.Start(ctx, "copy image", attribute.String("image", imgData.Image), attribute.String("target", target))
+
+	err := utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
+		if try > 0 {
+			// The context is done, so we are not retrying, just return the error
+			if ctx.Err() != nil {
+				return prevErr
+			}
+			l.Debugf("Failed to copy image: %v", prevErr)
+			cfg.ImageEventHandler(ev.withError(ImageRetrying, prevErr))
+			reportRateLimit(prevErr, p)
+			p.Warnf("Failed to copy image: retrying %d/%d", try, maxRetries)
+		}
+		dgst, err := copyImage(imgData, target, o, cfg.Mirrors)
+		if err != nil {
+			return err
+		}
+		ev.Digest = dgst
+		return nil
+	})
+	span.SetAttributes(attribute.String("digest", ev.Digest.String()))
+	tracing.End(span, err)
+	if err != nil {
+		cfg.ImageEventHandler(ev.withError(ImageFailed, err))
+		return err
+	}
+	cfg.ImageEventHandler(ev.withError(ImageCompleted, nil))
+	return nil
+}
+
+// copyImage writes into target the image index of the locked platforms of the image, streaming them from
+// its mirrors or registry, and returns its digest
+func copyImage(imgData *imagelock.ChartImage, target string, o crane.Options, mirrors imagelock.Mirrors) (digest.Digest, error) {
+	adds := make([]mutate.IndexAddendum, 0, len(imgData.Digests))
+	for _, dgst := range imgData.Digests {
+		img, err := fetchLockedImage(imgData.Image, dgst, o, mirrors)
+		if err != nil {
+			return "", err
+		}
+		add, err := indexAddendum(img)
+		if err != nil {
+			return "", err
+		}
+		// Fall back to the locked platform for images whose config does not record it
+		if add.Descriptor.Platform == nil {
+			if add.Descriptor.Platform, err = v1.ParsePlatform(dgst.Arch); err != nil {
+				return "", fmt.Errorf("failed to parse platform %q: %w", dgst.Arch, err)
+			}
+		}
+		adds = append(adds, add)
+	}
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.DockerManifestList), adds...)
+
+	ref, err := name.ParseReference(target, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image reference %q: %w", target, err)
+	}
+	if err := remote.WriteIndex(ref, idx, o.Remote...); err != nil {
+		return "", fmt.Errorf("failed to write image index: %w", err)
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get image index digest: %w", err)
+	}
+	return digest.Digest(h.String()), nil
+}
@@ -0,0 +1,62 @@
+package chartutils
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *ChartUtilsTestSuite) TestCopyImages() {
+	require := suite.Require()
+	assert := suite.Assert()
+
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", u.Host)
+	require.NoError(err)
+
+	lock := imagelock.NewImagesLock()
+	targets := make(map[string]string)
+	for _, img := range images {
+		chartImage := &imagelock.ChartImage{Name: img.Name, Image: fmt.Sprintf("%s/%s", u.Host, img.Image)}
+		for _, d := range img.Digests {
+			chartImage.Digests = append(chartImage.Digests, imagelock.DigestInfo{Arch: d.Arch, Digest: d.Digest})
+		}
+		lock.Images = append(lock.Images, chartImage)
+		targets[chartImage.Image] = fmt.Sprintf("%s/copies/%s", u.Host, img.Image)
+	}
+
+	events := make([]ImageEvent, 0)
+	require.NoError(CopyImages(lock, targets, WithImageEventHandler(func(ev ImageEvent) {
+		events = append(events, ev)
+	})))
+	for i, img := range lock.Images {
+		// Only the locked platforms are copied
+		remoteDigests, err := tu.ReadRemoteImageManifest(targets[img.Image])
+		require.NoError(err)
+		assert.Len(remoteDigests, len(img.Digests))
+		for _, dgst := range img.Digests {
+			assert.Equal(dgst.Digest.Hex(), remoteDigests[dgst.Arch].Digest.Hex())
+		}
+		indexDigest, err := crane.Digest(targets[img.Image])
+		require.NoError(err)
+		completed := events[2*i+1]
+		assert.Equal(ImageCompleted, completed.State)
+		assert.Equal("copy", completed.Operation)
+		assert.Equal(indexDigest, completed.Digest.String())
+	}
+
+	err = CopyImages(lock, map[string]string{})
+	assert.ErrorContains(err, "no target provided")
+}
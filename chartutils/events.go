@@ -0,0 +1,66 @@
+package chartutils
+
+import (
+	"os"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// ImageEventState defines the state of an image transfer
+type ImageEventState string
+
+const (
+	// ImageStarted indicates the image transfer started
+	ImageStarted ImageEventState = "started"
+	// ImageRetrying indicates the image transfer failed and will be retried
+	ImageRetrying ImageEventState = "retrying"
+	// ImageCompleted indicates the image was transferred
+	ImageCompleted ImageEventState = "completed"
+	// ImageFailed indicates the image transfer failed
+	ImageFailed ImageEventState = "failed"
+	// ImageCached indicates the image was already pulled, so it was not transferred again
+	ImageCached ImageEventState = "cached"
+)
+
+// ImageEvent describes the progress of pulling or pushing an image
+type ImageEvent struct {
+	// Operation is either "pull", "push" or "copy"
+	Operation string `json:"operation"`
+	Chart     string `json:"chart"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	// Arch is only set when pulling, as images are pushed with all their platforms
+	Arch   string          `json:"arch,omitempty"`
+	Digest digest.Digest   `json:"digest,omitempty"`
+	Bytes  int64           `json:"bytes,omitempty"`
+	State  ImageEventState `json:"state"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ImageEventHandler receives the progress events of image transfers
+type ImageEventHandler func(ImageEvent)
+
+func newImageEvent(operation string, img *imagelock.ChartImage, state ImageEventState) ImageEvent {
+	return ImageEvent{Operation: operation, Chart: img.Chart, Name: img.Name, Image: img.Image, State: state}
+}
+
+// withError returns a copy of the event reporting err
+func (e ImageEvent) withError(state ImageEventState, err error) ImageEvent {
+	e.State = state
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// imagesSize returns the size of the image tarballs of img stored in imagesDir
+func imagesSize(img *imagelock.ChartImage, imagesDir string) int64 {
+	var size int64
+	for _, dgst := range img.Digests {
+		if fi, err := os.Stat(getImageTarFile(imagesDir, dgst)); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
@@ -1,9 +1,12 @@
 package chartutils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -13,10 +16,28 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/opencontainers/go-digest"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// craneOptions returns the options used to access the registries
+func craneOptions(cfg *Configuration) crane.Options {
+	tr := cfg.Transport
+	if tr == nil {
+		tr = remote.DefaultTransport
+	}
+	return crane.GetOptions(
+		crane.WithContext(cfg.Context),
+		crane.WithAuthFromKeychain(newRefreshingKeychain(cfg.Keychain)),
+		crane.WithTransport(newRateLimitTransport(tr, registriesRateLimiter, cfg.MaxRequestsPerHost)),
+	)
+}
+
 func getNumberOfArtifacts(images imagelock.ImageList) int {
 	n := 0
 	for _, imgDesc := range images {
@@ -25,24 +46,44 @@ func getNumberOfArtifacts(images imagelock.ImageList) int {
 	return n
 }
 
+// getNumberOfUniqueArtifacts returns the number of distinct digests of the images, as images retagged under
+// different names share their tarballs
+func getNumberOfUniqueArtifacts(images imagelock.ImageList) int {
+	digests := make(map[digest.Digest]struct{})
+	for _, imgDesc := range images {
+		for _, dgst := range imgDesc.Digests {
+			digests[dgst.Digest] = struct{}{}
+		}
+	}
+	return len(digests)
+}
+
 // PullImages downloads the list of images specified in the provided ImagesLock
 func PullImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) error {
 
 	cfg := NewConfiguration(opts...)
 	ctx := cfg.Context
-	o := crane.GetOptions(crane.WithContext(ctx))
+	o := craneOptions(cfg)
+
+	if err := cfg.RegistryFilter.Validate(lock.Images); err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(imagesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create bundle directory: %v", err)
 	}
-	l := cfg.Log
 
-	p, _ := cfg.ProgressBar.WithTotal(getNumberOfArtifacts(lock.Images)).UpdateTitle("Pulling Images").Start()
+	p, _ := cfg.ProgressBar.WithTotal(getNumberOfUniqueArtifacts(lock.Images)).UpdateTitle("Pulling Images").Start()
 	defer p.Stop()
-	maxRetries := cfg.MaxRetries
 
+	pulled := make(map[digest.Digest]struct{})
 	for _, imgDesc := range lock.Images {
 		for _, dgst := range imgDesc.Digests {
+			if _, found := pulled[dgst.Digest]; found {
+				reportSharedImage(imgDesc, dgst, imagesDir, cfg)
+				continue
+			}
+			pulled[dgst.Digest] = struct{}{}
 			select {
 			// Early abort if the context is done
 			case <-ctx.Done():
@@ -50,22 +91,7 @@ func PullImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) er
 			default:
 				p.Add(1)
 				p.UpdateTitle(fmt.Sprintf("Saving image %s/%s %s (%s)", imgDesc.Chart, imgDesc.Name, imgDesc.Image, dgst.Arch))
-				err := utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
-					if try > 0 {
-						// The context is done, so we are not retrying, just return the error
-						if ctx.Err() != nil {
-							return prevErr
-						}
-						l.Debugf("Failed to pull image: %v", prevErr)
-						p.Warnf("Failed to pull image: retrying %d/%d", try, maxRetries)
-					}
-					if _, err := pullImage(imgDesc.Image, dgst, imagesDir, o); err != nil {
-						return err
-					}
-					return nil
-				})
-
-				if err != nil {
+				if err := pullImageWithRetries(imgDesc, dgst, imagesDir, o, cfg, p); err != nil {
 					return fmt.Errorf("failed to pull image %q: %w", imgDesc.Name, err)
 				}
 			}
@@ -75,19 +101,81 @@ func PullImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) er
 	return nil
 }
 
+// reportSharedImage reports the platform specific image as cached, as it shares the digest, and so the
+// tarball, of an image already pulled
+func reportSharedImage(imgDesc *imagelock.ChartImage, dgst imagelock.DigestInfo, imagesDir string, cfg *Configuration) {
+	cfg.Log.Debugf("Image %q (%s) shares the digest %s of an image already pulled", imgDesc.Image, dgst.Arch, dgst.Digest)
+	ev := newImageEvent("pull", imgDesc, ImageCached)
+	ev.Arch, ev.Digest = dgst.Arch, dgst.Digest
+	if fi, err := os.Stat(getImageTarFile(imagesDir, dgst)); err == nil {
+		ev.Bytes = fi.Size()
+	}
+	cfg.ImageEventHandler(ev)
+}
+
+// pullImageWithRetries pulls the platform specific image, reporting its progress to the configured event handler
+func pullImageWithRetries(imgDesc *imagelock.ChartImage, dgst imagelock.DigestInfo, imagesDir string, o crane.Options, cfg *Configuration, p widgets.ProgressBar) error {
+	ctx := cfg.Context
+	l := cfg.Log
+	maxRetries := cfg.MaxRetries
+
+	ev := newImageEvent("pull", imgDesc, ImageStarted)
+	ev.Arch, ev.Digest = dgst.Arch, dgst.Digest
+	// Reuse the images pulled by a previous execution, or for another chart
+	if verifyImageTar(imagesDir, dgst) == nil || restoreCachedImage(cfg.ImagesCacheDir, imagesDir, dgst) {
+		if fi, err := os.Stat(getImageTarFile(imagesDir, dgst)); err == nil {
+			ev.Bytes = fi.Size()
+		}
+		cfg.ImageEventHandler(ev.withError(ImageCached, nil))
+		return nil
+	}
+	cfg.ImageEventHandler(ev)
+	_, span := tracing.Start(ctx, "pull image", attribute.String("image", imgDesc.Image),
+		attribute.String("arch", dgst.Arch), attribute.String("digest", dgst.Digest.String()))
+
+	err := utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
+		if try > 0 {
+			// The context is done, so we are not retrying, just return the error
+			if ctx.Err() != nil {
+				return prevErr
+			}
+			l.Debugf("Failed to pull image: %v", prevErr)
+			cfg.ImageEventHandler(ev.withError(ImageRetrying, prevErr))
+			reportRateLimit(prevErr, p)
+			p.Warnf("Failed to pull image: retrying %d/%d", try, maxRetries)
+		}
+		imgFile, err := pullImageFromSources(imgDesc.Image, dgst, imagesDir, o, cfg)
+		if err != nil {
+			return err
+		}
+		if fi, err := os.Stat(imgFile); err == nil {
+			ev.Bytes = fi.Size()
+		}
+		return nil
+	})
+	span.SetAttributes(attribute.Int64("bytes", ev.Bytes))
+	tracing.End(span, err)
+	if err != nil {
+		cfg.ImageEventHandler(ev.withError(ImageFailed, err))
+		return err
+	}
+	if err := cacheImage(cfg.ImagesCacheDir, imagesDir, dgst); err != nil {
+		l.Debugf("Failed to cache image: %v", err)
+	}
+	cfg.ImageEventHandler(ev.withError(ImageCompleted, nil))
+	return nil
+}
+
 // PushImages push the list of images in imagesDir to the destination specified in the ImagesLock
 func PushImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) error {
 	cfg := NewConfiguration(opts...)
-	l := cfg.Log
-
 	ctx := cfg.Context
 
 	p, _ := cfg.ProgressBar.WithTotal(len(lock.Images)).UpdateTitle("Pushing Images").Start()
 	defer p.Stop()
 
-	o := crane.GetOptions(crane.WithContext(ctx))
+	o := craneOptions(cfg)
 
-	maxRetries := cfg.MaxRetries
 	for _, imgData := range lock.Images {
 		select {
 		// Early abort if the context is done
@@ -96,18 +184,7 @@ func PushImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) er
 		default:
 			p.Add(1)
 			p.UpdateTitle(fmt.Sprintf("Pushing image %q", imgData.Image))
-			err := utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
-				if try > 0 {
-					// The context is done, so we are not retrying, just return the error
-					if ctx.Err() != nil {
-						return prevErr
-					}
-					l.Debugf("Failed to push image: %v", prevErr)
-					p.Warnf("Failed to push image: retrying %d/%d", try, maxRetries)
-				}
-				return pushImage(imgData, imagesDir, o)
-			})
-			if err != nil {
+			if err := pushImageWithRetries(imgData, imagesDir, o, cfg, p); err != nil {
 				return fmt.Errorf("failed to push image %q: %w", imgData.Name, err)
 			}
 		}
@@ -115,77 +192,238 @@ func PushImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) er
 	return nil
 }
 
-func buildImageIndex(image *imagelock.ChartImage, imagesDir string) (v1.ImageIndex, error) {
-	adds := make([]mutate.IndexAddendum, 0, len(image.Digests))
+// pushImageWithRetries pushes the image with all its platforms, reporting its progress to the configured event handler
+func pushImageWithRetries(imgData *imagelock.ChartImage, imagesDir string, o crane.Options, cfg *Configuration, p widgets.ProgressBar) error {
+	ctx := cfg.Context
+	l := cfg.Log
+	maxRetries := cfg.MaxRetries
 
-	base := mutate.IndexMediaType(empty.Index, types.DockerManifestList)
-	for _, dgstData := range image.Digests {
-		imgFileName := getImageTarFile(imagesDir, dgstData)
+	ev := newImageEvent("push", imgData, ImageStarted)
+	ev.Bytes = imagesSize(imgData, imagesDir)
+	cfg.ImageEventHandler(ev)
+	_, span := tracing.Start(ctx, "push image", attribute.String("image", imgData.Image))
 
-		img, err := crane.Load(imgFileName)
+	err := utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
+		if try > 0 {
+			// The context is done, so we are not retrying, just return the error
+			if ctx.Err() != nil {
+				return prevErr
+			}
+			l.Debugf("Failed to push image: %v", prevErr)
+			cfg.ImageEventHandler(ev.withError(ImageRetrying, prevErr))
+			reportRateLimit(prevErr, p)
+			p.Warnf("Failed to push image: retrying %d/%d", try, maxRetries)
+		}
+		dgst, err := pushImage(imgData, imagesDir, o)
 		if err != nil {
-			return nil, fmt.Errorf("loading %s as tarball: %w", imgFileName, err)
+			return err
 		}
+		ev.Digest = dgst
+		return nil
+	})
+	span.SetAttributes(attribute.String("digest", ev.Digest.String()), attribute.Int64("bytes", ev.Bytes))
+	tracing.End(span, err)
+	if err != nil {
+		cfg.ImageEventHandler(ev.withError(ImageFailed, err))
+		return err
+	}
+	cfg.ImageEventHandler(ev.withError(ImageCompleted, nil))
+	return nil
+}
 
-		newDesc, err := partial.Descriptor(img)
+// BuildImageIndex returns the image index of the platforms of image, built from its image tarballs in imagesDir
+func BuildImageIndex(image *imagelock.ChartImage, imagesDir string) (v1.ImageIndex, error) {
+	adds := make([]mutate.IndexAddendum, 0, len(image.Digests))
+
+	base := mutate.IndexMediaType(empty.Index, types.DockerManifestList)
+	for _, dgstData := range image.Digests {
+		img, err := LoadImage(imagesDir, dgstData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create descriptor: %w", err)
+			return nil, err
 		}
-		cf, err := img.ConfigFile()
+		add, err := indexAddendum(img)
 		if err != nil {
-			return nil, fmt.Errorf("failed to obtain image config file: %w", err)
+			return nil, err
 		}
-		newDesc.Platform = cf.Platform()
-		adds = append(adds, mutate.IndexAddendum{
-			Add:        img,
-			Descriptor: *newDesc,
-		})
+		adds = append(adds, add)
 	}
 	return mutate.AppendManifests(base, adds...), nil
 }
 
-func pushImage(imgData *imagelock.ChartImage, imagesDir string, o crane.Options) error {
-	idx, err := buildImageIndex(imgData, imagesDir)
+// indexAddendum returns the entry of img, with its platform, in an image index
+func indexAddendum(img v1.Image) (mutate.IndexAddendum, error) {
+	newDesc, err := partial.Descriptor(img)
+	if err != nil {
+		return mutate.IndexAddendum{}, fmt.Errorf("failed to create descriptor: %w", err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return mutate.IndexAddendum{}, fmt.Errorf("failed to obtain image config file: %w", err)
+	}
+	newDesc.Platform = cf.Platform()
+	return mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: *newDesc,
+	}, nil
+}
+
+// ImageIndexDigest returns the digest of the image index pushed for img, built from its image tarballs in imagesDir
+func ImageIndexDigest(img *imagelock.ChartImage, imagesDir string) (digest.Digest, error) {
+	idx, err := BuildImageIndex(img, imagesDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build image index: %w", err)
+	}
+	h, err := idx.Digest()
 	if err != nil {
-		return fmt.Errorf("failed to build image index: %w", err)
+		return "", fmt.Errorf("failed to get image index digest: %w", err)
+	}
+	return digest.Digest(h.String()), nil
+}
+
+// pushImage pushes the image index built from the image tarballs and returns its digest
+func pushImage(imgData *imagelock.ChartImage, imagesDir string, o crane.Options) (digest.Digest, error) {
+	idx, err := BuildImageIndex(imgData, imagesDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build image index: %w", err)
 	}
 
 	ref, err := name.ParseReference(imgData.Image, o.Name...)
 	if err != nil {
-		return fmt.Errorf("failed to parse image reference %q: %w", imgData.Image, err)
+		return "", fmt.Errorf("failed to parse image reference %q: %w", imgData.Image, err)
 	}
 
 	if err := remote.WriteIndex(ref, idx, o.Remote...); err != nil {
-		return fmt.Errorf("failed to write image index: %w", err)
+		return "", fmt.Errorf("failed to write image index: %w", err)
 	}
 
-	return nil
+	h, err := idx.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get image index digest: %w", err)
+	}
+	return digest.Digest(h.String()), nil
+}
+
+// LoadImage loads the image with the digest dgst from its tarball in imagesDir. The image is looked up by its
+// digest, so a tarball holding a different image is never loaded in its place
+func LoadImage(imagesDir string, dgst imagelock.DigestInfo) (v1.Image, error) {
+	imgFileName := getImageTarFile(imagesDir, dgst)
+	img, err := loadImageTar(imgFileName, dgst.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s as tarball: %w", imgFileName, err)
+	}
+	return img, nil
 }
 
 func getImageTarFile(imagesDir string, dgst imagelock.DigestInfo) string {
 	return filepath.Join(imagesDir, fmt.Sprintf("%s.tar", dgst.Digest.Encoded()))
 }
 
-func pullImage(image string, digest imagelock.DigestInfo, imagesDir string, o crane.Options) (string, error) {
-	imgFileName := getImageTarFile(imagesDir, digest)
-
-	src := fmt.Sprintf("%s@%s", image, digest.Digest)
+// fetchLockedImage returns the platform specific image of image with the locked digest, from its mirrors or registry
+func fetchLockedImage(image string, digest imagelock.DigestInfo, o crane.Options, mirrors imagelock.Mirrors) (v1.Image, error) {
+	// Images relocated by digest only are already referenced by their index digest
+	src := fmt.Sprintf("%s@%s", strings.SplitN(image, "@", 2)[0], digest.Digest)
 	ref, err := name.ParseReference(src, o.Name...)
 	if err != nil {
-		return "", fmt.Errorf("parsing reference %q: %w", src, err)
+		return nil, fmt.Errorf("parsing reference %q: %w", src, err)
 	}
 
-	rmt, err := remote.Get(ref, o.Remote...)
+	rmt, err := imagelock.Fetch(mirrors, ref, func(r name.Reference) (*remote.Descriptor, error) {
+		return remote.Get(r, o.Remote...)
+	}, o.Name...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	img, err := rmt.Image()
+	return rmt.Image()
+}
+
+func pullImage(image string, digest imagelock.DigestInfo, imagesDir string, o crane.Options, mirrors imagelock.Mirrors) (string, error) {
+	imgFileName := getImageTarFile(imagesDir, digest)
+
+	img, err := fetchLockedImage(image, digest, o, mirrors)
 	if err != nil {
 		return "", err
 	}
 
-	if err := crane.Save(img, image, imgFileName); err != nil {
+	if err := saveImageTar(img, image, imgFileName); err != nil {
 		return "", fmt.Errorf("failed to save image %q to %q: %w", image, imgFileName, err)
 	}
 	return imgFileName, nil
 }
+
+// pullImageFromSources pulls the image from its mirrors or registry, falling back to the local Docker daemon
+// if enabled
+func pullImageFromSources(image string, dgst imagelock.DigestInfo, imagesDir string, o crane.Options, cfg *Configuration) (string, error) {
+	imgFile, err := pullImage(image, dgst, imagesDir, o, cfg.Mirrors)
+	if err == nil || !cfg.LocalDaemon {
+		return imgFile, err
+	}
+	imgFile, daemonErr := pullDaemonImage(cfg.Context, image, dgst, imagesDir, cfg.DaemonHost)
+	if daemonErr != nil {
+		return "", errors.Join(err, daemonErr)
+	}
+	return imgFile, nil
+}
+
+// pullDaemonImage saves the image stored in the local Docker daemon, which must match the locked digest
+func pullDaemonImage(ctx context.Context, image string, dgst imagelock.DigestInfo, imagesDir string, host string) (string, error) {
+	img, err := imagelock.DaemonImage(ctx, image, host)
+	if err != nil {
+		return "", err
+	}
+	h, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the digest of the local Docker daemon image %q: %w", image, err)
+	}
+	if h.String() != dgst.Digest.String() {
+		return "", fmt.Errorf("the local Docker daemon image %q digest %s does not match the locked %s", image, h, dgst.Digest)
+	}
+	imgFileName := getImageTarFile(imagesDir, dgst)
+	if err := saveImageTar(img, image, imgFileName); err != nil {
+		return "", fmt.Errorf("failed to save image %q to %q: %w", image, imgFileName, err)
+	}
+	return imgFileName, nil
+}
+
+// VerifyImages checks the images in imagesDir match the digests in the provided ImagesLock,
+// without accessing the network
+func VerifyImages(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) error {
+	cfg := NewConfiguration(opts...)
+
+	p, _ := cfg.ProgressBar.WithTotal(getNumberOfUniqueArtifacts(lock.Images)).UpdateTitle("Verifying Images").Start()
+	defer p.Stop()
+
+	var allErrors error
+	// The tarballs shared by images with the same digest are only verified once
+	verified := make(map[digest.Digest]error)
+	for _, imgDesc := range lock.Images {
+		for _, dgst := range imgDesc.Digests {
+			err, found := verified[dgst.Digest]
+			if !found {
+				p.Add(1)
+				p.UpdateTitle(fmt.Sprintf("Verifying image %s/%s %s (%s)", imgDesc.Chart, imgDesc.Name, imgDesc.Image, dgst.Arch))
+				err = verifyImageTar(imagesDir, dgst)
+				verified[dgst.Digest] = err
+			}
+			if err != nil {
+				allErrors = errors.Join(allErrors, fmt.Errorf("image %q (%s): %w", imgDesc.Image, dgst.Arch, err))
+			}
+		}
+	}
+	return allErrors
+}
+
+func verifyImageTar(imagesDir string, dgst imagelock.DigestInfo) error {
+	imgFile := getImageTarFile(imagesDir, dgst)
+	if !utils.FileExists(imgFile) {
+		return fmt.Errorf("image file %q not found", filepath.Base(imgFile))
+	}
+	img, err := loadImageTar(imgFile, dgst.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to load image file %q: %w", filepath.Base(imgFile), err)
+	}
+	// Validate the config and layers, which are not covered by the manifest digest check
+	if err := validate.Image(img); err != nil {
+		return fmt.Errorf("invalid image file %q: %w", filepath.Base(imgFile), err)
+	}
+	return nil
+}
@@ -4,14 +4,21 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 )
@@ -21,7 +28,17 @@ func (suite *ChartUtilsTestSuite) TestPullImages() {
 	t := suite.T()
 
 	silentLog := log.New(io.Discard, "", 0)
-	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	reg := registry.New(registry.Logger(silentLog))
+	// rateLimited is the number of requests to reject with 429 Too Many Requests
+	var rateLimited atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimited.Add(-1) >= 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
 	defer s.Close()
 	u, err := url.Parse(s.URL)
 	if err != nil {
@@ -55,16 +72,140 @@ func (suite *ChartUtilsTestSuite) TestPullImages() {
 
 		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
 		require.NoError(err)
-		require.NoError(PullImages(lock, imagesDir))
+		events := make([]ImageEvent, 0)
+		require.NoError(PullImages(lock, imagesDir, WithImageEventHandler(func(ev ImageEvent) {
+			events = append(events, ev)
+		})))
 
 		require.DirExists(imagesDir)
 
+		require.Len(events, 2*len(images[0].Digests))
+		for i, digestData := range images[0].Digests {
+			started, completed := events[2*i], events[2*i+1]
+			suite.Assert().Equal(ImageStarted, started.State)
+			suite.Assert().Equal(ImageCompleted, completed.State)
+			suite.Assert().Equal(digestData.Digest, completed.Digest)
+			suite.Assert().Equal(digestData.Arch, completed.Arch)
+			suite.Assert().Greater(completed.Bytes, int64(0))
+		}
+
 		for _, imgData := range images {
 			for _, digestData := range imgData.Digests {
 				imgFile := filepath.Join(imagesDir, fmt.Sprintf("%s.tar", digestData.Digest.Encoded()))
 				suite.Assert().FileExists(imgFile)
 			}
 		}
+
+		// Pulling again reuses the existing images
+		events = make([]ImageEvent, 0)
+		require.NoError(PullImages(lock, imagesDir, WithImageEventHandler(func(ev ImageEvent) {
+			events = append(events, ev)
+		})))
+		require.Len(events, len(images[0].Digests))
+		for _, ev := range events {
+			suite.Assert().Equal(ImageCached, ev.State)
+			suite.Assert().Greater(ev.Bytes, int64(0))
+		}
+	})
+	suite.T().Run("Pulls the images sharing digests once", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "RepositoryURL": serverURL},
+		))
+		chartDir := filepath.Join(dest, scenarioName)
+		imagesDir := filepath.Join(chartDir, "images")
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		// The retag is not published, so pulling it would fail
+		retag := *lock.Images[0]
+		retag.Name, retag.Image = "retag", fmt.Sprintf("%s/retag:1.0.0", serverURL)
+		lock.Images = append(lock.Images, &retag)
+
+		events := make([]ImageEvent, 0)
+		require.NoError(PullImages(lock, imagesDir, WithMaxRetries(0), WithImageEventHandler(func(ev ImageEvent) {
+			events = append(events, ev)
+		})))
+		require.Len(events, 3*len(images[0].Digests))
+		for i, digestData := range retag.Digests {
+			shared := events[2*len(images[0].Digests)+i]
+			suite.Assert().Equal(ImageCached, shared.State)
+			suite.Assert().Equal("retag", shared.Name)
+			suite.Assert().Equal(digestData.Digest, shared.Digest)
+			suite.Assert().Greater(shared.Bytes, int64(0))
+		}
+		require.NoError(VerifyImages(lock, imagesDir))
+	})
+	suite.T().Run("Reuses images from the cache", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "RepositoryURL": serverURL},
+		))
+		chartDir := filepath.Join(dest, scenarioName)
+		cacheDir := sb.TempFile()
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		require.NoError(PullImages(lock, filepath.Join(chartDir, "images"), WithImagesCache(cacheDir)))
+
+		// A different destination gets the images from the cache
+		imagesDir := sb.TempFile()
+		events := make([]ImageEvent, 0)
+		require.NoError(PullImages(lock, imagesDir, WithImagesCache(cacheDir), WithImageEventHandler(func(ev ImageEvent) {
+			events = append(events, ev)
+		})))
+		require.Len(events, len(images[0].Digests))
+		for _, ev := range events {
+			suite.Assert().Equal(ImageCached, ev.State)
+		}
+		for _, digestData := range images[0].Digests {
+			suite.Assert().FileExists(filepath.Join(imagesDir, fmt.Sprintf("%s.tar", digestData.Digest.Encoded())))
+		}
+	})
+	suite.T().Run("Waits when rate limited", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "RepositoryURL": serverURL},
+		))
+		chartDir := filepath.Join(dest, scenarioName)
+		imagesDir := filepath.Join(chartDir, "images")
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		retries := make([]ImageEvent, 0)
+		rateLimited.Store(1)
+		start := time.Now()
+		require.NoError(PullImages(lock, imagesDir, WithImageEventHandler(func(ev ImageEvent) {
+			if ev.State == ImageRetrying {
+				retries = append(retries, ev)
+			}
+		})))
+		require.GreaterOrEqual(time.Since(start), time.Second)
+		require.Len(retries, 1)
+		require.Contains(retries[0].Error, "rate limited by "+serverURL)
+	})
+	suite.T().Run("Pulls images from mirrors", func(t *testing.T) {
+		// The images are only available in the mirror
+		const registry = "registry.example.invalid"
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": registry, "Images": images, "Name": chartName, "RepositoryURL": registry},
+		))
+		chartDir := filepath.Join(dest, scenarioName)
+		imagesDir := filepath.Join(chartDir, "images")
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		require.Error(PullImages(lock, imagesDir, WithMaxRetries(0)))
+
+		mirrors := make(imagelock.Mirrors)
+		mirrors.Add(registry, serverURL)
+		require.NoError(PullImages(lock, imagesDir, WithMirrors(mirrors)))
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				suite.Assert().FileExists(filepath.Join(imagesDir, fmt.Sprintf("%s.tar", digestData.Digest.Encoded())))
+			}
+		}
 	})
 }
 
@@ -135,8 +276,14 @@ func (suite *ChartUtilsTestSuite) TestPushImages() {
 			require.NoError(PushImages(lock, imagesDir))
 
 			// Verify the images were pushed
-			for _, img := range images {
+			for i, img := range images {
 				src := fmt.Sprintf("%s/%s", u.Host, img.Image)
+				indexDigest, err := ImageIndexDigest(lock.Images[i], imagesDir)
+				require.NoError(err)
+				remoteIndexDigest, err := crane.Digest(src)
+				require.NoError(err)
+				assert.Equal(remoteIndexDigest, indexDigest.String())
+
 				remoteDigests, err := tu.ReadRemoteImageManifest(src)
 				if err != nil {
 					t.Fatal(err)
@@ -148,3 +295,82 @@ func (suite *ChartUtilsTestSuite) TestPushImages() {
 		})
 	})
 }
+
+func (suite *ChartUtilsTestSuite) TestVerifyImages() {
+	t := suite.T()
+	sb := suite.sb
+	require := suite.Require()
+	assert := suite.Assert()
+
+	imageData := tu.ImageData{Name: "test", Image: "test:mytag"}
+	craneImgs, err := tu.CreateSampleImages(&imageData, []string{"linux/amd64", "linux/arm"})
+	require.NoError(err)
+
+	lock := imagelock.NewImagesLock()
+	chartImage := &imagelock.ChartImage{Name: imageData.Name, Image: imageData.Image, Chart: "test"}
+	for _, d := range imageData.Digests {
+		chartImage.Digests = append(chartImage.Digests, imagelock.DigestInfo{Digest: d.Digest, Arch: d.Arch})
+	}
+	lock.Images = append(lock.Images, chartImage)
+
+	imagesDir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(err)
+	imgFiles := make([]string, 0)
+	for _, img := range craneImgs {
+		d, err := img.Digest()
+		require.NoError(err)
+		imgFile := filepath.Join(imagesDir, fmt.Sprintf("%s.tar", d.Hex))
+		require.NoError(crane.Save(img, imageData.Image, imgFile))
+		imgFiles = append(imgFiles, imgFile)
+	}
+
+	t.Run("Verifies valid images", func(t *testing.T) {
+		require.NoError(VerifyImages(lock, imagesDir))
+	})
+	t.Run("Detects missing images", func(t *testing.T) {
+		require.NoError(os.Rename(imgFiles[0], imgFiles[0]+".bak"))
+		defer os.Rename(imgFiles[0]+".bak", imgFiles[0])
+		assert.ErrorContains(VerifyImages(lock, imagesDir), "not found")
+	})
+	t.Run("Detects mismatching images", func(t *testing.T) {
+		data, err := os.ReadFile(imgFiles[0])
+		require.NoError(err)
+		defer os.WriteFile(imgFiles[0], data, 0644)
+
+		otherData, err := os.ReadFile(imgFiles[1])
+		require.NoError(err)
+		require.NoError(os.WriteFile(imgFiles[0], otherData, 0644))
+		assert.ErrorContains(VerifyImages(lock, imagesDir), "digest mismatch")
+
+		_, err = BuildImageIndex(chartImage, imagesDir)
+		assert.ErrorContains(err, "digest mismatch")
+	})
+	t.Run("Loads the locked image from tarballs holding several images", func(t *testing.T) {
+		data, err := os.ReadFile(imgFiles[0])
+		require.NoError(err)
+		defer os.WriteFile(imgFiles[0], data, 0644)
+
+		tagged := func(tag string) name.Reference {
+			ref, err := name.NewTag(tag)
+			require.NoError(err)
+			return ref
+		}
+		// Another image was written into the same tarball
+		require.NoError(tarball.MultiRefWriteToFile(imgFiles[0], map[name.Reference]v1.Image{
+			tagged("other:1.0.0"): craneImgs[1], tagged("test:mytag"): craneImgs[0],
+		}))
+
+		img, err := LoadImage(imagesDir, chartImage.Digests[0])
+		require.NoError(err)
+		d, err := img.Digest()
+		require.NoError(err)
+		assert.Equal(chartImage.Digests[0].Digest.String(), d.String())
+		require.NoError(VerifyImages(lock, imagesDir))
+
+		require.NoError(tarball.MultiRefWriteToFile(imgFiles[0], map[name.Reference]v1.Image{
+			tagged("other:1.0.0"): craneImgs[1], tagged("another:2.0.0"): empty.Image,
+		}))
+		_, err = LoadImage(imagesDir, chartImage.Digests[0])
+		assert.ErrorContains(err, "none of the 2 images in the tarball has the digest")
+	})
+}
@@ -0,0 +1,151 @@
+package chartutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/opencontainers/go-digest"
+)
+
+// imageCopyBufferSize is the size of the buffer the image layers are copied through
+const imageCopyBufferSize = 1 << 20
+
+// loadImageTar returns the image with the digest dgst stored in the tarball file. Tarballs holding several
+// images are searched by the tags of their images
+func loadImageTar(file string, dgst digest.Digest) (v1.Image, error) {
+	opener := func() (io.ReadCloser, error) { return os.Open(file) }
+	m, err := tarball.LoadManifest(opener)
+	if err != nil {
+		return nil, err
+	}
+	tags := []*name.Tag{nil}
+	if len(m) > 1 {
+		tags = make([]*name.Tag, 0)
+		for _, desc := range m {
+			for _, t := range desc.RepoTags {
+				if tag, err := name.NewTag(t); err == nil {
+					tags = append(tags, &tag)
+				}
+			}
+		}
+	}
+	for _, tag := range tags {
+		img, err := tarball.Image(opener, tag)
+		if err != nil {
+			return nil, err
+		}
+		d, err := img.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate image digest: %w", err)
+		}
+		if d.String() == dgst.String() {
+			return img, nil
+		}
+		if len(m) == 1 {
+			return nil, fmt.Errorf("digest mismatch: expected %s, got %s", dgst, d)
+		}
+	}
+	return nil, fmt.Errorf("none of the %d images in the tarball has the digest %s", len(m), dgst)
+}
+
+// saveImageTar writes img, tagged as image, into the tarball file through a temporary file, so concurrent or
+// interrupted writes never leave a tarball mixing different images
+func saveImageTar(img v1.Image, image string, file string) error {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return fmt.Errorf("failed to parse image reference %q: %w", image, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".image-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeImageTar(img, ref, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// writeImageTar streams img, tagged as ref if it is a tag, into w in the docker save format. The layers are
+// copied from their source one at a time through a fixed size buffer, and closed as soon as they are written,
+// so the memory used does not depend on the size of the image
+func writeImageTar(img v1.Image, ref name.Reference, w io.Writer) error {
+	m, err := tarball.ComputeManifest(map[name.Reference]v1.Image{ref: img})
+	if err != nil {
+		return fmt.Errorf("failed to compute tarball manifest: %w", err)
+	}
+	manifestData, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to serialize tarball manifest: %w", err)
+	}
+	tw := tar.NewWriter(w)
+	cfgData, err := img.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to read image config: %w", err)
+	}
+	if err := writeTarEntry(tw, m[0].Config, bytes.NewReader(cfgData), int64(len(cfgData)), nil); err != nil {
+		return err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("failed to read image layers: %w", err)
+	}
+	buf := make([]byte, imageCopyBufferSize)
+	written := make(map[string]struct{})
+	for i, l := range layers {
+		layerFile := m[0].Layers[i]
+		if _, found := written[layerFile]; found {
+			continue
+		}
+		written[layerFile] = struct{}{}
+		if err := writeLayerEntry(tw, layerFile, l, buf); err != nil {
+			return fmt.Errorf("failed to write layer %q: %w", layerFile, err)
+		}
+	}
+	if err := writeTarEntry(tw, "manifest.json", bytes.NewReader(manifestData), int64(len(manifestData)), nil); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeLayerEntry streams the compressed blob of the layer into the tar entry file
+func writeLayerEntry(tw *tar.Writer, file string, l v1.Layer, buf []byte) error {
+	size, err := l.Size()
+	if err != nil {
+		return err
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return writeTarEntry(tw, file, rc, size, buf)
+}
+
+// writeTarEntry writes the size bytes read from r as the tar entry file, copying them through buf if not nil
+func writeTarEntry(tw *tar.Writer, file string, r io.Reader, size int64, buf []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: file, Mode: 0644, Typeflag: tar.TypeReg, Size: size}); err != nil {
+		return err
+	}
+	// Hide any WriterTo implementation so the data is always copied through buf
+	n, err := io.CopyBuffer(tw, struct{ io.Reader }{r}, buf)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("got %d bytes, expected %d", n, size)
+	}
+	return nil
+}
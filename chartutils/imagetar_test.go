@@ -0,0 +1,116 @@
+package chartutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/opencontainers/go-digest"
+)
+
+// trackedLayer records whether the readers of its compressed blob are closed
+type trackedLayer struct {
+	v1.Layer
+	opened, closed int
+	// sizeDelta is added to the size reported for the layer
+	sizeDelta int64
+}
+
+type trackedReadCloser struct {
+	io.ReadCloser
+	l *trackedLayer
+}
+
+func (rc *trackedReadCloser) Close() error {
+	rc.l.closed++
+	return rc.ReadCloser.Close()
+}
+
+func (l *trackedLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	l.opened++
+	return &trackedReadCloser{ReadCloser: rc, l: l}, nil
+}
+
+func (l *trackedLayer) Size() (int64, error) {
+	size, err := l.Layer.Size()
+	return size + l.sizeDelta, err
+}
+
+func (suite *ChartUtilsTestSuite) TestSaveImageTar() {
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	newImage := func(layers ...*trackedLayer) v1.Image {
+		adds := make([]v1.Layer, 0, len(layers))
+		for _, l := range layers {
+			adds = append(adds, l)
+		}
+		base, err := random.Image(0, 0)
+		require.NoError(err)
+		img, err := mutate.AppendLayers(base, adds...)
+		require.NoError(err)
+		return img
+	}
+	newLayer := func() *trackedLayer {
+		l, err := random.Layer(1024*1024+17, "application/vnd.docker.image.rootfs.diff.tar.gzip")
+		require.NoError(err)
+		return &trackedLayer{Layer: l}
+	}
+	dir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(err)
+
+	suite.T().Run("Streams the image layers into the tarball", func(t *testing.T) {
+		shared := newLayer()
+		layers := []*trackedLayer{newLayer(), shared, newLayer(), shared}
+		img := newImage(layers...)
+		d, err := img.Digest()
+		require.NoError(err)
+		file := filepath.Join(dir, d.Hex+".tar")
+		require.NoError(saveImageTar(img, "example.com/app:1.0.0", file))
+
+		for _, l := range layers {
+			assert.Equal(1, l.opened)
+			assert.Equal(l.opened, l.closed)
+		}
+		loaded, err := loadImageTar(file, digest.Digest(d.String()))
+		require.NoError(err)
+		require.NoError(validate.Image(loaded))
+		// The tarball is readable by the usual tools
+		_, err = crane.LoadTag(file, "example.com/app:1.0.0")
+		require.NoError(err)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(err)
+		assert.Len(entries, 1, "temporary files are not removed")
+	})
+	suite.T().Run("Does not leave partial tarballs", func(t *testing.T) {
+		truncated := newLayer()
+		truncated.sizeDelta = 1
+		img := newImage(newLayer(), truncated)
+		file := filepath.Join(sb.TempFile(), "image.tar")
+		require.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+
+		size, err := truncated.Layer.Size()
+		require.NoError(err)
+		assert.ErrorContains(saveImageTar(img, "example.com/app:1.0.0", file), fmt.Sprintf("got %d bytes, expected %d", size, size+1))
+		assert.NoFileExists(file)
+		assert.Equal(truncated.opened, truncated.closed)
+
+		ref, err := name.ParseReference("example.com/app:1.0.0")
+		require.NoError(err)
+		assert.Error(writeImageTar(img, ref, io.Discard))
+	})
+}
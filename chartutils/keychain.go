@@ -0,0 +1,62 @@
+package chartutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// credentialsRefreshInterval is how often the registry credentials are resolved again during long transfers,
+// so short-lived tokens, like the ECR ones, do not expire in the middle of them
+var credentialsRefreshInterval = 5 * time.Minute
+
+// refreshingKeychain wraps a keychain so the authenticators it returns resolve the credentials again
+// once they are older than the refresh interval
+type refreshingKeychain struct {
+	keychain authn.Keychain
+	interval time.Duration
+}
+
+func newRefreshingKeychain(kc authn.Keychain) authn.Keychain {
+	return &refreshingKeychain{keychain: kc, interval: credentialsRefreshInterval}
+}
+
+// Resolve implements authn.Keychain
+func (k *refreshingKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	auth, err := k.keychain.Resolve(target)
+	if err != nil || auth == authn.Anonymous {
+		return auth, err
+	}
+	return &refreshingAuthenticator{
+		keychain: k.keychain, target: target, interval: k.interval, auth: auth, resolvedOn: time.Now(),
+	}, nil
+}
+
+// refreshingAuthenticator is an authn.Authenticator resolving its credentials from the keychain
+// again when they are older than the refresh interval
+type refreshingAuthenticator struct {
+	keychain   authn.Keychain
+	target     authn.Resource
+	interval   time.Duration
+	auth       authn.Authenticator
+	resolvedOn time.Time
+	mu         sync.Mutex
+}
+
+// Authorization implements authn.Authenticator
+func (a *refreshingAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if time.Since(a.resolvedOn) >= a.interval {
+		auth, err := a.keychain.Resolve(a.target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh credentials for %q: %w", a.target.RegistryStr(), err)
+		}
+		a.auth = auth
+		a.resolvedOn = time.Now()
+	}
+	return a.auth.Authorization()
+}
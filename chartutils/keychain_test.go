@@ -0,0 +1,53 @@
+package chartutils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// tokenKeychain issues a new token every time it is resolved, like credential helpers of short-lived tokens
+type tokenKeychain struct {
+	issued int
+}
+
+func (k *tokenKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	k.issued++
+	return authn.FromConfig(authn.AuthConfig{Username: "AWS", Password: fmt.Sprintf("token-%d", k.issued)}), nil
+}
+
+func (suite *ChartUtilsTestSuite) TestRefreshingKeychain() {
+	require := suite.Require()
+
+	reg, err := name.NewRegistry("123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	require.NoError(err)
+
+	password := func(auth authn.Authenticator) string {
+		cfg, err := auth.Authorization()
+		require.NoError(err)
+		return cfg.Password
+	}
+
+	suite.T().Run("Keeps the credentials while they are fresh", func(_ *testing.T) {
+		kc := &tokenKeychain{}
+		auth, err := (&refreshingKeychain{keychain: kc, interval: time.Hour}).Resolve(reg)
+		require.NoError(err)
+		require.Equal("token-1", password(auth))
+		require.Equal("token-1", password(auth))
+	})
+	suite.T().Run("Resolves the credentials again once expired", func(_ *testing.T) {
+		kc := &tokenKeychain{}
+		auth, err := (&refreshingKeychain{keychain: kc, interval: time.Millisecond}).Resolve(reg)
+		require.NoError(err)
+		time.Sleep(2 * time.Millisecond)
+		require.Equal("token-2", password(auth))
+	})
+	suite.T().Run("Does not wrap anonymous access", func(_ *testing.T) {
+		auth, err := newRefreshingKeychain(authn.NewMultiKeychain()).Resolve(reg)
+		require.NoError(err)
+		require.Equal(authn.Anonymous, auth)
+	})
+}
@@ -2,6 +2,9 @@ package chartutils
 
 import (
 	"context"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
 
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
 
@@ -16,6 +19,26 @@ type Configuration struct {
 	Context        context.Context
 	ProgressBar    widgets.ProgressBar
 	MaxRetries     int
+	RegistryFilter *imagelock.RegistryFilter
+	// ImageEventHandler receives the progress events when pulling and pushing images
+	ImageEventHandler ImageEventHandler
+	// Keychain resolves the credentials used to access the registries
+	Keychain authn.Keychain
+	// Transport, if not nil, is the HTTP transport used to access the registries
+	Transport http.RoundTripper
+	// Mirrors lists the mirrors images are pulled from before trying their registries
+	Mirrors imagelock.Mirrors
+	// ImagesCacheDir, if not empty, is a directory where the pulled images are cached, so they are reused
+	// when pulling other charts
+	ImagesCacheDir string
+	// LocalDaemon reads the images missing from their registries from the local Docker daemon
+	LocalDaemon bool
+	// DaemonHost, if not empty, is the address of the local daemon, instead of the DOCKER_HOST one
+	DaemonHost string
+	// UntaggedPolicy defines how the images specifying neither a tag nor a digest are handled
+	UntaggedPolicy imagelock.UntaggedPolicy
+	// MaxRequestsPerHost, if positive, bounds the number of concurrent requests to each registry
+	MaxRequestsPerHost int
 }
 
 // WithContext provides an execution context
@@ -32,6 +55,62 @@ func WithMaxRetries(retries int) func(cfg *Configuration) {
 	}
 }
 
+// WithKeychain provides the keychain used to authenticate against the registries
+func WithKeychain(kc authn.Keychain) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.Keychain = kc
+	}
+}
+
+// WithTransport provides the HTTP transport used to access the registries
+func WithTransport(tr http.RoundTripper) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.Transport = tr
+	}
+}
+
+// WithMirrors provides the registries mirrors images are pulled from
+func WithMirrors(m imagelock.Mirrors) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.Mirrors = m
+	}
+}
+
+// WithImagesCache caches the pulled images in dir, reusing them across charts
+func WithImagesCache(dir string) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.ImagesCacheDir = dir
+	}
+}
+
+// WithLocalDaemon reads the images missing from their registries from the local Docker daemon
+func WithLocalDaemon(enabled bool) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.LocalDaemon = enabled
+	}
+}
+
+// WithDaemonHost reads the local daemon images from the daemon listening on host, such as the podman API socket
+func WithDaemonHost(host string) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.DaemonHost = host
+	}
+}
+
+// WithUntaggedPolicy configures how the images specifying neither a tag nor a digest are handled
+func WithUntaggedPolicy(policy imagelock.UntaggedPolicy) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.UntaggedPolicy = policy
+	}
+}
+
+// WithMaxRequestsPerHost bounds the number of concurrent requests to each registry
+func WithMaxRequestsPerHost(n int) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.MaxRequestsPerHost = n
+	}
+}
+
 // WithProgressBar provides a ProgressBar for long running operations
 func WithProgressBar(pb widgets.ProgressBar) func(cfg *Configuration) {
 	return func(cfg *Configuration) {
@@ -42,10 +121,14 @@ func WithProgressBar(pb widgets.ProgressBar) func(cfg *Configuration) {
 // NewConfiguration returns a new Configuration
 func NewConfiguration(opts ...Option) *Configuration {
 	cfg := &Configuration{
-		AnnotationsKey: imagelock.DefaultAnnotationsKey,
-		Context:        context.Background(),
-		ProgressBar:    widgets.NewSilentProgressBar(),
-		MaxRetries:     3,
+		AnnotationsKey:    imagelock.DefaultAnnotationsKey,
+		Context:           context.Background(),
+		ProgressBar:       widgets.NewSilentProgressBar(),
+		Log:               log.SilentLog,
+		MaxRetries:        3,
+		ImageEventHandler: func(ImageEvent) {},
+		Keychain:          authn.DefaultKeychain,
+		UntaggedPolicy:    imagelock.UntaggedAssumeLatest,
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -70,3 +153,17 @@ func WithAnnotationsKey(str string) func(cfg *Configuration) {
 		cfg.AnnotationsKey = str
 	}
 }
+
+// WithRegistryFilter restricts the registries images can be pulled from
+func WithRegistryFilter(f *imagelock.RegistryFilter) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.RegistryFilter = f
+	}
+}
+
+// WithImageEventHandler provides a handler for the image pull and push progress events
+func WithImageEventHandler(h ImageEventHandler) func(cfg *Configuration) {
+	return func(cfg *Configuration) {
+		cfg.ImageEventHandler = h
+	}
+}
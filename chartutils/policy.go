@@ -0,0 +1,45 @@
+package chartutils
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/policy"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// ImageLabels returns the labels defined in the configuration of the image digest, previously pulled into imagesDir
+func ImageLabels(imagesDir string, dgst imagelock.DigestInfo) (map[string]string, error) {
+	imgFile := getImageTarFile(imagesDir, dgst)
+	if !utils.FileExists(imgFile) {
+		return nil, fmt.Errorf("image has not been pulled")
+	}
+	img, err := crane.Load(imgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image from %q: %w", imgFile, err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image configuration: %w", err)
+	}
+	labels := make(map[string]string)
+	for k, v := range cfg.Config.Labels {
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+// CheckImagesPolicy evaluates the policies in engine against the images in the provided ImagesLock,
+// previously pulled into imagesDir, and returns the violations found
+func CheckImagesPolicy(lock *imagelock.ImagesLock, imagesDir string, engine *policy.Engine, opts ...Option) ([]policy.Violation, error) {
+	cfg := NewConfiguration(opts...)
+
+	inputs, err := policy.NewInputs(lock, func(_ *imagelock.ChartImage, dgst imagelock.DigestInfo) (map[string]string, error) {
+		return ImageLabels(imagesDir, dgst)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return engine.Evaluate(cfg.Context, inputs)
+}
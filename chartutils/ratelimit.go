@@ -0,0 +1,209 @@
+package chartutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+)
+
+const (
+	// defaultRateLimitDelay is the delay before retrying a rate limited request without a Retry-After header
+	defaultRateLimitDelay = 10 * time.Second
+	// maxRateLimitDelay caps the delay requested by the registries
+	maxRateLimitDelay = 10 * time.Minute
+)
+
+// RateLimitError is returned when a registry rejects a request because of its rate limits
+type RateLimitError struct {
+	Host string
+	// RetryAfter is how long to wait before retrying the request
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s, retry after %s", e.Host, e.RetryAfter)
+}
+
+// parseRetryAfter returns the delay requested by the rate limit headers of a 429 response
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	delay := defaultRateLimitDelay
+	if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(v); err == nil {
+			delay = date.Sub(now)
+		}
+	} else if v := h.Get("RateLimit-Reset"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRateLimitDelay {
+		delay = maxRateLimitDelay
+	}
+	return delay
+}
+
+// registriesRateLimiter is shared by all the registry requests of the process, so the pulls and pushes to
+// the same registry back off together and share its requests budget
+var registriesRateLimiter = newHostsRateLimiter()
+
+// hostState is the rate limiting state of a host
+type hostState struct {
+	// resumeAt is the time the host accepts requests again
+	resumeAt time.Time
+	// inflight is the number of requests to the host in progress
+	inflight int
+	// released is closed, and replaced, when a request to the host finishes
+	released chan struct{}
+}
+
+// hostsRateLimiter keeps the time each rate limited host accepts requests again, so all the concurrent
+// requests to the host back off together instead of each of them hitting the limit again, and bounds the
+// number of concurrent requests to each host
+type hostsRateLimiter struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+func newHostsRateLimiter() *hostsRateLimiter {
+	return &hostsRateLimiter{hosts: make(map[string]*hostState)}
+}
+
+// host returns the state of host. It must be called with the mutex held
+func (l *hostsRateLimiter) host(host string) *hostState {
+	st, ok := l.hosts[host]
+	if !ok {
+		st = &hostState{released: make(chan struct{})}
+		l.hosts[host] = st
+	}
+	return st
+}
+
+// block holds the requests to host for delay
+func (l *hostsRateLimiter) block(host string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.host(host)
+	if t := time.Now().Add(delay); t.After(st.resumeAt) {
+		st.resumeAt = t
+	}
+}
+
+// acquire waits until host accepts requests again and, if budget is positive, has less than budget
+// requests in progress, or ctx is done. The request must be released once finished
+func (l *hostsRateLimiter) acquire(ctx context.Context, host string, budget int) error {
+	for {
+		l.mu.Lock()
+		st := l.host(host)
+		delay := time.Until(st.resumeAt)
+		if delay <= 0 && (budget <= 0 || st.inflight < budget) {
+			st.inflight++
+			l.mu.Unlock()
+			return nil
+		}
+		released := st.released
+		l.mu.Unlock()
+
+		if err := waitResumeOrRelease(ctx, delay, released); err != nil {
+			return err
+		}
+	}
+}
+
+// waitResumeOrRelease waits for delay, if positive, or until released is closed, whatever happens first
+func waitResumeOrRelease(ctx context.Context, delay time.Duration, released <-chan struct{}) error {
+	var resumed <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		resumed = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+	case <-released:
+	}
+	return nil
+}
+
+// release finishes a request to host acquired with acquire
+func (l *hostsRateLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.host(host)
+	st.inflight--
+	close(st.released)
+	st.released = make(chan struct{})
+}
+
+// releasingBody releases the request slot of the response once its body is closed, as the body is
+// still being downloaded from the host until then
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// rateLimitTransport turns the 429 responses of the registries into RateLimitError errors, so they
+// are retried after the requested delay, holding the rest of the requests to the same host meanwhile.
+// If budget is positive, it also bounds the number of concurrent requests to each host
+type rateLimitTransport struct {
+	inner   http.RoundTripper
+	limiter *hostsRateLimiter
+	budget  int
+}
+
+func newRateLimitTransport(inner http.RoundTripper, limiter *hostsRateLimiter, budget int) *rateLimitTransport {
+	return &rateLimitTransport{inner: inner, limiter: limiter, budget: budget}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.limiter.acquire(req.Context(), host, t.budget); err != nil {
+		return nil, err
+	}
+	release := func() { t.limiter.release(host) }
+	res, err := t.inner.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if res.StatusCode != http.StatusTooManyRequests {
+		res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+		return res, nil
+	}
+	res.Body.Close()
+	release()
+	delay := parseRetryAfter(res.Header, time.Now())
+	t.limiter.block(host, delay)
+	return nil, &RateLimitError{Host: host, RetryAfter: delay}
+}
+
+// reportRateLimit reports to the progress bar the delay requested by the registry if err is a
+// RateLimitError. The retried requests wait for it in the rate limited transport
+func reportRateLimit(err error, p widgets.ProgressBar) {
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		return
+	}
+	p.Warnf("Rate limited by %s, waiting %s", rlErr.Host, rlErr.RetryAfter)
+}
@@ -0,0 +1,172 @@
+package chartutils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+)
+
+func (suite *ChartUtilsTestSuite) TestParseRetryAfter() {
+	now := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"Uses the Retry-After seconds", map[string]string{"Retry-After": "30"}, 30 * time.Second},
+		{"Uses the Retry-After date", map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}, time.Minute},
+		{"Uses the RateLimit-Reset seconds", map[string]string{"RateLimit-Reset": "45"}, 45 * time.Second},
+		{"Defaults without headers", map[string]string{}, defaultRateLimitDelay},
+		{"Defaults with invalid headers", map[string]string{"Retry-After": "soon"}, defaultRateLimitDelay},
+		{"Does not wait for past dates", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+		{"Caps the delay", map[string]string{"Retry-After": "86400"}, maxRateLimitDelay},
+	} {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			h := make(http.Header)
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			if got := parseRetryAfter(h, now); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func (suite *ChartUtilsTestSuite) TestRateLimitTransport() {
+	require := suite.Require()
+
+	var rateLimited atomic.Int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if rateLimited.Add(-1) >= 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer limited.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+
+	limiter := newHostsRateLimiter()
+	tr := newRateLimitTransport(http.DefaultTransport, limiter, 0)
+	get := func(u string) error {
+		return roundTrip(tr, u)
+	}
+
+	rateLimited.Store(1)
+	err := get(limited.URL)
+	var rlErr *RateLimitError
+	require.True(errors.As(err, &rlErr))
+	u, err := url.Parse(limited.URL)
+	require.NoError(err)
+	require.Equal(u.Host, rlErr.Host)
+	require.Equal(time.Second, rlErr.RetryAfter)
+
+	suite.T().Run("Does not hold other hosts", func(_ *testing.T) {
+		start := time.Now()
+		require.NoError(get(other.URL))
+		require.Less(time.Since(start), 500*time.Millisecond)
+	})
+	suite.T().Run("Holds the concurrent requests to the rate limited host", func(_ *testing.T) {
+		start := time.Now()
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- get(limited.URL)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(err)
+		}
+		require.GreaterOrEqual(time.Since(start), 500*time.Millisecond)
+	})
+	suite.T().Run("Shares the backoff among the transports using the limiter", func(_ *testing.T) {
+		rateLimited.Store(1)
+		require.Error(get(limited.URL))
+		start := time.Now()
+		require.NoError(roundTrip(newRateLimitTransport(http.DefaultTransport, limiter, 0), limited.URL))
+		require.GreaterOrEqual(time.Since(start), 500*time.Millisecond)
+	})
+	suite.T().Run("Reports the rate limit without waiting for it", func(_ *testing.T) {
+		start := time.Now()
+		reportRateLimit(&RateLimitError{Host: "example.com", RetryAfter: time.Minute}, widgets.NewSilentProgressBar())
+		require.Less(time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func (suite *ChartUtilsTestSuite) TestRateLimitTransportBudget() {
+	require := suite.Require()
+
+	var inflight, maxInflight atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			prev := maxInflight.Load()
+			if n <= prev || maxInflight.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	limiter := newHostsRateLimiter()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each pull creates its own transport, all sharing the limiter
+			errs <- roundTrip(newRateLimitTransport(http.DefaultTransport, limiter, 2), s.URL)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(err)
+	}
+	require.EqualValues(2, maxInflight.Load())
+
+	suite.T().Run("Releases the failed requests", func(_ *testing.T) {
+		tr := newRateLimitTransport(http.DefaultTransport, limiter, 1)
+		for i := 0; i < 3; i++ {
+			require.Error(roundTrip(tr, "http://127.0.0.1:1"))
+		}
+		require.NoError(roundTrip(tr, s.URL))
+	})
+}
+
+// roundTrip sends a GET request to u through tr, reading and closing the response body
+func roundTrip(tr http.RoundTripper, u string) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(io.Discard, res.Body)
+	return err
+}
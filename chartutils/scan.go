@@ -0,0 +1,43 @@
+package chartutils
+
+import (
+	"fmt"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/scan"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// ScanImages scans the images in the provided ImagesLock, previously pulled into imagesDir,
+// using the specified scanner
+func ScanImages(lock *imagelock.ImagesLock, imagesDir string, scanner scan.Scanner, opts ...Option) ([]*scan.Report, error) {
+	cfg := NewConfiguration(opts...)
+	ctx := cfg.Context
+
+	p, _ := cfg.ProgressBar.WithTotal(getNumberOfArtifacts(lock.Images)).UpdateTitle("Scanning Images").Start()
+	defer p.Stop()
+
+	reports := make([]*scan.Report, 0)
+	for _, imgDesc := range lock.Images {
+		for _, dgst := range imgDesc.Digests {
+			select {
+			// Early abort if the context is done
+			case <-ctx.Done():
+				return nil, fmt.Errorf("cancelled execution")
+			default:
+				p.Add(1)
+				p.UpdateTitle(fmt.Sprintf("Scanning image %s/%s %s (%s)", imgDesc.Chart, imgDesc.Name, imgDesc.Image, dgst.Arch))
+				imgFile := getImageTarFile(imagesDir, dgst)
+				if !utils.FileExists(imgFile) {
+					return nil, fmt.Errorf("image %q (%s) has not been pulled", imgDesc.Image, dgst.Arch)
+				}
+				vulns, err := scanner.Scan(ctx, imgFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to scan image %q (%s): %w", imgDesc.Image, dgst.Arch, err)
+				}
+				reports = append(reports, &scan.Report{Image: imgDesc.Image, Arch: dgst.Arch, Vulnerabilities: vulns})
+			}
+		}
+	}
+	return reports, nil
+}
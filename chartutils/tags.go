@@ -0,0 +1,93 @@
+package chartutils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// PinMutableImages rewrites the images annotated in the chart (and its dependencies) using mutable tags
+// so they point to the digest currently published in their registries. It returns the list of pinned images
+func PinMutableImages(chartPath string, opts ...imagelock.Option) (imagelock.ImageList, error) {
+	lockCfg := imagelock.NewImagesLockConfig(opts...)
+	chart, err := LoadChart(chartPath, WithAnnotationsKey(lockCfg.AnnotationsKey))
+	if err != nil {
+		return nil, err
+	}
+	return pinImages(chart, imagelock.ImageList.MutableImages, opts...)
+}
+
+// PinUntaggedImages rewrites the images annotated in the chart (and its dependencies) specifying neither a tag
+// nor a digest so they point to the digest currently published for their "latest" tag. It returns the list of
+// pinned images
+func PinUntaggedImages(chartPath string, opts ...imagelock.Option) (imagelock.ImageList, error) {
+	lockCfg := imagelock.NewImagesLockConfig(opts...)
+	chart, err := LoadChart(chartPath, WithAnnotationsKey(lockCfg.AnnotationsKey))
+	if err != nil {
+		return nil, err
+	}
+	return pinImages(chart, imagelock.ImageList.UntaggedImages, opts...)
+}
+
+// UntaggedImages returns the images annotated in the chart (and its dependencies) specifying neither a tag
+// nor a digest
+func UntaggedImages(chartPath string, opts ...Option) (imagelock.ImageList, error) {
+	chart, err := LoadChart(chartPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	untagged := make(imagelock.ImageList, 0)
+	charts := []*Chart{chart}
+	for len(charts) > 0 {
+		current := charts[0]
+		charts = append(charts[1:], current.Dependencies()...)
+		images, err := current.GetAnnotatedImages()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read images from annotations of Helm chart %q: %v", current.Name(), err)
+		}
+		untagged = append(untagged, images.UntaggedImages()...)
+	}
+	return untagged, nil
+}
+
+// pinImages rewrites the images of the chart, and its dependencies, returned by selectImages so they point
+// to the digest currently published in their registries
+func pinImages(chart *Chart, selectImages func(imagelock.ImageList) imagelock.ImageList, opts ...imagelock.Option) (imagelock.ImageList, error) {
+	images, err := chart.GetAnnotatedImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images from annotations: %v", err)
+	}
+	pinned := make(imagelock.ImageList, 0)
+	for _, img := range selectImages(images) {
+		ref, err := imagelock.PinImage(img.Image, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pin image %q: %w", img.Image, err)
+		}
+		img.Image = ref
+		pinned = append(pinned, img)
+	}
+	if len(pinned) > 0 {
+		data, err := images.ToAnnotation()
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.YamlFileSet(chart.AbsFilePath("Chart.yaml"), map[string]string{
+			fmt.Sprintf("$.annotations['%s']", chart.AnnotationsKey()): string(data),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write annotations: %v", err)
+		}
+	}
+
+	var allErrors error
+	for _, dep := range chart.Dependencies() {
+		depPinned, err := pinImages(dep, selectImages, opts...)
+		if err != nil {
+			allErrors = errors.Join(allErrors, fmt.Errorf("failed to pin images of Helm chart %q: %v", dep.Name(), err))
+			continue
+		}
+		pinned = append(pinned, depPinned...)
+	}
+	return pinned, allErrors
+}
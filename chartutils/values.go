@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"reflect"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 	"gopkg.in/yaml.v2"
 
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 )
 
@@ -170,7 +172,7 @@ func findImageElementsInMap(data map[string]interface{}, id string) []*ValuesIma
 
 	for k, v := range data {
 		if v, ok := v.(map[string]interface{}); ok {
-			elements = append(elements, findImageElementsInMap(v, fmt.Sprintf("%s.%s", id, k))...)
+			elements = append(elements, findImageElementsInMap(v, utils.YamlPathChild(id, k))...)
 		}
 	}
 	return elements
@@ -199,3 +201,61 @@ func parseValuesImageElement(data map[string]interface{}) *ValuesImageElement {
 	}
 	return valuesImageElementFromMap(elemData)
 }
+
+// ChartValues returns the values of the chart in chartPath, including the values of its subcharts under
+// their names, or aliases, as they are overridden from the parent chart
+func ChartValues(chartPath string) (map[string]interface{}, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %v", err)
+	}
+	return chartValues(c), nil
+}
+
+func chartValues(c *chart.Chart) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, dep := range c.Dependencies() {
+		key := dep.Name()
+		for _, d := range c.Metadata.Dependencies {
+			if d.Name == dep.Name() && d.Alias != "" {
+				key = d.Alias
+				break
+			}
+		}
+		values[key] = chartValues(dep)
+	}
+	return mergeValues(values, c.Values)
+}
+
+// mergeValues merges src into dst, src taking precedence, and returns dst
+func mergeValues(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[k] = mergeValues(dstMap, srcMap)
+		} else {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+// DiffValues returns the values in after that are missing or different in before, keeping their structure
+func DiffValues(before map[string]interface{}, after map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for k, v := range after {
+		afterMap, afterIsMap := v.(map[string]interface{})
+		beforeMap, beforeIsMap := before[k].(map[string]interface{})
+		if afterIsMap && beforeIsMap {
+			if d := DiffValues(beforeMap, afterMap); len(d) > 0 {
+				diff[k] = d
+			}
+			continue
+		}
+		if old, found := before[k]; !found || !reflect.DeepEqual(old, v) {
+			diff[k] = v
+		}
+	}
+	return diff
+}
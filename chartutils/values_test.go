@@ -0,0 +1,27 @@
+package chartutils
+
+func (suite *ChartUtilsTestSuite) TestDiffValues() {
+	before := map[string]interface{}{
+		"replicaCount": 1,
+		"image":        map[string]interface{}{"registry": "docker.io", "repository": "bitnami/wordpress", "tag": "6.2.2"},
+		"mariadb": map[string]interface{}{
+			"enabled": true,
+			"image":   map[string]interface{}{"registry": "docker.io", "repository": "bitnami/mariadb", "tag": "11.0.2"},
+		},
+	}
+	after := map[string]interface{}{
+		"replicaCount": 1,
+		"image":        map[string]interface{}{"registry": "acme.com", "repository": "federal/bitnami/wordpress", "tag": "6.2.2"},
+		"mariadb": map[string]interface{}{
+			"enabled": true,
+			"image":   map[string]interface{}{"registry": "acme.com", "repository": "federal/bitnami/mariadb", "tag": "11.0.2"},
+		},
+		"extraImages": []interface{}{"acme.com/federal/bitnami/os-shell:11"},
+	}
+	suite.Assert().Equal(map[string]interface{}{
+		"image":       map[string]interface{}{"registry": "acme.com", "repository": "federal/bitnami/wordpress"},
+		"mariadb":     map[string]interface{}{"image": map[string]interface{}{"registry": "acme.com", "repository": "federal/bitnami/mariadb"}},
+		"extraImages": []interface{}{"acme.com/federal/bitnami/os-shell:11"},
+	}, DiffValues(before, after))
+	suite.Assert().Empty(DiffValues(after, after))
+}
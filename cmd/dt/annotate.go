@@ -28,6 +28,7 @@ Use it cautiously. Very often the complete list of images cannot be guessed from
 			err := l.ExecuteStep(fmt.Sprintf("Annotating Helm chart %q", chartPath), func() error {
 				return chartutils.AnnotateChart(chartPath,
 					chartutils.WithAnnotationsKey(getAnnotationsKey()),
+					chartutils.WithUntaggedPolicy(getUntaggedPolicy()),
 					chartutils.WithLog(l),
 				)
 			})
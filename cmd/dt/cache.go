@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+)
+
+// imagesCache, set through the --images-cache global flag, is a directory where the pulled images are
+// kept and reused across runs
+var imagesCache string
+
+var cacheCmd = &cobra.Command{
+	Use:           "cache",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Images cache management commands",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+// cachedImage is the machine-readable representation of an image in the images cache
+type cachedImage struct {
+	Digest   string    `json:"digest"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// cacheInfo is the machine-readable summary of the images cache
+type cacheInfo struct {
+	Dir    string `json:"dir"`
+	Images int    `json:"images"`
+	Size   int64  `json:"size"`
+}
+
+// listImagesCache returns the images in the configured images cache, the least recently used first
+func listImagesCache() ([]chartutils.CachedImage, error) {
+	if imagesCache == "" {
+		return nil, fmt.Errorf("no images cache configured, provide it with --images-cache")
+	}
+	return chartutils.ListImagesCache(imagesCache)
+}
+
+func newCacheInfoCmd() *cobra.Command {
+	var outputFormat = textOutput
+
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "Shows the size of the images cache",
+		Example: `  # Show the size of the images cache
+  $ dt cache info --images-cache ~/.cache/dt/images`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			images, err := listImagesCache()
+			if err != nil {
+				return err
+			}
+			info := cacheInfo{Dir: imagesCache, Images: len(images)}
+			for _, img := range images {
+				info.Size += img.Size
+			}
+			if isStructuredOutput(outputFormat) {
+				return writeStructuredOutput(os.Stdout, outputFormat, info)
+			}
+			_ = getLogger().Section(fmt.Sprintf("Images cache %q", info.Dir), func(l log.SectionLogger) error {
+				l.Printf("Images: %d", info.Images)
+				l.Printf("Total size: %s", units.HumanSize(float64(info.Size)))
+				return nil
+			})
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &outputFormat)
+	return cmd
+}
+
+func newCacheListCmd() *cobra.Command {
+	var outputFormat = textOutput
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the images in the images cache by digest",
+		Long:  "Lists the images in the images cache by digest, the least recently used first",
+		Example: `  # List the cached images
+  $ dt cache list --images-cache ~/.cache/dt/images`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			images, err := listImagesCache()
+			if err != nil {
+				return err
+			}
+			if isStructuredOutput(outputFormat) {
+				res := make([]cachedImage, 0, len(images))
+				for _, img := range images {
+					res = append(res, cachedImage{Digest: img.Digest.String(), Size: img.Size, LastUsed: img.LastUsed})
+				}
+				return writeStructuredOutput(os.Stdout, outputFormat, res)
+			}
+			_ = getLogger().Section(fmt.Sprintf("Images cache %q", imagesCache), func(l log.SectionLogger) error {
+				for _, img := range images {
+					l.Printf("%s: %s, last used %s", img.Digest, units.HumanSize(float64(img.Size)), img.LastUsed.Format(time.RFC3339))
+				}
+				return nil
+			})
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &outputFormat)
+	return cmd
+}
+
+func newCachePruneCmd() *cobra.Command {
+	var olderThan time.Duration
+	var maxSize string
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Removes images from the images cache",
+		Long:  "Removes the images not used for longer than --older-than, and then the least recently used ones until the images cache fits in --max-size",
+		Example: `  # Remove the images not used in the last 30 days
+  $ dt cache prune --images-cache ~/.cache/dt/images --older-than 720h
+
+  # Keep the images cache under 20GB
+  $ dt cache prune --images-cache ~/.cache/dt/images --max-size 20GB`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan <= 0 && maxSize == "" {
+				return fmt.Errorf("either --older-than or --max-size must be provided")
+			}
+			var maxBytes int64
+			if maxSize != "" {
+				size, err := units.FromHumanSize(maxSize)
+				if err != nil || size <= 0 {
+					return fmt.Errorf("invalid --max-size %q", maxSize)
+				}
+				maxBytes = size
+			}
+			if imagesCache == "" {
+				return fmt.Errorf("no images cache configured, provide it with --images-cache")
+			}
+			l := getLogger()
+			removed, err := chartutils.PruneImagesCache(imagesCache, olderThan, maxBytes)
+			var freed int64
+			for _, img := range removed {
+				l.Debugf("Removed cached image %s", img.Digest)
+				freed += img.Size
+			}
+			if err != nil {
+				return l.Failf("failed to prune images cache: %w", err)
+			}
+			l.Successf("Removed %d cached images (%s freed)", len(removed), units.HumanSize(float64(freed)))
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", olderThan, "remove the images not used for longer than the given duration (for example 720h)")
+	cmd.Flags().StringVar(&maxSize, "max-size", maxSize, "remove the least recently used images until the cache fits in the given size (for example 20GB)")
+	return cmd
+}
+
+func init() {
+	cacheCmd.AddCommand(newCacheInfoCmd(), newCacheListCmd(), newCachePruneCmd())
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (suite *CmdSuite) TestCacheCommands() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	// writeCache creates an images cache with three images, used one, two and three days ago
+	writeCache := func() (string, []string) {
+		dir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		digests := make([]string, 0)
+		for i := 1; i <= 3; i++ {
+			hex := strings.Repeat(fmt.Sprint(i), 64)
+			file := filepath.Join(dir, hex+".tar")
+			require.NoError(os.WriteFile(file, make([]byte, 1000*i), 0644))
+			usedAt := time.Now().Add(-time.Duration(i) * 24 * time.Hour)
+			require.NoError(os.Chtimes(file, usedAt, usedAt))
+			digests = append(digests, "sha256:"+hex)
+		}
+		require.NoError(os.WriteFile(filepath.Join(dir, "README"), []byte("not an image"), 0644))
+		return dir, digests
+	}
+
+	t.Run("Requires an images cache", func(t *testing.T) {
+		dt("cache", "info").AssertErrorMatch(t, `no images cache configured`)
+	})
+	t.Run("Shows the images cache size", func(t *testing.T) {
+		dir, _ := writeCache()
+		dt("cache", "info", "--images-cache", dir).AssertSuccessMatch(t, `(?s)Images: 3.*Total size: 6kB`)
+		dt("cache", "info", "--images-cache", dir, "--output", "json").AssertSuccessMatch(t, `"images": 3,\s+"size": 6000`)
+	})
+	t.Run("Lists the cached images by digest", func(t *testing.T) {
+		dir, digests := writeCache()
+		res := dt("cache", "list", "--images-cache", dir)
+		res.AssertSuccess(t)
+		// The least recently used first
+		assert.Regexp(fmt.Sprintf(`(?s)%s: 3kB.*%s: 2kB.*%s: 1kB`, digests[2], digests[1], digests[0]), res.stdout)
+		assert.NotContains(res.stdout, "README")
+	})
+	t.Run("Prunes the images by age", func(t *testing.T) {
+		dir, digests := writeCache()
+		dt("cache", "prune", "--images-cache", dir, "--older-than", "36h").AssertSuccessMatch(t, `Removed 2 cached images \(5kB freed\)`)
+		res := dt("cache", "list", "--images-cache", dir)
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, digests[0])
+		assert.NotContains(res.stdout, digests[1])
+		assert.NotContains(res.stdout, digests[2])
+	})
+	t.Run("Prunes the least recently used images to a size budget", func(t *testing.T) {
+		dir, digests := writeCache()
+		dt("cache", "prune", "--images-cache", dir, "--max-size", "3.5kB").AssertSuccessMatch(t, `Removed 1 cached images \(3kB freed\)`)
+		res := dt("cache", "list", "--images-cache", dir)
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, digests[0])
+		assert.Contains(res.stdout, digests[1])
+		assert.NotContains(res.stdout, digests[2])
+	})
+	t.Run("Requires a pruning criteria", func(t *testing.T) {
+		dir, _ := writeCache()
+		dt("cache", "prune", "--images-cache", dir).AssertErrorMatch(t, `either --older-than or --max-size must be provided`)
+		dt("cache", "prune", "--images-cache", dir, "--max-size", "lots").AssertErrorMatch(t, `invalid --max-size "lots"`)
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/carvel"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// translateCarvelLock writes into lockFile the Images.lock translated from the imgpkg images lock of the
+// Helm chart in chartPath, if it is a Carvel bundle without an Images.lock. It returns true if it did
+func translateCarvelLock(ctx context.Context, chartPath string, lockFile string, platforms []string, l log.SectionLogger) (bool, error) {
+	if utils.FileExists(lockFile) {
+		return false, nil
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	if !carvel.IsBundle(chart.RootDir()) {
+		return false, nil
+	}
+	if err := l.ExecuteStep("Translating the Carvel bundle images lock into Images.lock", func() error {
+		il, err := carvel.ReadImagesLock(chart.RootDir())
+		if err != nil {
+			return err
+		}
+		lock, err := il.ToImagesLock(chart.Metadata,
+			imagelock.WithPlatforms(platforms),
+			imagelock.WithContext(ctx),
+			imagelock.WithInsecure(insecure),
+			imagelock.WithKeychain(getKeychain()),
+			imagelock.WithTransport(getTransport()),
+			imagelock.WithMirrors(getMirrors()),
+		)
+		if err != nil {
+			return err
+		}
+		fh, err := os.Create(lockFile)
+		if err != nil {
+			return fmt.Errorf("failed to create Images.lock: %w", err)
+		}
+		defer fh.Close()
+		return lock.ToYAML(fh)
+	}); err != nil {
+		return false, l.Failf("Failed to translate the Carvel bundle images lock: %w", err)
+	}
+	l.Infof("Images.lock translated from the Carvel bundle into %q", lockFile)
+	return true, nil
+}
+
+// prepareCarvelBundle makes the Carvel bundle in chartPath, if it does not include an Images.lock, ready to
+// be unwrapped, translating its images lock and pulling its images
+func prepareCarvelBundle(ctx context.Context, chartPath string, cfg *unwrapConfig, l log.SectionLogger) error {
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to determine Images.lock file location: %w", err)
+	}
+	translated, err := translateCarvelLock(ctx, chartPath, lockFile, nil, l)
+	if err != nil || !translated {
+		return err
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	return cfg.Summary.stage(ctx, "pull images", func(ctx context.Context) error {
+		return pullImagesSection(ctx, chart, "", cfg.Summary, l)
+	})
+}
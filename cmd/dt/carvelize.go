@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/carvel"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var carvelizeCmd = newCarvelizeCmd()
+
+func newCarvelizeCmd() *cobra.Command {
+	var lockFile string
+
+	cmd := &cobra.Command{
+		Use:   "carvelize CHART_PATH",
+		Short: "Turns a Helm chart into a Carvel imgpkg bundle",
+		Long: `Turns a Helm chart into a Carvel imgpkg bundle, writing the bundle metadata and images lock into its .imgpkg directory.
+The images are taken from the Images.lock included in the chart, or generated if it does not include one, unless provided with --lock-file`,
+		Example: `  # Turn a Helm chart into an imgpkg bundle and push it
+  $ dt charts carvelize examples/mariadb
+  $ imgpkg push -b demo.goharbor.io/test_repo/mariadb-bundle:12.2.8 -f examples/mariadb`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			l := getLogger()
+
+			if err := carvelizeChart(chartPath, lockFile, l); err != nil {
+				return err
+			}
+			l.Successf("Carvel bundle created successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&lockFile, "lock-file", lockFile, "Images.lock file to use, instead of the one in the Helm chart")
+	return cmd
+}
+
+// carvelizeChart writes the imgpkg bundle metadata of the Helm chart in chartPath into its .imgpkg directory
+func carvelizeChart(chartPath string, lockFile string, l log.SectionLogger) error {
+	if isTar, _ := utils.IsTarFile(chartPath); isTar {
+		return fmt.Errorf("only Helm chart directories can be turned into Carvel bundles")
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return l.Failf("Failed to load Helm chart: %w", err)
+	}
+	if lockFile, err = chartLockFile(chart, lockFile, l); err != nil {
+		return err
+	}
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return l.Failf("Failed to read Images.lock: %w", err)
+	}
+	if err := l.ExecuteStep("Writing Carvel bundle metadata", func() error {
+		return carvel.WriteBundle(chart.RootDir(), chart.Metadata, lock)
+	}); err != nil {
+		return l.Failf("Failed to write Carvel bundle metadata: %w", err)
+	}
+	l.Infof("Carvel bundle metadata written to %q", filepath.Join(chart.RootDir(), carvel.BundleDir))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/vmware-labs/distribution-tooling-for-helm/carvel"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"gopkg.in/yaml.v3"
+)
+
+func (suite *CmdSuite) TestCarvelizeCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	silentLog := log.New(io.Discard, "", 0)
+
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+	// Images translated from Carvel bundles are resolved by digest, so they must define their platform
+	imageData := tu.ImageData{Name: "test", Image: "test:mytag"}
+	platforms := []string{"linux/amd64", "linux/arm64"}
+	craneImages, err := tu.CreateSampleImages(&imageData, platforms)
+	require.NoError(err)
+	var idx v1.ImageIndex = empty.Index
+	for i, img := range craneImages {
+		plat := strings.Split(platforms[i], "/")
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: plat[0], Architecture: plat[1]}},
+		})
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/%s", serverURL, imageData.Image))
+	require.NoError(err)
+	require.NoError(remote.WriteIndex(ref, idx))
+	images := []tu.ImageData{imageData}
+
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	createChart := func() string {
+		dest := suite.sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+	readImagesLock := func(chartDir string) *carvel.ImagesLock {
+		data, err := os.ReadFile(filepath.Join(chartDir, carvel.BundleDir, carvel.ImagesLockFileName))
+		require.NoError(err)
+		il := &carvel.ImagesLock{}
+		require.NoError(yaml.Unmarshal(data, il))
+		return il
+	}
+	numDigests := 0
+	for _, img := range images {
+		numDigests += len(img.Digests)
+	}
+
+	t.Run("Creates the bundle from the Images.lock", func(t *testing.T) {
+		chartDir := createChart()
+		dt("charts", "carvelize", chartDir).AssertSuccessMatch(t, "Carvel bundle created successfully")
+		assert.FileExists(filepath.Join(chartDir, carvel.BundleDir, carvel.BundleFileName))
+		il := readImagesLock(chartDir)
+		require.Len(il.Images, numDigests)
+		assert.Contains(il.Images[0].Image, fmt.Sprintf("%s/test@sha256:", serverURL))
+	})
+	t.Run("Generates the missing Images.lock", func(t *testing.T) {
+		chartDir := createChart()
+		require.NoError(os.Remove(filepath.Join(chartDir, "Images.lock")))
+		dt("charts", "carvelize", chartDir).AssertSuccess(t)
+		assert.Len(readImagesLock(chartDir).Images, numDigests)
+	})
+	t.Run("Wraps and unwraps Carvel bundles", func(t *testing.T) {
+		chartDir := createChart()
+		dt("charts", "carvelize", chartDir).AssertSuccess(t)
+		require.NoError(os.Remove(filepath.Join(chartDir, "Images.lock")))
+
+		outputFile := filepath.Join(suite.sb.TempFile(), "test.wrap.tgz")
+		dt("wrap", chartDir, "--output-file", outputFile).AssertSuccessMatch(t, "Images.lock translated from the Carvel bundle")
+		assert.FileExists(outputFile)
+
+		require.NoError(os.Remove(filepath.Join(chartDir, "Images.lock")))
+		targetRegistry := fmt.Sprintf("%s/carvel", serverURL)
+		dt("unwrap", "--yes", chartDir, targetRegistry).AssertSuccessMatch(t, "Images.lock translated from the Carvel bundle")
+		assert.True(utils.RemoteChartExist(fmt.Sprintf("oci://%s/test", targetRegistry), "1.0.0"), "chart should exist in the repository")
+	})
+	t.Run("Fails with packaged charts", func(t *testing.T) {
+		tarFile := filepath.Join(suite.sb.TempFile(), "test-1.0.0.tgz")
+		require.NoError(utils.Tar(createChart(), tarFile, utils.TarConfig{Prefix: "test"}))
+		dt("charts", "carvelize", tarFile).AssertErrorMatch(t, "only Helm chart directories")
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/artifact"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var chartAttachLockCmd = newChartAttachLockCmd()
+
+func newChartAttachLockCmd() *cobra.Command {
+	var version string
+	var lockFile string
+
+	cmd := &cobra.Command{
+		Use:   "attach-lock OCI_URI",
+		Short: "Attaches the Images.lock to a Helm chart in an OCI registry",
+		Long: `Attaches the Images.lock of a Helm chart stored in an OCI registry as an OCI referrer of the chart manifest, so consumers can discover its images without downloading the chart.
+The Images.lock included in the chart is used, or generated if it does not include one, unless provided with --lock-file`,
+		Example: `  # Attach the Images.lock of a Helm chart, generating it if needed
+  $ dt charts attach-lock oci://demo.goharbor.io/test_repo/mariadb --version 12.2.8
+
+  # Attach a previously verified Images.lock
+  $ dt charts attach-lock oci://demo.goharbor.io/test_repo/mariadb --version 12.2.8 --lock-file Images.lock`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartURL := args[0]
+			if !strings.HasPrefix(chartURL, "oci://") {
+				return fmt.Errorf("the Images.lock can only be attached to Helm charts in OCI registries (oci://REGISTRY/NAME)")
+			}
+			if err := flagsKeychain.scopeDefaultAuth(chartURL); err != nil {
+				return err
+			}
+			l := getLogger()
+
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+
+			ref, dgst, err := attachChartLock(ctx, chartURL, version, lockFile, l)
+			if err != nil {
+				return err
+			}
+			l.Successf("Images.lock attached to %q as %s", ref, dgst)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", version, "version of the Helm chart. Defaults to the latest stable version")
+	cmd.Flags().StringVar(&lockFile, "lock-file", lockFile, "Images.lock file to attach, instead of the one in the Helm chart")
+	return cmd
+}
+
+// attachChartLock attaches the Images.lock to the Helm chart in chartURL, returning the chart reference and
+// the digest of the attached artifact
+func attachChartLock(ctx context.Context, chartURL string, version string, lockFile string, l log.SectionLogger) (string, digest.Digest, error) {
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return "", "", err
+	}
+	var chartPath string
+	if err := l.ExecuteStep("Fetching Helm chart", func() error {
+		chartFile, err := pullRemoteChart(chartURL, "", version, tmpDir, "")
+		if err != nil {
+			return err
+		}
+		chartPath, err = wrap.Extract(ctx, chartFile, tmpDir)
+		return err
+	}); err != nil {
+		return "", "", l.Failf("Failed to fetch Helm chart: %w", err)
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return "", "", l.Failf("Failed to load Helm chart: %w", err)
+	}
+	if lockFile, err = chartLockFile(chart, lockFile, l); err != nil {
+		return "", "", err
+	}
+
+	ref := fmt.Sprintf("%s:%s", strings.TrimPrefix(chartURL, "oci://"), artifact.ChartVersionTag(chart.Metadata.Version))
+	var dgst digest.Digest
+	if err := l.ExecuteStep("Attaching Images.lock", func() error {
+		dgst, err = artifact.AttachImagesLock(ref, lockFile, artifactOptions(ctx)...)
+		return err
+	}); err != nil {
+		return "", "", l.Failf("Failed to attach Images.lock: %w", err)
+	}
+	return ref, dgst, nil
+}
+
+// chartLockFile returns the Images.lock to attach to chart: lockFile, if provided, after checking it
+// belongs to the chart, or the one in the chart, generating it if missing
+func chartLockFile(chart *chartutils.Chart, lockFile string, l log.SectionLogger) (string, error) {
+	if lockFile != "" {
+		lock, err := imagelock.FromYAMLFile(lockFile)
+		if err != nil {
+			return "", l.Failf("Failed to read Images.lock: %w", err)
+		}
+		if lock.Chart.Name != chart.Name() || lock.Chart.Version != chart.Metadata.Version {
+			return "", l.Failf("Images.lock belongs to Helm chart %s:%s, not to %s:%s",
+				lock.Chart.Name, lock.Chart.Version, chart.Name(), chart.Metadata.Version)
+		}
+		return lockFile, nil
+	}
+	lockFile = chart.AbsFilePath(imagelock.DefaultImagesLockFileName)
+	if utils.FileExists(lockFile) {
+		return lockFile, nil
+	}
+	if err := l.ExecuteStep("Generating Images.lock", func() error {
+		return createImagesLock(chart.RootDir(), lockFile, log.SilentLog)
+	}); err != nil {
+		return "", l.Failf("Failed to generate Images.lock: %w", err)
+	}
+	return lockFile, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/vmware-labs/distribution-tooling-for-helm/artifact"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestChartAttachLockCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	silentLog := log.New(io.Discard, "", 0)
+
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+	require.NoError(err)
+
+	scenarioName := "complete-chart"
+	chartName := "test"
+	version := "1.0.0"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	dest := suite.sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+	))
+	// The Images.lock is generated when the chart does not include it
+	require.NoError(os.Remove(filepath.Join(chartDir, "Images.lock")))
+	tarFile := filepath.Join(suite.sb.TempFile(), fmt.Sprintf("%s-%s.tgz", chartName, version))
+	require.NoError(utils.Tar(chartDir, tarFile, utils.TarConfig{Prefix: chartName}))
+	chartsURL := fmt.Sprintf("oci://%s/charts", serverURL)
+	require.NoError(utils.PushChart(tarFile, chartsURL, utils.PushConfig{}))
+	chartURL := fmt.Sprintf("%s/%s", chartsURL, chartName)
+
+	listReferrers := func() []string {
+		ref, err := name.ParseReference(fmt.Sprintf("%s/charts/%s:%s", serverURL, chartName, version))
+		require.NoError(err)
+		desc, err := remote.Head(ref)
+		require.NoError(err)
+		idx, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()))
+		require.NoError(err)
+		m, err := idx.IndexManifest()
+		require.NoError(err)
+		artifactTypes := make([]string, 0)
+		for _, d := range m.Manifests {
+			artifactTypes = append(artifactTypes, d.ArtifactType)
+		}
+		return artifactTypes
+	}
+
+	t.Run("Attaches the generated Images.lock", func(t *testing.T) {
+		dt("charts", "attach-lock", chartURL, "--version", version).AssertSuccessMatch(t, "Images.lock attached to")
+		assert.Equal([]string{string(artifact.ImagesLockConfigMediaType)}, listReferrers())
+	})
+	t.Run("Wrap uses the attached Images.lock", func(t *testing.T) {
+		outputFile := filepath.Join(suite.sb.TempFile(), "chart.wrap.tgz")
+		dt("wrap", chartURL, "--version", version, "--output-file", outputFile).
+			AssertSuccessMatch(t, "Using the Images.lock attached to")
+		assert.FileExists(outputFile)
+	})
+	t.Run("Fails with an Images.lock of another chart", func(t *testing.T) {
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "other", "Version": version},
+		)
+		require.NoError(err)
+		lockFile := filepath.Join(suite.sb.TempFile(), "Images.lock")
+		require.NoError(os.MkdirAll(filepath.Dir(lockFile), 0755))
+		require.NoError(os.WriteFile(lockFile, []byte(data), 0644))
+		res := dt("charts", "attach-lock", chartURL, "--version", version, "--lock-file", lockFile)
+		res.AssertError(t)
+		assert.True(strings.Contains(res.stdout+res.stderr, "Images.lock belongs to Helm chart other:1.0.0"))
+	})
+	t.Run("Fails with charts not in OCI registries", func(t *testing.T) {
+		dt("charts", "attach-lock", chartDir).AssertErrorMatch(t, "can only be attached to Helm charts in OCI registries")
+	})
+}
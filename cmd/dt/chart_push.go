@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+)
+
+var chartPushCmd = newChartPushCmd()
+
+func newChartPushCmd() *cobra.Command {
+	var signKey string
+	var keyring = signature.DefaultSecretKeyring()
+	var passphraseFile string
+
+	cmd := &cobra.Command{
+		Use:   "push CHART_PATH OCI_URI",
+		Short: "Pushes a Helm chart to an OCI registry",
+		Long:  "Packages the Helm chart, leaving out its pulled images, and pushes it as an OCI artifact into the given registry or, for http(s) URLs, uploads it to a ChartMuseum compatible Helm repository",
+		Example: `  # Relocate a chart, push its images and then the relocated chart into demo Harbor
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo
+  $ dt images push examples/mariadb
+  $ dt charts push examples/mariadb oci://demo.goharbor.io/test_repo
+
+  # Upload a chart to a ChartMuseum repository using basic auth
+  $ dt charts push examples/mariadb https://charts.example.com --creds charts.example.com=user:pass
+
+  # Push a chart signing it, so its provenance file is pushed along with it
+  $ dt charts push examples/mariadb oci://demo.goharbor.io/test_repo --sign-key ops@example.com`,
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath, repository := args[0], args[1]
+			if repository == "" {
+				return fmt.Errorf("repository cannot be empty")
+			}
+			if err := flagsKeychain.scopeDefaultAuth(repository); err != nil {
+				return err
+			}
+			l := getLogger()
+
+			var signOpts []signature.Option
+			if signKey != "" {
+				var err error
+				if signOpts, err = signOptions(signKey, keyring, passphraseFile); err != nil {
+					return err
+				}
+			}
+			chart, err := chartutils.LoadChart(chartPath)
+			if err != nil {
+				return l.Failf("failed to load Helm chart %q: %w", chartPath, err)
+			}
+			fullChartURL, err := pushChartWithRetries(chart, repository, maxRetries, signOpts, l)
+			if err != nil {
+				return err
+			}
+			l.Successf(`Helm chart pushed successfully: You can use it now by running "helm install %s --generate-name"`, fullChartURL)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&signKey, "sign-key", signKey, "sign the Helm chart with the given GPG key (user id, long key id or fingerprint), pushing its provenance file along with it")
+	cmd.Flags().StringVar(&keyring, "keyring", keyring, "location of the secret keyring used with --sign-key")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", passphraseFile, "file containing the passphrase of the signing key")
+	return cmd
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestChartPushCommand() {
+	t := suite.T()
+	silentLog := log.New(io.Discard, "", 0)
+
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL := u.Host
+	scenarioName := "complete-chart"
+	chartName := "test"
+	version := "1.0.0"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	t.Run("Push Helm chart", func(t *testing.T) {
+		require := suite.Require()
+		dest := suite.sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+
+		targetRegistry := fmt.Sprintf("%s/charts", serverURL)
+		dt("charts", "push", chartDir, targetRegistry).AssertSuccessMatch(t, fmt.Sprintf("helm install oci://%s/%s", targetRegistry, chartName))
+
+		suite.Assert().True(
+			utils.RemoteChartExist(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version),
+			"chart should exist in the repository",
+		)
+	})
+	t.Run("Upload Helm chart to a ChartMuseum repository", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		var uploaded string
+		repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if _, header, err := r.FormFile("chart"); err == nil && r.URL.Path == "/api/charts" {
+				uploaded = header.Filename
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer repo.Close()
+
+		dest := suite.sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+
+		dt("charts", "push", chartDir, repo.URL).AssertErrorMatch(t, "401 Unauthorized")
+
+		repoHost := strings.TrimPrefix(repo.URL, "http://")
+		dt("charts", "push", chartDir, repo.URL, "--creds", repoHost+"=admin:secret").AssertSuccessMatch(t,
+			regexp.QuoteMeta(fmt.Sprintf("helm install %s --repo %s", chartName, repo.URL)))
+		assert.Equal(fmt.Sprintf("%s-%s.tgz", chartName, version), uploaded)
+
+		// The default credentials apply to the push target
+		dt("charts", "push", chartDir, repo.URL, "--username", "admin", "--password", "secret").AssertSuccess(t)
+	})
+	t.Run("Fails pushing a missing chart", func(t *testing.T) {
+		dt("charts", "push", suite.sb.TempFile(), serverURL).AssertErrorMatch(t, "failed to load Helm chart")
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/zarf"
+)
+
+var chartZarfCmd = newChartZarfCmd()
+
+func newChartZarfCmd() *cobra.Command {
+	var outputDir string
+	arch := "amd64"
+
+	cmd := &cobra.Command{
+		Use:   "zarf-package WRAP",
+		Short: "Generates a Zarf package definition from a wrapped Helm chart",
+		Long: `Generates a Zarf package definition (zarf.yaml) from a wrapped Helm chart, along with the Helm chart it deploys, so it can be built with "zarf package create".
+The package references the images of the wrap Images.lock by the digest of the requested architecture`,
+		Example: `  # Generate a Zarf package from a wrap and build it
+  $ dt charts zarf-package mariadb-12.2.8.wrap.tgz --output-dir mariadb-zarf
+  $ zarf package create mariadb-zarf`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wrapPath := args[0]
+			if !utils.FileExists(wrapPath) {
+				return fmt.Errorf("wrap %q does not exist", wrapPath)
+			}
+			if isEncrypted, _ := encryption.IsEncrypted(wrapPath); isEncrypted {
+				return fmt.Errorf("encrypted wraps must be decrypted before generating the Zarf package")
+			}
+			l := getLogger()
+
+			dir, err := writeZarfPackage(wrapPath, outputDir, arch, l)
+			if err != nil {
+				return err
+			}
+			l.Successf("Zarf package written to %q", dir)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&outputDir, "output-dir", outputDir, "directory to write the Zarf package into (defaults to NAME-VERSION-zarf)")
+	cmd.Flags().StringVar(&arch, "arch", arch, "architecture of the Zarf package, selecting the images digests")
+	return cmd
+}
+
+// writeZarfPackage writes into outputDir the Zarf package definition of the wrap in wrapPath, and the
+// Helm chart it deploys, returning the package directory
+func writeZarfPackage(wrapPath string, outputDir string, arch string, l log.SectionLogger) (string, error) {
+	chartPath := wrapPath
+	if isTar, _ := utils.IsTarFile(wrapPath); isTar {
+		tmpDir, err := getGlobalTempWorkDir()
+		if err != nil {
+			return "", err
+		}
+		if chartPath, err = wrap.Extract(context.Background(), wrapPath, tmpDir); err != nil {
+			return "", l.Failf("Failed to uncompress wrap: %w", err)
+		}
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return "", l.Failf("Failed to load Helm chart: %w", err)
+	}
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return "", l.Failf("Failed to read Images.lock: %w", err)
+	}
+	if outputDir == "" {
+		outputDir = fmt.Sprintf("%s-%s-zarf", chart.Name(), chart.Metadata.Version)
+	}
+	// Zarf resolves the chart relative to the package definition
+	chartFile := filepath.Join("chart", fmt.Sprintf("%s-%s.tgz", chart.Name(), chart.Metadata.Version))
+	if err := l.ExecuteStep("Writing Zarf package", func() error {
+		pkg, err := zarf.NewPackage(chart.Metadata, lock, chartFile, arch)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(outputDir, "chart"), 0755); err != nil {
+			return fmt.Errorf("failed to create Zarf package directory: %w", err)
+		}
+		if err := chart.Package(filepath.Join(outputDir, chartFile)); err != nil {
+			return err
+		}
+		return pkg.Write(filepath.Join(outputDir, zarf.PackageFileName))
+	}); err != nil {
+		return "", l.Failf("Failed to write Zarf package: %w", err)
+	}
+	return outputDir, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/zarf"
+	"gopkg.in/yaml.v3"
+)
+
+func (suite *CmdSuite) TestChartZarfPackageCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	sb := suite.sb
+	serverURL := "registry.example.com"
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	images, err := writeSampleImages("test", "mytag", filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+	))
+	wrapFile := filepath.Join(sb.TempFile(), "test-1.0.0.wrap.tgz")
+	require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+
+	t.Run("Generates the Zarf package", func(t *testing.T) {
+		outputDir := sb.TempFile()
+		dt("charts", "zarf-package", wrapFile, "--output-dir", outputDir, "--arch", "arm64").AssertSuccessMatch(t, "Zarf package written to")
+
+		data, err := os.ReadFile(filepath.Join(outputDir, zarf.PackageFileName))
+		require.NoError(err)
+		pkg := &zarf.Package{}
+		require.NoError(yaml.Unmarshal(data, pkg))
+		assert.Equal("arm64", pkg.Metadata.Architecture)
+		require.Len(pkg.Components, 1)
+		digest := images[0].Digests[1]
+		require.Equal("linux/arm64", digest.Arch)
+		assert.Equal([]string{fmt.Sprintf("%s/test:mytag@%s", serverURL, digest.Digest)}, pkg.Components[0].Images)
+
+		chartFile := filepath.Join(outputDir, pkg.Components[0].Charts[0].LocalPath)
+		assert.FileExists(chartFile)
+		unpackedDir := sb.TempFile()
+		require.NoError(utils.Untar(chartFile, unpackedDir, utils.TarConfig{StripComponents: 1}))
+		assert.FileExists(filepath.Join(unpackedDir, "Chart.yaml"))
+		imageFiles, err := filepath.Glob(filepath.Join(unpackedDir, "images", "*.tar"))
+		require.NoError(err)
+		assert.Empty(imageFiles)
+	})
+	t.Run("Fails with unknown architectures", func(t *testing.T) {
+		dt("charts", "zarf-package", chartDir, "--output-dir", sb.TempFile(), "--arch", "s390x").AssertErrorMatch(t, `is not available for the "s390x" architecture`)
+	})
+}
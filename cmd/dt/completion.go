@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// registrySettings are the configuration file settings, in the registry=value format, naming registries
+var registrySettings = []string{"creds", "registry-mirror", "registry-ca-file", "registry-client-cert", "registry-client-key"}
+
+// chartPathArgs are the argument names, in the commands usage, completed with local charts and wraps
+var chartPathArgs = []string{"CHART_PATH", "WRAP", "FILE", "IMAGES_LOCK"}
+
+// completeChartPaths suggests the directories and the packaged charts and wraps matching toComplete
+func completeChartPaths(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	matches, _ := filepath.Glob(toComplete + "*")
+	suggestions := make([]string, 0)
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		switch {
+		case err != nil:
+			continue
+		case fi.IsDir():
+			suggestions = append(suggestions, m+string(filepath.Separator))
+		case strings.HasSuffix(m, ".tgz") || strings.HasSuffix(m, ".tar.gz") || strings.HasSuffix(m, ".age") || strings.HasSuffix(m, ".lock"):
+			suggestions = append(suggestions, m)
+		}
+	}
+	// Directories are completed without a trailing space, so their content can be completed next
+	return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// configuredRegistries returns the registries named in the configuration file
+func configuredRegistries() []string {
+	if configFile == "" || !utils.FileExists(configFile) {
+		return nil
+	}
+	settings, err := readConfigFile(configFile)
+	if err != nil {
+		return nil
+	}
+	registries := make([]string, 0)
+	for _, key := range registrySettings {
+		values, _ := settings[key].([]interface{})
+		for _, v := range values {
+			if registry, _, found := strings.Cut(strings.TrimSpace(fmt.Sprint(v)), "="); found && registry != "" {
+				registries = append(registries, registry)
+			}
+		}
+	}
+	for _, key := range []string{"allowed-registries", "blocked-registries"} {
+		values, _ := settings[key].([]interface{})
+		for _, v := range values {
+			registries = append(registries, fmt.Sprint(v))
+		}
+	}
+	return registries
+}
+
+// completeRegistries suggests the registries named in the configuration file as OCI URIs
+func completeRegistries(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	suggestions := make([]string, 0)
+	for _, registry := range uniqueSorted(configuredRegistries()) {
+		uri := "oci://" + strings.TrimSuffix(registry, "/") + "/"
+		if strings.HasPrefix(registry, toComplete) {
+			suggestions = append(suggestions, strings.TrimSuffix(registry, "/")+"/")
+		} else if strings.HasPrefix(uri, toComplete) {
+			suggestions = append(suggestions, uri)
+		}
+	}
+	return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// lockRepositories returns the repositories of the images in the Images.lock of the chart at chartPath
+func lockRepositories(chartPath string) []string {
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return nil
+	}
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil
+	}
+	repositories := make([]string, 0, len(lock.Images))
+	for _, img := range lock.Images {
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			continue
+		}
+		// Suggested as usually written, the registry filters normalize them
+		registry := ref.Context().RegistryStr()
+		if registry == name.DefaultRegistry {
+			registry = "docker.io"
+		}
+		repositories = append(repositories, registry, registry+"/"+ref.Context().RepositoryStr())
+	}
+	return repositories
+}
+
+// completeImageRepositories suggests the registries in the configuration file, and the registries and
+// repositories of the images in the Images.lock of the chart provided as first argument
+func completeImageRepositories(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	candidates := configuredRegistries()
+	if len(args) > 0 {
+		candidates = append(candidates, lockRepositories(args[0])...)
+	}
+	// Values are comma separated, so only the last one is completed
+	prefix, last := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, last = toComplete[:i+1], toComplete[i+1:]
+	}
+	suggestions := make([]string, 0)
+	for _, c := range uniqueSorted(candidates) {
+		if strings.HasPrefix(c, last) {
+			suggestions = append(suggestions, prefix+c)
+		}
+	}
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// uniqueSorted returns the sorted values without duplicates
+func uniqueSorted(values []string) []string {
+	done := make(map[string]bool)
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" && !done[v] {
+			done[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// argsCompletion returns the completion of the positional arguments of cmd, based on the argument names
+// of its usage line: local charts and wraps, or registries
+func argsCompletion(cmd *cobra.Command) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	argNames := strings.Fields(cmd.Use)[1:]
+	return func(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= len(argNames) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		argName := strings.Trim(argNames[len(args)], "[]")
+		for _, n := range strings.Split(argName, "|") {
+			for _, chartArg := range chartPathArgs {
+				if n == chartArg {
+					return completeChartPaths(c, args, toComplete)
+				}
+			}
+		}
+		if strings.Contains(argName, "OCI_URI") {
+			return completeRegistries(c, args, toComplete)
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+}
+
+// setupCompletion registers the dynamic completion of the arguments of cmd and its subcommands, and of
+// the registry flags
+func setupCompletion(cmd *cobra.Command) {
+	for _, c := range cmd.Commands() {
+		if c.ValidArgsFunction == nil && len(strings.Fields(c.Use)) > 1 {
+			c.ValidArgsFunction = argsCompletion(c)
+		}
+		setupCompletion(c)
+	}
+	if cmd.HasParent() {
+		return
+	}
+	for _, flagName := range []string{"allowed-registries", "blocked-registries"} {
+		_ = cmd.RegisterFlagCompletionFunc(flagName, completeImageRepositories)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func (suite *CmdSuite) TestCompletion() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	dir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(err)
+	chartDir := filepath.Join(dir, "mariadb")
+	require.NoError(os.MkdirAll(chartDir, 0755))
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: mariadb\nversion: 1.0.0\n"), 0644))
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(`apiVersion: v0
+kind: ImagesLock
+chart:
+  name: mariadb
+  version: 1.0.0
+images:
+  - name: mariadb
+    image: docker.io/bitnami/mariadb:11.0.2
+    chart: mariadb
+`), 0644))
+	require.NoError(os.WriteFile(filepath.Join(dir, "mariadb-1.0.0.wrap.tgz"), []byte{}, 0644))
+	require.NoError(os.WriteFile(filepath.Join(dir, "mariadb.txt"), []byte{}, 0644))
+
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(os.WriteFile(configFile, []byte(`creds:
+  - harbor.example.com=user:pass
+registry-mirror:
+  - docker.io=mirror.example.com
+`), 0644))
+
+	t.Run("Completes the shell completion command", func(t *testing.T) {
+		dt("completion", "bash").AssertSuccessMatch(t, "bash completion")
+	})
+	t.Run("Completes chart paths", func(t *testing.T) {
+		res := dt("__complete", "images", "lock", filepath.Join(dir, "mariadb"))
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, chartDir+string(filepath.Separator)+"\n")
+		assert.Contains(res.stdout, filepath.Join(dir, "mariadb-1.0.0.wrap.tgz")+"\n")
+		assert.NotContains(res.stdout, "mariadb.txt")
+	})
+	t.Run("Completes the registries in the configuration file", func(t *testing.T) {
+		res := dt("__complete", "unwrap", "--config", configFile, "mariadb-1.0.0.wrap.tgz", "")
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, "docker.io/\n")
+		assert.Contains(res.stdout, "harbor.example.com/\n")
+
+		res = dt("__complete", "unwrap", "--config", configFile, "mariadb-1.0.0.wrap.tgz", "oci://har")
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, "oci://harbor.example.com/\n")
+		assert.NotContains(res.stdout, "docker.io")
+	})
+	t.Run("Completes the images repositories of the Images.lock", func(t *testing.T) {
+		res := dt("__complete", "images", "pull", "--config", configFile, chartDir, "--allowed-registries", "docker.io/")
+		res.AssertSuccess(t)
+		assert.Equal("docker.io/bitnami/mariadb\n:4\n", res.stdout)
+
+		res = dt("__complete", "images", "pull", chartDir, "--blocked-registries", "quay.io,")
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, "quay.io,docker.io/bitnami/mariadb\n")
+		assert.Contains(res.stdout, "quay.io,docker.io\n")
+	})
+}
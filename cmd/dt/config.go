@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the location of the configuration file, set through the --config global flag
+var configFile = defaultConfigFile()
+
+// defaultConfigFile returns the location of the configuration file used if --config is not provided
+func defaultConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "dt", "config.yaml")
+}
+
+// readConfigFile reads the flags defaults defined in file, keyed by flag name
+func readConfigFile(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	settings := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %q: %w", file, err)
+	}
+	return settings, nil
+}
+
+// allFlagNames returns the names of the flags defined by cmd and its subcommands
+func allFlagNames(cmd *cobra.Command, names map[string]bool) map[string]bool {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { names[f.Name] = true })
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { names[f.Name] = true })
+	for _, c := range cmd.Commands() {
+		allFlagNames(c, names)
+	}
+	return names
+}
+
+// setFlagFromConfig sets the flag to value, as if it was provided in the command line
+func setFlagFromConfig(f *pflag.Flag, value interface{}) error {
+	var err error
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			err = sv.Replace(items)
+		} else {
+			err = fmt.Errorf("expected a single value")
+		}
+	} else {
+		err = f.Value.Set(fmt.Sprint(value))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %q in configuration file: %v", f.Name, err)
+	}
+	f.Changed = true
+	return nil
+}
+
+// flagEnvVar returns the environment variable that overrides the flag with the given name
+func flagEnvVar(name string) string {
+	return "DT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvironment sets the cmd flags not provided in the command line from their DT_ prefixed
+// environment variables, if defined. List values are comma separated
+func applyEnvironment(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		value, ok := os.LookupEnv(flagEnvVar(f.Name))
+		if err != nil || f.Changed || !ok || f.Name == "help" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value for %s: %v", flagEnvVar(f.Name), setErr)
+			return
+		}
+		f.Changed = true
+	})
+	return err
+}
+
+// applyConfigFile uses the settings in the configuration file as defaults of the cmd flags
+// not provided in the command line
+func applyConfigFile(cmd *cobra.Command) error {
+	file := configFile
+	if !cmd.Flags().Changed("config") {
+		if file == "" || !utils.FileExists(file) {
+			return nil
+		}
+	}
+	settings, err := readConfigFile(file)
+	if err != nil {
+		return err
+	}
+
+	known := allFlagNames(cmd.Root(), make(map[string]bool))
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] || name == "config" {
+			return fmt.Errorf("unknown setting %q in configuration file %q", name, file)
+		}
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			// Not used by this command, or overridden in the command line
+			continue
+		}
+		if err := setFlagFromConfig(f, settings[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *CmdSuite) TestConfigFile() {
+	require := suite.Require()
+	assert := suite.Assert()
+
+	s, err := tu.NewTestServer()
+	require.NoError(err)
+	defer s.Close()
+
+	images, err := s.LoadImagesFromFile("../../testdata/images.json")
+	require.NoError(err)
+
+	sb := suite.sb
+	scenarioName := "custom-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	t := suite.T()
+
+	createSampleChart := func() string {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": s.ServerURL, "Images": images, "Name": "test", "RepositoryURL": s.ServerURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+	writeConfig := func(file string, data string) string {
+		require.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(os.WriteFile(file, []byte(data), 0644))
+		return file
+	}
+	lockedArchs := func(chartDir string) []string {
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		archs := make([]string, 0)
+		for _, img := range lock.Images {
+			for _, dgst := range img.Digests {
+				archs = append(archs, dgst.Arch)
+			}
+		}
+		return archs
+	}
+
+	t.Run("Uses the settings as flag defaults", func(t *testing.T) {
+		// Our test server requires --insecure, so it is also taken from the configuration
+		configFile := writeConfig(filepath.Join(sb.TempFile(), "config.yaml"), "insecure: true\nplatforms:\n  - linux/amd64\n")
+		chartDir := createSampleChart()
+
+		dt("--config", configFile, "images", "lock", chartDir).AssertSuccess(t)
+		archs := lockedArchs(chartDir)
+		require.NotEmpty(archs)
+		for _, arch := range archs {
+			assert.Equal("linux/amd64", arch)
+		}
+
+		// Flags in the command line take precedence
+		dt("--config", configFile, "images", "lock", chartDir, "--platforms", "linux/arm64").AssertSuccess(t)
+		for _, arch := range lockedArchs(chartDir) {
+			assert.Equal("linux/arm64", arch)
+		}
+	})
+	t.Run("Reads the default configuration file", func(t *testing.T) {
+		configDir := sb.TempFile()
+		writeConfig(filepath.Join(configDir, "dt", "config.yaml"), "insecure: true\n")
+		t.Setenv("XDG_CONFIG_HOME", configDir)
+
+		dt("images", "lock", createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Uses the environment variables as flag defaults", func(t *testing.T) {
+		t.Setenv("DT_INSECURE", "true")
+		t.Setenv("DT_PLATFORMS", "linux/arm64")
+		chartDir := createSampleChart()
+
+		dt("images", "lock", chartDir).AssertSuccess(t)
+		archs := lockedArchs(chartDir)
+		require.NotEmpty(archs)
+		for _, arch := range archs {
+			assert.Equal("linux/arm64", arch)
+		}
+
+		// Environment variables take precedence over the configuration file, but not over the command line
+		configFile := writeConfig(filepath.Join(sb.TempFile(), "config.yaml"), "platforms: [linux/amd64]\n")
+		dt("--config", configFile, "images", "lock", chartDir).AssertSuccess(t)
+		for _, arch := range lockedArchs(chartDir) {
+			assert.Equal("linux/arm64", arch)
+		}
+		dt("--config", configFile, "images", "lock", chartDir, "--platforms", "linux/amd64").AssertSuccess(t)
+		for _, arch := range lockedArchs(chartDir) {
+			assert.Equal("linux/amd64", arch)
+		}
+
+		t.Setenv("DT_INSECURE", "maybe")
+		dt("images", "lock", chartDir).AssertErrorMatch(t, "invalid value for DT_INSECURE")
+	})
+	t.Run("Handles errors", func(t *testing.T) {
+		chartDir := createSampleChart()
+
+		dt("--config", filepath.Join(sb.TempFile(), "missing.yaml"), "images", "lock", chartDir).AssertErrorMatch(t, "failed to read configuration file")
+
+		configFile := writeConfig(filepath.Join(sb.TempFile(), "config.yaml"), "platfoms: [linux/amd64]\n")
+		dt("--config", configFile, "images", "lock", chartDir).AssertErrorMatch(t, `unknown setting "platfoms"`)
+
+		configFile = writeConfig(filepath.Join(sb.TempFile(), "config.yaml"), "insecure: [true, false]\n")
+		dt("--config", configFile, "images", "lock", chartDir).AssertErrorMatch(t, `invalid value for "insecure"`)
+	})
+}
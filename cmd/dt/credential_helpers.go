@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+// ecrRegistryRegexp matches the Amazon ECR private registries hosts
+var ecrRegistryRegexp = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// cloudCredentialHelper is an authn.Helper running a docker credential helper program for the
+// registries of a cloud provider, so they can be accessed without configuring it in the docker config
+type cloudCredentialHelper struct {
+	// program is the credential helper executable, looked up in the PATH
+	program string
+	// matches returns true if the registry is handled by the helper
+	matches func(registry string) bool
+}
+
+// Get implements authn.Helper
+func (h *cloudCredentialHelper) Get(serverURL string) (string, string, error) {
+	if !h.matches(serverURL) {
+		return "", "", fmt.Errorf("registry %q is not handled by %s", serverURL, h.program)
+	}
+	if _, err := exec.LookPath(h.program); err != nil {
+		return "", "", fmt.Errorf("cannot find credential helper: %w", err)
+	}
+	creds, err := client.Get(client.NewShellProgramFunc(h.program), serverURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get credentials from %s: %w", h.program, err)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// isECRRegistry returns true if registry is an Amazon ECR one
+func isECRRegistry(registry string) bool {
+	return ecrRegistryRegexp.MatchString(registry) || registry == "public.ecr.aws"
+}
+
+// isACRRegistry returns true if registry is an Azure Container Registry one
+func isACRRegistry(registry string) bool {
+	for _, suffix := range []string{".azurecr.io", ".azurecr.cn", ".azurecr.us", ".azurecr.de"} {
+		if strings.HasSuffix(registry, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloudKeychains returns the keychains resolving the credentials of the cloud providers registries:
+// Google Container and Artifact Registry, using the gcloud or application default credentials, and
+// Amazon ECR and Azure Container Registry, using their credential helpers, if installed
+func cloudKeychains() []authn.Keychain {
+	return []authn.Keychain{
+		google.Keychain,
+		authn.NewKeychainFromHelper(&cloudCredentialHelper{program: "docker-credential-ecr-login", matches: isECRRegistry}),
+		authn.NewKeychainFromHelper(&cloudCredentialHelper{program: "docker-credential-acr-env", matches: isACRRegistry}),
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestCloudCredentialHelpers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nread server\necho \"{\\\"ServerURL\\\": \\\"$server\\\", \\\"Username\\\": \\\"AWS\\\", \\\"Secret\\\": \\\"token\\\"}\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "docker-credential-ecr-login"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir)
+
+	kc := authn.NewMultiKeychain(cloudKeychains()...)
+	resolve := func(registry string) authn.Authenticator {
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := kc.Resolve(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth
+	}
+
+	cfg, err := resolve("123456789012.dkr.ecr.us-east-1.amazonaws.com").Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "AWS" || cfg.Password != "token" {
+		t.Errorf("unexpected ECR credentials %s:%s", cfg.Username, cfg.Password)
+	}
+	// Not installed
+	if auth := resolve("example.azurecr.io"); auth != authn.Anonymous {
+		t.Errorf("expected anonymous access to ACR without its credential helper, got %v", auth)
+	}
+	// Not a cloud registry
+	if auth := resolve("example.com"); auth != authn.Anonymous {
+		t.Errorf("expected anonymous access to example.com, got %v", auth)
+	}
+}
+
+func TestCloudRegistries(t *testing.T) {
+	for registry, expected := range map[string]bool{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com":          true,
+		"123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com": true,
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":      true,
+		"public.ecr.aws":                  true,
+		"dkr.ecr.us-east-1.amazonaws.com": false,
+		"example.com":                     false,
+	} {
+		if isECRRegistry(registry) != expected {
+			t.Errorf("expected isECRRegistry(%q) to be %v", registry, expected)
+		}
+	}
+	for registry, expected := range map[string]bool{
+		"example.azurecr.io":     true,
+		"example.azurecr.cn":     true,
+		"azurecr.io.example.com": false,
+	} {
+		if isACRRegistry(registry) != expected {
+			t.Errorf("expected isACRRegistry(%q) to be %v", registry, expected)
+		}
+	}
+}
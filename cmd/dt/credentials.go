@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// Registry credentials flags
+var (
+	registryUsername      string
+	registryPassword      string
+	registryPasswordStdin bool
+	registryCreds         []string
+	registryHost          string
+)
+
+// flagsKeychain resolves the credentials provided through the command line flags
+var flagsKeychain = newCredentialsKeychain()
+
+// credentialsKeychain is an authn.Keychain returning the registry specific credentials, if any, or the
+// default ones for the registries they are scoped to. Other registries resolve as anonymous, so the next
+// keychains are looked up
+type credentialsKeychain struct {
+	auths             map[string]authn.AuthConfig
+	defaultAuth       *authn.AuthConfig
+	defaultRegistries map[string]struct{}
+}
+
+func newCredentialsKeychain() *credentialsKeychain {
+	return &credentialsKeychain{auths: make(map[string]authn.AuthConfig), defaultRegistries: make(map[string]struct{})}
+}
+
+// Resolve implements authn.Keychain
+func (k *credentialsKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k.auths[target.RegistryStr()]; ok {
+		return authn.FromConfig(auth), nil
+	}
+	if _, ok := k.defaultRegistries[target.RegistryStr()]; ok && k.defaultAuth != nil {
+		return authn.FromConfig(*k.defaultAuth), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// scopeDefaultAuth makes the default credentials apply to the registries of urls, which can be OCI
+// references, with or without the oci:// scheme, or http(s) chart repository URLs. Empty urls are ignored
+func (k *credentialsKeychain) scopeDefaultAuth(urls ...string) error {
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		reg, err := urlRegistry(u)
+		if err != nil {
+			return err
+		}
+		k.defaultRegistries[reg] = struct{}{}
+	}
+	return nil
+}
+
+// urlRegistry returns the registry host of the OCI reference or http(s) URL u
+func urlRegistry(u string) (string, error) {
+	host := strings.TrimPrefix(u, "oci://")
+	if utils.IsChartRepositoryURL(u) {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return "", fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+		host = parsed.Host
+	}
+	host, _, _ = strings.Cut(host, "/")
+	if host == "" {
+		return "", fmt.Errorf("invalid registry URL %q: missing host", u)
+	}
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %v", host, err)
+	}
+	return reg.RegistryStr(), nil
+}
+
+// addCreds registers the credentials in the registry=user:pass format
+func (k *credentialsKeychain) addCreds(creds string) error {
+	registry, userPass, found := strings.Cut(creds, "=")
+	if !found || registry == "" {
+		return fmt.Errorf("invalid credentials %q: expected the registry=user:pass format", creds)
+	}
+	user, pass, found := strings.Cut(userPass, ":")
+	if !found || user == "" {
+		return fmt.Errorf("invalid credentials for registry %q: expected the registry=user:pass format", registry)
+	}
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		return fmt.Errorf("invalid registry %q: %v", registry, err)
+	}
+	k.auths[reg.RegistryStr()] = authn.AuthConfig{Username: user, Password: pass}
+	return nil
+}
+
+// authFileKeychain is an authn.Keychain reading the credentials from an auth file in the docker config
+// format, as written by podman login or helm registry login
+type authFileKeychain struct {
+	file string
+}
+
+// Resolve implements authn.Keychain
+func (k *authFileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if k.file == "" || !utils.FileExists(k.file) {
+		return authn.Anonymous, nil
+	}
+	f, err := os.Open(k.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open auth file: %w", err)
+	}
+	defer f.Close()
+	cf, err := config.LoadFromReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse auth file %q: %w", k.file, err)
+	}
+
+	var empty types.AuthConfig
+	// Entries can be scoped to a repository, as podman allows, or to the whole registry
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+		cfg, err := cf.GetAuthConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerAddress = ""
+		if cfg != empty {
+			return authn.FromConfig(authn.AuthConfig{
+				Username:      cfg.Username,
+				Password:      cfg.Password,
+				Auth:          cfg.Auth,
+				IdentityToken: cfg.IdentityToken,
+				RegistryToken: cfg.RegistryToken,
+			}), nil
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+// podmanAuthFiles returns the auth files podman login writes to, in lookup order
+func podmanAuthFiles() []string {
+	files := make([]string, 0)
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "containers", "auth.json"))
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		files = append(files, filepath.Join(dir, "containers", "auth.json"))
+	}
+	return files
+}
+
+// getKeychain returns the keychain used to authenticate against the registries. The credentials are looked
+// up, in order, in the command line flags, the REGISTRY_AUTH_FILE auth file, the docker configuration,
+// the Helm registry configuration, the podman auth files and the cloud providers credential helpers
+func getKeychain() authn.Keychain {
+	keychains := []authn.Keychain{
+		flagsKeychain, &authFileKeychain{file: os.Getenv("REGISTRY_AUTH_FILE")}, authn.DefaultKeychain,
+		&authFileKeychain{file: utils.HelmRegistryConfig()},
+	}
+	for _, file := range podmanAuthFiles() {
+		keychains = append(keychains, &authFileKeychain{file: file})
+	}
+	keychains = append(keychains, cloudKeychains()...)
+	return authn.NewMultiKeychain(keychains...)
+}
+
+// readPassword reads the password from r, removing the trailing newline
+func readPassword(r io.Reader) (string, error) {
+	password, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+// setupCredentials validates the credentials flags and registers them in the flags keychain
+func setupCredentials(cmd *cobra.Command) error {
+	if registryPasswordStdin {
+		if cmd.Flags().Changed("password") {
+			return fmt.Errorf("--password and --password-stdin are mutually exclusive")
+		}
+		password, err := readPassword(cmd.InOrStdin())
+		if err != nil {
+			return err
+		}
+		registryPassword = password
+	}
+	if registryPassword != "" && registryUsername == "" {
+		return fmt.Errorf("--username is required when providing a password")
+	}
+	if registryHost != "" && registryUsername == "" {
+		return fmt.Errorf("--username is required when providing --registry-host")
+	}
+	if registryUsername != "" {
+		flagsKeychain.defaultAuth = &authn.AuthConfig{Username: registryUsername, Password: registryPassword}
+		if err := flagsKeychain.scopeDefaultAuth(registryHost); err != nil {
+			return err
+		}
+	}
+	for _, creds := range registryCreds {
+		if err := flagsKeychain.addCreds(creds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHelmCredentialsFile writes, in the docker config format, the credentials resolved for the registry
+// of chartURL, so Helm uses the same credentials as the images. It returns an empty string if there are none
+func writeHelmCredentialsFile(dir string, chartURL string) (string, error) {
+	return utils.WriteRegistryCredentialsFile(dir, chartURL, getKeychain())
+}
+
+// chartRepositoryCredentials returns the basic auth credentials resolved for the host of the classic Helm
+// chart repository at repoURL, looked up like the registries ones
+func chartRepositoryCredentials(repoURL string) (string, string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid chart repository URL %q: %w", repoURL, err)
+	}
+	reg, err := name.NewRegistry(u.Host)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid chart repository host %q: %w", u.Host, err)
+	}
+	auth, err := getKeychain().Resolve(reg)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve credentials for %q: %w", u.Host, err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve credentials for %q: %w", u.Host, err)
+	}
+	return cfg.Username, cfg.Password, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+// newAuthRegistry returns an in-memory registry requiring the provided basic auth credentials
+func newAuthRegistry(username string, password string) *httptest.Server {
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+}
+
+func (suite *CmdSuite) TestCredentialsFlags() {
+	require := suite.Require()
+
+	s, err := tu.NewTestServer()
+	require.NoError(err)
+	defer s.Close()
+	s.RequireBasicAuth("admin", "s3cr3t")
+
+	images, err := s.LoadImagesFromFile("../../testdata/images.json")
+	require.NoError(err)
+
+	sb := suite.sb
+	scenarioName := "custom-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	t := suite.T()
+
+	createSampleChart := func() string {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": s.ServerURL, "Images": images, "Name": "test", "RepositoryURL": s.ServerURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+
+	t.Run("Fails without credentials", func(t *testing.T) {
+		dt("images", "lock", "--insecure", createSampleChart()).AssertErrorMatch(t, "UNAUTHORIZED|401")
+	})
+	t.Run("Uses the registry credentials", func(t *testing.T) {
+		dt("images", "lock", "--insecure", "--creds", fmt.Sprintf("%s=admin:s3cr3t", s.ServerURL), createSampleChart()).AssertSuccess(t)
+		dt("images", "lock", "--insecure", "--creds", fmt.Sprintf("%s=admin:wrong", s.ServerURL), createSampleChart()).AssertError(t)
+	})
+	t.Run("Uses the default credentials", func(t *testing.T) {
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "s3cr3t",
+			"--registry-host", s.ServerURL, createSampleChart()).AssertSuccess(t)
+		// Registry credentials take precedence
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "s3cr3t", "--registry-host", s.ServerURL,
+			"--creds", fmt.Sprintf("%s=admin:wrong", s.ServerURL), createSampleChart()).AssertError(t)
+	})
+	t.Run("Only sends the default credentials to their registry", func(t *testing.T) {
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "s3cr3t", createSampleChart()).AssertErrorMatch(t, "UNAUTHORIZED|401")
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "s3cr3t",
+			"--registry-host", "registry.example.com", createSampleChart()).AssertErrorMatch(t, "UNAUTHORIZED|401")
+	})
+	writeAuthFile := func(file string, user string, pass string) string {
+		auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		data := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, s.ServerURL, auth)
+		require.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(os.WriteFile(file, []byte(data), 0600))
+		return file
+	}
+	t.Run("Uses the REGISTRY_AUTH_FILE credentials", func(t *testing.T) {
+		t.Setenv("REGISTRY_AUTH_FILE", writeAuthFile(filepath.Join(sb.TempFile(), "auth.json"), "admin", "s3cr3t"))
+		dt("images", "lock", "--insecure", createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Uses the Helm registry credentials", func(t *testing.T) {
+		// Helm sets HELM_REGISTRY_CONFIG when running dt as a plugin
+		t.Setenv("HELM_REGISTRY_CONFIG", writeAuthFile(filepath.Join(sb.TempFile(), "registry", "config.json"), "admin", "s3cr3t"))
+		dt("images", "lock", "--insecure", createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Uses the podman credentials", func(t *testing.T) {
+		runtimeDir := sb.TempFile()
+		writeAuthFile(filepath.Join(runtimeDir, "containers", "auth.json"), "admin", "s3cr3t")
+		t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+		dt("images", "lock", "--insecure", createSampleChart()).AssertSuccess(t)
+
+		// Credentials in the command line take precedence
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "wrong", "--registry-host", s.ServerURL, createSampleChart()).AssertError(t)
+		// but only for their registry
+		dt("images", "lock", "--insecure", "--username", "admin", "--password", "wrong", createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Validates the flags", func(t *testing.T) {
+		chartDir := createSampleChart()
+		dt("images", "lock", "--creds", "admin:s3cr3t", chartDir).AssertErrorMatch(t, `expected the registry=user:pass format`)
+		dt("images", "lock", "--creds", "example.com=admin", chartDir).AssertErrorMatch(t, `expected the registry=user:pass format`)
+		dt("images", "lock", "--password", "s3cr3t", chartDir).AssertErrorMatch(t, `--username is required`)
+		dt("images", "lock", "--registry-host", "example.com", chartDir).AssertErrorMatch(t, `--username is required`)
+		dt("images", "lock", "--username", "admin", "--password", "s3cr3t", "--password-stdin", chartDir).AssertErrorMatch(t, `mutually exclusive`)
+	})
+}
+
+func (suite *CmdSuite) TestCredentialsPushTargets() {
+	require := suite.Require()
+	sb := suite.sb
+	t := suite.T()
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	authServer := newAuthRegistry("admin", "s3cr3t")
+	defer authServer.Close()
+	u, err := url.Parse(authServer.URL)
+	require.NoError(err)
+	authURL := u.Host
+
+	t.Run("Applies the default credentials to the Images.lock registries in images push", func(t *testing.T) {
+		imageData := tu.ImageData{Name: "test", Image: "test:mytag"}
+		craneImgs, err := tu.CreateSampleImages(&imageData, []string{"linux/amd64", "linux/arm"})
+		require.NoError(err)
+
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": authURL, "Images": []tu.ImageData{imageData}, "Name": "test", "RepositoryURL": authURL},
+		))
+		chartDir := filepath.Join(dest, scenarioName)
+		imagesDir := filepath.Join(chartDir, "images")
+		require.NoError(os.MkdirAll(imagesDir, 0755))
+		for _, img := range craneImgs {
+			d, err := img.Digest()
+			require.NoError(err)
+			require.NoError(crane.Save(img, imageData.Image, filepath.Join(imagesDir, fmt.Sprintf("%s.tar", d.Hex))))
+		}
+
+		dt("images", "push", chartDir).AssertErrorMatch(t, "UNAUTHORIZED|401")
+		dt("images", "push", chartDir, "--username", "admin", "--password", "wrong").AssertErrorMatch(t, "UNAUTHORIZED|401")
+		dt("images", "push", chartDir, "--username", "admin", "--password", "s3cr3t").AssertSuccessMatch(t, "All images pushed successfully")
+	})
+	t.Run("Applies the default credentials to the images copy destination", func(t *testing.T) {
+		s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+		defer s.Close()
+		su, err := url.Parse(s.URL)
+		require.NoError(err)
+		serverURL := su.Host
+
+		images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+		require.NoError(err)
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+		))
+		lockFile := filepath.Join(dest, scenarioName, "Images.lock")
+		target := fmt.Sprintf("%s/copies", authURL)
+
+		dt("images", "copy", lockFile, target).AssertErrorMatch(t, "UNAUTHORIZED|401")
+		dt("images", "copy", lockFile, target, "--username", "admin", "--password", "s3cr3t").AssertSuccessMatch(t, "All images copied successfully")
+	})
+}
+
+func TestCredentialsKeychain(t *testing.T) {
+	k := newCredentialsKeychain()
+	resolve := func(registry string) authn.Authenticator {
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := k.Resolve(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth
+	}
+
+	if auth := resolve("example.com"); auth != authn.Anonymous {
+		t.Errorf("expected anonymous access without credentials, got %v", auth)
+	}
+	if err := k.addCreds("docker.io=user:pass:with:colons"); err != nil {
+		t.Fatal(err)
+	}
+	k.defaultAuth = &authn.AuthConfig{Username: "default", Password: "pass"}
+
+	cfg, err := resolve("index.docker.io").Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "user" || cfg.Password != "pass:with:colons" {
+		t.Errorf("unexpected credentials for index.docker.io: %s:%s", cfg.Username, cfg.Password)
+	}
+	if auth := resolve("example.com"); auth != authn.Anonymous {
+		t.Errorf("expected anonymous access for registries the default credentials are not scoped to, got %v", auth)
+	}
+	if err := k.scopeDefaultAuth("oci://example.com/charts", "https://charts.example.com/api", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, registry := range []string{"example.com", "charts.example.com"} {
+		cfg, err = resolve(registry).Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Username != "default" {
+			t.Errorf("expected the default credentials for %s, got %q", registry, cfg.Username)
+		}
+	}
+	if err := k.scopeDefaultAuth("oci:///charts"); err == nil {
+		t.Errorf("expected an error for a URL without host")
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	for input, expected := range map[string]string{"s3cr3t\n": "s3cr3t", "s3cr3t\r\n": "s3cr3t", "s3cr3t": "s3cr3t", "": ""} {
+		password, err := readPassword(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != expected {
+			t.Errorf("expected %q, got %q", expected, password)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var doctorCmd = newDoctorCommand()
+
+// doctorCheck is the result of one of the preflight checks
+type doctorCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	// Fix describes how to solve the problem found, if any
+	Fix string `json:"fix,omitempty"`
+}
+
+// doctorReport is the result of all the preflight checks
+type doctorReport struct {
+	Chart  string        `json:"chart"`
+	Target string        `json:"target,omitempty"`
+	Checks []doctorCheck `json:"checks"`
+}
+
+func (r *doctorReport) pass(name string, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, OK: true, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *doctorReport) fail(name string, fix string, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+// failed returns the number of failed checks
+func (r *doctorReport) failed() int {
+	n := 0
+	for _, c := range r.Checks {
+		if !c.OK {
+			n++
+		}
+	}
+	return n
+}
+
+// doctorCraneOptions returns the options used to access the registries
+func doctorCraneOptions(ctx context.Context) []crane.Option {
+	opts := []crane.Option{crane.WithContext(ctx), crane.WithAuthFromKeychain(getKeychain())}
+	if insecure {
+		opts = append(opts, crane.Insecure)
+	}
+	if tr := getTransport(); tr != nil {
+		opts = append(opts, crane.WithTransport(tr))
+	}
+	return opts
+}
+
+// readDoctorLock reads the chart Images.lock, or generates it if the chart does not include one
+func readDoctorLock(ctx context.Context, chartPath string) (*imagelock.ImagesLock, error) {
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if utils.FileExists(lockFile) {
+		return imagelock.FromYAMLFile(lockFile)
+	}
+	return imagelock.GenerateFromChart(chartPath,
+		imagelock.WithAnnotationsKey(getAnnotationsKey()),
+		imagelock.WithContext(ctx),
+		imagelock.WithInsecure(insecure),
+		imagelock.WithKeychain(getKeychain()),
+		imagelock.WithTransport(getTransport()),
+		imagelock.WithMirrors(getMirrors()),
+	)
+}
+
+// sourceRegistryFix returns how to fix err, returned when pulling from registry
+func sourceRegistryFix(registry string, err error) string {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Sprintf("log in with credentials allowed to pull the images: docker login %s, or provide them with --creds %s=USER:PASSWORD", registry, registry)
+		case http.StatusNotFound:
+			return "the image is no longer available, regenerate the Images.lock with \"dt images lock\""
+		}
+	}
+	return fmt.Sprintf("make sure %s is reachable from this host (DNS, firewall and HTTPS_PROXY settings), or use --insecure if it uses a self-signed certificate", registry)
+}
+
+// checkSourceRegistries checks the images in the lock can be pulled from their registries, returning the
+// estimated size of the images
+func checkSourceRegistries(ctx context.Context, lock *imagelock.ImagesLock, r *doctorReport) int64 {
+	registries := make([]string, 0)
+	images := make(map[string][]*imagelock.ChartImage)
+	for _, img := range lock.Images {
+		registry := img.Image
+		if ref, err := name.ParseReference(img.Image); err == nil {
+			registry = ref.Context().RegistryStr()
+		}
+		if _, ok := images[registry]; !ok {
+			registries = append(registries, registry)
+		}
+		images[registry] = append(images[registry], img)
+	}
+
+	var size int64
+	for _, registry := range registries {
+		checkName := fmt.Sprintf("Pull from %s", registry)
+		registrySize, err := remoteImagesSize(ctx, images[registry])
+		if err != nil {
+			r.fail(checkName, sourceRegistryFix(registry, err), "%v", err)
+			continue
+		}
+		size += registrySize
+		r.pass(checkName, "%d images can be pulled", len(images[registry]))
+	}
+	return size
+}
+
+// manifestFromMirrors reads the manifest of image, trying the registry mirrors first
+func manifestFromMirrors(ctx context.Context, image string) ([]byte, error) {
+	opts := doctorCraneOptions(ctx)
+	ref, err := name.ParseReference(image, crane.GetOptions(opts...).Name...)
+	if err != nil {
+		return nil, err
+	}
+	return imagelock.Fetch(getMirrors(), ref, func(r name.Reference) ([]byte, error) {
+		return crane.Manifest(r.String(), opts...)
+	})
+}
+
+// remoteImagesSize returns the size of the layers and configs of images, failing if any of them cannot be read
+func remoteImagesSize(ctx context.Context, images []*imagelock.ChartImage) (int64, error) {
+	var size int64
+	for _, img := range images {
+		for _, dgst := range img.Digests {
+			data, err := manifestFromMirrors(ctx, fmt.Sprintf("%s@%s", img.Image, dgst.Digest))
+			if err != nil {
+				return 0, fmt.Errorf("failed to read image %q (%s): %w", img.Image, dgst.Arch, err)
+			}
+			m, err := v1.ParseManifest(bytes.NewReader(data))
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse manifest of image %q (%s): %w", img.Image, dgst.Arch, err)
+			}
+			size += m.Config.Size
+			for _, layer := range m.Layers {
+				size += layer.Size
+			}
+		}
+	}
+	return size, nil
+}
+
+// checkDiskSpace checks the temporary directory has room for the required bytes
+func checkDiskSpace(required int64, r *doctorReport) {
+	const checkName = "Disk space"
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		r.fail(checkName, "set TMPDIR to a writable directory", "%v", err)
+		return
+	}
+	dir := filepath.Dir(tmpDir)
+	available, err := utils.AvailableDiskSpace(dir)
+	if err != nil {
+		r.fail(checkName, "set TMPDIR to a directory in a local filesystem", "%v", err)
+		return
+	}
+	if uint64(required) > available {
+		r.fail(checkName, "free some space or set TMPDIR to a directory in a larger filesystem",
+			"%s available in %q, but about %s are required", units.HumanSize(float64(available)), dir, units.HumanSize(float64(required)))
+		return
+	}
+	r.pass(checkName, "%s available in %q (about %s required)", units.HumanSize(float64(available)), dir, units.HumanSize(float64(required)))
+}
+
+// checkTargetRepositories checks the relocated images and chart can be pushed under target
+func checkTargetRepositories(ctx context.Context, lock *imagelock.ImagesLock, target string, r *doctorReport) {
+	nameOpts := crane.GetOptions(doctorCraneOptions(ctx)...).Name
+	tr := getTransport()
+	if tr == nil {
+		defaultTransport := remote.DefaultTransport.(*http.Transport).Clone()
+		if insecure {
+			defaultTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402
+		}
+		tr = defaultTransport
+	}
+
+	target = strings.TrimRight(strings.TrimPrefix(target, "oci://"), "/")
+	repositories := make([]string, 0)
+	for _, img := range lock.Images {
+		relocated, err := utils.RelocateImageURL(img.Image, target, false)
+		if err != nil {
+			r.fail(fmt.Sprintf("Relocate %s", img.Image), "make sure the target is a valid OCI repository prefix", "%v", err)
+			continue
+		}
+		repositories = append(repositories, relocated)
+	}
+	repositories = append(repositories, fmt.Sprintf("%s/%s", target, lock.Chart.Name))
+
+	seen := make(map[string]bool)
+	for _, repo := range repositories {
+		if seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		checkName := fmt.Sprintf("Push to %s", repo)
+		ref, err := name.ParseReference(repo, nameOpts...)
+		if err != nil {
+			r.fail(checkName, "make sure the target is a valid OCI repository prefix", "%v", err)
+			continue
+		}
+		if err := remote.CheckPushPermission(ref, getKeychain(), tr); err != nil {
+			registry := ref.Context().RegistryStr()
+			r.fail(checkName, fmt.Sprintf("log in with credentials allowed to push to the repository: docker login %s, or provide them with --creds %s=USER:PASSWORD", registry, registry), "%v", err)
+			continue
+		}
+		r.pass(checkName, "credentials allow pushing")
+	}
+}
+
+// checkChartDependencies checks the dependencies of the chart at chartPath, if any, are vendored and match its Chart.lock
+func checkChartDependencies(chartPath string, r *doctorReport) {
+	const checkName = "Chart dependencies"
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil || len(chart.Metadata.Dependencies) == 0 {
+		return
+	}
+	if missing := chart.MissingDependencies(); len(missing) > 0 {
+		r.fail(checkName, "run helm dependency build, or wrap the chart with --dependency-build",
+			"%s not vendored under charts/", strings.Join(missing, ", "))
+		return
+	}
+	if err := chart.VerifyLock(); err != nil {
+		r.fail(checkName, "run helm dependency update to refresh Chart.lock and the charts/ directory", "%v", err)
+		return
+	}
+	r.pass(checkName, "%d dependencies vendored", len(chart.Metadata.Dependencies))
+}
+
+// runPreflightChecks validates the environment to wrap or unwrap the chart at chartPath, pushing it to target if not empty
+func runPreflightChecks(ctx context.Context, chartPath string, target string, r *doctorReport) {
+	checkChartDependencies(chartPath, r)
+	lock, err := readDoctorLock(ctx, chartPath)
+	if err != nil {
+		r.fail("Images.lock", "make sure the chart images are annotated, and that their registries are reachable", "%v", err)
+		return
+	}
+	r.pass("Images.lock", "%d images found", len(lock.Images))
+
+	var required int64
+	chart, err := chartutils.LoadChart(chartPath)
+	if err == nil && len(lock.Images) > 0 && chartutils.VerifyImages(lock, chart.ImagesDir()) == nil {
+		r.pass("Images", "all the images are bundled with the chart")
+	} else {
+		required = checkSourceRegistries(ctx, lock, r)
+	}
+	checkDiskSpace(required, r)
+
+	if target != "" {
+		checkTargetRepositories(ctx, lock, target, r)
+	}
+}
+
+// showDoctorReport prints the checks results
+func showDoctorReport(r *doctorReport, l log.SectionLogger) {
+	for _, c := range r.Checks {
+		if c.OK {
+			l.Successf("%s: %s", c.Name, c.Message)
+			continue
+		}
+		l.Errorf("%s: %s", c.Name, c.Message)
+		l.Printf("Fix: %s", c.Fix)
+	}
+}
+
+func newDoctorCommand() *cobra.Command {
+	var version string
+	var outputFormat = textOutput
+
+	cmd := &cobra.Command{
+		Use:   "doctor CHART_PATH|OCI_URI|WRAP [TARGET_OCI_URI]",
+		Short: "Validates the environment before wrapping or unwrapping a Helm chart",
+		Long: `Runs preflight checks before a wrap or unwrap: the registries of the images in the Images.lock are reachable
+and the credentials allow pulling them, there is enough disk space in the temporary directory, and, if a target is
+provided, the credentials allow pushing the relocated images and Helm chart`,
+		Example: `  # Check a Helm chart can be wrapped
+  $ dt doctor examples/mariadb
+
+  # Check a wrap can be unwrapped into a registry
+  $ dt doctor mariadb-13.0.0.wrap.tgz oci://demo.goharbor.io/helm-plugin`,
+		Args:          cobra.RangeArgs(1, 2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			if !isRemoteChart(args[0]) && !utils.FileExists(args[0]) {
+				return fmt.Errorf("Helm chart %q does not exist", args[0])
+			}
+			report := &doctorReport{Chart: args[0], Checks: make([]doctorCheck, 0)}
+			if len(args) > 1 {
+				report.Target = args[1]
+			}
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+
+			parentLog := getOutputLogger(outputFormat)
+			l := parentLog.StartSection(fmt.Sprintf("Running preflight checks for %q", report.Chart))
+			chartPath, _, err := resolveInputChartPath(report.Chart, l, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			runPreflightChecks(ctx, chartPath, report.Target, report)
+
+			if isStructuredOutput(outputFormat) {
+				if err := writeStructuredOutput(os.Stdout, outputFormat, report); err != nil {
+					return err
+				}
+			} else {
+				showDoctorReport(report, l)
+			}
+			if failed := report.failed(); failed > 0 {
+				return fmt.Errorf("%d of %d preflight checks failed", failed, len(report.Checks))
+			}
+			parentLog.Successf("All preflight checks passed")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", version, "when checking remote Helm charts from OCI, version to request")
+	addOutputFlag(cmd, &outputFormat)
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *CmdSuite) TestDoctorCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+	require.NoError(err)
+
+	sb := suite.sb
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	createSampleChart := func(serverURL string) string {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+	readReport := func(t *testing.T, res CmdResult) *doctorReport {
+		report := &doctorReport{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), report), "invalid output: %s", res.stdout)
+		return report
+	}
+	checkNames := func(report *doctorReport) []string {
+		names := make([]string, 0)
+		for _, c := range report.Checks {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	t.Run("Passes the preflight checks", func(t *testing.T) {
+		chartDir := createSampleChart(serverURL)
+		target := fmt.Sprintf("%s/doctor", serverURL)
+
+		res := dt("doctor", chartDir, target, "--output", "json")
+		res.AssertSuccess(t)
+		report := readReport(t, res)
+		assert.Equal(chartDir, report.Chart)
+		assert.Equal(target, report.Target)
+		assert.Equal([]string{
+			"Images.lock",
+			fmt.Sprintf("Pull from %s", serverURL),
+			"Disk space",
+			fmt.Sprintf("Push to %s/test", target),
+		}, checkNames(report))
+		for _, c := range report.Checks {
+			assert.True(c.OK, "check %q failed: %s", c.Name, c.Message)
+		}
+
+		dt("doctor", chartDir).AssertSuccessMatch(t, "All preflight checks passed")
+	})
+	t.Run("Reports unreachable registries", func(t *testing.T) {
+		closed := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+		closedURL, err := url.Parse(closed.URL)
+		require.NoError(err)
+		closed.Close()
+
+		chartDir := createSampleChart(closedURL.Host)
+
+		res := dt("doctor", chartDir, "--output", "json")
+		res.AssertErrorMatch(t, "1 of 3 preflight checks failed")
+		report := readReport(t, res)
+		require.Len(report.Checks, 3)
+		pullCheck := report.Checks[1]
+		assert.Equal(fmt.Sprintf("Pull from %s", closedURL.Host), pullCheck.Name)
+		assert.False(pullCheck.OK)
+		assert.Contains(pullCheck.Fix, "is reachable from this host")
+
+		dt("doctor", chartDir).AssertErrorMatch(t, "preflight checks failed")
+	})
+	t.Run("Reports missing chart dependencies", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest, map[string]interface{}{
+			"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL,
+			"Dependencies": []map[string]string{{"Name": "common", "Repository": "oci://registry-1.docker.io/bitnamicharts", "Version": "2.x.x"}},
+		}))
+
+		res := dt("doctor", filepath.Join(dest, scenarioName), "--output", "json")
+		res.AssertErrorMatch(t, "1 of 4 preflight checks failed")
+		report := readReport(t, res)
+		depsCheck := report.Checks[0]
+		assert.Equal("Chart dependencies", depsCheck.Name)
+		assert.False(depsCheck.OK)
+		assert.Equal("common not vendored under charts/", depsCheck.Message)
+		assert.Contains(depsCheck.Fix, "--dependency-build")
+	})
+}
@@ -2,14 +2,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
 )
 
 var (
 	// this variable is modified externally through the --keep-artifacts global flag
 	keepArtifacts bool
+	// workDir, set through the --work-dir global flag, is where the global temporary directory is
+	// created instead of the system one
+	workDir string
 
 	// global temporary directory used to store different assets
 	globalTempWorkDir      string
@@ -37,7 +43,12 @@ func getGlobalTempWorkDir() (string, error) {
 	defer globalTempWorkDirMutex.Unlock()
 
 	if globalTempWorkDir == "" {
-		dir, err := os.MkdirTemp("", "chart-*")
+		if workDir != "" {
+			if err := os.MkdirAll(workDir, 0755); err != nil {
+				return "", fmt.Errorf("failed to create work directory: %w", err)
+			}
+		}
+		dir, err := os.MkdirTemp(workDir, "chart-*")
 		if err != nil {
 			return "", fmt.Errorf("failed to create temporary directory: %w", err)
 		}
@@ -46,12 +57,29 @@ func getGlobalTempWorkDir() (string, error) {
 	return globalTempWorkDir, nil
 }
 
-func main() {
+func run() int {
 	// Make sure we clean up after ourselves
 	defer cleanGlobalTempWorkDir()
 
+	shutdownTracing, err := tracing.Setup(context.Background(), Version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up tracing: %v\n", err)
+	} else {
+		defer func() {
+			if err := shutdownTracing(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", err)
+			}
+		}()
+	}
+
+	setupCompletion(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return 1
 	}
+	return 0
+}
+
+func main() {
+	os.Exit(run())
 }
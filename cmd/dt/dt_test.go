@@ -43,9 +43,10 @@ func (suite *CmdSuite) SetupSuite() {
 	suite.sb = tu.NewSandbox()
 }
 
-// dt calls the dt command externally via exec
+// dt calls the dt command externally via exec. Its output is not a terminal, so the plain log, written
+// to stderr, is explicitly disabled to keep the logs in stdout, where the tests look for them
 func dt(cmdArgs ...string) CmdResult {
-	return execCommand(cmdArgs...)
+	return execCommand(append([]string{"--plain=false"}, cmdArgs...)...)
 }
 
 func TestDtToolCommand(t *testing.T) {
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// docsRootName is the name the commands are documented with, regardless of the binary name
+const docsRootName = "dt"
+
+var genDocsCmd = newGenDocsCmd()
+
+// genDocsTree writes the documentation of root and its subcommands into dir, in the given format
+func genDocsTree(root *cobra.Command, dir string, format string) error {
+	use := root.Use
+	root.Use = docsRootName
+	defer func() { root.Use = use }()
+	// Keep the generated files reproducible
+	root.DisableAutoGenTag = true
+
+	switch format {
+	case "man":
+		return doc.GenManTree(root, &doc.GenManHeader{
+			Section: "1",
+			Source:  fmt.Sprintf("dt %s", Version),
+			Manual:  "Distribution Tooling for Helm",
+		}, dir)
+	case "markdown":
+		return doc.GenMarkdownTree(root, dir)
+	default:
+		return fmt.Errorf("unsupported docs format %q", format)
+	}
+}
+
+func newGenDocsCmd() *cobra.Command {
+	format := "man"
+	outputDir := "docs"
+
+	cmd := &cobra.Command{
+		Use:   "gen-docs",
+		Short: "Generates the man pages or markdown reference of the commands",
+		Long:  "Generates a man page, or a markdown reference, for every dt command into the output directory, from the commands and flags of the running binary",
+		Example: `  # Generate the man pages to ship in a package
+  $ dt gen-docs --format man --output-dir out/man
+
+  # Generate the markdown commands reference
+  $ dt gen-docs --format markdown --output-dir docs/reference`,
+		Hidden:        true,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+			return genDocsTree(cmd.Root(), outputDir, format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", format, "documentation format (man, markdown)")
+	cmd.Flags().StringVar(&outputDir, "output-dir", outputDir, "directory the documentation is written into")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(genDocsCmd)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func (suite *CmdSuite) TestGenDocsCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	t.Run("Generates the man pages", func(t *testing.T) {
+		dir := filepath.Join(sb.TempFile(), "man")
+		dt("gen-docs", "--output-dir", dir).AssertSuccess(t)
+
+		data, err := os.ReadFile(filepath.Join(dir, "dt-images-lock.1"))
+		require.NoError(err)
+		assert.Regexp(`(?m)^\.TH "DT-IMAGES-LOCK" "1"`, string(data))
+		assert.Contains(string(data), "\\fBdt images lock CHART_PATH [flags]\\fP")
+		assert.Contains(string(data), "\\fB--output-file\\fP")
+		assert.Contains(string(data), "\\fBdt-images(1)\\fP")
+		assert.FileExists(filepath.Join(dir, "dt.1"))
+		assert.FileExists(filepath.Join(dir, "dt-wrap.1"))
+		// Hidden commands are not documented
+		assert.NoFileExists(filepath.Join(dir, "dt-gen-docs.1"))
+	})
+	t.Run("Generates the markdown reference", func(t *testing.T) {
+		dir := filepath.Join(sb.TempFile(), "reference")
+		dt("gen-docs", "--format", "markdown", "--output-dir", dir).AssertSuccess(t)
+
+		data, err := os.ReadFile(filepath.Join(dir, "dt_images.md"))
+		require.NoError(err)
+		assert.Contains(string(data), "## dt images\n")
+		assert.Contains(string(data), "* [dt images lock](dt_images_lock.md)\t - Creates the lock file")
+		assert.FileExists(filepath.Join(dir, "dt_wrap.md"))
+	})
+	t.Run("Fails on unsupported formats", func(t *testing.T) {
+		dt("gen-docs", "--format", "html", "--output-dir", sb.TempFile()).AssertErrorMatch(t, `unsupported docs format "html"`)
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+const (
+	beforePullStage = "before-pull"
+	afterPushStage  = "after-push"
+)
+
+var (
+	// beforePullHooks are the commands run before pulling the images of a chart
+	beforePullHooks []string
+	// afterPushHooks are the commands run after pushing the images of a chart
+	afterPushHooks []string
+)
+
+// hookEnv returns the environment variables describing the chart and images set to the hooks
+func hookEnv(stage string, chartRoot string, lockFile string, lock *imagelock.ImagesLock) []string {
+	images := make([]string, 0, len(lock.Images))
+	for _, img := range lock.Images {
+		images = append(images, img.Image)
+	}
+	return append(os.Environ(),
+		"DT_HOOK_STAGE="+stage,
+		"DT_HOOK_CHART_NAME="+lock.Chart.Name,
+		"DT_HOOK_CHART_VERSION="+lock.Chart.Version,
+		"DT_HOOK_CHART_DIR="+chartRoot,
+		"DT_HOOK_IMAGES_LOCK="+lockFile,
+		"DT_HOOK_IMAGES="+strings.Join(images, "\n"),
+	)
+}
+
+// runHooks runs the stage hook commands, in order, with the shell. The first failing command aborts the run
+func runHooks(ctx context.Context, stage string, commands []string, chartRoot string, lockFile string, lock *imagelock.ImagesLock) error {
+	for _, command := range commands {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		cmd.Env = hookEnv(stage, chartRoot, lockFile, lock)
+		cmd.Dir = chartRoot
+		// Keep stdout for the command results
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", stage, command, err)
+		}
+	}
+	return nil
+}
@@ -1,11 +1,7 @@
 package main
 
 import (
-	"path/filepath"
-
 	"github.com/spf13/cobra"
-	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
-	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 )
 
 var imagesCmd = &cobra.Command{
@@ -18,15 +14,6 @@ var imagesCmd = &cobra.Command{
 	},
 }
 
-func getImageLockFilePath(chartPath string) (string, error) {
-	chartRoot, err := chartutils.GetChartRoot(chartPath)
-	if err != nil {
-		return "", err
-	}
-
-	return filepath.Join(chartRoot, imagelock.DefaultImagesLockFileName), nil
-}
-
 func init() {
-	imagesCmd.AddCommand(lockCmd, verifyCmd, pullCmd, pushCmd)
+	imagesCmd.AddCommand(lockCmd, verifyCmd, pullCmd, pushCmd, imagesReportCmd, imagesExportCmd, imagesLoadCmd, imagesCopyCmd)
 }
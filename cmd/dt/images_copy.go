@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+var imagesCopyCmd = newImagesCopyCmd()
+
+// readImageReferences reads the image references listed in data, one per line, skipping empty lines and comments
+func readImageReferences(data []byte) []string {
+	images := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		images = append(images, line)
+	}
+	return images
+}
+
+// readImagesToCopy reads the images to copy from file, either an Images.lock or a plain list of image
+// references, which are locked with their current digests
+func readImagesToCopy(ctx context.Context, file string, platforms []string) (*imagelock.ImagesLock, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images file: %w", err)
+	}
+	if lock, err := imagelock.FromYAML(bytes.NewReader(data)); err == nil && len(lock.Images) > 0 {
+		return lock, nil
+	}
+	images := readImageReferences(data)
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%q is neither an Images.lock nor a list of images", file)
+	}
+	return imagelock.GenerateFromImages(images,
+		imagelock.WithPlatforms(platforms),
+		imagelock.WithContext(ctx),
+		imagelock.WithInsecure(insecure),
+		imagelock.WithKeychain(getKeychain()),
+		imagelock.WithTransport(getTransport()),
+		imagelock.WithMirrors(getMirrors()),
+		imagelock.WithRegistryFilter(getRegistryFilter()),
+	)
+}
+
+// copyImages copies the images in lock into the references relocated into prefix with settings, returning
+// the relocation report
+func copyImages(ctx context.Context, lock *imagelock.ImagesLock, prefix string, settings relocateSettings, l log.SectionLogger) (*relocator.Report, error) {
+	opts, err := settings.options("")
+	if err != nil {
+		return nil, err
+	}
+	report, err := relocator.NewReport(lock, prefix, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to relocate images: %w", err)
+	}
+	targets := make(map[string]string)
+	for _, m := range report.Images {
+		targets[m.Source] = m.Target
+	}
+	return report, chartutils.CopyImages(lock, targets, append([]chartutils.Option{
+		chartutils.WithLog(log.SilentLog),
+		chartutils.WithContext(ctx),
+		chartutils.WithRegistryFilter(getRegistryFilter()),
+	}, imageTransferOptions(l)...)...)
+}
+
+func newImagesCopyCmd() *cobra.Command {
+	var platforms []string
+	settings := relocateSettings{}
+
+	cmd := &cobra.Command{
+		Use:   "copy IMAGES_LOCK|IMAGES_LIST OCI_URI",
+		Short: "Copies the images of an Images.lock, or a list of images, into a registry",
+		Long: `Copies the images of an Images.lock, or of a plain list of image references (one per line), from their registries into OCI_URI, without requiring a Helm chart or storing the images locally.
+The images are relocated as "dt relocate" does, and copied with their locked platforms (or the ones requested with --platforms for the images in a list)`,
+		Example: `  # Copy the images of a Helm chart into a registry
+  $ dt images copy examples/mariadb/Images.lock demo.goharbor.io/test_repo
+
+  # Copy a list of images, keeping only their amd64 platform
+  $ dt images copy images.txt demo.goharbor.io/test_repo --platforms linux/amd64`,
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, prefix := args[0], args[1]
+			if err := flagsKeychain.scopeDefaultAuth(prefix); err != nil {
+				return err
+			}
+
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+			l := getLogger()
+
+			var lock *imagelock.ImagesLock
+			if err := l.ExecuteStep(fmt.Sprintf("Reading images from %q", file), func() error {
+				var err error
+				lock, err = readImagesToCopy(ctx, file, platforms)
+				return err
+			}); err != nil {
+				return l.Failf("Failed to read images: %w", err)
+			}
+
+			var report *relocator.Report
+			if err := l.Section(fmt.Sprintf("Copying %d images into %q", len(lock.Images), prefix), func(subLog log.SectionLogger) error {
+				var err error
+				if report, err = copyImages(ctx, lock, prefix, settings, subLog); err != nil {
+					return subLog.Failf("Failed to copy images: %w", err)
+				}
+				for _, m := range report.Images {
+					subLog.Infof("Copied %q into %q", m.Source, m.Target)
+				}
+				return writeRelocationReports(report, settings, subLog)
+			}); err != nil {
+				return err
+			}
+			l.Printf(terminalSpacer)
+			l.Successf("All images copied successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&platforms, "platforms", platforms, "platforms to copy of the images in a list (all of them by default)")
+	cmd.Flags().StringVar(&settings.ReportFile, "report-file", settings.ReportFile, "write a report mapping the original image references to the copied ones (JSON, or CSV if the file has the .csv extension)")
+	cmd.Flags().StringVar(&settings.KbldConfigFile, "kbld-config", settings.KbldConfigFile, kbldConfigUsage)
+	cmd.Flags().StringVar(&settings.RepositoryStrategy, "repository-strategy", settings.RepositoryStrategy, repositoryStrategyUsage)
+	cmd.Flags().StringVar(&settings.RepositoryMapFile, "repo-map", settings.RepositoryMapFile, "YAML file mapping source repositories to custom target repositories, instead of the ones under OCI_URI")
+	return cmd
+}
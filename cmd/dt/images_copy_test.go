@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+func (suite *CmdSuite) TestImagesCopyCommand() {
+	t := suite.T()
+	sb := suite.sb
+	require := suite.Require()
+	assert := suite.Assert()
+
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+	require.NoError(err)
+	scenarioName := "complete-chart"
+	dest := sb.TempFile()
+	require.NoError(tu.RenderScenario(fmt.Sprintf("../../testdata/scenarios/%s", scenarioName), dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+	))
+	lockFile := filepath.Join(dest, scenarioName, "Images.lock")
+
+	t.Run("Copies the images of an Images.lock", func(t *testing.T) {
+		reportFile := filepath.Join(sb.TempFile(), "report.json")
+		require.NoError(os.MkdirAll(filepath.Dir(reportFile), 0755))
+		target := fmt.Sprintf("%s/copies", serverURL)
+
+		dt("images", "copy", lockFile, target, "--report-file", reportFile).AssertSuccessMatch(t, "All images copied successfully")
+
+		data, err := os.ReadFile(reportFile)
+		require.NoError(err)
+		var report relocator.Report
+		require.NoError(json.Unmarshal(data, &report))
+		require.Len(report.Images, len(images))
+		for i, img := range images {
+			assert.Equal(fmt.Sprintf("%s/test:mytag", target), report.Images[i].Target)
+			remoteDigests, err := tu.ReadRemoteImageManifest(report.Images[i].Target)
+			require.NoError(err)
+			assert.Len(remoteDigests, len(img.Digests))
+			for _, dgst := range img.Digests {
+				assert.Equal(dgst.Digest.Hex(), remoteDigests[dgst.Arch].Digest.Hex())
+			}
+		}
+	})
+	t.Run("Copies a list of images", func(t *testing.T) {
+		listFile := filepath.Join(sb.TempFile(), "images.txt")
+		require.NoError(os.MkdirAll(filepath.Dir(listFile), 0755))
+		require.NoError(os.WriteFile(listFile, []byte(fmt.Sprintf("# Images to copy\n%s/test:mytag\n\n", serverURL)), 0644))
+		target := fmt.Sprintf("%s/list", serverURL)
+
+		dt("images", "copy", listFile, target, "--platforms", "linux/amd64").AssertSuccessMatch(t, "All images copied successfully")
+
+		remoteDigests, err := tu.ReadRemoteImageManifest(fmt.Sprintf("%s/test:mytag", target))
+		require.NoError(err)
+		require.Len(remoteDigests, 1)
+		assert.Contains(remoteDigests, "linux/amd64")
+	})
+	t.Run("Handles errors", func(t *testing.T) {
+		dt("images", "copy", sb.TempFile(), serverURL).AssertErrorMatch(t, "failed to read images file")
+
+		emptyFile := filepath.Join(sb.TempFile(), "images.txt")
+		require.NoError(os.MkdirAll(filepath.Dir(emptyFile), 0755))
+		require.NoError(os.WriteFile(emptyFile, []byte("# Nothing to copy\n"), 0644))
+		dt("images", "copy", emptyFile, serverURL).AssertErrorMatch(t, "neither an Images.lock nor a list of images")
+
+		require.NoError(os.WriteFile(emptyFile, []byte(fmt.Sprintf("%s/missing:1.0.0\n", serverURL)), 0644))
+		dt("images", "copy", emptyFile, serverURL).AssertErrorMatch(t, "failed to fetch image")
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+var imagesExportCmd = newImagesExportCmd()
+
+// skopeoSyncRegistry is the skopeo sync configuration of a source registry
+type skopeoSyncRegistry struct {
+	// Images maps the repositories to the tags and digests to copy
+	Images map[string][]string `yaml:"images"`
+}
+
+// newSkopeoSync returns the skopeo sync configuration copying the images in the inventory: the locked digest
+// of each platform and, so the Helm chart references keep working, the image tag
+func newSkopeoSync(inv *imageInventory) map[string]*skopeoSyncRegistry {
+	registries := make(map[string]*skopeoSyncRegistry)
+	for _, e := range inv.Images {
+		reg, ok := registries[e.Registry]
+		if !ok {
+			reg = &skopeoSyncRegistry{Images: make(map[string][]string)}
+			registries[e.Registry] = reg
+		}
+		for _, ref := range []string{e.Tag, e.Digest} {
+			if ref != "" && !slices.Contains(reg.Images[e.Repository], ref) {
+				reg.Images[e.Repository] = append(reg.Images[e.Repository], ref)
+			}
+		}
+	}
+	return registries
+}
+
+// writeSkopeoSync writes the skopeo sync configuration of the images in the wrap, or chart, in chartPath
+func writeSkopeoSync(w io.Writer, chartPath string) error {
+	info, err := readWrapInfo(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to load Images.lock: %v", err)
+	}
+	inventory, err := newImageInventory(info)
+	if err != nil {
+		return fmt.Errorf("failed to read images: %v", err)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(newSkopeoSync(inventory)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// loadWrapImages returns the images of the given arch in the wrap, or chart with its images pulled,
+// in chartPath, keyed by their Images.lock references or, if prefix is not empty, by the references
+// they are relocated to with that prefix
+func loadWrapImages(chartPath string, arch string, prefix string) (map[name.Reference]v1.Image, error) {
+	chart, lock, err := loadWrapLock(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := wrapImageRefs(lock, prefix)
+	if err != nil {
+		return nil, err
+	}
+	platform := "linux/" + arch
+	images := make(map[name.Reference]v1.Image)
+	for i, img := range lock.Images {
+		idx := slices.IndexFunc(img.Digests, func(d imagelock.DigestInfo) bool { return d.Arch == platform })
+		if idx < 0 {
+			return nil, fmt.Errorf("image %q has no %s digest", img.Image, platform)
+		}
+		ref, err := name.ParseReference(refs[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image reference %q: %v", refs[i], err)
+		}
+		if images[ref], err = chartutils.LoadImage(chart.ImagesDir(), img.Digests[idx]); err != nil {
+			return nil, fmt.Errorf("failed to load image %q (pull the images first with \"dt images pull\"): %v", img.Image, err)
+		}
+	}
+	return images, nil
+}
+
+// loadWrapLock loads the Helm chart, uncompressing it first if it is a wrap, and its Images.lock
+func loadWrapLock(chartPath string) (*chartutils.Chart, *imagelock.ImagesLock, error) {
+	if isTar, _ := utils.IsTarFile(chartPath); isTar {
+		tmpDir, err := getGlobalTempWorkDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		if chartPath, err = wrap.Extract(context.Background(), chartPath, tmpDir); err != nil {
+			return nil, nil, fmt.Errorf("failed to uncompress wrap: %v", err)
+		}
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Helm chart: %v", err)
+	}
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Images.lock: %v", err)
+	}
+	return chart, lock, nil
+}
+
+// wrapImageRefs returns the references of the images in lock, relocated with prefix if not empty
+func wrapImageRefs(lock *imagelock.ImagesLock, prefix string) ([]string, error) {
+	refs := make([]string, 0, len(lock.Images))
+	if prefix == "" {
+		for _, img := range lock.Images {
+			refs = append(refs, img.Image)
+		}
+		return refs, nil
+	}
+	report, err := relocator.NewReport(lock, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to relocate the images: %v", err)
+	}
+	for _, m := range report.Images {
+		refs = append(refs, m.Target)
+	}
+	return refs, nil
+}
+
+// writeK3sAirgapImages writes the images of the wrap, or chart, in chartPath as a gzipped docker-archive,
+// which k3s imports from its agent/images directory on startup
+func writeK3sAirgapImages(w io.Writer, chartPath string, arch string) error {
+	images, err := loadWrapImages(chartPath, arch, "")
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(w)
+	if err := tarball.MultiRefWrite(images, gw); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// writeImagesExport writes the images of the wrap, or chart, in chartPath in the given format: skopeo-sync,
+// docker-archive, a tarball with the image of the arch platform of every image "docker load" accepts, or
+// k3s-airgap, the same tarball gzipped
+func writeImagesExport(w io.Writer, chartPath string, format string, arch string) error {
+	switch format {
+	case "skopeo-sync":
+		return writeSkopeoSync(w, chartPath)
+	case "docker-archive":
+		images, err := loadWrapImages(chartPath, arch, "")
+		if err != nil {
+			return err
+		}
+		return tarball.MultiRefWrite(images, w)
+	case "k3s-airgap":
+		return writeK3sAirgapImages(w, chartPath, arch)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func newImagesExportCmd() *cobra.Command {
+	var format = "skopeo-sync"
+	var outputFile string
+	arch := "amd64"
+
+	cmd := &cobra.Command{
+		Use:   "export CHART_PATH|WRAP",
+		Short: "Exports the container images of a Helm chart for other mirroring tools",
+		Long: `Exports the container images referenced in the Images.lock of a Helm chart or wrap in the format of other mirroring tools.
+The skopeo-sync format is a "skopeo sync --src yaml" source file copying the tag and the locked digest of each platform of every image.
+The docker-archive format is a tarball "docker load" accepts, with the image of the requested architecture of every image tagged as in the Images.lock.
+The k3s-airgap format is the same tarball gzipped, to be copied as a .tar.gz file into the /var/lib/rancher/k3s/agent/images/ directory of k3s nodes`,
+		Example: `  # Mirror the images of a Helm chart with skopeo
+  $ dt images export examples/mariadb --output-file mariadb-sync.yaml
+  $ skopeo sync --all --src yaml --dest docker mariadb-sync.yaml registry.example.com/mirror
+
+  # Load the arm64 images of a wrap into a Docker host without registry access
+  $ dt images export mariadb-12.2.8.wrap.tgz --format docker-archive --arch arm64 --output-file mariadb-images.tar
+  $ docker load -i mariadb-images.tar
+
+  # Seed the images of a wrap into a k3s edge node
+  $ dt images export mariadb-12.2.8.wrap.tgz --format k3s-airgap --output-file /var/lib/rancher/k3s/agent/images/mariadb.tar.gz`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			if !utils.FileExists(chartPath) {
+				return fmt.Errorf("Helm chart %q does not exist", chartPath)
+			}
+			w := io.Writer(os.Stdout)
+			if outputFile != "" {
+				fh, err := os.Create(outputFile)
+				if err != nil {
+					return fmt.Errorf("failed to create export file: %v", err)
+				}
+				defer fh.Close()
+				w = fh
+			}
+			if err := writeImagesExport(w, chartPath, format, arch); err != nil {
+				return fmt.Errorf("failed to export images: %v", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", format, "export format (skopeo-sync, docker-archive or k3s-airgap)")
+	cmd.Flags().StringVar(&arch, "arch", arch, "architecture of the images written with the docker-archive and k3s-airgap formats")
+	cmd.Flags().StringVar(&outputFile, "output-file", outputFile, "write the export into the given file instead of the standard output")
+	return cmd
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"gopkg.in/yaml.v3"
+)
+
+func (suite *CmdSuite) TestImagesExportCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	sb := suite.sb
+
+	imageName := "test"
+	imageTag := "mytag"
+	serverURL := "registry.example.com"
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+	))
+
+	t.Run("Exports a skopeo sync file", func(t *testing.T) {
+		exportFile := filepath.Join(sb.TempFile(), "sync.yaml")
+		require.NoError(os.MkdirAll(filepath.Dir(exportFile), 0755))
+		dt("images", "export", "--format", "skopeo-sync", "--output-file", exportFile, chartDir).AssertSuccess(t)
+
+		data, err := os.ReadFile(exportFile)
+		require.NoError(err)
+		sync := make(map[string]*skopeoSyncRegistry)
+		require.NoError(yaml.Unmarshal(data, &sync))
+		expected := []string{imageTag}
+		for _, d := range images[0].Digests {
+			expected = append(expected, d.Digest.String())
+		}
+		require.Contains(sync, serverURL)
+		assert.Equal(map[string][]string{imageName: expected}, sync[serverURL].Images)
+	})
+	t.Run("Exports a docker archive", func(t *testing.T) {
+		exportFile := filepath.Join(sb.TempFile(), "images.tar")
+		require.NoError(os.MkdirAll(filepath.Dir(exportFile), 0755))
+		dt("images", "export", "--format", "docker-archive", "--arch", "arm64", "--output-file", exportFile, chartDir).AssertSuccess(t)
+
+		tag, err := name.NewTag(fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag))
+		require.NoError(err)
+		img, err := tarball.ImageFromPath(exportFile, &tag)
+		require.NoError(err)
+		d, err := img.Digest()
+		require.NoError(err)
+		for _, dgst := range images[0].Digests {
+			if dgst.Arch == "linux/arm64" {
+				assert.Equal(dgst.Digest.String(), d.String())
+			}
+		}
+
+		dt("images", "export", "--format", "docker-archive", "--arch", "s390x", "--output-file", exportFile, chartDir).AssertErrorMatch(t, `has no linux/s390x digest`)
+	})
+	t.Run("Exports a k3s airgap images tarball", func(t *testing.T) {
+		exportFile := filepath.Join(sb.TempFile(), "images.tar.gz")
+		require.NoError(os.MkdirAll(filepath.Dir(exportFile), 0755))
+		dt("images", "export", "--format", "k3s-airgap", "--output-file", exportFile, chartDir).AssertSuccess(t)
+
+		tag, err := name.NewTag(fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag))
+		require.NoError(err)
+		img, err := tarball.Image(func() (io.ReadCloser, error) {
+			fh, err := os.Open(exportFile)
+			if err != nil {
+				return nil, err
+			}
+			return gzip.NewReader(fh)
+		}, &tag)
+		require.NoError(err)
+		d, err := img.Digest()
+		require.NoError(err)
+		for _, dgst := range images[0].Digests {
+			if dgst.Arch == "linux/amd64" {
+				assert.Equal(dgst.Digest.String(), d.String())
+			}
+		}
+	})
+	t.Run("Fails with unknown formats", func(t *testing.T) {
+		dt("images", "export", "--format", "zip", chartDir).AssertErrorMatch(t, `unsupported export format "zip"`)
+	})
+}
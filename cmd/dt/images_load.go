@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var imagesLoadCmd = newImagesLoadCmd()
+
+// podmanHost returns the address of the podman API socket: CONTAINER_HOST, if defined, or the rootless
+// socket of the user, falling back to the rootful one
+func podmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Geteuid() != 0 {
+		return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// localDaemonHost returns the address of the daemon images are read from with --local-daemon, or empty
+// to use the Docker daemon configured in the environment
+func localDaemonHost() string {
+	if usePodman {
+		return podmanHost()
+	}
+	return ""
+}
+
+// dockerLoad loads the images into the Docker daemon configured in the environment (DOCKER_HOST...) or,
+// if host is provided, into the Docker compatible daemon listening on host, such as podman
+func dockerLoad(ctx context.Context, images map[name.Reference]v1.Image, host string) error {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.MultiRefWrite(images, pw))
+	}()
+	resp, err := cli.ImageLoad(ctx, pr, true)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to load images into the daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	// The daemon reports the load errors in the response stream
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("failed to load images into the daemon: %w", err)
+	}
+	return nil
+}
+
+// containerdLoad imports the images into the containerd namespace of the daemon listening on address,
+// unpacking them, with the ctr client shipped with containerd (and k3s)
+func containerdLoad(ctx context.Context, images map[name.Reference]v1.Image, address string, namespace string, platform string) error {
+	if _, err := exec.LookPath("ctr"); err != nil {
+		return fmt.Errorf("cannot find the containerd ctr client, which must be installed to use --daemon containerd: %w", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.MultiRefWrite(images, pw))
+	}()
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ctr", "--address", address, "--namespace", namespace, "images", "import", "--platform", platform, "-")
+	cmd.Stdin = pr
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to import images into containerd: %v: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// kindLoad side-loads the images into the nodes of the kind cluster, through a temporary docker-archive
+func kindLoad(ctx context.Context, images map[name.Reference]v1.Image, cluster string) error {
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return err
+	}
+	archive, err := os.CreateTemp(tmpDir, "kind-images-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create images archive: %v", err)
+	}
+	defer os.Remove(archive.Name())
+	err = tarball.MultiRefWrite(images, archive)
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write images archive: %v", err)
+	}
+	output, err := exec.CommandContext(ctx, "kind", "load", "image-archive", archive.Name(), "--name", cluster).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to load images into kind cluster %q: %v: %s", cluster, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// loadSettings defines where the images are loaded
+type loadSettings struct {
+	// Daemon is the container runtime to load the images into (docker, podman or containerd)
+	Daemon string
+	// Arch is the architecture of the images to load
+	Arch string
+	// ContainerdAddress is the address of the containerd daemon
+	ContainerdAddress string
+	// Namespace is the containerd namespace the images are imported into
+	Namespace string
+	// KindCluster, if not empty, is the kind cluster whose nodes the images are loaded into, instead of Daemon
+	KindCluster string
+}
+
+// target returns the description of where the images are loaded
+func (s loadSettings) target() string {
+	if s.KindCluster != "" {
+		return fmt.Sprintf("kind cluster %q", s.KindCluster)
+	}
+	return s.Daemon
+}
+
+// loadImages loads the images into the container runtime daemon, or kind cluster, in settings
+func loadImages(ctx context.Context, settings loadSettings, images map[name.Reference]v1.Image) error {
+	if settings.KindCluster != "" {
+		return kindLoad(ctx, images, settings.KindCluster)
+	}
+	switch settings.Daemon {
+	case "docker":
+		return dockerLoad(ctx, images, "")
+	case "podman":
+		return dockerLoad(ctx, images, podmanHost())
+	case "containerd":
+		return containerdLoad(ctx, images, settings.ContainerdAddress, settings.Namespace, "linux/"+settings.Arch)
+	default:
+		return fmt.Errorf("unsupported daemon %q", settings.Daemon)
+	}
+}
+
+func newImagesLoadCmd() *cobra.Command {
+	settings := loadSettings{
+		Daemon: "docker", Arch: runtime.GOARCH, ContainerdAddress: "/run/containerd/containerd.sock", Namespace: "k8s.io",
+	}
+
+	cmd := &cobra.Command{
+		Use:   "load CHART_PATH|WRAP [OCI_URI]",
+		Short: "Loads the container images of a Helm chart into a local container runtime",
+		Long: `Loads the container images of a wrap, or of a Helm chart with its images pulled, into a local container runtime, without a registry.
+The image of the requested architecture of every image is loaded, tagged as in the Images.lock or, if OCI_URI is provided, as it is relocated into OCI_URI`,
+		Example: `  # Load the images of a wrap into the local Docker daemon
+  $ dt images load mariadb-12.2.8.wrap.tgz
+
+  # Load the images of a wrap tagged as they are relocated by "dt unwrap"
+  $ dt images load mariadb-12.2.8.wrap.tgz demo.goharbor.io/test_repo
+
+  # Seed the images of a wrap into the containerd of a k3s node
+  $ dt images load mariadb-12.2.8.wrap.tgz --daemon containerd --containerd-address /run/k3s/containerd/containerd.sock
+
+  # Load the images of a wrap into the podman image store, through its API socket
+  $ dt images load mariadb-12.2.8.wrap.tgz --daemon podman
+
+  # Side-load the images of a wrap into the nodes of a kind cluster
+  $ dt images load mariadb-12.2.8.wrap.tgz --kind chart-testing`,
+		Args:          cobra.RangeArgs(1, 2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			if !utils.FileExists(chartPath) {
+				return fmt.Errorf("Helm chart %q does not exist", chartPath)
+			}
+			if settings.KindCluster != "" && cmd.Flags().Changed("daemon") {
+				return fmt.Errorf("--kind cannot be used with --daemon")
+			}
+			var prefix string
+			if len(args) > 1 {
+				prefix = args[1]
+			}
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+			l := getLogger()
+
+			if err := l.Section(fmt.Sprintf("Loading images into %s", settings.target()), func(subLog log.SectionLogger) error {
+				images, err := loadWrapImages(chartPath, settings.Arch, prefix)
+				if err != nil {
+					return subLog.Failf("Failed to read images: %w", err)
+				}
+				if err := subLog.ExecuteStep(fmt.Sprintf("Loading %d images", len(images)), func() error {
+					return loadImages(ctx, settings, images)
+				}); err != nil {
+					return subLog.Failf("Failed to load images: %w", err)
+				}
+				for ref := range images {
+					subLog.Infof("Loaded %q", ref.Name())
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			l.Successf("All images loaded successfully")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&settings.Daemon, "daemon", settings.Daemon, "container runtime to load the images into (docker, podman or containerd, which requires the ctr command line tool in the PATH)")
+	cmd.Flags().StringVar(&settings.Arch, "arch", settings.Arch, "architecture of the images to load")
+	cmd.Flags().StringVar(&settings.ContainerdAddress, "containerd-address", settings.ContainerdAddress, "address of the containerd daemon, when using --daemon containerd")
+	cmd.Flags().StringVar(&settings.Namespace, "namespace", settings.Namespace, "containerd namespace to import the images into, when using --daemon containerd")
+	cmd.Flags().StringVar(&settings.KindCluster, "kind", settings.KindCluster, "load the images into the nodes of the given kind cluster instead, with the kind CLI")
+	return cmd
+}
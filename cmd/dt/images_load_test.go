@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *CmdSuite) TestImagesLoadCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	sb := suite.sb
+
+	imageName := "test"
+	imageTag := "mytag"
+	serverURL := "registry.example.com"
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+	))
+
+	t.Run("Loads the images into Docker", func(t *testing.T) {
+		d := tu.NewDockerDaemon()
+		defer d.Close()
+		t.Setenv("DOCKER_HOST", d.Host())
+
+		dt("images", "load", chartDir, "--arch", "amd64").AssertSuccessMatch(t, "All images loaded successfully")
+		assert.Equal([]string{fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag)}, d.Loaded())
+	})
+	t.Run("Loads the images with their relocated tags", func(t *testing.T) {
+		d := tu.NewDockerDaemon()
+		defer d.Close()
+		t.Setenv("DOCKER_HOST", d.Host())
+
+		dt("images", "load", chartDir, "demo.example.com/airgap", "--arch", "arm64").AssertSuccess(t)
+		assert.Equal([]string{fmt.Sprintf("demo.example.com/airgap/%s:%s", imageName, imageTag)}, d.Loaded())
+	})
+	t.Run("Loads the images into podman", func(t *testing.T) {
+		d := tu.NewDockerDaemon()
+		defer d.Close()
+		t.Setenv("CONTAINER_HOST", d.Host())
+		t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+
+		dt("images", "load", chartDir, "--daemon", "podman").AssertSuccessMatch(t, "All images loaded successfully")
+		assert.Equal([]string{fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag)}, d.Loaded())
+	})
+	t.Run("Reports the daemon errors", func(t *testing.T) {
+		d := tu.NewDockerDaemon()
+		defer d.Close()
+		d.LoadError = "no space left on device"
+		t.Setenv("DOCKER_HOST", d.Host())
+
+		dt("images", "load", chartDir, "--arch", "amd64").AssertErrorMatch(t, "no space left on device")
+	})
+	t.Run("Imports the images into containerd", func(t *testing.T) {
+		// Fake ctr CLI saving its arguments and the imported archive
+		binDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		outDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s/args\ncat > %s/images.tar\n", outDir, outDir)
+		require.NoError(os.WriteFile(filepath.Join(binDir, "ctr"), []byte(script), 0755))
+		t.Setenv("PATH", fmt.Sprintf("%s:%s", binDir, os.Getenv("PATH")))
+
+		dt("images", "load", chartDir, "--daemon", "containerd", "--arch", "arm64", "--containerd-address", "/run/k3s/containerd/containerd.sock").AssertSuccess(t)
+		args, err := os.ReadFile(filepath.Join(outDir, "args"))
+		require.NoError(err)
+		assert.Equal("--address /run/k3s/containerd/containerd.sock --namespace k8s.io images import --platform linux/arm64 -\n", string(args))
+
+		tag, err := name.NewTag(fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag))
+		require.NoError(err)
+		_, err = tarball.ImageFromPath(filepath.Join(outDir, "images.tar"), &tag)
+		require.NoError(err)
+
+		require.NoError(os.WriteFile(filepath.Join(binDir, "ctr"), []byte("#!/bin/sh\necho 'connection refused' >&2\nexit 1\n"), 0755))
+		dt("images", "load", chartDir, "--daemon", "containerd").AssertErrorMatch(t, "connection refused")
+
+		t.Setenv("PATH", sb.TempFile())
+		dt("images", "load", chartDir, "--daemon", "containerd").AssertErrorMatch(t, "cannot find the containerd ctr client")
+	})
+	t.Run("Loads the images into kind clusters", func(t *testing.T) {
+		// Fake kind CLI saving its arguments and the loaded archive
+		binDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		outDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		script := fmt.Sprintf("#!/bin/sh\necho \"$1 $2 $4 $5\" > %s/args\ncp \"$3\" %s/images.tar\n", outDir, outDir)
+		require.NoError(os.WriteFile(filepath.Join(binDir, "kind"), []byte(script), 0755))
+		t.Setenv("PATH", fmt.Sprintf("%s:%s", binDir, os.Getenv("PATH")))
+
+		dt("images", "load", chartDir, "--kind", "chart-testing").AssertSuccessMatch(t, `Loading images into kind cluster .*chart-testing`)
+		args, err := os.ReadFile(filepath.Join(outDir, "args"))
+		require.NoError(err)
+		assert.Equal("load image-archive --name chart-testing\n", string(args))
+		tag, err := name.NewTag(fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag))
+		require.NoError(err)
+		_, err = tarball.ImageFromPath(filepath.Join(outDir, "images.tar"), &tag)
+		require.NoError(err)
+
+		dt("images", "load", chartDir, "--kind", "chart-testing", "--daemon", "containerd").AssertErrorMatch(t, "--kind cannot be used with --daemon")
+	})
+	t.Run("Fails with unknown daemons", func(t *testing.T) {
+		dt("images", "load", chartDir, "--daemon", "rkt").AssertErrorMatch(t, `unsupported daemon "rkt"`)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var imagesReportCmd = newImagesReportCmd()
+
+// imageInventoryEntry describes a platform specific image of a chart
+type imageInventoryEntry struct {
+	Chart      string `json:"chart"`
+	Name       string `json:"name"`
+	Image      string `json:"image"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Arch       string `json:"arch"`
+	Digest     string `json:"digest"`
+	// Size is only known for images bundled in a wrap
+	Size int64 `json:"size,omitempty"`
+}
+
+// imageInventory lists the images of a chart, with an entry per platform
+type imageInventory struct {
+	Chart   string                `json:"chart"`
+	Version string                `json:"version"`
+	Images  []imageInventoryEntry `json:"images"`
+}
+
+func newImageInventory(info *wrapInfo) (*imageInventory, error) {
+	lock := info.Lock
+	inventory := &imageInventory{Chart: lock.Chart.Name, Version: lock.Chart.Version, Images: make([]imageInventoryEntry, 0)}
+	for _, img := range lock.Images {
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image reference %q: %w", img.Image, err)
+		}
+		tag := ""
+		if t, ok := ref.(name.Tag); ok {
+			tag = t.TagStr()
+		}
+		for _, d := range img.Digests {
+			inventory.Images = append(inventory.Images, imageInventoryEntry{
+				Chart:      img.Chart,
+				Name:       img.Name,
+				Image:      img.Image,
+				Registry:   ref.Context().RegistryStr(),
+				Repository: ref.Context().RepositoryStr(),
+				Tag:        tag,
+				Arch:       d.Arch,
+				Digest:     d.Digest.String(),
+				Size:       info.ImageSizes[d.Digest.Encoded()],
+			})
+		}
+	}
+	return inventory, nil
+}
+
+func (inv *imageInventory) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHART\tNAME\tREGISTRY\tREPOSITORY\tTAG\tARCH\tDIGEST\tSIZE")
+	for _, e := range inv.Images {
+		size := "-"
+		if e.Size > 0 {
+			size = units.HumanSize(float64(e.Size))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Chart, e.Name, e.Registry, e.Repository, e.Tag, e.Arch, e.Digest, size)
+	}
+	return tw.Flush()
+}
+
+func (inv *imageInventory) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"chart", "name", "registry", "repository", "tag", "arch", "digest", "size"})
+	for _, e := range inv.Images {
+		_ = cw.Write([]string{e.Chart, e.Name, e.Registry, e.Repository, e.Tag, e.Arch, e.Digest, strconv.FormatInt(e.Size, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write serializes the inventory in the given format (text, csv or json)
+func (inv *imageInventory) Write(w io.Writer, format string) error {
+	switch format {
+	case textOutput:
+		return inv.writeTable(w)
+	case "csv":
+		return inv.writeCSV(w)
+	case jsonOutput:
+		return writeStructuredOutput(w, format, inv)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func newImagesReportCmd() *cobra.Command {
+	var outputFormat = textOutput
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "report CHART_PATH|WRAP",
+		Short: "Reports the inventory of container images of a Helm chart",
+		Long: `Reports the container images referenced in the Images.lock of a Helm chart or wrap, with an entry per platform including its registry, repository, tag and digest.
+The size of the images is included when they are bundled in a wrap`,
+		Example: `  # Show the images inventory of a Helm chart
+  $ dt images report examples/mariadb
+
+  # Write the images inventory of a wrap as CSV
+  $ dt images report mariadb-12.2.8.wrap.tgz --output csv --output-file mariadb-images.csv`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			if !utils.FileExists(chartPath) {
+				return fmt.Errorf("Helm chart %q does not exist", chartPath)
+			}
+			info, err := readWrapInfo(chartPath)
+			if err != nil {
+				return fmt.Errorf("failed to load Images.lock: %v", err)
+			}
+			inventory, err := newImageInventory(info)
+			if err != nil {
+				return fmt.Errorf("failed to generate images report: %v", err)
+			}
+			w := io.Writer(os.Stdout)
+			if outputFile != "" {
+				fh, err := os.Create(outputFile)
+				if err != nil {
+					return fmt.Errorf("failed to create report file: %v", err)
+				}
+				defer fh.Close()
+				w = fh
+			}
+			if err := inventory.Write(w, outputFormat); err != nil {
+				return fmt.Errorf("failed to write images report: %v", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outputFormat, "output format of the report (text, csv or json)")
+	cmd.Flags().StringVar(&outputFile, "output-file", outputFile, "write the report into the given file instead of the standard output")
+	return cmd
+}
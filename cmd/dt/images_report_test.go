@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestImagesReportCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	sb := suite.sb
+
+	imageName := "test"
+	imageTag := "mytag"
+	serverURL := "registry.example.com"
+	scenarioName := "complete-chart"
+	chartName := "test"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+
+	images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+	require.NoError(err)
+
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "RepositoryURL": serverURL},
+	))
+
+	tarFile := sb.TempFile()
+	require.NoError(utils.Tar(chartDir, tarFile, utils.TarConfig{Prefix: chartName}))
+
+	for _, inputChart := range []string{tarFile, chartDir} {
+		t.Run("Text report", func(t *testing.T) {
+			res := dt("images", "report", inputChart)
+			res.AssertSuccess(t)
+			assert.Regexp(`CHART\s+NAME\s+REGISTRY\s+REPOSITORY\s+TAG\s+ARCH\s+DIGEST\s+SIZE`, res.stdout)
+			for _, digest := range images[0].Digests {
+				assert.Regexp(fmt.Sprintf(`%s\s+%s\s+%s\s+%s\s+%s\s+%s\s+%s\s+[\d.]+k?B`,
+					chartName, imageName, serverURL, imageName, imageTag, digest.Arch, digest.Digest), res.stdout)
+			}
+			assert.Equal(res.stdout, dt("images", "report", "--output", "text", inputChart).stdout)
+		})
+		t.Run("JSON report", func(t *testing.T) {
+			res := dt("images", "report", "--output", "json", inputChart)
+			res.AssertSuccess(t)
+			inventory := &imageInventory{}
+			require.NoError(json.Unmarshal([]byte(res.stdout), inventory))
+			assert.Equal(chartName, inventory.Chart)
+			require.Len(inventory.Images, len(images[0].Digests))
+			for i, digest := range images[0].Digests {
+				entry := inventory.Images[i]
+				assert.Equal(fmt.Sprintf("%s/%s:%s", serverURL, imageName, imageTag), entry.Image)
+				assert.Equal(serverURL, entry.Registry)
+				assert.Equal(imageTag, entry.Tag)
+				assert.Equal(digest.Arch, entry.Arch)
+				assert.Equal(digest.Digest.String(), entry.Digest)
+				assert.Greater(entry.Size, int64(0))
+			}
+		})
+	}
+	t.Run("CSV report written to file", func(t *testing.T) {
+		reportFile := filepath.Join(sb.TempFile(), "images.csv")
+		require.NoError(os.MkdirAll(filepath.Dir(reportFile), 0755))
+		dt("images", "report", "-o", "csv", "--output-file", reportFile, chartDir).AssertSuccess(t)
+		data, err := os.ReadFile(reportFile)
+		require.NoError(err)
+		rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+		require.NoError(err)
+		require.Len(rows, len(images[0].Digests)+1)
+		assert.Equal([]string{"chart", "name", "registry", "repository", "tag", "arch", "digest", "size"}, rows[0])
+		assert.Equal(images[0].Digests[0].Digest.String(), rows[1][6])
+	})
+	t.Run("Errors", func(t *testing.T) {
+		dt("images", "report", sb.TempFile()).AssertErrorMatch(t, `Helm chart.* does not exist`)
+		dt("images", "report", "-o", "xml", chartDir).AssertErrorMatch(t, `unsupported output format "xml"`)
+		dt("images", "report", "-o", "table", chartDir).AssertErrorMatch(t, `unsupported output format "table"`)
+	})
+}
@@ -5,115 +5,261 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
 var infoCmd = newInfoCmd()
 
-func readLockFromWrap(chartPath string) (*imagelock.ImagesLock, error) {
-	var lock *imagelock.ImagesLock
-	var err error
-	if isTar, _ := utils.IsTarFile(chartPath); isTar {
-		if err := utils.FindFileInTar(context.Background(), chartPath, "Images.lock", func(tr *tar.Reader) error {
-			lock, err = imagelock.FromYAML(tr)
-			return err
-		}, utils.TarConfig{StripComponents: 1}); err != nil {
-			return nil, err
+// wrapInfo contains the information about a wrap shown by the info command
+type wrapInfo struct {
+	Lock *imagelock.ImagesLock
+	// Metadata is nil for wraps without a wrap.json file
+	Metadata *metadata.Metadata
+	// ImageSizes maps the digests of the bundled images to the size of their tarballs
+	ImageSizes map[string]int64
+}
+
+// imageSize returns the total size of the bundled tarballs of img
+func (info *wrapInfo) imageSize(img *imagelock.ChartImage) int64 {
+	var size int64
+	for _, d := range img.Digests {
+		size += info.ImageSizes[d.Digest.Encoded()]
+	}
+	return size
+}
+
+// readWrapInfoFromTar reads the wrap information by streaming its tarball, without extracting it
+func readWrapInfoFromTar(wrapFile string) (*wrapInfo, error) {
+	info := &wrapInfo{ImageSizes: make(map[string]int64)}
+	if err := utils.WalkTarFile(context.Background(), wrapFile, func(tr *tar.Reader, header *tar.Header) error {
+		var err error
+		rel := strings.SplitN(strings.TrimPrefix(path.Clean(header.Name), "/"), "/", 2)
+		if len(rel) < 2 {
+			return nil
 		}
-		if lock == nil {
-			return nil, fmt.Errorf("Images.lock not found in wrap")
+		switch name := rel[1]; {
+		case name == imagelock.DefaultImagesLockFileName:
+			info.Lock, err = imagelock.FromYAML(tr)
+		case name == metadata.FileName:
+			info.Metadata, err = metadata.Read(tr)
+		case path.Dir(name) == "images" && path.Ext(name) == ".tar":
+			info.ImageSizes[strings.TrimSuffix(path.Base(name), ".tar")] = header.Size
 		}
-		return lock, nil
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if info.Lock == nil {
+		return nil, fmt.Errorf("Images.lock not found in wrap")
 	}
+	return info, nil
+}
 
-	f, err := getImageLockFilePath(chartPath)
+// readWrapInfoFromDir reads the wrap information from an uncompressed wrap
+func readWrapInfoFromDir(chartPath string) (*wrapInfo, error) {
+	info := &wrapInfo{ImageSizes: make(map[string]int64)}
+	f, err := wrap.LockFile(chartPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find Images.lock: %v", err)
 	}
 	if !utils.FileExists(f) {
 		return nil, fmt.Errorf("Images.lock file does not exist")
 	}
-	return imagelock.FromYAMLFile(f)
+	if info.Lock, err = imagelock.FromYAMLFile(f); err != nil {
+		return nil, err
+	}
+	chartRoot := filepath.Dir(f)
+	if metadataFile := filepath.Join(chartRoot, metadata.FileName); utils.FileExists(metadataFile) {
+		if info.Metadata, err = metadata.FromFile(metadataFile); err != nil {
+			return nil, err
+		}
+	}
+	imgFiles, _ := filepath.Glob(filepath.Join(chartRoot, "images", "*.tar"))
+	for _, imgFile := range imgFiles {
+		if fi, err := os.Stat(imgFile); err == nil {
+			info.ImageSizes[strings.TrimSuffix(filepath.Base(imgFile), ".tar")] = fi.Size()
+		}
+	}
+	return info, nil
+}
+
+func readWrapInfo(chartPath string) (*wrapInfo, error) {
+	if isTar, _ := utils.IsTarFile(chartPath); isTar {
+		return readWrapInfoFromTar(chartPath)
+	}
+	return readWrapInfoFromDir(chartPath)
+}
+
+// showImagesInfo prints the bundled images, with their sizes if they are included in the wrap
+func showImagesInfo(info *wrapInfo, showDetails bool, l log.SectionLogger) {
+	for _, img := range info.Lock.Images {
+		size := ""
+		if imgSize := info.imageSize(img); imgSize > 0 {
+			size = fmt.Sprintf(" %s", units.HumanSize(float64(imgSize)))
+		}
+		if !showDetails {
+			platforms := make([]string, 0)
+			for _, digest := range img.Digests {
+				platforms = append(platforms, digest.Arch)
+			}
+			l.Printf("%s (%s)%s", img.Image, strings.Join(platforms, ", "), size)
+			continue
+		}
+		_ = l.Section(fmt.Sprintf("%s/%s", img.Chart, img.Name), func(l log.SectionLogger) error {
+			l.Printf("Image: %s", img.Image)
+			l.Printf("Digests")
+			for _, digest := range img.Digests {
+				l.Printf("- Arch: %s", digest.Arch)
+				l.Printf("  Digest: %s", digest.Digest)
+				if digestSize, ok := info.ImageSizes[digest.Digest.Encoded()]; ok {
+					l.Printf("  Size: %s", units.HumanSize(float64(digestSize)))
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// showWrapMetadata prints the wrap.json information
+func showWrapMetadata(m *metadata.Metadata, l log.SectionLogger) {
+	l.Printf("Created: %s", m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"))
+	l.Printf("Tool Version: %s", m.ToolVersion)
+	l.Printf("Source: %s", m.Chart.Reference)
+	if m.Chart.RequestedVersion != "" {
+		l.Printf("Requested Version: %s", m.Chart.RequestedVersion)
+	}
+	if m.Chart.Digest != "" {
+		l.Printf("Source Digest: %s", m.Chart.Digest)
+	}
+	if len(m.Platforms) > 0 {
+		l.Printf("Platforms: %s", strings.Join(m.Platforms, ", "))
+	}
+}
+
+// wrapInfoOutput is the machine-readable representation of the info command results
+type wrapInfoOutput struct {
+	Chart      string            `json:"chart"`
+	Version    string            `json:"version"`
+	AppVersion string            `json:"appVersion"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	// Wrap contains the wrap.json metadata, if the wrap includes it
+	Wrap   *metadata.Metadata `json:"wrap,omitempty"`
+	Images []metadata.Image   `json:"images"`
+	// TotalImagesSize is the size of all the bundled image tarballs
+	TotalImagesSize int64 `json:"totalImagesSize,omitempty"`
+}
+
+// totalImagesSize returns the size of all the bundled image tarballs
+func (info *wrapInfo) totalImagesSize() int64 {
+	var total int64
+	for _, size := range info.ImageSizes {
+		total += size
+	}
+	return total
+}
+
+func (info *wrapInfo) toOutput() *wrapInfoOutput {
+	lock := info.Lock
+	out := &wrapInfoOutput{
+		Chart: lock.Chart.Name, Version: lock.Chart.Version, AppVersion: lock.Chart.AppVersion,
+		Metadata: lock.Metadata, Wrap: info.Metadata, Images: make([]metadata.Image, 0),
+		TotalImagesSize: info.totalImagesSize(),
+	}
+	for _, img := range lock.Images {
+		outImg := metadata.Image{Name: img.Name, Image: img.Image, Chart: img.Chart, Digests: make([]metadata.ImageDigest, 0)}
+		for _, d := range img.Digests {
+			outImg.Digests = append(outImg.Digests, metadata.ImageDigest{Arch: d.Arch, Digest: d.Digest, Size: info.ImageSizes[d.Digest.Encoded()]})
+		}
+		out.Images = append(out.Images, outImg)
+	}
+	return out
+}
+
+// showWrapInfo prints the human-readable wrap information
+func showWrapInfo(info *wrapInfo, showDetails bool, l log.SectionLogger) {
+	lock := info.Lock
+	_ = l.Section("Wrap Information", func(l log.SectionLogger) error {
+		l.Printf("Chart: %s", lock.Chart.Name)
+		l.Printf("Version: %s", lock.Chart.Version)
+		l.Printf("App Version: %s", lock.Chart.AppVersion)
+		_ = l.Section("Metadata", func(l log.SectionLogger) error {
+			for k, v := range lock.Metadata {
+				l.Printf("- %s: %s", k, v)
+			}
+			return nil
+		})
+		if info.Metadata != nil {
+			_ = l.Section("Wrap Metadata", func(l log.SectionLogger) error {
+				showWrapMetadata(info.Metadata, l)
+				return nil
+			})
+		}
+		_ = l.Section("Images", func(l log.SectionLogger) error {
+			showImagesInfo(info, showDetails, l)
+			return nil
+		})
+		if len(info.ImageSizes) > 0 {
+			l.Printf("Total images size: %s", units.HumanSize(float64(info.totalImagesSize())))
+		}
+		return nil
+	})
 }
 
 func newInfoCmd() *cobra.Command {
 	var yamlFormat bool
 	var showDetails bool
+	var outputFormat = textOutput
 
 	cmd := &cobra.Command{
 		Use:   "info FILE",
 		Short: "shows info of a wrapped chart",
-		Long:  `Shows information of a wrapped Helm chart, including the bundled images and chart metadata`,
+		Long:  `Shows information of a wrapped Helm chart, including the bundled images and chart metadata. Wrap tarballs are read without extracting them`,
 		Example: `  # Show information of a wrapped Helm chart
-  $ dt info mariadb-12.2.8.wrap.tgz`,
+  $ dt info mariadb-12.2.8.wrap.tgz
+
+  # Show information of a wrapped Helm chart in JSON format
+  $ dt info mariadb-12.2.8.wrap.tgz --output json`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		Args:          cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			chartPath := args[0]
-			l := getLogger()
-			_, _ = chartPath, l
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
 			if !utils.FileExists(chartPath) {
 				return fmt.Errorf("wrap file %q does not exist", chartPath)
 			}
-			lock, err := readLockFromWrap(chartPath)
+			info, err := readWrapInfo(chartPath)
 			if err != nil {
 				return fmt.Errorf("failed to load Images.lock: %v", err)
 			}
-			if yamlFormat {
-				if err := lock.ToYAML(os.Stdout); err != nil {
+			switch {
+			case yamlFormat:
+				if err := info.Lock.ToYAML(os.Stdout); err != nil {
 					return fmt.Errorf("failed to write Images.lock yaml representation: %v", err)
 				}
-			} else {
-
-				_ = l.Section("Wrap Information", func(l log.SectionLogger) error {
-					l.Printf("Chart: %s", lock.Chart.Name)
-					l.Printf("Version: %s", lock.Chart.Version)
-					l.Printf("App Version: %s", lock.Chart.AppVersion)
-					_ = l.Section("Metadata", func(l log.SectionLogger) error {
-						for k, v := range lock.Metadata {
-							l.Printf("- %s: %s", k, v)
-
-						}
-						return nil
-					})
-					_ = l.Section("Images", func(l log.SectionLogger) error {
-						for _, img := range lock.Images {
-							if showDetails {
-								_ = l.Section(fmt.Sprintf("%s/%s", img.Chart, img.Name), func(l log.SectionLogger) error {
-									l.Printf("Image: %s", img.Image)
-									if showDetails {
-										l.Printf("Digests")
-										for _, digest := range img.Digests {
-											l.Printf("- Arch: %s", digest.Arch)
-											l.Printf("  Digest: %s", digest.Digest)
-										}
-									}
-									return nil
-								})
-							} else {
-								platforms := make([]string, 0)
-								for _, digest := range img.Digests {
-									platforms = append(platforms, digest.Arch)
-								}
-								l.Printf("%s (%s)", img.Image, strings.Join(platforms, ", "))
-							}
-						}
-						return nil
-					})
-					return nil
-				})
+			case isStructuredOutput(outputFormat):
+				return writeStructuredOutput(os.Stdout, outputFormat, info.toOutput())
+			default:
+				showWrapInfo(info, showDetails, getLogger())
 			}
 			return nil
 		},
 	}
-	cmd.PersistentFlags().BoolVar(&yamlFormat, "yaml", yamlFormat, "Show report in YAML format")
+	cmd.PersistentFlags().BoolVar(&yamlFormat, "yaml", yamlFormat, "Show the wrap Images.lock in YAML format")
 	cmd.PersistentFlags().BoolVar(&showDetails, "detailed", showDetails, "When using the printable report, add more details about the bundled images")
+	addOutputFlag(cmd, &outputFormat)
 
 	return cmd
 }
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"gopkg.in/yaml.v3"
 )
 
 func (suite *CmdSuite) TestInfoCommand() {
@@ -39,6 +45,14 @@ func (suite *CmdSuite) TestInfoCommand() {
 			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "AppVersion": appVersion, "RepositoryURL": serverURL},
 		))
 
+		wrapMetadata, err := metadata.New(&metadata.Input{
+			ChartRef: "oci://example.com/charts/test", Platforms: []string{"linux/amd64", "linux/arm64"}, ToolVersion: "1.2.3", CreatedAt: time.Now(),
+		})
+		require.NoError(err)
+		buff := &bytes.Buffer{}
+		require.NoError(wrapMetadata.Write(buff))
+		require.NoError(os.WriteFile(filepath.Join(chartDir, metadata.FileName), buff.Bytes(), 0644))
+
 		tarFile := sb.TempFile()
 		if err := utils.Tar(chartDir, tarFile, utils.TarConfig{
 			Prefix: chartName,
@@ -46,6 +60,17 @@ func (suite *CmdSuite) TestInfoCommand() {
 			require.NoError(err)
 		}
 		for _, inputChart := range []string{tarFile, chartDir} {
+			t.Run("Wrap metadata and sizes", func(t *testing.T) {
+				res := dt("info", inputChart)
+				res.AssertSuccess(t)
+				assert.Regexp(`(?s)Wrap Metadata.*Tool Version: 1.2.3.*Source: oci://example.com/charts/test.*Platforms: linux/amd64, linux/arm64`, res.stdout)
+				assert.Regexp(`\(linux/amd64, linux/arm64\) [\d.]+k?B`, res.stdout)
+				assert.Regexp(`Total images size: [\d.]+k?B`, res.stdout)
+
+				res = dt("info", "--detailed", inputChart)
+				res.AssertSuccess(t)
+				assert.Regexp(`Size: [\d.]+k?B`, res.stdout)
+			})
 			t.Run("Short info", func(t *testing.T) {
 				var archList []string
 				for _, digest := range images[0].Digests {
@@ -70,6 +95,59 @@ func (suite *CmdSuite) TestInfoCommand() {
 				}
 				assert.Regexp(fmt.Sprintf(`(?s).*Wrap Information.*Chart:.*%s\s*.*Version:.*%s.*Metadata.*Images.*%s`, chartName, version, imgDetailedInfo), res.stdout)
 			})
+			t.Run("Log format depends on the terminal", func(t *testing.T) {
+				// Tests do not run in a terminal, so the plain log is written to stderr unless explicitly selected
+				res := execCommand("info", inputChart)
+				res.AssertSuccess(t)
+				assert.Regexp(`level=info msg="Chart: test"`, res.stderr)
+				assert.Empty(res.stdout)
+
+				res = dt("info", "--plain=false", inputChart)
+				res.AssertSuccess(t)
+				assert.NotContains(res.stdout, "level=info")
+				assert.Regexp(`Wrap Information`, res.stdout)
+			})
+			t.Run("Disables colors", func(t *testing.T) {
+				res := dt("info", "--plain=false", inputChart)
+				res.AssertSuccess(t)
+				assert.Contains(res.stdout, "\x1b[")
+
+				res = dt("info", "--plain=false", "--no-color", inputChart)
+				res.AssertSuccess(t)
+				assert.NotContains(res.stdout, "\x1b[")
+
+				t.Setenv("NO_COLOR", "1")
+				res = dt("info", "--plain=false", inputChart)
+				res.AssertSuccess(t)
+				assert.NotContains(res.stdout, "\x1b[")
+			})
+			t.Run("Structured output", func(t *testing.T) {
+				res := dt("info", "--output", "json", inputChart)
+				res.AssertSuccess(t)
+				out := &wrapInfoOutput{}
+				require.NoError(json.Unmarshal([]byte(res.stdout), out))
+				assert.Equal(chartName, out.Chart)
+				assert.Equal(version, out.Version)
+				assert.Equal(appVersion, out.AppVersion)
+				require.NotNil(out.Wrap)
+				assert.Equal("1.2.3", out.Wrap.ToolVersion)
+				require.Len(out.Images, 1)
+				require.Len(out.Images[0].Digests, len(images[0].Digests))
+				for i, digest := range images[0].Digests {
+					assert.Equal(digest.Digest, out.Images[0].Digests[i].Digest)
+					assert.Greater(out.Images[0].Digests[i].Size, int64(0))
+				}
+				assert.Greater(out.TotalImagesSize, int64(0))
+
+				res = dt("info", "-o", "yaml", inputChart)
+				res.AssertSuccess(t)
+				yamlOut := make(map[string]interface{})
+				require.NoError(yaml.Unmarshal([]byte(res.stdout), &yamlOut))
+				assert.Equal(chartName, yamlOut["chart"])
+				assert.Equal(appVersion, yamlOut["appVersion"])
+
+				dt("info", "-o", "xml", inputChart).AssertErrorMatch(t, `unsupported output format "xml"`)
+			})
 			t.Run("YAML format", func(t *testing.T) {
 				res := dt("info", "--yaml", inputChart)
 				res.AssertSuccess(t)
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var listImagesCmd = newListImagesCmd()
+
+// listedImage is the machine-readable representation of an image annotated in a chart
+type listedImage struct {
+	Chart string `json:"chart"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// listChartImages returns the images annotated in the chart and its dependencies
+func listChartImages(chartPath string) (imagelock.ImageList, error) {
+	c, err := chartutils.LoadChart(chartPath, chartutils.WithAnnotationsKey(getAnnotationsKey()))
+	if err != nil {
+		return nil, err
+	}
+	images := make(imagelock.ImageList, 0)
+	charts := []*chartutils.Chart{c}
+	for len(charts) > 0 {
+		current := charts[0]
+		chartImages, err := current.GetAnnotatedImages()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Helm chart %q images: %v", current.Name(), err)
+		}
+		images = append(images, chartImages...)
+		charts = append(charts[1:], current.Dependencies()...)
+	}
+	return images.Dedup(), nil
+}
+
+func newListImagesCmd() *cobra.Command {
+	var outputFormat = textOutput
+
+	cmd := &cobra.Command{
+		Use:   "list-images CHART_PATH",
+		Short: "Lists the container images of a Helm chart",
+		Long:  "Lists the container images annotated in a Helm chart and its dependencies, without accessing the registries",
+		Example: `  # List the images of a Helm chart
+  $ dt charts list-images examples/mariadb
+
+  # List the images of a Helm chart in JSON format
+  $ dt charts list-images examples/mariadb --output json`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			if !utils.FileExists(chartPath) {
+				return fmt.Errorf("Helm chart %q does not exist", chartPath)
+			}
+			images, err := listChartImages(chartPath)
+			if err != nil {
+				return fmt.Errorf("failed to list images: %v", err)
+			}
+			if isStructuredOutput(outputFormat) {
+				res := make([]listedImage, 0)
+				for _, img := range images {
+					res = append(res, listedImage{Chart: img.Chart, Name: img.Name, Image: img.Image})
+				}
+				return writeStructuredOutput(os.Stdout, outputFormat, res)
+			}
+			_ = getLogger().Section("Images", func(l log.SectionLogger) error {
+				for _, img := range images {
+					l.Printf("%s/%s: %s", img.Chart, img.Name, img.Image)
+				}
+				return nil
+			})
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &outputFormat)
+	return cmd
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"gopkg.in/yaml.v3"
+)
+
+func (suite *CmdSuite) TestListImagesCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+
+	sb := suite.sb
+	serverURL := "example.com"
+	scenarioName := "chart1"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	dest := sb.TempFile()
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL},
+	))
+	chartDir := filepath.Join(dest, scenarioName)
+
+	expectedImages := []listedImage{
+		{Chart: "wordpress", Name: "wordpress", Image: serverURL + "/bitnami/wordpress:6.2.2-debian-11-r11"},
+		{Chart: "wordpress", Name: "bitnami-shell", Image: serverURL + "/bitnami/bitnami-shell:11-debian-11-r124"},
+		{Chart: "wordpress", Name: "apache-exporter", Image: serverURL + "/bitnami/apache-exporter:0.13.4-debian-11-r2"},
+		{Chart: "mariadb", Name: "mysqld-exporter", Image: serverURL + "/bitnami/mysqld-exporter:0.14.0-debian-11-r125"},
+		{Chart: "mariadb", Name: "bitnami-shell", Image: serverURL + "/bitnami/bitnami-shell:11-debian-11-r123"},
+		{Chart: "mariadb", Name: "mariadb", Image: serverURL + "/bitnami/mariadb:10.11.4-debian-11-r0"},
+	}
+
+	t.Run("Lists images", func(t *testing.T) {
+		res := dt("charts", "list-images", chartDir)
+		res.AssertSuccess(t)
+		for _, img := range expectedImages {
+			assert.Contains(res.stdout, fmt.Sprintf("%s/%s: %s", img.Chart, img.Name, img.Image))
+		}
+	})
+	t.Run("Lists images in JSON format", func(t *testing.T) {
+		res := dt("charts", "list-images", "--output", "json", chartDir)
+		res.AssertSuccess(t)
+		var images []listedImage
+		require.NoError(json.Unmarshal([]byte(res.stdout), &images))
+		assert.ElementsMatch(expectedImages, images)
+	})
+	t.Run("Lists images in YAML format", func(t *testing.T) {
+		res := dt("charts", "list-images", "-o", "yaml", chartDir)
+		res.AssertSuccess(t)
+		var images []listedImage
+		require.NoError(yaml.Unmarshal([]byte(res.stdout), &images))
+		assert.ElementsMatch(expectedImages, images)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		dt("charts", "list-images", sb.TempFile()).AssertErrorMatch(t, `Helm chart.* does not exist`)
+		dt("charts", "list-images", "-o", "xml", chartDir).AssertErrorMatch(t, `unsupported output format "xml"`)
+	})
+}
@@ -1,56 +1,160 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
 var lockCmd = newLockCommand()
 
-func createImagesLock(chartPath string, outputFile string, l log.Logger, opts ...imagelock.Option) error {
-	l.Infof("Generating images lock for Helm chart %q", chartPath)
-
-	allOpts := append([]imagelock.Option{
+// imagesLockOptions returns the options used to lock the images, followed by opts
+func imagesLockOptions(opts ...imagelock.Option) []imagelock.Option {
+	return append([]imagelock.Option{
 		imagelock.WithAnnotationsKey(getAnnotationsKey()),
 		imagelock.WithInsecure(insecure),
+		imagelock.WithKeychain(getKeychain()),
+		imagelock.WithTransport(getTransport()),
+		imagelock.WithMirrors(getMirrors()),
+		imagelock.WithRegistryFilter(getRegistryFilter()),
+		imagelock.WithLocalDaemon(useLocalDaemon),
+		imagelock.WithDaemonHost(localDaemonHost()),
+		imagelock.WithUntaggedPolicy(getUntaggedPolicy()),
 	}, opts...)
+}
 
-	lock, err := imagelock.GenerateFromChart(chartPath, allOpts...)
+func createImagesLock(chartPath string, outputFile string, l log.Logger, opts ...imagelock.Option) error {
+	l.Infof("Generating images lock for Helm chart %q", chartPath)
 
+	lock, err := wrap.CreateLock(chartPath, outputFile, imagesLockOptions(opts...)...)
 	if err != nil {
-		return fmt.Errorf("failed to load Helm chart: %v", err)
+		return err
 	}
 
 	if len(lock.Images) == 0 {
 		l.Warnf("Did not find any image annotations at Helm chart %q", chartPath)
 	}
 
-	buff := &bytes.Buffer{}
-	if err = lock.ToYAML(buff); err != nil {
-		return fmt.Errorf("failed to write Images.lock file: %v", err)
+	l.Infof("Images.lock file written to %q", outputFile)
+	return nil
+}
+
+// createReleaseImagesLock writes into outputFile the Images.lock of the images in use by the Helm release
+// deployed in the cluster, found in its rendered manifests and hooks
+func createReleaseImagesLock(releaseName string, relCfg utils.ReleaseConfig, outputFile string, opts ...imagelock.Option) error {
+	rel, err := utils.GetRelease(releaseName, relCfg)
+	if err != nil {
+		return err
+	}
+	manifests := []string{rel.Manifest}
+	for _, hook := range rel.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+	images, err := imagelock.GetImagesFromManifest(strings.Join(manifests, "\n---\n"))
+	if err != nil {
+		return fmt.Errorf("failed to read Helm release %q manifest: %w", releaseName, err)
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("did not find any image in Helm release %q", releaseName)
+	}
+	lock, err := imagelock.GenerateFromImages(images, imagesLockOptions(opts...)...)
+	if err != nil {
+		return err
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		lock.Chart.Name = rel.Chart.Metadata.Name
+		lock.Chart.Version = rel.Chart.Metadata.Version
+		lock.Chart.AppVersion = rel.Chart.Metadata.AppVersion
+		for _, img := range lock.Images {
+			img.Chart = rel.Chart.Metadata.Name
+		}
+	}
+	return wrap.WriteLock(lock, outputFile)
+}
+
+// pinMutableImages rewrites the chart annotations so images using mutable tags are pinned to their current digests
+func pinMutableImages(chartPath string, l log.Logger) error {
+	pinned, err := chartutils.PinMutableImages(chartPath,
+		imagelock.WithAnnotationsKey(getAnnotationsKey()),
+		imagelock.WithInsecure(insecure),
+		imagelock.WithKeychain(getKeychain()),
+		imagelock.WithTransport(getTransport()),
+		imagelock.WithMirrors(getMirrors()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to pin images: %w", err)
+	}
+	for _, img := range pinned {
+		l.Infof("Pinned image %q of Helm chart %q to %q", img.Name, img.Chart, img.Image)
 	}
+	return nil
+}
 
-	if err := os.WriteFile(outputFile, buff.Bytes(), 0666); err != nil {
-		return fmt.Errorf("failed to write lock to %q: %w", outputFile, err)
+// handleUntaggedImages applies the untagged images policy to the images annotated in the chart before locking
+// them: they are pinned to their current digests with UntaggedResolveDigest and reported with UntaggedAssumeLatest.
+// UntaggedError rejects them when locking
+func handleUntaggedImages(chartPath string, l log.Logger) error {
+	switch getUntaggedPolicy() {
+	case imagelock.UntaggedResolveDigest:
+		pinned, err := chartutils.PinUntaggedImages(chartPath,
+			imagelock.WithAnnotationsKey(getAnnotationsKey()),
+			imagelock.WithInsecure(insecure),
+			imagelock.WithKeychain(getKeychain()),
+			imagelock.WithTransport(getTransport()),
+			imagelock.WithMirrors(getMirrors()),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to pin untagged images: %w", err)
+		}
+		for _, img := range pinned {
+			l.Infof("Pinned untagged image %q of Helm chart %q to %q", img.Name, img.Chart, img.Image)
+		}
+	case imagelock.UntaggedAssumeLatest:
+		untagged, err := chartutils.UntaggedImages(chartPath, chartutils.WithAnnotationsKey(getAnnotationsKey()))
+		if err != nil {
+			return err
+		}
+		for _, img := range untagged {
+			l.Warnf("Image %q of Helm chart %q does not specify a tag, assuming %q", img.Image, img.Chart, "latest")
+		}
 	}
+	return nil
+}
 
-	l.Infof("Images.lock file written to %q", outputFile)
+// lockRelease writes into outputFile, or the Images.lock in the working directory, the Images.lock of the
+// Helm release deployed in the cluster
+func lockRelease(releaseName string, relCfg utils.ReleaseConfig, outputFile string, platforms []string) error {
+	l := getLogger()
+	if outputFile == "" {
+		outputFile = imagelock.DefaultImagesLockFileName
+	}
+	if err := l.ExecuteStep(fmt.Sprintf("Generating Images.lock from Helm release %q...", releaseName), func() error {
+		return createReleaseImagesLock(releaseName, relCfg, outputFile, imagelock.WithPlatforms(platforms))
+	}); err != nil {
+		return l.Failf("Failed to generate lock: %w", err)
+	}
+	l.Successf("Images.lock file written to %q", outputFile)
 	return nil
 }
 
 func newLockCommand() *cobra.Command {
 	var platforms []string
 	var outputFile string
+	var rejectMutableTags bool
+	var pinMutableTags bool
+	var fromRelease string
+	var relCfg utils.ReleaseConfig
 	getOutputFilename := func(chartPath string) (string, error) {
 		if outputFile != "" {
 			return outputFile, nil
 		}
-		return getImageLockFilePath(chartPath)
+		return wrap.LockFile(chartPath)
 	}
 
 	cmd := &cobra.Command{
@@ -61,11 +165,28 @@ func newLockCommand() *cobra.Command {
   $ dt images lock examples/mariadb
   
   # Create the Images.lock from a Helm chart that uses a different annotation for specifying images
-  $ dt images lock examples/mariadb --annotations-key artifacthub.io/images`,
+  $ dt images lock examples/mariadb --annotations-key artifacthub.io/images
+
+  # Create the Images.lock reading the images from the first of several annotations present in the Helm chart
+  $ dt images lock examples/mariadb --annotations-key bitnami.com/images,images
+
+  # Create the Images.lock pinning the images using the "latest" tag to their current digests
+  $ dt images lock examples/mariadb --pin-mutable-tags
+
+  # Create the Images.lock of the images in use by a Helm release deployed in the cluster
+  $ dt images lock --from-release mariadb -n databases --output-file Images.lock`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		Args:          cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if fromRelease != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if fromRelease != "" {
+				return lockRelease(fromRelease, relCfg, outputFile, platforms)
+			}
 			l := getLogger()
 
 			chartPath := args[0]
@@ -74,8 +195,23 @@ func newLockCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to obtain Images.lock location: %w", err)
 			}
+			if pinMutableTags {
+				if err := l.ExecuteStep("Pinning images using mutable tags...", func() error {
+					return pinMutableImages(chartPath, l)
+				}); err != nil {
+					return l.Failf("Failed to pin images: %w", err)
+				}
+			}
+			if err := l.ExecuteStep("Checking untagged images...", func() error {
+				return handleUntaggedImages(chartPath, l)
+			}); err != nil {
+				return l.Failf("Failed to handle untagged images: %w", err)
+			}
 			if err := l.ExecuteStep("Generating Images.lock from annotations...", func() error {
-				return createImagesLock(chartPath, outputFile, log.SilentLog, imagelock.WithPlatforms(platforms))
+				return createImagesLock(chartPath, outputFile, log.SilentLog,
+					imagelock.WithPlatforms(platforms),
+					imagelock.WithRejectMutableTags(rejectMutableTags),
+				)
 			}); err != nil {
 				return l.Failf("Failed to genereate lock: %w", err)
 			}
@@ -85,6 +221,12 @@ func newLockCommand() *cobra.Command {
 	}
 	cmd.PersistentFlags().StringVar(&outputFile, "output-file", outputFile, "output file where to write the Images Lock. If empty, writes to stdout")
 	cmd.PersistentFlags().StringSliceVar(&platforms, "platforms", platforms, "platforms to include in the Images.lock file")
+	cmd.PersistentFlags().BoolVar(&rejectMutableTags, "reject-mutable-tags", rejectMutableTags, "fail if any image uses the \"latest\" tag or no tag at all")
+	cmd.PersistentFlags().BoolVar(&pinMutableTags, "pin-mutable-tags", pinMutableTags, "rewrite the annotations of images using mutable tags to pin them to their current digests")
+	cmd.PersistentFlags().StringVar(&fromRelease, "from-release", fromRelease, "lock the images in use by the given Helm release deployed in the cluster, instead of the ones annotated in a Helm chart")
+	cmd.PersistentFlags().StringVarP(&relCfg.Namespace, "namespace", "n", relCfg.Namespace, "namespace of the --from-release Helm release (defaults to HELM_NAMESPACE, or the one of the kubeconfig context)")
+	cmd.PersistentFlags().StringVar(&relCfg.KubeConfig, "kubeconfig", relCfg.KubeConfig, "kubeconfig file used to access the cluster of the --from-release Helm release (defaults to KUBECONFIG)")
+	cmd.PersistentFlags().StringVar(&relCfg.KubeContext, "kube-context", relCfg.KubeContext, "kubeconfig context used to access the cluster of the --from-release Helm release (defaults to HELM_KUBECONTEXT)")
 
 	return cmd
 }
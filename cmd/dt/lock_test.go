@@ -2,12 +2,23 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 func (suite *CmdSuite) TestLockCommand() {
@@ -61,7 +72,121 @@ func (suite *CmdSuite) TestLockCommand() {
 		require.Equal(expectedLock, newLock)
 
 	})
+	t.Run("Handles images using mutable tags", func(t *testing.T) {
+		silentLog := log.New(io.Discard, "", 0)
+		s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+		defer s.Close()
+		u, err := url.Parse(s.URL)
+		require.NoError(err)
+
+		image := fmt.Sprintf("%s/bitnami/app1:latest", u.Host)
+		img, err := tu.CreateSingleArchImage(&tu.ImageData{Name: "app1", Image: image}, "linux/amd64")
+		require.NoError(err)
+		require.NoError(crane.Push(img, image, crane.Insecure))
+		dgst, err := img.Digest()
+		require.NoError(err)
+
+		renderChart := func() string {
+			dest := sb.TempFile()
+			require.NoError(tu.RenderScenario(scenarioDir, dest,
+				map[string]interface{}{"Images": []*tu.ImageData{{Name: "app1", Image: image}}, "Name": chartName},
+			))
+			return filepath.Join(dest, scenarioName)
+		}
+		t.Run("Rejects them if requested", func(t *testing.T) {
+			dt("images", "lock", "--insecure", "--reject-mutable-tags", renderChart()).AssertErrorMatch(t, "found images using mutable tags: "+image)
+		})
+		t.Run("Pins them if requested", func(t *testing.T) {
+			chartDir := renderChart()
+			dt("images", "lock", "--insecure", "--reject-mutable-tags", "--pin-mutable-tags", chartDir).AssertSuccess(t)
+
+			chart, err := chartutils.LoadChart(chartDir)
+			require.NoError(err)
+			images, err := chart.GetAnnotatedImages()
+			require.NoError(err)
+			require.Len(images, 1)
+			require.Equal(fmt.Sprintf("%s@%s", image, dgst), images[0].Image)
+		})
+		t.Run("Applies the untagged images policy", func(t *testing.T) {
+			untagged := strings.TrimSuffix(image, ":latest")
+			renderUntaggedChart := func() string {
+				dest := sb.TempFile()
+				require.NoError(tu.RenderScenario(scenarioDir, dest,
+					map[string]interface{}{"Images": []*tu.ImageData{{Name: "app1", Image: untagged}}, "Name": chartName},
+				))
+				return filepath.Join(dest, scenarioName)
+			}
+			dt("images", "lock", "--insecure", renderUntaggedChart()).
+				AssertSuccessMatch(t, fmt.Sprintf(`Image .*%s.* of Helm chart .*%s.* does not specify a tag, assuming .*latest`, untagged, chartName))
+			dt("images", "lock", "--insecure", "--untagged-images", "error", renderUntaggedChart()).
+				AssertErrorMatch(t, "found images without a tag or digest: "+untagged)
+			dt("images", "lock", "--insecure", "--untagged-images", "guess", renderUntaggedChart()).
+				AssertErrorMatch(t, `unknown untagged images policy "guess"`)
+
+			chartDir := renderUntaggedChart()
+			dt("images", "lock", "--insecure", "--untagged-images", "resolve-digest", chartDir).AssertSuccess(t)
+			chart, err := chartutils.LoadChart(chartDir)
+			require.NoError(err)
+			images, err := chart.GetAnnotatedImages()
+			require.NoError(err)
+			require.Len(images, 1)
+			require.Equal(fmt.Sprintf("%s@%s", untagged, dgst), images[0].Image)
+		})
+	})
+	t.Run("Generate lock file from a deployed release", func(t *testing.T) {
+		cluster := tu.NewKubeCluster()
+		defer cluster.Close()
+		kubeconfig := sb.TempFile()
+		require.NoError(cluster.WriteKubeconfig(kubeconfig, "default"))
+
+		manifest := ""
+		for _, img := range images {
+			manifest += fmt.Sprintf("---\nkind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n        - name: %s\n          image: %s/%s\n", img.Name, serverURL, img.Image)
+		}
+		cluster.AddRelease(&release.Release{
+			Name: "myrelease", Namespace: "apps", Version: 1, Manifest: manifest,
+			Info:  &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{Metadata: &chart.Metadata{Name: chartName, Version: "1.0.0", AppVersion: "2.0.0"}},
+		})
+
+		outputFile := filepath.Join(sb.TempFile(), "Images.lock")
+		require.NoError(os.MkdirAll(filepath.Dir(outputFile), 0755))
+		dt("images", "lock", "--insecure", "--from-release", "myrelease", "-n", "apps", "--kubeconfig", kubeconfig, "--output-file", outputFile).AssertSuccessMatch(t, "Images.lock file written to")
+
+		lock, err := imagelock.FromYAMLFile(outputFile)
+		require.NoError(err)
+		require.Equal(chartName, lock.Chart.Name)
+		require.Equal("1.0.0", lock.Chart.Version)
+		require.Len(lock.Images, len(images))
+		for i, img := range images {
+			require.Equal(fmt.Sprintf("%s/%s", serverURL, img.Image), lock.Images[i].Image)
+			require.Equal(chartName, lock.Images[i].Chart)
+			require.Len(lock.Images[i].Digests, len(img.Digests))
+		}
+
+		t.Run("Inherits the Helm plugin settings", func(t *testing.T) {
+			// Helm exports its --namespace and --kubeconfig flags when running dt as a plugin
+			t.Setenv("HELM_NAMESPACE", "apps")
+			t.Setenv("KUBECONFIG", kubeconfig)
+			outputFile := filepath.Join(filepath.Dir(outputFile), "Plugin.lock")
+			dt("images", "lock", "--insecure", "--from-release", "myrelease", "--output-file", outputFile).AssertSuccess(t)
+			require.FileExists(outputFile)
+		})
+		dt("images", "lock", "--from-release", "other", "-n", "apps", "--kubeconfig", kubeconfig).AssertErrorMatch(t, "failed to get Helm release .*other.*: release: not found")
+		dt("images", "lock", "--from-release", "myrelease", "--kubeconfig", kubeconfig, sb.TempFile()).AssertErrorMatch(t, "unknown command|accepts 0 arg")
+	})
 	t.Run("Errors", func(t *testing.T) {
+		t.Run("Rejects images from disallowed registries", func(t *testing.T) {
+			dest := sb.TempFile()
+			require.NoError(tu.RenderScenario(scenarioDir, dest,
+				map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "RepositoryURL": serverURL},
+			))
+			chartDir := filepath.Join(dest, scenarioName)
+
+			dt("images", "lock", "--insecure", "--blocked-registries", serverURL, chartDir).AssertErrorMatch(t, "found images from disallowed registries")
+			dt("images", "lock", "--insecure", "--allowed-registries", "quay.io", chartDir).AssertErrorMatch(t, "registry is not in the allowed list")
+			dt("images", "lock", "--insecure", "--allowed-registries", serverURL, chartDir).AssertSuccess(t)
+		})
 		t.Run("Handles failure to write lock because of permissions", func(t *testing.T) {
 			scenarioName := "plain-chart"
 			scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/spf13/cobra"
+)
+
+// metricsJobName is the job the metrics are pushed under to the Pushgateway
+const metricsJobName = "dt"
+
+// metricsConfig defines where to publish the metrics of a run
+type metricsConfig struct {
+	// File, if not empty, is the textfile the metrics are written to, for the node_exporter textfile collector
+	File string
+	// PushgatewayURL, if not empty, is the Prometheus Pushgateway the metrics are pushed to
+	PushgatewayURL string
+}
+
+// addMetricsFlags registers the flags used to publish the run metrics
+func addMetricsFlags(cmd *cobra.Command, cfg *metricsConfig) {
+	cmd.Flags().StringVar(&cfg.File, "metrics-file", cfg.File, "write Prometheus metrics of the run to the given file, for the node_exporter textfile collector")
+	cmd.Flags().StringVar(&cfg.PushgatewayURL, "metrics-pushgateway", cfg.PushgatewayURL, "push Prometheus metrics of the run to the given Pushgateway URL")
+}
+
+// enabled returns true if the metrics must be published somewhere
+func (cfg *metricsConfig) enabled() bool {
+	return cfg.File != "" || cfg.PushgatewayURL != ""
+}
+
+// newRunMetricsRegistry returns a registry with the metrics of the run described by s, which failed if runErr
+// is not nil. The labels are added to all the metrics
+func newRunMetricsRegistry(s *runSummary, runErr error, labels prometheus.Labels) *prometheus.Registry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reg := prometheus.NewRegistry()
+	newGauge := func(name string, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help, ConstLabels: labels})
+		reg.MustRegister(g)
+		return g
+	}
+	newCounter := func(name string, help string) prometheus.Counter {
+		c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help, ConstLabels: labels})
+		reg.MustRegister(c)
+		return c
+	}
+
+	t := s.Transfers
+	newCounter("dt_images_pulled_total", "Number of images pulled").Add(float64(t.ImagesPulled))
+	newCounter("dt_images_pushed_total", "Number of images pushed").Add(float64(t.ImagesPushed))
+	newCounter("dt_image_cache_hits_total", "Number of images reused from a previous pull").Add(float64(t.CacheHits))
+	newCounter("dt_retries_total", "Number of image transfers retried").Add(float64(t.Retries))
+
+	transferred := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dt_bytes_transferred_total", Help: "Bytes of image data transferred", ConstLabels: labels,
+	}, []string{"direction"})
+	reg.MustRegister(transferred)
+	transferred.WithLabelValues("download").Add(float64(t.BytesDownloaded))
+	transferred.WithLabelValues("upload").Add(float64(t.BytesUploaded))
+
+	newGauge("dt_duration_seconds", "Duration of the command").Set(float64(s.DurationMs) / 1000)
+	stages := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dt_stage_duration_seconds", Help: "Duration of each stage of the command", ConstLabels: labels,
+	}, []string{"stage"})
+	reg.MustRegister(stages)
+	for _, st := range s.Stages {
+		stages.WithLabelValues(st.Name).Set(float64(st.DurationMs) / 1000)
+	}
+
+	success := newGauge("dt_success", "Whether the command succeeded (1) or failed (0)")
+	if runErr == nil {
+		success.Set(1)
+	}
+	newGauge("dt_last_run_timestamp_seconds", "Time the command finished, in seconds since the epoch").SetToCurrentTime()
+	return reg
+}
+
+// exportRunMetrics publishes the metrics of the run described by s, which failed if runErr is not nil
+func exportRunMetrics(s *runSummary, runErr error, cfg *metricsConfig) error {
+	if !cfg.enabled() {
+		return nil
+	}
+	s.finish()
+	if cfg.File != "" {
+		reg := newRunMetricsRegistry(s, runErr, prometheus.Labels{"command": s.Command})
+		if err := prometheus.WriteToTextfile(cfg.File, reg); err != nil {
+			return fmt.Errorf("failed to write metrics file: %w", err)
+		}
+	}
+	if cfg.PushgatewayURL != "" {
+		// The Pushgateway adds the command label from the grouping key
+		reg := newRunMetricsRegistry(s, runErr, nil)
+		if err := push.New(cfg.PushgatewayURL, metricsJobName).Grouping("command", s.Command).Gatherer(reg).Push(); err != nil {
+			return fmt.Errorf("failed to push metrics: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// Registries mirrors flags
+var (
+	mirrorSettings []string
+	hostsDir       string
+)
+
+// registryMirrors are the mirrors images are read from before trying their registries
+var registryMirrors = make(imagelock.Mirrors)
+
+func getMirrors() imagelock.Mirrors {
+	return registryMirrors
+}
+
+// setupMirrors validates the mirrors flags and registers the mirrors
+func setupMirrors() error {
+	if hostsDir != "" {
+		mirrors, err := imagelock.ReadMirrorsFromHostsDir(hostsDir)
+		if err != nil {
+			return err
+		}
+		registryMirrors = mirrors
+	}
+	for _, setting := range mirrorSettings {
+		registry, mirror, err := parseRegistrySetting("registry-mirror", setting)
+		if err != nil {
+			return err
+		}
+		registryMirrors.Add(registry, mirror)
+	}
+	return nil
+}
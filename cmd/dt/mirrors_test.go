@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *CmdSuite) TestRegistryMirrorFlags() {
+	require := suite.Require()
+	assert := suite.Assert()
+	t := suite.T()
+
+	s, err := tu.NewTestServer()
+	require.NoError(err)
+	defer s.Close()
+
+	images, err := s.LoadImagesFromFile("../../testdata/images.json")
+	require.NoError(err)
+
+	// The images are only available in the mirror
+	const registry = "registry.example.invalid"
+	createSampleChart := func() string {
+		dest := suite.sb.TempFile()
+		require.NoError(tu.RenderScenario("../../testdata/scenarios/custom-chart", dest,
+			map[string]interface{}{"ServerURL": registry, "Images": images, "Name": "test", "RepositoryURL": registry},
+		))
+		return filepath.Join(dest, "custom-chart")
+	}
+	assertLockedFromRegistry := func(chartDir string) {
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		require.Len(lock.Images, len(images))
+		for _, img := range lock.Images {
+			assert.True(strings.HasPrefix(img.Image, registry+"/"), "expected %q to keep its original registry", img.Image)
+			assert.NotEmpty(img.Digests)
+		}
+	}
+
+	t.Run("Fails without mirrors", func(t *testing.T) {
+		dt("images", "lock", "--insecure", createSampleChart()).AssertError(t)
+	})
+	t.Run("Uses the registry mirrors", func(t *testing.T) {
+		chartDir := createSampleChart()
+		dt("images", "lock", "--insecure", "--registry-mirror", fmt.Sprintf("%s=%s", registry, s.ServerURL), chartDir).AssertSuccess(t)
+		assertLockedFromRegistry(chartDir)
+	})
+	t.Run("Reads the mirrors from a containerd hosts directory", func(t *testing.T) {
+		hostsDir := suite.sb.TempFile()
+		require.NoError(os.MkdirAll(filepath.Join(hostsDir, registry), 0755))
+		require.NoError(os.WriteFile(filepath.Join(hostsDir, registry, "hosts.toml"),
+			[]byte(fmt.Sprintf("[host.\"https://%s\"]\n  capabilities = [\"pull\", \"resolve\"]\n", s.ServerURL)), 0644))
+
+		chartDir := createSampleChart()
+		dt("images", "lock", "--insecure", "--registry-hosts-dir", hostsDir, chartDir).AssertSuccess(t)
+		assertLockedFromRegistry(chartDir)
+	})
+	t.Run("Validates the flags", func(t *testing.T) {
+		dt("images", "lock", "--registry-mirror", s.ServerURL, createSampleChart()).AssertErrorMatch(t, "expected the registry=value format")
+	})
+}
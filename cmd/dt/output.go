@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats supported by the informational commands
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+	yamlOutput = "yaml"
+)
+
+// addOutputFlag registers the --output flag used to select the format of the command results
+func addOutputFlag(cmd *cobra.Command, format *string) {
+	cmd.Flags().StringVarP(format, "output", "o", *format, "output format (text, json or yaml)")
+}
+
+// validateOutputFormat returns an error if format is not a supported output format
+func validateOutputFormat(format string) error {
+	switch format {
+	case textOutput, jsonOutput, yamlOutput:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// isStructuredOutput returns true if the results must be printed in a machine-readable format
+func isStructuredOutput(format string) bool {
+	return format == jsonOutput || format == yamlOutput
+}
+
+// getOutputLogger returns the logger to use, writing to stderr if the results are printed to stdout in
+// a structured format
+func getOutputLogger(format string) log.SectionLogger {
+	l := getLogger()
+	if isStructuredOutput(format) {
+		l.SetWriter(os.Stderr)
+	}
+	return l
+}
+
+// writeStructuredOutput serializes v as JSON or YAML. YAML documents use the same field
+// names than their JSON counterpart
+func writeStructuredOutput(w io.Writer, format string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize output: %w", err)
+	}
+	switch format {
+	case jsonOutput:
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case yamlOutput:
+		// JSON is valid YAML, decoding it into a node preserves the fields order
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("failed to serialize output: %w", err)
+		}
+		clearNodeStyle(&node)
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(&node)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// clearNodeStyle resets the flow and quoting styles inherited from the JSON document
+func clearNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		clearNodeStyle(n)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/policy"
+)
+
+// checkChartPolicies evaluates the Rego policies in policyPaths against the images of the chart,
+// which must have been previously pulled, failing if any of them is denied
+func checkChartPolicies(ctx context.Context, chartPath string, policyPaths []string, l log.SectionLogger) error {
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return l.Failf("Failed to load Helm chart: %w", err)
+	}
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return l.Failf("Failed to read Images.lock: %w", err)
+	}
+	engine, err := policy.Load(ctx, policyPaths...)
+	if err != nil {
+		return l.Failf("%v", err)
+	}
+	var violations []policy.Violation
+	if err := l.ExecuteStep("Evaluating images policies", func() error {
+		violations, err = chartutils.CheckImagesPolicy(lock, chart.ImagesDir(), engine, chartutils.WithContext(ctx))
+		return err
+	}); err != nil {
+		return l.Failf("Failed to evaluate policies: %w", err)
+	}
+	if len(violations) == 0 {
+		l.Infof("All images comply with the policies")
+		return nil
+	}
+	for _, v := range violations {
+		l.Warnf("%s", v)
+	}
+	return l.Failf("found %d policy violations", len(violations))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+var postRendererCmd = newPostRendererCmd()
+
+// postRendererSettings defines where the post-renderer reads the relocation of the images from
+type postRendererSettings struct {
+	relocateSettings
+	// ReportFile, if not empty, is the relocation report mapping the original images to the relocated ones
+	ReportFile string
+	// ImagesLockFile, if not empty, is the Images.lock whose images are relocated into Prefix
+	ImagesLockFile string
+	Prefix         string
+}
+
+// relocationReport returns the relocation of the images to apply to the manifests
+func (settings postRendererSettings) relocationReport() (*relocator.Report, error) {
+	if (settings.ReportFile == "") == (settings.ImagesLockFile == "") {
+		return nil, fmt.Errorf("either --relocation-report or --images-lock must be provided")
+	}
+	if settings.ReportFile != "" {
+		return relocator.ReadReport(settings.ReportFile)
+	}
+	if settings.Prefix == "" {
+		return nil, fmt.Errorf("--prefix is required along with --images-lock")
+	}
+	lock, err := imagelock.FromYAMLFile(settings.ImagesLockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %w", err)
+	}
+	opts, err := settings.options("")
+	if err != nil {
+		return nil, err
+	}
+	return relocator.NewReport(lock, settings.Prefix, opts...)
+}
+
+func newPostRendererCmd() *cobra.Command {
+	settings := postRendererSettings{}
+
+	cmd := &cobra.Command{
+		Use:   "post-renderer",
+		Short: "Relocates the images of the manifests rendered by Helm",
+		Long: `Works as a Helm post-renderer: reads the manifests rendered by "helm install", "helm upgrade" or "helm template" from stdin and writes them to stdout, with the references to the images rewritten according to a relocation report (as written by "dt relocate --report-file"), or to the relocation of an Images.lock into a prefix.
+This applies the relocation at install time, without modifying the chart. Only the images of the report or Images.lock are rewritten`,
+		Example: `  # Install a chart relocating its images according to a relocation report
+  $ helm install mariadb examples/mariadb --post-renderer dt --post-renderer-args post-renderer --post-renderer-args --relocation-report=report.json
+
+  # Relocate the images of an Images.lock into a registry
+  $ helm template examples/mariadb | dt post-renderer --images-lock examples/mariadb/Images.lock --prefix demo.goharbor.io/test_repo`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := settings.relocationReport()
+			if err != nil {
+				return err
+			}
+			manifest, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed to read manifests: %w", err)
+			}
+			relocated, _, err := report.RelocateManifest(manifest)
+			if err != nil {
+				return fmt.Errorf("failed to relocate manifests: %w", err)
+			}
+			_, err = cmd.OutOrStdout().Write(relocated)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&settings.ReportFile, "relocation-report", settings.ReportFile, "JSON relocation report mapping the original images to the relocated ones")
+	cmd.Flags().StringVar(&settings.ImagesLockFile, "images-lock", settings.ImagesLockFile, "Images.lock whose images are relocated into --prefix, instead of a relocation report")
+	cmd.Flags().StringVar(&settings.Prefix, "prefix", settings.Prefix, "registry and repository prefix the --images-lock images are relocated into")
+	cmd.Flags().StringVar(&settings.RepositoryStrategy, "repository-strategy", settings.RepositoryStrategy, repositoryStrategyUsage)
+	cmd.Flags().StringVar(&settings.RepositoryMapFile, "repo-map", settings.RepositoryMapFile, "YAML file mapping source repositories to custom target repositories, instead of the ones under --prefix")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(postRendererCmd)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+func (suite *CmdSuite) TestPostRendererCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: docker.io/bitnami/app:1.0.0
+        - name: other
+          image: docker.io/bitnami/other:1.0.0
+`
+	lock := imagelock.NewImagesLock()
+	lock.Images = append(lock.Images, &imagelock.ChartImage{Name: "app", Chart: "test", Image: "docker.io/bitnami/app:1.0.0"})
+	dir := sb.TempFile()
+	require.NoError(os.MkdirAll(dir, 0755))
+	lockFile := filepath.Join(dir, "Images.lock")
+	var buf bytes.Buffer
+	require.NoError(lock.ToYAML(&buf))
+	require.NoError(os.WriteFile(lockFile, buf.Bytes(), 0644))
+	report, err := relocator.NewReport(lock, "registry.example.com/airgap")
+	require.NoError(err)
+	reportFile := filepath.Join(dir, "report.json")
+	require.NoError(report.WriteFile(reportFile))
+
+	// postRender runs the post-renderer as Helm does, writing the manifests into its stdin
+	postRender := func(args ...string) (string, string, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(os.Args[0], append([]string{"post-renderer"}, args...)...)
+		cmd.Env = append(os.Environ(), "BE_DT=1")
+		cmd.Stdin = strings.NewReader(manifest)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+	expected := strings.Replace(manifest, "docker.io/bitnami/app:1.0.0", "registry.example.com/airgap/bitnami/app:1.0.0", 1)
+
+	t.Run("Relocates the images of a relocation report", func(t *testing.T) {
+		stdout, stderr, err := postRender("--relocation-report", reportFile)
+		require.NoError(err, stderr)
+		assert.Equal(expected, stdout)
+	})
+	t.Run("Relocates the images of an Images.lock", func(t *testing.T) {
+		stdout, stderr, err := postRender("--images-lock", lockFile, "--prefix", "registry.example.com/airgap")
+		require.NoError(err, stderr)
+		assert.Equal(expected, stdout)
+	})
+	t.Run("Handles errors", func(t *testing.T) {
+		dt("post-renderer").AssertErrorMatch(t, "either --relocation-report or --images-lock must be provided")
+		dt("post-renderer", "--images-lock", lockFile).AssertErrorMatch(t, "--prefix is required")
+		dt("post-renderer", "--relocation-report", sb.TempFile()).AssertErrorMatch(t, "failed to read report")
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+)
+
+// Progress modes supported by the --progress flag
+const (
+	barProgress  = "bar"
+	jsonProgress = "json"
+)
+
+func validateProgressMode(mode string) error {
+	switch mode {
+	case barProgress, jsonProgress:
+		return nil
+	default:
+		return fmt.Errorf("unsupported progress mode %q", mode)
+	}
+}
+
+// newJSONEventWriter returns an ImageEventHandler writing the events to w as newline-delimited JSON
+func newJSONEventWriter(w io.Writer) chartutils.ImageEventHandler {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(ev chartutils.ImageEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(ev)
+	}
+}
+
+// imageTransferOptions returns the options used when pulling and pushing images: the retries set with
+// --max-retries, the registries requests budget set with --max-requests-per-host and the progress reporting selected with --progress. The image events are also sent
+// to the provided handlers
+func imageTransferOptions(l log.SectionLogger, handlers ...chartutils.ImageEventHandler) []chartutils.Option {
+	var pb widgets.ProgressBar
+	if progressMode == jsonProgress {
+		pb = widgets.NewSilentProgressBar()
+		handlers = append(handlers, newJSONEventWriter(os.Stderr))
+	} else {
+		pb = l.ProgressBar()
+	}
+	return []chartutils.Option{
+		chartutils.WithMaxRetries(maxRetries),
+		chartutils.WithMaxRequestsPerHost(maxRequestsPerHost),
+		chartutils.WithKeychain(getKeychain()),
+		chartutils.WithTransport(getTransport()),
+		chartutils.WithMirrors(getMirrors()),
+		chartutils.WithLocalDaemon(useLocalDaemon),
+		chartutils.WithDaemonHost(localDaemonHost()),
+		chartutils.WithProgressBar(pb),
+		chartutils.WithImageEventHandler(func(ev chartutils.ImageEvent) {
+			for _, h := range handlers {
+				h(ev)
+			}
+		}),
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
-	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
 )
 
 var pullCmd = newPullCommand()
@@ -24,20 +24,19 @@ func pullChartImages(chart *chartutils.Chart, opts ...chartutils.Option) error {
 	if err != nil {
 		return fmt.Errorf("failed to read Images.lock file")
 	}
+	ctx := chartutils.NewConfiguration(opts...).Context
+	if err := runHooks(ctx, beforePullStage, beforePullHooks, chartRoot, lockFile, lock); err != nil {
+		return err
+	}
+	allOpts := append([]chartutils.Option{chartutils.WithRegistryFilter(getRegistryFilter())}, opts...)
 	if err := chartutils.PullImages(lock, imagesDir,
-		opts...,
+		allOpts...,
 	); err != nil {
 		return fmt.Errorf("failed to pull images: %v", err)
 	}
 	return nil
 }
 
-func compressChart(ctx context.Context, chart *chartutils.Chart, outputFile string) error {
-	return utils.TarContext(ctx, chart.RootDir(), outputFile, utils.TarConfig{
-		Prefix: fmt.Sprintf("%s-%s", chart.Name(), chart.Metadata.Version),
-	})
-}
-
 func newPullCommand() *cobra.Command {
 	var outputFile string
 
@@ -65,9 +64,11 @@ func newPullCommand() *cobra.Command {
 			if err := l.Section(fmt.Sprintf("Pulling images into %q", chart.ImagesDir()), func(childLog log.SectionLogger) error {
 				if err := pullChartImages(
 					chart,
-					chartutils.WithLog(childLog),
-					chartutils.WithContext(ctx),
-					chartutils.WithProgressBar(childLog.ProgressBar()),
+					append([]chartutils.Option{
+						chartutils.WithLog(childLog),
+						chartutils.WithContext(ctx),
+						chartutils.WithImagesCache(imagesCache),
+					}, imageTransferOptions(childLog)...)...,
 				); err != nil {
 					return childLog.Failf("%v", err)
 				}
@@ -81,7 +82,7 @@ func newPullCommand() *cobra.Command {
 				if err := l.ExecuteStep(
 					fmt.Sprintf("Compressing chart into %q", outputFile),
 					func() error {
-						return compressChart(ctx, chart, outputFile)
+						return wrap.Compress(ctx, chart, outputFile)
 					},
 				); err != nil {
 					return l.Failf("failed to compress chart: %w", err)
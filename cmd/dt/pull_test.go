@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -8,13 +9,29 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
+// readImageEvents parses the newline-delimited JSON events emitted with --progress json
+func readImageEvents(data string) ([]chartutils.ImageEvent, error) {
+	events := make([]chartutils.ImageEvent, 0)
+	dec := json.NewDecoder(strings.NewReader(data))
+	for dec.More() {
+		var ev chartutils.ImageEvent
+		if err := dec.Decode(&ev); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
 func (suite *CmdSuite) TestPullCommand() {
 	t := suite.T()
 	silentLog := log.New(io.Discard, "", 0)
@@ -74,7 +91,95 @@ func (suite *CmdSuite) TestPullCommand() {
 		verifyChartDir(tmpDir)
 	})
 
+	t.Run("Pulls images reporting JSON progress events", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile())
+		res := dt("images", "pull", "--progress", "json", chartDir)
+		res.AssertSuccess(t)
+		verifyChartDir(chartDir)
+
+		events, err := readImageEvents(res.stderr)
+		require.NoError(err)
+		completed := make(map[string]chartutils.ImageEvent)
+		for _, ev := range events {
+			suite.Assert().Equal("pull", ev.Operation)
+			if ev.State == chartutils.ImageCompleted {
+				completed[ev.Digest.String()] = ev
+			}
+		}
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				ev, ok := completed[digestData.Digest.String()]
+				require.True(ok, "missing completed event for %s", digestData.Digest)
+				suite.Assert().Equal(digestData.Arch, ev.Arch)
+				suite.Assert().Greater(ev.Bytes, int64(0))
+			}
+		}
+		suite.Assert().Len(events, 2*len(completed))
+
+		dt("images", "pull", "--progress", "fancy", chartDir).AssertErrorMatch(t, `unsupported progress mode "fancy"`)
+	})
+
+	t.Run("Pulls images with JSON logs", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile())
+		res := dt("images", "pull", "--log-format", "json", chartDir)
+		res.AssertSuccess(t)
+		verifyChartDir(chartDir)
+
+		sectionTitle := fmt.Sprintf("Pulling images into %q", filepath.Join(chartDir, "images"))
+		var foundSectionMessage, foundSectionDuration bool
+		dec := json.NewDecoder(strings.NewReader(res.stderr))
+		for dec.More() {
+			msg := make(map[string]interface{})
+			require.NoError(dec.Decode(&msg))
+			suite.Assert().Contains(msg, "level")
+			suite.Assert().Contains(msg, "time")
+			if msg["section"] == sectionTitle {
+				foundSectionMessage = true
+			}
+			if msg["completedSection"] == sectionTitle {
+				foundSectionDuration = true
+				suite.Assert().Contains(msg, "durationMs")
+			}
+		}
+		suite.Assert().True(foundSectionMessage, "no messages logged inside the section")
+		suite.Assert().True(foundSectionDuration, "section duration not logged")
+
+		dt("images", "pull", "--log-format", "xml", chartDir).AssertErrorMatch(t, `unsupported log format "xml"`)
+	})
+
+	t.Run("Pulls images writing the full logs into a file", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile())
+		logFile := filepath.Join(chartDir, "dt.log")
+		res := dt("images", "pull", "--log-level", "error", "--log-file", logFile, chartDir)
+		res.AssertSuccess(t)
+		suite.Assert().NotContains(res.stdout, "All images pulled successfully")
+
+		data, err := os.ReadFile(logFile)
+		require.NoError(err)
+		suite.Assert().Contains(string(data), "All images pulled successfully")
+		suite.Assert().NotContains(string(data), "\x1b[")
+
+		dt("images", "pull", "--log-file", filepath.Join(sb.TempFile(), "missing", "dt.log"), chartDir).AssertErrorMatch(t, "failed to open log file")
+	})
+
+	t.Run("Pulls images running the before-pull hooks", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile())
+		hookOutput := sb.TempFile()
+		hook := fmt.Sprintf(`echo "$DT_HOOK_STAGE $DT_HOOK_CHART_NAME $DT_HOOK_CHART_DIR $DT_HOOK_IMAGES" > %s && test ! -d images`, hookOutput)
+		dt("images", "pull", "--before-pull", hook, chartDir).AssertSuccess(t)
+		verifyChartDir(chartDir)
+
+		data, err := os.ReadFile(hookOutput)
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("before-pull %s %s %s/%s\n", chartName, chartDir, serverURL, imageName), string(data))
+	})
+
 	t.Run("Errors", func(t *testing.T) {
+		t.Run("Fails when a before-pull hook fails", func(t *testing.T) {
+			chartDir := createSampleChart(sb.TempFile())
+			dt("images", "pull", "--before-pull", "exit 3", chartDir).AssertErrorMatch(t, `before-pull hook .*exit 3.* failed: exit status 3`)
+			suite.Assert().NoDirExists(filepath.Join(chartDir, "images"))
+		})
 		t.Run("Fails when Images.lock is not found", func(t *testing.T) {
 			chartDir := createSampleChart(sb.TempFile())
 			require.NoError(os.RemoveAll(filepath.Join(chartDir, "Images.lock")))
@@ -83,3 +188,46 @@ func (suite *CmdSuite) TestPullCommand() {
 		})
 	})
 }
+
+func (suite *CmdSuite) TestPullFromLocalDaemon() {
+	t := suite.T()
+	require := suite.Require()
+	sb := suite.sb
+
+	// Nothing listens on the registry, so the image is only available in the daemon, as if freshly built
+	serverURL := "127.0.0.1:1"
+	imageData := tu.ImageData{Name: "test", Image: "test:dev"}
+	img, err := tu.CreateSingleArchImage(&imageData, "linux/amd64")
+	require.NoError(err)
+
+	d := tu.NewDockerDaemon()
+	defer d.Close()
+	d.AddImage(fmt.Sprintf("%s/%s", serverURL, imageData.Image), img)
+	t.Setenv("DOCKER_HOST", d.Host())
+
+	dest := sb.TempFile()
+	require.NoError(tu.RenderScenario("../../testdata/scenarios/complete-chart", dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": []tu.ImageData{imageData}, "Name": "test", "RepositoryURL": serverURL},
+	))
+	chartDir := filepath.Join(dest, "complete-chart")
+	require.NoError(os.Remove(filepath.Join(chartDir, "Images.lock")))
+
+	dt("images", "lock", "--max-retries", "0", chartDir).AssertErrorMatch(t, "failed to get descriptor")
+	dt("images", "lock", "--local-daemon", chartDir).AssertSuccess(t)
+	data, err := os.ReadFile(filepath.Join(chartDir, "Images.lock"))
+	require.NoError(err)
+	suite.Assert().Contains(string(data), imageData.Digests[0].Digest.String())
+
+	dt("images", "pull", "--max-retries", "0", "--local-daemon", chartDir).AssertSuccess(t)
+	suite.Assert().FileExists(filepath.Join(chartDir, "images", fmt.Sprintf("%s.tar", imageData.Digests[0].Digest.Encoded())))
+	dt("images", "verify", chartDir, "--local-daemon").AssertSuccess(t)
+
+	// podman serves the same API through its own socket
+	t.Setenv("CONTAINER_HOST", d.Host())
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	require.NoError(os.RemoveAll(filepath.Join(chartDir, "images")))
+	dt("images", "verify", chartDir, "--local-daemon").AssertErrorMatch(t, "local Docker daemon")
+	dt("images", "verify", chartDir, "--local-daemon", "--podman").AssertSuccess(t)
+	dt("images", "pull", "--max-retries", "0", "--local-daemon", "--podman", chartDir).AssertSuccess(t)
+	suite.Assert().FileExists(filepath.Join(chartDir, "images", fmt.Sprintf("%s.tar", imageData.Digests[0].Digest.Encoded())))
+}
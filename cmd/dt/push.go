@@ -3,64 +3,108 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
 	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
 )
 
 var pushCmd = newPushCmd()
 
 func pushChartImages(chartPath string, opts ...chartutils.Option) error {
-	chartRoot, err := chartutils.GetChartRoot(chartPath)
+	lock, err := wrap.PushImages(chartPath, opts...)
 	if err != nil {
-		return fmt.Errorf("cannot determine Helm chart root for %q: %v", chartPath, err)
+		return err
 	}
-	imagesDir := filepath.Join(chartRoot, "images")
-
-	lockFile := filepath.Join(chartRoot, imagelock.DefaultImagesLockFileName)
-
-	fh, err := os.Open(lockFile)
+	lockFile, err := wrap.LockFile(chartPath)
 	if err != nil {
-		return fmt.Errorf("failed to open Images.lock file: %v", err)
+		return err
 	}
-	defer fh.Close()
+	ctx := chartutils.NewConfiguration(opts...).Context
+	return runHooks(ctx, afterPushStage, afterPushHooks, filepath.Dir(lockFile), lockFile, lock)
+}
 
-	lock, err := imagelock.FromYAML(fh)
+// lockRegistries returns the registries the images of the Images.lock of the chart at chartPath are pushed to
+func lockRegistries(chartPath string) ([]string, error) {
+	lockFile, err := wrap.LockFile(chartPath)
 	if err != nil {
-		return fmt.Errorf("failed to load Images.lock: %v", err)
+		return nil, err
 	}
-
-	return chartutils.PushImages(lock, imagesDir, opts...)
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil, err
+	}
+	registries := make([]string, 0)
+	for _, img := range lock.Images {
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image reference %q: %w", img.Image, err)
+		}
+		registries = append(registries, ref.Context().RegistryStr())
+	}
+	return registries, nil
 }
 
 func newPushCmd() *cobra.Command {
-	return &cobra.Command{
+	var policyPaths []string
+	var replicaURLs []string
+
+	cmd := &cobra.Command{
 		Use:   "push CHART_PATH OCI_URI",
 		Short: "Pushes the images from Images.lock",
 		Long:  "Pushes the images found on the Images.lock from the given Helm chart path into their current registries",
 		Example: `  # Push images from a sample local Helm chart
   # Images are pushed to their registries, e.g. oci://docker.io/bitnami/kafka will be pushed to DockerHub, oci://demo.goharbor.io/bitnami/redis will be pushed to Harbor
-  $ dt images push examples/mariadb`,
+  $ dt images push examples/mariadb
+
+  # Push images replicating them into a registry per region
+  $ dt images push examples/mariadb --replicate-to eu.example.com/bitnami --replicate-to us.example.com/bitnami
+
+  # Push images only if they comply with a set of Rego policies
+  $ dt images push examples/mariadb --policy policies/`,
 		Args:          cobra.ExactArgs(1),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			chartPath := args[0]
+			if err := flagsKeychain.scopeDefaultAuth(replicaURLs...); err != nil {
+				return err
+			}
+			// A missing or malformed Images.lock is reported when pushing the images
+			if registries, err := lockRegistries(chartPath); err == nil {
+				if err := flagsKeychain.scopeDefaultAuth(registries...); err != nil {
+					return err
+				}
+			}
 
 			ctx, cancel := contextWithSigterm(context.Background())
 			defer cancel()
 			l := getLogger()
 
+			if len(policyPaths) > 0 {
+				if err := l.Section("Checking images policies", func(subLog log.SectionLogger) error {
+					return checkChartPolicies(ctx, chartPath, policyPaths, subLog)
+				}); err != nil {
+					return err
+				}
+			}
+
+			replicas, err := newImagesReplication(chartPath, replicaURLs, relocateSettings{})
+			if err != nil {
+				return l.Failf("failed to prepare the images replication: %w", err)
+			}
+
 			if err := l.Section("Pushing Images", func(subLog log.SectionLogger) error {
 				if err := pushChartImages(
 					chartPath,
-					chartutils.WithLog(log.SilentLog),
-					chartutils.WithContext(ctx),
-					chartutils.WithProgressBar(subLog.ProgressBar()),
+					append([]chartutils.Option{
+						chartutils.WithLog(log.SilentLog),
+						chartutils.WithContext(ctx),
+					}, imageTransferOptions(subLog)...)...,
 				); err != nil {
 					return subLog.Failf("Failed to push images: %w", err)
 				}
@@ -70,9 +114,21 @@ func newPushCmd() *cobra.Command {
 				return err
 			}
 
+			if replicas != nil {
+				results, err := replicas.push(ctx, l)
+				showTargets(l, results)
+				if err != nil {
+					return l.Failf("Failed to replicate images: %w", err)
+				}
+			}
+
 			l.Printf(terminalSpacer)
 			l.Successf("All images pushed successfully")
 			return nil
 		},
 	}
+	cmd.PersistentFlags().StringSliceVar(&replicaURLs, "replicate-to", replicaURLs, "also push the images into the given registries, relocating their Images.lock references (can be repeated)")
+	cmd.PersistentFlags().StringSliceVar(&policyPaths, "policy", policyPaths, "Rego policy file or directory the images must comply with before being pushed (can be repeated)")
+
+	return cmd
 }
@@ -13,6 +13,7 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 )
@@ -141,6 +142,52 @@ func (suite *CmdSuite) TestPushCommand() {
 				}
 			}
 		})
+		t.Run("Push images reporting JSON progress events", func(t *testing.T) {
+			res := dt("images", "push", "--progress", "json", chartDir)
+			res.AssertSuccess(t)
+			events, err := readImageEvents(res.stderr)
+			require.NoError(err)
+			require.Len(events, 2)
+			assert.Equal(chartutils.ImageStarted, events[0].State)
+			assert.Equal(chartutils.ImageCompleted, events[1].State)
+			assert.Equal("push", events[1].Operation)
+			assert.Equal(fmt.Sprintf("%s/%s", u.Host, imageName), events[1].Image)
+			assert.NotEmpty(events[1].Digest)
+			assert.Greater(events[1].Bytes, int64(0))
+		})
+		t.Run("Push images running the after-push hooks", func(t *testing.T) {
+			hookOutput := sb.TempFile()
+			hook := fmt.Sprintf(`echo "$DT_HOOK_STAGE $DT_HOOK_CHART_NAME $DT_HOOK_IMAGES_LOCK $DT_HOOK_IMAGES" >> %s`, hookOutput)
+			dt("images", "push", "--after-push", hook, chartDir).AssertSuccess(t)
+
+			data, err := os.ReadFile(hookOutput)
+			require.NoError(err)
+			assert.Equal(fmt.Sprintf("after-push %s %s %s/%s\n", chartName, filepath.Join(chartDir, "Images.lock"), u.Host, imageName), string(data))
+
+			dt("images", "push", "--after-push", "exit 1", chartDir).AssertErrorMatch(t, `after-push hook .*exit 1.* failed`)
+		})
+		t.Run("Push images replicating them into other registries", func(t *testing.T) {
+			verifyPushed := func(prefix string) {
+				for _, img := range images {
+					remoteDigests, err := tu.ReadRemoteImageManifest(fmt.Sprintf("%s/%s", prefix, img.Image))
+					require.NoError(err)
+					for _, dgstData := range img.Digests {
+						assert.Equal(dgstData.Digest.Hex(), remoteDigests[dgstData.Arch].Digest.Hex())
+					}
+				}
+			}
+			res := dt("images", "push", chartDir, "--replicate-to", serverURL+"/eu", "--replicate-to", serverURL+"/us")
+			res.AssertSuccess(t)
+			assert.Regexp(`Registry .*/eu.*: 1 images pushed`, res.stdout)
+			verifyPushed(serverURL + "/eu")
+			verifyPushed(serverURL + "/us")
+
+			// A failing registry does not prevent replicating the images into the rest of them
+			unreachable := "127.0.0.1:1/unreachable"
+			dt("images", "push", chartDir, "--max-retries", "0", "--replicate-to", unreachable, "--replicate-to", serverURL+"/apac").
+				AssertErrorMatch(t, regexp.MustCompile(fmt.Sprintf(`failed to replicate images into %q`, unreachable)))
+			verifyPushed(serverURL + "/apac")
+		})
 	})
 
 }
@@ -2,13 +2,29 @@ package main
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
 	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
 var relocateCmd = newRelocateCmd()
 
+const repositoryStrategyUsage = "how source repositories are mapped under OCI_URI: default (keep the last two path components, bitnami/nginx), " +
+	"full (keep the full path), flat (keep the last component, nginx) or hash (keep the last component prefixed with a hash of the source repository)"
+
+const tagStrategyUsage = "how the relocated images are addressed, and so pushed: original (keep the source tags), " +
+	"digest (by digest only, pushing them untagged; requires the images to be pulled into the chart directory) or chart (retag them with CHART_NAME-VERSION)"
+
+const kbldConfigUsage = "write a kbld Config overriding the original image references with the relocated ones, for carvel-based deployments"
+
 func relocateChart(chartPath, repository string, opts ...relocator.RelocateOption) error {
 	baseOpts := []relocator.RelocateOption{
 		relocator.Recursive,
@@ -23,13 +39,219 @@ func relocateChart(chartPath, repository string, opts ...relocator.RelocateOptio
 	}
 	return nil
 }
+
+// newRelocationReport returns the report mapping the images in the chart Images.lock to their relocated references
+func newRelocationReport(chartPath string, prefix string, opts ...relocator.RelocateOption) (*relocator.Report, error) {
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine Images.lock file location: %w", err)
+	}
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %w", err)
+	}
+	return relocator.NewReport(lock, prefix, opts...)
+}
+
+// imageIndexDigests returns the digests the images in the chart Images.lock are pushed with, computed from
+// the images pulled into the chart directory
+func imageIndexDigests(chartPath string) (map[string]digest.Digest, error) {
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine Images.lock file location: %w", err)
+	}
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %w", err)
+	}
+	digests := make(map[string]digest.Digest)
+	for _, img := range lock.Images {
+		d, err := chartutils.ImageIndexDigest(img, chart.ImagesDir())
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the digest of %q (pull the images first with \"dt images pull\"): %w", img.Image, err)
+		}
+		digests[img.Image] = d
+	}
+	return digests, nil
+}
+
+// relocateSettings defines the optional relocation behaviors
+type relocateSettings struct {
+	// ReportFile, if not empty, is where to write the relocation report
+	ReportFile string
+	// KbldConfigFile, if not empty, is where to write the kbld config overriding the original images
+	KbldConfigFile string
+	// Emit are the kinds of deployment files, referencing the relocated images, to write into EmitDir
+	Emit []string
+	// EmitDir is the directory the Emit files are written into
+	EmitDir string
+	// PinDigests requests values.yaml to reference the relocated images by the digests they are pushed with
+	PinDigests bool
+	// Files are the patterns of the chart files whose image references are relocated, besides values.yaml
+	Files []string
+	// RepositoryMapFile, if not empty, maps source repositories to custom target repositories
+	RepositoryMapFile string
+	// RepositoryMap, if not nil, takes precedence over RepositoryMapFile
+	RepositoryMap relocator.RepositoryMap
+	// RepositoryStrategy defines how the source repositories are mapped into the relocation prefix
+	RepositoryStrategy string
+	// TagStrategy defines how the relocated images are addressed
+	TagStrategy string
+}
+
+// options returns the relocation options for the chart in chartPath
+func (s relocateSettings) options(chartPath string) ([]relocator.RelocateOption, error) {
+	opts := []relocator.RelocateOption{relocator.WithFiles(s.Files...)}
+	if s.RepositoryStrategy != "" {
+		strategy, err := relocator.ParseRepositoryStrategy(s.RepositoryStrategy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, relocator.WithRepositoryStrategy(strategy))
+	}
+	pinDigests := s.PinDigests
+	if s.TagStrategy != "" {
+		strategy, err := relocator.ParseTagStrategy(s.TagStrategy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, relocator.WithTagStrategy(strategy))
+		pinDigests = pinDigests || strategy == relocator.DigestTagStrategy
+	}
+	if s.RepositoryMap != nil {
+		opts = append(opts, relocator.WithRepositoryMap(s.RepositoryMap))
+	} else if s.RepositoryMapFile != "" {
+		m, err := relocator.ReadRepositoryMap(s.RepositoryMapFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, relocator.WithRepositoryMap(m))
+	}
+	if pinDigests {
+		digests, err := imageIndexDigests(chartPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pin images digests: %w", err)
+		}
+		opts = append(opts, relocator.WithDigests(digests))
+	}
+	return opts, nil
+}
+
+// relocateChartWithReport relocates the chart and, if requested, writes the relocation report
+func relocateChartWithReport(chartPath string, prefix string, settings relocateSettings, l log.SectionLogger) error {
+	opts, err := settings.options(chartPath)
+	if err != nil {
+		return l.Failf("failed to relocate %q: %w", chartPath, err)
+	}
+	if err := validateEmit(settings.Emit); err != nil {
+		return l.Failf("failed to relocate %q: %w", chartPath, err)
+	}
+	var report *relocator.Report
+	if settings.ReportFile != "" || settings.KbldConfigFile != "" || len(settings.Emit) > 0 {
+		if report, err = newRelocationReport(chartPath, prefix, opts...); err != nil {
+			return l.Failf("failed to generate relocation report: %w", err)
+		}
+	}
+	// The original values are kept to emit the ones the relocation modifies
+	var values map[string]interface{}
+	if len(settings.Emit) > 0 {
+		if values, err = chartutils.ChartValues(chartPath); err != nil {
+			return l.Failf("failed to relocate %q: %w", chartPath, err)
+		}
+	}
+	opts = append(opts, relocator.WithLog(l))
+	if err := l.ExecuteStep(fmt.Sprintf("Relocating %q with prefix %q", chartPath, prefix), func() error {
+		return relocateChart(chartPath, prefix, opts...)
+	}); err != nil {
+		return l.Failf("failed to relocate %q: %w", chartPath, err)
+	}
+	if report == nil {
+		return nil
+	}
+	if err := writeRelocationReports(report, settings, l); err != nil {
+		return err
+	}
+	return emitRelocationFiles(chartPath, report, values, settings, l)
+}
+
+// writeRelocationReports writes the relocation report and the kbld config requested in settings
+func writeRelocationReports(report *relocator.Report, settings relocateSettings, l log.SectionLogger) error {
+	if settings.ReportFile != "" {
+		if err := report.WriteFile(settings.ReportFile); err != nil {
+			return l.Failf("failed to write relocation report: %w", err)
+		}
+		l.Infof("Relocation report written to %q", settings.ReportFile)
+	}
+	if settings.KbldConfigFile != "" {
+		if err := report.WriteFileFormat(settings.KbldConfigFile, relocator.ReportKbld); err != nil {
+			return l.Failf("failed to write kbld config: %w", err)
+		}
+		l.Infof("kbld config written to %q", settings.KbldConfigFile)
+	}
+	return nil
+}
+
+// signRelocatedChart packages the relocated chart into outputFile and writes its provenance file next to it
+func signRelocatedChart(chartPath string, outputFile string, signOpts []signature.Option) (string, error) {
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	if outputFile == "" {
+		outputFile = fmt.Sprintf("%s-%s.tgz", chart.Name(), chart.Metadata.Version)
+	}
+	if err := chart.Package(outputFile); err != nil {
+		return "", err
+	}
+	if err := signature.SignChart(outputFile, outputFile+utils.ProvenanceExtension, signOpts...); err != nil {
+		return "", err
+	}
+	return outputFile, nil
+}
+
 func newRelocateCmd() *cobra.Command {
-	return &cobra.Command{
+	var signKey string
+	var keyring = signature.DefaultSecretKeyring()
+	var passphraseFile string
+	var outputFile string
+	var showDiff bool
+	var interactive bool
+	settings := relocateSettings{
+		Files: relocator.DefaultFiles, RepositoryStrategy: string(relocator.DefaultRepositoryStrategy), TagStrategy: string(relocator.OriginalTagStrategy),
+	}
+
+	cmd := &cobra.Command{
 		Use:   "relocate CHART_PATH OCI_URI",
 		Short: "Relocates a Helm chart",
-		Long:  "Relocates a Helm chart into a new OCI registry. This command will replace the existing registry references with the new registry both in the Images.lock and values.yaml files",
+		Long:  "Relocates a Helm chart into a new OCI registry. This command will replace the existing registry references with the new registry in the Images.lock and values.yaml files, as well as in the CRDs and the files/ directory",
 		Example: `  # Relocate a chart from DockerHub into demo Harbor
-  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo`,
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo
+
+  # Review the changes relocating a chart would make, without modifying it
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --diff
+
+  # Relocate a chart reviewing the target repository of every image
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --interactive
+
+  # Relocate a chart writing a CSV report mapping the original images to the relocated ones
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --report-file relocation.csv
+
+  # Relocate a chart moving some repositories to custom targets (source: target entries)
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --repo-map repo-map.yaml
+
+  # Relocate a chart referencing the pulled images by digest in values.yaml
+  $ dt images pull examples/mariadb
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --pin-digests
+
+  # Relocate a chart retagging its images with the chart name and version (mariadb-12.2.8)
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --tag-strategy chart
+
+  # Relocate a chart, packaging and signing the result (mariadb-12.2.8.tgz and mariadb-12.2.8.tgz.prov)
+  $ dt charts relocate examples/mariadb oci://demo.goharbor.io/test_repo --sign-key ops@example.com`,
 		Args:          cobra.ExactArgs(2),
 		SilenceUsage:  true,
 		SilenceErrors: true,
@@ -39,14 +261,59 @@ func newRelocateCmd() *cobra.Command {
 				return fmt.Errorf("repository cannot be empty")
 			}
 			l := getLogger()
-			if err := l.ExecuteStep(fmt.Sprintf("Relocating %q with prefix %q", chartPath, repository), func() error {
-				return relocateChart(chartPath, repository, relocator.WithLog(l))
-			}); err != nil {
-				return l.Failf("failed to relocate %q: %w", chartPath, err)
+			if interactive {
+				m, err := reviewChartRelocation(cmd.InOrStdin(), cmd.OutOrStdout(), chartPath, repository, settings)
+				if err != nil {
+					return l.Failf("failed to review the relocation of %q: %w", chartPath, err)
+				}
+				settings.RepositoryMap = m
+			}
+			if showDiff {
+				if signKey != "" {
+					return fmt.Errorf("--diff cannot be used with --sign-key")
+				}
+				if err := relocateChartDiff(os.Stdout, chartPath, repository, settings); err != nil {
+					return l.Failf("failed to relocate %q: %w", chartPath, err)
+				}
+				return nil
+			}
+			if err := relocateChartWithReport(chartPath, repository, settings, l); err != nil {
+				return err
+			}
+
+			if signKey != "" {
+				signOpts, err := signOptions(signKey, keyring, passphraseFile)
+				if err != nil {
+					return err
+				}
+				var chartFile string
+				if err := l.ExecuteStep("Signing relocated Helm chart", func() error {
+					chartFile, err = signRelocatedChart(chartPath, outputFile, signOpts)
+					return err
+				}); err != nil {
+					return l.Failf("failed to sign %q: %w", chartPath, err)
+				}
+				l.Infof("Helm chart packaged into %q and signed into %q", chartFile, chartFile+utils.ProvenanceExtension)
 			}
 
 			l.Successf("Helm chart relocated successfully")
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&signKey, "sign-key", signKey, "package the relocated chart and sign it with the given GPG key (user id, long key id or fingerprint), writing its provenance file")
+	cmd.Flags().StringVar(&keyring, "keyring", keyring, "location of the secret keyring used with --sign-key")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", passphraseFile, "file containing the passphrase of the signing key")
+	cmd.Flags().StringVar(&outputFile, "output-file", outputFile, "location of the packaged chart when using --sign-key (defaults to CHART_NAME-VERSION.tgz)")
+	cmd.Flags().BoolVar(&interactive, "interactive", interactive, "review the target repository of every source repository, accepting, editing or skipping it, before relocating")
+	cmd.Flags().BoolVar(&showDiff, "diff", showDiff, "print a unified diff of the files the relocation would modify, without modifying them")
+	cmd.Flags().StringVar(&settings.ReportFile, "report-file", settings.ReportFile, "write a report mapping the original image references to the relocated ones (JSON, or CSV if the file has the .csv extension)")
+	cmd.Flags().StringVar(&settings.KbldConfigFile, "kbld-config", settings.KbldConfigFile, kbldConfigUsage)
+	cmd.Flags().StringSliceVar(&settings.Emit, "emit", settings.Emit, emitUsage)
+	cmd.Flags().StringVar(&settings.EmitDir, "emit-dir", ".", "directory the --emit files are written into")
+	cmd.Flags().BoolVar(&settings.PinDigests, "pin-digests", settings.PinDigests, "reference the relocated images by digest in values.yaml (requires the images to be pulled into the chart directory)")
+	cmd.Flags().StringVar(&settings.RepositoryStrategy, "repository-strategy", settings.RepositoryStrategy, repositoryStrategyUsage)
+	cmd.Flags().StringVar(&settings.TagStrategy, "tag-strategy", settings.TagStrategy, tagStrategyUsage)
+	cmd.Flags().StringVar(&settings.RepositoryMapFile, "repo-map", settings.RepositoryMapFile, "YAML file mapping source repositories to custom target repositories, instead of the ones under OCI_URI")
+	cmd.Flags().StringSliceVar(&settings.Files, "relocate-files", settings.Files, "patterns of the chart files, relative to its root, whose image references are relocated besides values.yaml (directories include all their files)")
+	return cmd
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+)
+
+// copyChartDir copies the Helm chart in src into dest, skipping the pulled images, which relocation does not modify
+func copyChartDir(src string, dest string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && rel == "images" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// diffChartDirs writes into w the unified diff of the files of the Helm chart in src modified in dest
+func diffChartDirs(w io.Writer, src string, dest string) error {
+	return filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		original, err := os.ReadFile(filepath.Join(src, rel))
+		if err != nil {
+			return err
+		}
+		relocated, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(original, relocated) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if bytes.IndexByte(original, 0) >= 0 || bytes.IndexByte(relocated, 0) >= 0 {
+			_, err := fmt.Fprintf(w, "Binary files a/%s and b/%s differ\n", rel, rel)
+			return err
+		}
+		return difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(original)),
+			B:        difflib.SplitLines(string(relocated)),
+			FromFile: "a/" + rel,
+			ToFile:   "b/" + rel,
+			Context:  3,
+		})
+	})
+}
+
+// relocateChartDiff writes into w the unified diff of the files relocating the Helm chart in chartPath
+// would modify, relocating a copy of it
+func relocateChartDiff(w io.Writer, chartPath string, prefix string, settings relocateSettings) error {
+	opts, err := settings.options(chartPath)
+	if err != nil {
+		return err
+	}
+	chartRoot, err := chartutils.GetChartRoot(chartPath)
+	if err != nil {
+		return fmt.Errorf("cannot determine Helm chart root for %q: %v", chartPath, err)
+	}
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(tmpDir, "relocate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	chartCopy := filepath.Join(dir, filepath.Base(chartRoot))
+	if err := copyChartDir(chartRoot, chartCopy); err != nil {
+		return fmt.Errorf("failed to copy Helm chart: %w", err)
+	}
+	if err := relocateChart(chartCopy, prefix, opts...); err != nil {
+		return err
+	}
+	return diffChartDirs(w, chartRoot, chartCopy)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+const emitUsage = "write deployment files referencing the relocated images into --emit-dir: kustomize (a kustomization.yaml with an images transformer renaming the original images), " +
+	"values (a relocated-values.yaml overriding the relocated values) or flux (a helmrelease.yaml Flux HelmRelease with those values)"
+
+// relocationOutputs are the relocation details the --emit files are generated from
+type relocationOutputs struct {
+	Report *relocator.Report
+	// Values are the chart values modified by the relocation
+	Values map[string]interface{}
+}
+
+// relocationEmitter writes one of the --emit files
+type relocationEmitter struct {
+	File  string
+	Write func(w io.Writer, out *relocationOutputs) error
+}
+
+// emitters maps the --emit kinds to their emitters
+var emitters = map[string]relocationEmitter{
+	"kustomize": {File: "kustomization.yaml", Write: func(w io.Writer, out *relocationOutputs) error {
+		return out.Report.Write(w, relocator.ReportKustomize)
+	}},
+	"values": {File: "relocated-values.yaml", Write: func(w io.Writer, out *relocationOutputs) error {
+		return writeStructuredOutput(w, yamlOutput, out.Values)
+	}},
+	"flux": {File: "helmrelease.yaml", Write: func(w io.Writer, out *relocationOutputs) error {
+		return writeStructuredOutput(w, yamlOutput, newFluxHelmRelease(out))
+	}},
+}
+
+// fluxHelmRelease is a Flux HelmRelease installing the relocated chart
+type fluxHelmRelease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Interval string `json:"interval"`
+		Chart    struct {
+			Spec struct {
+				Chart     string `json:"chart"`
+				Version   string `json:"version"`
+				SourceRef struct {
+					Kind string `json:"kind"`
+					Name string `json:"name"`
+				} `json:"sourceRef"`
+			} `json:"spec"`
+		} `json:"chart"`
+		Values map[string]interface{} `json:"values"`
+	} `json:"spec"`
+}
+
+// newFluxHelmRelease returns the HelmRelease of the relocated chart. Its source is expected to be a
+// HelmRepository named after the chart
+func newFluxHelmRelease(out *relocationOutputs) *fluxHelmRelease {
+	hr := &fluxHelmRelease{APIVersion: "helm.toolkit.fluxcd.io/v2", Kind: "HelmRelease"}
+	hr.Metadata.Name = out.Report.Chart
+	hr.Spec.Interval = "10m"
+	hr.Spec.Chart.Spec.Chart = out.Report.Chart
+	hr.Spec.Chart.Spec.Version = out.Report.Version
+	hr.Spec.Chart.Spec.SourceRef.Kind = "HelmRepository"
+	hr.Spec.Chart.Spec.SourceRef.Name = out.Report.Chart
+	hr.Spec.Values = out.Values
+	return hr
+}
+
+// validateEmit checks all the requested --emit kinds are supported
+func validateEmit(emit []string) error {
+	for _, kind := range emit {
+		if _, ok := emitters[kind]; !ok {
+			return fmt.Errorf("unsupported --emit kind %q", kind)
+		}
+	}
+	return nil
+}
+
+// emitRelocationFiles writes the --emit files requested in settings, from the relocation report and
+// the chart values before the relocation
+func emitRelocationFiles(chartPath string, report *relocator.Report, before map[string]interface{}, settings relocateSettings, l log.SectionLogger) error {
+	if len(settings.Emit) == 0 {
+		return nil
+	}
+	after, err := chartutils.ChartValues(chartPath)
+	if err != nil {
+		return l.Failf("failed to read the relocated values: %w", err)
+	}
+	out := &relocationOutputs{Report: report, Values: chartutils.DiffValues(before, after)}
+	for _, kind := range settings.Emit {
+		file := filepath.Join(settings.EmitDir, emitters[kind].File)
+		if err := writeEmittedFile(file, emitters[kind], out); err != nil {
+			return l.Failf("failed to write %s file: %w", kind, err)
+		}
+		l.Infof("%s file written to %q", kind, file)
+	}
+	return nil
+}
+
+func writeEmittedFile(file string, e relocationEmitter, out *relocationOutputs) error {
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return e.Write(fh, out)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+// reviewRepositories asks, for each source repository in report, whether to accept its proposed target
+// repository, edit it or skip relocating it, adding the edited and skipped repositories to m
+func reviewRepositories(in io.Reader, out io.Writer, report *relocator.Report, m relocator.RepositoryMap) error {
+	r := bufio.NewReader(in)
+	reviewed := make(map[string]bool)
+	for _, img := range report.Images {
+		source, err := name.ParseReference(img.Source)
+		if err != nil {
+			return fmt.Errorf("failed to parse image %q: %v", img.Source, err)
+		}
+		target, err := name.ParseReference(img.Target)
+		if err != nil {
+			return fmt.Errorf("failed to parse image %q: %v", img.Target, err)
+		}
+		sourceRepo := source.Context().Name()
+		if reviewed[sourceRepo] {
+			continue
+		}
+		reviewed[sourceRepo] = true
+		fmt.Fprintf(out, "%s -> %s\n", sourceRepo, target.Context().Name())
+		if err := reviewRepository(r, out, sourceRepo, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reviewRepository asks how to relocate sourceRepo until getting a valid answer
+func reviewRepository(r *bufio.Reader, out io.Writer, sourceRepo string, m relocator.RepositoryMap) error {
+	for {
+		answer, err := readAnswer(r, out, "[a]ccept, [e]dit or [s]kip? (default: accept) ")
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(answer) {
+		case "", "a", "accept":
+			return nil
+		case "s", "skip":
+			return m.Add(sourceRepo, sourceRepo)
+		case "e", "edit":
+			target, err := readAnswer(r, out, "Target repository: ")
+			if err != nil {
+				return err
+			}
+			if err := m.Add(sourceRepo, target); err != nil {
+				fmt.Fprintf(out, "Invalid target: %v\n", err)
+				continue
+			}
+			return nil
+		default:
+			fmt.Fprintf(out, "Unknown answer %q\n", answer)
+		}
+	}
+}
+
+// readAnswer shows the prompt and reads a line from r
+func readAnswer(r *bufio.Reader, out io.Writer, prompt string) (string, error) {
+	fmt.Fprint(out, prompt)
+	answer, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", fmt.Errorf("relocation review cancelled: %w", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// reviewChartRelocation interactively reviews the relocation of the images of the Helm chart in chartPath into
+// prefix, returning the repository map with the chosen targets
+func reviewChartRelocation(in io.Reader, out io.Writer, chartPath string, prefix string, settings relocateSettings) (relocator.RepositoryMap, error) {
+	m := make(relocator.RepositoryMap)
+	if settings.RepositoryMapFile != "" {
+		var err error
+		if m, err = relocator.ReadRepositoryMap(settings.RepositoryMapFile); err != nil {
+			return nil, err
+		}
+	}
+	opts, err := settings.options(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	report, err := newRelocationReport(chartPath, prefix, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the images relocation: %w", err)
+	}
+	if err := reviewRepositories(in, out, report, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 	"gopkg.in/yaml.v3"
 )
 
@@ -66,4 +78,338 @@ func (suite *CmdSuite) TestRelocateCommand() {
 			suite.Assert().Equal(expected, got)
 		}
 	})
+	suite.T().Run("Relocate Helm chart signing it", func(t *testing.T) {
+		keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		secring, pubring, err := writeKeyrings(keysDir, "dt")
+		require.NoError(err)
+
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		chartFile := filepath.Join(sb.TempFile(), "test.tgz")
+		require.NoError(os.MkdirAll(filepath.Dir(chartFile), 0755))
+		dt("charts", "relocate", originChart, "custom.repo.example.com",
+			"--sign-key", "dt", "--keyring", secring, "--output-file", chartFile).AssertSuccess(t)
+
+		identities, err := utils.VerifyChartProvenance(chartFile, chartFile+utils.ProvenanceExtension, pubring)
+		require.NoError(err)
+		suite.Assert().Equal([]string{"dt <dt@example.com>"}, identities)
+
+		dt("charts", "relocate", originChart, "custom.repo.example.com",
+			"--sign-key", "missing", "--keyring", secring, "--output-file", chartFile).AssertErrorMatch(t, "failed to sign")
+	})
+	suite.T().Run("Relocate Helm chart files", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		image := images[0].Image
+		writeCRD := func(chartDir string) string {
+			crd := filepath.Join(chartDir, "crds", "crd.yaml")
+			require.NoError(os.MkdirAll(filepath.Dir(crd), 0755))
+			require.NoError(os.WriteFile(crd, []byte(fmt.Sprintf("image: %s/%s\n", serverURL, image)), 0644))
+			return crd
+		}
+
+		crd := writeCRD(renderLockedChart(sb.TempFile(), scenarioName, serverURL))
+		dt("charts", "relocate", filepath.Dir(filepath.Dir(crd)), relocateURL).AssertSuccess(t)
+		data, err := os.ReadFile(crd)
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("image: %s/%s\n", relocateURL, image), string(data))
+
+		crd = writeCRD(renderLockedChart(sb.TempFile(), scenarioName, serverURL))
+		dt("charts", "relocate", filepath.Dir(filepath.Dir(crd)), relocateURL, "--relocate-files", "files").AssertSuccess(t)
+		data, err = os.ReadFile(crd)
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("image: %s/%s\n", serverURL, image), string(data))
+	})
+	suite.T().Run("Relocate Helm chart with a repository map", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		mapped := images[0]
+		repository, tag, _ := strings.Cut(mapped.Image, ":")
+		repoMap := filepath.Join(sb.TempFile(), "repo-map.yaml")
+		require.NoError(os.MkdirAll(filepath.Dir(repoMap), 0755))
+		require.NoError(os.WriteFile(repoMap, []byte(fmt.Sprintf("%s/%s: %s/team-a/%s\n", serverURL, repository, relocateURL, mapped.Name)), 0644))
+
+		reportFile := filepath.Join(filepath.Dir(repoMap), "report.json")
+		dt("charts", "relocate", originChart, relocateURL, "--repo-map", repoMap, "--report-file", reportFile).AssertSuccess(t)
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(originChart, "Images.lock"))
+		require.NoError(err)
+		data, err := os.ReadFile(reportFile)
+		require.NoError(err)
+		report := &relocator.Report{}
+		require.NoError(json.Unmarshal(data, report))
+		for i, img := range images {
+			expected := fmt.Sprintf("%s/%s", relocateURL, img.Image)
+			if img.Name == mapped.Name {
+				expected = fmt.Sprintf("%s/team-a/%s:%s", relocateURL, mapped.Name, tag)
+			}
+			suite.Assert().Equal(expected, lock.Images[i].Image)
+			suite.Assert().Equal(expected, report.Images[i].Target)
+		}
+
+		require.NoError(os.WriteFile(repoMap, []byte("- invalid\n"), 0644))
+		dt("charts", "relocate", originChart, relocateURL, "--repo-map", repoMap).AssertErrorMatch(t, "failed to parse repository map")
+	})
+	suite.T().Run("Relocate Helm chart with a repository strategy", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		dt("charts", "relocate", originChart, relocateURL, "--repository-strategy", "flat").AssertSuccess(t)
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(originChart, "Images.lock"))
+		require.NoError(err)
+		for i, img := range images {
+			suite.Assert().Equal(fmt.Sprintf("%s/%s", relocateURL, filepath.Base(img.Image)), lock.Images[i].Image)
+		}
+		dt("charts", "relocate", originChart, relocateURL, "--repository-strategy", "deep").AssertErrorMatch(t, "unknown repository strategy")
+	})
+	suite.T().Run("Relocate Helm chart showing the diff", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		lockFile := filepath.Join(originChart, "Images.lock")
+		originalLock, err := os.ReadFile(lockFile)
+		require.NoError(err)
+
+		res := dt("charts", "relocate", originChart, relocateURL, "--diff")
+		res.AssertSuccess(t)
+		suite.Assert().Contains(res.stdout, "--- a/Images.lock\n+++ b/Images.lock\n")
+		for _, img := range images {
+			suite.Assert().Contains(res.stdout, fmt.Sprintf("-    image: %s/%s\n", serverURL, img.Image))
+			suite.Assert().Contains(res.stdout, fmt.Sprintf("+    image: %s/%s\n", relocateURL, img.Image))
+		}
+
+		// The chart is left untouched
+		lockData, err := os.ReadFile(lockFile)
+		require.NoError(err)
+		suite.Assert().Equal(string(originalLock), string(lockData))
+
+		dt("charts", "relocate", originChart, relocateURL, "--diff", "--sign-key", "ops@example.com").AssertErrorMatch(t, "--diff cannot be used with --sign-key")
+	})
+	suite.T().Run("Relocate Helm chart interactively", func(t *testing.T) {
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		// There is no terminal to answer the review questions
+		dt("charts", "relocate", originChart, "custom.repo.example.com", "--interactive").AssertErrorMatch(t, "relocation review cancelled")
+	})
+	suite.T().Run("Relocate Helm chart writing a report", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		reportsDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+
+		jsonReport := filepath.Join(reportsDir, "report.json")
+		dt("charts", "relocate", originChart, relocateURL, "--report-file", jsonReport).AssertSuccess(t)
+		data, err := os.ReadFile(jsonReport)
+		require.NoError(err)
+		report := &relocator.Report{}
+		require.NoError(json.Unmarshal(data, report))
+		require.Len(report.Images, len(images))
+		for i, img := range images {
+			suite.Assert().Equal(fmt.Sprintf("%s/%s", serverURL, img.Image), report.Images[i].Source)
+			suite.Assert().Equal(fmt.Sprintf("%s/%s", relocateURL, img.Image), report.Images[i].Target)
+		}
+
+		// The chart is already relocated, so the report maps the relocated images to themselves
+		csvReport := filepath.Join(reportsDir, "report.csv")
+		dt("charts", "relocate", originChart, relocateURL, "--report-file", csvReport).AssertSuccess(t)
+		data, err = os.ReadFile(csvReport)
+		require.NoError(err)
+		suite.Assert().Regexp(fmt.Sprintf(`(?m)^chart,name,source,target,arch,digest\n%s,`, chartName), string(data))
+	})
+	suite.T().Run("Relocate Helm chart writing a kbld config", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		kbldFile := filepath.Join(sb.TempFile(), "kbld.yml")
+		require.NoError(os.MkdirAll(filepath.Dir(kbldFile), 0755))
+
+		dt("charts", "relocate", originChart, relocateURL, "--kbld-config", kbldFile).AssertSuccessMatch(t, "kbld config written to")
+		data, err := os.ReadFile(kbldFile)
+		require.NoError(err)
+		suite.Assert().Contains(string(data), "apiVersion: kbld.k14s.io/v1alpha1\nkind: Config\n")
+		for _, img := range images {
+			suite.Assert().Contains(string(data), fmt.Sprintf("  - image: %s/%s\n    newImage: %s/%s\n    preresolved: true\n", serverURL, img.Image, relocateURL, img.Image))
+		}
+	})
+	suite.T().Run("Relocate Helm chart emitting deployment files", func(t *testing.T) {
+		relocateURL := "custom.repo.example.com"
+		originChart := renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		emitDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		repository, tag, _ := strings.Cut(images[0].Image, ":")
+		require.NoError(os.WriteFile(filepath.Join(originChart, "values.yaml"), []byte(fmt.Sprintf(
+			"replicaCount: 1\nimage:\n  registry: %s\n  repository: %s\n  tag: %s\n", serverURL, repository, tag)), 0644))
+
+		dt("charts", "relocate", originChart, relocateURL, "--emit", "flux-v0", "--emit-dir", emitDir).AssertErrorMatch(t, `unsupported --emit kind "flux-v0"`)
+
+		dt("charts", "relocate", originChart, relocateURL, "--emit", "kustomize", "--emit-dir", emitDir).AssertSuccessMatch(t, "kustomize file written to")
+		data, err := os.ReadFile(filepath.Join(emitDir, "kustomization.yaml"))
+		require.NoError(err)
+		suite.Assert().Contains(string(data), "kind: Kustomization\nimages:\n")
+		for _, img := range images {
+			name := strings.Split(img.Image, ":")[0]
+			suite.Assert().Contains(string(data), fmt.Sprintf("  - name: %s/%s\n    newName: %s/%s\n    newTag: ", serverURL, name, relocateURL, name))
+		}
+
+		// The chart is already relocated, so emit from a fresh copy
+		originChart = renderLockedChart(sb.TempFile(), scenarioName, serverURL)
+		require.NoError(os.WriteFile(filepath.Join(originChart, "values.yaml"), []byte(fmt.Sprintf(
+			"replicaCount: 1\nimage:\n  registry: %s\n  repository: %s\n  tag: %s\n", serverURL, repository, tag)), 0644))
+		dt("charts", "relocate", originChart, relocateURL, "--emit", "values,flux", "--emit-dir", emitDir).AssertSuccess(t)
+		data, err = os.ReadFile(filepath.Join(emitDir, "relocated-values.yaml"))
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("image:\n  registry: %s\n", relocateURL), string(data))
+		data, err = os.ReadFile(filepath.Join(emitDir, "helmrelease.yaml"))
+		require.NoError(err)
+		suite.Assert().Contains(string(data), "apiVersion: helm.toolkit.fluxcd.io/v2\nkind: HelmRelease\nmetadata:\n  name: test\n")
+		suite.Assert().Contains(string(data), fmt.Sprintf("  values:\n    image:\n      registry: %s\n", relocateURL))
+	})
+}
+
+func (suite *CmdSuite) TestRelocatePinningDigests() {
+	t := suite.T()
+	require := suite.Require()
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+	require.NoError(err)
+
+	dest := suite.sb.TempFile()
+	require.NoError(tu.RenderScenario("../../testdata/scenarios/complete-chart", dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+	))
+	chartDir := filepath.Join(dest, "complete-chart")
+	valuesFile := filepath.Join(chartDir, "values.yaml")
+	require.NoError(os.WriteFile(valuesFile, []byte(fmt.Sprintf("image: %s/test:mytag\n", serverURL)), 0644))
+
+	relocateURL := fmt.Sprintf("%s/relocated", serverURL)
+	t.Run("Requires the images to be pulled", func(t *testing.T) {
+		dt("charts", "relocate", chartDir, relocateURL, "--pin-digests").AssertErrorMatch(t, `pull the images first`)
+	})
+	t.Run("Pins the pushed images digests", func(t *testing.T) {
+		dt("images", "pull", chartDir).AssertSuccess(t)
+		dt("charts", "relocate", chartDir, relocateURL, "--pin-digests").AssertSuccess(t)
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		indexDigest, err := chartutils.ImageIndexDigest(lock.Images[0], filepath.Join(chartDir, "images"))
+		require.NoError(err)
+
+		data, err := readYamlFile(valuesFile)
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("%s/test@%s", relocateURL, indexDigest), data["image"])
+
+		// The pushed image is the one referenced by the pinned digest
+		dt("images", "push", chartDir).AssertSuccess(t)
+		pushedDigest, err := crane.Digest(fmt.Sprintf("%s/test:mytag", relocateURL))
+		require.NoError(err)
+		suite.Assert().Equal(indexDigest.String(), pushedDigest)
+	})
+}
+
+func (suite *CmdSuite) TestRelocateTagStrategies() {
+	t := suite.T()
+	require := suite.Require()
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+
+	images, err := tu.AddSampleImagesToRegistry("test:mytag", serverURL)
+	require.NoError(err)
+
+	createPulledChart := func() string {
+		dest := suite.sb.TempFile()
+		require.NoError(tu.RenderScenario("../../testdata/scenarios/complete-chart", dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+		))
+		chartDir := filepath.Join(dest, "complete-chart")
+		dt("images", "pull", chartDir).AssertSuccess(t)
+		return chartDir
+	}
+
+	t.Run("Pushes the images by digest only", func(t *testing.T) {
+		chartDir := createPulledChart()
+		relocateURL := fmt.Sprintf("%s/by-digest", serverURL)
+		dt("charts", "relocate", chartDir, relocateURL, "--tag-strategy", "digest").AssertSuccess(t)
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		indexDigest, err := chartutils.ImageIndexDigest(lock.Images[0], filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("%s/test@%s", relocateURL, indexDigest), lock.Images[0].Image)
+
+		dt("images", "push", chartDir).AssertSuccess(t)
+		pushedDigest, err := crane.Digest(lock.Images[0].Image)
+		require.NoError(err)
+		suite.Assert().Equal(indexDigest.String(), pushedDigest)
+		_, err = crane.Digest(fmt.Sprintf("%s/test:mytag", relocateURL))
+		suite.Assert().Error(err, "the image should not be tagged")
+
+		// The relocated chart images can be pulled again
+		require.NoError(os.RemoveAll(filepath.Join(chartDir, "images")))
+		dt("images", "pull", chartDir).AssertSuccess(t)
+	})
+	t.Run("Retags the images with the chart version", func(t *testing.T) {
+		chartDir := createPulledChart()
+		relocateURL := fmt.Sprintf("%s/by-chart", serverURL)
+		dt("charts", "relocate", chartDir, relocateURL, "--tag-strategy", "chart").AssertSuccess(t)
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(err)
+		suite.Assert().Equal(fmt.Sprintf("%s/test:test-1.0.0", relocateURL), lock.Images[0].Image)
+
+		dt("images", "push", chartDir).AssertSuccess(t)
+		indexDigest, err := chartutils.ImageIndexDigest(lock.Images[0], filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		pushedDigest, err := crane.Digest(lock.Images[0].Image)
+		require.NoError(err)
+		suite.Assert().Equal(indexDigest.String(), pushedDigest)
+	})
+	t.Run("Rejects unknown strategies", func(t *testing.T) {
+		dt("charts", "relocate", createPulledChart(), serverURL, "--tag-strategy", "latest").AssertErrorMatch(t, "unknown tag strategy")
+	})
+}
+
+func TestReviewRepositories(t *testing.T) {
+	report := &relocator.Report{Images: []relocator.ImageMapping{
+		{Source: "docker.io/bitnami/mariadb:11.0.2", Target: "acme.com/federal/bitnami/mariadb:11.0.2"},
+		{Source: "docker.io/bitnami/os-shell:11", Target: "acme.com/federal/bitnami/os-shell:11"},
+		{Source: "docker.io/bitnami/os-shell:12", Target: "acme.com/federal/bitnami/os-shell:12"},
+		{Source: "docker.io/bitnami/mysqld-exporter:0.15.0", Target: "acme.com/federal/bitnami/mysqld-exporter:0.15.0"},
+	}}
+	t.Run("Accepts, edits and skips the repositories", func(t *testing.T) {
+		m := make(relocator.RepositoryMap)
+		out := &strings.Builder{}
+		// The invalid answers are asked again
+		input := "\nx\ne\nInvalid Target\ne\nacme.com/tools/os-shell\ns\n"
+		if err := reviewRepositories(strings.NewReader(input), out, report, m); err != nil {
+			t.Fatal(err)
+		}
+		expected := relocator.RepositoryMap{
+			"index.docker.io/bitnami/os-shell":        "acme.com/tools/os-shell",
+			"index.docker.io/bitnami/mysqld-exporter": "index.docker.io/bitnami/mysqld-exporter",
+		}
+		if fmt.Sprint(m) != fmt.Sprint(expected) {
+			t.Errorf("expected %v, got %v", expected, m)
+		}
+		// Repositories are only reviewed once
+		if count := strings.Count(out.String(), "-> acme.com/federal/bitnami/os-shell"); count != 1 {
+			t.Errorf("expected the os-shell repository to be reviewed once, got %d", count)
+		}
+		for _, msg := range []string{`Unknown answer "x"`, "Invalid target"} {
+			if !strings.Contains(out.String(), msg) {
+				t.Errorf("expected %q in the output %q", msg, out.String())
+			}
+		}
+	})
+	t.Run("Cancels on missing answers", func(t *testing.T) {
+		err := reviewRepositories(strings.NewReader("a\n"), io.Discard, report, make(relocator.RepositoryMap))
+		if err == nil || !strings.Contains(err.Error(), "relocation review cancelled") {
+			t.Errorf("expected the review to be cancelled, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+// imagesReplication defines the additional registries the images of a chart are pushed into
+type imagesReplication struct {
+	targets []string
+	// lock lists the original images of the chart
+	lock         *imagelock.ImagesLock
+	imagesDir    string
+	relocateOpts []relocator.RelocateOption
+}
+
+// newImagesReplication returns the replication of the images of the chart in chartPath into targets, relocated
+// with settings, or nil if there are no targets
+func newImagesReplication(chartPath string, targets []string, settings relocateSettings) (*imagesReplication, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %w", err)
+	}
+	opts, err := settings.options(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	return &imagesReplication{targets: targets, lock: lock, imagesDir: chart.ImagesDir(), relocateOpts: opts}, nil
+}
+
+// push pushes the images into each of the targets. All the targets are tried, returning the results of each of them
+func (r *imagesReplication) push(ctx context.Context, l log.SectionLogger, handlers ...chartutils.ImageEventHandler) ([]targetSummary, error) {
+	// Each target relocates its own copy of the lock
+	lockData := &bytes.Buffer{}
+	if err := r.lock.ToYAML(lockData); err != nil {
+		return nil, fmt.Errorf("failed to serialize Images.lock: %w", err)
+	}
+	results := make([]targetSummary, 0, len(r.targets))
+	var allErrors error
+	for _, target := range r.targets {
+		result := targetSummary{Registry: target}
+		if err := l.Section(fmt.Sprintf("Replicating images into %q", target), func(subLog log.SectionLogger) error {
+			if err := pushRelocatedImages(ctx, lockData.Bytes(), r.imagesDir, target, r.relocateOpts, subLog, handlers...); err != nil {
+				return subLog.Failf("Failed to push images: %w", err)
+			}
+			subLog.Infof("Images pushed successfully")
+			return nil
+		}); err != nil {
+			result.Error = err.Error()
+			allErrors = errors.Join(allErrors, fmt.Errorf("failed to replicate images into %q: %w", target, err))
+		} else {
+			result.ImagesPushed = len(r.lock.Images)
+		}
+		results = append(results, result)
+	}
+	return results, allErrors
+}
+
+// pushRelocatedImages pushes the images of the lock serialized in lockData, relocated into target
+func pushRelocatedImages(ctx context.Context, lockData []byte, imagesDir string, target string,
+	relocateOpts []relocator.RelocateOption, l log.SectionLogger, handlers ...chartutils.ImageEventHandler) error {
+	lock, err := imagelock.FromYAML(bytes.NewReader(lockData))
+	if err != nil {
+		return fmt.Errorf("failed to load Images.lock: %w", err)
+	}
+	if _, err := relocator.RelocateLock(lock, target, relocateOpts...); err != nil {
+		return err
+	}
+	return chartutils.PushImages(lock, imagesDir, append([]chartutils.Option{
+		chartutils.WithLog(log.SilentLog),
+		chartutils.WithContext(ctx),
+	}, imageTransferOptions(l, handlers...)...)...)
+}
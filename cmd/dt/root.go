@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os/signal"
 	"path/filepath"
 	"syscall"
@@ -11,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"golang.org/x/term"
 )
 
 var rootCmd = newRootCmd()
@@ -27,6 +30,27 @@ var (
 	annotationsKey string = imagelock.DefaultAnnotationsKey
 	logLevel              = "info"
 	usePlainLog           = false
+	logFormat             = "text"
+	progressMode          = barProgress
+	logFile               = ""
+	noColor               = false
+	maxRetries            = 3
+	// logFileWriter receives the debug-level logs when --log-file is provided
+	logFileWriter io.Writer
+
+	allowedRegistries []string
+	blockedRegistries []string
+
+	// useLocalDaemon reads the images missing from their registries from the local Docker daemon
+	useLocalDaemon bool
+	// usePodman reads the local daemon images from the podman API socket instead of the Docker daemon
+	usePodman bool
+
+	// untaggedPolicy defines how the images specifying neither a tag nor a digest are handled
+	untaggedPolicy = string(imagelock.UntaggedAssumeLatest)
+
+	// maxRequestsPerHost bounds the number of concurrent requests to each registry, if positive
+	maxRequestsPerHost int
 )
 
 func newRootCmd() *cobra.Command {
@@ -35,17 +59,69 @@ func newRootCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			_ = cmd.Help()
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyEnvironment(cmd); err != nil {
+				return err
+			}
+			if err := applyConfigFile(cmd); err != nil {
+				return err
+			}
+			if err := setupCredentials(cmd); err != nil {
+				return err
+			}
+			if err := setupTransport(); err != nil {
+				return err
+			}
+			if err := setupMirrors(); err != nil {
+				return err
+			}
+			if _, err := imagelock.ParseUntaggedPolicy(untaggedPolicy); err != nil {
+				return err
+			}
+			return setupLogging(cmd)
+		},
 	}
+	cmd.PersistentFlags().StringVar(&configFile, "config", configFile, "configuration file defining defaults for the flags, by flag name")
 	cmd.PersistentFlags().BoolVar(&insecure, "insecure", insecure, "skip TLS verification")
-
-	cmd.PersistentFlags().StringVar(&annotationsKey, "annotations-key", annotationsKey, "annotation key used to define the list of included images")
+	cmd.PersistentFlags().StringSliceVar(&caFiles, "ca-file", caFiles, "CA certificates file used to verify the registries certificates, in addition to the system ones (can be repeated)")
+	cmd.PersistentFlags().StringVar(&caDir, "ca-dir", caDir, "directory with CA certificates files (*.crt, *.pem, *.cert) used to verify the registries certificates")
+	cmd.PersistentFlags().StringArrayVar(&registryCAFiles, "registry-ca-file", registryCAFiles, "CA certificates file used to verify the certificate of a specific registry, in the registry=file format (can be repeated)")
+	cmd.PersistentFlags().StringVar(&clientCert, "client-cert", clientCert, "client certificate file used to authenticate against the registries requiring mutual TLS")
+	cmd.PersistentFlags().StringVar(&clientKey, "client-key", clientKey, "private key file of the --client-cert certificate")
+	cmd.PersistentFlags().StringArrayVar(&registryClientCerts, "registry-client-cert", registryClientCerts, "client certificate file used to authenticate against a specific registry, in the registry=file format (can be repeated)")
+	cmd.PersistentFlags().StringArrayVar(&registryClientKeys, "registry-client-key", registryClientKeys, "private key file of the --registry-client-cert certificate of a specific registry, in the registry=file format (can be repeated)")
+	cmd.PersistentFlags().StringVar(&proxyURL, "proxy", proxyURL, "proxy used to access the registries, either HTTP (http://host:port) or SOCKS (socks5://host:port). Defaults to the HTTPS_PROXY and HTTP_PROXY environment variables")
+	cmd.PersistentFlags().StringVar(&noProxy, "no-proxy", noProxy, "comma-separated list of hosts, domains (.example.com) or CIDRs accessed without the proxy. Defaults to the NO_PROXY environment variable")
+	cmd.PersistentFlags().StringArrayVar(&mirrorSettings, "registry-mirror", mirrorSettings, "mirror images of a registry are read from before trying the registry itself, in the registry=mirror format, where the mirror can include a repository prefix (can be repeated)")
+	cmd.PersistentFlags().StringVar(&hostsDir, "registry-hosts-dir", hostsDir, "containerd hosts directory (for example /etc/containerd/certs.d) to read the registries mirrors from")
+	cmd.PersistentFlags().StringVar(&registryUsername, "username", registryUsername, "username used to authenticate against the registry the images or charts are pushed to, or the one set with --registry-host, instead of the docker credentials")
+	cmd.PersistentFlags().StringVar(&registryPassword, "password", registryPassword, "password used to authenticate against the registries, along with --username")
+	cmd.PersistentFlags().BoolVar(&registryPasswordStdin, "password-stdin", registryPasswordStdin, "read the registries password from stdin")
+	cmd.PersistentFlags().StringVar(&registryHost, "registry-host", registryHost, "registry --username and --password apply to, in addition to the one the images or charts are pushed to")
+	cmd.PersistentFlags().StringArrayVar(&registryCreds, "creds", registryCreds, "credentials for a specific registry, in the registry=user:pass format (can be repeated). They take precedence over --username and --password")
+
+	cmd.PersistentFlags().StringSliceVar(&allowedRegistries, "allowed-registries", allowedRegistries, "only allow images from the given registries or repository prefixes when locking and pulling")
+	cmd.PersistentFlags().StringSliceVar(&blockedRegistries, "blocked-registries", blockedRegistries, "reject images from the given registries or repository prefixes when locking and pulling")
+
+	cmd.PersistentFlags().BoolVar(&useLocalDaemon, "local-daemon", useLocalDaemon, "read the images missing from their registries, such as freshly built ones, from the local Docker daemon when locking and pulling")
+	cmd.PersistentFlags().BoolVar(&usePodman, "podman", usePodman, "read the --local-daemon images from the podman API socket (CONTAINER_HOST or the default podman socket) instead of the Docker daemon")
+
+	cmd.PersistentFlags().StringVar(&untaggedPolicy, "untagged-images", untaggedPolicy, fmt.Sprintf("how the annotated images specifying neither a tag nor a digest are handled: %v", imagelock.UntaggedPolicies))
+	cmd.PersistentFlags().StringVar(&annotationsKey, "annotations-key", annotationsKey, "comma-separated list of annotation keys, in fallback order, used to define the list of included images")
 
 	cmd.PersistentFlags().StringVar(&logLevel, "log-level", logLevel, "set log level: (debug, info, warn, error, fatal, panic)")
-	cmd.PersistentFlags().BoolVar(&usePlainLog, "plain", usePlainLog, "suppress the progress bar and symbols in messages and display only plain log messages")
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormat, "set log format: (text, json). The json format writes a JSON document per message to stderr, including the section names and steps durations")
+	cmd.PersistentFlags().StringVar(&logFile, "log-file", logFile, "also write the logs into the given file, at debug level regardless of --log-level")
+	cmd.PersistentFlags().BoolVar(&usePlainLog, "plain", usePlainLog, "suppress the progress bar and symbols in messages and display only plain log messages (enabled by default when stdout is not a terminal, use --plain=false to disable it)")
+	cmd.PersistentFlags().BoolVar(&noColor, "no-color", noColor, "disable colors in the output (also disabled if the NO_COLOR environment variable is set)")
+	cmd.PersistentFlags().StringVar(&progressMode, "progress", progressMode, "progress reporting when pulling and pushing images: bar, or json to emit newline-delimited JSON events to stderr")
+	cmd.PersistentFlags().StringArrayVar(&beforePullHooks, "before-pull", beforePullHooks, "command run with the shell before pulling the images of a chart, with DT_HOOK_* environment variables describing the chart and its images (can be repeated)")
+	cmd.PersistentFlags().StringArrayVar(&afterPushHooks, "after-push", afterPushHooks, "command run with the shell after pushing the images of a chart, with DT_HOOK_* environment variables describing the chart and its images (can be repeated)")
+	cmd.PersistentFlags().IntVar(&maxRetries, "max-retries", maxRetries, "number of times pulling or pushing an image or chart is retried on error")
+	cmd.PersistentFlags().IntVar(&maxRequestsPerHost, "max-requests-per-host", maxRequestsPerHost, "maximum number of concurrent requests to each registry when pulling and pushing images, shared by all the images (0 for no limit)")
 	cmd.PersistentFlags().BoolVar(&keepArtifacts, "keep-artifacts", keepArtifacts, "keep temporary artifacts created during the tool execution")
-
-	// Do not show completion command
-	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.PersistentFlags().StringVar(&imagesCache, "images-cache", imagesCache, "directory where the pulled images are kept and reused across runs, managed with the cache commands")
+	cmd.PersistentFlags().StringVar(&workDir, "work-dir", workDir, "directory where the charts are fetched and extracted and the images staged, instead of the system temporary directory")
 
 	cmd.AddCommand(chartCmd)
 	cmd.AddCommand(imagesCmd)
@@ -54,25 +130,81 @@ func newRootCmd() *cobra.Command {
 	return cmd
 }
 
+// setupLogging validates the logging flags and configures the logs output
+func setupLogging(cmd *cobra.Command) error {
+	if logFormat != "text" && logFormat != "json" {
+		return fmt.Errorf("unsupported log format %q", logFormat)
+	}
+	if !cmd.Flags().Changed("plain") && !term.IsTerminal(int(os.Stdout.Fd())) {
+		usePlainLog = true
+	}
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		log.DisableColors()
+	}
+	if logFile != "" {
+		fh, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		logFileWriter = fh
+	}
+	return validateProgressMode(progressMode)
+}
+
 func getAnnotationsKey() string {
 	return annotationsKey
 }
 
+func getUntaggedPolicy() imagelock.UntaggedPolicy {
+	return imagelock.UntaggedPolicy(untaggedPolicy)
+}
+
+func getRegistryFilter() *imagelock.RegistryFilter {
+	return &imagelock.RegistryFilter{Allowed: allowedRegistries, Blocked: blockedRegistries}
+}
+
 func getLogger() log.SectionLogger {
 	var l log.SectionLogger
-	if usePlainLog {
+	if logFormat == "json" {
+		l = log.NewJSONSectionLogger()
+	} else if usePlainLog {
 		l = log.NewLogrusSectionLogger()
 	} else {
 		l = log.NewPtermSectionLogger()
 	}
-	lvl, err := log.ParseLevel(logLevel)
-
-	if err != nil {
+	if lvl, err := log.ParseLevel(logLevel); err != nil {
 		l.Warnf("Invalid log level %s: %v", logLevel, err)
-		return l
+	} else {
+		l.SetLevel(lvl)
 	}
 
-	l.SetLevel(lvl)
+	if logFileWriter != nil {
+		return log.NewTeeSectionLogger(l, getFileLogger(logFileWriter))
+	}
+	return l
+}
+
+// getQuietLogger returns a logger that only reports errors, into stderr
+func getQuietLogger() log.SectionLogger {
+	l := log.NewLogrusSectionLogger()
+	l.SetWriter(os.Stderr)
+	l.SetLevel(log.ErrorLevel)
+	if logFileWriter != nil {
+		return log.NewTeeSectionLogger(l, getFileLogger(logFileWriter))
+	}
+	return l
+}
+
+// getFileLogger returns the logger writing the full debug logs into w
+func getFileLogger(w io.Writer) log.SectionLogger {
+	var l log.SectionLogger
+	if logFormat == "json" {
+		l = log.NewJSONSectionLogger()
+	} else {
+		l = log.NewLogrusSectionLogger()
+	}
+	l.SetWriter(w)
+	l.SetLevel(log.DebugLevel)
 	return l
 }
 
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+)
+
+const (
+	// releasesRepository is the GitHub repository dt is released from
+	releasesRepository = "vmware-labs/distribution-tooling-for-helm"
+	// releasesProject is the name goreleaser uses in the release assets
+	releasesProject = "distribution-tooling-for-helm"
+)
+
+// ReleaseKeyFingerprint is the fingerprint of the dt release signing key, set at build time. The release
+// checksums are only trusted if signed by it, unless a keyring is explicitly provided
+var ReleaseKeyFingerprint = ""
+
+var selfUpdateCmd = newSelfUpdateCmd()
+
+// releaseAsset is a file attached to a GitHub release
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// githubRelease is the subset of the GitHub release API used to update dt
+type githubRelease struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+// asset returns the download URL of the release asset with the given name
+func (r *githubRelease) asset(name string) (string, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s does not include %q", r.TagName, name)
+}
+
+// selfUpdateSettings defines how dt is updated
+type selfUpdateSettings struct {
+	// APIURL is the GitHub API the releases are read from
+	APIURL string
+	// Version, if not empty, is the version to install instead of the latest one
+	Version string
+	// CheckOnly only reports whether a newer version is available
+	CheckOnly bool
+	// Force installs the release even if it is not newer than the running version
+	Force bool
+	// InstallPath is the binary replaced, instead of the running one
+	InstallPath string
+	// SkipSignature installs the release without verifying the GPG signature of its checksums
+	SkipSignature bool
+	// Keyring, if not empty, is the public keyring trusted to verify the checksums, instead of the
+	// release signing key
+	Keyring string
+}
+
+// fetchRelease returns the release to update to: the requested version or the latest one
+func fetchRelease(ctx context.Context, settings *selfUpdateSettings) (*githubRelease, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(settings.APIURL, "/"), releasesRepository)
+	if settings.Version != "" {
+		endpoint = fmt.Sprintf("%s/repos/%s/releases/tags/v%s", strings.TrimSuffix(settings.APIURL, "/"), releasesRepository, strings.TrimPrefix(settings.Version, "v"))
+	}
+	var sb strings.Builder
+	if err := downloadObject(ctx, endpoint, &sb); err != nil {
+		return nil, fmt.Errorf("failed to fetch release: %w", err)
+	}
+	release := &githubRelease{}
+	if err := json.Unmarshal([]byte(sb.String()), release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	return release, nil
+}
+
+// isNewerVersion returns true if the release version is newer than the current one. Development builds,
+// whose version cannot be parsed, are always considered outdated
+func isNewerVersion(release string, current string) (bool, error) {
+	releaseVersion, err := semver.NewVersion(release)
+	if err != nil {
+		return false, fmt.Errorf("invalid release version %q: %w", release, err)
+	}
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return true, nil
+	}
+	return releaseVersion.GreaterThan(currentVersion), nil
+}
+
+// releaseArchiveName returns the name of the release archive for the running platform, as goreleaser names it
+func releaseArchiveName(version string) string {
+	arch := runtime.GOARCH
+	if arch == "arm" {
+		arch = "armv6"
+	}
+	ext := ".tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("%s_%s_%s_%s%s", releasesProject, version, runtime.GOOS, arch, ext)
+}
+
+// releaseChecksum returns the SHA256 checksum of file listed in the checksums file, in the sha256sum format
+func releaseChecksum(checksumsFile string, file string) (string, error) {
+	data, err := os.ReadFile(checksumsFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksums: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == file {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum found for %q", file)
+}
+
+// binaryName returns the name of the dt binary in the release archives
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "dt.exe"
+	}
+	return "dt"
+}
+
+// extractBinary returns a reader to the dt binary in the release archive, and a function to release it
+func extractBinary(archive string) (io.Reader, func(), error) {
+	if strings.HasSuffix(archive, ".zip") {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open release archive: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.Name == binaryName() {
+				rc, err := f.Open()
+				if err != nil {
+					zr.Close()
+					return nil, nil, err
+				}
+				return rc, func() { rc.Close(); zr.Close() }, nil
+			}
+		}
+		zr.Close()
+		return nil, nil, fmt.Errorf("%q not found in the release archive", binaryName())
+	}
+	fh, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open release archive: %w", err)
+	}
+	gzr, err := gzip.NewReader(fh)
+	if err != nil {
+		fh.Close()
+		return nil, nil, fmt.Errorf("failed to open release archive: %w", err)
+	}
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			fh.Close()
+			return nil, nil, fmt.Errorf("%q not found in the release archive", binaryName())
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Name == binaryName() {
+			return tr, func() { fh.Close() }, nil
+		}
+	}
+}
+
+// replaceBinary atomically replaces the binary in installPath with the one in the release archive
+func replaceBinary(archive string, installPath string) error {
+	r, done, err := extractBinary(archive)
+	if err != nil {
+		return err
+	}
+	defer done()
+	// Created next to the binary, so it can be renamed over it
+	tmpFile, err := os.CreateTemp(filepath.Dir(installPath), ".dt-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmpFile.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		// Running binaries cannot be overwritten on Windows, but they can be renamed
+		oldFile := installPath + ".old"
+		_ = os.Remove(oldFile)
+		if err := os.Rename(installPath, oldFile); err != nil {
+			return fmt.Errorf("failed to replace binary: %w", err)
+		}
+	}
+	if err := os.Rename(tmpFile.Name(), installPath); err != nil {
+		return fmt.Errorf("failed to replace binary: %w", err)
+	}
+	return nil
+}
+
+// verifyReleaseSignature verifies the detached signature of the release checksums file, returning the signer.
+// Any key in an explicit keyring is trusted. Otherwise, the signer must be the pinned release signing key,
+// looked up in the default public keyring
+func verifyReleaseSignature(checksumsFile string, keyring string, fingerprint string) (string, error) {
+	opts := []signature.Option{signature.WithKeyring(keyring)}
+	if keyring == "" {
+		if fingerprint == "" {
+			return "", fmt.Errorf("this dt build does not pin the release signing key: provide the keyring to verify it with --keyring, or use --skip-signature")
+		}
+		opts = []signature.Option{signature.WithKeyring(signature.DefaultPublicKeyring()), signature.WithFingerprint(fingerprint)}
+	}
+	return signature.VerifyFile(checksumsFile, signature.FileName(checksumsFile), opts...)
+}
+
+// downloadRelease downloads the release archive for the running platform into dir and verifies it,
+// returning the archive file
+func downloadRelease(ctx context.Context, release *githubRelease, version string, dir string, settings *selfUpdateSettings, l log.SectionLogger) (string, error) {
+	archiveName := releaseArchiveName(version)
+	archiveURL, err := release.asset(archiveName)
+	if err != nil {
+		return "", err
+	}
+	checksumsName := fmt.Sprintf("%s_%s_checksums.txt", releasesProject, version)
+	checksumsURL, err := release.asset(checksumsName)
+	if err != nil {
+		return "", err
+	}
+	var archive, checksum, checksumsFile string
+	if err := l.ExecuteStep(fmt.Sprintf("Downloading %q", archiveName), func() error {
+		if checksumsFile, _, err = downloadFile(ctx, checksumsURL, dir); err != nil {
+			return err
+		}
+		archive, checksum, err = downloadFile(ctx, archiveURL, dir)
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to download release: %w", err)
+	}
+	if !settings.SkipSignature {
+		sigURL, err := release.asset(signature.FileName(checksumsName))
+		if err != nil {
+			return "", fmt.Errorf("%w: use --skip-signature to install it unverified", err)
+		}
+		if _, _, err := downloadFile(ctx, sigURL, dir); err != nil {
+			return "", fmt.Errorf("failed to download release signature: %w", err)
+		}
+		signer, err := verifyReleaseSignature(checksumsFile, settings.Keyring, ReleaseKeyFingerprint)
+		if err != nil {
+			return "", fmt.Errorf("failed to verify release signature: %w", err)
+		}
+		l.Infof("Release checksums signed by %q", signer)
+	}
+	expected, err := releaseChecksum(checksumsFile, archiveName)
+	if err != nil {
+		return "", err
+	}
+	if expected != checksum {
+		return "", fmt.Errorf("release checksum mismatch: expected %s, got %s", expected, checksum)
+	}
+	l.Infof("Release checksum verified")
+	return archive, nil
+}
+
+// selfUpdate updates dt to the release selected in settings
+func selfUpdate(ctx context.Context, settings *selfUpdateSettings, l log.SectionLogger) error {
+	release, err := fetchRelease(ctx, settings)
+	if err != nil {
+		return err
+	}
+	version := strings.TrimPrefix(release.TagName, "v")
+	newer, err := isNewerVersion(version, Version)
+	if err != nil {
+		return err
+	}
+	if !newer && !settings.Force {
+		l.Infof("dt %s is up to date (latest release is %s)", Version, version)
+		return nil
+	}
+	if settings.CheckOnly {
+		l.Infof("dt %s is available (running %s)", version, Version)
+		return nil
+	}
+	dir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return err
+	}
+	archive, err := downloadRelease(ctx, release, version, dir, settings, l)
+	if err != nil {
+		return err
+	}
+	if err := replaceBinary(archive, settings.InstallPath); err != nil {
+		return err
+	}
+	l.Infof("dt updated to %s in %q", version, settings.InstallPath)
+	return nil
+}
+
+func newSelfUpdateCmd() *cobra.Command {
+	settings := &selfUpdateSettings{
+		APIURL: "https://api.github.com",
+	}
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Updates dt to the latest release",
+		Long:  "Checks the GitHub releases for a newer version of dt and, if available, downloads it for the running platform, verifies the GPG signature of the release checksums and its checksum, and replaces the dt binary with it",
+		Example: `  # Update dt to the latest release
+  $ dt self-update
+
+  # Check whether a newer release is available, without installing it
+  $ dt self-update --check
+
+  # Install a specific release, from a GitHub Enterprise mirror
+  $ dt self-update --version 0.4.0 --github-api-url https://github.example.com/api/v3`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l := getLogger()
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+
+			if settings.InstallPath == "" {
+				exe, err := os.Executable()
+				if err != nil {
+					return l.Failf("failed to determine the dt binary location: %w", err)
+				}
+				if settings.InstallPath, err = filepath.EvalSymlinks(exe); err != nil {
+					return l.Failf("failed to determine the dt binary location: %w", err)
+				}
+			}
+			if err := selfUpdate(ctx, settings, l); err != nil {
+				return l.Failf("%w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&settings.APIURL, "github-api-url", settings.APIURL, "GitHub API URL the releases are read from, such as the one of a GitHub Enterprise mirror")
+	cmd.Flags().StringVar(&settings.Version, "version", settings.Version, "release to install, instead of the latest one")
+	cmd.Flags().BoolVar(&settings.CheckOnly, "check", settings.CheckOnly, "only report whether a newer release is available")
+	cmd.Flags().BoolVar(&settings.Force, "force", settings.Force, "install the release even if it is not newer than the running version")
+	cmd.Flags().StringVar(&settings.InstallPath, "install-path", settings.InstallPath, "binary to replace, instead of the running one")
+	cmd.Flags().BoolVar(&settings.SkipSignature, "skip-signature", settings.SkipSignature, "install the release without verifying the detached GPG signature of its checksums file")
+	cmd.Flags().StringVar(&settings.Keyring, "keyring", settings.Keyring, "public keyring the release checksums are verified with, trusting any key in it, instead of requiring the dt release signing key from the default GnuPG keyring")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+}
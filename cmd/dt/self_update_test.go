@@ -0,0 +1,245 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Same implementation used by Helm provenance files
+)
+
+// fakeReleases serves the GitHub releases API and assets of dt, with a single release
+type fakeReleases struct {
+	s       *httptest.Server
+	version string
+	files   map[string][]byte
+}
+
+func newFakeReleases(version string, binary []byte) (*fakeReleases, error) {
+	r := &fakeReleases{version: version, files: make(map[string][]byte)}
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	if err := tw.WriteHeader(&tar.Header{Name: "dt", Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(binary); err != nil {
+		return nil, err
+	}
+	tw.Close()
+	gzw.Close()
+	archiveName := fmt.Sprintf("%s_%s_%s_%s.tar.gz", releasesProject, version, runtime.GOOS, runtime.GOARCH)
+	r.files[archiveName] = buf.Bytes()
+	sum := sha256.Sum256(buf.Bytes())
+	r.files[r.checksumsName()] = []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), archiveName))
+	r.s = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r, nil
+}
+
+func (r *fakeReleases) checksumsName() string {
+	return fmt.Sprintf("%s_%s_checksums.txt", releasesProject, r.version)
+}
+
+// sign serves the detached signature of the release checksums, signed with the secret keyring secring
+func (r *fakeReleases) sign(dir string, secring string) error {
+	checksumsFile := filepath.Join(dir, r.checksumsName())
+	if err := os.WriteFile(checksumsFile, r.files[r.checksumsName()], 0644); err != nil {
+		return err
+	}
+	if err := signature.SignFile(checksumsFile, signature.FileName(checksumsFile), signature.WithKeyring(secring)); err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(signature.FileName(checksumsFile))
+	if err != nil {
+		return err
+	}
+	r.files[signature.FileName(r.checksumsName())] = sig
+	return nil
+}
+
+func (r *fakeReleases) handle(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case fmt.Sprintf("/repos/%s/releases/latest", releasesRepository), fmt.Sprintf("/repos/%s/releases/tags/v%s", releasesRepository, r.version):
+		release := githubRelease{TagName: "v" + r.version}
+		for name := range r.files {
+			release.Assets = append(release.Assets, releaseAsset{Name: name, URL: fmt.Sprintf("%s/download/%s", r.s.URL, name)})
+		}
+		_ = json.NewEncoder(w).Encode(release)
+	default:
+		data, ok := r.files[filepath.Base(req.URL.Path)]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write(data)
+	}
+}
+
+func (suite *CmdSuite) TestSelfUpdateCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	if runtime.GOOS == "windows" || runtime.GOARCH == "arm" {
+		t.Skip("the fake releases only provide tar.gz archives named after the platform")
+	}
+	newBinary := []byte("#!/bin/sh\necho new dt\n")
+	writeInstalled := func() string {
+		installPath := filepath.Join(sb.TempFile(), "dt")
+		require.NoError(os.MkdirAll(filepath.Dir(installPath), 0755))
+		require.NoError(os.WriteFile(installPath, []byte("old dt"), 0755))
+		return installPath
+	}
+	keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(err)
+	secring, pubring, err := writeKeyrings(keysDir, "releases")
+	require.NoError(err)
+	newSignedReleases := func(version string) *fakeReleases {
+		releases, err := newFakeReleases(version, newBinary)
+		require.NoError(err)
+		require.NoError(releases.sign(keysDir, secring))
+		return releases
+	}
+
+	t.Run("Updates to the latest release", func(t *testing.T) {
+		releases := newSignedReleases("99.0.0")
+		defer releases.s.Close()
+
+		installPath := writeInstalled()
+		dt("self-update", "--keyring", pubring, "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertSuccessMatch(t, `(?s)Release checksums signed by .*releases.*dt updated to 99.0.0`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal(newBinary, data)
+		fi, err := os.Stat(installPath)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0755), fi.Mode().Perm())
+	})
+	t.Run("Only checks for updates", func(t *testing.T) {
+		releases := newSignedReleases("99.0.0")
+		defer releases.s.Close()
+
+		installPath := writeInstalled()
+		dt("self-update", "--check", "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertSuccessMatch(t, `dt 99.0.0 is available`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+	})
+	t.Run("Does nothing if up to date", func(t *testing.T) {
+		releases := newSignedReleases("0.0.1")
+		defer releases.s.Close()
+
+		installPath := writeInstalled()
+		dt("self-update", "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertSuccessMatch(t, `is up to date \(latest release is 0.0.1\)`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+
+		dt("self-update", "--version", "0.0.1", "--force", "--keyring", pubring, "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertSuccessMatch(t, `dt updated to 0.0.1`)
+	})
+	t.Run("Requires the release checksums signature", func(t *testing.T) {
+		releases, err := newFakeReleases("99.0.0", newBinary)
+		require.NoError(err)
+		defer releases.s.Close()
+
+		installPath := writeInstalled()
+		dt("self-update", "--keyring", pubring, "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertErrorMatch(t, `does not include .*checksums.txt.asc.*--skip-signature`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+
+		dt("self-update", "--skip-signature", "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertSuccessMatch(t, `dt updated to 99.0.0`)
+	})
+	t.Run("Fails if the release checksums are not signed by the keyring", func(t *testing.T) {
+		releases := newSignedReleases("99.0.0")
+		defer releases.s.Close()
+
+		dir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		_, otherPubring, err := writeKeyrings(dir, "other")
+		require.NoError(err)
+
+		installPath := writeInstalled()
+		dt("self-update", "--keyring", otherPubring, "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertErrorMatch(t, `failed to verify release signature`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+	})
+	t.Run("Requires a keyring if the release signing key is not pinned", func(t *testing.T) {
+		releases := newSignedReleases("99.0.0")
+		defer releases.s.Close()
+
+		installPath := writeInstalled()
+		dt("self-update", "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertErrorMatch(t, `does not pin the release signing key`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+	})
+	t.Run("Fails on checksum mismatch", func(t *testing.T) {
+		releases, err := newFakeReleases("99.0.0", newBinary)
+		require.NoError(err)
+		defer releases.s.Close()
+		releases.files[releases.checksumsName()] = bytes.Replace(releases.files[releases.checksumsName()], []byte("  "), []byte("00  "), 1)
+		require.NoError(releases.sign(keysDir, secring))
+
+		installPath := writeInstalled()
+		dt("self-update", "--keyring", pubring, "--github-api-url", releases.s.URL, "--install-path", installPath).
+			AssertErrorMatch(t, `release checksum mismatch`)
+		data, err := os.ReadFile(installPath)
+		require.NoError(err)
+		assert.Equal("old dt", string(data))
+	})
+}
+
+func TestVerifyReleaseSignature(t *testing.T) {
+	keysDir := t.TempDir()
+	secring, pubring, err := writeKeyrings(keysDir, "releases")
+	require.NoError(t, err)
+	data, err := os.ReadFile(pubring)
+	require.NoError(t, err)
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	require.NoError(t, err)
+	fingerprint := fmt.Sprintf("%X", entities[0].PrimaryKey.Fingerprint)
+
+	checksumsFile := filepath.Join(t.TempDir(), "checksums.txt")
+	require.NoError(t, os.WriteFile(checksumsFile, []byte("checksums"), 0644))
+	require.NoError(t, signature.SignFile(checksumsFile, signature.FileName(checksumsFile), signature.WithKeyring(secring)))
+
+	// The pinned key is looked up in the default public keyring
+	t.Setenv("GNUPGHOME", keysDir)
+	signer, err := verifyReleaseSignature(checksumsFile, "", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, "releases <releases@example.com>", signer)
+
+	_, err = verifyReleaseSignature(checksumsFile, "", strings.Repeat("0", len(fingerprint)))
+	assert.ErrorContains(t, err, "expected key")
+
+	_, err = verifyReleaseSignature(checksumsFile, "", "")
+	assert.ErrorContains(t, err, "does not pin the release signing key")
+
+	// An explicit keyring trusts any of its keys
+	_, err = verifyReleaseSignature(checksumsFile, pubring, strings.Repeat("0", len(fingerprint)))
+	require.NoError(t, err)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imageserver"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var serveCmd = newServeCmd()
+
+// serveSettings defines how the images are served
+type serveSettings struct {
+	// Addr is the address the registry listens on. It defaults to the loopback interface, as anyone reaching
+	// the registry can pull the images, and fetch new ones with the credentials of the pull-through cache
+	Addr string
+	// TLSCertFile and TLSKeyFile, if set, serve the registry over HTTPS
+	TLSCertFile string
+	TLSKeyFile  string
+	// CacheDir, if set, fetches the images not pulled from their upstream registries, caching them in it
+	CacheDir string
+	// UpstreamRegistry is the upstream registry of the repositories not in the Images.lock
+	UpstreamRegistry string
+}
+
+// options returns the image server options for the settings
+func (settings serveSettings) options() []imageserver.Option {
+	if settings.CacheDir == "" {
+		return nil
+	}
+	return []imageserver.Option{
+		imageserver.WithCacheDir(settings.CacheDir),
+		imageserver.WithDefaultRegistry(settings.UpstreamRegistry),
+		imageserver.WithKeychain(getKeychain()),
+		imageserver.WithTransport(getTransport()),
+	}
+}
+
+// serveImages serves the server images on the listener until ctx is done
+func serveImages(ctx context.Context, ln net.Listener, s *imageserver.Server, settings serveSettings) error {
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	var err error
+	if settings.TLSCertFile != "" {
+		err = srv.ServeTLS(ln, settings.TLSCertFile, settings.TLSKeyFile)
+	} else {
+		err = srv.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// isLoopback returns whether the listener address is only reachable from the local host
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+func newServeCmd() *cobra.Command {
+	settings := serveSettings{Addr: "127.0.0.1:5000"}
+
+	cmd := &cobra.Command{
+		Use:   "serve WRAP|CHART_PATH",
+		Short: "Serves the images of a wrap through a read-only registry",
+		Long: `Serves the container images of a wrap, or of a Helm chart with its images pulled, through a read-only OCI registry API, until interrupted.
+Disconnected clusters can pull the images straight from the transfer host, without deploying a registry. The images are served under their original repository path, so unwrap or relocate the chart using the address of the host as prefix.
+With --pull-through-cache, the images not pulled are fetched from their upstream registries on first request and cached, so hosts with intermittent connectivity serve them from then on.
+The registry does not authenticate its clients, so it only listens on the loopback interface unless an --addr is provided. Anyone reaching it can pull the images, and, with --pull-through-cache, fetch any image the configured credentials can pull`,
+		Example: `  # Serve the images of a wrap on port 5000 of the local host
+  $ dt serve mariadb-12.2.8.wrap.tgz
+
+  # Serve the images to the cluster nodes, on every interface of the host
+  $ dt serve mariadb-12.2.8.wrap.tgz --addr :5000
+
+  # Serve the images over HTTPS
+  $ dt serve mariadb-12.2.8.wrap.tgz --tls-cert server.crt --tls-key server.key
+
+  # Fetch the images not pulled on demand, caching them
+  $ dt serve examples/mariadb --pull-through-cache /var/cache/dt --upstream-registry docker.io`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chartPath := args[0]
+			if !utils.FileExists(chartPath) {
+				return fmt.Errorf("wrap file %q does not exist", chartPath)
+			}
+			if (settings.TLSCertFile == "") != (settings.TLSKeyFile == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be provided together")
+			}
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+			l := getLogger()
+
+			chart, lock, err := loadWrapLock(chartPath)
+			if err != nil {
+				return l.Failf("Failed to load wrap: %w", err)
+			}
+			s, err := imageserver.NewServer(lock, chart.ImagesDir(), settings.options()...)
+			if err != nil {
+				return l.Failf("Failed to load images (pull them first with \"dt images pull\"): %w", err)
+			}
+			ln, err := net.Listen("tcp", settings.Addr)
+			if err != nil {
+				return l.Failf("Failed to listen on %q: %w", settings.Addr, err)
+			}
+			for _, img := range s.Images() {
+				if img.Tag != "" {
+					l.Infof("Serving %q as %s:%s", img.Image, img.Repository, img.Tag)
+				} else {
+					l.Infof("Serving %q as %s@%s", img.Image, img.Repository, img.Digest)
+				}
+			}
+			if settings.CacheDir != "" && !isLoopback(ln.Addr()) {
+				l.Warnf("The pull-through cache fetches images with your registry credentials for any client reaching %s", ln.Addr())
+			}
+			l.Successf("Serving %d images on %s (interrupt to stop)", len(s.Images()), ln.Addr())
+			if err := serveImages(ctx, ln, s, settings); err != nil {
+				return l.Failf("Failed to serve images: %w", err)
+			}
+			l.Infof("Stopped serving images")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&settings.Addr, "addr", settings.Addr, "address the registry listens on. Use :5000 to serve the images on every interface")
+	cmd.Flags().StringVar(&settings.TLSCertFile, "tls-cert", settings.TLSCertFile, "certificate file to serve the registry over HTTPS")
+	cmd.Flags().StringVar(&settings.TLSKeyFile, "tls-key", settings.TLSKeyFile, "private key file of --tls-cert")
+	cmd.Flags().StringVar(&settings.CacheDir, "pull-through-cache", settings.CacheDir, "fetch the images not pulled from their upstream registries on demand, caching them in this directory")
+	cmd.Flags().StringVar(&settings.UpstreamRegistry, "upstream-registry", settings.UpstreamRegistry, "upstream registry of the repositories not in the Images.lock, with --pull-through-cache")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func (suite *CmdSuite) TestServeCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	serverURL := "registry.example.com"
+	scenarioName := "complete-chart"
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	images, err := writeSampleImages("bitnami/test", "mytag", filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(fmt.Sprintf("../../testdata/scenarios/%s", scenarioName), dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+	))
+
+	t.Run("Serves the images until interrupted", func(t *testing.T) {
+		addr := freeAddr(t)
+		// Not running in a terminal, the logs are written to stderr
+		var stderr bytes.Buffer
+		cmd := exec.Command(os.Args[0], "serve", chartDir, "--addr", addr)
+		cmd.Env = append(os.Environ(), "BE_DT=1")
+		cmd.Stderr = &stderr
+		require.NoError(cmd.Start())
+		defer func() { _ = cmd.Process.Kill() }()
+
+		ref := fmt.Sprintf("%s/bitnami/test:mytag", addr)
+		for i := 0; i < 100; i++ {
+			if _, err = crane.Digest(ref, crane.Insecure); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		require.NoError(err)
+		for _, d := range images[0].Digests {
+			img, err := crane.Pull(fmt.Sprintf("%s@%s", ref, d.Digest), crane.Insecure)
+			require.NoError(err)
+			_, err = img.Layers()
+			require.NoError(err)
+		}
+
+		require.NoError(cmd.Process.Signal(syscall.SIGTERM))
+		require.NoError(cmd.Wait())
+		assert.Contains(stderr.String(), "Serving 1 images")
+		assert.Contains(stderr.String(), "bitnami/test:mytag")
+	})
+	t.Run("Handles errors", func(t *testing.T) {
+		dt("serve", sb.TempFile()).AssertErrorMatch(t, "does not exist")
+		dt("serve", chartDir, "--tls-cert", "server.crt").AssertErrorMatch(t, "must be provided together")
+		require.NoError(os.RemoveAll(filepath.Join(chartDir, "images")))
+		dt("serve", chartDir, "--addr", freeAddr(t)).AssertErrorMatch(t, "pull them first")
+	})
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"127.0.0.1:5000": true,
+		"[::1]:5000":     true,
+		":5000":          false,
+		"0.0.0.0:5000":   false,
+		"10.0.0.2:5000":  false,
+	} {
+		tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isLoopback(tcpAddr); got != expected {
+			t.Errorf("isLoopback(%q) = %v, expected %v", addr, got, expected)
+		}
+	}
+}
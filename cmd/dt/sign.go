@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+)
+
+// signOptions returns the signature options to sign with key from keyring, reading
+// the key passphrase from passphraseFile if provided
+func signOptions(key string, keyring string, passphraseFile string) ([]signature.Option, error) {
+	opts := []signature.Option{signature.WithKeyName(key), signature.WithKeyring(keyring)}
+	if passphraseFile != "" {
+		passphrase, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		opts = append(opts, signature.WithPassphrase(bytes.TrimSpace(passphrase)))
+	}
+	return opts, nil
+}
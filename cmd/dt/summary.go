@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
+)
+
+// stageSummary describes the execution of one of the stages of a command
+type stageSummary struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+	Failed     bool   `json:"failed,omitempty"`
+}
+
+// transferSummary accumulates the statistics of the images pulled and pushed
+type transferSummary struct {
+	ImagesPulled    int   `json:"imagesPulled"`
+	ImagesPushed    int   `json:"imagesPushed"`
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+	BytesUploaded   int64 `json:"bytesUploaded"`
+	Retries         int   `json:"retries"`
+	CacheHits       int   `json:"cacheHits"`
+}
+
+// targetSummary describes the images pushed into one of the target registries
+type targetSummary struct {
+	Registry     string `json:"registry"`
+	ImagesPushed int    `json:"imagesPushed"`
+	Error        string `json:"error,omitempty"`
+}
+
+// runSummary collects the stages durations and the transfer statistics of a wrap or unwrap
+type runSummary struct {
+	Command string `json:"command"`
+	// Output is the resulting wrap file or pushed Helm chart URL
+	Output string `json:"output,omitempty"`
+	// Outputs lists the resulting wrap files when wrapping the charts listed in a manifest, and the
+	// subcharts wrapped separately
+	Outputs    []string        `json:"outputs,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+	Stages     []stageSummary  `json:"stages"`
+	Transfers  transferSummary `json:"transfers"`
+	// Targets lists the results of pushing the images into each of the target registries
+	Targets []targetSummary `json:"targets,omitempty"`
+
+	startedOn time.Time
+	mu        sync.Mutex
+}
+
+func newRunSummary(command string) *runSummary {
+	return &runSummary{Command: command, Stages: make([]stageSummary, 0), startedOn: time.Now()}
+}
+
+// stage executes fn as a named stage, recording its duration and tracing it
+func (s *runSummary) stage(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := tracing.Run(ctx, name, fn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Stages = append(s.Stages, stageSummary{Name: name, DurationMs: time.Since(start).Milliseconds(), Failed: err != nil})
+	return err
+}
+
+// handleImageEvent updates the transfer statistics with ev
+func (s *runSummary) handleImageEvent(ev chartutils.ImageEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &s.Transfers
+	switch ev.State {
+	case chartutils.ImageRetrying:
+		t.Retries++
+	case chartutils.ImageCached:
+		t.CacheHits++
+	case chartutils.ImageCompleted:
+		if ev.Operation == "push" {
+			t.ImagesPushed++
+			t.BytesUploaded += ev.Bytes
+		} else {
+			t.ImagesPulled++
+			t.BytesDownloaded += ev.Bytes
+		}
+	}
+}
+
+// addTargets records the results of pushing the images into target registries
+func (s *runSummary) addTargets(targets ...targetSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Targets = append(s.Targets, targets...)
+}
+
+// finish records the total duration of the command
+func (s *runSummary) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DurationMs = time.Since(s.startedOn).Milliseconds()
+}
+
+// show prints the summary in a human-readable way
+func (s *runSummary) show(l log.SectionLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = l.Section("Summary", func(childLog log.SectionLogger) error {
+		childLog.Infof("Total duration: %s", formatDurationMs(s.DurationMs))
+		for _, st := range s.Stages {
+			status := ""
+			if st.Failed {
+				status = " (failed)"
+			}
+			childLog.Infof("Stage %q: %s%s", st.Name, formatDurationMs(st.DurationMs), status)
+		}
+		t := s.Transfers
+		childLog.Infof("Images pulled: %d (%s downloaded, %d reused from previous pulls)",
+			t.ImagesPulled, units.HumanSize(float64(t.BytesDownloaded)), t.CacheHits)
+		childLog.Infof("Images pushed: %d (%s uploaded)", t.ImagesPushed, units.HumanSize(float64(t.BytesUploaded)))
+		childLog.Infof("Retries: %d", t.Retries)
+		showTargets(childLog, s.Targets)
+		return nil
+	})
+}
+
+// showTargets prints the results of pushing the images into each of the target registries
+func showTargets(l log.SectionLogger, targets []targetSummary) {
+	for _, target := range targets {
+		if target.Error != "" {
+			l.Infof("Registry %q: failed (%s)", target.Registry, target.Error)
+			continue
+		}
+		l.Infof("Registry %q: %d images pushed", target.Registry, target.ImagesPushed)
+	}
+}
+
+// reportRunSummary finishes the summary and prints it, in the requested output format
+func reportRunSummary(s *runSummary, format string) error {
+	s.finish()
+	if isStructuredOutput(format) {
+		return writeStructuredOutput(os.Stdout, format, s)
+	}
+	s.show(getLogger())
+	return nil
+}
+
+func formatDurationMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
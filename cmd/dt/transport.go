@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Registries TLS and proxy flags
+var (
+	caFiles         []string
+	caDir           string
+	registryCAFiles []string
+
+	clientCert          string
+	clientKey           string
+	registryClientCerts []string
+	registryClientKeys  []string
+
+	proxyURL string
+	noProxy  string
+)
+
+// registryTransport is the transport used to access the registries, or nil to use the default one
+var registryTransport http.RoundTripper
+
+// hostsTransport is an http.RoundTripper using a specific transport for some hosts
+type hostsTransport struct {
+	defaultTransport http.RoundTripper
+	transports       map[string]http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *hostsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if tr, ok := t.transports[req.URL.Host]; ok {
+		return tr.RoundTrip(req)
+	}
+	return t.defaultTransport.RoundTrip(req)
+}
+
+// getTransport returns the transport used to access the registries, or nil to use the default one
+func getTransport() http.RoundTripper {
+	return registryTransport
+}
+
+// httpClient returns the HTTP client used to download files, honoring the same TLS, proxy and --insecure
+// settings as the registries
+func httpClient() (*http.Client, error) {
+	tr := getTransport()
+	if tr == nil {
+		defaultTransport, err := newTLSTransport(tlsSettings{})
+		if err != nil {
+			return nil, err
+		}
+		tr = defaultTransport
+	}
+	return &http.Client{Transport: tr}, nil
+}
+
+// parseRegistrySetting parses a setting in the registry=value format, returning the normalized registry
+func parseRegistrySetting(flagName string, setting string) (string, string, error) {
+	registry, value, found := strings.Cut(setting, "=")
+	if !found || registry == "" || value == "" {
+		return "", "", fmt.Errorf("invalid --%s %q: expected the registry=value format", flagName, setting)
+	}
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid registry %q in --%s: %v", registry, flagName, err)
+	}
+	return reg.RegistryStr(), value, nil
+}
+
+// caDirFiles returns the certificate files in dir
+func caDirFiles(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read CA directory: %w", err)
+	}
+	files := make([]string, 0)
+	for _, pattern := range []string{"*.crt", "*.pem", "*.cert"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// newCertPool returns the system certificates pool with the certificates in files added
+func newCertPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in CA file %q", file)
+		}
+	}
+	return pool, nil
+}
+
+// tlsSettings defines the TLS configuration used to access a registry
+type tlsSettings struct {
+	caFiles []string
+	// certFile and keyFile, if not empty, are the client certificate and key used to authenticate
+	certFile string
+	keyFile  string
+}
+
+// withDefaults returns the settings with the default CA files added, and the default client certificate
+// if none was provided
+func (s tlsSettings) withDefaults(defaults tlsSettings) tlsSettings {
+	s.caFiles = append(append([]string{}, defaults.caFiles...), s.caFiles...)
+	if s.certFile == "" && s.keyFile == "" {
+		s.certFile, s.keyFile = defaults.certFile, defaults.keyFile
+	}
+	return s
+}
+
+// proxyFunc returns the function selecting the proxy for each request: the one provided with --proxy
+// or, otherwise, the one set in the HTTPS_PROXY and HTTP_PROXY environment variables, except for the
+// hosts in --no-proxy or, if not provided, in the NO_PROXY environment variable
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	cfg := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q: use http, https, socks5 or socks5h", u.Scheme)
+		}
+		cfg.HTTPProxy, cfg.HTTPSProxy = proxyURL, proxyURL
+	}
+	if noProxy != "" {
+		cfg.NoProxy = noProxy
+	}
+	fn := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}, nil
+}
+
+// newTLSTransport returns a clone of the default transport using the provided TLS settings
+func newTLSTransport(settings tlsSettings) (*http.Transport, error) {
+	tr := remote.DefaultTransport.(*http.Transport).Clone()
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	tr.Proxy = proxy
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure} // #nosec G402
+	if len(settings.caFiles) > 0 {
+		pool, err := newCertPool(settings.caFiles)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if settings.certFile != "" || settings.keyFile != "" {
+		if settings.certFile == "" || settings.keyFile == "" {
+			return nil, fmt.Errorf("both the client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.certFile, settings.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	tr.TLSClientConfig = tlsConfig
+	return tr, nil
+}
+
+// registriesTLSSettings returns the TLS settings provided for specific registries
+func registriesTLSSettings() (map[string]*tlsSettings, error) {
+	settings := make(map[string]*tlsSettings)
+	get := func(registry string) *tlsSettings {
+		if _, ok := settings[registry]; !ok {
+			settings[registry] = &tlsSettings{}
+		}
+		return settings[registry]
+	}
+	for _, flag := range []struct {
+		name   string
+		values []string
+		set    func(s *tlsSettings, file string)
+	}{
+		{"registry-ca-file", registryCAFiles, func(s *tlsSettings, file string) { s.caFiles = append(s.caFiles, file) }},
+		{"registry-client-cert", registryClientCerts, func(s *tlsSettings, file string) { s.certFile = file }},
+		{"registry-client-key", registryClientKeys, func(s *tlsSettings, file string) { s.keyFile = file }},
+	} {
+		for _, value := range flag.values {
+			registry, file, err := parseRegistrySetting(flag.name, value)
+			if err != nil {
+				return nil, err
+			}
+			flag.set(get(registry), file)
+		}
+	}
+	return settings, nil
+}
+
+// setupTransport validates the registries TLS and proxy flags and builds the transport used to access the registries
+func setupTransport() error {
+	defaults := tlsSettings{caFiles: append([]string{}, caFiles...), certFile: clientCert, keyFile: clientKey}
+	if caDir != "" {
+		files, err := caDirFiles(caDir)
+		if err != nil {
+			return err
+		}
+		defaults.caFiles = append(defaults.caFiles, files...)
+	}
+	registries, err := registriesTLSSettings()
+	if err != nil {
+		return err
+	}
+	if len(defaults.caFiles) == 0 && defaults.certFile == "" && defaults.keyFile == "" && len(registries) == 0 &&
+		proxyURL == "" && noProxy == "" {
+		return nil
+	}
+
+	defaultTransport, err := newTLSTransport(defaults)
+	if err != nil {
+		return err
+	}
+	t := &hostsTransport{defaultTransport: defaultTransport, transports: make(map[string]http.RoundTripper)}
+	for registry, settings := range registries {
+		tr, err := newTLSTransport(settings.withDefaults(defaults))
+		if err != nil {
+			return fmt.Errorf("invalid TLS settings for registry %q: %w", registry, err)
+		}
+		t.transports[registry] = tr
+	}
+	registryTransport = t
+	return nil
+}
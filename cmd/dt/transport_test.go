@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func (suite *CmdSuite) TestRegistriesCAFlags() {
+	require := suite.Require()
+
+	s, err := tu.NewTestServer()
+	require.NoError(err)
+	defer s.Close()
+
+	images, err := s.LoadImagesFromFile("../../testdata/images.json")
+	require.NoError(err)
+
+	// The server certificate is only valid for 127.0.0.1
+	serverURL := strings.Replace(s.ServerURL, "localhost", "127.0.0.1", 1)
+
+	sb := suite.sb
+	scenarioName := "custom-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	t := suite.T()
+
+	createSampleChart := func() string {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "RepositoryURL": serverURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+
+	caDir := sb.TempFile()
+	require.NoError(os.MkdirAll(caDir, 0755))
+	caFile := filepath.Join(caDir, "ca.crt")
+	require.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0644))
+
+	t.Run("Fails without the CA", func(t *testing.T) {
+		dt("images", "lock", createSampleChart()).AssertErrorMatch(t, "certificate")
+	})
+	t.Run("Uses the CA files", func(t *testing.T) {
+		dt("images", "lock", "--ca-file", caFile, createSampleChart()).AssertSuccess(t)
+		dt("images", "lock", "--ca-dir", caDir, createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Uses the registry CA files", func(t *testing.T) {
+		dt("images", "lock", "--registry-ca-file", fmt.Sprintf("%s=%s", serverURL, caFile), createSampleChart()).AssertSuccess(t)
+		dt("images", "lock", "--registry-ca-file", fmt.Sprintf("example.com=%s", caFile), createSampleChart()).AssertErrorMatch(t, "certificate")
+	})
+	t.Run("Validates the flags", func(t *testing.T) {
+		chartDir := createSampleChart()
+		dt("images", "lock", "--ca-file", filepath.Join(caDir, "missing.crt"), chartDir).AssertErrorMatch(t, "failed to read CA file")
+		dt("images", "lock", "--ca-file", filepath.Join(chartDir, "Chart.yaml"), chartDir).AssertErrorMatch(t, "no PEM certificates found")
+		dt("images", "lock", "--ca-dir", filepath.Join(caDir, "missing"), chartDir).AssertErrorMatch(t, "failed to read CA directory")
+		dt("images", "lock", "--registry-ca-file", caFile, chartDir).AssertErrorMatch(t, "expected the registry=value format")
+	})
+}
+
+// writeClientCertificate writes a new self-signed client certificate and its key into dir
+func writeClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dt"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func (suite *CmdSuite) TestRegistriesClientCertificateFlags() {
+	require := suite.Require()
+	t := suite.T()
+
+	s, err := tu.NewTestServer()
+	require.NoError(err)
+	defer s.Close()
+
+	certDir := suite.sb.TempFile()
+	require.NoError(os.MkdirAll(certDir, 0755))
+	cert, certFile, keyFile := writeClientCertificate(t, certDir)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	s.RequireClientCertificates(pool)
+
+	images, err := s.LoadImagesFromFile("../../testdata/images.json")
+	require.NoError(err)
+
+	scenarioName := "custom-chart"
+	createSampleChart := func() string {
+		dest := suite.sb.TempFile()
+		require.NoError(tu.RenderScenario(fmt.Sprintf("../../testdata/scenarios/%s", scenarioName), dest,
+			map[string]interface{}{"ServerURL": s.ServerURL, "Images": images, "Name": "test", "RepositoryURL": s.ServerURL},
+		))
+		return filepath.Join(dest, scenarioName)
+	}
+
+	t.Run("Fails without the client certificate", func(t *testing.T) {
+		dt("images", "lock", "--insecure", createSampleChart()).AssertError(t)
+	})
+	t.Run("Uses the client certificate", func(t *testing.T) {
+		dt("images", "lock", "--insecure", "--client-cert", certFile, "--client-key", keyFile, createSampleChart()).AssertSuccess(t)
+	})
+	t.Run("Uses the registry client certificate", func(t *testing.T) {
+		dt("images", "lock", "--insecure",
+			"--registry-client-cert", fmt.Sprintf("%s=%s", s.ServerURL, certFile),
+			"--registry-client-key", fmt.Sprintf("%s=%s", s.ServerURL, keyFile),
+			createSampleChart()).AssertSuccess(t)
+		dt("images", "lock", "--insecure",
+			"--registry-client-cert", fmt.Sprintf("example.com=%s", certFile),
+			"--registry-client-key", fmt.Sprintf("example.com=%s", keyFile),
+			createSampleChart()).AssertError(t)
+	})
+	t.Run("Validates the flags", func(t *testing.T) {
+		chartDir := createSampleChart()
+		dt("images", "lock", "--client-cert", certFile, chartDir).AssertErrorMatch(t, "both the client certificate and key are required")
+		dt("images", "lock", "--client-cert", certFile, "--client-key", certFile, chartDir).AssertErrorMatch(t, "failed to load client certificate")
+		dt("images", "lock", "--registry-client-key", fmt.Sprintf("%s=%s", s.ServerURL, keyFile), chartDir).AssertErrorMatch(t, `invalid TLS settings for registry`)
+	})
+}
+
+func (suite *CmdSuite) TestRegistriesProxyFlags() {
+	require := suite.Require()
+	t := suite.T()
+
+	var mu sync.Mutex
+	proxied := make([]string, 0)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.Host)
+		mu.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	proxiedHosts := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		hosts := proxied
+		proxied = make([]string, 0)
+		return hosts
+	}
+
+	const registry = "registry.example.invalid"
+	chartDir := filepath.Join(suite.sb.TempFile(), "custom-chart")
+	require.NoError(tu.RenderScenario("../../testdata/scenarios/custom-chart", filepath.Dir(chartDir),
+		map[string]interface{}{"ServerURL": registry, "Images": []*tu.ImageData{{Name: "app", Image: "app:1.0.0"}}, "Name": "test", "RepositoryURL": registry},
+	))
+
+	t.Run("Uses the proxy", func(t *testing.T) {
+		dt("images", "lock", "--proxy", proxy.URL, chartDir).AssertError(t)
+		require.Contains(proxiedHosts(), registry+":443")
+	})
+	t.Run("Does not use the proxy for excluded hosts", func(t *testing.T) {
+		dt("images", "lock", "--proxy", proxy.URL, "--no-proxy", ".example.invalid", chartDir).AssertError(t)
+		require.Empty(proxiedHosts())
+	})
+	t.Run("Uses the proxy environment variables", func(t *testing.T) {
+		t.Setenv("HTTPS_PROXY", proxy.URL)
+		dt("images", "lock", chartDir).AssertError(t)
+		require.Contains(proxiedHosts(), registry+":443")
+	})
+	t.Run("Validates the flags", func(t *testing.T) {
+		dt("images", "lock", "--proxy", "ftp://proxy.example.com", chartDir).AssertErrorMatch(t, "unsupported proxy scheme")
+		dt("images", "lock", "--proxy", "proxy.example.com", chartDir).AssertErrorMatch(t, "invalid proxy URL")
+	})
+}
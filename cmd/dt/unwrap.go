@@ -8,127 +8,494 @@ import (
 	"regexp"
 	"strings"
 
+	"filippo.io/age"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
 	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var unwrapCmd = newUnwrapCommand()
 
+// unwrapConfig defines the settings used when unwrapping a Helm chart
+type unwrapConfig struct {
+	SayYes       bool
+	PushChartURL string
+	MaxRetries   int
+	PolicyPaths  []string
+	// VerifySignature requests the wrap detached signature to be verified against Keyring
+	VerifySignature bool
+	Keyring         string
+	SignatureFile   string
+	// Checksum, if not empty, is the SHA256 checksum remote wraps must match
+	Checksum string
+	// SkipChecksum allows unwrapping remote wraps without a checksum to verify them against
+	SkipChecksum bool
+	// IdentityFile and DecryptionPassphraseFile are used to decrypt encrypted wraps
+	IdentityFile             string
+	DecryptionPassphraseFile string
+	// DeltaBase, if not empty, is the wrap the images omitted from delta wraps are read from
+	DeltaBase string
+	// ReportFile, if not empty, is where to write the relocation report
+	ReportFile string
+	// KbldConfigFile, if not empty, is where to write the kbld config overriding the original images
+	KbldConfigFile string
+	// Emit are the kinds of deployment files, referencing the relocated images, to write into EmitDir
+	Emit []string
+	// EmitDir is the directory the Emit files are written into
+	EmitDir string
+	// PinDigests requests values.yaml to reference the relocated images by digest
+	PinDigests bool
+	// RelocateFiles are the patterns of the chart files whose image references are relocated
+	RelocateFiles []string
+	// RepositoryMapFile, if not empty, maps source repositories to custom target repositories
+	RepositoryMapFile string
+	// RepositoryStrategy defines how the source repositories are mapped into the relocation prefix
+	RepositoryStrategy string
+	// TagStrategy defines how the relocated images are addressed, and so pushed
+	TagStrategy string
+	// ReplicaURLs are the additional registries the images are pushed into, relocated as into the main one
+	ReplicaURLs []string
+	// ExtractTo, if not empty, is the directory the chart is extracted into, relocated if a registry is
+	// provided, instead of pushing it
+	ExtractTo string
+	// Only, if not empty, restricts the push to the images or the Helm chart
+	Only string
+	// ChartSignOptions, if not nil, requests a new provenance file for the relocated Helm chart
+	ChartSignOptions []signature.Option
+	// OutputFormat selects how the run summary is printed
+	OutputFormat string
+	// Summary collects the stages durations and transfer statistics
+	Summary *runSummary
+}
+
+// Artifacts the unwrap push can be restricted to with --only
+const (
+	unwrapOnlyImages = "images"
+	unwrapOnlyChart  = "chart"
+)
+
+// validateUnwrapOnly returns an error if only is not a valid --only value
+func validateUnwrapOnly(only string) error {
+	switch only {
+	case "", unwrapOnlyImages, unwrapOnlyChart:
+		return nil
+	default:
+		return fmt.Errorf("unsupported --only value %q, use %q or %q", only, unwrapOnlyImages, unwrapOnlyChart)
+	}
+}
+
+// pushesImages returns true if the unwrap pushes the wrapped images
+func (cfg *unwrapConfig) pushesImages() bool {
+	return cfg.Only != unwrapOnlyChart
+}
+
+// pushesChart returns true if the unwrap pushes the relocated Helm chart
+func (cfg *unwrapConfig) pushesChart() bool {
+	return cfg.Only != unwrapOnlyImages
+}
+
+// prepareUnwrapInput returns the uncompressed chart path of the wrap, restoring the images omitted from
+// delta wraps from their base wrap
+func prepareUnwrapInput(ctx context.Context, inputChart string, tempDir string, flags *pflag.FlagSet, cfg *unwrapConfig, l log.SectionLogger) (string, error) {
+	chartPath, err := resolveUnwrapInput(ctx, inputChart, tempDir, flags, cfg, l)
+	if err != nil {
+		return "", err
+	}
+	return chartPath, restoreDeltaImages(ctx, chartPath, cfg.DeltaBase, l)
+}
+
+// resolveUnwrapInput verifies and decrypts the wrap, if requested, and returns the uncompressed chart path,
+// pulling the images of Carvel bundles without an Images.lock.
+// Wraps in http(s) or object storage URLs are downloaded first, and wraps pushed as OCI artifacts are
+// downloaded straight into the chart directory
+func resolveUnwrapInput(ctx context.Context, inputChart string, tempDir string, flags *pflag.FlagSet, cfg *unwrapConfig, l log.SectionLogger) (string, error) {
+	if isRemoteWrapURL(inputChart) {
+		var err error
+		if inputChart, err = downloadRemoteWrap(ctx, inputChart, tempDir, cfg, l); err != nil {
+			return "", err
+		}
+	}
+	if cfg.VerifySignature {
+		if err := verifyWrapSignature(inputChart, cfg.SignatureFile, cfg.Keyring, l); err != nil {
+			return "", err
+		}
+	}
+	if isWrapArtifactRef(ctx, inputChart) {
+		var chartPath string
+		err := cfg.Summary.stage(ctx, "pull wrap", func(ctx context.Context) (err error) {
+			chartPath, err = pullWrapArtifact(ctx, inputChart, tempDir, l)
+			return err
+		})
+		return chartPath, err
+	}
+	if isEncrypted, _ := encryption.IsEncrypted(inputChart); isEncrypted {
+		var err error
+		if inputChart, err = decryptWrap(inputChart, tempDir, cfg.IdentityFile, cfg.DecryptionPassphraseFile, l); err != nil {
+			return "", err
+		}
+	}
+	chartPath, _, err := resolveInputChartPath(inputChart, l, flags)
+	if err != nil {
+		return "", err
+	}
+	return chartPath, prepareCarvelBundle(ctx, chartPath, cfg, l)
+}
+
+func unwrapChart(ctx context.Context, inputChart string, registryURL string, flags *pflag.FlagSet, cfg *unwrapConfig) error {
+	successMessage := "Helm chart unwrapped successfully"
+	parentLog := getOutputLogger(cfg.OutputFormat)
+
+	l := parentLog.StartSection(fmt.Sprintf("Unwrapping Helm chart %q", inputChart))
+
+	tempDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	if keepArtifacts {
+		l.Debugf("Temporary assets kept at %q", tempDir)
+	}
+
+	chartPath, err := prepareUnwrapInput(ctx, inputChart, tempDir, flags, cfg, l)
+	if err != nil {
+		return err
+	}
+
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return l.Failf("failed to load Helm chart %q: %w", chartPath, err)
+	}
+
+	if cfg.ExtractTo != "" {
+		if err := extractUnwrappedChart(ctx, chartPath, registryURL, cfg, l); err != nil {
+			return err
+		}
+		parentLog.Successf("Helm chart extracted into %q", cfg.ExtractTo)
+		return nil
+	}
+
+	replicas, err := relocateUnwrappedChart(ctx, chartPath, registryURL, cfg, l)
+	if err != nil {
+		return err
+	}
+	l.Infof("Helm chart relocated successfully")
+
+	lenImages := showImagesSummary(chart, l)
+
+	if len(cfg.PolicyPaths) > 0 {
+		if err := l.Section("Checking images policies", func(subLog log.SectionLogger) error {
+			return checkChartPolicies(ctx, chartPath, cfg.PolicyPaths, subLog)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := pushUnwrappedImagesStep(ctx, chartPath, registryURL, lenImages, replicas, cfg, l); err != nil {
+		return err
+	}
+
+	if !cfg.pushesChart() {
+		l.Infof("Skipping the Helm chart push (--only %s)", cfg.Only)
+		successMessage = "Wrapped images pushed successfully"
+	} else if cfg.SayYes || widgets.ShowYesNoQuestion(l.PrefixText("Do you want to push the Helm chart to the OCI registry?")) {
+		var fullChartURL string
+		if err := cfg.Summary.stage(ctx, "push chart", func(context.Context) (err error) {
+			fullChartURL, err = pushUnwrappedChart(chart, registryURL, cfg, l)
+			return err
+		}); err != nil {
+			return err
+		}
+		successMessage = fmt.Sprintf(`%s: You can use it now by running "helm install %s --generate-name"`, successMessage, fullChartURL)
+		cfg.Summary.Output = fullChartURL
+	}
+
+	l.Printf(terminalSpacer)
+
+	parentLog.Successf(successMessage)
+
+	return nil
+}
+
+// pushUnwrappedImagesStep pushes the wrapped images, unless skipped with --only chart, after confirmation
+func pushUnwrappedImagesStep(ctx context.Context, chartPath string, registryURL string, lenImages int, replicas *imagesReplication, cfg *unwrapConfig, l log.SectionLogger) error {
+	if lenImages == 0 {
+		return nil
+	}
+	if !cfg.pushesImages() {
+		l.Infof("Skipping the images push (--only %s)", cfg.Only)
+		return nil
+	}
+	if !cfg.SayYes && !widgets.ShowYesNoQuestion(l.PrefixText("Do you want to push the wrapped images to the OCI registry?")) {
+		return nil
+	}
+	return pushUnwrappedImages(ctx, chartPath, registryURL, lenImages, replicas, cfg, l)
+}
+
+// relocateUnwrappedChart relocates the chart into registryURL, returning the replication of its images into
+// the additional registries, if any
+func relocateUnwrappedChart(ctx context.Context, chartPath string, registryURL string, cfg *unwrapConfig, l log.SectionLogger) (*imagesReplication, error) {
+	settings := relocateSettings{
+		ReportFile: cfg.ReportFile, KbldConfigFile: cfg.KbldConfigFile, Emit: cfg.Emit, EmitDir: cfg.EmitDir, PinDigests: cfg.PinDigests, Files: cfg.RelocateFiles,
+		RepositoryMapFile: cfg.RepositoryMapFile, RepositoryStrategy: cfg.RepositoryStrategy, TagStrategy: cfg.TagStrategy,
+	}
+	// The replicas relocate the original images, so read them before relocating the chart
+	replicas, err := newImagesReplication(chartPath, cfg.ReplicaURLs, settings)
+	if err != nil {
+		return nil, l.Failf("failed to prepare the images replication: %w", err)
+	}
+	if err := cfg.Summary.stage(ctx, "relocate", func(context.Context) error {
+		return relocateChartWithReport(chartPath, registryURL, settings, l)
+	}); err != nil {
+		return nil, err
+	}
+	return replicas, nil
+}
+
+// pushUnwrappedImages pushes the images of the relocated chart and replicates them into the additional registries
+func pushUnwrappedImages(ctx context.Context, chartPath string, registryURL string, lenImages int, replicas *imagesReplication, cfg *unwrapConfig, l log.SectionLogger) error {
+	if err := l.Section("Pushing Images", func(subLog log.SectionLogger) error {
+		return cfg.Summary.stage(ctx, "push images", func(ctx context.Context) error {
+			return pushChartImagesAndVerify(ctx, chartPath, subLog, cfg.Summary.handleImageEvent)
+		})
+	}); err != nil {
+		return l.Failf("Failed to push images: %w", err)
+	}
+	cfg.Summary.addTargets(targetSummary{Registry: registryURL, ImagesPushed: lenImages})
+	l.Printf(terminalSpacer)
+	if replicas == nil {
+		return nil
+	}
+	if err := cfg.Summary.stage(ctx, "replicate images", func(ctx context.Context) error {
+		results, err := replicas.push(ctx, l, cfg.Summary.handleImageEvent)
+		cfg.Summary.addTargets(results...)
+		return err
+	}); err != nil {
+		return l.Failf("Failed to replicate images: %w", err)
+	}
+	l.Printf(terminalSpacer)
+	return nil
+}
+
+// pushUnwrappedChart pushes the relocated Helm chart and returns its full URL
+func pushUnwrappedChart(chart *chartutils.Chart, registryURL string, cfg *unwrapConfig, l log.SectionLogger) (string, error) {
+	pushChartURL := cfg.PushChartURL
+	if pushChartURL == "" {
+		pushChartURL = registryURL
+	}
+	return pushChartWithRetries(chart, pushChartURL, cfg.MaxRetries, cfg.ChartSignOptions, l)
+}
+
+// pushChartWithRetries packages and pushes the Helm chart into pushChartURL, retrying up to maxRetries
+// times, and returns the reference to install it with: its full URL or, for chart repositories, its name
+// and the repository
+func pushChartWithRetries(chart *chartutils.Chart, pushChartURL string, maxRetries int, signOpts []signature.Option, l log.SectionLogger) (string, error) {
+	pushChartURL = normalizeOCIURL(pushChartURL)
+
+	if err := l.ExecuteStep(fmt.Sprintf("Pushing Helm chart to %q", pushChartURL), func() error {
+		return utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
+			if try > 0 {
+				l.Debugf("Failed to push Helm chart: %v", prevErr)
+			}
+			return pushChart(chart, pushChartURL, signOpts)
+		})
+	}); err != nil {
+		return "", l.Failf("Failed to push Helm chart: %w", err)
+	}
+	if signOpts != nil {
+		l.Infof("Helm chart signed and successfully pushed")
+	} else {
+		l.Infof("Helm chart successfully pushed")
+	}
+	if utils.IsChartRepositoryURL(pushChartURL) {
+		return fmt.Sprintf("%s --repo %s", chart.Name(), pushChartURL), nil
+	}
+	return fmt.Sprintf("%s/%s", pushChartURL, chart.Name()), nil
+}
+
 func newUnwrapCommand() *cobra.Command {
 	var (
-		sayYes       bool
-		pushChartURL string
-		maxRetries   = 3
-		version      string
+		version            string
+		signChartKey       string
+		signChartKeyring   = signature.DefaultSecretKeyring()
+		signPassphraseFile string
+		metrics            = &metricsConfig{}
 	)
-
-	successMessage := "Helm chart unwrapped successfully"
+	cfg := &unwrapConfig{
+		Keyring:      signature.DefaultPublicKeyring(),
+		OutputFormat: textOutput,
+	}
 
 	cmd := &cobra.Command{
-		Use:   "unwrap FILE OCI_URI",
+		Use:   "unwrap FILE|URL|OCI_ARTIFACT [OCI_URI]",
 		Short: "Unwraps a wrapped Helm chart",
-		Long:  "Unwraps a wrapped package and moves it into a target OCI registry. This command will read a wrap tarball and push all its container images and Helm chart into the target OCI registry",
+		Long:  "Unwraps a wrapped package and moves it into a target OCI registry. This command will read a wrap tarball, downloading it first from http(s), s3://, gs:// and azblob:// URLs, or a wrap pushed as an OCI artifact, and push all its container images and Helm chart into the target OCI registry",
 		Example: `  # Unwrap a Helm chart and push it into a Harbor repository
   $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo
-`,
-		SilenceUsage:  true,
-		SilenceErrors: true,
-		Args:          cobra.ExactArgs(2),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			inputChart, registryURL := args[0], args[1]
 
-			if registryURL == "" {
-				return fmt.Errorf("the registry cannot be empty")
-			}
+  # Unwrap a wrap pushed as an OCI artifact with "dt wrap --push", registry to registry
+  $ dt unwrap oci://harbor.example.com/wraps/mariadb-wrap:12.2.8 oci://demo.goharbor.io/test_repo
 
-			parentLog := getLogger()
+  # Unwrap a Helm chart downloading it from an S3 bucket, verifying it against its published .sha256 checksum
+  $ dt unwrap s3://my-bucket/wraps/mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo
 
-			ctx, cancel := contextWithSigterm(context.Background())
-			defer cancel()
+  # Verify the wrap GPG signature (mariadb-12.2.8.wrap.tgz.asc) before unwrapping it
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --verify-signature
 
-			l := parentLog.StartSection(fmt.Sprintf("Unwrapping Helm chart %q", inputChart))
+  # Push the images first, and publish the relocated Helm chart later in a separate step
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --only images
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --only chart
 
-			tempDir, err := getGlobalTempWorkDir()
-			if err != nil {
-				return fmt.Errorf("failed to create temporary directory: %v", err)
-			}
-			if keepArtifacts {
-				l.Debugf("Temporary assets kept at %q", tempDir)
+  # Extract the wrap relocated into a registry, for another tool to upload the chart and its images
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --extract-to mariadb
+
+  # Unwrap a delta wrap, reading the images it omits from the previous wrap
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --delta-base mariadb-12.2.7.wrap.tgz
+
+  # Unwrap an encrypted Helm chart
+  $ dt unwrap mariadb-12.2.8.wrap.tgz.age oci://demo.goharbor.io/test_repo --identity key.txt
+
+  # Unwrap a Helm chart pushing it with a new provenance file for the relocated chart
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --sign-chart-key ops@example.com
+
+  # Unwrap a Helm chart pushing the images into Harbor and the chart into a ChartMuseum repository
+  $ dt unwrap mariadb-12.2.8.wrap.tgz oci://demo.goharbor.io/test_repo --push-chart-url https://charts.example.com --creds charts.example.com=user:pass
+`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputChart, registryURL := args[0], ""
+			if len(args) > 1 {
+				registryURL = args[1]
 			}
 
-			chartPath, err := resolveInputChartPath(inputChart, l, cmd.Flags())
-			if err != nil {
+			if registryURL == "" && cfg.ExtractTo == "" {
+				return fmt.Errorf("the registry cannot be empty")
+			}
+			if err := validateOutputFormat(cfg.OutputFormat); err != nil {
 				return err
 			}
-
-			chart, err := chartutils.LoadChart(chartPath)
-			if err != nil {
-				return l.Failf("failed to load Helm chart %q: %w", chartPath, err)
+			if err := validateUnwrapOnly(cfg.Only); err != nil {
+				return err
 			}
-
-			if err := l.ExecuteStep(fmt.Sprintf("Relocating %q with prefix %q", chartPath, registryURL), func() error {
-				return relocateChart(chartPath, registryURL, relocator.WithLog(l))
-			}); err != nil {
-				return l.Failf("failed to relocate %q: %w", chartPath, err)
+			if err := flagsKeychain.scopeDefaultAuth(append([]string{registryURL, cfg.PushChartURL}, cfg.ReplicaURLs...)...); err != nil {
+				return err
 			}
-			l.Infof("Helm chart relocated successfully")
-
-			lenImages := showImagesSummary(chart, l)
-
-			if lenImages > 0 && (sayYes || widgets.ShowYesNoQuestion(l.PrefixText("Do you want to push the wrapped images to the OCI registry?"))) {
-				if err := l.Section("Pushing Images", func(subLog log.SectionLogger) error {
-					return pushChartImagesAndVerify(ctx, chartPath, subLog)
-				}); err != nil {
-					return l.Failf("Failed to push images: %w", err)
+			cfg.MaxRetries = maxRetries
+			if signChartKey != "" {
+				opts, err := signOptions(signChartKey, signChartKeyring, signPassphraseFile)
+				if err != nil {
+					return err
 				}
-				l.Printf(terminalSpacer)
+				cfg.ChartSignOptions = opts
 			}
 
-			if sayYes || widgets.ShowYesNoQuestion(l.PrefixText("Do you want to push the Helm chart to the OCI registry?")) {
-
-				if pushChartURL == "" {
-					pushChartURL = registryURL
-				}
-				pushChartURL = normalizeOCIURL(pushChartURL)
-				fullChartURL := fmt.Sprintf("%s/%s", pushChartURL, chart.Name())
-
-				if err := l.ExecuteStep(fmt.Sprintf("Pushing Helm chart to %q", pushChartURL), func() error {
-					return utils.ExecuteWithRetry(maxRetries, func(try int, prevErr error) error {
-						if try > 0 {
-							l.Debugf("Failed to push Helm chart: %v", prevErr)
-						}
-						return pushChart(chart, pushChartURL)
-					})
-				}); err != nil {
-					return l.Failf("Failed to push Helm chart: %w", err)
-				}
-				l.Infof("Helm chart successfully pushed")
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
 
-				successMessage = fmt.Sprintf(`%s: You can use it now by running "helm install %s --generate-name"`, successMessage, fullChartURL)
+			cfg.Summary = newRunSummary("unwrap")
+			ctx, span := tracing.Start(ctx, "unwrap", attribute.String("chart", inputChart))
+			err := unwrapChart(ctx, inputChart, registryURL, cmd.Flags(), cfg)
+			tracing.End(span, err)
+			if mErr := exportRunMetrics(cfg.Summary, err, metrics); mErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", mErr)
 			}
-
-			l.Printf(terminalSpacer)
-
-			parentLog.Successf(successMessage)
-
-			return nil
+			if err != nil {
+				return err
+			}
+			return reportRunSummary(cfg.Summary, cfg.OutputFormat)
 		},
 	}
 
+	addOutputFlag(cmd, &cfg.OutputFormat)
+	addMetricsFlags(cmd, metrics)
 	cmd.PersistentFlags().StringVar(&version, "version", version, "when unwrapping remote Helm charts from OCI, version to request")
-	cmd.PersistentFlags().StringVar(&pushChartURL, "push-chart-url", pushChartURL, "push the unwrapped Helm chart to the given URL")
-	cmd.PersistentFlags().BoolVar(&sayYes, "yes", sayYes, "respond 'yes' to any yes/no question")
+	cmd.PersistentFlags().StringVar(&cfg.PushChartURL, "push-chart-url", cfg.PushChartURL, "push the unwrapped Helm chart to the given URL: an OCI registry or, for http(s) URLs, a ChartMuseum compatible Helm repository")
+	cmd.PersistentFlags().StringVar(&cfg.ExtractTo, "extract-to", cfg.ExtractTo, "only extract the wrap into the given directory, relocating the Helm chart into OCI_URI if provided, without pushing anything")
+	cmd.PersistentFlags().StringVar(&cfg.Only, "only", cfg.Only, "only push the wrapped images (images) or the relocated Helm chart (chart), so they can be published in separate steps")
+	cmd.PersistentFlags().BoolVar(&cfg.SayYes, "yes", cfg.SayYes, "respond 'yes' to any yes/no question")
+	cmd.PersistentFlags().BoolVar(&cfg.VerifySignature, "verify-signature", cfg.VerifySignature, "verify the detached GPG signature of the wrap before unwrapping it")
+	cmd.PersistentFlags().StringVar(&cfg.Keyring, "keyring", cfg.Keyring, "location of the public keyring used with --verify-signature")
+	cmd.PersistentFlags().StringVar(&cfg.SignatureFile, "signature-file", cfg.SignatureFile, "location of the wrap signature (defaults to the wrap file with the .asc extension)")
+	cmd.PersistentFlags().StringVar(&cfg.Checksum, "checksum", cfg.Checksum, "SHA256 checksum the wrap downloaded from a URL must match (defaults to the one published in the URL with the .sha256 extension)")
+	cmd.PersistentFlags().BoolVar(&cfg.SkipChecksum, "skip-checksum", cfg.SkipChecksum, "unwrap wraps downloaded from a URL even if no checksum is available to verify them")
+	cmd.PersistentFlags().StringVar(&cfg.IdentityFile, "identity", cfg.IdentityFile, "age identity file used to decrypt encrypted wraps")
+	cmd.PersistentFlags().StringVar(&cfg.DecryptionPassphraseFile, "decryption-passphrase-file", cfg.DecryptionPassphraseFile, "file containing the passphrase used to decrypt encrypted wraps")
+	cmd.PersistentFlags().StringSliceVar(&cfg.PolicyPaths, "policy", cfg.PolicyPaths, "Rego policy file or directory the images must comply with before being pushed (can be repeated)")
+	cmd.PersistentFlags().StringVar(&cfg.DeltaBase, "delta-base", cfg.DeltaBase, "wrap the delta wrap was generated from with \"dt wrap --delta-from\", providing the images omitted from it")
+	cmd.PersistentFlags().StringVar(&cfg.KbldConfigFile, "kbld-config", cfg.KbldConfigFile, kbldConfigUsage)
+	cmd.PersistentFlags().StringSliceVar(&cfg.Emit, "emit", cfg.Emit, emitUsage)
+	cmd.PersistentFlags().StringVar(&cfg.EmitDir, "emit-dir", ".", "directory the --emit files are written into")
+	cmd.PersistentFlags().StringVar(&cfg.ReportFile, "report-file", cfg.ReportFile, "write a report mapping the original image references to the relocated ones (JSON, or CSV if the file has the .csv extension)")
+	cmd.PersistentFlags().BoolVar(&cfg.PinDigests, "pin-digests", cfg.PinDigests, "reference the relocated images by digest in values.yaml")
+	cmd.PersistentFlags().StringVar(&cfg.RepositoryStrategy, "repository-strategy", string(relocator.DefaultRepositoryStrategy), repositoryStrategyUsage)
+	cmd.PersistentFlags().StringVar(&cfg.TagStrategy, "tag-strategy", string(relocator.OriginalTagStrategy), tagStrategyUsage)
+	cmd.PersistentFlags().StringSliceVar(&cfg.ReplicaURLs, "replicate-to", cfg.ReplicaURLs, "also push the images into the given registries, relocated as into OCI_URI (can be repeated)")
+	cmd.PersistentFlags().StringVar(&cfg.RepositoryMapFile, "repo-map", cfg.RepositoryMapFile, "YAML file mapping source repositories to custom target repositories, instead of the ones under OCI_URI")
+	cmd.PersistentFlags().StringSliceVar(&cfg.RelocateFiles, "relocate-files", relocator.DefaultFiles, "patterns of the chart files, relative to its root, whose image references are relocated besides values.yaml (directories include all their files)")
+	cmd.PersistentFlags().StringVar(&signChartKey, "sign-chart-key", signChartKey, "sign the relocated Helm chart with the given GPG key (user id, long key id or fingerprint), pushing a new provenance file with it")
+	cmd.PersistentFlags().StringVar(&signChartKeyring, "sign-chart-keyring", signChartKeyring, "location of the secret keyring used with --sign-chart-key")
+	cmd.PersistentFlags().StringVar(&signPassphraseFile, "sign-chart-passphrase-file", signPassphraseFile, "file containing the passphrase of the chart signing key")
 
 	return cmd
 }
 
-func pushChartImagesAndVerify(ctx context.Context, chartPath string, l log.SectionLogger) error {
-	lockFile, err := getImageLockFilePath(chartPath)
+func verifyWrapSignature(wrapFile string, signatureFile string, keyring string, l log.SectionLogger) error {
+	if !utils.FileExists(wrapFile) {
+		return l.Failf("signatures can only be verified for local wrap files")
+	}
+	if signatureFile == "" {
+		signatureFile = signature.FileName(wrapFile)
+	}
+	var signer string
+	if err := l.ExecuteStep("Verifying wrap signature", func() error {
+		var err error
+		signer, err = signature.VerifyFile(wrapFile, signatureFile, signature.WithKeyring(keyring))
+		return err
+	}); err != nil {
+		return l.Failf("Failed to verify wrap signature: %w", err)
+	}
+	l.Infof("Wrap signed by %q", signer)
+	return nil
+}
+
+func decryptWrap(wrapFile string, dir string, identityFile string, passphraseFile string, l log.SectionLogger) (string, error) {
+	identities := make([]age.Identity, 0)
+	if identityFile != "" {
+		ids, err := encryption.ReadIdentities(identityFile)
+		if err != nil {
+			return "", l.Failf("%v", err)
+		}
+		identities = append(identities, ids...)
+	}
+	if passphraseFile != "" {
+		id, err := encryption.PassphraseIdentity(passphraseFile)
+		if err != nil {
+			return "", l.Failf("%v", err)
+		}
+		identities = append(identities, id)
+	}
+	decryptedFile := filepath.Join(dir, strings.TrimSuffix(filepath.Base(wrapFile), encryption.Extension))
+	if err := l.ExecuteStep("Decrypting wrap", func() error {
+		return encryption.DecryptFile(wrapFile, decryptedFile, identities...)
+	}); err != nil {
+		return "", l.Failf("Failed to decrypt wrap: %w", err)
+	}
+	l.Infof("Wrap decrypted to %q", decryptedFile)
+	return decryptedFile, nil
+}
+
+func pushChartImagesAndVerify(ctx context.Context, chartPath string, l log.SectionLogger, handlers ...chartutils.ImageEventHandler) error {
+	lockFile, err := wrap.LockFile(chartPath)
 	if err != nil {
 		return fmt.Errorf("failed to determine Images.lock file location: %w", err)
 	}
@@ -137,9 +504,10 @@ func pushChartImagesAndVerify(ctx context.Context, chartPath string, l log.Secti
 	}
 	if err := pushChartImages(
 		chartPath,
-		chartutils.WithLog(log.SilentLog),
-		chartutils.WithContext(ctx),
-		chartutils.WithProgressBar(l.ProgressBar()),
+		append([]chartutils.Option{
+			chartutils.WithLog(log.SilentLog),
+			chartutils.WithContext(ctx),
+		}, imageTransferOptions(l, handlers...)...)...,
 	); err != nil {
 		return err
 	}
@@ -173,17 +541,6 @@ func showImagesSummary(chart *chartutils.Chart, l log.SectionLogger) int {
 	return len(lock.Images)
 }
 
-func untarChart(chartFile string, dir string) (string, error) {
-	sandboxDir, err := os.MkdirTemp(dir, "at-wrap*")
-	if err != nil {
-		return "", fmt.Errorf("failed to create sandbox directory")
-	}
-	if err := utils.Untar(chartFile, sandboxDir, utils.TarConfig{StripComponents: 1}); err != nil {
-		return "", err
-	}
-	return sandboxDir, nil
-}
-
 func normalizeOCIURL(url string) string {
 	schemeRe := regexp.MustCompile(`([a-z][a-z0-9+\-.]*)://`)
 	if !schemeRe.MatchString(url) {
@@ -192,8 +549,10 @@ func normalizeOCIURL(url string) string {
 	return url
 }
 
-func pushChart(chart *chartutils.Chart, pushChartURL string) error {
-	chartPath := chart.RootDir()
+// pushChart pushes the Helm chart into pushChartURL, either an OCI registry or, for http(s) URLs, a
+// ChartMuseum compatible repository. If signOpts are provided, the chart is signed and its provenance
+// file pushed along with it
+func pushChart(chart *chartutils.Chart, pushChartURL string, signOpts []signature.Option) error {
 	tmpDir, err := getGlobalTempWorkDir()
 	if err != nil {
 		return err
@@ -203,14 +562,28 @@ func pushChart(chart *chartutils.Chart, pushChartURL string) error {
 	if err != nil {
 		return fmt.Errorf("failed to upload Helm chart: failed to create temp directory: %w", err)
 	}
-	tempTarFile := filepath.Join(dir, fmt.Sprintf("%s.tgz", chart.Name()))
-	if err := utils.Tar(chartPath, tempTarFile, utils.TarConfig{
-		Prefix: chart.Name(),
-		Skip:   func(f string) bool { return strings.HasPrefix(f, "/images/") },
-	}); err != nil {
-		return fmt.Errorf("failed to untar filename %q: %w", chartPath, err)
+	tempTarFile, err := wrap.PackageChart(chart, dir)
+	if err != nil {
+		return err
+	}
+	if signOpts != nil {
+		if err := signature.SignChart(tempTarFile, tempTarFile+utils.ProvenanceExtension, signOpts...); err != nil {
+			return err
+		}
+	}
+	if utils.IsChartRepositoryURL(pushChartURL) {
+		username, password, err := chartRepositoryCredentials(pushChartURL)
+		if err != nil {
+			return err
+		}
+		return utils.UploadChart(tempTarFile, pushChartURL, utils.RepositoryPushConfig{Username: username, Password: password, Transport: getTransport()})
+	}
+	credentialsFile, err := writeHelmCredentialsFile(dir, pushChartURL)
+	if err != nil {
+		return err
 	}
-	return utils.PushChart(tempTarFile, pushChartURL)
+	defer os.Remove(credentialsFile)
+	return utils.PushChart(tempTarFile, pushChartURL, utils.PushConfig{CredentialsFile: credentialsFile, Transport: getTransport()})
 }
 
 func init() {
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/objectstore"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
+)
+
+// checksumExtension is the extension of the files holding the SHA256 checksum of remote wraps
+const checksumExtension = ".sha256"
+
+// isRemoteWrapURL returns true if the wrap in inputChart has to be downloaded from an http(s) or object
+// storage URL
+func isRemoteWrapURL(inputChart string) bool {
+	return strings.HasPrefix(inputChart, "http://") || strings.HasPrefix(inputChart, "https://") || objectstore.IsURL(inputChart)
+}
+
+// withExtension returns the URL of the rawURL file with ext appended, keeping its query, as in presigned URLs
+func withExtension(rawURL string, ext string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL + ext
+	}
+	u.Path += ext
+	return u.String()
+}
+
+// downloadObject streams the remote file in src into w
+func downloadObject(ctx context.Context, src string, w io.Writer) error {
+	if objectstore.IsURL(src) {
+		client, err := objectstore.New(src)
+		if err != nil {
+			return err
+		}
+		return client.Download(ctx, src, w)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", src, err)
+	}
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %q: %w", src, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %q: %s", src, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %q: %w", src, err)
+	}
+	return nil
+}
+
+// downloadFile downloads the remote file in src into dir, returning the file and its SHA256 checksum
+func downloadFile(ctx context.Context, src string, dir string) (string, string, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL %q: %w", src, err)
+	}
+	base := path.Base(u.Path)
+	if base == "." || base == "/" {
+		return "", "", fmt.Errorf("invalid URL %q: it does not include a file name", src)
+	}
+	file := filepath.Join(dir, base)
+	fh, err := os.Create(file)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer fh.Close()
+	h := sha256.New()
+	if err := downloadObject(ctx, src, io.MultiWriter(fh, h)); err != nil {
+		return "", "", err
+	}
+	return file, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// expectedWrapChecksum returns the SHA256 checksum the wrap in wrapURL must match: the provided one or,
+// if empty, the one published alongside the wrap. It returns an empty string if none is available
+func expectedWrapChecksum(ctx context.Context, wrapURL string, checksum string, l log.SectionLogger) string {
+	if checksum == "" {
+		var sb strings.Builder
+		if err := downloadObject(ctx, withExtension(wrapURL, checksumExtension), &sb); err != nil {
+			l.Debugf("Cannot fetch the wrap checksum: %v", err)
+			return ""
+		}
+		// Support the sha256sum output format: "CHECKSUM  FILENAME"
+		checksum = strings.Join(strings.Fields(sb.String()), " ")
+		checksum, _, _ = strings.Cut(checksum, " ")
+	}
+	return strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+}
+
+// downloadRemoteWrap downloads the wrap in wrapURL into dir, verifying its SHA256 checksum, and its
+// signature if it has to be verified, returning the downloaded wrap file
+func downloadRemoteWrap(ctx context.Context, wrapURL string, dir string, cfg *unwrapConfig, l log.SectionLogger) (string, error) {
+	downloadDir, err := os.MkdirTemp(dir, "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create download directory: %w", err)
+	}
+	var wrapFile, checksum string
+	if err := cfg.Summary.stage(ctx, "download wrap", func(ctx context.Context) (err error) {
+		return l.ExecuteStep("Downloading wrap", func() error {
+			wrapFile, checksum, err = downloadFile(ctx, wrapURL, downloadDir)
+			return err
+		})
+	}); err != nil {
+		return "", l.Failf("Failed to download wrap: %w", err)
+	}
+	l.Infof("Wrap downloaded to %q", wrapFile)
+
+	expected := expectedWrapChecksum(ctx, wrapURL, cfg.Checksum, l)
+	switch {
+	case expected == "" && cfg.SkipChecksum:
+		l.Warnf("No %s checksum found for the wrap: skipping its verification", checksumExtension)
+	case expected == "":
+		return "", l.Failf("No %s checksum found for the wrap: provide it with --checksum, or use --skip-checksum to unwrap it unverified", checksumExtension)
+	case expected != checksum:
+		return "", l.Failf("Wrap checksum mismatch: expected %s, got %s", expected, checksum)
+	default:
+		l.Infof("Wrap checksum verified")
+	}
+
+	if cfg.VerifySignature && cfg.SignatureFile == "" {
+		if err := l.ExecuteStep("Downloading wrap signature", func() error {
+			_, _, err := downloadFile(ctx, withExtension(wrapURL, signature.Extension), downloadDir)
+			return err
+		}); err != nil {
+			return "", l.Failf("Failed to download wrap signature: %w", err)
+		}
+	}
+	return wrapFile, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+)
+
+// extractUnwrappedChart relocates the unwrapped chart at chartPath into registryURL, if not empty, and
+// copies it, along with its images, into the --extract-to directory, without pushing anything
+func extractUnwrappedChart(ctx context.Context, chartPath string, registryURL string, cfg *unwrapConfig, l log.SectionLogger) error {
+	if registryURL != "" {
+		if _, err := relocateUnwrappedChart(ctx, chartPath, registryURL, cfg, l); err != nil {
+			return err
+		}
+		l.Infof("Helm chart relocated successfully")
+	}
+	if err := l.ExecuteStep(fmt.Sprintf("Extracting Helm chart into %q", cfg.ExtractTo), func() error {
+		return wrap.CopyTree(chartPath, cfg.ExtractTo)
+	}); err != nil {
+		return l.Failf("failed to extract Helm chart: %w", err)
+	}
+	return nil
+}
@@ -1,19 +1,29 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"filippo.io/age"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/vmware-labs/distribution-tooling-for-helm/artifact"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Same implementation used by Helm provenance files
 )
 
 func writeSampleImages(imageName string, imageTag string, dir string) ([]tu.ImageData, error) {
@@ -46,6 +56,30 @@ func writeSampleImages(imageName string, imageTag string, dir string) ([]tu.Imag
 	return []tu.ImageData{imageData}, nil
 }
 
+// writeKeyrings generates a new GPG key and returns the paths to the secret and public keyrings
+func writeKeyrings(dir string, name string) (string, string, error) {
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		return "", "", err
+	}
+	secring, pubring := filepath.Join(dir, "secring.gpg"), filepath.Join(dir, "pubring.gpg")
+	for file, serialize := range map[string]func(w io.Writer) error{
+		secring: func(w io.Writer) error { return e.SerializePrivate(w, nil) },
+		pubring: e.Serialize,
+	} {
+		fh, err := os.Create(file)
+		if err != nil {
+			return "", "", err
+		}
+		if err := serialize(fh); err != nil {
+			fh.Close()
+			return "", "", err
+		}
+		fh.Close()
+	}
+	return secring, pubring, nil
+}
+
 func (suite *CmdSuite) TestUnwrapCommand() {
 	t := suite.T()
 	silentLog := log.New(io.Discard, "", 0)
@@ -104,4 +138,354 @@ func (suite *CmdSuite) TestUnwrapCommand() {
 			"chart should exist in the repository",
 		)
 	})
+	t.Run("Unwrap the images and the chart in separate steps", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		targetRegistry := fmt.Sprintf("%s/only-images", serverURL)
+		chartURL := fmt.Sprintf("oci://%s/%s", targetRegistry, chartName)
+		dt("unwrap", "--yes", chartDir, targetRegistry, "--only", "images").
+			AssertSuccessMatch(t, `(?s)Skipping the Helm chart push \(--only images\).*Wrapped images pushed successfully`)
+		for _, img := range images {
+			_, err := tu.ReadRemoteImageManifest(fmt.Sprintf("%s/%s", targetRegistry, img.Image))
+			assert.NoError(err)
+		}
+		assert.False(utils.RemoteChartExist(chartURL, version), "chart should not be pushed")
+
+		dt("unwrap", "--yes", chartDir, targetRegistry, "--only", "chart").
+			AssertSuccessMatch(t, `Skipping the images push \(--only chart\)`)
+		assert.True(utils.RemoteChartExist(chartURL, version), "chart should exist in the repository")
+
+		dt("unwrap", "--yes", chartDir, targetRegistry, "--only", "everything").AssertErrorMatch(t, `unsupported --only value "everything"`)
+	})
+	t.Run("Extracts the wrap without pushing it", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+		wrapFile := filepath.Join(dest, "test-1.0.0.wrap.tgz")
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+		imgFiles, err := filepath.Glob(filepath.Join(chartDir, "images", "*.tar"))
+		require.NoError(err)
+
+		extractDir := filepath.Join(sb.TempFile(), "extracted")
+		dt("unwrap", wrapFile, "--extract-to", extractDir).AssertSuccessMatch(t, `Helm chart extracted into`)
+		for _, f := range append(imgFiles, filepath.Join(chartDir, "Chart.yaml")) {
+			rel, err := filepath.Rel(chartDir, f)
+			require.NoError(err)
+			expected, err := os.ReadFile(f)
+			require.NoError(err)
+			extracted, err := os.ReadFile(filepath.Join(extractDir, rel))
+			require.NoError(err)
+			assert.Equal(expected, extracted, "%s differs", rel)
+		}
+
+		targetRegistry := fmt.Sprintf("%s/extracted", serverURL)
+		relocatedDir := filepath.Join(sb.TempFile(), "relocated")
+		dt("unwrap", wrapFile, targetRegistry, "--extract-to", relocatedDir).AssertSuccessMatch(t, `Helm chart relocated successfully`)
+		chartData, err := os.ReadFile(filepath.Join(relocatedDir, "Chart.yaml"))
+		require.NoError(err)
+		assert.Contains(string(chartData), targetRegistry)
+		assert.False(utils.RemoteChartExist(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version), "chart should not be pushed")
+
+		dt("unwrap", wrapFile, targetRegistry, "--extract-to", relocatedDir).AssertErrorMatch(t, `is not empty`)
+		dt("unwrap", wrapFile).AssertErrorMatch(t, `the registry cannot be empty`)
+	})
+	t.Run("Unwrap Chart printing the run summary", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		targetRegistry := fmt.Sprintf("%s/summary-images", serverURL)
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("unwrap", "--yes", chartDir, targetRegistry, "--output", "json")
+		res.AssertSuccess(t)
+
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		assert.Equal("unwrap", summary.Command)
+		assert.Equal(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), summary.Output)
+		stages := make([]string, 0)
+		for _, st := range summary.Stages {
+			stages = append(stages, st.Name)
+		}
+		assert.Equal([]string{"relocate", "push images", "push chart"}, stages)
+		assert.Equal(len(images), summary.Transfers.ImagesPushed)
+		assert.Greater(summary.Transfers.BytesUploaded, int64(0))
+		assert.Equal(0, summary.Transfers.ImagesPulled)
+	})
+	t.Run("Unwrap Chart replicating the images into other registries", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		targetRegistry := fmt.Sprintf("%s/primary-images", serverURL)
+		replicaRegistry := fmt.Sprintf("%s/replica-images", serverURL)
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("unwrap", "--yes", chartDir, targetRegistry, "--replicate-to", replicaRegistry, "--output", "json")
+		res.AssertSuccess(t)
+
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		assert.Equal([]targetSummary{
+			{Registry: targetRegistry, ImagesPushed: len(images)},
+			{Registry: replicaRegistry, ImagesPushed: len(images)},
+		}, summary.Targets)
+		assert.Equal(2*len(images), summary.Transfers.ImagesPushed)
+
+		for _, img := range images {
+			remoteDigests, err := tu.ReadRemoteImageManifest(fmt.Sprintf("%s/%s", replicaRegistry, img.Image))
+			require.NoError(err)
+			for _, dgstData := range img.Digests {
+				assert.Equal(dgstData.Digest.Hex(), remoteDigests[dgstData.Arch].Digest.Hex())
+			}
+		}
+	})
+	t.Run("Unwrap Chart verifying its signature", func(t *testing.T) {
+		require := suite.Require()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		wrapFile := filepath.Join(dest, "test-1.0.0.wrap.tgz")
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+
+		keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		secring, pubring, err := writeKeyrings(keysDir, "dt")
+		require.NoError(err)
+		otherKeysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		_, otherPubring, err := writeKeyrings(otherKeysDir, "other")
+		require.NoError(err)
+
+		targetRegistry := fmt.Sprintf("%s/signed-images", serverURL)
+
+		dt("unwrap", "--plain", "--yes", "--verify-signature", "--keyring", pubring, wrapFile, targetRegistry).
+			AssertErrorMatch(t, "failed to open signature")
+
+		require.NoError(signature.SignFile(wrapFile, signature.FileName(wrapFile), signature.WithKeyring(secring)))
+
+		dt("unwrap", "--plain", "--yes", "--verify-signature", "--keyring", otherPubring, wrapFile, targetRegistry).
+			AssertErrorMatch(t, "invalid signature")
+		dt("unwrap", "--plain", "--yes", "--verify-signature", "--keyring", pubring, wrapFile, targetRegistry).
+			AssertSuccess(t)
+	})
+	t.Run("Unwrap Chart signing the relocated chart", func(t *testing.T) {
+		require := suite.Require()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		secring, pubring, err := writeKeyrings(keysDir, "dt")
+		require.NoError(err)
+
+		targetRegistry := fmt.Sprintf("%s/signed-charts", serverURL)
+		dt("unwrap", "--plain", "--yes", "--sign-chart-key", "dt", "--sign-chart-keyring", secring, chartDir, targetRegistry).
+			AssertSuccess(t)
+
+		fetchDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		_, err = utils.FetchRemoteChart(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version, fetchDir, utils.FetchConfig{Keyring: pubring})
+		require.NoError(err, "the pushed chart provenance should be valid")
+	})
+	t.Run("Unwrap encrypted Chart", func(t *testing.T) {
+		require := suite.Require()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		wrapFile := filepath.Join(dest, "test-1.0.0.wrap.tgz")
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+
+		id, err := age.GenerateX25519Identity()
+		require.NoError(err)
+		identityFile := filepath.Join(dest, "key.txt")
+		require.NoError(os.WriteFile(identityFile, []byte(id.String()), 0600))
+		encryptedFile := wrapFile + encryption.Extension
+		require.NoError(encryption.EncryptFile(wrapFile, encryptedFile, id.Recipient()))
+
+		targetRegistry := fmt.Sprintf("%s/encrypted-images", serverURL)
+		dt("unwrap", "--plain", "--yes", encryptedFile, targetRegistry).AssertErrorMatch(t, "no identity or passphrase was provided")
+		dt("unwrap", "--plain", "--yes", "--identity", identityFile, encryptedFile, targetRegistry).AssertSuccess(t)
+		suite.Assert().True(
+			utils.RemoteChartExist(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version),
+			"chart should exist in the repository",
+		)
+	})
+	t.Run("Unwrap Chart from a URL", func(t *testing.T) {
+		require := suite.Require()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		wrapsDir := filepath.Join(dest, "wraps")
+		require.NoError(os.MkdirAll(wrapsDir, 0755))
+		wrapFile := filepath.Join(wrapsDir, "test-1.0.0.wrap.tgz")
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+		wrapData, err := os.ReadFile(wrapFile)
+		require.NoError(err)
+		checksum := fmt.Sprintf("%x", sha256.Sum256(wrapData))
+		require.NoError(os.WriteFile(wrapFile+".sha256", []byte(checksum+"  test-1.0.0.wrap.tgz\n"), 0644))
+
+		fs := httptest.NewServer(http.FileServer(http.Dir(wrapsDir)))
+		defer fs.Close()
+		wrapURL := fs.URL + "/test-1.0.0.wrap.tgz"
+
+		targetRegistry := fmt.Sprintf("%s/downloaded-images", serverURL)
+		dt("unwrap", "--yes", wrapURL, targetRegistry).AssertSuccessMatch(t, "Wrap checksum verified")
+		suite.Assert().True(
+			utils.RemoteChartExist(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version),
+			"chart should exist in the repository",
+		)
+
+		dt("unwrap", "--yes", "--checksum", "sha256:"+strings.Repeat("0", 64), wrapURL, targetRegistry).
+			AssertErrorMatch(t, "Wrap checksum mismatch")
+		dt("unwrap", "--yes", fs.URL+"/missing.wrap.tgz", targetRegistry).AssertErrorMatch(t, "404 Not Found")
+		dt("unwrap", "--yes", fs.URL, targetRegistry).AssertErrorMatch(t, "does not include a file name")
+
+		// Wraps without a checksum are only unwrapped if requested
+		unverifiedFile := filepath.Join(wrapsDir, "unverified-1.0.0.wrap.tgz")
+		require.NoError(os.WriteFile(unverifiedFile, wrapData, 0644))
+		dt("unwrap", "--yes", fs.URL+"/unverified-1.0.0.wrap.tgz", targetRegistry).AssertErrorMatch(t, "No .sha256 checksum found")
+		dt("unwrap", "--yes", "--skip-checksum", fs.URL+"/unverified-1.0.0.wrap.tgz", targetRegistry).AssertSuccess(t)
+
+		// The downloads honor the TLS settings
+		tlsServer := httptest.NewTLSServer(http.FileServer(http.Dir(wrapsDir)))
+		defer tlsServer.Close()
+		caFile := filepath.Join(dest, "ca.pem")
+		require.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}), 0644))
+		dt("unwrap", "--yes", tlsServer.URL+"/test-1.0.0.wrap.tgz", targetRegistry).AssertErrorMatch(t, "certificate")
+		dt("unwrap", "--yes", "--ca-file", caFile, tlsServer.URL+"/test-1.0.0.wrap.tgz", targetRegistry).AssertSuccessMatch(t, "Wrap checksum verified")
+	})
+
+	t.Run("Unwrap Chart from an OCI artifact", func(t *testing.T) {
+		require := suite.Require()
+		assert := suite.Assert()
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages(imageName, imageTag, filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version, "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0755))
+
+		ref := artifact.Reference(serverURL+"/wraps", chartName, version)
+		_, err = artifact.Push(chartDir, ref)
+		require.NoError(err)
+
+		targetRegistry := fmt.Sprintf("%s/artifact-images", serverURL)
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("unwrap", "--yes", "oci://"+ref, targetRegistry, "--output", "json")
+		res.AssertSuccess(t)
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		assert.Equal("pull wrap", summary.Stages[0].Name)
+		assert.Equal(len(images), summary.Transfers.ImagesPushed)
+		assert.True(
+			utils.RemoteChartExist(fmt.Sprintf("oci://%s/%s", targetRegistry, chartName), version),
+			"chart should exist in the repository",
+		)
+
+		dt("unwrap", "--yes", "--verify-signature", "oci://"+ref, targetRegistry).AssertError(t)
+	})
+
 }
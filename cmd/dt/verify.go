@@ -7,55 +7,72 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
 var verifyCmd = newVerifyCmd()
 
 func verifyLock(chartPath string, lockFile string) error {
-	if !utils.FileExists(chartPath) {
-		return fmt.Errorf("Helm chart %q does not exist", chartPath)
-	}
-	fh, err := os.Open(lockFile)
-	if err != nil {
-		return fmt.Errorf("failed to open Images.lock file: %v", err)
-	}
-	defer fh.Close()
-
-	currentLock, err := imagelock.FromYAML(fh)
-	if err != nil {
-		return fmt.Errorf("failed to load Images.lock: %v", err)
-	}
-
-	calculatedLock, err := imagelock.GenerateFromChart(chartPath,
+	_, err := wrap.VerifyLock(chartPath, lockFile,
 		imagelock.WithAnnotationsKey(getAnnotationsKey()),
 		imagelock.WithContext(context.Background()),
 		imagelock.WithInsecure(insecure),
+		imagelock.WithKeychain(getKeychain()),
+		imagelock.WithTransport(getTransport()),
+		imagelock.WithMirrors(getMirrors()),
+		imagelock.WithLocalDaemon(useLocalDaemon),
+		imagelock.WithDaemonHost(localDaemonHost()),
+		imagelock.WithUntaggedPolicy(getUntaggedPolicy()),
 	)
-	if err != nil {
-		return fmt.Errorf("failed to re-create Images.lock from Helm chart %q: %v", chartPath, err)
-	}
+	return err
+}
 
-	if err := calculatedLock.Validate(currentLock.Images); err != nil {
-		return fmt.Errorf("Images.lock does not validate:\n%v", err)
+// verifyOutput is the machine-readable representation of the verify command results
+type verifyOutput struct {
+	Chart    string `json:"chart"`
+	LockFile string `json:"lockFile"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+// writeVerifyOutput verifies the lock and prints the result in the given structured format
+func writeVerifyOutput(chartPath string, lockFile string, format string) error {
+	verifyErr := verifyLock(chartPath, lockFile)
+	res := &verifyOutput{Chart: chartPath, LockFile: lockFile, Valid: verifyErr == nil}
+	if verifyErr != nil {
+		res.Error = verifyErr.Error()
+	}
+	if err := writeStructuredOutput(os.Stdout, format, res); err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		return fmt.Errorf("failed to verify %q lock", chartPath)
 	}
 	return nil
 }
 
 func newVerifyCmd() *cobra.Command {
 	var lockFile string
+	var outputFormat = textOutput
 
 	cmd := &cobra.Command{
 		Use:   "verify CHART_PATH",
 		Short: "Verifies the images in an Images.lock",
 		Long:  "Verifies that the information in the Images.lock from the given Helm chart are the same images available on their registries for being pulled",
 		Example: `  # Verifies integrity of the container images on the given Helm chart
-  $ dt images verify examples/mariadb`,
+  $ dt images verify examples/mariadb
+
+  # Verify the Images.lock reporting the result in JSON format
+  $ dt images verify examples/mariadb --output json`,
 		Args:          cobra.ExactArgs(1),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			chartPath := args[0]
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
 
 			l := getLogger()
 
@@ -64,13 +81,17 @@ func newVerifyCmd() *cobra.Command {
 			}
 
 			if lockFile == "" {
-				f, err := getImageLockFilePath(chartPath)
+				f, err := wrap.LockFile(chartPath)
 				if err != nil {
 					return fmt.Errorf("failed to find Images.lock file for Helm chart %q: %v", chartPath, err)
 				}
 				lockFile = f
 			}
 
+			if isStructuredOutput(outputFormat) {
+				return writeVerifyOutput(chartPath, lockFile, outputFormat)
+			}
+
 			if err := l.ExecuteStep("Verifying Images.lock", func() error {
 				return verifyLock(chartPath, lockFile)
 			}); err != nil {
@@ -82,5 +103,6 @@ func newVerifyCmd() *cobra.Command {
 		},
 	}
 	cmd.PersistentFlags().StringVar(&lockFile, "imagelock-file", lockFile, "location of the Images.lock YAML file")
+	addOutputFlag(cmd, &outputFormat)
 	return cmd
 }
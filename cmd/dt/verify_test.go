@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -92,6 +93,13 @@ func (suite *CmdSuite) TestVerifyCommand() {
 			require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0644))
 			dt("images", "verify", "--insecure", chartDir).AssertErrorMatch(t, fmt.Sprintf(`.*Images.lock does not validate:
 .*Helm chart "test": image ".*%s": digests do not match:\s*.*- %s\s*\s*\+ %s.*`, images[0].Image, newDigest, oldDigest))
+
+			res := dt("images", "verify", "--insecure", "--output", "json", chartDir)
+			res.AssertErrorMatch(t, "failed to verify")
+			out := &verifyOutput{}
+			require.NoError(json.Unmarshal([]byte(res.stdout), out))
+			suite.Assert().False(out.Valid)
+			suite.Assert().Contains(out.Error, "Images.lock does not validate")
 		})
 	})
 	t.Run("Verify Helm chart", func(t *testing.T) {
@@ -103,6 +111,13 @@ func (suite *CmdSuite) TestVerifyCommand() {
 		originChart := renderLockedChart(sb.TempFile(), chartName, scenarioName, serverURL, images)
 
 		dt("images", "verify", "--insecure", originChart).AssertSuccessMatch(t, "")
+
+		res := dt("images", "verify", "--insecure", "-o", "json", originChart)
+		res.AssertSuccess(t)
+		out := &verifyOutput{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), out))
+		suite.Assert().True(out.Valid)
+		suite.Assert().Equal(filepath.Join(originChart, "Images.lock"), out.LockFile)
 	})
 
 }
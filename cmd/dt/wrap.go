@@ -1,131 +1,769 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"filippo.io/age"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/objectstore"
+	"github.com/vmware-labs/distribution-tooling-for-helm/provenance"
+	"github.com/vmware-labs/distribution-tooling-for-helm/sbom"
+	"github.com/vmware-labs/distribution-tooling-for-helm/scan"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
 var wrapCmd = newWrapCommand()
 
-func wrapChart(ctx context.Context, inputPath string, outputFile string, platforms []string, flags *pflag.FlagSet) error {
-	parentLog := getLogger()
+// wrapConfig defines the optional settings used when wrapping a Helm chart
+type wrapConfig struct {
+	// SBOMFormat, if not empty, requests a SBOM document to be embedded in the wrap
+	SBOMFormat sbom.Format
+	// SBOMFile, if not empty, is an additional location where to write the SBOM document
+	SBOMFile string
+	// Scanner, if not nil, is used to scan the pulled images for vulnerabilities
+	Scanner scan.Scanner
+	// ScanSeverity is the minimum severity that makes the scan fail
+	ScanSeverity scan.Severity
+	// ScanWarnOnly reports the vulnerabilities found instead of failing
+	ScanWarnOnly bool
+	// PolicyPaths, if not empty, lists the Rego policies the pulled images must comply with
+	PolicyPaths []string
+	// RejectMutableTags makes the lock generation fail for images using mutable tags
+	RejectMutableTags bool
+	// PinMutableTags pins images using mutable tags to their digests before generating the lock
+	PinMutableTags bool
+	// Provenance requests a SLSA provenance statement to be written next to the wrap
+	Provenance bool
+	// ProvenanceKey, if not empty, is the cosign key used to sign the provenance statement
+	ProvenanceKey string
+	// SignKey, if not empty, selects the GPG key used to write a detached signature of the wrap
+	SignKey string
+	// SignOptions configures the signature (keyring, passphrase...)
+	SignOptions []signature.Option
+	// EncryptRecipients, if not empty, requests the wrap to be encrypted for the provided age recipients
+	EncryptRecipients []age.Recipient
+	// ImagesCacheDir, if not empty, is a directory where the pulled images are shared with other wraps
+	ImagesCacheDir string
+	// DeltaFrom, if not empty, is a previous wrap whose images are omitted from the wrap
+	DeltaFrom string
+	// PushURL, if not empty, is the OCI registry namespace where the wrap is pushed as an artifact
+	PushURL string
+	// SplitSubcharts also wraps each first-level subchart into its own wrap
+	SplitSubcharts bool
+	// DependencyBuild downloads the chart dependencies not vendored under charts/ before wrapping it
+	DependencyBuild bool
+	// Quiet restricts the logs to errors
+	Quiet bool
+	// OutputFormat selects how the run summary is printed
+	OutputFormat string
+	// Summary collects the stages durations and transfer statistics
+	Summary *runSummary
+}
+
+// wrapOption defines a wrapConfig option
+type wrapOption func(*wrapConfig)
+
+// withSBOM requests a SBOM in the specified format, optionally copied to file
+func withSBOM(format sbom.Format, file string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.SBOMFormat = format
+		cfg.SBOMFile = file
+	}
+}
+
+// withScanner requests the pulled images to be scanned, failing (or warning if warnOnly is set)
+// when vulnerabilities of severity or higher are found
+func withScanner(scanner scan.Scanner, severity scan.Severity, warnOnly bool) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.Scanner = scanner
+		cfg.ScanSeverity = severity
+		cfg.ScanWarnOnly = warnOnly
+	}
+}
+
+// withPolicies requests the pulled images to be checked against the Rego policies in paths
+func withPolicies(paths []string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.PolicyPaths = paths
+	}
+}
+
+// withMutableTags configures how images using mutable tags are handled when generating the lock
+func withMutableTags(reject bool, pin bool) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.RejectMutableTags = reject
+		cfg.PinMutableTags = pin
+	}
+}
+
+// withProvenance requests a SLSA provenance statement for the wrap, signed with the cosign key if provided
+func withProvenance(key string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.Provenance = true
+		cfg.ProvenanceKey = key
+	}
+}
+
+// withSignature requests a detached GPG signature of the wrap made with the provided key
+func withSignature(key string, opts ...signature.Option) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.SignKey = key
+		cfg.SignOptions = opts
+	}
+}
+
+// withEncryption requests the wrap to be encrypted for the provided recipients
+func withEncryption(recipients ...age.Recipient) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.EncryptRecipients = recipients
+	}
+}
+
+// withDependencyBuild requests the chart dependencies to be downloaded before wrapping it
+func withDependencyBuild(cfg *wrapConfig) {
+	cfg.DependencyBuild = true
+}
+
+// withImagesCache shares the pulled images with other wraps through dir
+func withImagesCache(dir string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.ImagesCacheDir = dir
+	}
+}
+
+// withPushWrap pushes the wrap as an OCI artifact under url
+func withPushWrap(url string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.PushURL = url
+	}
+}
+
+// withSplitSubcharts requests each first-level subchart to be wrapped separately too
+func withSplitSubcharts(cfg *wrapConfig) {
+	cfg.SplitSubcharts = true
+}
 
-	// Allows silencing called methods
-	silentLog := log.SilentLog
+// withQuietLog restricts the logs to errors
+func withQuietLog(cfg *wrapConfig) {
+	cfg.Quiet = true
+}
+
+// withRunSummary collects the run statistics into s
+func withRunSummary(s *runSummary) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.Summary = s
+	}
+}
+
+// withOutputFormat selects the format of the run summary
+func withOutputFormat(format string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.OutputFormat = format
+	}
+}
+
+func newWrapConfig(opts ...wrapOption) *wrapConfig {
+	cfg := &wrapConfig{OutputFormat: textOutput, Summary: newRunSummary("wrap")}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// logger returns the logger to use, keeping stdout for the structured summary if requested
+func (cfg *wrapConfig) logger() log.SectionLogger {
+	if cfg.Quiet {
+		return getQuietLogger()
+	}
+	return getOutputLogger(cfg.OutputFormat)
+}
+
+// imagesCacheDir returns the directory where the pulled images are shared with other wraps and runs, if any
+func (cfg *wrapConfig) imagesCacheDir() string {
+	if imagesCache != "" {
+		return imagesCache
+	}
+	if cfg.ImagesCacheDir != "" || !cfg.SplitSubcharts {
+		return cfg.ImagesCacheDir
+	}
+	// The subcharts wraps reuse the images pulled for the umbrella chart
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(tmpDir, "images-cache")
+}
+
+// wrapChart wraps the chart and returns the location of the wrap, uploading it if outputFile is an object
+// storage URL
+func wrapChart(ctx context.Context, inputPath string, outputFile string, platforms []string, flags *pflag.FlagSet, opts ...wrapOption) (string, error) {
+	cfg := newWrapConfig(opts...)
+	if objectstore.IsURL(outputFile) {
+		return wrapChartToStorage(ctx, inputPath, outputFile, platforms, flags, cfg)
+	}
+	return wrapChartWithConfig(ctx, inputPath, outputFile, platforms, flags, cfg)
+}
+
+// wrapChartWithConfig wraps the chart using the provided configuration and returns the location of the wrap
+func wrapChartWithConfig(ctx context.Context, inputPath string, outputFile string, platforms []string, flags *pflag.FlagSet, cfg *wrapConfig) (string, error) {
+	parentLog := cfg.logger()
+	startedOn := time.Now()
 
 	l := parentLog.StartSection(fmt.Sprintf("Wrapping Helm chart %q", inputPath))
-	chartPath, err := resolveInputChartPath(inputPath, l, flags)
+	chartPath, chartFile, err := resolveInputChartPath(inputPath, l, flags)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if err := prepareChartDependencies(chartPath, cfg, l); err != nil {
+		return "", err
 	}
 
 	chart, err := chartutils.LoadChart(chartPath)
 	if err != nil {
-		return fmt.Errorf("failed to load Helm chart: %w", err)
+		return "", fmt.Errorf("failed to load Helm chart: %w", err)
 	}
 	chartRoot := chart.RootDir()
 
-	lockFile, err := getImageLockFilePath(chartPath)
+	lockFile, err := wrap.LockFile(chartPath)
 	if err != nil {
-		return fmt.Errorf("failed to determine Images.lock file location: %w", err)
+		return "", fmt.Errorf("failed to determine Images.lock file location: %w", err)
 	}
-	if utils.FileExists(lockFile) {
-		if err := l.ExecuteStep("Verifying Images.lock", func() error {
-			return verifyLock(chartPath, lockFile)
-		}); err != nil {
-			return l.Failf("Failed to verify lock: %w", err)
-		}
-		l.Infof("Helm chart %q lock is valid", chartPath)
-
-	} else {
-		err := l.ExecuteStep(
-			"Images.lock file does not exist. Generating it from annotations...",
-			func() error {
-				return createImagesLock(chartPath,
-					lockFile, silentLog,
-					imagelock.WithPlatforms(platforms),
-					imagelock.WithContext(ctx),
-				)
-			},
-		)
-		if err != nil {
-			return l.Failf("Failed to generate lock: %w", err)
-		}
-		l.Infof("Images.lock file written to %q", lockFile)
+	if err := cfg.Summary.stage(ctx, "lock", func(ctx context.Context) error {
+		return prepareWrapLock(ctx, inputPath, chartPath, lockFile, platforms, cfg, l)
+	}); err != nil {
+		return "", err
 	}
 	if outputFile == "" {
-		outputBaseName := fmt.Sprintf("%s-%s.wrap.tgz", chart.Name(), chart.Metadata.Version)
+		outputBaseName := wrap.DefaultFileName(chart)
 		if outputFile, err = filepath.Abs(outputBaseName); err != nil {
 			l.Debugf("failed to normalize output file: %v", err)
 			outputFile = filepath.Join(filepath.Dir(chartRoot), outputBaseName)
 		}
 	}
-	if err := l.Section(fmt.Sprintf("Pulling images into %q", chart.ImagesDir()), func(childLog log.SectionLogger) error {
+	if err := cfg.Summary.stage(ctx, "pull images", func(ctx context.Context) error {
+		return pullWrapImages(ctx, chart, cfg, l)
+	}); err != nil {
+		return "", err
+	}
+
+	requestedVersion := requestedChartVersion(inputPath, flags)
+	logResolvedVersion(requestedVersion, chart, l)
+	metadataInput := &metadata.Input{
+		ChartRef: inputPath, ChartVersion: requestedVersion, ChartFile: chartFile, ImagesDir: chart.ImagesDir(),
+		Platforms: platforms, ToolVersion: Version, CreatedAt: startedOn,
+	}
+	if err := writeWrapMetadata(chart, metadataInput, l); err != nil {
+		return "", err
+	}
+
+	if err := cfg.Summary.stage(ctx, "process images", func(ctx context.Context) (err error) {
+		chart, err = processWrapImages(ctx, chart, cfg, l)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	if err := cfg.Summary.stage(ctx, "compress", func(ctx context.Context) (err error) {
+		outputFile, err = compressWrap(ctx, chart, outputFile, cfg, l)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	provenanceInput := &provenance.Input{
+		ChartRef: inputPath, ChartFile: chartFile, LockFile: lockFile, Platforms: platforms,
+		OutputFile: outputFile, ToolVersion: Version, StartedOn: startedOn, FinishedOn: time.Now(),
+	}
+	if err := attestWrap(ctx, provenanceInput, cfg, l); err != nil {
+		return "", err
+	}
+	if err := pushWrapArtifact(ctx, chart, cfg, l); err != nil {
+		return "", err
+	}
+
+	l.Printf(terminalSpacer)
+
+	parentLog.Successf("Helm chart wrapped into %q", outputFile)
+	if err := wrapSubcharts(ctx, chart, outputFile, platforms, cfg); err != nil {
+		return "", err
+	}
+	cfg.Summary.Output = outputFile
+	return outputFile, nil
+}
+
+// attestWrap writes the provenance statement and the signature of the wrap, if requested
+func attestWrap(ctx context.Context, input *provenance.Input, cfg *wrapConfig, l log.SectionLogger) error {
+	if cfg.Provenance {
+		if err := writeWrapProvenance(ctx, input, cfg, l); err != nil {
+			return err
+		}
+	}
+	return signWrap(input.OutputFile, cfg, l)
+}
+
+// prepareChartDependencies downloads the chart dependencies not vendored under charts/, if requested, or
+// warns otherwise, as their images are not wrapped. It then verifies the vendored dependencies match the
+// Chart.lock, so the wrapped chart can be installed reproducibly
+func prepareChartDependencies(chartPath string, cfg *wrapConfig, l log.SectionLogger) error {
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	if missing := chart.MissingDependencies(); len(missing) > 0 {
+		if !cfg.DependencyBuild {
+			// The Chart.lock cannot be verified without them
+			l.Warnf("Helm chart dependencies %s are not vendored under charts/, so their images are not wrapped: use --dependency-build to download them", strings.Join(missing, ", "))
+			return nil
+		}
+		if err := l.ExecuteStep("Building Helm chart dependencies", func() error {
+			return utils.BuildChartDependencies(chart.RootDir(), utils.FetchConfig{Transport: getTransport()})
+		}); err != nil {
+			return l.Failf("Failed to build Helm chart dependencies: %w", err)
+		}
+		l.Infof("Helm chart dependencies %s downloaded", strings.Join(missing, ", "))
+		if chart, err = chartutils.LoadChart(chartPath); err != nil {
+			return fmt.Errorf("failed to load Helm chart: %w", err)
+		}
+	}
+	if chart.Lock == nil {
+		return nil
+	}
+	if err := l.ExecuteStep("Verifying Helm chart dependencies against Chart.lock", chart.VerifyLock); err != nil {
+		return l.Failf("Helm chart dependencies do not match Chart.lock (run \"helm dependency update\" to refresh them): %w", err)
+	}
+	l.Infof("Helm chart dependencies match Chart.lock")
+	return nil
+}
+
+// signWrap writes a detached GPG signature of the wrap, if requested
+func signWrap(outputFile string, cfg *wrapConfig, l log.SectionLogger) error {
+	if cfg.SignKey == "" {
+		return nil
+	}
+	sigFile := signature.FileName(outputFile)
+	if err := l.ExecuteStep("Signing wrap...", func() error {
+		return signature.SignFile(outputFile, sigFile, cfg.SignOptions...)
+	}); err != nil {
+		return l.Failf("Failed to sign wrap: %w", err)
+	}
+	l.Infof("Signature written to %q", sigFile)
+	return nil
+}
+
+// pullWrapImages pulls the chart images into its images directory, recording the transfers in the run summary
+func pullWrapImages(ctx context.Context, chart *chartutils.Chart, cfg *wrapConfig, l log.SectionLogger) error {
+	return pullImagesSection(ctx, chart, cfg.imagesCacheDir(), cfg.Summary, l)
+}
+
+// pullImagesSection pulls the images in the chart Images.lock into its images directory, reusing the ones
+// in cacheDir, if not empty
+func pullImagesSection(ctx context.Context, chart *chartutils.Chart, cacheDir string, summary *runSummary, l log.SectionLogger) error {
+	return l.Section(fmt.Sprintf("Pulling images into %q", chart.ImagesDir()), func(childLog log.SectionLogger) error {
 		if err := pullChartImages(
 			chart,
-			chartutils.WithLog(childLog),
-			chartutils.WithContext(ctx),
-			chartutils.WithProgressBar(childLog.ProgressBar()),
+			append([]chartutils.Option{
+				chartutils.WithLog(childLog),
+				chartutils.WithContext(ctx),
+				chartutils.WithImagesCache(cacheDir),
+			}, imageTransferOptions(childLog, summary.handleImageEvent)...)...,
 		); err != nil {
 			return childLog.Failf("%v", err)
 		}
 		childLog.Infof("All images pulled successfully")
 		return nil
-	}); err != nil {
+	})
+}
+
+// writeWrapMetadata writes the wrap.json file describing the wrap into the chart directory
+func writeWrapMetadata(chart *chartutils.Chart, input *metadata.Input, l log.SectionLogger) error {
+	var metadataFile string
+	if err := l.ExecuteStep("Generating wrap metadata...", func() error {
+		lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+		if err != nil {
+			return fmt.Errorf("failed to read Images.lock: %w", err)
+		}
+		input.Lock = lock
+		metadataFile, err = wrap.WriteMetadata(chart, input)
 		return err
+	}); err != nil {
+		return l.Failf("Failed to generate wrap metadata: %w", err)
 	}
+	l.Infof("Wrap metadata written to %q", metadataFile)
+	return nil
+}
 
+// compressWrap compresses the chart into outputFile, encrypting it if requested, and returns the final wrap file.
+// Encrypted wraps are compressed into the temporary work directory, so the unencrypted wrap never reaches the
+// output location
+func compressWrap(ctx context.Context, chart *chartutils.Chart, outputFile string, cfg *wrapConfig, l log.SectionLogger) (string, error) {
+	compressedFile := outputFile
+	if len(cfg.EncryptRecipients) > 0 {
+		if !strings.HasSuffix(outputFile, encryption.Extension) {
+			outputFile += encryption.Extension
+		}
+		tmpDir, err := getGlobalTempWorkDir()
+		if err != nil {
+			return "", err
+		}
+		compressedFile = filepath.Join(tmpDir, filepath.Base(strings.TrimSuffix(outputFile, encryption.Extension)))
+	}
 	if err := l.ExecuteStep(
 		"Compressing Helm chart...",
 		func() error {
-			return compressChart(ctx, chart, outputFile)
+			return wrap.Compress(ctx, chart, compressedFile)
 		},
 	); err != nil {
-		return l.Failf("failed to wrap Helm chart: %w", err)
+		return "", l.Failf("failed to wrap Helm chart: %w", err)
+	}
+	if len(cfg.EncryptRecipients) == 0 {
+		l.Infof("Compressed into %q", compressedFile)
+		return outputFile, nil
 	}
-	l.Infof("Compressed into %q", outputFile)
 
-	l.Printf(terminalSpacer)
+	defer os.Remove(compressedFile)
+	if err := l.ExecuteStep("Encrypting wrap...", func() error {
+		return encryptWrap(compressedFile, outputFile, cfg.EncryptRecipients)
+	}); err != nil {
+		return "", l.Failf("Failed to encrypt wrap: %w", err)
+	}
+	l.Infof("Encrypted into %q", outputFile)
+	return outputFile, nil
+}
 
-	parentLog.Successf("Helm chart wrapped into %q", outputFile)
+// encryptWrap encrypts wrapFile into a temporary file next to outputFile, and only then moves it into place,
+// so a failed encryption does not leave a partial wrap behind
+func encryptWrap(wrapFile string, outputFile string, recipients []age.Recipient) error {
+	f, err := os.CreateTemp(filepath.Dir(outputFile), filepath.Base(outputFile)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpFile := f.Name()
+	defer os.Remove(tmpFile)
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	f.Close()
+	if err := encryption.EncryptFile(wrapFile, tmpFile, recipients...); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, outputFile)
+}
+
+// prepareWrapLock verifies the chart Images.lock, or the one attached to the OCI chart, translates the one
+// of Carvel bundles or, if none exists, generates it
+func prepareWrapLock(ctx context.Context, inputPath string, chartPath string, lockFile string, platforms []string, cfg *wrapConfig, l log.SectionLogger) error {
+	if translated, err := translateCarvelLock(ctx, chartPath, lockFile, platforms, l); err != nil || translated {
+		return err
+	}
+	if err := useAttachedLock(ctx, inputPath, chartPath, lockFile, l); err != nil {
+		return err
+	}
+	if utils.FileExists(lockFile) {
+		if err := l.ExecuteStep("Verifying Images.lock", func() error {
+			return verifyLock(chartPath, lockFile)
+		}); err != nil {
+			return l.Failf("Failed to verify lock: %w", err)
+		}
+		l.Infof("Helm chart %q lock is valid", chartPath)
+		return nil
+	}
+	if cfg.PinMutableTags {
+		if err := l.ExecuteStep("Pinning images using mutable tags...", func() error {
+			return pinMutableImages(chartPath, l)
+		}); err != nil {
+			return l.Failf("Failed to pin images: %w", err)
+		}
+	}
+	if err := l.ExecuteStep("Checking untagged images...", func() error {
+		return handleUntaggedImages(chartPath, l)
+	}); err != nil {
+		return l.Failf("Failed to handle untagged images: %w", err)
+	}
+	err := l.ExecuteStep(
+		"Images.lock file does not exist. Generating it from annotations...",
+		func() error {
+			return createImagesLock(chartPath,
+				lockFile, log.SilentLog,
+				imagelock.WithPlatforms(platforms),
+				imagelock.WithContext(ctx),
+				imagelock.WithRejectMutableTags(cfg.RejectMutableTags),
+			)
+		},
+	)
+	if err != nil {
+		return l.Failf("Failed to generate lock: %w", err)
+	}
+	l.Infof("Images.lock file written to %q", lockFile)
 	return nil
 }
 
+// processWrapImages performs the steps over the pulled images before compressing the wrap, returning the
+// chart to compress: a staged copy of it, for delta wraps
+func processWrapImages(ctx context.Context, chart *chartutils.Chart, cfg *wrapConfig, l log.SectionLogger) (*chartutils.Chart, error) {
+	if cfg.Scanner != nil {
+		if err := l.Section(fmt.Sprintf("Scanning images with %s", cfg.Scanner.Name()), func(childLog log.SectionLogger) error {
+			return scanChartImages(ctx, chart, cfg, childLog)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.PolicyPaths) > 0 {
+		if err := l.Section("Checking images policies", func(childLog log.SectionLogger) error {
+			return checkChartPolicies(ctx, chart.RootDir(), cfg.PolicyPaths, childLog)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.SBOMFormat != "" {
+		sbomFile := chart.AbsFilePath(cfg.SBOMFormat.FileName())
+		if err := l.ExecuteStep("Generating SBOM...", func() error {
+			return writeChartSBOM(chart, sbomFile, cfg.SBOMFormat, cfg.SBOMFile)
+		}); err != nil {
+			return nil, l.Failf("Failed to generate SBOM: %w", err)
+		}
+		l.Infof("SBOM written to %q", sbomFile)
+		if cfg.SBOMFile != "" {
+			l.Infof("SBOM copied to %q", cfg.SBOMFile)
+		}
+	}
+
+	if cfg.DeltaFrom != "" {
+		var err error
+		if chart, err = stageWrapDelta(ctx, chart, cfg.DeltaFrom, l); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.ExecuteStep("Generating checksums...", func() error {
+		return utils.WriteChecksums(chart.RootDir(), utils.ChecksumsFileName)
+	}); err != nil {
+		return nil, l.Failf("Failed to generate checksums: %w", err)
+	}
+	return chart, nil
+}
+
+// wrapFlags holds the wrap command flags that translate into wrapOptions
+type wrapFlags struct {
+	withSBOM          bool
+	sbomFormat        string
+	sbomFile          string
+	scannerName       string
+	scanSeverity      string
+	scanWarnOnly      bool
+	policyPaths       []string
+	rejectMutableTags bool
+	pinMutableTags    bool
+	withProvenance    bool
+	provenanceKey     string
+	signKey           string
+	keyring           string
+	passphraseFile    string
+	encryptRecipients []string
+	dependencyBuild   bool
+	splitSubcharts    bool
+	pushURL           string
+	deltaFrom         string
+}
+
+// options returns the wrapOptions requested by the flags
+func (f *wrapFlags) options() ([]wrapOption, error) {
+	opts := make([]wrapOption, 0)
+	if f.withSBOM || f.sbomFile != "" {
+		format, err := sbom.ParseFormat(f.sbomFormat)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, withSBOM(format, f.sbomFile))
+	}
+	if f.scannerName != "" {
+		opt, err := f.scannerOption()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if len(f.policyPaths) > 0 {
+		opts = append(opts, withPolicies(f.policyPaths))
+	}
+	opts = append(opts, withMutableTags(f.rejectMutableTags, f.pinMutableTags), withDeltaFrom(f.deltaFrom))
+	if f.dependencyBuild {
+		opts = append(opts, withDependencyBuild)
+	}
+	if f.splitSubcharts {
+		opts = append(opts, withSplitSubcharts)
+	}
+	if f.pushURL != "" {
+		opts = append(opts, withPushWrap(f.pushURL))
+	}
+	if f.withProvenance || f.provenanceKey != "" {
+		opts = append(opts, withProvenance(f.provenanceKey))
+	}
+	if f.signKey != "" {
+		signOpts, err := signOptions(f.signKey, f.keyring, f.passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, withSignature(f.signKey, signOpts...))
+	}
+	if len(f.encryptRecipients) > 0 {
+		recipients, err := parseRecipients(f.encryptRecipients)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, withEncryption(recipients...))
+	}
+	return opts, nil
+}
+
+// scannerOption returns the wrapOption scanning the images with the requested scanner
+func (f *wrapFlags) scannerOption() (wrapOption, error) {
+	scanner, err := scan.New(f.scannerName)
+	if err != nil {
+		return nil, err
+	}
+	severity, err := scan.ParseSeverity(f.scanSeverity)
+	if err != nil {
+		return nil, err
+	}
+	return withScanner(scanner, severity, f.scanWarnOnly), nil
+}
+
+// parseRecipients parses the age recipients the wrap is encrypted for
+func parseRecipients(specs []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(specs))
+	for _, spec := range specs {
+		r, err := encryption.ParseRecipient(spec)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
 func newWrapCommand() *cobra.Command {
 	var outputFile string
+	var manifestFile string
 	var version string
+	var chartName string
 	var platforms []string
+	var verifyChart bool
+	var quiet bool
+	var outputFormat = textOutput
+	metrics := &metricsConfig{}
+	var chartKeyringFile = signature.DefaultPublicKeyring()
+	f := &wrapFlags{
+		sbomFormat:   string(sbom.SPDX),
+		scanSeverity: scan.SeverityHigh.String(),
+		keyring:      signature.DefaultSecretKeyring(),
+	}
 	var examples = `  # Wrap a Helm chart from a local folder
   $ dt wrap examples/mariadb
 
   # Wrap a Helm chart in an OCI registry
   $ dt wrap oci://docker.io/bitnamicharts/mariadb
+
+  # Wrap a Helm chart from a classic Helm repository
+  $ dt wrap https://charts.bitnami.com/bitnami --chart mariadb --version 12.2.8
+
+  # Wrap the latest 12.x version of a Helm chart in an OCI registry
+  $ dt wrap oci://docker.io/bitnamicharts/mariadb --version ">=12.0.0 <13"
+
+  # Wrap a Helm chart from a repository added with "helm repo add"
+  $ dt wrap bitnami/mariadb --version 12.2.8
+
+  # Wrap every Helm chart listed in a manifest file, pulling the images they share only once
+  $ dt wrap -f charts.yaml
+
+  # Wrap a Helm chart downloading its dependencies first
+  $ dt wrap examples/wordpress --dependency-build
+
+  # Wrap a Helm chart and push the wrap as an OCI artifact (oci://harbor.example.com/wraps/mariadb-wrap:VERSION)
+  $ dt wrap examples/mariadb --push oci://harbor.example.com/wraps
+
+  # Wrap a Helm chart uploading the wrap to an S3 bucket (also gs:// and azblob://)
+  $ dt wrap examples/mariadb --output-file s3://my-bucket/wraps/mariadb.wrap.tgz
+
+  # Wrap a Helm chart including only the images not already included in its previous wrap
+  $ dt wrap examples/mariadb --delta-from mariadb-12.2.7.wrap.tgz
+
+  # Wrap an umbrella Helm chart and each of its subcharts separately
+  $ dt wrap examples/wordpress --split-subcharts
+
+  # Wrap a Helm chart including a CycloneDX SBOM, also written alongside the wrap
+  $ dt wrap examples/mariadb --sbom --sbom-format cyclonedx --sbom-file mariadb.cdx.json
+
+  # Wrap a Helm chart failing if trivy finds critical vulnerabilities in its images
+  $ dt wrap examples/mariadb --scan trivy --scan-severity critical
+
+  # Wrap a Helm chart checking its images against a set of Rego policies
+  $ dt wrap examples/mariadb --policy policies/
+
+  # Wrap a Helm chart from an OCI registry verifying its provenance file
+  $ dt wrap oci://docker.io/bitnamicharts/mariadb --verify-chart --chart-keyring ~/.gnupg/pubring.gpg
+
+  # Wrap a Helm chart writing a provenance statement signed with cosign
+  $ dt wrap examples/mariadb --provenance-key cosign.key
+
+  # Wrap a Helm chart writing a detached GPG signature next to it
+  $ dt wrap examples/mariadb --sign-key ops@example.com --keyring ~/.gnupg/secring.gpg
+
+  # Wrap a Helm chart and copy the wrap to another host
+  $ scp "$(dt wrap --quiet examples/mariadb)" airgap.example.com:
+
+  # Wrap a Helm chart encrypting it for an age recipient
+  $ dt wrap examples/mariadb --encrypt age:age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
 	`
 	cmd := &cobra.Command{
-		Use:   "wrap CHART_PATH|OCI_URI",
+		Use:   "wrap CHART_PATH|OCI_URI|REPO_URL|REPO/NAME | -f MANIFEST_FILE",
 		Short: "Wraps a Helm chart",
 		Long: `Wraps a Helm chart either local or remote into a distributable package.
 This command will pull all the container images and wrap it into a single tarball along with the Images.lock and metadata`,
 		Example:       examples,
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		Args:          cobra.ExactArgs(1),
+		Args:          cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			chartPath := args[0]
-
+			if err := validateWrapInput(args, manifestFile, outputFile, f); err != nil {
+				return err
+			}
 			ctx, cancel := contextWithSigterm(context.Background())
 			defer cancel()
 
-			err := wrapChart(ctx, chartPath, outputFile, platforms, cmd.Flags())
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			opts, err := f.options()
+			if err != nil {
+				return err
+			}
+
+			if quiet {
+				opts = append(opts, withQuietLog)
+			}
+			summary := newRunSummary("wrap")
+			opts = append(opts, withRunSummary(summary), withOutputFormat(outputFormat))
+			wrapFiles, err := runWrap(ctx, args, manifestFile, outputFile, platforms, summary, cmd.Flags(), opts...)
+			if mErr := exportRunMetrics(summary, err, metrics); mErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", mErr)
+			}
 			if err != nil {
 				if _, ok := err.(*log.LoggedError); ok {
 					// We already logged it, lets be less verbose
@@ -133,57 +771,270 @@ This command will pull all the container images and wrap it into a single tarbal
 				}
 				return err
 			}
-			return nil
+			if quiet && !isStructuredOutput(outputFormat) {
+				fmt.Println(strings.Join(wrapFiles, "\n"))
+				return nil
+			}
+			return reportRunSummary(summary, outputFormat)
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(&version, "version", version, "when wrapping remote Helm charts from OCI, version to request")
-	cmd.PersistentFlags().StringVar(&outputFile, "output-file", outputFile, "generate a tar.gz with the output of the pull operation")
-	cmd.PersistentFlags().StringSliceVar(&platforms, "platforms", platforms, "platforms to include in the Images.lock file")
+	addOutputFlag(cmd, &outputFormat)
+	addMetricsFlags(cmd, metrics)
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", quiet, "only print the location of the resulting wrap, and errors")
+	cmd.Flags().StringVar(&version, "version", version, "when wrapping remote Helm charts, version or version range (\">=12.0.0 <13\") to request. Defaults to the latest stable version")
+	cmd.Flags().StringVar(&chartName, "chart", chartName, "when wrapping from a classic Helm repository URL, name of the chart to fetch from it")
+	cmd.Flags().StringVarP(&manifestFile, "file", "f", manifestFile, "wrap every Helm chart listed in the given manifest file, sharing the pulled images between them")
+	cmd.Flags().StringVar(&outputFile, "output-file", outputFile, "generate a tar.gz with the output of the pull operation. Object storage URLs (s3://, gs:// and azblob://) upload it using the aws, gcloud or azcopy command line tools")
+	cmd.Flags().StringSliceVar(&platforms, "platforms", platforms, "platforms to include in the Images.lock file")
+	cmd.Flags().BoolVar(&f.dependencyBuild, "dependency-build", f.dependencyBuild, "download the Helm chart dependencies not vendored under charts/ before wrapping it, as \"helm dependency build\" does")
+	cmd.Flags().BoolVar(&f.splitSubcharts, "split-subcharts", f.splitSubcharts, "also wrap each first-level subchart into its own wrap, next to the umbrella chart one")
+	cmd.Flags().StringVar(&f.pushURL, "push", f.pushURL, "also push the wrap as an OCI artifact under the given registry namespace (oci://REGISTRY/NAMESPACE), as NAME-wrap:VERSION")
+	cmd.Flags().StringVar(&f.deltaFrom, "delta-from", f.deltaFrom, "previous wrap of the Helm chart: the images it already includes are omitted from the wrap, and read from it when unwrapping with --delta-base")
+	cmd.Flags().BoolVar(&f.withSBOM, "sbom", f.withSBOM, "embed a SBOM document of the chart and its images in the wrap")
+	cmd.Flags().StringVar(&f.sbomFormat, "sbom-format", f.sbomFormat, "format of the generated SBOM document (spdx, cyclonedx)")
+	cmd.Flags().StringVar(&f.sbomFile, "sbom-file", f.sbomFile, "also write the generated SBOM document to the given file (implies --sbom)")
+	cmd.Flags().StringVar(&f.scannerName, "scan", f.scannerName, "scan the pulled images for vulnerabilities with the given scanner (trivy, grype)")
+	cmd.Flags().StringVar(&f.scanSeverity, "scan-severity", f.scanSeverity, "minimum vulnerability severity that makes the scan fail (low, medium, high, critical)")
+	cmd.Flags().BoolVar(&f.scanWarnOnly, "scan-warn-only", f.scanWarnOnly, "only warn about the vulnerabilities found instead of failing")
+	cmd.Flags().StringSliceVar(&f.policyPaths, "policy", f.policyPaths, "Rego policy file or directory the images must comply with (can be repeated)")
+	cmd.Flags().BoolVar(&f.rejectMutableTags, "reject-mutable-tags", f.rejectMutableTags, "when generating the Images.lock, fail if any image uses the \"latest\" tag or no tag at all")
+	cmd.Flags().BoolVar(&f.pinMutableTags, "pin-mutable-tags", f.pinMutableTags, "when generating the Images.lock, pin images using mutable tags to their current digests")
+	cmd.Flags().BoolVar(&f.withProvenance, "provenance", f.withProvenance, "write a SLSA provenance statement describing the wrap next to it")
+	cmd.Flags().StringVar(&f.provenanceKey, "provenance-key", f.provenanceKey, "sign the provenance statement with the given cosign key (implies --provenance)")
+	cmd.Flags().StringVar(&f.signKey, "sign-key", f.signKey, "write a detached GPG signature of the wrap made with the given key (user id, long key id or fingerprint)")
+	cmd.Flags().StringVar(&f.keyring, "keyring", f.keyring, "location of the secret keyring used with --sign-key")
+	cmd.Flags().StringVar(&f.passphraseFile, "passphrase-file", f.passphraseFile, "file containing the passphrase of the signing key")
+	cmd.Flags().BoolVar(&verifyChart, "verify-chart", verifyChart, "verify the Helm chart provenance file before wrapping it (packaged or remote charts only)")
+	cmd.Flags().StringVar(&chartKeyringFile, "chart-keyring", chartKeyringFile, "location of the public keyring used with --verify-chart")
+	cmd.Flags().StringSliceVar(&f.encryptRecipients, "encrypt", f.encryptRecipients, "encrypt the wrap for the given recipient, either age:<public key> or passphrase:<file> (can be repeated)")
 
 	return cmd
 }
 
-func resolveInputChartPath(inputPath string, l log.SectionLogger, flags *pflag.FlagSet) (string, error) {
-	var chartPath string
+func scanChartImages(ctx context.Context, chart *chartutils.Chart, cfg *wrapConfig, l log.SectionLogger) error {
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return l.Failf("Failed to read Images.lock: %w", err)
+	}
+	reports, err := chartutils.ScanImages(lock, chart.ImagesDir(), cfg.Scanner,
+		chartutils.WithLog(l),
+		chartutils.WithContext(ctx),
+		chartutils.WithProgressBar(l.ProgressBar()),
+	)
+	if err != nil {
+		return l.Failf("%v", err)
+	}
+	failedImages := make([]string, 0)
+	for _, r := range reports {
+		found := r.AtOrAbove(cfg.ScanSeverity)
+		if len(found) == 0 {
+			l.Debugf("Image %s (%s): %s", r.Image, r.Arch, r.Summary())
+			continue
+		}
+		l.Warnf("Image %s (%s): %s", r.Image, r.Arch, r.Summary())
+		failedImages = append(failedImages, fmt.Sprintf("%s (%s)", r.Image, r.Arch))
+	}
+	if len(failedImages) == 0 {
+		l.Infof("No vulnerabilities of severity %s or higher found", cfg.ScanSeverity)
+		return nil
+	}
+	if cfg.ScanWarnOnly {
+		l.Warnf("Found vulnerabilities of severity %s or higher in %d images", cfg.ScanSeverity, len(failedImages))
+		return nil
+	}
+	return l.Failf("found vulnerabilities of severity %s or higher in images: %s", cfg.ScanSeverity, strings.Join(failedImages, ", "))
+}
+
+func writeChartSBOM(chart *chartutils.Chart, file string, format sbom.Format, extraFile string) error {
+	lock, err := imagelock.FromYAMLFile(chart.AbsFilePath(imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return fmt.Errorf("failed to read Images.lock: %w", err)
+	}
+	buff := &bytes.Buffer{}
+	if err := sbom.Write(buff, lock, format, sbom.WithToolVersion(Version)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, buff.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM to %q: %w", file, err)
+	}
+	if extraFile != "" {
+		if err := os.WriteFile(extraFile, buff.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write SBOM to %q: %w", extraFile, err)
+		}
+	}
+	return nil
+}
+
+func writeWrapProvenance(ctx context.Context, input *provenance.Input, cfg *wrapConfig, l log.SectionLogger) error {
+	provenanceFile := input.OutputFile + ".provenance.json"
+	if err := l.ExecuteStep("Generating provenance statement...", func() error {
+		lock, err := imagelock.FromYAMLFile(input.LockFile)
+		if err != nil {
+			return fmt.Errorf("failed to read Images.lock: %w", err)
+		}
+		input.Lock = lock
+		st, err := provenance.New(input)
+		if err != nil {
+			return err
+		}
+		buff := &bytes.Buffer{}
+		if err := st.Write(buff); err != nil {
+			return err
+		}
+		if err := os.WriteFile(provenanceFile, buff.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write provenance to %q: %w", provenanceFile, err)
+		}
+		if cfg.ProvenanceKey != "" {
+			return provenance.NewSigner(cfg.ProvenanceKey).Sign(ctx, provenanceFile, provenanceFile+".sig")
+		}
+		return nil
+	}); err != nil {
+		return l.Failf("Failed to generate provenance: %w", err)
+	}
+	l.Infof("Provenance statement written to %q", provenanceFile)
+	if cfg.ProvenanceKey != "" {
+		l.Infof("Provenance signature written to %q", provenanceFile+".sig")
+	}
+	return nil
+}
 
+// isRemoteChart returns true if the chart has to be fetched from an OCI registry or HTTP repository
+func isRemoteChart(inputPath string) bool {
+	for _, scheme := range []string{"oci://", "http://", "https://"} {
+		if strings.HasPrefix(inputPath, scheme) {
+			return true
+		}
+	}
+	// Local paths take precedence over the REPO/NAME charts of the Helm repositories
+	return !utils.FileExists(inputPath) && utils.IsRepositoryChartReference(inputPath)
+}
+
+// requestedChartVersion returns the version, or version range, requested for the remote chart in inputPath,
+// "latest" if none was provided, or an empty string for local charts
+func requestedChartVersion(inputPath string, flags *pflag.FlagSet) string {
+	if !isRemoteChart(inputPath) {
+		return ""
+	}
+	if version, _ := flags.GetString("version"); version != "" {
+		return version
+	}
+	return "latest"
+}
+
+// logResolvedVersion reports the exact chart version the requested version resolved to, if they differ
+func logResolvedVersion(requestedVersion string, chart *chartutils.Chart, l log.SectionLogger) {
+	if requestedVersion != "" && requestedVersion != chart.Metadata.Version {
+		l.Infof("Helm chart version %q resolved to %q", requestedVersion, chart.Metadata.Version)
+	}
+}
+
+// chartKeyring returns the keyring to verify the input chart provenance with, or an empty string if
+// the verification was not requested
+func chartKeyring(flags *pflag.FlagSet) string {
+	if verify, err := flags.GetBool("verify-chart"); err != nil || !verify {
+		return ""
+	}
+	keyring, _ := flags.GetString("chart-keyring")
+	return keyring
+}
+
+// resolveInputChartPath returns the directory of the input chart, fetching or uncompressing it if needed,
+// and the packaged chart it was read from, if any
+func resolveInputChartPath(inputPath string, l log.SectionLogger, flags *pflag.FlagSet) (string, string, error) {
 	tmpDir, err := getGlobalTempWorkDir()
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	keyring := chartKeyring(flags)
+
+	// Only wrap accepts charts from classic Helm repositories
+	chartName, _ := flags.GetString("chart")
+	if chartName != "" && !utils.IsChartRepositoryURL(inputPath) {
+		return "", "", fmt.Errorf("--chart can only be used with http(s) Helm repository URLs")
 	}
 
-	if strings.HasPrefix(inputPath, "oci://") {
+	chartFile := inputPath
+	if isRemoteChart(inputPath) {
 		if err := l.ExecuteStep("Fetching remote Helm chart", func() error {
 			version, err := flags.GetString("version")
 			if err != nil {
 				return fmt.Errorf("failed to retrieve version flag: %w", err)
 			}
-			chartPath, err = fetchRemoteChart(inputPath, version, tmpDir)
-			if err != nil {
-				return err
-			}
-			return nil
+			chartFile, err = pullRemoteChart(inputPath, chartName, version, tmpDir, keyring)
+			return err
 		}); err != nil {
-			return "", l.Failf("Failed to download Helm chart: %w", err)
+			return "", "", l.Failf("Failed to download Helm chart: %w", err)
 		}
-		l.Infof("Helm chart downloaded to %q", chartPath)
-	} else if isTar, _ := utils.IsTarFile(inputPath); isTar {
-		if err := l.ExecuteStep("Uncompressing Helm chart", func() error {
+		l.Infof("Helm chart downloaded to %q", chartFile)
+		if keyring != "" {
+			l.Infof("Helm chart provenance verified")
+		}
+	} else if isTar, _ := utils.IsTarFile(inputPath); !isTar {
+		if keyring != "" {
+			return "", "", l.Failf("Helm chart provenance can only be verified for packaged or remote charts")
+		}
+		return inputPath, "", nil
+	} else if keyring != "" {
+		var identities []string
+		if err := l.ExecuteStep("Verifying Helm chart provenance", func() error {
 			var err error
-			chartPath, err = untarChart(inputPath, tmpDir)
+			identities, err = utils.VerifyChartProvenance(inputPath, inputPath+utils.ProvenanceExtension, keyring)
 			return err
 		}); err != nil {
-			return "", l.Failf("Failed to uncompress %q: %w", inputPath, err)
+			return "", "", l.Failf("Failed to verify Helm chart: %w", err)
 		}
-		l.Infof("Helm chart uncompressed to %q", chartPath)
-	} else {
-		chartPath = inputPath
+		l.Infof("Helm chart signed by %s", strings.Join(identities, ", "))
 	}
 
-	return chartPath, nil
+	var chartPath string
+	if err := l.ExecuteStep("Uncompressing Helm chart", func() error {
+		var err error
+		chartPath, err = wrap.Extract(context.Background(), chartFile, tmpDir)
+		if err != nil {
+			return err
+		}
+		return copyProvenanceFile(chartFile+utils.ProvenanceExtension, chartPath)
+	}); err != nil {
+		return "", "", l.Failf("Failed to uncompress %q: %w", chartFile, err)
+	}
+	l.Infof("Helm chart uncompressed to %q", chartPath)
+
+	return chartPath, chartFile, nil
 }
-func fetchRemoteChart(chartURL string, version string, dir string) (string, error) {
-	return utils.FetchRemoteChart(chartURL, version, dir)
+
+// copyProvenanceFile copies provFile, if it exists, into the chart directory
+func copyProvenanceFile(provFile string, chartPath string) error {
+	if !utils.FileExists(provFile) {
+		return nil
+	}
+	data, err := os.ReadFile(provFile)
+	if err != nil {
+		return fmt.Errorf("failed to read provenance file: %w", err)
+	}
+	return os.WriteFile(filepath.Join(chartPath, filepath.Base(provFile)), data, 0644)
+}
+
+func pullRemoteChart(chartURL string, chartName string, version string, dir string, keyring string) (string, error) {
+	if chartName != "" {
+		username, password, err := chartRepositoryCredentials(chartURL)
+		if err != nil {
+			return "", err
+		}
+		return utils.PullChart(chartName, version, dir, utils.FetchConfig{
+			Keyring: keyring, RepoURL: chartURL, Username: username, Password: password, Transport: getTransport(),
+		})
+	}
+	var credentialsFile string
+	// Only the OCI registries are authenticated with the registry credentials
+	if strings.HasPrefix(chartURL, "oci://") {
+		var err error
+		if credentialsFile, err = writeHelmCredentialsFile(dir, chartURL); err != nil {
+			return "", err
+		}
+		defer os.Remove(credentialsFile)
+	}
+	return utils.PullChart(chartURL, version, dir, utils.FetchConfig{Keyring: keyring, CredentialsFile: credentialsFile, Transport: getTransport()})
 }
 
 func init() {
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/artifact"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// validatePushWrap checks the wrap can be pushed as an OCI artifact into pushURL
+func validatePushWrap(pushURL string, encrypted bool) error {
+	if pushURL == "" {
+		return nil
+	}
+	if !strings.HasPrefix(pushURL, "oci://") {
+		return fmt.Errorf("--push only supports OCI registries (oci://REGISTRY/NAMESPACE)")
+	}
+	if encrypted {
+		return fmt.Errorf("--push cannot be used with --encrypt, as the artifact layers are not encrypted")
+	}
+	return nil
+}
+
+// pushWrapArtifact pushes the wrapped chart as an OCI artifact, if requested
+func pushWrapArtifact(ctx context.Context, chart *chartutils.Chart, cfg *wrapConfig, l log.SectionLogger) error {
+	if cfg.PushURL == "" {
+		return nil
+	}
+	if err := flagsKeychain.scopeDefaultAuth(cfg.PushURL); err != nil {
+		return err
+	}
+	ref := artifact.Reference(cfg.PushURL, chart.Name(), chart.Metadata.Version)
+	return cfg.Summary.stage(ctx, "push wrap", func(ctx context.Context) error {
+		if err := l.ExecuteStep(fmt.Sprintf("Pushing wrap to %q", ref), func() error {
+			_, err := artifact.Push(chart.RootDir(), ref, artifactOptions(ctx)...)
+			return err
+		}); err != nil {
+			return l.Failf("Failed to push wrap: %w", err)
+		}
+		l.Infof("Wrap pushed to %q", "oci://"+ref)
+		return nil
+	})
+}
+
+// artifactOptions returns the options used to access the wrap artifacts
+func artifactOptions(ctx context.Context) []artifact.Option {
+	return []artifact.Option{artifact.WithContext(ctx), artifact.WithKeychain(getKeychain()), artifact.WithTransport(getTransport())}
+}
+
+// isWrapArtifactRef returns whether inputChart is an OCI reference to a wrap artifact, instead of a Helm chart
+func isWrapArtifactRef(ctx context.Context, inputChart string) bool {
+	return strings.HasPrefix(inputChart, "oci://") &&
+		artifact.IsWrapArtifact(strings.TrimPrefix(inputChart, "oci://"), artifactOptions(ctx)...)
+}
+
+// pullWrapArtifact downloads the wrap artifact in inputChart into a directory under tempDir, and returns it
+func pullWrapArtifact(ctx context.Context, inputChart string, tempDir string, l log.SectionLogger) (string, error) {
+	chartPath, err := os.MkdirTemp(tempDir, "wrap-artifact-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if err := l.ExecuteStep(fmt.Sprintf("Pulling wrap from %q", inputChart), func() error {
+		return artifact.Pull(strings.TrimPrefix(inputChart, "oci://"), chartPath, artifactOptions(ctx)...)
+	}); err != nil {
+		return "", l.Failf("Failed to pull wrap: %w", err)
+	}
+	l.Infof("Wrap pulled into %q", chartPath)
+	return chartPath, nil
+}
+
+// useAttachedLock writes the Images.lock attached to the OCI chart in inputPath as lockFile, if the chart
+// does not include one, so it is verified instead of generated
+func useAttachedLock(ctx context.Context, inputPath string, chartPath string, lockFile string, l log.SectionLogger) error {
+	if !strings.HasPrefix(inputPath, "oci://") || utils.FileExists(lockFile) {
+		return nil
+	}
+	chart, err := chartutils.LoadChart(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+	ref := fmt.Sprintf("%s:%s", strings.TrimPrefix(inputPath, "oci://"), artifact.ChartVersionTag(chart.Metadata.Version))
+	var data []byte
+	if err := l.ExecuteStep("Looking for an Images.lock attached to the Helm chart", func() error {
+		data, err = artifact.FetchImagesLock(ref, artifactOptions(ctx)...)
+		return err
+	}); err != nil {
+		// Registries not supporting referrers should not prevent wrapping the chart
+		l.Warnf("Failed to look for an attached Images.lock: %v", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	if err := os.WriteFile(lockFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write attached Images.lock: %w", err)
+	}
+	l.Infof("Using the Images.lock attached to %q", ref)
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// withDeltaFrom omits from the wrap the images already included in the baseWrap file, if not empty
+func withDeltaFrom(baseWrap string) func(cfg *wrapConfig) {
+	return func(cfg *wrapConfig) {
+		cfg.DeltaFrom = baseWrap
+	}
+}
+
+// validateDeltaBase returns an error if baseWrap, if not empty, cannot be used as the base of a delta wrap
+func validateDeltaBase(baseWrap string) error {
+	if baseWrap == "" {
+		return nil
+	}
+	if !utils.FileExists(baseWrap) {
+		return fmt.Errorf("base wrap %q does not exist", baseWrap)
+	}
+	if isEncrypted, _ := encryption.IsEncrypted(baseWrap); isEncrypted {
+		return fmt.Errorf("base wrap %q is encrypted and must be decrypted first", baseWrap)
+	}
+	return nil
+}
+
+// imageTarDigest returns the digest of the platform specific image stored in the images directory file, if any
+func imageTarDigest(file string) (digest.Digest, bool) {
+	hex, found := strings.CutSuffix(file, ".tar")
+	if !found {
+		return "", false
+	}
+	dgst := digest.NewDigestFromEncoded(digest.SHA256, hex)
+	return dgst, dgst.Validate() == nil
+}
+
+// wrapImageFile returns the digest of the platform specific image stored in the wrap entry name, if any
+func wrapImageFile(name string) (digest.Digest, bool) {
+	// Wrap entries are prefixed by the chart directory
+	dir, file := path.Split(strings.TrimPrefix(path.Clean(name), "/"))
+	if path.Base(dir) != "images" || strings.Count(dir, "/") != 2 {
+		return "", false
+	}
+	return imageTarDigest(file)
+}
+
+// walkWrapImages calls fn for every platform specific image stored in the wrapFile tarball
+func walkWrapImages(ctx context.Context, wrapFile string, fn func(dgst digest.Digest, r io.Reader) error) error {
+	return utils.WalkTarFile(ctx, wrapFile, func(tr *tar.Reader, header *tar.Header) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		if dgst, ok := wrapImageFile(header.Name); ok {
+			return fn(dgst, tr)
+		}
+		return nil
+	})
+}
+
+// deltaBase returns the description of baseWrap and the digests of the images it includes
+func deltaBase(ctx context.Context, baseWrap string) (metadata.DeltaBase, map[digest.Digest]bool, error) {
+	base := metadata.DeltaBase{File: filepath.Base(baseWrap)}
+	sum, err := utils.FileSHA256(baseWrap)
+	if err != nil {
+		return base, nil, fmt.Errorf("failed to digest base wrap: %w", err)
+	}
+	base.Digest = digest.NewDigestFromEncoded(digest.SHA256, sum)
+	images := make(map[digest.Digest]bool)
+	if err := walkWrapImages(ctx, baseWrap, func(dgst digest.Digest, _ io.Reader) error {
+		images[dgst] = true
+		return nil
+	}); err != nil {
+		return base, nil, fmt.Errorf("failed to read base wrap %q: %w", baseWrap, err)
+	}
+	return base, images, nil
+}
+
+// stageWrapDelta copies the chart into the temporary work directory and turns the copy into a delta of
+// baseWrap, returning it, so the images omitted from the wrap are never removed from the chart
+func stageWrapDelta(ctx context.Context, chart *chartutils.Chart, baseWrap string, l log.SectionLogger) (*chartutils.Chart, error) {
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return nil, l.Failf("Failed to stage delta wrap: %w", err)
+	}
+	stagedPath, err := wrap.Stage(chart.RootDir(), tmpDir)
+	if err != nil {
+		return nil, l.Failf("Failed to stage delta wrap: %w", err)
+	}
+	staged, err := chartutils.LoadChart(stagedPath)
+	if err != nil {
+		return nil, l.Failf("Failed to stage delta wrap: %w", err)
+	}
+	if err := writeWrapDelta(ctx, staged, baseWrap, l); err != nil {
+		return nil, err
+	}
+	return staged, nil
+}
+
+// writeWrapDelta removes from the staged chart the images already included in the base wrap, and
+// describes them in its delta.json file
+func writeWrapDelta(ctx context.Context, chart *chartutils.Chart, baseWrap string, l log.SectionLogger) error {
+	var omitted []digest.Digest
+	if err := l.ExecuteStep(fmt.Sprintf("Comparing images with base wrap %q...", baseWrap), func() error {
+		base, baseImages, err := deltaBase(ctx, baseWrap)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(chart.ImagesDir())
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read images directory: %w", err)
+		}
+		omitted = make([]digest.Digest, 0)
+		for _, e := range entries {
+			dgst, ok := imageTarDigest(e.Name())
+			if !ok || !baseImages[dgst] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(chart.ImagesDir(), e.Name())); err != nil {
+				return fmt.Errorf("failed to remove image %q: %w", dgst, err)
+			}
+			omitted = append(omitted, dgst)
+		}
+		return metadata.NewDelta(base, omitted).WriteFile(chart.AbsFilePath(metadata.DeltaFileName))
+	}); err != nil {
+		return l.Failf("Failed to generate delta wrap: %w", err)
+	}
+	l.Infof("%d images already included in %q omitted from the wrap", len(omitted), baseWrap)
+	return nil
+}
+
+// readWrapDelta returns the delta.json of the chart at chartPath, or nil if it is not a delta wrap
+func readWrapDelta(chartPath string) (*metadata.Delta, error) {
+	deltaFile := filepath.Join(chartPath, metadata.DeltaFileName)
+	if !utils.FileExists(deltaFile) {
+		return nil, nil
+	}
+	return metadata.DeltaFromFile(deltaFile)
+}
+
+// restoreDeltaImages extracts the images omitted from the delta wrap at chartPath from its base wrap
+func restoreDeltaImages(ctx context.Context, chartPath string, baseWrap string, l log.SectionLogger) error {
+	delta, err := readWrapDelta(chartPath)
+	if err != nil {
+		return l.Failf("%w", err)
+	}
+	if delta == nil {
+		if baseWrap != "" {
+			l.Warnf("The wrap is not a delta wrap, ignoring its base wrap %q", baseWrap)
+		}
+		return nil
+	}
+	if baseWrap == "" {
+		return l.Failf("the wrap is a delta of %q, provide it with --delta-base", delta.Base.File)
+	}
+	if err := l.ExecuteStep(fmt.Sprintf("Restoring %d images from base wrap %q...", len(delta.Images), baseWrap), func() error {
+		return extractDeltaImages(ctx, delta, baseWrap, filepath.Join(chartPath, "images"))
+	}); err != nil {
+		return l.Failf("Failed to restore delta wrap images: %w", err)
+	}
+	l.Infof("Images restored from base wrap %q", baseWrap)
+	return nil
+}
+
+// extractDeltaImages copies the images omitted from the delta from baseWrap into imagesDir
+func extractDeltaImages(ctx context.Context, delta *metadata.Delta, baseWrap string, imagesDir string) error {
+	if err := validateDeltaBase(baseWrap); err != nil {
+		return err
+	}
+	sum, err := utils.FileSHA256(baseWrap)
+	if err != nil {
+		return fmt.Errorf("failed to digest base wrap: %w", err)
+	}
+	if dgst := digest.NewDigestFromEncoded(digest.SHA256, sum); dgst != delta.Base.Digest {
+		return fmt.Errorf("base wrap digest %s does not match the expected %s", dgst, delta.Base.Digest)
+	}
+	missing := make(map[digest.Digest]bool, len(delta.Images))
+	for _, dgst := range delta.Images {
+		missing[dgst] = true
+	}
+	if err := os.MkdirAll(imagesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create images directory: %w", err)
+	}
+	if err := walkWrapImages(ctx, baseWrap, func(dgst digest.Digest, r io.Reader) error {
+		if !missing[dgst] {
+			return nil
+		}
+		delete(missing, dgst)
+		return writeImageFile(filepath.Join(imagesDir, dgst.Encoded()+".tar"), r)
+	}); err != nil {
+		return err
+	}
+	for dgst := range missing {
+		return fmt.Errorf("image %s is not included in the base wrap", dgst)
+	}
+	return nil
+}
+
+// writeImageFile writes the contents of r into file
+func writeImageFile(file string, r io.Reader) error {
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fh, r); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// withoutDeltaImages returns the lock without the images omitted from the delta wrap, if any
+func withoutDeltaImages(lock *imagelock.ImagesLock, delta *metadata.Delta) *imagelock.ImagesLock {
+	if delta == nil {
+		return lock
+	}
+	omitted := make(map[digest.Digest]bool, len(delta.Images))
+	for _, dgst := range delta.Images {
+		omitted[dgst] = true
+	}
+	filtered := *lock
+	filtered.Images = make(imagelock.ImageList, 0, len(lock.Images))
+	for _, img := range lock.Images {
+		included := *img
+		included.Digests = make([]imagelock.DigestInfo, 0, len(img.Digests))
+		for _, d := range img.Digests {
+			if !omitted[d.Digest] {
+				included.Digests = append(included.Digests, d)
+			}
+		}
+		if len(included.Digests) > 0 {
+			filtered.Images = append(filtered.Images, &included)
+		}
+	}
+	return &filtered
+}
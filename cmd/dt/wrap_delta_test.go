@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/opencontainers/go-digest"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestWrapDelta() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+	serverURL := u.Host
+
+	scenarioName := "complete-chart"
+	scenarioDir, err := filepath.Abs(fmt.Sprintf("../../testdata/scenarios/%s", scenarioName))
+	require.NoError(err)
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+
+	// The sample images are pushed from the chart, so the wraps pull them back
+	images, err := writeSampleImages("test", "mytag", filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+	))
+	data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0"},
+	)
+	require.NoError(err)
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0644))
+	dt("images", "push", chartDir).AssertSuccess(t)
+	require.NoError(os.RemoveAll(filepath.Join(chartDir, "images")))
+
+	lockedDigests := make([]digest.Digest, 0)
+	for _, img := range images {
+		for _, d := range img.Digests {
+			lockedDigests = append(lockedDigests, d.Digest)
+		}
+	}
+
+	outDir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(err)
+	baseWrap := filepath.Join(outDir, "base.wrap.tgz")
+	deltaWrap := filepath.Join(outDir, "delta.wrap.tgz")
+	dt("wrap", chartDir, "--output-file", baseWrap).AssertSuccess(t)
+
+	t.Run("Omits the images included in the base wrap", func(t *testing.T) {
+		dt("wrap", chartDir, "--output-file", deltaWrap, "--delta-from", baseWrap).
+			AssertSuccessMatch(t, fmt.Sprintf(`%d images already included in .* omitted from the wrap`, len(lockedDigests)))
+
+		// The delta is built from a copy of the chart, which keeps its images
+		for _, dgst := range lockedDigests {
+			assert.FileExists(filepath.Join(chartDir, "images", dgst.Encoded()+".tar"))
+		}
+		assert.NoFileExists(filepath.Join(chartDir, metadata.DeltaFileName))
+		assert.NoError(utils.VerifyChecksums(chartDir, utils.ChecksumsFileName))
+
+		tmpDir := sb.TempFile()
+		require.NoError(utils.Untar(deltaWrap, tmpDir, utils.TarConfig{StripComponents: 1}))
+		imgFiles, err := filepath.Glob(filepath.Join(tmpDir, "images", "*.tar"))
+		require.NoError(err)
+		assert.Empty(imgFiles)
+		assert.NoError(utils.VerifyChecksums(tmpDir, utils.ChecksumsFileName))
+
+		delta, err := metadata.DeltaFromFile(filepath.Join(tmpDir, metadata.DeltaFileName))
+		require.NoError(err)
+		assert.Equal("base.wrap.tgz", delta.Base.File)
+		baseSum, err := utils.FileSHA256(baseWrap)
+		require.NoError(err)
+		assert.Equal(digest.NewDigestFromEncoded(digest.SHA256, baseSum), delta.Base.Digest)
+		assert.ElementsMatch(lockedDigests, delta.Images)
+
+		dt("wrap", "verify", deltaWrap).AssertSuccessMatch(t, `The wrap is a delta of .*base.wrap.tgz`)
+	})
+	t.Run("Unwraps reading the omitted images from the base wrap", func(t *testing.T) {
+		targetRegistry := fmt.Sprintf("%s/delta", serverURL)
+		dt("unwrap", "--yes", deltaWrap, targetRegistry).AssertErrorMatch(t, `provide it with --delta-base`)
+
+		dt("unwrap", "--yes", deltaWrap, targetRegistry, "--delta-base", baseWrap).
+			AssertSuccessMatch(t, `Images restored from base wrap`)
+		for _, img := range images {
+			ref := fmt.Sprintf("%s/%s", targetRegistry, filepath.Base(img.Image))
+			_, err := crane.Digest(ref)
+			assert.NoError(err, "image %s not pushed", ref)
+		}
+	})
+	t.Run("Rejects a different base wrap", func(t *testing.T) {
+		dt("unwrap", "--yes", deltaWrap, fmt.Sprintf("%s/other", serverURL), "--delta-base", deltaWrap).
+			AssertErrorMatch(t, `base wrap digest .* does not match`)
+		dt("wrap", chartDir, "--delta-from", filepath.Join(outDir, "missing.wrap.tgz")).
+			AssertErrorMatch(t, `base wrap .*missing.wrap.tgz" does not exist`)
+	})
+}
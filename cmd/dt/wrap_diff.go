@@ -0,0 +1,301 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var wrapDiffCmd = newWrapDiffCommand()
+
+// Kinds of changes reported by the wrap diff command
+const (
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeModified = "modified"
+)
+
+// fileChange describes a chart file that differs between two wraps
+type fileChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// imageChange describes an image that differs between two wraps
+type imageChange struct {
+	Chart    string `json:"chart"`
+	Name     string `json:"name"`
+	Change   string `json:"change"`
+	OldImage string `json:"oldImage,omitempty"`
+	NewImage string `json:"newImage,omitempty"`
+	// OldSize and NewSize are the sizes of the image tarballs included in each wrap
+	OldSize   int64 `json:"oldSize"`
+	NewSize   int64 `json:"newSize"`
+	SizeDelta int64 `json:"sizeDelta"`
+}
+
+// wrapDiff is the machine-readable representation of the differences between two wraps
+type wrapDiff struct {
+	Files  []fileChange  `json:"files"`
+	Images []imageChange `json:"images"`
+	// SizeDelta is the difference in the size of all the image tarballs
+	SizeDelta int64 `json:"sizeDelta"`
+}
+
+// isDiffIgnoredFile returns true for the wrap files, relative to the chart root, that are not compared as
+// chart files: the images, compared through the Images.lock, and the files describing the wrap itself
+func isDiffIgnoredFile(name string) bool {
+	return strings.HasPrefix(name, "images/") || name == utils.ChecksumsFileName || name == metadata.FileName || name == metadata.DeltaFileName
+}
+
+// readChartFilesFromTar returns the SHA256 checksums of the chart files in the wrap tarball, by their path
+func readChartFilesFromTar(wrapFile string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := utils.WalkTarFile(context.Background(), wrapFile, func(tr *tar.Reader, header *tar.Header) error {
+		rel := strings.SplitN(strings.TrimPrefix(path.Clean(header.Name), "/"), "/", 2)
+		if header.Typeflag != tar.TypeReg || len(rel) < 2 || isDiffIgnoredFile(rel[1]) {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return err
+		}
+		files[rel[1]] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return files, err
+}
+
+// readChartFilesFromDir returns the SHA256 checksums of the files of the chart at chartPath, by their path
+func readChartFilesFromDir(chartPath string) (map[string]string, error) {
+	chartRoot, err := chartutils.GetChartRoot(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	err = filepath.WalkDir(chartRoot, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(chartRoot, file)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); isDiffIgnoredFile(rel) {
+			return nil
+		}
+		sum, err := utils.FileSHA256(file)
+		if err != nil {
+			return err
+		}
+		files[rel] = sum
+		return nil
+	})
+	return files, err
+}
+
+// readChartFiles returns the SHA256 checksums of the chart files in a wrap or chart directory
+func readChartFiles(chartPath string) (map[string]string, error) {
+	if isTar, _ := utils.IsTarFile(chartPath); isTar {
+		return readChartFilesFromTar(chartPath)
+	}
+	return readChartFilesFromDir(chartPath)
+}
+
+// diffChartFiles returns the changes between the oldFiles and newFiles checksums, sorted by path
+func diffChartFiles(oldFiles map[string]string, newFiles map[string]string) []fileChange {
+	changes := make([]fileChange, 0)
+	for f, sum := range newFiles {
+		oldSum, found := oldFiles[f]
+		switch {
+		case !found:
+			changes = append(changes, fileChange{Path: f, Change: changeAdded})
+		case oldSum != sum:
+			changes = append(changes, fileChange{Path: f, Change: changeModified})
+		}
+	}
+	for f := range oldFiles {
+		if _, found := newFiles[f]; !found {
+			changes = append(changes, fileChange{Path: f, Change: changeRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// sameImageDigests returns true if both images reference the same digests for the same platforms
+func sameImageDigests(a *imagelock.ChartImage, b *imagelock.ChartImage) bool {
+	if len(a.Digests) != len(b.Digests) {
+		return false
+	}
+	digests := make(map[string]string, len(a.Digests))
+	for _, d := range a.Digests {
+		digests[d.Arch] = d.Digest.String()
+	}
+	for _, d := range b.Digests {
+		if digests[d.Arch] != d.Digest.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// diffImages returns the changes between the images of the oldInfo and newInfo wraps, sorted by chart and name
+func diffImages(oldInfo *wrapInfo, newInfo *wrapInfo) []imageChange {
+	key := func(img *imagelock.ChartImage) string { return img.Chart + "/" + img.Name }
+	oldImages := make(map[string]*imagelock.ChartImage, len(oldInfo.Lock.Images))
+	for _, img := range oldInfo.Lock.Images {
+		oldImages[key(img)] = img
+	}
+	changes := make([]imageChange, 0)
+	for _, img := range newInfo.Lock.Images {
+		c := imageChange{Chart: img.Chart, Name: img.Name, NewImage: img.Image, NewSize: newInfo.imageSize(img)}
+		oldImg, found := oldImages[key(img)]
+		delete(oldImages, key(img))
+		if found {
+			if oldImg.Image == img.Image && sameImageDigests(oldImg, img) {
+				continue
+			}
+			c.Change, c.OldImage, c.OldSize = changeModified, oldImg.Image, oldInfo.imageSize(oldImg)
+		} else {
+			c.Change = changeAdded
+		}
+		c.SizeDelta = c.NewSize - c.OldSize
+		changes = append(changes, c)
+	}
+	for _, img := range oldImages {
+		size := oldInfo.imageSize(img)
+		changes = append(changes, imageChange{Chart: img.Chart, Name: img.Name, Change: changeRemoved, OldImage: img.Image, OldSize: size, SizeDelta: -size})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Chart+"/"+changes[i].Name < changes[j].Chart+"/"+changes[j].Name
+	})
+	return changes
+}
+
+// diffWraps returns the differences between the oldPath and newPath wraps or chart directories
+func diffWraps(oldPath string, newPath string) (*wrapDiff, error) {
+	infos := make([]*wrapInfo, 0, 2)
+	files := make([]map[string]string, 0, 2)
+	for _, p := range []string{oldPath, newPath} {
+		if !utils.FileExists(p) {
+			return nil, fmt.Errorf("wrap %q does not exist", p)
+		}
+		info, err := readWrapInfo(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %v", p, err)
+		}
+		chartFiles, err := readChartFiles(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q files: %v", p, err)
+		}
+		infos, files = append(infos, info), append(files, chartFiles)
+	}
+	diff := &wrapDiff{
+		Files:     diffChartFiles(files[0], files[1]),
+		Images:    diffImages(infos[0], infos[1]),
+		SizeDelta: infos[1].totalImagesSize() - infos[0].totalImagesSize(),
+	}
+	return diff, nil
+}
+
+// signedSize returns the human-readable size delta, with its sign
+func signedSize(delta int64) string {
+	if delta < 0 {
+		return "-" + units.HumanSize(float64(-delta))
+	}
+	return "+" + units.HumanSize(float64(delta))
+}
+
+// sizeNote returns the human-readable size delta of the image, if its tarballs are included in any wrap
+func (c imageChange) sizeNote() string {
+	if c.OldSize == 0 && c.NewSize == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", signedSize(c.SizeDelta))
+}
+
+// showWrapDiff prints the human-readable differences between two wraps
+func showWrapDiff(diff *wrapDiff, l log.SectionLogger) {
+	if len(diff.Files) == 0 && len(diff.Images) == 0 {
+		l.Infof("No differences found")
+		return
+	}
+	symbols := map[string]string{changeAdded: "+", changeRemoved: "-", changeModified: "~"}
+	_ = l.Section(fmt.Sprintf("Chart files (%d changed)", len(diff.Files)), func(l log.SectionLogger) error {
+		for _, f := range diff.Files {
+			l.Printf("%s %s", symbols[f.Change], f.Path)
+		}
+		return nil
+	})
+	_ = l.Section(fmt.Sprintf("Images (%d changed)", len(diff.Images)), func(l log.SectionLogger) error {
+		for _, img := range diff.Images {
+			switch img.Change {
+			case changeAdded:
+				l.Printf("+ %s/%s: %s%s", img.Chart, img.Name, img.NewImage, img.sizeNote())
+			case changeRemoved:
+				l.Printf("- %s/%s: %s%s", img.Chart, img.Name, img.OldImage, img.sizeNote())
+			default:
+				l.Printf("~ %s/%s: %s -> %s%s", img.Chart, img.Name, img.OldImage, img.NewImage, img.sizeNote())
+			}
+		}
+		return nil
+	})
+	l.Printf("Total images size delta: %s", signedSize(diff.SizeDelta))
+}
+
+func newWrapDiffCommand() *cobra.Command {
+	var outputFormat = textOutput
+
+	cmd := &cobra.Command{
+		Use:   "diff WRAP|CHART_PATH WRAP|CHART_PATH",
+		Short: "Compares two wrapped Helm charts",
+		Long: `Compares two wrapped Helm charts, or a wrap and a Helm chart directory with its Images.lock, without extracting them.
+The chart files added, removed or modified and the images added, removed or changed, with the difference in their sizes, are reported`,
+		Example: `  # Compare two releases of a wrapped Helm chart
+  $ dt wrap diff mariadb-12.2.7.wrap.tgz mariadb-12.2.8.wrap.tgz
+
+  # Compare a wrap with the Helm chart it will be updated from, in JSON format
+  $ dt wrap diff mariadb-12.2.7.wrap.tgz examples/mariadb --output json`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			diff, err := diffWraps(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if isStructuredOutput(outputFormat) {
+				return writeStructuredOutput(os.Stdout, outputFormat, diff)
+			}
+			showWrapDiff(diff, getLogger())
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &outputFormat)
+	return cmd
+}
+
+func init() {
+	wrapCmd.AddCommand(wrapDiffCmd)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestWrapDiffCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	serverURL := "localhost"
+
+	// createChart creates a sample chart, with its images, including one image per tag
+	createChart := func(tags map[string]string, extraFiles map[string]string) string {
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+		images := make([]tu.ImageData, 0)
+		for name, tag := range tags {
+			imgs, err := writeSampleImages(name, tag, filepath.Join(chartDir, "images"))
+			require.NoError(err)
+			images = append(images, imgs...)
+		}
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+		))
+		for f, content := range extraFiles {
+			require.NoError(os.WriteFile(filepath.Join(chartDir, f), []byte(content), 0644))
+		}
+		return chartDir
+	}
+	createWrap := func(chartDir string) string {
+		wrapFile := filepath.Join(sb.TempFile(), "test-1.0.0.wrap.tgz")
+		require.NoError(os.MkdirAll(filepath.Dir(wrapFile), 0755))
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+		return wrapFile
+	}
+
+	oldChart := createChart(map[string]string{"app": "1.0.0", "legacy": "1.0.0"}, map[string]string{"README.md": "old", "NOTES.txt": "notes"})
+	newChart := createChart(map[string]string{"app": "2.0.0", "sidecar": "1.0.0"}, map[string]string{"README.md": "new", "LICENSE": "license"})
+	oldWrap, newWrap := createWrap(oldChart), createWrap(newChart)
+
+	t.Run("Reports no differences between identical wraps", func(t *testing.T) {
+		dt("wrap", "diff", oldWrap, oldWrap).AssertSuccessMatch(t, "No differences found")
+	})
+	t.Run("Reports the chart files and images changes", func(t *testing.T) {
+		res := dt("wrap", "diff", oldWrap, newWrap)
+		res.AssertSuccess(t)
+		for _, expected := range []string{
+			`\+ LICENSE`, `- NOTES.txt`, `~ README.md`,
+			`~ test/app: localhost/app:1.0.0 -> localhost/app:2.0.0 \([+-]`,
+			`- test/legacy: localhost/legacy:1.0.0 \(-\d`,
+			`\+ test/sidecar: localhost/sidecar:1.0.0 \(\+\d`,
+			`Total images size delta: [+-]`,
+		} {
+			assert.Regexp(expected, res.stdout)
+		}
+		assert.NotContains(res.stdout, "Images.lock\n")
+	})
+	t.Run("Compares a wrap with a chart directory", func(t *testing.T) {
+		res := dt("wrap", "diff", oldWrap, newChart, "--output", "json")
+		res.AssertSuccess(t)
+		var diff wrapDiff
+		require.NoError(json.Unmarshal([]byte(res.stdout), &diff))
+		changes := make(map[string]string)
+		for _, f := range diff.Files {
+			changes[f.Path] = f.Change
+		}
+		assert.Equal(changeAdded, changes["LICENSE"])
+		assert.Equal(changeRemoved, changes["NOTES.txt"])
+		assert.Equal(changeModified, changes["README.md"])
+		assert.Equal(changeModified, changes["Images.lock"])
+		require.Len(diff.Images, 3)
+		assert.Equal("sidecar", diff.Images[2].Name)
+		assert.Equal(changeAdded, diff.Images[2].Change)
+		assert.Greater(diff.Images[2].SizeDelta, int64(0))
+	})
+	t.Run("Fails for missing wraps", func(t *testing.T) {
+		dt("wrap", "diff", oldWrap, filepath.Join(sb.TempFile(), "missing.wrap.tgz")).AssertErrorMatch(t, `missing.wrap.tgz" does not exist`)
+	})
+}
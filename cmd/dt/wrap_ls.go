@@ -0,0 +1,141 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var wrapLsCmd = newWrapLsCommand()
+
+// wrapEntry is the machine-readable representation of a file inside a wrap
+type wrapEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Image, Arch and Digest describe the platform specific image stored in the file, for image tarballs
+	Image  string `json:"image,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// listWrapEntries returns the files in the wrap tarball, relative to the chart root, streaming it. The image
+// tarballs are described using the wrap Images.lock
+func listWrapEntries(ctx context.Context, wrapFile string) ([]wrapEntry, error) {
+	entries := make([]wrapEntry, 0)
+	var lock *imagelock.ImagesLock
+	if err := utils.WalkTarFile(ctx, wrapFile, func(tr *tar.Reader, header *tar.Header) error {
+		rel := strings.SplitN(strings.TrimPrefix(path.Clean(header.Name), "/"), "/", 2)
+		if header.Typeflag != tar.TypeReg || len(rel) < 2 {
+			return nil
+		}
+		entries = append(entries, wrapEntry{Path: rel[1], Size: header.Size})
+		if rel[1] == imagelock.DefaultImagesLockFileName {
+			var err error
+			lock, err = imagelock.FromYAML(tr)
+			return err
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	images := make(map[string]wrapEntry)
+	if lock != nil {
+		for _, img := range lock.Images {
+			for _, d := range img.Digests {
+				images[d.Digest.Encoded()] = wrapEntry{Image: img.Image, Arch: d.Arch, Digest: d.Digest.String()}
+			}
+		}
+	}
+	for i, e := range entries {
+		if path.Dir(e.Path) != "images" || path.Ext(e.Path) != ".tar" {
+			continue
+		}
+		if img, ok := images[strings.TrimSuffix(path.Base(e.Path), ".tar")]; ok {
+			entries[i].Image, entries[i].Arch, entries[i].Digest = img.Image, img.Arch, img.Digest
+		}
+	}
+	return entries, nil
+}
+
+// showWrapEntries prints the human-readable list of files in the wrap
+func showWrapEntries(entries []wrapEntry, l log.SectionLogger) {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+		if e.Image != "" {
+			l.Printf("%8s  %s (%s, %s)", units.HumanSize(float64(e.Size)), e.Path, e.Image, e.Arch)
+			continue
+		}
+		l.Printf("%8s  %s", units.HumanSize(float64(e.Size)), e.Path)
+	}
+	l.Printf("%d files, %s", len(entries), units.HumanSize(float64(total)))
+}
+
+func newWrapLsCommand() *cobra.Command {
+	var outputFormat = textOutput
+	var imagesOnly bool
+
+	cmd := &cobra.Command{
+		Use:     "ls WRAP",
+		Aliases: []string{"list"},
+		Short:   "Lists the files inside a wrapped Helm chart",
+		Long: `Lists the files inside a wrapped Helm chart, streaming the tarball without extracting it.
+The image tarballs are described with the image, platform and digest they store, as locked in the Images.lock`,
+		Example: `  # List the files of a wrapped Helm chart
+  $ dt wrap ls mariadb-12.2.8.wrap.tgz
+
+  # List the images of a wrapped Helm chart in JSON format
+  $ dt wrap ls mariadb-12.2.8.wrap.tgz --images --output json`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wrapFile := args[0]
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			if !utils.FileExists(wrapFile) {
+				return fmt.Errorf("wrap file %q does not exist", wrapFile)
+			}
+			if isTar, _ := utils.IsTarFile(wrapFile); !isTar {
+				return fmt.Errorf("%q is not a wrap file", wrapFile)
+			}
+			ctx, cancel := contextWithSigterm(context.Background())
+			defer cancel()
+			entries, err := listWrapEntries(ctx, wrapFile)
+			if err != nil {
+				return fmt.Errorf("failed to list wrap %q: %v", wrapFile, err)
+			}
+			if imagesOnly {
+				images := make([]wrapEntry, 0)
+				for _, e := range entries {
+					if e.Image != "" {
+						images = append(images, e)
+					}
+				}
+				entries = images
+			}
+			if isStructuredOutput(outputFormat) {
+				return writeStructuredOutput(os.Stdout, outputFormat, entries)
+			}
+			showWrapEntries(entries, getLogger())
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &outputFormat)
+	cmd.Flags().BoolVar(&imagesOnly, "images", imagesOnly, "only list the image tarballs")
+	return cmd
+}
+
+func init() {
+	wrapCmd.AddCommand(wrapLsCmd)
+}
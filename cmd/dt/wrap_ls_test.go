@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestWrapLsCommand() {
+	t := suite.T()
+	require := suite.Require()
+	assert := suite.Assert()
+	sb := suite.sb
+
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	serverURL := "localhost"
+
+	dest := sb.TempFile()
+	chartDir := filepath.Join(dest, scenarioName)
+	images, err := writeSampleImages("test", "mytag", filepath.Join(chartDir, "images"))
+	require.NoError(err)
+	require.NoError(tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+	))
+	data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+		map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0"},
+	)
+	require.NoError(err)
+	require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0644))
+	wrapFile := filepath.Join(dest, "test-1.0.0.wrap.tgz")
+	require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+
+	t.Run("Lists the wrap files", func(t *testing.T) {
+		res := dt("wrap", "ls", wrapFile)
+		res.AssertSuccess(t)
+		assert.Regexp(` Chart.yaml`, res.stdout)
+		assert.Regexp(` Images.lock`, res.stdout)
+		for _, d := range images[0].Digests {
+			assert.Regexp(fmt.Sprintf(` images/%s.tar \(localhost/test:mytag, %s\)`, d.Digest.Encoded(), d.Arch), res.stdout)
+		}
+		assert.Regexp(`\d+ files, `, res.stdout)
+	})
+	t.Run("Lists the wrap images in JSON format", func(t *testing.T) {
+		res := dt("wrap", "ls", wrapFile, "--images", "--output", "json")
+		res.AssertSuccess(t)
+		var entries []wrapEntry
+		require.NoError(json.Unmarshal([]byte(res.stdout), &entries))
+		require.Len(entries, len(images[0].Digests))
+		for _, e := range entries {
+			assert.Equal("localhost/test:mytag", e.Image)
+			assert.Equal(fmt.Sprintf("images/%s.tar", e.Digest[len("sha256:"):]), e.Path)
+			assert.Greater(e.Size, int64(0))
+		}
+	})
+	t.Run("Fails for files other than wraps", func(t *testing.T) {
+		dt("wrap", "ls", filepath.Join(chartDir, "Chart.yaml")).AssertErrorMatch(t, `is not a wrap file`)
+		dt("wrap", "ls", filepath.Join(dest, "missing.wrap.tgz")).AssertErrorMatch(t, `does not exist`)
+	})
+}
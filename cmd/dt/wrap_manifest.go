@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/tracing"
+	"github.com/vmware-labs/distribution-tooling-for-helm/objectstore"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// wrapManifest lists the Helm charts to wrap in a single execution
+type wrapManifest struct {
+	Charts []wrapManifestEntry `yaml:"charts"`
+}
+
+// wrapManifestEntry describes one of the Helm charts listed in a wrap manifest
+type wrapManifestEntry struct {
+	// Ref is the chart to wrap: a local path, an OCI URI, a Helm repository URL or REPO/NAME
+	Ref string `yaml:"ref"`
+	// Chart is the name of the chart to fetch when Ref is a Helm repository URL
+	Chart string `yaml:"chart,omitempty"`
+	// Version is the version or version range to request for remote charts
+	Version string `yaml:"version,omitempty"`
+	// Platforms, if not empty, overrides the platforms to include in the Images.lock
+	Platforms []string `yaml:"platforms,omitempty"`
+	// OutputFile, if not empty, is the location of the resulting wrap
+	OutputFile string `yaml:"outputFile,omitempty"`
+}
+
+// readWrapManifest reads the wrap manifest in file, resolving its relative paths from the manifest directory
+func readWrapManifest(file string) (*wrapManifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wrap manifest: %w", err)
+	}
+	m := &wrapManifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse wrap manifest %q: %w", file, err)
+	}
+	if len(m.Charts) == 0 {
+		return nil, fmt.Errorf("wrap manifest %q does not list any Helm chart", file)
+	}
+	baseDir := filepath.Dir(file)
+	for i := range m.Charts {
+		entry := &m.Charts[i]
+		if entry.Ref == "" {
+			return nil, fmt.Errorf("entry %d of wrap manifest %q does not define the chart ref", i+1, file)
+		}
+		if localRef := filepath.Join(baseDir, entry.Ref); !filepath.IsAbs(entry.Ref) && utils.FileExists(localRef) {
+			entry.Ref = localRef
+		}
+		if entry.OutputFile != "" && !filepath.IsAbs(entry.OutputFile) && !objectstore.IsURL(entry.OutputFile) {
+			entry.OutputFile = filepath.Join(baseDir, entry.OutputFile)
+		}
+	}
+	return m, nil
+}
+
+// wrapManifestCharts wraps every chart listed in the manifest file, sharing the pulled images between them,
+// and returns the resulting wrap files
+func wrapManifestCharts(ctx context.Context, file string, platforms []string, flags *pflag.FlagSet, opts ...wrapOption) ([]string, error) {
+	m, err := readWrapManifest(file)
+	if err != nil {
+		return nil, err
+	}
+	tempDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, withImagesCache(filepath.Join(tempDir, "images-cache")))
+
+	// The command line values apply to the entries not defining their own
+	defaultVersion, _ := flags.GetString("version")
+	defaultChart, _ := flags.GetString("chart")
+
+	wrapFiles := make([]string, 0, len(m.Charts))
+	for _, entry := range m.Charts {
+		if err := setManifestFlags(flags, map[string]string{
+			"version": valueOrDefault(entry.Version, defaultVersion),
+			"chart":   valueOrDefault(entry.Chart, defaultChart),
+		}); err != nil {
+			return wrapFiles, err
+		}
+		entryPlatforms := platforms
+		if len(entry.Platforms) > 0 {
+			entryPlatforms = entry.Platforms
+		}
+		wrapFile, err := wrapChart(ctx, entry.Ref, entry.OutputFile, entryPlatforms, flags, opts...)
+		if err != nil {
+			return wrapFiles, err
+		}
+		wrapFiles = append(wrapFiles, wrapFile)
+	}
+	return wrapFiles, nil
+}
+
+// setManifestFlags sets the flags read by wrapChart to the values of a manifest entry
+func setManifestFlags(flags *pflag.FlagSet, values map[string]string) error {
+	for name, value := range values {
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("failed to set --%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// validateWrapInput checks the command receives either a Helm chart or a wrap manifest, and the flags
+// can be combined
+func validateWrapInput(args []string, manifestFile string, outputFile string, f *wrapFlags) error {
+	if (len(args) == 0) == (manifestFile == "") {
+		return fmt.Errorf("either a Helm chart or a wrap manifest (--file) must be provided")
+	}
+	if manifestFile != "" && outputFile != "" {
+		return fmt.Errorf("--output-file cannot be used with --file: set the outputFile of the manifest entries instead")
+	}
+	if err := validateDeltaBase(f.deltaFrom); err != nil {
+		return err
+	}
+	return validatePushWrap(f.pushURL, len(f.encryptRecipients) > 0)
+}
+
+// runWrap wraps the chart in args, or the charts listed in manifestFile, and returns the resulting wrap files
+func runWrap(ctx context.Context, args []string, manifestFile string, outputFile string, platforms []string, summary *runSummary, flags *pflag.FlagSet, opts ...wrapOption) ([]string, error) {
+	if manifestFile != "" {
+		ctx, span := tracing.Start(ctx, "wrap", attribute.String("manifest", manifestFile))
+		wrapFiles, err := wrapManifestCharts(ctx, manifestFile, platforms, flags, opts...)
+		tracing.End(span, err)
+		// The subcharts wrapped separately follow the charts in the manifest
+		summary.Output, summary.Outputs = "", append(wrapFiles, summary.Outputs...)
+		return summary.Outputs, err
+	}
+	ctx, span := tracing.Start(ctx, "wrap", attribute.String("chart", args[0]))
+	wrapFile, err := wrapChart(ctx, args[0], outputFile, platforms, flags, opts...)
+	tracing.End(span, err)
+	return append([]string{wrapFile}, summary.Outputs...), err
+}
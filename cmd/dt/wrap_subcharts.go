@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// wrapSubcharts wraps each first-level subchart of chart next to its wrap, if requested, recording them
+// in the run summary
+func wrapSubcharts(ctx context.Context, chart *chartutils.Chart, outputFile string, platforms []string, cfg *wrapConfig) error {
+	if !cfg.SplitSubcharts {
+		return nil
+	}
+	tmpDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return err
+	}
+	subCfg := *cfg
+	subCfg.SplitSubcharts, subCfg.SBOMFile = false, ""
+	subCfg.ImagesCacheDir = cfg.imagesCacheDir()
+	// The subcharts are wrapped from local directories, so the remote chart flags do not apply
+	noFlags := pflag.NewFlagSet("subchart", pflag.ContinueOnError)
+
+	for _, dep := range chart.Chart.Dependencies() {
+		subchartDir, err := os.MkdirTemp(tmpDir, "subchart-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		if err := chartutil.SaveDir(dep, subchartDir); err != nil {
+			return fmt.Errorf("failed to extract subchart %q: %w", dep.Name(), err)
+		}
+		subOutputFile := filepath.Join(filepath.Dir(outputFile), fmt.Sprintf("%s-%s.wrap.tgz", dep.Name(), dep.Metadata.Version))
+		wrapFile, err := wrapChartWithConfig(ctx, filepath.Join(subchartDir, dep.Name()), subOutputFile, platforms, noFlags, &subCfg)
+		if err != nil {
+			return err
+		}
+		cfg.Summary.Outputs = append(cfg.Summary.Outputs, wrapFile)
+	}
+	return nil
+}
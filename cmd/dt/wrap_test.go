@@ -1,19 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/vmware-labs/distribution-tooling-for-helm/artifact"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/provenance"
+	"github.com/vmware-labs/distribution-tooling-for-helm/signature"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 	"gopkg.in/yaml.v3"
+	helmprov "helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 func (suite *CmdSuite) TestWrapCommand() {
@@ -59,7 +74,7 @@ func (suite *CmdSuite) TestWrapCommand() {
 		}
 		return chartDir
 	}
-	testWrap := func(t *testing.T, inputChart string, outputFile string, expectedLock map[string]interface{}) {
+	testWrap := func(t *testing.T, inputChart string, outputFile string, expectedLock map[string]interface{}, extraArgs ...string) string {
 		// Setup a working directory to look for the wrap when not providing a output-filename
 		currentDir, err := os.Getwd()
 		require.NoError(err)
@@ -71,7 +86,7 @@ func (suite *CmdSuite) TestWrapCommand() {
 		require.NoError(os.Chdir(workingDir))
 
 		var expectedWrapFile string
-		args := []string{"wrap", inputChart}
+		args := append([]string{"wrap", inputChart}, extraArgs...)
 		if outputFile != "" {
 			expectedWrapFile = outputFile
 			args = append(args, "--output-file", expectedWrapFile)
@@ -96,6 +111,14 @@ func (suite *CmdSuite) TestWrapCommand() {
 		}
 		lockFile := filepath.Join(tmpDir, "Images.lock")
 		assert.FileExists(lockFile)
+		assert.NoError(utils.VerifyChecksums(tmpDir, utils.ChecksumsFileName))
+
+		wrapMetadata, err := metadata.FromFile(filepath.Join(tmpDir, metadata.FileName))
+		require.NoError(err)
+		assert.Equal(inputChart, wrapMetadata.Chart.Reference)
+		assert.Equal(chartName, wrapMetadata.Chart.Name)
+		assert.Equal(Version, wrapMetadata.ToolVersion)
+		assert.Len(wrapMetadata.Images, len(images))
 
 		newData, err := os.ReadFile(lockFile)
 		require.NoError(err)
@@ -106,8 +129,9 @@ func (suite *CmdSuite) TestWrapCommand() {
 
 		assert.Equal(expectedLock, newLock)
 
+		return tmpDir
 	}
-	testSampleWrap := func(t *testing.T, withLock bool, outputFile string) {
+	testSampleWrap := func(t *testing.T, withLock bool, outputFile string, extraArgs ...string) string {
 		dest := sb.TempFile()
 		chartDir := createSampleChart(dest, withLock)
 
@@ -121,7 +145,7 @@ func (suite *CmdSuite) TestWrapCommand() {
 		// Clear the timestamp
 		expectedLock["metadata"] = nil
 
-		testWrap(t, chartDir, outputFile, expectedLock)
+		return testWrap(t, chartDir, outputFile, expectedLock, extraArgs...)
 	}
 
 	t.Run("Wrap Chart without exiting lock", func(t *testing.T) {
@@ -183,9 +207,214 @@ func (suite *CmdSuite) TestWrapCommand() {
 		pushChartURL := fmt.Sprintf("oci://%s/charts", ociServerURL)
 		fullChartURL := fmt.Sprintf("%s/%s", pushChartURL, chartName)
 
-		require.NoError(utils.PushChart(tarFilename, pushChartURL))
+		require.NoError(utils.PushChart(tarFilename, pushChartURL, utils.PushConfig{}))
+
+		for requested, args := range map[string][]string{
+			"latest":     nil,
+			">=1.0.0 <2": {"--version", ">=1.0.0 <2"},
+		} {
+			tmpDir := testWrap(t, fullChartURL, "", expectedLock, args...)
+			wrapMetadata, err := metadata.FromFile(filepath.Join(tmpDir, metadata.FileName))
+			require.NoError(err)
+			assert.Equal(requested, wrapMetadata.Chart.RequestedVersion)
+			assert.Equal(version, wrapMetadata.Chart.Version)
+		}
+	})
+
+	t.Run("Wrap Chart From a classic Helm repository", func(t *testing.T) {
+		dest := sb.TempFile()
+		chartDir := createSampleChart(dest, withLock)
+
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		var expectedLock map[string]interface{}
+		require.NoError(yaml.Unmarshal([]byte(data), &expectedLock))
+
+		// Clear the timestamp
+		expectedLock["metadata"] = nil
+
+		repoDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		require.NoError(utils.Tar(chartDir, filepath.Join(repoDir, fmt.Sprintf("%s-%s.tgz", chartName, version)), utils.TarConfig{Prefix: chartName}))
+
+		fileServer := http.FileServer(http.Dir(repoDir))
+		repoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fileServer.ServeHTTP(w, r)
+		}))
+		defer repoServer.Close()
+		index, err := repo.IndexDirectory(repoDir, repoServer.URL)
+		require.NoError(err)
+		require.NoError(index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644))
+
+		repoHost := strings.TrimPrefix(repoServer.URL, "http://")
+		testWrap(t, repoServer.URL, "", expectedLock, "--chart", chartName, "--version", version, "--creds", repoHost+"=admin:secret")
+
+		dt("wrap", chartDir, "--chart", chartName).AssertErrorMatch(t, "--chart can only be used with http")
+
+		// HTTPS repositories honor the TLS settings
+		tlsRepoDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		require.NoError(utils.Tar(chartDir, filepath.Join(tlsRepoDir, fmt.Sprintf("%s-%s.tgz", chartName, version)), utils.TarConfig{Prefix: chartName}))
+		tlsRepoServer := httptest.NewTLSServer(http.FileServer(http.Dir(tlsRepoDir)))
+		defer tlsRepoServer.Close()
+		tlsIndex, err := repo.IndexDirectory(tlsRepoDir, tlsRepoServer.URL)
+		require.NoError(err)
+		require.NoError(tlsIndex.WriteFile(filepath.Join(tlsRepoDir, "index.yaml"), 0644))
+		caFile := filepath.Join(dest, "ca.pem")
+		require.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsRepoServer.Certificate().Raw}), 0644))
+
+		dt("wrap", tlsRepoServer.URL, "--chart", chartName, "--version", version).AssertError(t)
+		testWrap(t, tlsRepoServer.URL, "", expectedLock, "--chart", chartName, "--version", version, "--ca-file", caFile)
+	})
+
+	t.Run("Wrap Chart building its dependencies", func(t *testing.T) {
+		s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+		defer s.Close()
+		u, err := url.Parse(s.URL)
+		require.NoError(err)
+
+		// The dependency is the sample chart, pushed into an OCI registry
+		tarFilename := fmt.Sprintf("%s/%s-%s.tgz", sb.TempFile(), chartName, version)
+		require.NoError(utils.Tar(createSampleChart(sb.TempFile(), withLock), tarFilename, utils.TarConfig{Prefix: chartName}))
+		chartsURL := fmt.Sprintf("oci://%s/charts", u.Host)
+		require.NoError(utils.PushChart(tarFilename, chartsURL, utils.PushConfig{}))
+
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest, map[string]interface{}{
+			"Name": "parent", "Version": version,
+			"Dependencies": []map[string]string{{"Name": chartName, "Repository": chartsURL, "Version": version}},
+		}))
+		parentDir := filepath.Join(dest, scenarioName)
+		// Without --dependency-build, the missing dependencies are only warned about, and Helm fails as it used to
+		res := dt("wrap", parentDir, "--output-file", filepath.Join(sb.TempFile(), "parent.wrap.tgz"))
+		res.AssertError(t)
+		assert.Contains(res.stdout, "are not vendored under charts/, so their images are not wrapped: use --dependency-build to download them")
+
+		require.NoError(os.RemoveAll(filepath.Join(parentDir, "Images.lock")))
+		// Helm writes Chart.lock, instead of requirements.lock, for v2 charts
+		chartData, err := os.ReadFile(filepath.Join(parentDir, "Chart.yaml"))
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(parentDir, "Chart.yaml"), append([]byte("apiVersion: v2\n"), chartData...), 0644))
+
+		wrapFile := filepath.Join(sb.TempFile(), "parent.wrap.tgz")
+		dt("wrap", parentDir, "--dependency-build", "--output-file", wrapFile).AssertSuccess(t)
+
+		tmpDir := sb.TempFile()
+		require.NoError(utils.Untar(wrapFile, tmpDir, utils.TarConfig{StripComponents: 1}))
+		assert.FileExists(filepath.Join(tmpDir, "charts", fmt.Sprintf("%s-%s.tgz", chartName, version)))
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				assert.FileExists(filepath.Join(tmpDir, "images", fmt.Sprintf("%s.tar", digestData.Digest.Encoded())))
+			}
+		}
+
+		// The Chart.lock written by the dependency build is verified in the next wraps
+		lockFile := filepath.Join(parentDir, "Chart.lock")
+		require.FileExists(lockFile)
+		dt("wrap", parentDir, "--output-file", filepath.Join(sb.TempFile(), "parent.wrap.tgz")).AssertSuccess(t)
+
+		lockData, err := os.ReadFile(lockFile)
+		require.NoError(err)
+		require.NoError(os.WriteFile(lockFile, []byte(strings.Replace(string(lockData), "version: "+version, "version: 0.9.0", 1)), 0644))
+		res = dt("wrap", parentDir, "--output-file", filepath.Join(sb.TempFile(), "parent.wrap.tgz"))
+		res.AssertError(t)
+		assert.Contains(res.stdout, "do not match Chart.lock")
+	})
+
+	t.Run("Wrap Chart splitting its subcharts", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(tu.RenderScenario(scenarioDir, dest, map[string]interface{}{
+			"Name": "parent", "Version": version,
+			"Dependencies": []map[string]string{{"Name": chartName, "Repository": "oci://example.com/charts", "Version": version}},
+		}))
+		parentDir := filepath.Join(dest, scenarioName)
+		require.NoError(os.RemoveAll(filepath.Join(parentDir, "Images.lock")))
+		// Vendor the sample chart as the subchart
+		require.NoError(os.MkdirAll(filepath.Join(parentDir, "charts"), 0755))
+		require.NoError(os.Rename(createSampleChart(sb.TempFile(), withoutLock), filepath.Join(parentDir, "charts", chartName)))
+		numDigests := 0
+		for _, imgData := range images {
+			numDigests += len(imgData.Digests)
+		}
+
+		outputDir := sb.TempFile()
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("wrap", parentDir, "--split-subcharts", "--output-file", filepath.Join(outputDir, "parent.wrap.tgz"), "--output", "json")
+		res.AssertSuccess(t)
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		assert.Equal(filepath.Join(outputDir, "parent.wrap.tgz"), summary.Output)
+		subchartWrap := filepath.Join(outputDir, fmt.Sprintf("%s-%s.wrap.tgz", chartName, version))
+		assert.Equal([]string{subchartWrap}, summary.Outputs)
+		// The subchart reuses the images pulled for the umbrella chart
+		assert.Equal(numDigests, summary.Transfers.ImagesPulled)
+		assert.Equal(numDigests, summary.Transfers.CacheHits)
+
+		tmpDir := sb.TempFile()
+		require.NoError(utils.Untar(subchartWrap, tmpDir, utils.TarConfig{StripComponents: 1}))
+		wrapMetadata, err := metadata.FromFile(filepath.Join(tmpDir, metadata.FileName))
+		require.NoError(err)
+		assert.Equal(chartName, wrapMetadata.Chart.Name)
+		assert.FileExists(filepath.Join(tmpDir, "Images.lock"))
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				assert.FileExists(filepath.Join(tmpDir, "images", fmt.Sprintf("%s.tar", digestData.Digest.Encoded())))
+			}
+		}
+	})
+
+	t.Run("Wrap Chart From oci verifying its provenance", func(t *testing.T) {
+		s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+		defer s.Close()
+		u, err := url.Parse(s.URL)
+		require.NoError(err)
+
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": chartName, "Version": version},
+		)
+		require.NoError(err)
+		var expectedLock map[string]interface{}
+		require.NoError(yaml.Unmarshal([]byte(data), &expectedLock))
+		expectedLock["metadata"] = nil
+
+		keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		secring, pubring, err := writeKeyrings(keysDir, "dt")
+		require.NoError(err)
+		otherKeysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		_, otherPubring, err := writeKeyrings(otherKeysDir, "other")
+		require.NoError(err)
+
+		tarFilename := fmt.Sprintf("%s/%s-%s.tgz", sb.TempFile(), chartName, version)
+		require.NoError(utils.Tar(chartDir, tarFilename, utils.TarConfig{}))
+		signer, err := helmprov.NewFromKeyring(secring, "dt")
+		require.NoError(err)
+		provData, err := signer.ClearSign(tarFilename)
+		require.NoError(err)
+		require.NoError(os.WriteFile(tarFilename+utils.ProvenanceExtension, []byte(provData), 0644))
+
+		pushChartURL := fmt.Sprintf("oci://%s/charts", u.Host)
+		require.NoError(utils.PushChart(tarFilename, pushChartURL, utils.PushConfig{}))
+		fullChartURL := fmt.Sprintf("%s/%s", pushChartURL, chartName)
+
+		wrapDir := testWrap(t, fullChartURL, "", expectedLock, "--verify-chart", "--chart-keyring", pubring)
+		assert.FileExists(filepath.Join(wrapDir, fmt.Sprintf("%s-%s.tgz.prov", chartName, version)))
+
+		dt("wrap", fullChartURL, "--output-file", sb.TempFile(), "--verify-chart", "--chart-keyring", otherPubring).
+			AssertErrorMatch(t, "failed to wrap Helm chart")
 
-		testWrap(t, fullChartURL, "", expectedLock)
+		// Local packaged charts are verified using the provenance file next to them
+		testWrap(t, tarFilename, "", expectedLock, "--verify-chart", "--chart-keyring", pubring)
+		dt("wrap", chartDir, "--output-file", sb.TempFile(), "--verify-chart", "--chart-keyring", pubring).
+			AssertErrorMatch(t, "failed to wrap Helm chart")
 	})
 
 	t.Run("Wrap Chart with custom output filename", func(t *testing.T) {
@@ -194,4 +423,329 @@ func (suite *CmdSuite) TestWrapCommand() {
 		// This should already be handled by testWrap, but make sure it is there
 		suite.Assert().FileExists(tempFilename)
 	})
+
+	t.Run("Wrap Chart uploading it to an object storage", func(t *testing.T) {
+		// Fake aws CLI storing the uploads under bucketsDir
+		binDir := sb.TempFile()
+		bucketsDir := sb.TempFile()
+		require.NoError(os.MkdirAll(binDir, 0755))
+		script := fmt.Sprintf("#!/bin/sh\nf=%s/${4#s3://}\nmkdir -p $(dirname $f) && cat > $f\n", bucketsDir)
+		require.NoError(os.WriteFile(filepath.Join(binDir, "aws"), []byte(script), 0755))
+		t.Setenv("PATH", fmt.Sprintf("%s:%s", binDir, os.Getenv("PATH")))
+
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		res := dt("wrap", chartDir, "--quiet", "--output-file", "s3://bucket/wraps/chart.wrap.tgz")
+		res.AssertSuccess(t)
+		assert.Equal("s3://bucket/wraps/chart.wrap.tgz\n", res.stdout)
+
+		uploadedFile := filepath.Join(bucketsDir, "bucket/wraps/chart.wrap.tgz")
+		assert.FileExists(uploadedFile)
+		tmpDir := sb.TempFile()
+		require.NoError(utils.Untar(uploadedFile, tmpDir, utils.TarConfig{StripComponents: 1}))
+		assert.FileExists(filepath.Join(tmpDir, "Images.lock"))
+
+		dt("wrap", chartDir, "--output-file", "gs://bucket/").AssertErrorMatch(t, "must include the bucket and the object name")
+	})
+
+	t.Run("Wrap Chart in quiet mode", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		outputFile := fmt.Sprintf("%s/chart.wrap.tgz", sb.TempFile())
+		res := dt("wrap", chartDir, "--quiet", "--output-file", outputFile)
+		res.AssertSuccess(t)
+		assert.Equal(outputFile+"\n", res.stdout)
+		assert.Empty(res.stderr)
+		assert.FileExists(outputFile)
+
+		res = dt("wrap", chartDir, "-q", "--sbom", "--sbom-format", "unknown")
+		res.AssertErrorMatch(t, "unsupported SBOM format")
+		assert.Empty(res.stdout)
+	})
+
+	t.Run("Wrap Chart printing the run summary", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		numDigests := 0
+		for _, imgData := range images {
+			numDigests += len(imgData.Digests)
+		}
+
+		outputFile := fmt.Sprintf("%s/chart.wrap.tgz", sb.TempFile())
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("wrap", chartDir, "--output-file", outputFile, "--output", "json")
+		res.AssertSuccess(t)
+
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		assert.Equal("wrap", summary.Command)
+		assert.Equal(outputFile, summary.Output)
+		stages := make([]string, 0)
+		for _, st := range summary.Stages {
+			stages = append(stages, st.Name)
+		}
+		assert.Equal([]string{"lock", "pull images", "process images", "compress"}, stages)
+		assert.Equal(numDigests, summary.Transfers.ImagesPulled)
+		assert.Greater(summary.Transfers.BytesDownloaded, int64(0))
+		assert.Equal(0, summary.Transfers.CacheHits)
+
+		// The images pulled by the previous execution are reused
+		res = dt("wrap", chartDir, "--output-file", outputFile)
+		res.AssertSuccess(t)
+		assert.Contains(res.stdout, "Summary")
+		assert.Contains(res.stdout, fmt.Sprintf("Images pulled: 0 (0B downloaded, %d reused from previous pulls)", numDigests))
+	})
+
+	t.Run("Wrap Charts listed in a manifest file", func(t *testing.T) {
+		manifestDir := sb.TempFile()
+		createSampleChart(filepath.Join(manifestDir, "first"), withLock)
+		createSampleChart(filepath.Join(manifestDir, "second"), withLock)
+		manifestFile := filepath.Join(manifestDir, "charts.yaml")
+		require.NoError(os.WriteFile(manifestFile, []byte(fmt.Sprintf(`charts:
+  - ref: first/%[1]s
+    outputFile: first.wrap.tgz
+  - ref: second/%[1]s
+    outputFile: second.wrap.tgz
+    platforms: [linux/amd64]
+`, scenarioName)), 0644))
+		numDigests := 0
+		for _, imgData := range images {
+			numDigests += len(imgData.Digests)
+		}
+
+		// Run as in CI, with the plain log in stderr, so stdout only contains the summary
+		res := execCommand("wrap", "-f", manifestFile, "--output", "json")
+		res.AssertSuccess(t)
+		summary := &runSummary{}
+		require.NoError(json.Unmarshal([]byte(res.stdout), summary))
+		expectedOutputs := []string{filepath.Join(manifestDir, "first.wrap.tgz"), filepath.Join(manifestDir, "second.wrap.tgz")}
+		assert.Equal(expectedOutputs, summary.Outputs)
+		for _, wrapFile := range expectedOutputs {
+			assert.FileExists(wrapFile)
+		}
+		// The second chart reuses the images pulled for the first one
+		assert.Equal(numDigests, summary.Transfers.ImagesPulled)
+		assert.Greater(summary.Transfers.CacheHits, 0)
+
+		res = dt("wrap", "-f", manifestFile, "--quiet")
+		res.AssertSuccess(t)
+		assert.Equal(strings.Join(expectedOutputs, "\n")+"\n", res.stdout)
+
+		dt("wrap").AssertErrorMatch(t, "either a Helm chart or a wrap manifest")
+		dt("wrap", "-f", manifestFile, "--output-file", "out.wrap.tgz").AssertErrorMatch(t, "--output-file cannot be used with --file")
+		require.NoError(os.WriteFile(manifestFile, []byte("charts: []\n"), 0644))
+		dt("wrap", "-f", manifestFile).AssertErrorMatch(t, "does not list any Helm chart")
+	})
+
+	t.Run("Wrap Chart exporting metrics", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		numDigests := 0
+		for _, imgData := range images {
+			numDigests += len(imgData.Digests)
+		}
+
+		var pushMethod, pushPath, pushBody string
+		gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			pushMethod, pushPath, pushBody = r.Method, r.URL.Path, string(data)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer gateway.Close()
+
+		metricsFile := filepath.Join(sb.TempFile(), "dt.prom")
+		require.NoError(os.MkdirAll(filepath.Dir(metricsFile), 0755))
+		outputFile := fmt.Sprintf("%s/chart.wrap.tgz", sb.TempFile())
+		dt("wrap", chartDir, "--output-file", outputFile,
+			"--metrics-file", metricsFile, "--metrics-pushgateway", gateway.URL).AssertSuccess(t)
+
+		data, err := os.ReadFile(metricsFile)
+		require.NoError(err)
+		metrics := string(data)
+		assert.Contains(metrics, fmt.Sprintf(`dt_images_pulled_total{command="wrap"} %d`, numDigests))
+		assert.Contains(metrics, `dt_success{command="wrap"} 1`)
+		assert.Contains(metrics, `dt_stage_duration_seconds{command="wrap",stage="pull images"}`)
+		assert.Contains(metrics, `dt_bytes_transferred_total{command="wrap",direction="download"}`)
+
+		assert.Equal(http.MethodPut, pushMethod)
+		assert.Equal("/metrics/job/dt/command/wrap", pushPath)
+		assert.NotEmpty(pushBody)
+
+		// Metrics are also exported when failing
+		dt("wrap", chartDir, "--output-file", outputFile, "--metrics-file", metricsFile,
+			"--allowed-registries", "registry.example.com").AssertErrorMatch(t, "")
+		data, err = os.ReadFile(metricsFile)
+		require.NoError(err)
+		assert.Contains(string(data), `dt_success{command="wrap"} 0`)
+	})
+
+	t.Run("Wrap Chart with SBOM", func(t *testing.T) {
+		sbomFile := filepath.Join(sb.TempFile(), "sbom.json")
+		require.NoError(os.MkdirAll(filepath.Dir(sbomFile), 0755))
+
+		wrapDir := testSampleWrap(t, withLock, "", "--sbom", "--sbom-format", "cyclonedx", "--sbom-file", sbomFile)
+
+		embeddedFile := filepath.Join(wrapDir, "sbom.cdx.json")
+		require.FileExists(embeddedFile)
+		require.FileExists(sbomFile)
+
+		data, err := os.ReadFile(embeddedFile)
+		require.NoError(err)
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				assert.Contains(string(data), digestData.Digest.String())
+			}
+		}
+		extraData, err := os.ReadFile(sbomFile)
+		require.NoError(err)
+		assert.Equal(string(data), string(extraData))
+	})
+	t.Run("Wrap Chart scanning images", func(t *testing.T) {
+		writeFakeTrivy := func(t *testing.T, severity string) {
+			binDir, err := sb.Mkdir(sb.TempFile(), 0755)
+			require.NoError(err)
+			script := fmt.Sprintf("#!/bin/sh\necho '{\"Results\": [{\"Vulnerabilities\": [{\"VulnerabilityID\": \"CVE-2023-0001\", \"Severity\": \"%s\"}]}]}'\n", severity)
+			require.NoError(os.WriteFile(filepath.Join(binDir, "trivy"), []byte(script), 0755))
+			t.Setenv("PATH", fmt.Sprintf("%s%c%s", binDir, os.PathListSeparator, os.Getenv("PATH")))
+		}
+		t.Run("Fails when finding vulnerabilities", func(t *testing.T) {
+			writeFakeTrivy(t, "CRITICAL")
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--scan", "trivy").AssertErrorMatch(t, "failed to wrap Helm chart")
+		})
+		t.Run("Only warns if requested", func(t *testing.T) {
+			writeFakeTrivy(t, "CRITICAL")
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--scan", "trivy", "--scan-warn-only").AssertSuccess(t)
+		})
+		t.Run("Ignores vulnerabilities below the threshold", func(t *testing.T) {
+			writeFakeTrivy(t, "MEDIUM")
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--scan", "trivy", "--scan-severity", "high").AssertSuccess(t)
+		})
+		t.Run("Fails with unknown scanner", func(t *testing.T) {
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--scan", "foo").AssertErrorMatch(t, `unsupported scanner "foo"`)
+		})
+	})
+	t.Run("Wrap Chart checking images policies", func(t *testing.T) {
+		writePolicy := func(rule string) string {
+			policyFile := filepath.Join(sb.TempFile(), "policy.rego")
+			require.NoError(os.MkdirAll(filepath.Dir(policyFile), 0755))
+			require.NoError(os.WriteFile(policyFile, []byte(fmt.Sprintf("package dt\n\n%s\n", rule)), 0644))
+			return policyFile
+		}
+		t.Run("Succeeds when complying with the policies", func(t *testing.T) {
+			policyFile := writePolicy(`deny[msg] { input.image.tag == "latest"; msg := "latest tag not allowed" }`)
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--policy", policyFile).AssertSuccess(t)
+		})
+		t.Run("Fails when violating the policies", func(t *testing.T) {
+			policyFile := writePolicy(fmt.Sprintf(`deny[msg] { input.image.registry == %q; msg := "registry not allowed" }`, serverURL))
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--policy", policyFile).AssertErrorMatch(t, "failed to wrap Helm chart")
+		})
+		t.Run("Fails with missing policies", func(t *testing.T) {
+			chartDir := createSampleChart(sb.TempFile(), withLock)
+			dt("wrap", chartDir, "--output-file", sb.TempFile(), "--policy", sb.TempFile()).AssertErrorMatch(t, "failed to wrap Helm chart")
+		})
+	})
+	t.Run("Wrap Chart with provenance", func(t *testing.T) {
+		binDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		script := "#!/bin/sh\nwhile [ \"$1\" != \"--output-signature\" ]; do shift; done\necho signed > \"$2\"\n"
+		require.NoError(os.WriteFile(filepath.Join(binDir, "cosign"), []byte(script), 0755))
+		t.Setenv("PATH", fmt.Sprintf("%s%c%s", binDir, os.PathListSeparator, os.Getenv("PATH")))
+
+		outputFile := fmt.Sprintf("%s.wrap.tgz", sb.TempFile())
+		testSampleWrap(t, withLock, outputFile, "--provenance-key", "cosign.key")
+
+		provenanceFile := outputFile + ".provenance.json"
+		require.FileExists(provenanceFile)
+		assert.FileExists(provenanceFile + ".sig")
+		data, err := os.ReadFile(provenanceFile)
+		require.NoError(err)
+		for _, imgData := range images {
+			for _, digestData := range imgData.Digests {
+				assert.Contains(string(data), digestData.Digest.Encoded())
+			}
+		}
+		assert.Contains(string(data), provenance.PredicateType)
+	})
+	t.Run("Wrap Chart with GPG signature", func(t *testing.T) {
+		keysDir, err := sb.Mkdir(sb.TempFile(), 0755)
+		require.NoError(err)
+		secring, pubring, err := writeKeyrings(keysDir, "dt")
+		require.NoError(err)
+
+		outputFile := fmt.Sprintf("%s.wrap.tgz", sb.TempFile())
+		testSampleWrap(t, withLock, outputFile, "--sign-key", "dt@example.com", "--keyring", secring)
+
+		sigFile := signature.FileName(outputFile)
+		require.FileExists(sigFile)
+		signer, err := signature.VerifyFile(outputFile, sigFile, signature.WithKeyring(pubring))
+		require.NoError(err)
+		assert.Equal("dt <dt@example.com>", signer)
+
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		dt("wrap", chartDir, "--output-file", sb.TempFile(), "--sign-key", "missing", "--keyring", secring).
+			AssertErrorMatch(t, "failed to wrap Helm chart")
+	})
+	t.Run("Wrap Chart encrypting it", func(t *testing.T) {
+		passFile := filepath.Join(sb.TempFile(), "passphrase")
+		require.NoError(os.MkdirAll(filepath.Dir(passFile), 0755))
+		require.NoError(os.WriteFile(passFile, []byte("secret"), 0600))
+
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		outputFile := fmt.Sprintf("%s.wrap.tgz", sb.TempFile())
+		dt("wrap", chartDir, "--output-file", outputFile, "--encrypt", "passphrase:"+passFile).AssertSuccess(t)
+
+		encryptedFile := outputFile + encryption.Extension
+		require.FileExists(encryptedFile)
+		assert.NoFileExists(outputFile)
+		isEncrypted, err := encryption.IsEncrypted(encryptedFile)
+		require.NoError(err)
+		assert.True(isEncrypted)
+
+		id, err := encryption.PassphraseIdentity(passFile)
+		require.NoError(err)
+		require.NoError(encryption.DecryptFile(encryptedFile, outputFile, id))
+		isTar, err := utils.IsTarFile(outputFile)
+		require.NoError(err)
+		assert.True(isTar)
+
+		dt("wrap", chartDir, "--encrypt", "foo:bar").AssertErrorMatch(t, `unsupported encryption method "foo"`)
+
+		// No unencrypted wrap is left behind if the encrypted one cannot be written
+		outputDir := sb.TempFile()
+		outputFile = filepath.Join(outputDir, "chart.wrap.tgz")
+		require.NoError(os.MkdirAll(filepath.Join(outputFile+encryption.Extension, "busy"), 0755))
+		dt("wrap", chartDir, "--output-file", outputFile, "--encrypt", "passphrase:"+passFile).AssertError(t)
+		entries, err := os.ReadDir(outputDir)
+		require.NoError(err)
+		assert.Len(entries, 1)
+	})
+	t.Run("Wrap Chart pushing it as an OCI artifact", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		outputFile := fmt.Sprintf("%s.wrap.tgz", sb.TempFile())
+		dt("wrap", chartDir, "--output-file", outputFile, "--push", fmt.Sprintf("oci://%s/wraps", serverURL)).AssertSuccess(t)
+		assert.FileExists(outputFile)
+
+		ref, err := name.ParseReference(fmt.Sprintf("%s/wraps/%s-wrap:%s", serverURL, chartName, version))
+		require.NoError(err)
+		desc, err := remote.Get(ref)
+		require.NoError(err)
+		m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+		require.NoError(err)
+		assert.Equal(artifact.ConfigMediaType, m.Config.MediaType)
+		numDigests := 0
+		for _, imgData := range images {
+			numDigests += len(imgData.Digests)
+		}
+		// One layer for the chart, and one for each image
+		assert.Len(m.Layers, numDigests+1)
+
+		dt("wrap", chartDir, "--push", serverURL).AssertErrorMatch(t, "--push only supports OCI registries")
+		dt("wrap", chartDir, "--push", "oci://"+serverURL, "--encrypt", "age:foo").AssertErrorMatch(t, "--push cannot be used with --encrypt")
+	})
+	t.Run("Wrap Chart fails with unknown SBOM format", func(t *testing.T) {
+		chartDir := createSampleChart(sb.TempFile(), withLock)
+		dt("wrap", chartDir, "--sbom", "--sbom-format", "foo").AssertErrorMatch(t, `unsupported SBOM format "foo"`)
+	})
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/objectstore"
+)
+
+// wrapChartToStorage wraps the chart into a temporary directory and uploads the resulting files, including
+// signatures, provenance and wrapped subcharts, next to the outputURL object storage location
+func wrapChartToStorage(ctx context.Context, inputPath string, outputURL string, platforms []string, flags *pflag.FlagSet, cfg *wrapConfig) (string, error) {
+	client, err := objectstore.New(outputURL)
+	if err != nil {
+		return "", err
+	}
+	tempDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return "", err
+	}
+	uploadDir, err := os.MkdirTemp(tempDir, "upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	wrapFile, err := wrapChartWithConfig(ctx, inputPath, filepath.Join(uploadDir, path.Base(outputURL)), platforms, flags, cfg)
+	if err != nil {
+		return "", err
+	}
+	var uploaded map[string]string
+	if err := cfg.Summary.stage(ctx, "upload", func(ctx context.Context) (err error) {
+		uploaded, err = uploadWrapFiles(ctx, client, uploadDir, outputURL, cfg.logger())
+		return err
+	}); err != nil {
+		return "", err
+	}
+	for i, f := range cfg.Summary.Outputs {
+		if u, ok := uploaded[f]; ok {
+			cfg.Summary.Outputs[i] = u
+		}
+	}
+	cfg.Summary.Output = uploaded[wrapFile]
+	return uploaded[wrapFile], nil
+}
+
+// uploadWrapFiles uploads the files in dir next to outputURL and returns their URLs, indexed by file
+func uploadWrapFiles(ctx context.Context, client *objectstore.Client, dir string, outputURL string, parentLog log.SectionLogger) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wrap files: %w", err)
+	}
+	uploaded := make(map[string]string)
+	err = parentLog.Section(fmt.Sprintf("Uploading wrap to %q", outputURL), func(l log.SectionLogger) error {
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			file := filepath.Join(dir, e.Name())
+			dest := objectstore.Sibling(outputURL, e.Name())
+			if err := l.ExecuteStep(fmt.Sprintf("Uploading %q", e.Name()), func() error {
+				return client.Upload(ctx, file, dest)
+			}); err != nil {
+				return l.Failf("Failed to upload wrap: %w", err)
+			}
+			uploaded[file] = dest
+			l.Infof("Uploaded %q", dest)
+		}
+		return nil
+	})
+	return uploaded, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/encryption"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+var wrapVerifyCmd = newWrapVerifyCommand()
+
+func verifyWrap(wrapFile string, l log.SectionLogger) error {
+	if isEncrypted, _ := encryption.IsEncrypted(wrapFile); isEncrypted {
+		return l.Failf("the wrap is encrypted and must be decrypted before verifying it")
+	}
+	tempDir, err := getGlobalTempWorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	var chartDir string
+	if err := l.ExecuteStep("Uncompressing wrap", func() error {
+		var err error
+		chartDir, err = wrap.Extract(context.Background(), wrapFile, tempDir)
+		return err
+	}); err != nil {
+		return l.Failf("Failed to uncompress %q: %w", wrapFile, err)
+	}
+
+	if checksumsFile := filepath.Join(chartDir, utils.ChecksumsFileName); utils.FileExists(checksumsFile) {
+		if err := l.ExecuteStep("Verifying checksums", func() error {
+			return utils.VerifyChecksums(chartDir, utils.ChecksumsFileName)
+		}); err != nil {
+			return l.Failf("Failed to verify checksums: %w", err)
+		}
+		l.Infof("All files match their checksums")
+	} else {
+		l.Warnf("The wrap does not include a %s file, skipping files verification", utils.ChecksumsFileName)
+	}
+
+	lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, imagelock.DefaultImagesLockFileName))
+	if err != nil {
+		return l.Failf("Failed to read Images.lock: %w", err)
+	}
+	delta, err := readWrapDelta(chartDir)
+	if err != nil {
+		return l.Failf("%w", err)
+	}
+	if delta != nil {
+		l.Infof("The wrap is a delta of %q, skipping the %d images omitted from it", delta.Base.File, len(delta.Images))
+	}
+	return l.Section("Verifying images", func(childLog log.SectionLogger) error {
+		if err := chartutils.VerifyImages(withoutDeltaImages(lock, delta), filepath.Join(chartDir, "images"),
+			chartutils.WithProgressBar(childLog.ProgressBar()),
+		); err != nil {
+			return childLog.Failf("%v", err)
+		}
+		childLog.Infof("All images match the Images.lock digests")
+		return nil
+	})
+}
+
+func newWrapVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify FILE",
+		Short: "Verifies the integrity of a wrapped Helm chart",
+		Long: `Verifies the integrity of a wrapped Helm chart without accessing the network.
+The files in the wrap are checked against its checksums.sha256 file and the image tarballs against the Images.lock digests`,
+		Example: `  # Verify a wrapped Helm chart
+  $ dt wrap verify mariadb-12.2.8.wrap.tgz`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wrapFile := args[0]
+			parentLog := getLogger()
+			l := parentLog.StartSection(fmt.Sprintf("Verifying wrap %q", wrapFile))
+			if err := verifyWrap(wrapFile, l); err != nil {
+				if _, ok := err.(*log.LoggedError); ok {
+					return fmt.Errorf("failed to verify wrap")
+				}
+				return err
+			}
+			l.Printf(terminalSpacer)
+			parentLog.Successf("Wrap %q verified successfully", wrapFile)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func init() {
+	wrapCmd.AddCommand(wrapVerifyCmd)
+}
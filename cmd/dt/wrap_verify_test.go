@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func (suite *CmdSuite) TestWrapVerifyCommand() {
+	t := suite.T()
+	require := suite.Require()
+	sb := suite.sb
+
+	scenarioName := "complete-chart"
+	scenarioDir := fmt.Sprintf("../../testdata/scenarios/%s", scenarioName)
+	serverURL := "localhost"
+
+	// createWrap creates a wrap from a sample chart, allowing to modify it after the checksums are written
+	createWrap := func(withChecksums bool, modify func(chartDir string)) string {
+		dest := sb.TempFile()
+		chartDir := filepath.Join(dest, scenarioName)
+
+		images, err := writeSampleImages("test", "mytag", filepath.Join(chartDir, "images"))
+		require.NoError(err)
+		require.NoError(tu.RenderScenario(scenarioDir, dest,
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": serverURL},
+		))
+		data, err := tu.RenderTemplateFile(filepath.Join(scenarioDir, "imagelock.partial.tmpl"),
+			map[string]interface{}{"ServerURL": serverURL, "Images": images, "Name": "test", "Version": "1.0.0"},
+		)
+		require.NoError(err)
+		require.NoError(os.WriteFile(filepath.Join(chartDir, "Images.lock"), []byte(data), 0644))
+		if withChecksums {
+			require.NoError(utils.WriteChecksums(chartDir, utils.ChecksumsFileName))
+		}
+		if modify != nil {
+			modify(chartDir)
+		}
+		wrapFile := filepath.Join(dest, "test-1.0.0.wrap.tgz")
+		require.NoError(utils.Tar(chartDir, wrapFile, utils.TarConfig{Prefix: "test-1.0.0"}))
+		return wrapFile
+	}
+
+	t.Run("Verifies a valid wrap", func(t *testing.T) {
+		dt("wrap", "verify", createWrap(true, nil)).AssertSuccess(t)
+	})
+	t.Run("Extracts the wrap into the work directory", func(t *testing.T) {
+		workDir := filepath.Join(sb.TempFile(), "work")
+		dt("wrap", "verify", "--work-dir", workDir, "--keep-artifacts", createWrap(true, nil)).AssertSuccess(t)
+		matches, err := filepath.Glob(filepath.Join(workDir, "chart-*", "*", "Chart.yaml"))
+		require.NoError(err)
+		require.Len(matches, 1)
+	})
+	t.Run("Verifies a wrap without checksums", func(t *testing.T) {
+		dt("wrap", "verify", createWrap(false, nil)).AssertSuccess(t)
+	})
+	t.Run("Detects modified files", func(t *testing.T) {
+		wrapFile := createWrap(true, func(chartDir string) {
+			fh, err := os.OpenFile(filepath.Join(chartDir, "Chart.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+			require.NoError(err)
+			defer fh.Close()
+			_, err = fh.WriteString("# modified\n")
+			require.NoError(err)
+		})
+		res := dt("wrap", "verify", wrapFile)
+		res.AssertErrorMatch(t, "failed to verify wrap")
+		assert.Regexp(t, `checksum mismatch for \\?"Chart.yaml\\?"`, res.stdout)
+	})
+	t.Run("Detects corrupted images", func(t *testing.T) {
+		wrapFile := createWrap(false, func(chartDir string) {
+			imgFiles, err := filepath.Glob(filepath.Join(chartDir, "images", "*.tar"))
+			require.NoError(err)
+			require.NotEmpty(imgFiles)
+			require.NoError(os.WriteFile(imgFiles[0], []byte("corrupted"), 0644))
+		})
+		dt("wrap", "verify", wrapFile).AssertErrorMatch(t, "failed to verify wrap")
+	})
+	t.Run("Detects corrupted tarballs", func(t *testing.T) {
+		wrapFile := createWrap(true, nil)
+		data, err := os.ReadFile(wrapFile)
+		require.NoError(err)
+		require.NoError(os.WriteFile(wrapFile, data[:len(data)/2], 0644))
+		dt("wrap", "verify", wrapFile).AssertErrorMatch(t, "failed to verify wrap")
+	})
+}
@@ -0,0 +1,150 @@
+// Package encryption implements the encryption and decryption of wrapped Helm charts
+// using the age file encryption format
+package encryption
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Extension is the extension appended to encrypted files
+const Extension = ".age"
+
+// ageHeader is the first line of any age encrypted file
+const ageHeader = "age-encryption.org/v1"
+
+// readPassphrase reads a passphrase from file, ignoring surrounding whitespace
+func readPassphrase(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimSpace(string(data))
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %q is empty", file)
+	}
+	return passphrase, nil
+}
+
+// ParseRecipient returns the age.Recipient described by spec, which can be either
+// "age:<recipient>", with an age X25519 public key, or "passphrase:<file>",
+// with a file containing the passphrase for symmetric encryption
+func ParseRecipient(spec string) (age.Recipient, error) {
+	kind, value, found := strings.Cut(spec, ":")
+	if !found || value == "" {
+		return nil, fmt.Errorf("invalid encryption recipient %q: expected age:<recipient> or passphrase:<file>", spec)
+	}
+	switch kind {
+	case "age":
+		r, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient: %w", err)
+		}
+		return r, nil
+	case "passphrase":
+		passphrase, err := readPassphrase(value)
+		if err != nil {
+			return nil, err
+		}
+		return age.NewScryptRecipient(passphrase)
+	default:
+		return nil, fmt.Errorf("unsupported encryption method %q", kind)
+	}
+}
+
+// ReadIdentities returns the age identities stored in identityFile
+func ReadIdentities(identityFile string) ([]age.Identity, error) {
+	fh, err := os.Open(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open identity file: %w", err)
+	}
+	defer fh.Close()
+	ids, err := age.ParseIdentities(fh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %q: %w", identityFile, err)
+	}
+	return ids, nil
+}
+
+// PassphraseIdentity returns an identity decrypting files encrypted with the passphrase stored in file
+func PassphraseIdentity(file string) (age.Identity, error) {
+	passphrase, err := readPassphrase(file)
+	if err != nil {
+		return nil, err
+	}
+	return age.NewScryptIdentity(passphrase)
+}
+
+// IsEncrypted returns true if file is age encrypted
+func IsEncrypted(file string) (bool, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+	line, err := bufio.NewReader(fh).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return strings.TrimSpace(line) == ageHeader, nil
+}
+
+// EncryptFile encrypts src into dst for the provided recipients
+func EncryptFile(src string, dst string, recipients ...age.Recipient) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no encryption recipients provided")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", dst, err)
+	}
+	defer out.Close()
+
+	w, err := age.Encrypt(out, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %q: %w", src, err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("failed to encrypt %q: %w", src, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt %q: %w", src, err)
+	}
+	return out.Close()
+}
+
+// DecryptFile decrypts src into dst using the first matching identity
+func DecryptFile(src string, dst string, identities ...age.Identity) error {
+	if len(identities) == 0 {
+		return fmt.Errorf("the file is encrypted but no identity or passphrase was provided")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", src, err)
+	}
+	defer in.Close()
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %q: %w", src, err)
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", dst, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("failed to decrypt %q: %w", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,84 @@
+package encryption
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir string, name string, data string) string {
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(file, []byte(data), 0644))
+	return file
+}
+
+func TestParseRecipient(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, err = ParseRecipient("age:" + id.Recipient().String())
+	assert.NoError(t, err)
+	_, err = ParseRecipient("passphrase:" + writeFile(t, t.TempDir(), "pass", "secret\n"))
+	assert.NoError(t, err)
+
+	_, err = ParseRecipient("age:invalid")
+	assert.ErrorContains(t, err, "invalid age recipient")
+	_, err = ParseRecipient("gpg:foo")
+	assert.ErrorContains(t, err, `unsupported encryption method "gpg"`)
+	_, err = ParseRecipient("age")
+	assert.ErrorContains(t, err, "expected age:<recipient> or passphrase:<file>")
+	_, err = ParseRecipient("passphrase:" + writeFile(t, t.TempDir(), "pass", "\n"))
+	assert.ErrorContains(t, err, "is empty")
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	src := writeFile(t, dir, "chart.wrap.tgz", "wrap contents")
+	encrypted := src + Extension
+
+	t.Run("With age keys", func(t *testing.T) {
+		id, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		r, err := ParseRecipient("age:" + id.Recipient().String())
+		require.NoError(t, err)
+		require.NoError(t, EncryptFile(src, encrypted, r))
+
+		isEncrypted, err := IsEncrypted(encrypted)
+		require.NoError(t, err)
+		assert.True(t, isEncrypted)
+		isEncrypted, err = IsEncrypted(src)
+		require.NoError(t, err)
+		assert.False(t, isEncrypted)
+
+		ids, err := ReadIdentities(writeFile(t, dir, "key.txt", id.String()+"\n"))
+		require.NoError(t, err)
+		decrypted := filepath.Join(dir, "decrypted.tgz")
+		require.NoError(t, DecryptFile(encrypted, decrypted, ids...))
+		data, err := os.ReadFile(decrypted)
+		require.NoError(t, err)
+		assert.Equal(t, "wrap contents", string(data))
+
+		other, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		assert.ErrorContains(t, DecryptFile(encrypted, decrypted, other), "failed to decrypt")
+		assert.ErrorContains(t, DecryptFile(encrypted, decrypted), "no identity or passphrase was provided")
+	})
+	t.Run("With passphrase", func(t *testing.T) {
+		passFile := writeFile(t, dir, "pass", "secret")
+		r, err := ParseRecipient("passphrase:" + passFile)
+		require.NoError(t, err)
+		require.NoError(t, EncryptFile(src, encrypted, r))
+
+		id, err := PassphraseIdentity(passFile)
+		require.NoError(t, err)
+		decrypted := filepath.Join(dir, "decrypted.tgz")
+		require.NoError(t, DecryptFile(encrypted, decrypted, id))
+		data, err := os.ReadFile(decrypted)
+		require.NoError(t, err)
+		assert.Equal(t, "wrap contents", string(data))
+	})
+}
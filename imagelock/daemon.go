@@ -0,0 +1,48 @@
+package imagelock
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+)
+
+// DaemonImage returns the image referenced by image from the local Docker daemon, which is selected with
+// the usual DOCKER_HOST environment variables unless host, such as the podman API socket, is provided
+func DaemonImage(ctx context.Context, image string, host string) (v1.Image, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference %q: %w", image, err)
+	}
+	opts := []daemon.Option{daemon.WithContext(ctx)}
+	if host != "" {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(host), client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for %q: %w", host, err)
+		}
+		defer cli.Close()
+		opts = append(opts, daemon.WithClient(cli))
+	}
+	img, err := daemon.Image(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q from the local Docker daemon: %w", image, err)
+	}
+	return img, nil
+}
+
+// fetchDaemonImageDigests returns the digest of the image stored in the local Docker daemon. Images stored in
+// the daemon are single platform, so they have a single digest
+func fetchDaemonImageDigests(r string, cfg *Config) ([]DigestInfo, error) {
+	img, err := DaemonImage(cfg.Context, r, cfg.DaemonHost)
+	if err != nil {
+		return nil, err
+	}
+	d, err := readDigestInfoFromImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the digest of %q from the local Docker daemon: %w", r, err)
+	}
+	return []DigestInfo{d}, nil
+}
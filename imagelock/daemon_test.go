@@ -0,0 +1,44 @@
+package imagelock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func TestFetchDigestsFromLocalDaemon(t *testing.T) {
+	// Nothing listens on the registry, so the image is only available in the daemon
+	image := "127.0.0.1:1/local/app:dev"
+	imageData := &tu.ImageData{Name: "app", Image: image}
+	img, err := tu.CreateSingleArchImage(imageData, "linux/arm64")
+	require.NoError(t, err)
+
+	d := tu.NewDockerDaemon()
+	defer d.Close()
+	d.AddImage(image, img)
+	t.Setenv("DOCKER_HOST", d.Host())
+
+	t.Run("Reads the digest from the daemon", func(t *testing.T) {
+		ci := &ChartImage{Name: "app", Image: image}
+		require.NoError(t, ci.FetchDigests(NewImagesLockConfig(WithLocalDaemon(true))))
+		assert.Equal(t, []DigestInfo{{Arch: "linux/arm64", Digest: imageData.Digests[0].Digest}}, ci.Digests)
+	})
+	t.Run("Reads the digest from the daemon listening on the given host", func(t *testing.T) {
+		t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+		ci := &ChartImage{Name: "app", Image: image}
+		require.NoError(t, ci.FetchDigests(NewImagesLockConfig(WithLocalDaemon(true), WithDaemonHost(d.Host()))))
+		assert.Equal(t, imageData.Digests[0].Digest, ci.Digests[0].Digest)
+	})
+	t.Run("Requires the daemon to be enabled", func(t *testing.T) {
+		ci := &ChartImage{Name: "app", Image: image}
+		assert.ErrorContains(t, ci.FetchDigests(NewImagesLockConfig()), "failed to get descriptor")
+	})
+	t.Run("Reports both failures", func(t *testing.T) {
+		ci := &ChartImage{Name: "app", Image: "127.0.0.1:1/local/missing:dev"}
+		err := ci.FetchDigests(NewImagesLockConfig(WithLocalDaemon(true)))
+		assert.ErrorContains(t, err, "failed to get descriptor")
+		assert.ErrorContains(t, err, "from the local Docker daemon")
+	})
+}
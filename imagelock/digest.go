@@ -22,7 +22,16 @@ type DigestInfo struct {
 func fetchImageDigests(r string, cfg *Config) ([]DigestInfo, error) {
 	desc, err := getRemoteDescriptor(r, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get descriptor: %v", err)
+		err = fmt.Errorf("failed to get descriptor: %v", err)
+		if !cfg.LocalDaemon {
+			return nil, err
+		}
+		// Images only available locally, such as freshly built ones, are read from the daemon
+		digests, daemonErr := fetchDaemonImageDigests(r, cfg)
+		if daemonErr != nil {
+			return nil, errors.Join(err, daemonErr)
+		}
+		return digests, nil
 	}
 
 	switch desc.MediaType {
@@ -59,6 +68,12 @@ func getRemoteDescriptor(r string, cfg *Config) (*remote.Descriptor, error) {
 		opts = append(opts, crane.Insecure)
 	}
 	opts = append(opts, crane.WithContext(cfg.Context))
+	if cfg.Keychain != nil {
+		opts = append(opts, crane.WithAuthFromKeychain(cfg.Keychain))
+	}
+	if cfg.Transport != nil {
+		opts = append(opts, crane.WithTransport(cfg.Transport))
+	}
 
 	o := crane.GetOptions(opts...)
 
@@ -67,7 +82,9 @@ func getRemoteDescriptor(r string, cfg *Config) (*remote.Descriptor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse reference %q: %w", r, err)
 	}
-	return remote.Get(ref, o.Remote...)
+	return Fetch(cfg.Mirrors, ref, func(r name.Reference) (*remote.Descriptor, error) {
+		return remote.Get(r, o.Remote...)
+	}, o.Name...)
 }
 
 func readDigestsInfoFromIndex(idx v1.IndexManifest) ([]DigestInfo, error) {
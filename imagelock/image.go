@@ -18,6 +18,9 @@ type ChartImage struct {
 	Image   string       // The image reference.
 	Chart   string       // The chart containing the image.
 	Digests []DigestInfo // List of image digests associated with the image.
+	// Destination, if set, is the repository the image is relocated to, instead of the one derived from
+	// the relocation prefix
+	Destination string `yaml:"destination,omitempty"`
 }
 
 // ImageList defines a list of images
@@ -42,12 +45,13 @@ func (imgs ImageList) Dedup() ImageList {
 // named images, ready to be inserted in the Chart.yaml file
 func (imgs ImageList) ToAnnotation() ([]byte, error) {
 	type rawDataElem struct {
-		Name  string
-		Image string
+		Name        string
+		Image       string
+		Destination string `yaml:",omitempty"`
 	}
 	rawData := make([]rawDataElem, 0)
 	for _, img := range imgs {
-		rawData = append(rawData, rawDataElem{Name: img.Name, Image: img.Image})
+		rawData = append(rawData, rawDataElem{Name: img.Name, Image: img.Image, Destination: img.Destination})
 	}
 	buf := &bytes.Buffer{}
 	enc := yaml.NewEncoder(buf)
@@ -107,17 +111,42 @@ func (i *ChartImage) FetchDigests(cfg *Config) error {
 	return nil
 }
 
+// AnnotationsKeys returns the annotation keys, in fallback order, in the comma-separated key list, followed by
+// the well-known keys used by other tools
+func AnnotationsKeys(key string) []string {
+	keys := make([]string, 0)
+	for _, k := range strings.Split(key, ",") {
+		if k = strings.TrimSpace(k); k != "" && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		keys = append(keys, DefaultAnnotationsKey)
+	}
+	if !slices.Contains(keys, ArtifactHubAnnotationsKey) {
+		keys = append(keys, ArtifactHubAnnotationsKey)
+	}
+	return keys
+}
+
+// ResolveAnnotationsKey returns the first of the keys defined by the comma-separated key list present in the
+// annotations, or the first key in the list if none is
+func ResolveAnnotationsKey(annotations map[string]string, key string) string {
+	keys := AnnotationsKeys(key)
+	for _, k := range keys {
+		if _, ok := annotations[k]; ok {
+			return k
+		}
+	}
+	return keys[0]
+}
+
 // GetImagesFromChartAnnotations reads the images annotation from the chart (if present) and returns a list of
 // ChartImage
 func GetImagesFromChartAnnotations(c *chart.Chart, cfg *Config) (ImageList, error) {
 	images := make([]*ChartImage, 0)
 
-	annotationsKey := cfg.AnnotationsKey
-	if annotationsKey == "" {
-		annotationsKey = DefaultAnnotationsKey
-	}
-
-	imgsData, ok := c.Metadata.Annotations[annotationsKey]
+	imgsData, ok := c.Metadata.Annotations[ResolveAnnotationsKey(c.Metadata.Annotations, cfg.AnnotationsKey)]
 
 	// Is perfectly fine to just return an empty list
 	// if the key is not there
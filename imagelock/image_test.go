@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
 )
 
 func TestImageList_ToAnnotation(t *testing.T) {
@@ -31,3 +32,42 @@ func TestImageList_ToAnnotation(t *testing.T) {
 		assert.Equal(t, tu.MustNormalizeYAML(expected), tu.MustNormalizeYAML(string(got)))
 	})
 }
+
+func TestGetImagesFromChartAnnotations(t *testing.T) {
+	newChart := func(annotations map[string]string) *chart.Chart {
+		return &chart.Chart{Metadata: &chart.Metadata{Name: "app", Annotations: annotations}}
+	}
+	appAnnotation := "- name: app\n  image: app:1.0.0\n"
+	blogAnnotation := "- name: blog\n  image: blog:v2\n  whitelisted: true\n"
+
+	tests := map[string]struct {
+		key         string
+		annotations map[string]string
+		expected    []string
+	}{
+		"Reads the default key":                  {key: "", annotations: map[string]string{"images": appAnnotation}, expected: []string{"app:1.0.0"}},
+		"Falls back to the Artifact Hub key":     {key: "images", annotations: map[string]string{ArtifactHubAnnotationsKey: blogAnnotation}, expected: []string{"blog:v2"}},
+		"Prefers the keys in the order given":    {key: "custom, images", annotations: map[string]string{"images": blogAnnotation, "custom": appAnnotation}, expected: []string{"app:1.0.0"}},
+		"Falls back to the next key in the list": {key: "custom,images", annotations: map[string]string{"images": blogAnnotation}, expected: []string{"blog:v2"}},
+		"Returns no images if no key is present": {key: "custom", annotations: map[string]string{"other": appAnnotation}, expected: []string{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			images, err := GetImagesFromChartAnnotations(newChart(tc.annotations), NewImagesLockConfig(WithAnnotationsKey(tc.key)))
+			require.NoError(t, err)
+			got := make([]string, 0)
+			for _, img := range images {
+				got = append(got, img.Image)
+				assert.Equal(t, "app", img.Chart)
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestResolveAnnotationsKey(t *testing.T) {
+	assert.Equal(t, DefaultAnnotationsKey, ResolveAnnotationsKey(nil, ""))
+	assert.Equal(t, "custom", ResolveAnnotationsKey(map[string]string{}, "custom,images"))
+	assert.Equal(t, ArtifactHubAnnotationsKey, ResolveAnnotationsKey(map[string]string{ArtifactHubAnnotationsKey: ""}, "custom"))
+	assert.Equal(t, []string{"custom", "images", ArtifactHubAnnotationsKey}, AnnotationsKeys(" custom , images,custom"))
+}
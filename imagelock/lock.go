@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
+
 	"gopkg.in/yaml.v3"
 
 	"helm.sh/helm/v3/pkg/chart"
@@ -24,6 +27,10 @@ const DefaultImagesLockFileName = "Images.lock"
 // DefaultAnnotationsKey is the default annotations key used to include the images metadata
 const DefaultAnnotationsKey = "images"
 
+// ArtifactHubAnnotationsKey is the annotations key used by Artifact Hub to list the chart images, recognized as a
+// fallback when none of the requested keys are present
+const ArtifactHubAnnotationsKey = "artifacthub.io/images"
+
 // ImagesLock represents the lock file containing information about the included images.
 type ImagesLock struct {
 	APIVersion string            `yaml:"apiVersion"` // The version of the API used for the lock file.
@@ -127,6 +134,10 @@ func GenerateFromChart(chartPath string, opts ...Option) (*ImagesLock, error) {
 		return nil, fmt.Errorf("failed to load Helm chart: %v", err)
 	}
 
+	if err := checkAnnotatedImages(chart, cfg); err != nil {
+		return nil, err
+	}
+
 	imgLock := NewImagesLock()
 
 	imgLock.Chart.Name = chart.Name()
@@ -136,10 +147,71 @@ func GenerateFromChart(chartPath string, opts ...Option) (*ImagesLock, error) {
 	if err := populateImagesFromChart(imgLock, chart, cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.RegistryFilter.Validate(imgLock.Images); err != nil {
+		return nil, err
+	}
+
+	return imgLock, nil
+}
+
+// GenerateFromImages creates a ImagesLock locking the given image references, not bound to any chart.
+// Images are named after their repository
+func GenerateFromImages(images []string, opts ...Option) (*ImagesLock, error) {
+	cfg := NewImagesLockConfig(opts...)
 
+	imgLock := NewImagesLock()
+	for _, image := range images {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image reference %q: %w", image, err)
+		}
+		imgLock.Images = append(imgLock.Images, &ChartImage{Name: path.Base(ref.Context().RepositoryStr()), Image: image})
+	}
+	imgLock.Images = imgLock.Images.Dedup()
+	if err := cfg.RegistryFilter.Validate(imgLock.Images); err != nil {
+		return nil, err
+	}
+	if cfg.UntaggedPolicy == UntaggedError {
+		if err := imgLock.Images.CheckUntaggedImages(); err != nil {
+			return nil, err
+		}
+	}
+	for _, img := range imgLock.Images {
+		if err := img.FetchDigests(cfg); err != nil {
+			return nil, fmt.Errorf("failed to fetch image %q digests: %w", img.Image, err)
+		}
+	}
 	return imgLock, nil
 }
 
+// checkAnnotatedImages returns an error if any image annotated in the chart or its dependencies uses a mutable
+// tag, if rejected, or does not specify a tag, if the untagged images policy rejects them
+func checkAnnotatedImages(c *chart.Chart, cfg *Config) error {
+	if !cfg.RejectMutableTags && cfg.UntaggedPolicy != UntaggedError {
+		return nil
+	}
+	images := make(ImageList, 0)
+	charts := []*chart.Chart{c}
+	for len(charts) > 0 {
+		current := charts[0]
+		charts = append(charts[1:], current.Dependencies()...)
+		chartImages, err := GetImagesFromChartAnnotations(current, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to process Helm chart %q images: %v", current.Name(), err)
+		}
+		images = append(images, chartImages...)
+	}
+	if cfg.UntaggedPolicy == UntaggedError {
+		if err := images.CheckUntaggedImages(); err != nil {
+			return err
+		}
+	}
+	if cfg.RejectMutableTags {
+		return images.CheckMutableTags()
+	}
+	return nil
+}
+
 // populateImagesFromChart populates the ImagesLock with images and digests from the given chart and its dependencies.
 func populateImagesFromChart(imgLock *ImagesLock, chart *chart.Chart, cfg *Config) error {
 
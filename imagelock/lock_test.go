@@ -518,3 +518,27 @@ images:
 		})
 	})
 }
+
+func (suite *ImageLockTestSuite) TestGenerateFromImages() {
+	require := suite.Require()
+	assert := suite.Assert()
+
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(err)
+
+	img := &tu.ImageData{Name: "app1", Image: fmt.Sprintf("%s/bitnami/app1:1.0.0", u.Host)}
+	craneImg, err := tu.CreateSingleArchImage(img, "linux/amd64")
+	require.NoError(err)
+	require.NoError(crane.Push(craneImg, img.Image, crane.Insecure))
+
+	lock, err := GenerateFromImages([]string{img.Image, img.Image}, WithInsecure(true))
+	require.NoError(err)
+	expectedLock := createLockFromImageData(map[string][]*tu.ImageData{"": {img}})
+	assert.Equal(expectedLock.Images, lock.Images)
+
+	_, err = GenerateFromImages([]string{fmt.Sprintf("%s/bitnami/missing:1.0.0", u.Host)}, WithInsecure(true))
+	assert.ErrorContains(err, "failed to fetch image")
+}
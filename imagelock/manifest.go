@@ -0,0 +1,72 @@
+package imagelock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// containerListKeys are the pod spec fields listing containers
+var containerListKeys = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// GetImagesFromManifest returns the images of the containers defined in the Kubernetes manifest, a stream of
+// YAML documents such as the one rendered for a Helm release, in order of appearance and without duplicates
+func GetImagesFromManifest(manifest string) ([]string, error) {
+	images := make([]string, 0)
+	done := make(map[string]struct{})
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		for _, image := range findContainerImages(doc) {
+			if _, found := done[image]; found {
+				continue
+			}
+			done[image] = struct{}{}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// findContainerImages returns the images of the containers found at any depth of the YAML node, so pods
+// embedded in workloads, such as the pod templates of Deployments or CronJobs, are also inspected
+func findContainerImages(node interface{}) []string {
+	images := make([]string, 0)
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for _, key := range containerListKeys {
+			containers, _ := v[key].([]interface{})
+			for _, c := range containers {
+				container, _ := c.(map[string]interface{})
+				if image, ok := container["image"].(string); ok && image != "" {
+					images = append(images, image)
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		// Sorted, so the images are returned in a stable order
+		sort.Strings(keys)
+		for _, key := range keys {
+			images = append(images, findContainerImages(v[key])...)
+		}
+	case []interface{}:
+		for _, value := range v {
+			images = append(images, findContainerImages(value)...)
+		}
+	}
+	return images
+}
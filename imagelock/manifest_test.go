@@ -0,0 +1,63 @@
+package imagelock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImagesFromManifest(t *testing.T) {
+	manifest := `---
+# Source: wordpress/templates/secrets.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: wordpress
+---
+# Source: wordpress/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wordpress
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: prepare
+          image: docker.io/bitnami/os-shell:11-debian-11-r16
+      containers:
+        - name: wordpress
+          image: docker.io/bitnami/wordpress:6.2.2
+        - name: metrics
+          image: docker.io/bitnami/apache-exporter:0.13.4
+---
+# Source: wordpress/templates/backup.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: wordpress-backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: docker.io/bitnami/wordpress:6.2.2
+`
+	images, err := GetImagesFromManifest(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker.io/bitnami/wordpress:6.2.2",
+		"docker.io/bitnami/apache-exporter:0.13.4",
+		"docker.io/bitnami/os-shell:11-debian-11-r16",
+	}, images)
+
+	images, err = GetImagesFromManifest("")
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	_, err = GetImagesFromManifest("kind: [Deployment")
+	assert.ErrorContains(t, err, "failed to parse manifest")
+}
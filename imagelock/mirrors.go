@@ -0,0 +1,140 @@
+package imagelock
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Mirrors maps registries ("docker.io") to the mirrors images are read from instead. Mirrors can be
+// either registry hosts ("mirror.example.com") or repository prefixes ("harbor.example.com/dockerhub")
+type Mirrors map[string][]string
+
+// IsEmpty returns true if no mirrors are defined
+func (m Mirrors) IsEmpty() bool {
+	return len(m) == 0
+}
+
+// Add registers mirror as the next mirror of registry
+func (m Mirrors) Add(registry string, mirror string) {
+	registry = normalizeRegistryEntry(registry)
+	m[registry] = append(m[registry], strings.TrimSuffix(strings.TrimPrefix(mirror, "oci://"), "/"))
+}
+
+// References returns the references to try, in order, when reading ref: the ones in the mirrors of
+// its registry, followed by ref itself
+func (m Mirrors) References(ref name.Reference, opts ...name.Option) []name.Reference {
+	refs := make([]name.Reference, 0)
+	repository := ref.Context().RepositoryStr()
+	for _, mirror := range m[ref.Context().RegistryStr()] {
+		var mirrored name.Reference
+		var err error
+		switch r := ref.(type) {
+		case name.Digest:
+			mirrored, err = name.NewDigest(fmt.Sprintf("%s/%s@%s", mirror, repository, r.DigestStr()), opts...)
+		default:
+			mirrored, err = name.NewTag(fmt.Sprintf("%s/%s:%s", mirror, repository, ref.Identifier()), opts...)
+		}
+		if err == nil {
+			refs = append(refs, mirrored)
+		}
+	}
+	return append(refs, ref)
+}
+
+// Fetch calls fn with the references to read ref from, in the mirrors of its registry first and from its
+// registry last, until one succeeds. It returns the errors of all the attempts if all of them fail
+func Fetch[T any](m Mirrors, ref name.Reference, fn func(name.Reference) (T, error), opts ...name.Option) (T, error) {
+	var allErrors error
+	var res T
+	for _, r := range m.References(ref, opts...) {
+		var err error
+		res, err = fn(r)
+		if err == nil {
+			return res, nil
+		}
+		allErrors = errors.Join(allErrors, err)
+	}
+	return res, allErrors
+}
+
+// containerdHostsFile defines the subset of the containerd hosts.toml format used to configure mirrors
+type containerdHostsFile struct {
+	Hosts map[string]struct {
+		Capabilities []string `toml:"capabilities"`
+		OverridePath bool     `toml:"override_path"`
+	} `toml:"host"`
+}
+
+// ReadMirrorsFromHostsDir reads the registries mirrors from a containerd hosts directory, with a
+// <registry>/hosts.toml file per registry (usually /etc/containerd/certs.d)
+func ReadMirrorsFromHostsDir(dir string) (Mirrors, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "hosts.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts directory: %w", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read hosts directory: %w", err)
+	}
+	mirrors := make(Mirrors)
+	for _, file := range files {
+		registry := filepath.Base(filepath.Dir(file))
+		if registry == "_default" {
+			continue
+		}
+		hosts, err := readContainerdHostsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			mirrors.Add(registry, host)
+		}
+	}
+	return mirrors, nil
+}
+
+// readContainerdHostsFile returns the hosts in file that can be pulled from, in order
+func readContainerdHostsFile(file string) ([]string, error) {
+	var hostsFile containerdHostsFile
+	md, err := toml.DecodeFile(file, &hostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", file, err)
+	}
+	hosts := make([]string, 0)
+	// The order of the keys in the file defines the order the mirrors are tried in
+	for _, key := range md.Keys() {
+		if len(key) != 2 || key[0] != "host" {
+			continue
+		}
+		h := hostsFile.Hosts[key[1]]
+		if len(h.Capabilities) > 0 && !contains(h.Capabilities, "pull") {
+			continue
+		}
+		u, err := url.Parse(key[1])
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid host %q in %q", key[1], file)
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		if h.OverridePath {
+			// The path includes the API prefix, as in https://harbor.example.com/v2/dockerhub
+			path = strings.TrimPrefix(path, "/v2")
+		}
+		hosts = append(hosts, u.Host+path)
+	}
+	return hosts, nil
+}
+
+func contains(list []string, item string) bool {
+	for _, e := range list {
+		if e == item {
+			return true
+		}
+	}
+	return false
+}
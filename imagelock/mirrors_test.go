@@ -0,0 +1,99 @@
+package imagelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrors(t *testing.T) {
+	mirrors := make(Mirrors)
+	mirrors.Add("docker.io", "mirror.example.com")
+	mirrors.Add("docker.io", "oci://harbor.example.com/dockerhub/")
+
+	references := func(image string) []string {
+		ref, err := name.ParseReference(image)
+		require.NoError(t, err)
+		refs := make([]string, 0)
+		for _, r := range mirrors.References(ref) {
+			refs = append(refs, r.String())
+		}
+		return refs
+	}
+
+	t.Run("Mirrors tags", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"mirror.example.com/bitnami/wordpress:6.2.2",
+			"harbor.example.com/dockerhub/bitnami/wordpress:6.2.2",
+			"bitnami/wordpress:6.2.2",
+		}, references("bitnami/wordpress:6.2.2"))
+	})
+	t.Run("Mirrors digests", func(t *testing.T) {
+		const dgst = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		assert.Equal(t, []string{
+			"mirror.example.com/library/nginx@" + dgst,
+			"harbor.example.com/dockerhub/library/nginx@" + dgst,
+			"nginx@" + dgst,
+		}, references("nginx@"+dgst))
+	})
+	t.Run("Ignores other registries", func(t *testing.T) {
+		assert.Equal(t, []string{"quay.io/bitnami/redis:7.0"}, references("quay.io/bitnami/redis:7.0"))
+	})
+	t.Run("Fetches from the first mirror that works", func(t *testing.T) {
+		ref, err := name.ParseReference("bitnami/wordpress:6.2.2")
+		require.NoError(t, err)
+		tried := make([]string, 0)
+		res, err := Fetch(mirrors, ref, func(r name.Reference) (string, error) {
+			tried = append(tried, r.Context().RegistryStr())
+			if r.Context().RegistryStr() == "mirror.example.com" {
+				return "", assert.AnError
+			}
+			return r.String(), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "harbor.example.com/dockerhub/bitnami/wordpress:6.2.2", res)
+		assert.Equal(t, []string{"mirror.example.com", "harbor.example.com"}, tried)
+	})
+}
+
+func TestReadMirrorsFromHostsDir(t *testing.T) {
+	dir := t.TempDir()
+	writeHosts := func(registry string, data string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, registry), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, registry, "hosts.toml"), []byte(data), 0644))
+	}
+	writeHosts("docker.io", `
+server = "https://registry-1.docker.io"
+
+[host."https://mirror.example.com"]
+  capabilities = ["pull", "resolve"]
+
+[host."https://push.example.com"]
+  capabilities = ["push"]
+
+[host."https://harbor.example.com/v2/dockerhub"]
+  capabilities = ["pull", "resolve"]
+  override_path = true
+`)
+	writeHosts("quay.io", `
+[host."https://quay-mirror.example.com:5000"]
+`)
+
+	mirrors, err := ReadMirrorsFromHostsDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, Mirrors{
+		"index.docker.io": {"mirror.example.com", "harbor.example.com/dockerhub"},
+		"quay.io":         {"quay-mirror.example.com:5000"},
+	}, mirrors)
+
+	_, err = ReadMirrorsFromHostsDir(filepath.Join(dir, "missing"))
+	assert.ErrorContains(t, err, "failed to read hosts directory")
+
+	writeHosts("ghcr.io", "[host.")
+	_, err = ReadMirrorsFromHostsDir(dir)
+	assert.ErrorContains(t, err, "failed to parse")
+}
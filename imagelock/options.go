@@ -1,6 +1,11 @@
 package imagelock
 
-import "context"
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
 
 // Config defines configuration options for ImageLock functions
 type Config struct {
@@ -8,6 +13,22 @@ type Config struct {
 	AnnotationsKey string
 	Context        context.Context
 	Platforms      []string
+	RegistryFilter *RegistryFilter
+	// RejectMutableTags makes lock creation fail for images using mutable tags
+	RejectMutableTags bool
+	// UntaggedPolicy defines how images specifying neither a tag nor a digest are handled. Only UntaggedError
+	// changes how locks are created, rejecting them
+	UntaggedPolicy UntaggedPolicy
+	// Keychain resolves the credentials used to access the registries
+	Keychain authn.Keychain
+	// Transport, if not nil, is the HTTP transport used to access the registries
+	Transport http.RoundTripper
+	// Mirrors lists the mirrors images digests are read from before trying their registries
+	Mirrors Mirrors
+	// LocalDaemon reads the digests of the images missing from their registries from the local Docker daemon
+	LocalDaemon bool
+	// DaemonHost, if not empty, is the address of the local daemon, instead of the DOCKER_HOST one
+	DaemonHost string
 }
 
 // NewImagesLockConfig returns a new ImageLockConfig with default values
@@ -16,6 +37,8 @@ func NewImagesLockConfig(opts ...Option) *Config {
 		AnnotationsKey: DefaultAnnotationsKey,
 		Context:        context.Background(),
 		Platforms:      make([]string, 0),
+		Keychain:       authn.DefaultKeychain,
+		UntaggedPolicy: UntaggedAssumeLatest,
 	}
 
 	for _, opt := range opts {
@@ -46,6 +69,27 @@ func WithInsecure(insecure bool) func(ic *Config) {
 	}
 }
 
+// WithKeychain provides the keychain used to authenticate against the registries
+func WithKeychain(kc authn.Keychain) func(ic *Config) {
+	return func(ic *Config) {
+		ic.Keychain = kc
+	}
+}
+
+// WithTransport provides the HTTP transport used to access the registries
+func WithTransport(tr http.RoundTripper) func(ic *Config) {
+	return func(ic *Config) {
+		ic.Transport = tr
+	}
+}
+
+// WithMirrors provides the registries mirrors images digests are read from
+func WithMirrors(m Mirrors) func(ic *Config) {
+	return func(ic *Config) {
+		ic.Mirrors = m
+	}
+}
+
 // WithContext provides an execution context
 func WithContext(ctx context.Context) func(ic *Config) {
 	return func(ic *Config) {
@@ -60,3 +104,38 @@ func WithAnnotationsKey(str string) func(ic *Config) {
 		ic.AnnotationsKey = str
 	}
 }
+
+// WithRegistryFilter restricts the registries images can be sourced from
+func WithRegistryFilter(f *RegistryFilter) func(ic *Config) {
+	return func(ic *Config) {
+		ic.RegistryFilter = f
+	}
+}
+
+// WithLocalDaemon reads the digests of the images missing from their registries from the local Docker daemon
+func WithLocalDaemon(enabled bool) func(ic *Config) {
+	return func(ic *Config) {
+		ic.LocalDaemon = enabled
+	}
+}
+
+// WithDaemonHost reads the local daemon images from the daemon listening on host, such as the podman API socket
+func WithDaemonHost(host string) func(ic *Config) {
+	return func(ic *Config) {
+		ic.DaemonHost = host
+	}
+}
+
+// WithRejectMutableTags makes lock creation fail if any image uses a mutable tag
+func WithRejectMutableTags(reject bool) func(ic *Config) {
+	return func(ic *Config) {
+		ic.RejectMutableTags = reject
+	}
+}
+
+// WithUntaggedPolicy configures how images specifying neither a tag nor a digest are handled
+func WithUntaggedPolicy(policy UntaggedPolicy) func(ic *Config) {
+	return func(ic *Config) {
+		ic.UntaggedPolicy = policy
+	}
+}
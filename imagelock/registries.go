@@ -0,0 +1,82 @@
+package imagelock
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// RegistryFilter restricts the registries images can be sourced from. Entries can be
+// either registry hosts ("docker.io") or repository prefixes ("docker.io/bitnami")
+type RegistryFilter struct {
+	// Allowed, if not empty, lists the only registries images can come from
+	Allowed []string
+	// Blocked lists registries images cannot come from
+	Blocked []string
+}
+
+// IsEmpty returns true if the filter does not impose any restriction
+func (f *RegistryFilter) IsEmpty() bool {
+	return f == nil || (len(f.Allowed) == 0 && len(f.Blocked) == 0)
+}
+
+// normalizeRegistryEntry converts entry to the repository form used by go-containerregistry
+// so "docker.io/bitnami" matches "index.docker.io/bitnami/wordpress"
+func normalizeRegistryEntry(entry string) string {
+	entry = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(entry), "oci://"), "/")
+	host, rest, _ := strings.Cut(entry, "/")
+	if host == "docker.io" {
+		host = name.DefaultRegistry
+	}
+	if rest == "" {
+		return host
+	}
+	return host + "/" + rest
+}
+
+func matchesRegistry(repository string, entries []string) bool {
+	for _, e := range entries {
+		e = normalizeRegistryEntry(e)
+		if repository == e || strings.HasPrefix(repository, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if image is not allowed by the filter
+func (f *RegistryFilter) Check(image string) error {
+	if f.IsEmpty() {
+		return nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return fmt.Errorf("failed to parse image reference %q: %w", image, err)
+	}
+	repository := ref.Context().Name()
+	if matchesRegistry(repository, f.Blocked) {
+		return fmt.Errorf("registry is blocked")
+	}
+	if len(f.Allowed) > 0 && !matchesRegistry(repository, f.Allowed) {
+		return fmt.Errorf("registry is not in the allowed list")
+	}
+	return nil
+}
+
+// Validate returns an error listing all the images not allowed by the filter
+func (f *RegistryFilter) Validate(images ImageList) error {
+	if f.IsEmpty() {
+		return nil
+	}
+	offending := make([]string, 0)
+	for _, img := range images {
+		if err := f.Check(img.Image); err != nil {
+			offending = append(offending, fmt.Sprintf("%s (%v)", img.Image, err))
+		}
+	}
+	if len(offending) > 0 {
+		return fmt.Errorf("found images from disallowed registries: %s", strings.Join(offending, ", "))
+	}
+	return nil
+}
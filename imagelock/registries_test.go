@@ -0,0 +1,44 @@
+package imagelock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryFilter(t *testing.T) {
+	images := ImageList{
+		{Name: "wordpress", Image: "docker.io/bitnami/wordpress:6.2.2"},
+		{Name: "nginx", Image: "nginx:1.25"},
+		{Name: "redis", Image: "quay.io/bitnami/redis:7.0"},
+	}
+
+	t.Run("Empty filter allows everything", func(t *testing.T) {
+		assert.NoError(t, (&RegistryFilter{}).Validate(images))
+		var f *RegistryFilter
+		assert.NoError(t, f.Validate(images))
+	})
+	t.Run("Allowed registries", func(t *testing.T) {
+		f := &RegistryFilter{Allowed: []string{"docker.io"}}
+		assert.EqualError(t, f.Validate(images),
+			"found images from disallowed registries: quay.io/bitnami/redis:7.0 (registry is not in the allowed list)")
+	})
+	t.Run("Allowed repository prefixes", func(t *testing.T) {
+		f := &RegistryFilter{Allowed: []string{"docker.io/bitnami", "quay.io"}}
+		assert.EqualError(t, f.Validate(images),
+			"found images from disallowed registries: nginx:1.25 (registry is not in the allowed list)")
+	})
+	t.Run("Blocked registries", func(t *testing.T) {
+		f := &RegistryFilter{Blocked: []string{"index.docker.io/library"}}
+		assert.EqualError(t, f.Validate(images),
+			"found images from disallowed registries: nginx:1.25 (registry is blocked)")
+	})
+	t.Run("Blocked takes precedence", func(t *testing.T) {
+		f := &RegistryFilter{Allowed: []string{"quay.io"}, Blocked: []string{"quay.io/bitnami/"}}
+		assert.ErrorContains(t, f.Check("quay.io/bitnami/redis:7.0"), "registry is blocked")
+	})
+	t.Run("Prefixes match full path components", func(t *testing.T) {
+		f := &RegistryFilter{Allowed: []string{"docker.io/bitnami"}}
+		assert.Error(t, f.Check("docker.io/bitnamicharts/wordpress:1.0"))
+	})
+}
@@ -0,0 +1,115 @@
+package imagelock
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// IsMutableImage returns true if the image reference is not pinned to a digest and
+// uses the "latest" tag, either explicitly or by not specifying any tag
+func IsMutableImage(image string) bool {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+	tag, ok := ref.(name.Tag)
+	return ok && tag.TagStr() == name.DefaultTag
+}
+
+// MutableImages returns the images in the list using mutable tags
+func (imgs ImageList) MutableImages() ImageList {
+	mutable := make(ImageList, 0)
+	for _, img := range imgs {
+		if IsMutableImage(img.Image) {
+			mutable = append(mutable, img)
+		}
+	}
+	return mutable
+}
+
+// describeImages returns the comma-separated list of the images references, with their charts
+func describeImages(imgs ImageList) string {
+	refs := make([]string, 0, len(imgs))
+	for _, img := range imgs {
+		refs = append(refs, fmt.Sprintf("%s (%s)", img.Image, img.Chart))
+	}
+	return strings.Join(refs, ", ")
+}
+
+// CheckMutableTags returns an error listing the images using mutable tags
+func (imgs ImageList) CheckMutableTags() error {
+	mutable := imgs.MutableImages()
+	if len(mutable) == 0 {
+		return nil
+	}
+	return fmt.Errorf("found images using mutable tags: %s", describeImages(mutable))
+}
+
+// UntaggedPolicy defines how image references specifying neither a tag nor a digest are handled
+type UntaggedPolicy string
+
+const (
+	// UntaggedAssumeLatest handles untagged images as using the "latest" tag, warning about them
+	UntaggedAssumeLatest UntaggedPolicy = "assume-latest"
+	// UntaggedError rejects untagged images
+	UntaggedError UntaggedPolicy = "error"
+	// UntaggedResolveDigest pins untagged images to the digest currently published for their "latest" tag
+	UntaggedResolveDigest UntaggedPolicy = "resolve-digest"
+)
+
+// UntaggedPolicies lists the supported untagged images policies
+var UntaggedPolicies = []UntaggedPolicy{UntaggedAssumeLatest, UntaggedError, UntaggedResolveDigest}
+
+// ParseUntaggedPolicy returns the UntaggedPolicy named s
+func ParseUntaggedPolicy(s string) (UntaggedPolicy, error) {
+	for _, policy := range UntaggedPolicies {
+		if string(policy) == s {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown untagged images policy %q: use one of %v", s, UntaggedPolicies)
+}
+
+// IsUntaggedImage returns true if the image reference specifies neither a tag nor a digest
+func IsUntaggedImage(image string) bool {
+	if _, err := name.ParseReference(image); err != nil || strings.Contains(image, "@") {
+		return false
+	}
+	// The registry may include a port, so only the last path component can include the tag
+	return !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":")
+}
+
+// UntaggedImages returns the images in the list specifying neither a tag nor a digest
+func (imgs ImageList) UntaggedImages() ImageList {
+	untagged := make(ImageList, 0)
+	for _, img := range imgs {
+		if IsUntaggedImage(img.Image) {
+			untagged = append(untagged, img)
+		}
+	}
+	return untagged
+}
+
+// CheckUntaggedImages returns an error listing the images specifying neither a tag nor a digest
+func (imgs ImageList) CheckUntaggedImages() error {
+	untagged := imgs.UntaggedImages()
+	if len(untagged) == 0 {
+		return nil
+	}
+	return fmt.Errorf("found images without a tag or digest: %s", describeImages(untagged))
+}
+
+// PinImage returns the image reference pinned to the digest currently published in the registry
+func PinImage(image string, opts ...Option) (string, error) {
+	cfg := NewImagesLockConfig(opts...)
+	desc, err := getRemoteDescriptor(image, cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to get descriptor: %v", err)
+	}
+	if strings.Contains(image, "@") {
+		image = strings.SplitN(image, "@", 2)[0]
+	}
+	return fmt.Sprintf("%s@%s", image, desc.Digest), nil
+}
@@ -0,0 +1,89 @@
+package imagelock
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMutableImage(t *testing.T) {
+	for image, expected := range map[string]bool{
+		"bitnami/wordpress":                            true,
+		"bitnami/wordpress:latest":                     true,
+		"localhost:5000/bitnami/wordpress":             true,
+		"bitnami/wordpress:6.2.2":                      false,
+		"bitnami/wordpress:latest@sha256:" + sha256Hex: false,
+		"bitnami/wordpress@sha256:" + sha256Hex:        false,
+	} {
+		assert.Equal(t, expected, IsMutableImage(image), image)
+	}
+}
+
+const sha256Hex = "a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f"
+
+func TestCheckMutableTags(t *testing.T) {
+	images := ImageList{
+		{Name: "wordpress", Chart: "wordpress", Image: "bitnami/wordpress:6.2.2"},
+		{Name: "shell", Chart: "wordpress", Image: "bitnami/os-shell"},
+	}
+	assert.EqualError(t, images.CheckMutableTags(), "found images using mutable tags: bitnami/os-shell (wordpress)")
+	assert.NoError(t, images[:1].CheckMutableTags())
+}
+
+func TestPinImage(t *testing.T) {
+	silentLog := log.New(io.Discard, "", 0)
+	s := httptest.NewServer(registry.New(registry.Logger(silentLog)))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	img, err := crane.Image(map[string][]byte{"file.txt": []byte("data")})
+	require.NoError(t, err)
+	image := fmt.Sprintf("%s/bitnami/app1:latest", u.Host)
+	require.NoError(t, crane.Push(img, image, crane.Insecure))
+	dgst, err := img.Digest()
+	require.NoError(t, err)
+
+	pinned, err := PinImage(image, Insecure)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%s@%s", image, dgst), pinned)
+	assert.False(t, IsMutableImage(pinned))
+
+	_, err = PinImage(fmt.Sprintf("%s/bitnami/missing", u.Host), Insecure)
+	assert.ErrorContains(t, err, "failed to get descriptor")
+}
+
+func TestUntaggedImages(t *testing.T) {
+	for image, expected := range map[string]bool{
+		"bitnami/wordpress":                     true,
+		"localhost:5000/bitnami/wordpress":      true,
+		"bitnami/wordpress:latest":              false,
+		"localhost:5000/bitnami/wordpress:6.2":  false,
+		"bitnami/wordpress@sha256:" + sha256Hex: false,
+		"Invalid/Reference":                     false,
+	} {
+		assert.Equal(t, expected, IsUntaggedImage(image), image)
+	}
+	images := ImageList{
+		{Name: "wordpress", Chart: "wordpress", Image: "bitnami/wordpress:latest"},
+		{Name: "shell", Chart: "wordpress", Image: "bitnami/os-shell"},
+	}
+	assert.EqualError(t, images.CheckUntaggedImages(), "found images without a tag or digest: bitnami/os-shell (wordpress)")
+	assert.NoError(t, images[:1].CheckUntaggedImages())
+
+	for _, policy := range UntaggedPolicies {
+		parsed, err := ParseUntaggedPolicy(string(policy))
+		require.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+	_, err := ParseUntaggedPolicy("latest")
+	assert.ErrorContains(t, err, "unknown untagged images policy")
+}
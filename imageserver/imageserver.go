@@ -0,0 +1,370 @@
+// Package imageserver implements a read-only OCI distribution API serving the images of an Images.lock
+package imageserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// manifest defines a raw manifest, either an image index or an image manifest
+type manifest struct {
+	data      []byte
+	mediaType string
+}
+
+// blob defines a config or layer blob, read on demand
+type blob struct {
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// repository holds the content served for a repository
+type repository struct {
+	tags      map[string]v1.Hash
+	manifests map[v1.Hash]manifest
+	blobs     map[v1.Hash]blob
+}
+
+func newRepository() *repository {
+	return &repository{tags: make(map[string]v1.Hash), manifests: make(map[v1.Hash]manifest), blobs: make(map[v1.Hash]blob)}
+}
+
+// Served describes where an image of the Images.lock is served
+type Served struct {
+	// Image is the original image reference
+	Image string
+	// Repository is the repository the image is served from
+	Repository string
+	// Tag is the tag the image is served with, empty for images referenced by digest
+	Tag string
+	// Digest is the digest of the image index served
+	Digest v1.Hash
+}
+
+// Server serves the images of an Images.lock, from their tarballs, through the OCI distribution API. The
+// images are served under their original repository path, without their registry, with the image index
+// pushed by "dt images push"
+type Server struct {
+	// mu protects repos, which the pull-through cache updates while serving
+	mu     sync.RWMutex
+	repos  map[string]*repository
+	served []Served
+	// proxy, if not nil, fetches the images the Server does not hold from their upstream registries
+	proxy *proxy
+}
+
+// NewServer returns a Server serving the images in lock, loaded from their tarballs in imagesDir. With the
+// pull-through cache enabled, the images not pulled into imagesDir are fetched on demand instead
+func NewServer(lock *imagelock.ImagesLock, imagesDir string, opts ...Option) (*Server, error) {
+	cfg := NewConfig(opts...)
+	s := &Server{repos: make(map[string]*repository), served: make([]Served, 0, len(lock.Images))}
+	if cfg.CacheDir != "" {
+		p, err := newProxy(lock, cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.proxy = p
+		if err := s.loadCache(); err != nil {
+			return nil, err
+		}
+	}
+	for _, img := range lock.Images {
+		if err := s.addImage(img, imagesDir); err != nil && s.proxy == nil {
+			return nil, fmt.Errorf("failed to serve image %q: %w", img.Image, err)
+		}
+	}
+	return s, nil
+}
+
+// repository returns the repository named repoName, creating it if needed. Callers must hold the lock
+func (s *Server) repository(repoName string) *repository {
+	repo, ok := s.repos[repoName]
+	if !ok {
+		repo = newRepository()
+		s.repos[repoName] = repo
+	}
+	return repo
+}
+
+// lookupManifest returns the manifest referenced by reference, a tag or a digest, in the repository
+func (s *Server) lookupManifest(repoName string, reference string) (v1.Hash, manifest, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	repo, ok := s.repos[repoName]
+	if !ok {
+		return v1.Hash{}, manifest{}, false
+	}
+	h, ok := repo.tags[reference]
+	if !ok {
+		var err error
+		if h, err = v1.NewHash(reference); err != nil {
+			return v1.Hash{}, manifest{}, false
+		}
+	}
+	m, ok := repo.manifests[h]
+	return h, m, ok
+}
+
+// lookupBlob returns the blob with digest h in the repository
+func (s *Server) lookupBlob(repoName string, h v1.Hash) (blob, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	repo, ok := s.repos[repoName]
+	if !ok {
+		return blob{}, false
+	}
+	b, ok := repo.blobs[h]
+	return b, ok
+}
+
+// Images returns where each of the images is served
+func (s *Server) Images() []Served {
+	return s.served
+}
+
+func (s *Server) addImage(img *imagelock.ChartImage, imagesDir string) error {
+	ref, err := name.ParseReference(img.Image)
+	if err != nil {
+		return fmt.Errorf("failed to parse image reference: %w", err)
+	}
+	idx, err := chartutils.BuildImageIndex(img, imagesDir)
+	if err != nil {
+		return fmt.Errorf("failed to build image index: %w", err)
+	}
+	repoName := ref.Context().RepositoryStr()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(repoName)
+	h, err := addIndex(repo, idx)
+	if err != nil {
+		return err
+	}
+	served := Served{Image: img.Image, Repository: repoName, Digest: h}
+	if tag, ok := ref.(name.Tag); ok {
+		repo.tags[tag.TagStr()] = h
+		served.Tag = tag.TagStr()
+	}
+	s.served = append(s.served, served)
+	return nil
+}
+
+// addIndex adds the image index, and its images, to the repository, returning its digest
+func addIndex(repo *repository, idx v1.ImageIndex) (v1.Hash, error) {
+	h, err := idx.Digest()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("failed to get image index digest: %w", err)
+	}
+	if err := addManifest(repo, idx); err != nil {
+		return v1.Hash{}, err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("failed to read image index: %w", err)
+	}
+	for _, desc := range im.Manifests {
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("failed to read image %s: %w", desc.Digest, err)
+		}
+		if err := addImage(repo, img); err != nil {
+			return v1.Hash{}, err
+		}
+	}
+	return h, nil
+}
+
+// rawManifest defines the artifacts with a manifest, image indexes and images
+type rawManifest interface {
+	Digest() (v1.Hash, error)
+	RawManifest() ([]byte, error)
+}
+
+func addManifest(repo *repository, m rawManifest) error {
+	h, err := m.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to get manifest digest: %w", err)
+	}
+	data, err := m.RawManifest()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	// Both images and indexes define their media type
+	var mt struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(data, &mt); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	repo.manifests[h] = manifest{data: data, mediaType: mt.MediaType}
+	return nil
+}
+
+// addImage adds the image manifest, config and layers to the repository
+func addImage(repo *repository, img v1.Image) error {
+	if err := addManifest(repo, img); err != nil {
+		return err
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return fmt.Errorf("failed to get image config digest: %w", err)
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to read image config: %w", err)
+	}
+	repo.blobs[cfgName] = blob{size: int64(len(cfg)), open: func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(cfg)), nil
+	}}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("failed to read image layers: %w", err)
+	}
+	for _, l := range layers {
+		h, err := l.Digest()
+		if err != nil {
+			return fmt.Errorf("failed to get layer digest: %w", err)
+		}
+		size, err := l.Size()
+		if err != nil {
+			return fmt.Errorf("failed to get layer size: %w", err)
+		}
+		repo.blobs[h] = blob{size: size, open: l.Compressed}
+	}
+	return nil
+}
+
+// writeError writes an error of the OCI distribution API
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
+
+// ServeHTTP implements the read-only subset of the OCI distribution API used to pull images
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case r.URL.Path == "/v2/" || r.URL.Path == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{}")
+	case path == "_catalog":
+		s.serveCatalog(w)
+	case strings.HasSuffix(path, "/tags/list"):
+		s.serveTags(w, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		i := strings.LastIndex(path, "/manifests/")
+		s.serveManifest(w, r, path[:i], path[i+len("/manifests/"):])
+	case strings.Contains(path, "/blobs/"):
+		i := strings.LastIndex(path, "/blobs/")
+		s.serveBlob(w, r, path[:i], path[i+len("/blobs/"):])
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("unknown path %q", r.URL.Path))
+	}
+}
+
+func (s *Server) serveCatalog(w http.ResponseWriter) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	repos := make([]string, 0, len(s.repos))
+	for repoName := range s.repos {
+		repos = append(repos, repoName)
+	}
+	sort.Strings(repos)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"repositories": repos})
+}
+
+func (s *Server) serveTags(w http.ResponseWriter, repoName string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	repo, ok := s.repos[repoName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", fmt.Sprintf("unknown repository %q", repoName))
+		return
+	}
+	tags := make([]string, 0, len(repo.tags))
+	for tag := range repo.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repoName, "tags": tags})
+}
+
+func (s *Server) serveManifest(w http.ResponseWriter, r *http.Request, repoName string, reference string) {
+	h, m, ok := s.lookupManifest(repoName, reference)
+	if !ok && s.proxy != nil {
+		if err := s.fetch(r.Context(), repoName, reference); err != nil {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("failed to fetch %q from upstream: %v", reference, err))
+			return
+		}
+		h, m, ok = s.lookupManifest(repoName, reference)
+	}
+	if !ok {
+		s.writeManifestUnknown(w, repoName, reference)
+		return
+	}
+	w.Header().Set("Content-Type", m.mediaType)
+	w.Header().Set("Docker-Content-Digest", h.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(m.data)))
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(m.data)
+	}
+}
+
+// writeManifestUnknown writes the error for a manifest missing from the repository, or for an unknown repository
+func (s *Server) writeManifestUnknown(w http.ResponseWriter, repoName string, reference string) {
+	s.mu.RLock()
+	_, ok := s.repos[repoName]
+	s.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", fmt.Sprintf("unknown repository %q", repoName))
+		return
+	}
+	writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("unknown manifest %q in repository %q", reference, repoName))
+}
+
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, repoName string, digest string) {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("invalid digest %q", digest))
+		return
+	}
+	b, ok := s.lookupBlob(repoName, h)
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("unknown blob %q in repository %q", digest, repoName))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", h.String())
+	w.Header().Set("Content-Length", strconv.FormatInt(b.size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	rc, err := b.open()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	defer rc.Close()
+	_, _ = io.Copy(w, rc)
+}
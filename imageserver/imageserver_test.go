@@ -0,0 +1,227 @@
+package imageserver
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+// writeSampleLock writes the tarballs of a multi-platform image into imagesDir, returning its Images.lock
+func writeSampleLock(t *testing.T, image string, imagesDir string) *imagelock.ImagesLock {
+	imageData := tu.ImageData{Name: "app", Image: image}
+	imgs, err := tu.CreateSampleImages(&imageData, []string{"linux/amd64", "linux/arm64"})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(imagesDir, 0755))
+	chartImage := &imagelock.ChartImage{Name: imageData.Name, Image: image, Chart: "test"}
+	for i, img := range imgs {
+		d := imageData.Digests[i]
+		require.NoError(t, crane.Save(img, image, filepath.Join(imagesDir, fmt.Sprintf("%s.tar", d.Digest.Encoded()))))
+		chartImage.Digests = append(chartImage.Digests, imagelock.DigestInfo{Arch: d.Arch, Digest: d.Digest})
+	}
+	lock := imagelock.NewImagesLock()
+	lock.Images = append(lock.Images, chartImage)
+	return lock
+}
+
+func TestServer(t *testing.T) {
+	imagesDir := filepath.Join(t.TempDir(), "images")
+	lock := writeSampleLock(t, "registry.example.com/bitnami/app:1.0.0", imagesDir)
+
+	s, err := NewServer(lock, imagesDir)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	ref := fmt.Sprintf("%s/bitnami/app:1.0.0", u.Host)
+
+	indexDigest, err := chartutils.ImageIndexDigest(lock.Images[0], imagesDir)
+	require.NoError(t, err)
+	assert.Equal(t, []Served{{Image: lock.Images[0].Image, Repository: "bitnami/app", Tag: "1.0.0", Digest: v1.Hash{Algorithm: "sha256", Hex: indexDigest.Encoded()}}}, s.Images())
+
+	t.Run("Serves the image index", func(t *testing.T) {
+		dgst, err := crane.Digest(ref)
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest.String(), dgst)
+
+		tags, err := crane.ListTags(fmt.Sprintf("%s/bitnami/app", u.Host))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1.0.0"}, tags)
+
+		repos, err := crane.Catalog(u.Host)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bitnami/app"}, repos)
+	})
+	t.Run("Serves the platform images", func(t *testing.T) {
+		for _, d := range lock.Images[0].Digests {
+			platform, err := v1.ParsePlatform(d.Arch)
+			require.NoError(t, err)
+			img, err := crane.Pull(ref, crane.WithPlatform(platform))
+			require.NoError(t, err)
+			h, err := img.Digest()
+			require.NoError(t, err)
+			assert.Equal(t, d.Digest.String(), h.String())
+			// Reads all the blobs, validating their digests
+			require.NoError(t, crane.Save(img, ref, filepath.Join(t.TempDir(), "image.tar")))
+		}
+	})
+	t.Run("Rejects unknown images", func(t *testing.T) {
+		_, err := crane.Digest(fmt.Sprintf("%s/bitnami/app:2.0.0", u.Host))
+		assert.ErrorContains(t, err, "MANIFEST_UNKNOWN")
+		_, err = crane.Digest(fmt.Sprintf("%s/bitnami/other:1.0.0", u.Host))
+		assert.ErrorContains(t, err, "NAME_UNKNOWN")
+	})
+	t.Run("Is read-only", func(t *testing.T) {
+		img, err := crane.Image(map[string][]byte{"file.txt": []byte("data")})
+		require.NoError(t, err)
+		assert.ErrorContains(t, crane.Push(img, ref), "UNSUPPORTED")
+	})
+	t.Run("Fails if the images were not pulled", func(t *testing.T) {
+		_, err := NewServer(lock, t.TempDir())
+		assert.ErrorContains(t, err, "failed to serve image")
+	})
+}
+
+func TestServerPullThroughCache(t *testing.T) {
+	upstream := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	images, err := tu.AddSampleImagesToRegistry("bitnami/app:1.0.0", upstreamURL.Host)
+	require.NoError(t, err)
+	upstreamDigest, err := crane.Digest(fmt.Sprintf("%s/bitnami/app:1.0.0", upstreamURL.Host))
+	require.NoError(t, err)
+
+	cacheDir := t.TempDir()
+	// The images were not pulled, they are fetched on demand
+	lock := imagelock.NewImagesLock()
+	chartImage := &imagelock.ChartImage{Name: "app", Image: fmt.Sprintf("%s/%s", upstreamURL.Host, images[0].Image)}
+	for _, d := range images[0].Digests {
+		chartImage.Digests = append(chartImage.Digests, imagelock.DigestInfo{Arch: d.Arch, Digest: d.Digest})
+	}
+	lock.Images = append(lock.Images, chartImage)
+	serve := func(opts ...Option) string {
+		s, err := NewServer(lock, t.TempDir(), append([]Option{WithCacheDir(cacheDir)}, opts...)...)
+		require.NoError(t, err)
+		ts := httptest.NewServer(s)
+		t.Cleanup(ts.Close)
+		u, err := url.Parse(ts.URL)
+		require.NoError(t, err)
+		return u.Host
+	}
+
+	host := serve()
+	t.Run("Fetches the images from upstream", func(t *testing.T) {
+		dgst, err := crane.Digest(fmt.Sprintf("%s/bitnami/app:1.0.0", host))
+		require.NoError(t, err)
+		assert.Equal(t, upstreamDigest, dgst)
+		img, err := crane.Pull(fmt.Sprintf("%s/bitnami/app@%s", host, images[0].Digests[0].Digest))
+		require.NoError(t, err)
+		require.NoError(t, crane.Save(img, "app", filepath.Join(t.TempDir(), "image.tar")))
+	})
+	t.Run("Rejects repositories without upstream registry", func(t *testing.T) {
+		_, err := crane.Digest(fmt.Sprintf("%s/bitnami/other:1.0.0", host))
+		assert.ErrorContains(t, err, "no upstream registry")
+	})
+
+	upstream.Close()
+	host = serve(WithDefaultRegistry(upstreamURL.Host))
+	t.Run("Serves the cached images", func(t *testing.T) {
+		dgst, err := crane.Digest(fmt.Sprintf("%s/bitnami/app:1.0.0", host))
+		require.NoError(t, err)
+		assert.Equal(t, upstreamDigest, dgst)
+		img, err := crane.Pull(fmt.Sprintf("%s/bitnami/app:1.0.0", host))
+		require.NoError(t, err)
+		require.NoError(t, crane.Save(img, "app", filepath.Join(t.TempDir(), "image.tar")))
+	})
+	t.Run("Fails to fetch new images if upstream is unreachable", func(t *testing.T) {
+		_, err := crane.Digest(fmt.Sprintf("%s/bitnami/app:2.0.0", host))
+		assert.ErrorContains(t, err, "failed to fetch")
+	})
+}
+
+func TestServerPullThroughCacheConcurrency(t *testing.T) {
+	slowImg, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	layers, err := slowImg.Layers()
+	require.NoError(t, err)
+	slowLayer, err := layers[0].Digest()
+	require.NoError(t, err)
+
+	// The layer of the slow image is not served until released
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+slowLayer.String()) {
+			once.Do(func() { close(blocked) })
+			<-release
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(slowImg, fmt.Sprintf("%s/bitnami/slow:1.0.0", upstreamURL.Host)))
+	fastImg, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(fastImg, fmt.Sprintf("%s/bitnami/fast:1.0.0", upstreamURL.Host)))
+
+	s, err := NewServer(imagelock.NewImagesLock(), t.TempDir(), WithCacheDir(t.TempDir()), WithDefaultRegistry(upstreamURL.Host))
+	require.NoError(t, err)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := crane.Digest(fmt.Sprintf("%s/bitnami/slow:1.0.0", u.Host))
+		slowDone <- err
+	}()
+	select {
+	case <-blocked:
+	case <-time.After(10 * time.Second):
+		close(release)
+		t.Fatal("the slow image was not fetched")
+	}
+
+	// Other images are fetched while the slow one is downloaded
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := crane.Digest(fmt.Sprintf("%s/bitnami/fast:1.0.0", u.Host))
+		fastDone <- err
+	}()
+	select {
+	case err := <-fastDone:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Error("fetching an image waited for the download of another")
+	}
+	close(release)
+	require.NoError(t, <-slowDone)
+	dgst, err := crane.Digest(fmt.Sprintf("%s/bitnami/slow:1.0.0", u.Host))
+	require.NoError(t, err)
+	expected, err := slowImg.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, expected.String(), dgst)
+}
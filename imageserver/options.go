@@ -0,0 +1,61 @@
+package imageserver
+
+import (
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Config defines the configuration of the Server
+type Config struct {
+	// CacheDir, if not empty, enables the pull-through cache: the images the Server does not hold are fetched
+	// from their upstream registries and stored into the OCI layout in CacheDir
+	CacheDir string
+	// DefaultRegistry, if not empty, is the upstream registry of the repositories not in the Images.lock
+	DefaultRegistry string
+	// Keychain resolves the credentials used to access the upstream registries
+	Keychain authn.Keychain
+	// Transport, if not nil, is the HTTP transport used to access the upstream registries
+	Transport http.RoundTripper
+}
+
+// NewConfig returns a new Config with default values
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{Keychain: authn.DefaultKeychain}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Option defines a Config option
+type Option func(*Config)
+
+// WithCacheDir fetches the images the Server does not hold from their upstream registries, caching them into
+// the OCI layout in dir
+func WithCacheDir(dir string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.CacheDir = dir
+	}
+}
+
+// WithDefaultRegistry sets the upstream registry of the repositories not in the Images.lock
+func WithDefaultRegistry(registry string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.DefaultRegistry = registry
+	}
+}
+
+// WithKeychain sets the keychain used to access the upstream registries
+func WithKeychain(kc authn.Keychain) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Keychain = kc
+	}
+}
+
+// WithTransport sets the HTTP transport used to access the upstream registries
+func WithTransport(tr http.RoundTripper) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Transport = tr
+	}
+}
@@ -0,0 +1,333 @@
+package imageserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"golang.org/x/sync/errgroup"
+)
+
+// refNameAnnotation records, in the cache index, the reference the cached images are served with
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// proxy fetches the images the Server does not hold from their upstream registries, caching them into an
+// OCI layout
+type proxy struct {
+	cache layout.Path
+	// registries are the upstream registries of the repositories in the Images.lock
+	registries      map[string]string
+	defaultRegistry string
+	opts            []remote.Option
+	// blobs serializes the writes of each manifest and blob, so concurrent requests of an image, or of images
+	// sharing layers, download them once while other images are fetched in parallel
+	blobs digestLocks
+	// indexMu serializes the reads and updates of the cache index
+	indexMu sync.Mutex
+}
+
+// digestLocks holds a mutex per digest
+type digestLocks struct {
+	mu    sync.Mutex
+	locks map[v1.Hash]*sync.Mutex
+}
+
+// lock locks the digest, returning the function unlocking it
+func (l *digestLocks) lock(h v1.Hash) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[v1.Hash]*sync.Mutex)
+	}
+	m, ok := l.locks[h]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[h] = m
+	}
+	l.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+func newProxy(lock *imagelock.ImagesLock, cfg *Config) (*proxy, error) {
+	cache, err := layout.FromPath(cfg.CacheDir)
+	if err != nil {
+		if cache, err = layout.Write(cfg.CacheDir, empty.Index); err != nil {
+			return nil, fmt.Errorf("failed to create cache %q: %w", cfg.CacheDir, err)
+		}
+	}
+	p := &proxy{
+		cache: cache, registries: make(map[string]string), defaultRegistry: cfg.DefaultRegistry,
+		opts: []remote.Option{remote.WithAuthFromKeychain(cfg.Keychain)},
+	}
+	if cfg.Transport != nil {
+		p.opts = append(p.opts, remote.WithTransport(cfg.Transport))
+	}
+	for _, img := range lock.Images {
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image reference %q: %w", img.Image, err)
+		}
+		if _, found := p.registries[ref.Context().RepositoryStr()]; !found {
+			p.registries[ref.Context().RepositoryStr()] = ref.Context().RegistryStr()
+		}
+	}
+	return p, nil
+}
+
+// upstream returns the upstream reference of reference, a tag or a digest, in the repository
+func (p *proxy) upstream(repoName string, reference string) (name.Reference, error) {
+	registry, ok := p.registries[repoName]
+	if !ok {
+		registry = p.defaultRegistry
+	}
+	if registry == "" {
+		return nil, fmt.Errorf("no upstream registry for repository %q", repoName)
+	}
+	if strings.Contains(reference, ":") {
+		return name.NewDigest(fmt.Sprintf("%s/%s@%s", registry, repoName, reference))
+	}
+	return name.NewTag(fmt.Sprintf("%s/%s:%s", registry, repoName, reference))
+}
+
+// servedRef returns the reference the image is served with, as recorded in the cache index
+func servedRef(repoName string, reference string) string {
+	if strings.Contains(reference, ":") {
+		return repoName + "@" + reference
+	}
+	return repoName + ":" + reference
+}
+
+// parseServedRef returns the repository and the tag, or digest, of a reference returned by servedRef
+func parseServedRef(ref string) (string, string, bool) {
+	if i := strings.LastIndex(ref, "@"); i > 0 {
+		return ref[:i], ref[i+1:], true
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:], true
+	}
+	return "", "", false
+}
+
+// fetch downloads reference, a tag or a digest, from the upstream registry of the repository into the
+// cache, and serves it
+func (s *Server) fetch(ctx context.Context, repoName string, reference string) error {
+	p := s.proxy
+	ref, err := p.upstream(repoName, reference)
+	if err != nil {
+		return err
+	}
+	desc, err := remote.Get(ref, append(p.opts, remote.WithContext(ctx))...)
+	if err != nil {
+		return err
+	}
+	unlock := p.blobs.lock(desc.Digest)
+	defer unlock()
+	// Fetched by a concurrent request
+	if _, _, ok := s.lookupManifest(repoName, reference); ok {
+		return nil
+	}
+	if err := p.write(desc); err != nil {
+		return fmt.Errorf("failed to cache %q: %w", ref, err)
+	}
+	d := desc.Descriptor
+	d.Annotations = map[string]string{refNameAnnotation: servedRef(repoName, reference)}
+	p.indexMu.Lock()
+	err = p.cache.AppendDescriptor(d)
+	p.indexMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to cache %q: %w", ref, err)
+	}
+	return s.addCached(repoName, reference, desc.Digest)
+}
+
+// write writes the blobs of the image, or image index, into the cache. The caller holds the lock of its
+// digest
+func (p *proxy) write(desc *remote.Descriptor) error {
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return p.writeIndex(idx)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return p.writeImage(img)
+}
+
+// writeIndex writes the blobs of the image index, and of its children, into the cache
+func (p *proxy) writeIndex(idx v1.ImageIndex) error {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, child := range im.Manifests {
+		switch {
+		case child.MediaType.IsIndex():
+			childIdx, err := idx.ImageIndex(child.Digest)
+			if err != nil {
+				return err
+			}
+			if err := p.lockedWrite(child.Digest, func() error { return p.writeIndex(childIdx) }); err != nil {
+				return err
+			}
+		case child.MediaType.IsImage():
+			img, err := idx.Image(child.Digest)
+			if err != nil {
+				return err
+			}
+			if err := p.lockedWrite(child.Digest, func() error { return p.writeImage(img) }); err != nil {
+				return err
+			}
+		}
+	}
+	raw, err := idx.RawManifest()
+	if err != nil {
+		return err
+	}
+	h, err := idx.Digest()
+	if err != nil {
+		return err
+	}
+	return p.cache.WriteBlob(h, io.NopCloser(bytes.NewReader(raw)))
+}
+
+// writeImage writes the layers, config and manifest of the image into the cache, locking each layer while
+// written
+func (p *proxy) writeImage(img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	var g errgroup.Group
+	for _, layer := range layers {
+		layer := layer
+		g.Go(func() error {
+			h, err := layer.Digest()
+			if err != nil {
+				return err
+			}
+			return p.lockedWrite(h, func() error { return p.writeLayer(h, layer) })
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	cfgName, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	cfg, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := p.lockedWrite(cfgName, func() error {
+		return p.cache.WriteBlob(cfgName, io.NopCloser(bytes.NewReader(cfg)))
+	}); err != nil {
+		return err
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		return err
+	}
+	h, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	return p.cache.WriteBlob(h, io.NopCloser(bytes.NewReader(raw)))
+}
+
+// writeLayer writes the compressed layer into the cache, unless already cached. A blob of a different size,
+// left by an interrupted execution, is downloaded again
+func (p *proxy) writeLayer(h v1.Hash, layer v1.Layer) error {
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(filepath.Join(string(p.cache), "blobs", h.Algorithm, h.Hex)); err == nil {
+		if fi.Size() == size {
+			return nil
+		}
+		if err := p.cache.RemoveBlob(h); err != nil {
+			return err
+		}
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	return p.cache.WriteBlob(h, rc)
+}
+
+// lockedWrite runs write holding the lock of the digest
+func (p *proxy) lockedWrite(h v1.Hash, write func() error) error {
+	unlock := p.blobs.lock(h)
+	defer unlock()
+	return write()
+}
+
+// loadCache serves the images cached by previous executions
+func (s *Server) loadCache() error {
+	root, err := s.proxy.cache.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	im, err := root.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	for _, desc := range im.Manifests {
+		repoName, reference, ok := parseServedRef(desc.Annotations[refNameAnnotation])
+		if !ok {
+			continue
+		}
+		if err := s.addCached(repoName, reference, desc.Digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCached serves the cached image, or image index, with digest h as reference, a tag or a digest, in the
+// repository
+func (s *Server) addCached(repoName string, reference string, h v1.Hash) error {
+	s.proxy.indexMu.Lock()
+	root, err := s.proxy.cache.ImageIndex()
+	s.proxy.indexMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(repoName)
+	if idx, err := root.ImageIndex(h); err == nil {
+		if _, err := addIndex(repo, idx); err != nil {
+			return err
+		}
+	} else {
+		img, err := root.Image(h)
+		if err != nil {
+			return fmt.Errorf("failed to read cached image %s: %w", h, err)
+		}
+		if err := addImage(repo, img); err != nil {
+			return err
+		}
+	}
+	if !strings.Contains(reference, ":") {
+		repo.tags[reference] = h
+	}
+	return nil
+}
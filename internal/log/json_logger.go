@@ -0,0 +1,123 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+)
+
+// JSONSectionLogger defines a SectionLogger writing one JSON document per message,
+// including the current section and the steps durations as fields
+type JSONSectionLogger struct {
+	logger   *logrus.Logger
+	sections []string
+}
+
+// NewJSONSectionLogger returns a new SectionLogger producing JSON messages
+func NewJSONSectionLogger() SectionLogger {
+	l := logrus.New()
+	l.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	return &JSONSectionLogger{logger: l}
+}
+
+func (l *JSONSectionLogger) entry() *logrus.Entry {
+	e := logrus.NewEntry(l.logger)
+	if len(l.sections) > 0 {
+		e = e.WithField("section", strings.Join(l.sections, " / "))
+	}
+	return e
+}
+
+// SetWriter sets the internal writer used by the log
+func (l *JSONSectionLogger) SetWriter(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
+// SetLevel sets the log level
+func (l *JSONSectionLogger) SetLevel(level Level) {
+	l.logger.SetLevel(logrus.Level(level))
+}
+
+// Failf logs a formatted error and returns it back
+func (l *JSONSectionLogger) Failf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	l.Errorf("%v", err)
+	return &LoggedError{Err: err}
+}
+
+// Printf prints a message in the log
+func (l *JSONSectionLogger) Printf(format string, args ...interface{}) {
+	l.entry().Infof(format, args...)
+}
+
+// Errorf logs an error message
+func (l *JSONSectionLogger) Errorf(format string, args ...interface{}) {
+	l.entry().Errorf(format, args...)
+}
+
+// Infof logs an information message
+func (l *JSONSectionLogger) Infof(format string, args ...interface{}) {
+	l.entry().Infof(format, args...)
+}
+
+// Debugf logs a debug message
+func (l *JSONSectionLogger) Debugf(format string, args ...interface{}) {
+	l.entry().Debugf(format, args...)
+}
+
+// Warnf logs a warning message
+func (l *JSONSectionLogger) Warnf(format string, args ...interface{}) {
+	l.entry().Warnf(format, args...)
+}
+
+// Successf logs a new success message
+func (l *JSONSectionLogger) Successf(format string, args ...interface{}) {
+	l.entry().WithField("success", true).Infof(format, args...)
+}
+
+// PrefixText returns the provided text, as JSON messages are not indented
+func (l *JSONSectionLogger) PrefixText(txt string) string {
+	return txt
+}
+
+// StartSection starts a new log section, whose name is included in the messages
+func (l *JSONSectionLogger) StartSection(title string) SectionLogger {
+	l.entry().Info(title)
+	sections := make([]string, 0, len(l.sections)+1)
+	sections = append(sections, l.sections...)
+	return &JSONSectionLogger{logger: l.logger, sections: append(sections, title)}
+}
+
+// Section executes the provided function inside a new section, logging its duration
+func (l *JSONSectionLogger) Section(title string, fn func(SectionLogger) error) error {
+	start := time.Now()
+	err := fn(l.StartSection(title))
+	l.finished(l.entry().WithField("completedSection", title), start, err)
+	return err
+}
+
+// ExecuteStep executes a function, logging its duration
+func (l *JSONSectionLogger) ExecuteStep(title string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	l.finished(l.entry().WithField("step", title), start, err)
+	return err
+}
+
+func (l *JSONSectionLogger) finished(e *logrus.Entry, start time.Time, err error) {
+	e = e.WithField("durationMs", time.Since(start).Milliseconds())
+	if err != nil {
+		e.WithError(err).Error("failed")
+		return
+	}
+	e.Info("completed")
+}
+
+// ProgressBar returns a new ProgressBar logging its progress as JSON messages
+func (l *JSONSectionLogger) ProgressBar() widgets.ProgressBar {
+	return widgets.NewLogProgressBar(l.logger)
+}
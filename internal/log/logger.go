@@ -43,8 +43,16 @@ const (
 var (
 	// SilentLog implement a Logger that does not print anything
 	SilentLog = NewSilentLogger()
+
+	colorsDisabled = false
 )
 
+// DisableColors disables the ANSI colors in the output of all loggers
+func DisableColors() {
+	colorsDisabled = true
+	pterm.DisableColor()
+}
+
 // LoggedError indicates an error that has been already logged
 type LoggedError struct {
 	Err error
@@ -172,7 +180,11 @@ func NewLogrusLogger() Logger {
 }
 
 func newLogrusLogger() *LogrusLogger {
-	return &LogrusLogger{Logger: logrus.New()}
+	l := logrus.New()
+	if colorsDisabled {
+		l.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	}
+	return &LogrusLogger{Logger: l}
 }
 
 // NewSilentLogger returns a new Logger that does not log any message
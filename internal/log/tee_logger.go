@@ -0,0 +1,104 @@
+package log
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/widgets"
+)
+
+// TeeSectionLogger defines a SectionLogger that sends every message to two loggers.
+// The secondary logger keeps its own level and does not display progress bars
+type TeeSectionLogger struct {
+	primary   SectionLogger
+	secondary SectionLogger
+}
+
+// NewTeeSectionLogger returns a SectionLogger writing to both primary and secondary
+func NewTeeSectionLogger(primary SectionLogger, secondary SectionLogger) SectionLogger {
+	return &TeeSectionLogger{primary: primary, secondary: secondary}
+}
+
+// SetWriter sets the writer of the primary logger
+func (l *TeeSectionLogger) SetWriter(w io.Writer) {
+	l.primary.SetWriter(w)
+}
+
+// SetLevel sets the level of the primary logger
+func (l *TeeSectionLogger) SetLevel(level Level) {
+	l.primary.SetLevel(level)
+}
+
+// Failf logs a formatted error and returns it back
+func (l *TeeSectionLogger) Failf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	l.Errorf("%v", err)
+	return &LoggedError{Err: err}
+}
+
+// Printf prints a message in the log
+func (l *TeeSectionLogger) Printf(format string, args ...interface{}) {
+	l.primary.Printf(format, args...)
+	l.secondary.Printf(format, args...)
+}
+
+// Errorf logs an error message
+func (l *TeeSectionLogger) Errorf(format string, args ...interface{}) {
+	l.primary.Errorf(format, args...)
+	l.secondary.Errorf(format, args...)
+}
+
+// Infof logs an information message
+func (l *TeeSectionLogger) Infof(format string, args ...interface{}) {
+	l.primary.Infof(format, args...)
+	l.secondary.Infof(format, args...)
+}
+
+// Debugf logs a debug message
+func (l *TeeSectionLogger) Debugf(format string, args ...interface{}) {
+	l.primary.Debugf(format, args...)
+	l.secondary.Debugf(format, args...)
+}
+
+// Warnf logs a warning message
+func (l *TeeSectionLogger) Warnf(format string, args ...interface{}) {
+	l.primary.Warnf(format, args...)
+	l.secondary.Warnf(format, args...)
+}
+
+// Successf logs a new success message
+func (l *TeeSectionLogger) Successf(format string, args ...interface{}) {
+	l.primary.Successf(format, args...)
+	l.secondary.Successf(format, args...)
+}
+
+// PrefixText returns the indented version of the provided text, according to the primary logger
+func (l *TeeSectionLogger) PrefixText(txt string) string {
+	return l.primary.PrefixText(txt)
+}
+
+// StartSection starts a new log section in both loggers
+func (l *TeeSectionLogger) StartSection(title string) SectionLogger {
+	return NewTeeSectionLogger(l.primary.StartSection(title), l.secondary.StartSection(title))
+}
+
+// Section executes the provided function inside a new section of both loggers
+func (l *TeeSectionLogger) Section(title string, fn func(SectionLogger) error) error {
+	return l.primary.Section(title, func(primary SectionLogger) error {
+		return l.secondary.Section(title, func(secondary SectionLogger) error {
+			return fn(NewTeeSectionLogger(primary, secondary))
+		})
+	})
+}
+
+// ExecuteStep executes a function as a step of both loggers
+func (l *TeeSectionLogger) ExecuteStep(title string, fn func() error) error {
+	return l.primary.ExecuteStep(title, func() error {
+		return l.secondary.ExecuteStep(title, fn)
+	})
+}
+
+// ProgressBar returns the progress bar of the primary logger
+func (l *TeeSectionLogger) ProgressBar() widgets.ProgressBar {
+	return l.primary.ProgressBar()
+}
@@ -0,0 +1,165 @@
+package testutil
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// apiVersionRe matches the API version prefix of the Docker Engine API paths
+var apiVersionRe = regexp.MustCompile(`^/v[0-9.]+`)
+
+// DockerDaemon defines a fake Docker Engine API server for testing, storing and loading images
+type DockerDaemon struct {
+	s  *httptest.Server
+	mu sync.Mutex
+	// images are the images stored in the daemon, by reference
+	images map[string]v1.Image
+	// loaded are the tags of the images loaded into the daemon
+	loaded []string
+	// LoadError, if not empty, makes the image loads fail with that message
+	LoadError string
+}
+
+// NewDockerDaemon returns a new fake Docker daemon. Point the Docker clients to it with Host
+func NewDockerDaemon() *DockerDaemon {
+	d := &DockerDaemon{images: make(map[string]v1.Image), loaded: make([]string, 0)}
+	d.s = httptest.NewServer(http.HandlerFunc(d.handle))
+	return d
+}
+
+// Host returns the DOCKER_HOST of the daemon
+func (d *DockerDaemon) Host() string {
+	return "tcp://" + strings.TrimPrefix(d.s.URL, "http://")
+}
+
+// Close shuts down the daemon
+func (d *DockerDaemon) Close() {
+	d.s.Close()
+}
+
+// AddImage stores img in the daemon under the ref reference
+func (d *DockerDaemon) AddImage(ref string, img v1.Image) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.images[ref] = img
+}
+
+// Loaded returns the tags of the images loaded into the daemon
+func (d *DockerDaemon) Loaded() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.loaded...)
+}
+
+func (d *DockerDaemon) image(ref string) (v1.Image, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img, ok := d.images[ref]
+	return img, ok
+}
+
+func (d *DockerDaemon) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Api-Version", "1.42")
+	path := apiVersionRe.ReplaceAllString(r.URL.Path, "")
+	switch {
+	case path == "/_ping":
+		fmt.Fprint(w, "OK")
+	case path == "/images/load":
+		d.load(w, r)
+	case path == "/images/get":
+		d.save(w, r.URL.Query()["names"])
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		d.inspect(w, strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json"))
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/history"):
+		fmt.Fprint(w, "[]")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// load records the tags in the manifest of the loaded docker-archive
+func (d *DockerDaemon) load(w http.ResponseWriter, r *http.Request) {
+	tr := tar.NewReader(r.Body)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Name != "manifest.json" {
+			continue
+		}
+		var manifest []struct{ RepoTags []string }
+		if err := json.NewDecoder(tr).Decode(&manifest); err == nil {
+			d.mu.Lock()
+			for _, m := range manifest {
+				d.loaded = append(d.loaded, m.RepoTags...)
+			}
+			d.mu.Unlock()
+		}
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	if d.LoadError != "" {
+		fmt.Fprintf(w, `{"errorDetail":{"message":%q},"error":%q}`+"\n", d.LoadError, d.LoadError)
+		return
+	}
+	fmt.Fprint(w, `{"stream":"Loaded images\n"}`+"\n")
+}
+
+// inspect writes the subset of the image inspection used by the Docker clients
+func (d *DockerDaemon) inspect(w http.ResponseWriter, ref string) {
+	img, ok := d.image(ref)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"No such image: %s"}`, ref)
+		return
+	}
+	id, err := img.ConfigName()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	layers := make([]string, 0)
+	for _, h := range cfg.RootFS.DiffIDs {
+		layers = append(layers, h.String())
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"Id": id.String(), "Os": cfg.OS, "Architecture": cfg.Architecture, "Created": cfg.Created.Format("2006-01-02T15:04:05Z07:00"),
+		"RootFS": map[string]interface{}{"Type": "layers", "Layers": layers},
+	})
+}
+
+// save writes the requested images as a docker-archive
+func (d *DockerDaemon) save(w http.ResponseWriter, names []string) {
+	images := make(map[name.Reference]v1.Image)
+	for _, n := range names {
+		img, ok := d.image(n)
+		if !ok {
+			http.Error(w, fmt.Sprintf("No such image: %s", n), http.StatusNotFound)
+			return
+		}
+		ref, err := name.ParseReference(n)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		images[ref] = img
+	}
+	_ = tarball.MultiRefWrite(images, w)
+}
@@ -0,0 +1,139 @@
+package testutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// secretsPathRe matches the path listing the secrets of a namespace
+var secretsPathRe = regexp.MustCompile(`^/api/v1/namespaces/([^/]+)/secrets$`)
+
+// KubeCluster defines a fake Kubernetes API server for testing, serving the Helm releases stored with the
+// default Helm storage driver, secrets
+type KubeCluster struct {
+	s        *httptest.Server
+	mu       sync.Mutex
+	releases []*release.Release
+}
+
+// NewKubeCluster returns a new fake Kubernetes cluster. Point the Kubernetes clients to it with WriteKubeconfig
+func NewKubeCluster() *KubeCluster {
+	k := &KubeCluster{releases: make([]*release.Release, 0)}
+	k.s = httptest.NewServer(http.HandlerFunc(k.handle))
+	return k
+}
+
+// Close shuts down the cluster
+func (k *KubeCluster) Close() {
+	k.s.Close()
+}
+
+// AddRelease stores rel in the cluster, in its namespace
+func (k *KubeCluster) AddRelease(rel *release.Release) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.releases = append(k.releases, rel)
+}
+
+// WriteKubeconfig writes a kubeconfig file accessing the cluster, with namespace as default namespace
+func (k *KubeCluster) WriteKubeconfig(file string, namespace string) error {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    namespace: %s
+    user: test
+current-context: test
+users:
+- name: test
+  user: {}
+`, k.s.URL, namespace)
+	return os.WriteFile(file, []byte(kubeconfig), 0600)
+}
+
+// encodeRelease encodes rel as the Helm storage drivers do, gzipped and base64 encoded
+func encodeRelease(rel *release.Release) ([]byte, error) {
+	data, err := json.Marshal(rel)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// secrets returns the secrets storing the releases in namespace matching the label selector of a Helm query
+func (k *KubeCluster) secrets(namespace string, selector string) ([]interface{}, error) {
+	labels := make(map[string]string)
+	for _, l := range strings.Split(selector, ",") {
+		if key, value, found := strings.Cut(l, "="); found {
+			labels[key] = value
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	items := make([]interface{}, 0)
+	for _, rel := range k.releases {
+		if rel.Namespace != namespace || (labels["name"] != "" && labels["name"] != rel.Name) {
+			continue
+		}
+		data, err := encodeRelease(rel)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("sh.helm.release.v1.%s.v%d", rel.Name, rel.Version),
+				"namespace": namespace,
+				"labels":    map[string]string{"name": rel.Name, "owner": "helm", "version": fmt.Sprint(rel.Version)},
+			},
+			"type": "helm.sh/release.v1",
+			"data": map[string][]byte{"release": data},
+		})
+	}
+	return items, nil
+}
+
+func (k *KubeCluster) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Helm checks the cluster is reachable by requesting its version
+	if r.URL.Path == "/version" {
+		fmt.Fprint(w, `{"major":"1","minor":"27","gitVersion":"v1.27.3"}`)
+		return
+	}
+	m := secretsPathRe.FindStringSubmatch(r.URL.Path)
+	if m == nil || r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+		return
+	}
+	items, err := k.secrets(m[1], r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"kind": "SecretList", "apiVersion": "v1", "items": items})
+}
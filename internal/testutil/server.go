@@ -3,6 +3,8 @@ package testutil
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,6 +25,15 @@ type TestServer struct {
 	ServerURL    string
 	s            *httptest.Server
 	responsesMap map[string]response
+	// username and password, if not empty, are the credentials required to access the server
+	username string
+	password string
+}
+
+// RequireBasicAuth makes the server reject the requests not authenticated with the provided credentials
+func (s *TestServer) RequireBasicAuth(username string, password string) {
+	s.username = username
+	s.password = password
 }
 
 // DigestData defines Digest information for an Architecture
@@ -54,6 +65,18 @@ func (s *TestServer) AddImage(img *ImageData) error {
 	return nil
 }
 
+// Certificate returns the certificate used by the server, valid for 127.0.0.1
+func (s *TestServer) Certificate() *x509.Certificate {
+	return s.s.Certificate()
+}
+
+// RequireClientCertificates makes the server reject the connections not authenticated with a client
+// certificate signed by one of the CAs in pool
+func (s *TestServer) RequireClientCertificates(pool *x509.CertPool) {
+	s.s.TLS.ClientCAs = pool
+	s.s.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+}
+
 // Close shuts down the test server
 func (s *TestServer) Close() {
 	s.s.Close()
@@ -87,6 +110,13 @@ func NewTestServer() (*TestServer, error) {
 	testServer := &TestServer{responsesMap: make(map[string]response)}
 
 	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if testServer.username != "" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != testServer.username || pass != testServer.password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
 		if strings.Contains(r.URL.Path, "manifests") {
 			resp, ok := testServer.responsesMap[r.URL.Path]
 			if !ok {
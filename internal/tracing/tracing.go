@@ -0,0 +1,87 @@
+// Package tracing implements the OpenTelemetry instrumentation of the tool
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName is the name reported as the service.name resource attribute
+	ServiceName = "dt"
+	tracerName  = "github.com/vmware-labs/distribution-tooling-for-helm"
+
+	// Maximum time to wait for the pending spans to be exported on shutdown
+	shutdownTimeout = 5 * time.Second
+)
+
+// Enabled returns true if an OTLP endpoint is configured through the standard OpenTelemetry environment variables
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup configures the global tracer provider to export the spans via OTLP, if Enabled.
+// It returns the function that flushes the pending spans and stops the provider
+func Setup(ctx context.Context, version string) (func() error, error) {
+	if !Enabled() {
+		return func() error { return nil }, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", ServiceName),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenTelemetry resource: %w", err)
+	}
+	return register(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))), nil
+}
+
+// register sets tp as the global tracer provider and returns its shutdown function
+func register(tp *sdktrace.TracerProvider) func() error {
+	otel.SetTracerProvider(tp)
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return tp.Shutdown(ctx)
+	}
+}
+
+// Start starts a new span, child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, recording err if not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Run executes fn inside a new span
+func Run(ctx context.Context, name string, fn func(ctx context.Context) error, attrs ...attribute.KeyValue) error {
+	ctx, span := Start(ctx, name, attrs...)
+	err := fn(ctx)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	assert.False(t, Enabled())
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4317")
+	assert.True(t, Enabled())
+}
+
+func TestRun(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := register(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	ctx, span := Start(context.Background(), "wrap")
+	require.NoError(t, Run(ctx, "lock", func(context.Context) error { return nil }))
+	err := Run(ctx, "pull images", func(context.Context) error {
+		return errors.New("pull failed")
+	}, attribute.String("image", "nginx"))
+	require.Error(t, err)
+	End(span, err)
+
+	spans := exporter.GetSpans()
+	require.NoError(t, shutdown())
+	require.Len(t, spans, 3)
+
+	lock, pull, root := spans[0], spans[1], spans[2]
+	assert.Equal(t, "lock", lock.Name)
+	assert.Equal(t, root.SpanContext.SpanID(), lock.Parent.SpanID())
+	assert.Equal(t, codes.Unset, lock.Status.Code)
+
+	assert.Equal(t, "pull images", pull.Name)
+	assert.Equal(t, root.SpanContext.SpanID(), pull.Parent.SpanID())
+	assert.Equal(t, codes.Error, pull.Status.Code)
+	assert.Equal(t, "pull failed", pull.Status.Description)
+	assert.Contains(t, pull.Attributes, attribute.String("image", "nginx"))
+
+	assert.Equal(t, "wrap", root.Name)
+	assert.Equal(t, codes.Error, root.Status.Code)
+}
@@ -0,0 +1,106 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+)
+
+// Stage copies the chart directory at chartPath into a new directory under dir, returning it, so the wrap
+// steps write the Images.lock, the pulled images and the wrap metadata without modifying the chart. The
+// image tarballs already pulled into the chart are hard linked, when possible, instead of copied
+func Stage(chartPath string, dir string) (string, error) {
+	chartRoot, err := chartutils.GetChartRoot(chartPath)
+	if err != nil {
+		return "", err
+	}
+	stagingDir, err := os.MkdirTemp(dir, "wrap-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	dest := filepath.Join(stagingDir, filepath.Base(chartRoot))
+	imagesDir := filepath.Join(chartRoot, "images")
+	if err := copyTree(chartRoot, dest, func(path string) bool { return filepath.Dir(path) == imagesDir }); err != nil {
+		return "", fmt.Errorf("failed to stage Helm chart: %w", err)
+	}
+	return dest, nil
+}
+
+// CopyTree copies the regular files, directories and symlinks in src into dest, which must not exist or be empty
+func CopyTree(src string, dest string) error {
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return fmt.Errorf("directory %q is not empty", dest)
+	}
+	return copyTree(src, dest, func(string) bool { return false })
+}
+
+// copyTree copies the regular files, directories and symlinks in src into dest, hard linking the files
+// matching link
+func copyTree(src string, dest string, link func(path string) bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return copySymlink(src, path, target)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("cannot copy %q: unsupported file type %s", path, info.Mode().Type())
+		}
+		if link(path) && os.Link(path, target) == nil {
+			return nil
+		}
+		return copyFileMode(path, target, info.Mode().Perm())
+	})
+}
+
+// copySymlink recreates the symlink at path, in the tree rooted at src, as target. Relative links pointing
+// outside src are made absolute, so they keep resolving to the same file
+func copySymlink(src string, path string, target string) error {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(link) {
+		resolved := filepath.Join(filepath.Dir(path), link)
+		if rel, err := filepath.Rel(src, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			link = resolved
+		}
+	}
+	return os.Symlink(link, target)
+}
+
+// copyFileMode copies src into dest, streaming it, with the given permissions
+func copyFileMode(src string, dest string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
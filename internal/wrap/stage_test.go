@@ -0,0 +1,87 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStage(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "mychart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "images"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0755))
+	files := map[string]string{
+		"Chart.yaml":                "apiVersion: v2\nname: mychart\nversion: 1.0.0\n",
+		"values.yaml":               "image: bitnami/app:1.0.0\n",
+		"templates/deployment.yaml": "kind: Deployment\n",
+		"images/abcd.tar":           "image",
+	}
+	for f, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, f), []byte(data), 0644))
+	}
+
+	staged, err := Stage(filepath.Join(chartDir, "Chart.yaml"), t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "mychart", filepath.Base(staged))
+	for f, data := range files {
+		got, err := os.ReadFile(filepath.Join(staged, f))
+		require.NoError(t, err)
+		assert.Equal(t, data, string(got))
+	}
+
+	// Modifying the staged chart does not modify the original one
+	require.NoError(t, os.WriteFile(filepath.Join(staged, "values.yaml"), []byte("relocated"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(staged, "Images.lock"), []byte("lock"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(staged, "images", "abcd.tar")))
+	for f, data := range files {
+		got, err := os.ReadFile(filepath.Join(chartDir, f))
+		require.NoError(t, err)
+		assert.Equal(t, data, string(got))
+	}
+	assert.NoFileExists(t, filepath.Join(chartDir, "Images.lock"))
+}
+
+func TestStageSymlinks(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "mychart")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "files"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: mychart\nversion: 1.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "files", "config.yaml"), []byte("config"), 0644))
+	outside := filepath.Join(filepath.Dir(chartDir), "outside.yaml")
+	require.NoError(t, os.WriteFile(outside, []byte("outside"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join("files", "config.yaml"), filepath.Join(chartDir, "config.yaml")))
+	require.NoError(t, os.Symlink(filepath.Join("..", "outside.yaml"), filepath.Join(chartDir, "outside.yaml")))
+
+	staged, err := Stage(chartDir, t.TempDir())
+	require.NoError(t, err)
+
+	// Links inside the chart are kept as they are
+	link, err := os.Readlink(filepath.Join(staged, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("files", "config.yaml"), link)
+	data, err := os.ReadFile(filepath.Join(staged, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "config", string(data))
+
+	// and the ones pointing outside keep resolving to the same file
+	link, err = os.Readlink(filepath.Join(staged, "outside.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, outside, link)
+	data, err = os.ReadFile(filepath.Join(staged, "outside.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "outside", string(data))
+}
+
+func TestCopyTree(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "file.txt"), []byte("data"), 0600))
+	dest := filepath.Join(t.TempDir(), "dest")
+	require.NoError(t, CopyTree(src, dest))
+	fi, err := os.Stat(filepath.Join(dest, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	assert.ErrorContains(t, CopyTree(src, dest), "is not empty")
+}
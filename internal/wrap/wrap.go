@@ -0,0 +1,152 @@
+// Package wrap implements the steps of wrapping and unwrapping Helm charts shared by the dt command and the
+// pkg/dt API
+package wrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// BaseName returns the NAME-VERSION name the entries of the wrap of chart are prefixed with
+func BaseName(chart *chartutils.Chart) string {
+	return fmt.Sprintf("%s-%s", chart.Name(), chart.Metadata.Version)
+}
+
+// DefaultFileName returns the file name of the wrap of chart, NAME-VERSION.wrap.tgz
+func DefaultFileName(chart *chartutils.Chart) string {
+	return BaseName(chart) + ".wrap.tgz"
+}
+
+// LockFile returns the path of the Images.lock of the chart at chartPath
+func LockFile(chartPath string) (string, error) {
+	chartRoot, err := chartutils.GetChartRoot(chartPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(chartRoot, imagelock.DefaultImagesLockFileName), nil
+}
+
+// WriteLock writes lock into lockFile
+func WriteLock(lock *imagelock.ImagesLock, lockFile string) error {
+	buff := &bytes.Buffer{}
+	if err := lock.ToYAML(buff); err != nil {
+		return fmt.Errorf("failed to write Images.lock file: %v", err)
+	}
+	if err := os.WriteFile(lockFile, buff.Bytes(), 0666); err != nil {
+		return fmt.Errorf("failed to write lock to %q: %w", lockFile, err)
+	}
+	return nil
+}
+
+// CreateLock generates the Images.lock of the chart at chartPath, resolving the digests of its images, and
+// writes it into lockFile
+func CreateLock(chartPath string, lockFile string, opts ...imagelock.Option) (*imagelock.ImagesLock, error) {
+	lock, err := imagelock.GenerateFromChart(chartPath, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %v", err)
+	}
+	if err := WriteLock(lock, lockFile); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// VerifyLock checks the Images.lock at lockFile matches the images the chart at chartPath references,
+// returning it
+func VerifyLock(chartPath string, lockFile string, opts ...imagelock.Option) (*imagelock.ImagesLock, error) {
+	if !utils.FileExists(chartPath) {
+		return nil, fmt.Errorf("Helm chart %q does not exist", chartPath)
+	}
+	current, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %v", err)
+	}
+	calculated, err := imagelock.GenerateFromChart(chartPath, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-create Images.lock from Helm chart %q: %v", chartPath, err)
+	}
+	if err := calculated.Validate(current.Images); err != nil {
+		return nil, fmt.Errorf("Images.lock does not validate:\n%v", err)
+	}
+	return current, nil
+}
+
+// EnsureLock returns the Images.lock of the chart at chartPath, verifying it or, if missing, creating it
+func EnsureLock(chartPath string, opts ...imagelock.Option) (*imagelock.ImagesLock, error) {
+	lockFile, err := LockFile(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if utils.FileExists(lockFile) {
+		return VerifyLock(chartPath, lockFile, opts...)
+	}
+	return CreateLock(chartPath, lockFile, opts...)
+}
+
+// WriteMetadata writes the wrap.json file describing the wrap into the chart directory, returning its path
+func WriteMetadata(chart *chartutils.Chart, input *metadata.Input) (string, error) {
+	metadataFile := chart.AbsFilePath(metadata.FileName)
+	m, err := metadata.New(input)
+	if err != nil {
+		return "", err
+	}
+	buff := &bytes.Buffer{}
+	if err := m.Write(buff); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(metadataFile, buff.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return metadataFile, nil
+}
+
+// Compress compresses the chart directory into the wrap outputFile
+func Compress(ctx context.Context, chart *chartutils.Chart, outputFile string) error {
+	return utils.TarContext(ctx, chart.RootDir(), outputFile, utils.TarConfig{Prefix: BaseName(chart)})
+}
+
+// Extract uncompresses the wrap, or packaged chart, file into a new directory under dir, returning it
+func Extract(ctx context.Context, file string, dir string) (string, error) {
+	sandboxDir, err := os.MkdirTemp(dir, "at-wrap*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create sandbox directory")
+	}
+	if err := utils.UntarContext(ctx, file, sandboxDir, utils.TarConfig{StripComponents: 1}); err != nil {
+		return "", err
+	}
+	return sandboxDir, nil
+}
+
+// PackageChart packages the chart into dir, returning the NAME-VERSION.tgz file
+func PackageChart(chart *chartutils.Chart, dir string) (string, error) {
+	tarFile := filepath.Join(dir, BaseName(chart)+".tgz")
+	if err := chart.Package(tarFile); err != nil {
+		return "", err
+	}
+	return tarFile, nil
+}
+
+// PushImages pushes the images of the Images.lock of the chart at chartPath, stored in its images directory,
+// returning the lock
+func PushImages(chartPath string, opts ...chartutils.Option) (*imagelock.ImagesLock, error) {
+	lockFile, err := LockFile(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine Helm chart root for %q: %v", chartPath, err)
+	}
+	lock, err := imagelock.FromYAMLFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Images.lock: %v", err)
+	}
+	if err := chartutils.PushImages(lock, filepath.Join(filepath.Dir(lockFile), "images"), opts...); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// DeltaFileName is the name of the file describing the images a delta wrap takes from its base wrap
+	DeltaFileName = "delta.json"
+	// DeltaKind identifies the delta documents
+	DeltaKind = "WrapDelta"
+)
+
+// DeltaBase identifies the wrap a delta wrap was generated from
+type DeltaBase struct {
+	// File is the name of the base wrap file
+	File string `json:"file"`
+	// Digest is the digest of the base wrap file
+	Digest digest.Digest `json:"digest"`
+}
+
+// Delta describes the platform specific images omitted from a delta wrap, which are read from its base wrap
+type Delta struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Base       DeltaBase       `json:"base"`
+	Images     []digest.Digest `json:"images"`
+}
+
+// NewDelta returns the Delta of a wrap omitting the images of base
+func NewDelta(base DeltaBase, images []digest.Digest) *Delta {
+	return &Delta{APIVersion: APIVersion, Kind: DeltaKind, Base: base, Images: images}
+}
+
+// WriteFile serializes the Delta as JSON into file
+func (d *Delta) WriteFile(file string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize wrap delta: %w", err)
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// DeltaFromFile reads the Delta stored in file
+func DeltaFromFile(file string) (*Delta, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wrap delta: %w", err)
+	}
+	d := &Delta{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("failed to parse wrap delta: %w", err)
+	}
+	return d, nil
+}
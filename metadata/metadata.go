@@ -0,0 +1,158 @@
+// Package metadata implements the wrap.json manifest, a machine-readable description
+// of the contents of a wrap
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+const (
+	// FileName is the name of the metadata file inside the wrap
+	FileName = "wrap.json"
+	// APIVersion is the version of the metadata format
+	APIVersion = "v0"
+	// Kind identifies the metadata documents
+	Kind = "WrapMetadata"
+)
+
+// Chart describes the wrapped Helm chart and where it was read from
+type Chart struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion,omitempty"`
+	// Reference is the chart reference provided to the wrap command (path, tarball or OCI URI)
+	Reference string `json:"reference"`
+	// RequestedVersion is the version or version range requested when wrapping a remote chart ("latest"
+	// if omitted), Version being the exact version it resolved to
+	RequestedVersion string `json:"requestedVersion,omitempty"`
+	// Digest is the digest of the packaged chart, if the chart was read from one
+	Digest digest.Digest `json:"digest,omitempty"`
+}
+
+// ImageDigest describes a platform specific image included in the wrap
+type ImageDigest struct {
+	Arch   string        `json:"arch"`
+	Digest digest.Digest `json:"digest"`
+	// Size is the size in bytes of the image tarball
+	Size int64 `json:"size,omitempty"`
+}
+
+// Image describes an image included in the wrap
+type Image struct {
+	Name    string        `json:"name"`
+	Image   string        `json:"image"`
+	Chart   string        `json:"chart"`
+	Digests []ImageDigest `json:"digests"`
+}
+
+// Metadata defines the wrap metadata
+type Metadata struct {
+	APIVersion  string    `json:"apiVersion"`
+	Kind        string    `json:"kind"`
+	ToolVersion string    `json:"toolVersion"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Chart       Chart     `json:"chart"`
+	Platforms   []string  `json:"platforms"`
+	Images      []Image   `json:"images"`
+}
+
+// Input defines the information used to generate the metadata
+type Input struct {
+	// ChartRef is the chart reference provided to the wrap command
+	ChartRef string
+	// ChartVersion is the chart version or version range requested, for remote charts
+	ChartVersion string
+	// ChartFile, if not empty, points to the packaged chart used as input, which is digested
+	ChartFile string
+	// Lock is the Images.lock of the wrapped chart
+	Lock *imagelock.ImagesLock
+	// ImagesDir, if not empty, is the directory containing the image tarballs, used to report their sizes
+	ImagesDir string
+	// Platforms lists the platforms requested
+	Platforms []string
+	// ToolVersion is the version of the tool
+	ToolVersion string
+	// CreatedAt is the wrap creation time
+	CreatedAt time.Time
+}
+
+// New returns the Metadata describing the wrap
+func New(input *Input) (*Metadata, error) {
+	m := &Metadata{
+		APIVersion:  APIVersion,
+		Kind:        Kind,
+		ToolVersion: input.ToolVersion,
+		CreatedAt:   input.CreatedAt.UTC().Truncate(time.Second),
+		Chart:       Chart{Reference: input.ChartRef, RequestedVersion: input.ChartVersion},
+		Platforms:   make([]string, 0),
+		Images:      make([]Image, 0),
+	}
+	if input.Platforms != nil {
+		m.Platforms = input.Platforms
+	}
+	if input.ChartFile != "" {
+		dgst, err := utils.FileSHA256(input.ChartFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to digest %q: %w", input.ChartFile, err)
+		}
+		m.Chart.Digest = digest.NewDigestFromEncoded(digest.SHA256, dgst)
+	}
+	if input.Lock == nil {
+		return m, nil
+	}
+	m.Chart.Name = input.Lock.Chart.Name
+	m.Chart.Version = input.Lock.Chart.Version
+	m.Chart.AppVersion = input.Lock.Chart.AppVersion
+	for _, img := range input.Lock.Images {
+		image := Image{Name: img.Name, Image: img.Image, Chart: img.Chart, Digests: make([]ImageDigest, 0, len(img.Digests))}
+		for _, d := range img.Digests {
+			imgDigest := ImageDigest{Arch: d.Arch, Digest: d.Digest}
+			if input.ImagesDir != "" {
+				if fi, err := os.Stat(filepath.Join(input.ImagesDir, fmt.Sprintf("%s.tar", d.Digest.Encoded()))); err == nil {
+					imgDigest.Size = fi.Size()
+				}
+			}
+			image.Digests = append(image.Digests, imgDigest)
+		}
+		m.Images = append(m.Images, image)
+	}
+	return m, nil
+}
+
+// Write serializes the Metadata as JSON
+func (m *Metadata) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("failed to serialize wrap metadata: %w", err)
+	}
+	return nil
+}
+
+// Read parses the Metadata from r
+func Read(r io.Reader) (*Metadata, error) {
+	m := &Metadata{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("failed to parse wrap metadata: %w", err)
+	}
+	return m, nil
+}
+
+// FromFile reads the Metadata stored in file
+func FromFile(file string) (*Metadata, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wrap metadata: %w", err)
+	}
+	defer fh.Close()
+	return Read(fh)
+}
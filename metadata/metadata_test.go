@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func sampleLock() *imagelock.ImagesLock {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Chart.Version = "1.0.0"
+	lock.Chart.AppVersion = "6.2.2"
+	lock.Images = imagelock.ImageList{
+		{
+			Name:  "wordpress",
+			Chart: "wordpress",
+			Image: "docker.io/bitnami/wordpress:6.2.2-debian-11-r11",
+			Digests: []imagelock.DigestInfo{
+				{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")},
+				{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")},
+			},
+		},
+	}
+	return lock
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	chartFile := filepath.Join(dir, "wordpress-1.0.0.tgz")
+	require.NoError(t, os.WriteFile(chartFile, []byte("hello"), 0644))
+	imagesDir := filepath.Join(dir, "images")
+	require.NoError(t, os.MkdirAll(imagesDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(imagesDir, "a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f.tar"), []byte("image"), 0644))
+
+	createdAt := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	m, err := New(&Input{
+		ChartRef: "oci://example.com/charts/wordpress", ChartVersion: ">=1.0.0 <2", ChartFile: chartFile, Lock: sampleLock(), ImagesDir: imagesDir,
+		Platforms: []string{"linux/amd64"}, ToolVersion: "1.2.3", CreatedAt: createdAt,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, Kind, m.Kind)
+	assert.Equal(t, "1.2.3", m.ToolVersion)
+	assert.Equal(t, createdAt, m.CreatedAt)
+	assert.Equal(t, Chart{
+		Name: "wordpress", Version: "1.0.0", AppVersion: "6.2.2",
+		Reference:        "oci://example.com/charts/wordpress",
+		RequestedVersion: ">=1.0.0 <2",
+		Digest:           digest.Digest("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"),
+	}, m.Chart)
+	assert.Equal(t, []string{"linux/amd64"}, m.Platforms)
+	require.Len(t, m.Images, 1)
+	assert.Equal(t, []ImageDigest{
+		{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f"), Size: 5},
+		{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")},
+	}, m.Images[0].Digests)
+
+	t.Run("Round trip", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, m.Write(buff))
+		file := filepath.Join(dir, FileName)
+		require.NoError(t, os.WriteFile(file, buff.Bytes(), 0644))
+		newMetadata, err := FromFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, m, newMetadata)
+	})
+	t.Run("Fails with missing chart files", func(t *testing.T) {
+		_, err := New(&Input{ChartRef: "chart.tgz", ChartFile: filepath.Join(dir, "missing.tgz")})
+		assert.ErrorContains(t, err, "failed to digest")
+	})
+}
@@ -0,0 +1,165 @@
+// Package objectstore implements transferring files to and from cloud object storages
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// azureAccountEnv is the environment variable holding the Azure storage account of azblob:// URLs
+const azureAccountEnv = "AZURE_STORAGE_ACCOUNT"
+
+// Client transfers files to and from an object storage using the command line tool of the provider,
+// which streams them using multipart transfers
+type Client struct {
+	// Binary is the provider command line tool
+	Binary string
+	// uploadArgs returns the Binary arguments uploading its standard input, of the given size, to dest
+	uploadArgs func(dest *url.URL, size int64) ([]string, error)
+	// downloadArgs returns the Binary arguments writing the src object to its standard output
+	downloadArgs func(src *url.URL) ([]string, error)
+}
+
+// IsURL returns true if dest is a supported object storage URL: s3://, gs:// or azblob://
+func IsURL(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "s3", "gs", "azblob":
+		return true
+	default:
+		return false
+	}
+}
+
+// New returns the Client for the object storage of the objectURL
+func New(objectURL string) (*Client, error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object storage URL %q: %w", objectURL, err)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("object storage URL %q must include the bucket and the object name", objectURL)
+	}
+	switch u.Scheme {
+	case "s3":
+		return &Client{
+			Binary: "aws",
+			// The expected size lets the AWS CLI size the parts of large uploads
+			uploadArgs: func(dest *url.URL, size int64) ([]string, error) {
+				return []string{"s3", "cp", "-", dest.String(), "--expected-size", strconv.FormatInt(size, 10)}, nil
+			},
+			downloadArgs: func(src *url.URL) ([]string, error) {
+				return []string{"s3", "cp", src.String(), "-"}, nil
+			},
+		}, nil
+	case "gs":
+		return &Client{
+			Binary: "gcloud",
+			uploadArgs: func(dest *url.URL, _ int64) ([]string, error) {
+				return []string{"storage", "cp", "-", dest.String()}, nil
+			},
+			downloadArgs: func(src *url.URL) ([]string, error) {
+				return []string{"storage", "cp", src.String(), "-"}, nil
+			},
+		}, nil
+	case "azblob":
+		return &Client{
+			Binary: "azcopy",
+			uploadArgs: func(dest *url.URL, _ int64) ([]string, error) {
+				blobURL, err := azureBlobURL(dest)
+				return []string{"copy", blobURL, "--from-to", "PipeBlob"}, err
+			},
+			downloadArgs: func(src *url.URL) ([]string, error) {
+				blobURL, err := azureBlobURL(src)
+				return []string{"copy", blobURL, "--from-to", "BlobPipe"}, err
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported object storage URL %q: only s3://, gs:// and azblob:// are supported", objectURL)
+	}
+}
+
+// azureBlobURL returns the https URL of the azblob://CONTAINER/PATH URL in u, in the storage account set
+// in the environment
+func azureBlobURL(u *url.URL) (string, error) {
+	account := os.Getenv(azureAccountEnv)
+	if account == "" {
+		return "", fmt.Errorf("the %s environment variable is required to access %q", azureAccountEnv, u)
+	}
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", account, u.Host, strings.TrimPrefix(u.Path, "/")), nil
+}
+
+// Upload streams file to the dest object storage URL
+func (c *Client) Upload(ctx context.Context, file string, dest string) error {
+	destURL, err := url.Parse(dest)
+	if err != nil {
+		return fmt.Errorf("invalid object storage URL %q: %w", dest, err)
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	args, err := c.uploadArgs(destURL, fi.Size())
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, fh, nil, args...); err != nil {
+		return fmt.Errorf("failed to upload %q to %q: %w", file, dest, err)
+	}
+	return nil
+}
+
+// Download streams the src object storage URL into w
+func (c *Client) Download(ctx context.Context, src string, w io.Writer) error {
+	srcURL, err := url.Parse(src)
+	if err != nil {
+		return fmt.Errorf("invalid object storage URL %q: %w", src, err)
+	}
+	args, err := c.downloadArgs(srcURL)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, nil, w, args...); err != nil {
+		return fmt.Errorf("failed to download %q: %w", src, err)
+	}
+	return nil
+}
+
+// run executes the Binary with the provided standard input and output
+func (c *Client) run(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Binary, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%q failed: %v: %s", c.Binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Sibling returns the URL of the object called name in the same location as the objectURL object
+func Sibling(objectURL string, name string) string {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return objectURL
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	return u.String()
+}
@@ -0,0 +1,120 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeUploader writes a script saving its arguments and standard input into dir
+func writeFakeUploader(t *testing.T, dir string) string {
+	script := filepath.Join(dir, "uploader")
+	data := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s/args\ncat > %s/data\n", dir, dir)
+	require.NoError(t, os.WriteFile(script, []byte(data), 0755))
+	return script
+}
+
+func TestIsURL(t *testing.T) {
+	for _, dest := range []string{"s3://bucket/chart.wrap.tgz", "gs://bucket/chart.wrap.tgz", "azblob://container/chart.wrap.tgz"} {
+		assert.True(t, IsURL(dest), dest)
+	}
+	for _, dest := range []string{"chart.wrap.tgz", "/tmp/chart.wrap.tgz", "oci://registry/chart", "https://example.com/chart.wrap.tgz"} {
+		assert.False(t, IsURL(dest), dest)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for dest, binary := range map[string]string{
+		"s3://bucket/chart.wrap.tgz": "aws", "gs://bucket/chart.wrap.tgz": "gcloud", "azblob://container/chart.wrap.tgz": "azcopy",
+	} {
+		u, err := New(dest)
+		require.NoError(t, err)
+		assert.Equal(t, binary, u.Binary)
+	}
+	_, err := New("ftp://host/chart.wrap.tgz")
+	assert.ErrorContains(t, err, "unsupported object storage URL")
+	_, err = New("s3://bucket/")
+	assert.ErrorContains(t, err, "must include the bucket and the object name")
+}
+
+func TestUpload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chart.wrap.tgz")
+	require.NoError(t, os.WriteFile(file, []byte("wrap contents"), 0644))
+
+	for dest, expectedArgs := range map[string]string{
+		"s3://bucket/wraps/chart.wrap.tgz":        "s3 cp - s3://bucket/wraps/chart.wrap.tgz --expected-size 13",
+		"gs://bucket/wraps/chart.wrap.tgz":        "storage cp - gs://bucket/wraps/chart.wrap.tgz",
+		"azblob://container/wraps/chart.wrap.tgz": "copy https://account.blob.core.windows.net/container/wraps/chart.wrap.tgz --from-to PipeBlob",
+	} {
+		t.Run(dest, func(t *testing.T) {
+			t.Setenv(azureAccountEnv, "account")
+			dir := t.TempDir()
+			c, err := New(dest)
+			require.NoError(t, err)
+			c.Binary = writeFakeUploader(t, dir)
+			require.NoError(t, c.Upload(context.Background(), file, dest))
+
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			require.NoError(t, err)
+			assert.Equal(t, expectedArgs, strings.TrimSpace(string(args)))
+			data, err := os.ReadFile(filepath.Join(dir, "data"))
+			require.NoError(t, err)
+			assert.Equal(t, "wrap contents", string(data))
+		})
+	}
+	t.Run("Fails without the Azure storage account", func(t *testing.T) {
+		t.Setenv(azureAccountEnv, "")
+		u, err := New("azblob://container/chart.wrap.tgz")
+		require.NoError(t, err)
+		assert.ErrorContains(t, u.Upload(context.Background(), file, "azblob://container/chart.wrap.tgz"), azureAccountEnv)
+	})
+	t.Run("Reports the tool errors", func(t *testing.T) {
+		u, err := New("s3://bucket/chart.wrap.tgz")
+		require.NoError(t, err)
+		u.Binary = "false"
+		assert.ErrorContains(t, u.Upload(context.Background(), file, "s3://bucket/chart.wrap.tgz"), "failed to upload")
+	})
+}
+
+func TestDownload(t *testing.T) {
+	for src, expectedArgs := range map[string]string{
+		"s3://bucket/wraps/chart.wrap.tgz":        "s3 cp s3://bucket/wraps/chart.wrap.tgz -",
+		"gs://bucket/wraps/chart.wrap.tgz":        "storage cp gs://bucket/wraps/chart.wrap.tgz -",
+		"azblob://container/wraps/chart.wrap.tgz": "copy https://account.blob.core.windows.net/container/wraps/chart.wrap.tgz --from-to BlobPipe",
+	} {
+		t.Run(src, func(t *testing.T) {
+			t.Setenv(azureAccountEnv, "account")
+			dir := t.TempDir()
+			script := filepath.Join(dir, "downloader")
+			data := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s/args\nprintf 'wrap contents'\n", dir)
+			require.NoError(t, os.WriteFile(script, []byte(data), 0755))
+			c, err := New(src)
+			require.NoError(t, err)
+			c.Binary = script
+
+			var buf bytes.Buffer
+			require.NoError(t, c.Download(context.Background(), src, &buf))
+			assert.Equal(t, "wrap contents", buf.String())
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			require.NoError(t, err)
+			assert.Equal(t, expectedArgs, strings.TrimSpace(string(args)))
+		})
+	}
+	c, err := New("s3://bucket/chart.wrap.tgz")
+	require.NoError(t, err)
+	c.Binary = "false"
+	assert.ErrorContains(t, c.Download(context.Background(), "s3://bucket/chart.wrap.tgz", io.Discard), "failed to download")
+}
+
+func TestSibling(t *testing.T) {
+	assert.Equal(t, "s3://bucket/wraps/chart.wrap.tgz.sig", Sibling("s3://bucket/wraps/chart.wrap.tgz", "chart.wrap.tgz.sig"))
+	assert.Equal(t, "gs://bucket/subchart-1.0.0.wrap.tgz", Sibling("gs://bucket/chart.wrap.tgz", "subchart-1.0.0.wrap.tgz"))
+}
@@ -0,0 +1,171 @@
+// Package dt provides the operations of the Distribution Tooling for Helm, so they can be embedded in other
+// Go programs without running the dt command: locking the images of a chart, verifying the lock, relocating
+// the chart, and wrapping and unwrapping it. The operations are configured with functional options
+package dt
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/internal/wrap"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// schemeRe matches URLs with a scheme
+var schemeRe = regexp.MustCompile(`^[a-z][a-z0-9+\-.]*://`)
+
+// CreateLock returns the Images.lock of the chart at chartPath, resolving the digests of its images
+func CreateLock(chartPath string, opts ...Option) (*imagelock.ImagesLock, error) {
+	cfg := NewConfig(opts...)
+	lock, err := imagelock.GenerateFromChart(chartPath, cfg.lockOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Images.lock: %w", err)
+	}
+	return lock, nil
+}
+
+// VerifyLock checks the Images.lock of the chart at chartPath matches the images the chart references
+func VerifyLock(chartPath string, opts ...Option) error {
+	cfg := NewConfig(opts...)
+	lockFile, err := wrap.LockFile(chartPath)
+	if err != nil {
+		return err
+	}
+	_, err = wrap.VerifyLock(chartPath, lockFile, cfg.lockOptions()...)
+	return err
+}
+
+// Relocate rewrites the image references of the chart directory at chartPath, including its subcharts,
+// to point to the registry and repository prefix
+func Relocate(chartPath string, prefix string, opts ...Option) error {
+	cfg := NewConfig(opts...)
+	relocateOpts := []relocator.RelocateOption{
+		relocator.Recursive,
+		relocator.WithAnnotationsKey(cfg.AnnotationsKey),
+	}
+	if cfg.RepositoryStrategy != "" {
+		relocateOpts = append(relocateOpts, relocator.WithRepositoryStrategy(cfg.RepositoryStrategy))
+	}
+	if err := relocator.RelocateChartDir(chartPath, prefix, relocateOpts...); err != nil {
+		return fmt.Errorf("failed to relocate Helm chart: %w", err)
+	}
+	return nil
+}
+
+// Wrap wraps the chart directory at chartPath into outputFile, returning the path of the wrap. The chart is
+// copied into a temporary directory first, where its Images.lock is verified or, if missing, created, its
+// images are pulled and the wrap metadata is written, so the chart directory is not modified. If outputFile
+// is empty, the wrap is written as NAME-VERSION.wrap.tgz in the current directory
+func Wrap(chartPath string, outputFile string, opts ...Option) (string, error) {
+	cfg := NewConfig(opts...)
+	startedOn := time.Now()
+	dir, err := os.MkdirTemp(cfg.TempDir, "dt-wrap-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	stagedPath, err := wrap.Stage(chartPath, dir)
+	if err != nil {
+		return "", err
+	}
+	chart, err := chartutils.LoadChart(stagedPath, chartutils.WithAnnotationsKey(cfg.AnnotationsKey))
+	if err != nil {
+		return "", err
+	}
+	lock, err := wrap.EnsureLock(chart.RootDir(), cfg.lockOptions()...)
+	if err != nil {
+		return "", err
+	}
+	if err := chartutils.PullImages(lock, chart.ImagesDir(), cfg.transferOptions()...); err != nil {
+		return "", fmt.Errorf("failed to pull images: %w", err)
+	}
+	if _, err := wrap.WriteMetadata(chart, &metadata.Input{
+		ChartRef: chartPath, Lock: lock, ImagesDir: chart.ImagesDir(), Platforms: cfg.Platforms,
+		ToolVersion: cfg.ToolVersion, CreatedAt: startedOn,
+	}); err != nil {
+		return "", fmt.Errorf("failed to write wrap metadata: %w", err)
+	}
+	if err := utils.WriteChecksums(chart.RootDir(), utils.ChecksumsFileName); err != nil {
+		return "", fmt.Errorf("failed to write checksums: %w", err)
+	}
+	if outputFile == "" {
+		outputFile = wrap.DefaultFileName(chart)
+	}
+	if err := wrap.Compress(cfg.Context, chart, outputFile); err != nil {
+		return "", fmt.Errorf("failed to compress wrap: %w", err)
+	}
+	return outputFile, nil
+}
+
+// Unwrap relocates the wrap, either a file or an uncompressed directory, to registryURL, pushes its images
+// and Helm chart there and returns the URL of the pushed chart. Uncompressed directories are copied into a
+// temporary directory first, so they are not modified
+func Unwrap(wrapPath string, registryURL string, opts ...Option) (string, error) {
+	cfg := NewConfig(opts...)
+	dir, err := os.MkdirTemp(cfg.TempDir, "dt-unwrap-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	var chartPath string
+	if isDir(wrapPath) {
+		chartPath, err = wrap.Stage(wrapPath, dir)
+	} else if chartPath, err = wrap.Extract(cfg.Context, wrapPath, dir); err != nil {
+		err = fmt.Errorf("failed to uncompress wrap: %w", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	registryURL = schemeRe.ReplaceAllString(registryURL, "")
+	if err := Relocate(chartPath, registryURL, opts...); err != nil {
+		return "", err
+	}
+	chart, err := chartutils.LoadChart(chartPath, chartutils.WithAnnotationsKey(cfg.AnnotationsKey))
+	if err != nil {
+		return "", err
+	}
+	if _, err := wrap.PushImages(chart.RootDir(), cfg.transferOptions()...); err != nil {
+		return "", fmt.Errorf("failed to push images: %w", err)
+	}
+	if err := VerifyLock(chart.RootDir(), opts...); err != nil {
+		return "", fmt.Errorf("failed to verify the pushed images: %w", err)
+	}
+	chartURL := "oci://" + registryURL
+	if err := pushChart(chart, chartURL, cfg); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", chartURL, chart.Name()), nil
+}
+
+// pushChart packages chart and pushes it to the OCI chartURL with the configured credentials, retrying on error
+func pushChart(chart *chartutils.Chart, chartURL string, cfg *Config) error {
+	dir, err := os.MkdirTemp(cfg.TempDir, "dt-chart-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	tarFile, err := wrap.PackageChart(chart, dir)
+	if err != nil {
+		return err
+	}
+	// Helm is given the credentials of the keychain, so the chart is pushed as the images are
+	credentialsFile, err := utils.WriteRegistryCredentialsFile(dir, chartURL, cfg.Keychain)
+	if err != nil {
+		return err
+	}
+	return utils.ExecuteWithRetry(cfg.MaxRetries, func(int, error) error {
+		return utils.PushChart(tarFile, chartURL, utils.PushConfig{CredentialsFile: credentialsFile, Transport: cfg.Transport})
+	})
+}
+
+// isDir returns true if path is an existing directory
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
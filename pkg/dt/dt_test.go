@@ -0,0 +1,149 @@
+package dt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/metadata"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+func TestWrapAndUnwrap(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	// The pushed images are verified, so they must define their platform
+	imageData := tu.ImageData{Name: "test", Image: "test:mytag"}
+	platforms := []string{"linux/amd64", "linux/arm64"}
+	craneImages, err := tu.CreateSampleImages(&imageData, platforms)
+	require.NoError(t, err)
+	var idx v1.ImageIndex = empty.Index
+	for i, img := range craneImages {
+		plat := strings.Split(platforms[i], "/")
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: plat[0], Architecture: plat[1]}},
+		})
+	}
+	ref, err := name.ParseReference(fmt.Sprintf("%s/%s", u.Host, imageData.Image))
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+	images := []tu.ImageData{imageData}
+
+	dest := t.TempDir()
+	scenarioDir := "../../testdata/scenarios/complete-chart"
+	require.NoError(t, tu.RenderScenario(scenarioDir, dest,
+		map[string]interface{}{"ServerURL": u.Host, "Images": images, "Name": "test", "Version": "1.0.0", "RepositoryURL": u.Host},
+	))
+	chartDir := filepath.Join(dest, "complete-chart")
+	require.NoError(t, os.RemoveAll(filepath.Join(chartDir, "Images.lock")))
+
+	lock, err := CreateLock(chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "test", lock.Chart.Name)
+	assert.NotEmpty(t, lock.Images)
+
+	outputFile := filepath.Join(t.TempDir(), "test.wrap.tgz")
+	wrapFile, err := Wrap(chartDir, outputFile, WithTempDir(t.TempDir()), WithToolVersion("1.2.3"))
+	require.NoError(t, err)
+	assert.Equal(t, outputFile, wrapFile)
+	// The chart directory is not modified
+	assert.NoFileExists(t, filepath.Join(chartDir, "Images.lock"))
+	assert.NoDirExists(t, filepath.Join(chartDir, "images"))
+	assert.NoFileExists(t, filepath.Join(chartDir, metadata.FileName))
+
+	wrapDir := t.TempDir()
+	require.NoError(t, utils.Untar(wrapFile, wrapDir, utils.TarConfig{StripComponents: 1}))
+	require.NoError(t, VerifyLock(wrapDir))
+	require.NoError(t, utils.VerifyChecksums(wrapDir, utils.ChecksumsFileName))
+	m, err := metadata.FromFile(filepath.Join(wrapDir, metadata.FileName))
+	require.NoError(t, err)
+	assert.Equal(t, "test", m.Chart.Name)
+	assert.Equal(t, "1.2.3", m.ToolVersion)
+	assert.Len(t, m.Images, len(lock.Images))
+
+	targetRegistry := fmt.Sprintf("%s/new-images", u.Host)
+	chartURL, err := Unwrap(wrapFile, targetRegistry, WithTempDir(t.TempDir()))
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("oci://%s/test", targetRegistry), chartURL)
+
+	for _, img := range images {
+		data, err := crane.Manifest(fmt.Sprintf("%s/%s", targetRegistry, img.Image))
+		require.NoError(t, err)
+		im, err := v1.ParseIndexManifest(bytes.NewReader(data))
+		require.NoError(t, err)
+		pushed := make([]string, 0)
+		for _, m := range im.Manifests {
+			pushed = append(pushed, m.Digest.String())
+		}
+		for _, dgstData := range img.Digests {
+			assert.Contains(t, pushed, dgstData.Digest.String())
+		}
+	}
+	assert.True(t, utils.RemoteChartExist(chartURL, "1.0.0"), "chart should exist in the repository")
+
+	// Uncompressed wraps are relocated in a copy
+	lockBefore, err := os.ReadFile(filepath.Join(wrapDir, "Images.lock"))
+	require.NoError(t, err)
+	_, err = Unwrap(wrapDir, fmt.Sprintf("%s/from-dir", u.Host), WithTempDir(t.TempDir()))
+	require.NoError(t, err)
+	lockAfter, err := os.ReadFile(filepath.Join(wrapDir, "Images.lock"))
+	require.NoError(t, err)
+	assert.Equal(t, string(lockBefore), string(lockAfter))
+	t.Run("Unwraps into an authenticated registry with the keychain credentials", func(t *testing.T) {
+		auth := authn.AuthConfig{Username: "admin", Password: "s3cr3t"}
+		reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+		authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, pass, ok := r.BasicAuth(); !ok || user != auth.Username || pass != auth.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			reg.ServeHTTP(w, r)
+		}))
+		defer authServer.Close()
+		au, err := url.Parse(authServer.URL)
+		require.NoError(t, err)
+		targetRegistry := fmt.Sprintf("%s/private", au.Host)
+
+		_, err = Unwrap(wrapFile, targetRegistry, WithTempDir(t.TempDir()), WithMaxRetries(0))
+		require.Error(t, err)
+
+		chartURL, err := Unwrap(wrapFile, targetRegistry, WithTempDir(t.TempDir()), WithKeychain(staticKeychain{auth}))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("oci://%s/test", targetRegistry), chartURL)
+		_, err = crane.Manifest(fmt.Sprintf("%s/test:1.0.0", targetRegistry), crane.WithAuth(authn.FromConfig(auth)))
+		require.NoError(t, err, "chart should exist in the repository")
+	})
+}
+
+// staticKeychain resolves the same credentials for every registry
+type staticKeychain struct {
+	auth authn.AuthConfig
+}
+
+// Resolve implements authn.Keychain
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return authn.FromConfig(k.auth), nil
+}
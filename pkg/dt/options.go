@@ -0,0 +1,146 @@
+package dt
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/relocator"
+)
+
+// Config defines the settings of the operations
+type Config struct {
+	// Context controls the cancellation of the operations
+	Context context.Context
+	// AnnotationsKey is the Chart.yaml annotation listing the images of the charts
+	AnnotationsKey string
+	// Platforms, if not empty, are the only platforms of the images locked and pulled
+	Platforms []string
+	// Keychain resolves the credentials used to access the registries
+	Keychain authn.Keychain
+	// Transport, if not nil, is the HTTP transport used to access the registries
+	Transport http.RoundTripper
+	// MaxRetries is the number of times pulling or pushing an image or chart is retried on error
+	MaxRetries int
+	// RepositoryStrategy defines how the source repositories are mapped into the relocation prefix
+	RepositoryStrategy relocator.RepositoryStrategy
+	// ImageEventHandler receives the progress events when pulling and pushing images
+	ImageEventHandler chartutils.ImageEventHandler
+	// TempDir, if not empty, is the directory the temporary files are created in, instead of the default one
+	TempDir string
+	// ToolVersion is the version of the program embedding the package, recorded in the wrap metadata
+	ToolVersion string
+}
+
+// NewConfig returns a new Config with default values
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{
+		Context:           context.Background(),
+		AnnotationsKey:    imagelock.DefaultAnnotationsKey,
+		Keychain:          authn.DefaultKeychain,
+		MaxRetries:        3,
+		ImageEventHandler: func(chartutils.ImageEvent) {},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Option defines a Config option
+type Option func(*Config)
+
+// WithContext provides the context controlling the cancellation of the operations
+func WithContext(ctx context.Context) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Context = ctx
+	}
+}
+
+// WithAnnotationsKey sets the Chart.yaml annotation listing the images of the charts
+func WithAnnotationsKey(key string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.AnnotationsKey = key
+	}
+}
+
+// WithPlatforms restricts the platforms of the images locked and pulled
+func WithPlatforms(platforms []string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Platforms = platforms
+	}
+}
+
+// WithKeychain sets the keychain used to access the registries
+func WithKeychain(kc authn.Keychain) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Keychain = kc
+	}
+}
+
+// WithTransport sets the HTTP transport used to access the registries
+func WithTransport(tr http.RoundTripper) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Transport = tr
+	}
+}
+
+// WithMaxRetries sets the number of times pulling or pushing an image or chart is retried on error
+func WithMaxRetries(retries int) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.MaxRetries = retries
+	}
+}
+
+// WithRepositoryStrategy sets how the source repositories are mapped into the relocation prefix
+func WithRepositoryStrategy(strategy relocator.RepositoryStrategy) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.RepositoryStrategy = strategy
+	}
+}
+
+// WithImageEventHandler sets the handler receiving the progress events when pulling and pushing images
+func WithImageEventHandler(h chartutils.ImageEventHandler) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.ImageEventHandler = h
+	}
+}
+
+// WithTempDir sets the directory the temporary files are created in
+func WithTempDir(dir string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.TempDir = dir
+	}
+}
+
+// WithToolVersion sets the version of the program embedding the package, recorded in the wrap metadata
+func WithToolVersion(version string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.ToolVersion = version
+	}
+}
+
+// lockOptions returns the imagelock options of the configuration
+func (cfg *Config) lockOptions() []imagelock.Option {
+	return []imagelock.Option{
+		imagelock.WithContext(cfg.Context),
+		imagelock.WithAnnotationsKey(cfg.AnnotationsKey),
+		imagelock.WithPlatforms(cfg.Platforms),
+		imagelock.WithKeychain(cfg.Keychain),
+		imagelock.WithTransport(cfg.Transport),
+	}
+}
+
+// transferOptions returns the chartutils options used to pull and push images
+func (cfg *Config) transferOptions() []chartutils.Option {
+	return []chartutils.Option{
+		chartutils.WithContext(cfg.Context),
+		chartutils.WithAnnotationsKey(cfg.AnnotationsKey),
+		chartutils.WithKeychain(cfg.Keychain),
+		chartutils.WithTransport(cfg.Transport),
+		chartutils.WithMaxRetries(cfg.MaxRetries),
+		chartutils.WithImageEventHandler(cfg.ImageEventHandler),
+	}
+}
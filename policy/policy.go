@@ -0,0 +1,199 @@
+// Package policy implements the evaluation of Rego policies against the
+// container images included in a Helm chart
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// DefaultQuery is the query evaluated against every image. Policies are expected to
+// define "deny" rules in the "dt" package, returning the violation messages
+const DefaultQuery = "data.dt.deny"
+
+// Digest defines the digest of an image for a platform
+type Digest struct {
+	Digest   string            `json:"digest"`
+	Platform string            `json:"platform"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// Image defines the image information made available to policies as input.image
+type Image struct {
+	Name       string   `json:"name"`
+	Chart      string   `json:"chart"`
+	Reference  string   `json:"reference"`
+	Registry   string   `json:"registry"`
+	Repository string   `json:"repository"`
+	Tag        string   `json:"tag"`
+	Platforms  []string `json:"platforms"`
+	Digests    []Digest `json:"digests"`
+	// Labels contains the labels found in any of the image platforms
+	Labels map[string]string `json:"labels"`
+}
+
+// Chart defines the chart information made available to policies as input.chart
+type Chart struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion"`
+}
+
+// Input defines the input document evaluated for every image
+type Input struct {
+	Chart Chart `json:"chart"`
+	Image Image `json:"image"`
+}
+
+// Violation defines a policy violation for an image
+type Violation struct {
+	Image   string
+	Message string
+}
+
+// String returns the text representation of the Violation
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Image, v.Message)
+}
+
+// LabelsFunc returns the labels of an image digest
+type LabelsFunc func(img *imagelock.ChartImage, digest imagelock.DigestInfo) (map[string]string, error)
+
+// NewInputs returns the list of policy inputs, one per image in the lock. The labels function
+// is optional, and used to populate the images labels
+func NewInputs(lock *imagelock.ImagesLock, labelsFn LabelsFunc) ([]*Input, error) {
+	chart := Chart{Name: lock.Chart.Name, Version: lock.Chart.Version, AppVersion: lock.Chart.AppVersion}
+	inputs := make([]*Input, 0, len(lock.Images))
+	for _, img := range lock.Images {
+		image := Image{
+			Name: img.Name, Chart: img.Chart, Reference: img.Image,
+			Platforms: make([]string, 0), Digests: make([]Digest, 0), Labels: make(map[string]string),
+		}
+		if ref, err := name.ParseReference(img.Image); err == nil {
+			image.Registry = ref.Context().RegistryStr()
+			image.Repository = ref.Context().RepositoryStr()
+			if t, ok := ref.(name.Tag); ok {
+				image.Tag = t.TagStr()
+			}
+		}
+		for _, d := range img.Digests {
+			labels := make(map[string]string)
+			if labelsFn != nil {
+				l, err := labelsFn(img, d)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read labels of image %q (%s): %w", img.Image, d.Arch, err)
+				}
+				labels = l
+			}
+			for k, v := range labels {
+				image.Labels[k] = v
+			}
+			image.Platforms = append(image.Platforms, d.Arch)
+			image.Digests = append(image.Digests, Digest{Digest: d.Digest.String(), Platform: d.Arch, Labels: labels})
+		}
+		inputs = append(inputs, &Input{Chart: chart, Image: image})
+	}
+	return inputs, nil
+}
+
+// Engine evaluates a set of Rego policies
+type Engine struct {
+	query rego.PreparedEvalQuery
+}
+
+// regoFiles returns the list of .rego files found in paths, which may be files or directories
+func regoFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access policy %q: %w", p, err)
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, ".rego") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policies in %q: %w", p, err)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no policy files found")
+	}
+	return files, nil
+}
+
+// Load returns a new Engine evaluating the Rego policies found in paths
+func Load(ctx context.Context, paths ...string) (*Engine, error) {
+	files, err := regoFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	q, err := rego.New(rego.Query(DefaultQuery), rego.Load(files, nil)).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	return &Engine{query: q}, nil
+}
+
+// Evaluate evaluates the policies against the provided inputs and returns the list of violations
+func (e *Engine) Evaluate(ctx context.Context, inputs []*Input) ([]Violation, error) {
+	violations := make([]Violation, 0)
+	for _, input := range inputs {
+		rs, err := e.query.Eval(ctx, rego.EvalInput(input))
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate policies for image %q: %w", input.Image.Reference, err)
+		}
+		messages := make([]string, 0)
+		for _, r := range rs {
+			for _, expr := range r.Expressions {
+				msgs, err := denyMessages(expr.Value)
+				if err != nil {
+					return nil, fmt.Errorf("failed to evaluate policies for image %q: %w", input.Image.Reference, err)
+				}
+				messages = append(messages, msgs...)
+			}
+		}
+		sort.Strings(messages)
+		for _, msg := range messages {
+			violations = append(violations, Violation{Image: input.Image.Reference, Message: msg})
+		}
+	}
+	return violations, nil
+}
+
+// denyMessages converts the result of a deny rule, either a set of strings
+// or an object with string keys, into a list of messages
+func denyMessages(value interface{}) ([]string, error) {
+	messages := make([]string, 0)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			messages = append(messages, fmt.Sprint(elem))
+		}
+	case map[string]interface{}:
+		for k := range v {
+			messages = append(messages, k)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected deny result of type %T", value)
+	}
+	return messages, nil
+}
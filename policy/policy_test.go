@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func sampleLock() *imagelock.ImagesLock {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Chart.Version = "1.0.0"
+	lock.Images = imagelock.ImageList{
+		{
+			Name:  "wordpress",
+			Chart: "wordpress",
+			Image: "docker.io/bitnami/wordpress:6.2.2-debian-11-r11",
+			Digests: []imagelock.DigestInfo{
+				{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")},
+				{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")},
+			},
+		},
+		{
+			Name:  "bitnami-shell",
+			Chart: "wordpress",
+			Image: "docker.io/bitnami/bitnami-shell:latest",
+			Digests: []imagelock.DigestInfo{
+				{Arch: "linux/amd64", Digest: digest.Digest("sha256:10f8fa2c8f2f3bd8b9a1c4d0a2d6e7f1e5a0ff0e6a8a4c6b2d7e1f3a5b9c8d7e")},
+			},
+		},
+	}
+	return lock
+}
+
+func writePolicy(t *testing.T, content string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policy.rego"), []byte(content), 0644))
+	return dir
+}
+
+func TestNewInputs(t *testing.T) {
+	inputs, err := NewInputs(sampleLock(), func(img *imagelock.ChartImage, d imagelock.DigestInfo) (map[string]string, error) {
+		return map[string]string{"arch": d.Arch}, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, inputs, 2)
+
+	img := inputs[0].Image
+	assert.Equal(t, "wordpress", inputs[0].Chart.Name)
+	assert.Equal(t, "index.docker.io", img.Registry)
+	assert.Equal(t, "bitnami/wordpress", img.Repository)
+	assert.Equal(t, "6.2.2-debian-11-r11", img.Tag)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, img.Platforms)
+	assert.Equal(t, map[string]string{"arch": "linux/arm64"}, img.Digests[1].Labels)
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	inputs, err := NewInputs(sampleLock(), nil)
+	require.NoError(t, err)
+
+	t.Run("Reports violations", func(t *testing.T) {
+		engine, err := Load(ctx, writePolicy(t, `package dt
+
+deny[msg] {
+	input.image.tag == "latest"
+	msg := sprintf("image %s uses the latest tag", [input.image.name])
+}
+
+deny[msg] {
+	count(input.image.platforms) < 2
+	msg := "image must support at least two platforms"
+}
+`))
+		require.NoError(t, err)
+		violations, err := engine.Evaluate(ctx, inputs)
+		require.NoError(t, err)
+		assert.Equal(t, []Violation{
+			{Image: "docker.io/bitnami/bitnami-shell:latest", Message: "image bitnami-shell uses the latest tag"},
+			{Image: "docker.io/bitnami/bitnami-shell:latest", Message: "image must support at least two platforms"},
+		}, violations)
+	})
+	t.Run("No violations", func(t *testing.T) {
+		engine, err := Load(ctx, writePolicy(t, "package dt\n\ndeny[msg] { input.image.registry == \"quay.io\"; msg := \"forbidden\" }\n"))
+		require.NoError(t, err)
+		violations, err := engine.Evaluate(ctx, inputs)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+	t.Run("Invalid policies", func(t *testing.T) {
+		_, err := Load(ctx, writePolicy(t, "package dt\n\ndeny[msg] {"))
+		assert.ErrorContains(t, err, "failed to load policies")
+	})
+	t.Run("Missing policies", func(t *testing.T) {
+		_, err := Load(ctx, t.TempDir())
+		assert.ErrorContains(t, err, "no policy files found")
+	})
+}
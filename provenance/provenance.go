@@ -0,0 +1,176 @@
+// Package provenance implements the generation of in-toto SLSA provenance statements
+// describing how a wrapped Helm chart was produced
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+const (
+	// StatementType is the in-toto statement type
+	StatementType = "https://in-toto.io/Statement/v1"
+	// PredicateType is the SLSA provenance predicate type
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType identifies the wrap process
+	BuildType = "https://github.com/vmware-labs/distribution-tooling-for-helm/wrap@v1"
+	// BuilderID identifies the tool producing the wrap
+	BuilderID = "https://github.com/vmware-labs/distribution-tooling-for-helm"
+)
+
+// DigestSet maps digest algorithms to their values
+type DigestSet map[string]string
+
+// ResourceDescriptor describes an artifact consumed or produced by the wrap
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      DigestSet         `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Predicate defines the SLSA provenance v1 predicate
+type Predicate struct {
+	BuildDefinition struct {
+		BuildType            string                 `json:"buildType"`
+		ExternalParameters   map[string]interface{} `json:"externalParameters"`
+		ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  string `json:"startedOn,omitempty"`
+			FinishedOn string `json:"finishedOn,omitempty"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// Statement defines an in-toto statement carrying a SLSA provenance predicate
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Predicate            `json:"predicate"`
+}
+
+// Input defines the information about the wrap process recorded in the provenance
+type Input struct {
+	// ChartRef is the chart reference provided to the wrap command (path, tarball or OCI URI)
+	ChartRef string
+	// ChartFile, if not empty, points to a chart tarball used as input, which is digested
+	ChartFile string
+	// LockFile is the Images.lock used to pull the images
+	LockFile string
+	// Lock is the parsed Images.lock
+	Lock *imagelock.ImagesLock
+	// Platforms lists the platforms requested
+	Platforms []string
+	// OutputFile is the wrap tarball produced
+	OutputFile string
+	// ToolVersion is the version of the tool
+	ToolVersion string
+	// StartedOn and FinishedOn define when the wrap process started and ended
+	StartedOn  time.Time
+	FinishedOn time.Time
+}
+
+// fileSHA256 returns the hex encoded sha256 digest of the file
+func fileSHA256(file string) (string, error) {
+	dgst, err := utils.FileSHA256(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to digest %q: %w", file, err)
+	}
+	return dgst, nil
+}
+
+// New returns a new Statement describing the wrap process
+func New(input *Input) (*Statement, error) {
+	outputDigest, err := fileSHA256(input.OutputFile)
+	if err != nil {
+		return nil, err
+	}
+	st := &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Subject: []ResourceDescriptor{
+			{Name: filepath.Base(input.OutputFile), Digest: DigestSet{"sha256": outputDigest}},
+		},
+	}
+	p := &st.Predicate
+	p.BuildDefinition.BuildType = BuildType
+	platforms := input.Platforms
+	if platforms == nil {
+		platforms = make([]string, 0)
+	}
+	p.BuildDefinition.ExternalParameters = map[string]interface{}{
+		"chart":     input.ChartRef,
+		"platforms": platforms,
+	}
+
+	deps := make([]ResourceDescriptor, 0)
+	chartDep := ResourceDescriptor{URI: input.ChartRef}
+	if input.Lock != nil {
+		chartDep.Name = input.Lock.Chart.Name
+		chartDep.Annotations = map[string]string{"version": input.Lock.Chart.Version}
+	}
+	if input.ChartFile != "" {
+		dgst, err := fileSHA256(input.ChartFile)
+		if err != nil {
+			return nil, err
+		}
+		chartDep.Digest = DigestSet{"sha256": dgst}
+	}
+	deps = append(deps, chartDep)
+
+	if input.LockFile != "" {
+		dgst, err := fileSHA256(input.LockFile)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, ResourceDescriptor{Name: imagelock.DefaultImagesLockFileName, Digest: DigestSet{"sha256": dgst}})
+	}
+	if input.Lock != nil {
+		for _, img := range input.Lock.Images {
+			for _, d := range img.Digests {
+				deps = append(deps, ResourceDescriptor{
+					Name:        img.Name,
+					URI:         img.Image,
+					Digest:      DigestSet{d.Digest.Algorithm().String(): d.Digest.Encoded()},
+					Annotations: map[string]string{"platform": d.Arch, "chart": img.Chart},
+				})
+			}
+		}
+	}
+	p.BuildDefinition.ResolvedDependencies = deps
+
+	p.RunDetails.Builder.ID = BuilderID
+	if input.ToolVersion != "" {
+		p.RunDetails.Builder.Version = map[string]string{"dt": input.ToolVersion}
+	}
+	if !input.StartedOn.IsZero() {
+		p.RunDetails.Metadata.StartedOn = input.StartedOn.UTC().Format(time.RFC3339)
+	}
+	if !input.FinishedOn.IsZero() {
+		p.RunDetails.Metadata.FinishedOn = input.FinishedOn.UTC().Format(time.RFC3339)
+	}
+	return st, nil
+}
+
+// Write serializes the Statement as JSON
+func (st *Statement) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(st); err != nil {
+		return fmt.Errorf("failed to serialize provenance: %w", err)
+	}
+	return nil
+}
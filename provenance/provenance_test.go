@@ -0,0 +1,89 @@
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func writeFile(t *testing.T, name string, data string) string {
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, []byte(data), 0644))
+	return file
+}
+
+func TestNew(t *testing.T) {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Chart.Version = "1.0.0"
+	lock.Images = imagelock.ImageList{{
+		Name: "wordpress", Chart: "wordpress", Image: "docker.io/bitnami/wordpress:6.2.2",
+		Digests: []imagelock.DigestInfo{
+			{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")},
+		},
+	}}
+	input := &Input{
+		ChartRef:    "oci://docker.io/bitnamicharts/wordpress",
+		LockFile:    writeFile(t, "Images.lock", "lock"),
+		Lock:        lock,
+		OutputFile:  writeFile(t, "wordpress-1.0.0.wrap.tgz", "wrap"),
+		ToolVersion: "1.2.3",
+		StartedOn:   time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC),
+		FinishedOn:  time.Date(2023, 8, 1, 10, 5, 0, 0, time.UTC),
+	}
+	st, err := New(input)
+	require.NoError(t, err)
+
+	buff := &bytes.Buffer{}
+	require.NoError(t, st.Write(buff))
+	decoded := &Statement{}
+	require.NoError(t, json.Unmarshal(buff.Bytes(), decoded))
+
+	assert.Equal(t, StatementType, decoded.Type)
+	assert.Equal(t, PredicateType, decoded.PredicateType)
+	// sha256 of "wrap"
+	assert.Equal(t, []ResourceDescriptor{{
+		Name:   "wordpress-1.0.0.wrap.tgz",
+		Digest: DigestSet{"sha256": "f0a289923ed634acec748941a7fab6a057e5d4a5cb29e5e2b6136d639897c74a"},
+	}}, decoded.Subject)
+
+	deps := decoded.Predicate.BuildDefinition.ResolvedDependencies
+	require.Len(t, deps, 3)
+	assert.Equal(t, "oci://docker.io/bitnamicharts/wordpress", deps[0].URI)
+	assert.Equal(t, "1.0.0", deps[0].Annotations["version"])
+	assert.Equal(t, imagelock.DefaultImagesLockFileName, deps[1].Name)
+	assert.Equal(t, DigestSet{"sha256": "a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f"}, deps[2].Digest)
+	assert.Equal(t, "linux/amd64", deps[2].Annotations["platform"])
+	assert.Equal(t, "2023-08-01T10:05:00Z", decoded.Predicate.RunDetails.Metadata.FinishedOn)
+	assert.Equal(t, "1.2.3", decoded.Predicate.RunDetails.Builder.Version["dt"])
+
+	_, err = New(&Input{OutputFile: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorContains(t, err, "failed to digest")
+}
+
+func TestSign(t *testing.T) {
+	binDir := t.TempDir()
+	cosign := filepath.Join(binDir, "cosign")
+	// Writes the received arguments as the signature
+	require.NoError(t, os.WriteFile(cosign, []byte("#!/bin/sh\nwhile [ \"$1\" != \"--output-signature\" ]; do shift; done\necho signed > \"$2\"\n"), 0755))
+
+	file := writeFile(t, "statement.json", "{}")
+	signer := NewSigner("cosign.key")
+	signer.Binary = cosign
+	require.NoError(t, signer.Sign(context.Background(), file, file+".sig"))
+	data, err := os.ReadFile(file + ".sig")
+	require.NoError(t, err)
+	assert.Equal(t, "signed\n", string(data))
+
+	signer.Binary = filepath.Join(binDir, "missing")
+	assert.ErrorContains(t, signer.Sign(context.Background(), file, file+".sig"), "failed to execute")
+}
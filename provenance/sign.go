@@ -0,0 +1,32 @@
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Signer signs provenance statements using the cosign command line tool
+type Signer struct {
+	Binary string
+	// Key is the cosign key reference (file path, KMS URI...)
+	Key string
+}
+
+// NewSigner returns a new Signer using the provided cosign key
+func NewSigner(key string) *Signer {
+	return &Signer{Binary: "cosign", Key: key}
+}
+
+// Sign writes a detached signature of file into signatureFile
+func (s *Signer) Sign(ctx context.Context, file string, signatureFile string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Binary, "sign-blob", "--yes", "--key", s.Key, "--output-signature", signatureFile, file)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to execute %q: %v: %s", s.Binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -13,19 +13,19 @@ func RelocateAnnotations(chartDir string, prefix string, opts ...chartutils.Opti
 	if err != nil {
 		return "", fmt.Errorf("failed to relocate annotations: %w", err)
 	}
-	res, err := relocateAnnotations(c, prefix)
+	res, err := relocateAnnotations(c, relocation{prefix: prefix})
 	if err != nil {
 		return "", fmt.Errorf("failed to relocate annotations: %w", err)
 	}
 	return string(res.Data), nil
 }
 
-func relocateAnnotations(c *cu.Chart, prefix string) (*RelocationResult, error) {
+func relocateAnnotations(c *cu.Chart, r relocation) (*RelocationResult, error) {
 	images, err := c.GetAnnotatedImages()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read images from annotations: %v", err)
 	}
-	count, err := relocateImages(images, prefix)
+	count, err := relocateImages(images, r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to relocate annotations: %v", err)
 	}
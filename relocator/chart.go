@@ -21,8 +21,14 @@ type RelocationResult struct {
 	Count int
 }
 
-func relocateChart(chart *cu.Chart, prefix string, cfg *RelocateConfig) error {
-	valuesReplRes, err := relocateValues(chart, prefix)
+func relocateChart(chart *cu.Chart, r relocation, cfg *RelocateConfig, parentImages ...*imagelock.ChartImage) error {
+	// Invalid annotations are reported when relocating them
+	images, _ := chart.GetAnnotatedImages()
+	r, err := r.withDestinations(append(images, parentImages...))
+	if err != nil {
+		return err
+	}
+	valuesReplRes, err := relocateValues(chart, r, cfg.Digests, parentImages...)
 	if err != nil {
 		return fmt.Errorf("failed to relocate values.yaml: %v", err)
 	}
@@ -31,16 +37,21 @@ func relocateChart(chart *cu.Chart, prefix string, cfg *RelocateConfig) error {
 			return fmt.Errorf("failed to write values.yaml: %v", err)
 		}
 	}
+	filesCount, err := relocateFiles(chart, r, cfg.Files, cfg.Digests, parentImages...)
+	if err != nil {
+		return fmt.Errorf("failed to relocate chart files: %v", err)
+	}
+	cfg.Log.Debugf("Relocated %d image references in the %q chart files", filesCount, chart.Name())
 
 	var allErrors error
 
 	// TODO: Compare annotations with values replacements
-	annotationsRelocResult, err := relocateAnnotations(chart, prefix)
+	annotationsRelocResult, err := relocateAnnotations(chart, r)
 	if err != nil {
 		allErrors = errors.Join(allErrors, fmt.Errorf("failed to relocate Helm chart: %v", err))
 	} else {
 		if annotationsRelocResult.Count > 0 {
-			annotationsKeyPath := fmt.Sprintf("$.annotations['%s']", cfg.ImageLockConfig.AnnotationsKey)
+			annotationsKeyPath := fmt.Sprintf("$.annotations['%s']", chart.AnnotationsKey())
 			if err := utils.YamlFileSet(chart.AbsFilePath("Chart.yaml"), map[string]string{
 				annotationsKeyPath: string(annotationsRelocResult.Data),
 			}); err != nil {
@@ -51,7 +62,7 @@ func relocateChart(chart *cu.Chart, prefix string, cfg *RelocateConfig) error {
 
 	lockFile := chart.AbsFilePath(imagelock.DefaultImagesLockFileName)
 	if utils.FileExists(lockFile) {
-		err = RelocateLockFile(lockFile, prefix)
+		err = relocateLockFile(lockFile, r)
 		if err != nil {
 			allErrors = errors.Join(allErrors, fmt.Errorf("failed to relocate Images.lock file: %v", err))
 		}
@@ -61,10 +72,9 @@ func relocateChart(chart *cu.Chart, prefix string, cfg *RelocateConfig) error {
 }
 
 // RelocateChartDir relocates the chart (Chart.yaml annotations, Images.lock and values.yaml) specified
-// by chartPath using the provided prefix
+// by chartPath using the provided prefix. If Recursive, the subcharts under charts/, including the packaged ones,
+// are relocated too
 func RelocateChartDir(chartPath string, prefix string, opts ...RelocateOption) error {
-	prefix = normalizeRelocateURL(prefix)
-
 	cfg := NewRelocateConfig()
 	for _, opt := range opts {
 		opt(cfg)
@@ -73,22 +83,24 @@ func RelocateChartDir(chartPath string, prefix string, opts ...RelocateOption) e
 	if err != nil {
 		return fmt.Errorf("failed to load Helm chart: %v", err)
 	}
-
-	err = relocateChart(chart, prefix, cfg)
+	// The subcharts images are tagged after the root chart, as they are distributed with it
+	r, err := newRelocation(prefix, cfg, chart.Name(), chart.Metadata.Version)
 	if err != nil {
 		return err
 	}
 
-	var allErrors error
+	// Read before relocating them, to find the values referencing them in the subcharts
+	images, _ := chart.GetAnnotatedImages()
+
+	err = relocateChart(chart, r, cfg)
+	if err != nil {
+		return err
+	}
 
 	if cfg.Recursive {
-		for _, dep := range chart.Dependencies() {
-			if err := relocateChart(dep, prefix, cfg); err != nil {
-				allErrors = errors.Join(allErrors, fmt.Errorf("failed to reloacte Helm SubChart %q: %v", dep.ChartFullPath(), err))
-			}
-		}
+		return relocateDependencies(chart.RootDir(), r, cfg, images...)
 	}
-	return allErrors
+	return nil
 }
 
 func normalizeRelocateURL(url string) string {
@@ -9,8 +9,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 	"gopkg.in/yaml.v3"
 
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 )
 
@@ -81,3 +83,69 @@ func TestRelocateChartDir(t *testing.T) {
 
 	})
 }
+
+func TestRelocateSubchartValues(t *testing.T) {
+	scenarioName := "chart1"
+	dest := sb.TempFile()
+	require.NoError(t, tu.RenderScenario(fmt.Sprintf("../testdata/scenarios/%s", scenarioName), dest, map[string]interface{}{"ServerURL": "localhost"}))
+	chartDir := filepath.Join(dest, scenarioName)
+
+	subchartValuesFile := filepath.Join(chartDir, "charts/mariadb/values.yaml")
+	require.NoError(t, os.WriteFile(subchartValuesFile, []byte(`
+metrics:
+  image: localhost/bitnami/mysqld-exporter:0.14.0-debian-11-r125
+volumePermissions:
+  image: localhost/bitnami/bitnami-shell:11-debian-11-r124
+`), 0644))
+
+	require.NoError(t, RelocateChartDir(chartDir, "test.example.com/airgap", Recursive))
+
+	data, err := os.ReadFile(subchartValuesFile)
+	require.NoError(t, err)
+	relocatedValues, err := tu.NormalizeYAML(string(data))
+	require.NoError(t, err)
+	// The bitnami-shell tag is only annotated in the parent chart
+	expectedValues, err := tu.NormalizeYAML(`
+metrics:
+  image: test.example.com/airgap/bitnami/mysqld-exporter:0.14.0-debian-11-r125
+volumePermissions:
+  image: test.example.com/airgap/bitnami/bitnami-shell:11-debian-11-r124
+`)
+	require.NoError(t, err)
+	assert.Equal(t, expectedValues, relocatedValues)
+}
+
+func TestRelocatePackagedSubcharts(t *testing.T) {
+	scenarioName := "chart1"
+	dest := sb.TempFile()
+	require.NoError(t, tu.RenderScenario(fmt.Sprintf("../testdata/scenarios/%s", scenarioName), dest, map[string]interface{}{"ServerURL": "localhost"}))
+	chartDir := filepath.Join(dest, scenarioName)
+
+	// Nest a subchart in mariadb, referencing an image only annotated in its parents, and package it
+	subchartDir := filepath.Join(chartDir, "charts/mariadb")
+	nestedDir := filepath.Join(subchartDir, "charts/exporter")
+	require.NoError(t, os.MkdirAll(nestedDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(nestedDir, "Chart.yaml"), []byte("apiVersion: v2\nname: exporter\nversion: 1.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nestedDir, "values.yaml"), []byte("image: localhost/bitnami/mysqld-exporter:0.14.0-debian-11-r125\n"), 0644))
+	packagedChart := filepath.Join(chartDir, "charts/mariadb-12.2.5.tgz")
+	require.NoError(t, utils.Tar(subchartDir, packagedChart, utils.TarConfig{Prefix: "mariadb"}))
+	require.NoError(t, os.RemoveAll(subchartDir))
+
+	require.NoError(t, RelocateChartDir(chartDir, "test.example.com/airgap", Recursive))
+
+	c, err := loader.Load(chartDir)
+	require.NoError(t, err)
+	var subchart *chart.Chart
+	for _, dep := range c.Dependencies() {
+		if dep.Name() == "mariadb" {
+			subchart = dep
+		}
+	}
+	require.NotNil(t, subchart, "packaged subchart not found")
+	assert.Contains(t, subchart.Metadata.Annotations["images"], "image: test.example.com/airgap/bitnami/mariadb:10.11.4-debian-11-r0")
+	assert.NotContains(t, subchart.Metadata.Annotations["images"], "localhost/")
+
+	require.Len(t, subchart.Dependencies(), 1)
+	nested := subchart.Dependencies()[0]
+	assert.Equal(t, "test.example.com/airgap/bitnami/mysqld-exporter:0.14.0-debian-11-r125", nested.Values["image"])
+}
@@ -0,0 +1,96 @@
+package relocator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cu "github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+)
+
+// relocateDependencies relocates the subcharts found in the charts/ directory of chartDir, both unpacked and
+// packaged (.tgz), recursively. parentImages are the images annotated in the charts above them
+func relocateDependencies(chartDir string, r relocation, cfg *RelocateConfig, parentImages ...*imagelock.ChartImage) error {
+	chartsDir := filepath.Join(chartDir, "charts")
+	entries, err := os.ReadDir(chartsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read subcharts: %v", err)
+	}
+	var allErrors error
+	for _, entry := range entries {
+		subchartPath := filepath.Join(chartsDir, entry.Name())
+		var err error
+		switch {
+		case entry.IsDir():
+			err = relocateSubchartDir(subchartPath, r, cfg, parentImages...)
+		case filepath.Ext(entry.Name()) == ".tgz":
+			err = relocatePackagedSubchart(subchartPath, r, cfg, parentImages...)
+		default:
+			continue
+		}
+		if err != nil {
+			allErrors = errors.Join(allErrors, fmt.Errorf("failed to relocate Helm SubChart %q: %v", subchartPath, err))
+		}
+	}
+	return allErrors
+}
+
+// relocateSubchartDir relocates the unpacked subchart in dir and its own subcharts
+func relocateSubchartDir(dir string, r relocation, cfg *RelocateConfig, parentImages ...*imagelock.ChartImage) error {
+	chart, err := cu.LoadChart(dir, cu.WithAnnotationsKey(cfg.ImageLockConfig.AnnotationsKey))
+	if err != nil {
+		return fmt.Errorf("failed to load Helm chart: %v", err)
+	}
+	// Read before relocating them, to find the values referencing them in the nested subcharts
+	images, _ := chart.GetAnnotatedImages()
+
+	if err := relocateChart(chart, r, cfg, parentImages...); err != nil {
+		return err
+	}
+	images = append(append(imagelock.ImageList{}, parentImages...), images...)
+	return relocateDependencies(dir, r, cfg, images...)
+}
+
+// relocatePackagedSubchart unpacks the subchart in file, relocates it and packages it back into file
+func relocatePackagedSubchart(file string, r relocation, cfg *RelocateConfig, parentImages ...*imagelock.ChartImage) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to read packaged Helm chart: %v", err)
+	}
+	tmpDir, err := os.MkdirTemp("", "subchart-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Packaged charts contain a single directory, named after the chart
+	chartDir := filepath.Join(tmpDir, "chart")
+	if err := utils.Untar(file, chartDir, utils.TarConfig{StripComponents: 1}); err != nil {
+		return fmt.Errorf("failed to unpack Helm chart: %v", err)
+	}
+	if err := relocateSubchartDir(chartDir, r, cfg, parentImages...); err != nil {
+		return err
+	}
+	chart, err := cu.LoadChart(chartDir)
+	if err != nil {
+		return fmt.Errorf("failed to load relocated Helm chart: %v", err)
+	}
+	tarFile := filepath.Join(tmpDir, filepath.Base(file))
+	if err := utils.Tar(chartDir, tarFile, utils.TarConfig{Prefix: chart.Name()}); err != nil {
+		return fmt.Errorf("failed to package relocated Helm chart: %v", err)
+	}
+	data, err := os.ReadFile(tarFile)
+	if err != nil {
+		return fmt.Errorf("failed to read relocated Helm chart: %v", err)
+	}
+	if err := utils.SafeWriteFile(file, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write relocated Helm chart: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package relocator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	cu "github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+var (
+	// DefaultFiles are the chart files relocated by default, besides values.yaml
+	DefaultFiles = []string{"crds", "files"}
+
+	// imageTokenRe matches the words that may be image references
+	imageTokenRe = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9._\-/:@]*[A-Za-z0-9]`)
+)
+
+// matchesFilesPatterns returns true if the file, relative to the chart root, matches any of the patterns,
+// either directly or by being inside a matching directory
+func matchesFilesPatterns(file string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(path.Clean(filepath.ToSlash(pattern)), "/")
+		for p := file; p != "." && p != "/"; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasTagOrDigest returns true if the image reference explicitly includes its tag or digest, so references
+// are not confused with the plain words in scripts
+func hasTagOrDigest(image string) bool {
+	return strings.Contains(image, "@") || strings.Contains(image[strings.LastIndex(image, "/")+1:], ":")
+}
+
+// relocateFileData rewrites the references to the annotated images in data, returning the number of replacements
+func relocateFileData(data []byte, r relocation, idx *annotatedImagesIndex) ([]byte, int, error) {
+	var allErrors error
+	count := 0
+	relocated := imageTokenRe.ReplaceAllFunc(data, func(token []byte) []byte {
+		image := string(token)
+		if !hasTagOrDigest(image) || !idx.hasImage(image) {
+			return token
+		}
+		newImage, err := idx.relocate(image, r)
+		if err != nil {
+			allErrors = errors.Join(allErrors, fmt.Errorf("failed to relocate %q: %v", image, err))
+			return token
+		}
+		count++
+		return []byte(newImage)
+	})
+	return relocated, count, allErrors
+}
+
+// relocateFiles rewrites the references to the annotated images in the chart files matching patterns, such as
+// the CRDs manifests or the scripts in files/. Subcharts are not included, as they are relocated on their own
+func relocateFiles(c *cu.Chart, r relocation, patterns []string, digests map[string]digest.Digest, parentImages ...*imagelock.ChartImage) (int, error) {
+	if len(patterns) == 0 {
+		return 0, nil
+	}
+	images, _ := c.GetAnnotatedImages()
+	idx := newAnnotatedImagesIndex(append(images, parentImages...), digests)
+	root := c.RootDir()
+	count := 0
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == "charts" || rel == "images" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !matchesFilesPatterns(rel, patterns) {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %v", rel, err)
+		}
+		// Binary files are not relocated
+		if bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+		relocated, n, err := relocateFileData(data, r, idx)
+		if err != nil {
+			return fmt.Errorf("failed to relocate %q: %v", rel, err)
+		}
+		if n == 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, relocated, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %q: %v", rel, err)
+		}
+		count += n
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,74 @@
+package relocator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func TestMatchesFilesPatterns(t *testing.T) {
+	patterns := []string{"crds", "files/", "scripts/*.sh"}
+	for file, expected := range map[string]bool{
+		"crds/crd.yaml":           true,
+		"crds/nested/crd.yaml":    true,
+		"files/init/setup.sh":     true,
+		"scripts/entrypoint.sh":   true,
+		"scripts/README.md":       false,
+		"templates/crds/crd.yaml": false,
+		"values.yaml":             false,
+	} {
+		assert.Equal(t, expected, matchesFilesPatterns(file, patterns), file)
+	}
+}
+
+func TestRelocateFiles(t *testing.T) {
+	const image = "localhost/bitnami/wordpress:6.2.2-debian-11-r11"
+	createChart := func(t *testing.T, files map[string]string) string {
+		dest := sb.TempFile()
+		require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": "localhost"}))
+		chartDir := filepath.Join(dest, "chart1")
+		for name, data := range files {
+			file := filepath.Join(chartDir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+			require.NoError(t, os.WriteFile(file, []byte(data), 0644))
+		}
+		return chartDir
+	}
+	assertFiles := func(t *testing.T, chartDir string, files map[string]string) {
+		for name, expected := range files {
+			data, err := os.ReadFile(filepath.Join(chartDir, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(data), name)
+		}
+	}
+
+	t.Run("Relocates CRDs and files by default", func(t *testing.T) {
+		chartDir := createChart(t, map[string]string{
+			"crds/crd.yaml":            "spec:\n  defaultImage: \"" + image + "\"\n",
+			"files/init.sh":            "#!/bin/sh\n# Uses the bitnami/wordpress image\ndocker run " + image + " && docker run localhost/bitnami/other:1.0.0\n",
+			"templates/configmap.yaml": "image: " + image + "\n",
+		})
+		require.NoError(t, RelocateChartDir(chartDir, "test.example.com/airgap"))
+		assertFiles(t, chartDir, map[string]string{
+			"crds/crd.yaml": "spec:\n  defaultImage: \"test.example.com/airgap/bitnami/wordpress:6.2.2-debian-11-r11\"\n",
+			"files/init.sh": "#!/bin/sh\n# Uses the bitnami/wordpress image\ndocker run test.example.com/airgap/bitnami/wordpress:6.2.2-debian-11-r11 && docker run localhost/bitnami/other:1.0.0\n",
+			// Only the configured files are relocated
+			"templates/configmap.yaml": "image: " + image + "\n",
+		})
+	})
+	t.Run("Relocates the configured files", func(t *testing.T) {
+		chartDir := createChart(t, map[string]string{
+			"crds/crd.yaml":            "image: " + image + "\n",
+			"templates/configmap.yaml": "image: " + image + "\n",
+		})
+		require.NoError(t, RelocateChartDir(chartDir, "other.example.com", WithFiles("templates/*.yaml")))
+		assertFiles(t, chartDir, map[string]string{
+			"crds/crd.yaml":            "image: " + image + "\n",
+			"templates/configmap.yaml": "image: other.example.com/bitnami/wordpress:6.2.2-debian-11-r11\n",
+		})
+	})
+}
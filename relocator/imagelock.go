@@ -9,10 +9,10 @@ import (
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 )
 
-func relocateImages(images imagelock.ImageList, prefix string) (count int, err error) {
+func relocateImages(images imagelock.ImageList, r relocation) (count int, err error) {
 	var allErrors error
 	for _, img := range images {
-		norm, err := utils.RelocateImageURL(img.Image, prefix, true)
+		norm, err := r.relocateURL(img.Image, true)
 		if err != nil {
 			allErrors = errors.Join(allErrors, err)
 			continue
@@ -23,9 +23,26 @@ func relocateImages(images imagelock.ImageList, prefix string) (count int, err e
 	return count, allErrors
 }
 
-// RelocateLock rewrites the images urls in the provided lock using prefix
-func RelocateLock(lock *imagelock.ImagesLock, prefix string) (*RelocationResult, error) {
-	count, err := relocateImages(lock.Images, prefix)
+// RelocateLock rewrites the images urls in the provided lock using prefix. Only the WithRepositoryMap,
+// WithRepositoryStrategy, WithTagStrategy and WithDigests options are taken into account
+func RelocateLock(lock *imagelock.ImagesLock, prefix string, opts ...RelocateOption) (*RelocationResult, error) {
+	cfg := NewRelocateConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	r, err := newRelocation(prefix, cfg, lock.Chart.Name, lock.Chart.Version)
+	if err != nil {
+		return nil, err
+	}
+	return relocateLock(lock, r)
+}
+
+func relocateLock(lock *imagelock.ImagesLock, r relocation) (*RelocationResult, error) {
+	r, err := r.withDestinations(lock.Images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to relocate Images.lock file: %v", err)
+	}
+	count, err := relocateImages(lock.Images, r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to relocate Images.lock file: %v", err)
 	}
@@ -38,11 +55,15 @@ func RelocateLock(lock *imagelock.ImagesLock, prefix string) (*RelocationResult,
 
 // RelocateLockFile reloactes images urls in the provided Images.lock using prefix
 func RelocateLockFile(file string, prefix string) error {
+	return relocateLockFile(file, relocation{prefix: prefix})
+}
+
+func relocateLockFile(file string, r relocation) error {
 	lock, err := imagelock.FromYAMLFile(file)
 	if err != nil {
 		return fmt.Errorf("failed to load Images.lock: %v", err)
 	}
-	result, err := RelocateLock(lock, prefix)
+	result, err := relocateLock(lock, r)
 	if err != nil {
 		return err
 	}
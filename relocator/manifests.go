@@ -0,0 +1,64 @@
+package relocator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// ReadReport reads a relocation report written in JSON
+func ReadReport(file string) (*Report, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	return r, nil
+}
+
+// normalizedImage returns the fully qualified reference of image, so references to the same image written
+// differently, such as "bitnami/mariadb:10.11" and "docker.io/bitnami/mariadb:10.11", match
+func normalizedImage(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	return ref.Name(), nil
+}
+
+// RelocateManifest rewrites the references to the source images of the report in the Kubernetes manifest,
+// such as the one rendered by Helm, with their targets, returning the number of replacements. The rest of the
+// manifest is kept as is
+func (r *Report) RelocateManifest(manifest []byte) ([]byte, int, error) {
+	targets := make(map[string]string, len(r.Images))
+	for _, m := range r.Images {
+		source, err := normalizedImage(m.Source)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to parse image %q: %w", m.Source, err)
+		}
+		targets[source] = m.Target
+	}
+	count := 0
+	relocated := imageTokenRe.ReplaceAllFunc(manifest, func(token []byte) []byte {
+		image := string(token)
+		if !hasTagOrDigest(image) {
+			return token
+		}
+		source, err := normalizedImage(image)
+		if err != nil {
+			return token
+		}
+		target, ok := targets[source]
+		if !ok {
+			return token
+		}
+		count++
+		return []byte(target)
+	})
+	return relocated, count, nil
+}
@@ -0,0 +1,76 @@
+package relocator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func TestRelocateManifest(t *testing.T) {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Images = imagelock.ImageList{
+		{Name: "wordpress", Chart: "wordpress", Image: "docker.io/bitnami/wordpress:6.2.2"},
+		{Name: "os-shell", Chart: "wordpress", Image: "docker.io/bitnami/os-shell:11-debian-11-r16"},
+	}
+	r, err := NewReport(lock, "registry.example.com/airgap")
+	require.NoError(t, err)
+
+	manifest := `---
+# Source: wordpress/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wordpress
+  labels:
+    app.kubernetes.io/name: wordpress
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: prepare
+          image: "bitnami/os-shell:11-debian-11-r16"
+      containers:
+        - name: wordpress
+          image: docker.io/bitnami/wordpress:6.2.2
+        - name: metrics
+          image: docker.io/bitnami/apache-exporter:0.13.4
+`
+	relocated, count, err := r.RelocateManifest([]byte(manifest))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, `---
+# Source: wordpress/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wordpress
+  labels:
+    app.kubernetes.io/name: wordpress
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: prepare
+          image: "registry.example.com/airgap/bitnami/os-shell:11-debian-11-r16"
+      containers:
+        - name: wordpress
+          image: registry.example.com/airgap/bitnami/wordpress:6.2.2
+        - name: metrics
+          image: docker.io/bitnami/apache-exporter:0.13.4
+`, string(relocated))
+
+	t.Run("Reads the report from a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "report.json")
+		require.NoError(t, r.WriteFile(file))
+		read, err := ReadReport(file)
+		require.NoError(t, err)
+		assert.Equal(t, r, read)
+
+		_, err = ReadReport(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorContains(t, err, "failed to read report")
+	})
+}
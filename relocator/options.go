@@ -1,6 +1,7 @@
 package relocator
 
 import (
+	"github.com/opencontainers/go-digest"
 	"github.com/vmware-labs/distribution-tooling-for-helm/internal/log"
 
 	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
@@ -11,13 +12,28 @@ type RelocateConfig struct {
 	ImageLockConfig imagelock.Config
 	Log             log.Logger
 	Recursive       bool
+	// Digests, if not empty, maps the original image references to the digests used to reference
+	// the relocated images in values.yaml
+	Digests map[string]digest.Digest
+	// Repositories maps source repositories to custom target repositories, instead of the ones derived from
+	// the relocation prefix
+	Repositories RepositoryMap
+	// RepositoryStrategy defines how the source repositories are mapped into the relocation prefix
+	RepositoryStrategy RepositoryStrategy
+	// TagStrategy defines how the relocated images are addressed
+	TagStrategy TagStrategy
+	// Files are the patterns of the chart files, relative to its root, whose image references are relocated
+	Files []string
 }
 
 // NewRelocateConfig returns a new RelocateConfig with default settings
 func NewRelocateConfig() *RelocateConfig {
 	return &RelocateConfig{
-		Log:             log.SilentLog,
-		ImageLockConfig: *imagelock.NewImagesLockConfig(),
+		Log:                log.SilentLog,
+		ImageLockConfig:    *imagelock.NewImagesLockConfig(),
+		Files:              DefaultFiles,
+		RepositoryStrategy: DefaultRepositoryStrategy,
+		TagStrategy:        OriginalTagStrategy,
 	}
 }
 
@@ -42,3 +58,41 @@ func WithLog(l log.Logger) func(rc *RelocateConfig) {
 		rc.Log = l
 	}
 }
+
+// WithDigests requests the relocated images in values.yaml to be referenced by the provided digests,
+// keyed by the original image references
+func WithDigests(digests map[string]digest.Digest) func(rc *RelocateConfig) {
+	return func(rc *RelocateConfig) {
+		rc.Digests = digests
+	}
+}
+
+// WithFiles customizes the patterns of the chart files, relative to its root, whose image references are
+// relocated. Patterns matching a directory include all the files inside it
+func WithFiles(patterns ...string) func(rc *RelocateConfig) {
+	return func(rc *RelocateConfig) {
+		rc.Files = patterns
+	}
+}
+
+// WithRepositoryMap relocates the images of the repositories in m into their mapped repositories
+func WithRepositoryMap(m RepositoryMap) func(rc *RelocateConfig) {
+	return func(rc *RelocateConfig) {
+		rc.Repositories = m
+	}
+}
+
+// WithRepositoryStrategy customizes how the source repositories are mapped into the relocation prefix
+func WithRepositoryStrategy(strategy RepositoryStrategy) func(rc *RelocateConfig) {
+	return func(rc *RelocateConfig) {
+		rc.RepositoryStrategy = strategy
+	}
+}
+
+// WithTagStrategy customizes how the relocated images are addressed. The digest strategy requires the images
+// digests, provided using WithDigests
+func WithTagStrategy(strategy TagStrategy) func(rc *RelocateConfig) {
+	return func(rc *RelocateConfig) {
+		rc.TagStrategy = strategy
+	}
+}
@@ -0,0 +1,218 @@
+package relocator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+)
+
+// ReportFormat defines the format of a relocation report
+type ReportFormat string
+
+const (
+	// ReportJSON defines the JSON report format
+	ReportJSON ReportFormat = "json"
+	// ReportCSV defines the CSV report format, with a row per image digest
+	ReportCSV ReportFormat = "csv"
+	// ReportKbld defines the kbld Config format, overriding the original images with the relocated ones
+	ReportKbld ReportFormat = "kbld"
+	// ReportKustomize defines the kustomization format, with an images transformer renaming the original images
+	ReportKustomize ReportFormat = "kustomize"
+)
+
+// kbldOverride is a kbld image override
+type kbldOverride struct {
+	Image       string `yaml:"image"`
+	NewImage    string `yaml:"newImage"`
+	Preresolved bool   `yaml:"preresolved"`
+}
+
+// kbldConfig is a kbld Config, as read by kbld along the deployed manifests
+type kbldConfig struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Overrides  []kbldOverride `yaml:"overrides"`
+}
+
+// kbldConfig returns the kbld Config mapping the original image references to the relocated ones, which
+// are already resolved, so kbld deploys them as they are
+func (r *Report) kbldConfig() *kbldConfig {
+	cfg := &kbldConfig{APIVersion: "kbld.k14s.io/v1alpha1", Kind: "Config", Overrides: make([]kbldOverride, 0)}
+	done := make(map[string]struct{})
+	for _, img := range r.Images {
+		if _, found := done[img.Source]; found {
+			continue
+		}
+		done[img.Source] = struct{}{}
+		cfg.Overrides = append(cfg.Overrides, kbldOverride{Image: img.Source, NewImage: img.Target, Preresolved: true})
+	}
+	return cfg
+}
+
+// kustomizeImage is a kustomize images transformer entry
+type kustomizeImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// kustomization is a kustomization.yaml only declaring its images transformer
+type kustomization struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Images     []kustomizeImage `yaml:"images"`
+}
+
+// splitImage splits an image reference into its name, tag and digest
+func splitImage(image string) (string, string, string) {
+	var tag, dgst string
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		image, dgst = image[:idx], image[idx+1:]
+	}
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		image, tag = image[:idx], image[idx+1:]
+	}
+	return image, tag, dgst
+}
+
+// kustomization returns the kustomization renaming the original images to the relocated ones. As kustomize
+// matches the images by name, only the first relocation of every original image name is used
+func (r *Report) kustomization() *kustomization {
+	k := &kustomization{APIVersion: "kustomize.config.k8s.io/v1beta1", Kind: "Kustomization", Images: make([]kustomizeImage, 0)}
+	done := make(map[string]struct{})
+	for _, img := range r.Images {
+		name, _, _ := splitImage(img.Source)
+		if _, found := done[name]; found {
+			continue
+		}
+		done[name] = struct{}{}
+		newName, newTag, dgst := splitImage(img.Target)
+		k.Images = append(k.Images, kustomizeImage{Name: name, NewName: newName, NewTag: newTag, Digest: dgst})
+	}
+	return k
+}
+
+// writeYAML serializes obj as YAML indented with two spaces
+func writeYAML(w io.Writer, obj interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(obj); err != nil {
+		return fmt.Errorf("failed to serialize report: %w", err)
+	}
+	return enc.Close()
+}
+
+// ReportFormatFromFile returns the ReportFormat matching the file extension, defaulting to JSON
+func ReportFormatFromFile(file string) ReportFormat {
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return ReportCSV
+	}
+	return ReportJSON
+}
+
+// ReportDigest defines a platform specific digest of a relocated image
+type ReportDigest struct {
+	Arch   string        `json:"arch"`
+	Digest digest.Digest `json:"digest"`
+}
+
+// ImageMapping describes the relocation of an image
+type ImageMapping struct {
+	Chart   string         `json:"chart"`
+	Name    string         `json:"name"`
+	Source  string         `json:"source"`
+	Target  string         `json:"target"`
+	Digests []ReportDigest `json:"digests"`
+}
+
+// Report maps the original image references of a chart to their relocated references
+type Report struct {
+	Chart   string         `json:"chart"`
+	Version string         `json:"version"`
+	Prefix  string         `json:"prefix"`
+	Images  []ImageMapping `json:"images"`
+}
+
+// NewReport returns the Report describing the relocation of the images in lock using prefix. Only the
+// WithRepositoryMap, WithRepositoryStrategy, WithTagStrategy and WithDigests options are taken into account
+func NewReport(lock *imagelock.ImagesLock, prefix string, opts ...RelocateOption) (*Report, error) {
+	cfg := NewRelocateConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	rel, err := newRelocation(prefix, cfg, lock.Chart.Name, lock.Chart.Version)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err = rel.withDestinations(lock.Images); err != nil {
+		return nil, err
+	}
+	r := &Report{Chart: lock.Chart.Name, Version: lock.Chart.Version, Prefix: rel.prefix, Images: make([]ImageMapping, 0)}
+	for _, img := range lock.Images {
+		target, err := rel.relocateURL(img.Image, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to relocate image %q: %w", img.Image, err)
+		}
+		m := ImageMapping{Chart: img.Chart, Name: img.Name, Source: img.Image, Target: target, Digests: make([]ReportDigest, 0)}
+		for _, d := range img.Digests {
+			m.Digests = append(m.Digests, ReportDigest{Arch: d.Arch, Digest: d.Digest})
+		}
+		r.Images = append(r.Images, m)
+	}
+	return r, nil
+}
+
+// Write serializes the report in the provided format
+func (r *Report) Write(w io.Writer, format ReportFormat) error {
+	switch format {
+	case ReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to serialize report: %w", err)
+		}
+	case ReportCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"chart", "name", "source", "target", "arch", "digest"})
+		for _, img := range r.Images {
+			for _, d := range img.Digests {
+				_ = cw.Write([]string{img.Chart, img.Name, img.Source, img.Target, d.Arch, d.Digest.String()})
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("failed to serialize report: %w", err)
+		}
+	case ReportKbld:
+		return writeYAML(w, r.kbldConfig())
+	case ReportKustomize:
+		return writeYAML(w, r.kustomization())
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+	return nil
+}
+
+// WriteFile writes the report into file, in the format matching its extension
+func (r *Report) WriteFile(file string) error {
+	return r.WriteFileFormat(file, ReportFormatFromFile(file))
+}
+
+// WriteFileFormat writes the report into file in the provided format
+func (r *Report) WriteFileFormat(file string, format ReportFormat) error {
+	fh, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer fh.Close()
+	return r.Write(fh, format)
+}
@@ -0,0 +1,94 @@
+package relocator
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func TestReport(t *testing.T) {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Chart.Version = "1.0.0"
+	lock.Images = imagelock.ImageList{
+		{
+			Name:  "wordpress",
+			Chart: "wordpress",
+			Image: "docker.io/bitnami/wordpress:6.2.2",
+			Digests: []imagelock.DigestInfo{
+				{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")},
+				{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")},
+			},
+		},
+	}
+	r, err := NewReport(lock, "oci://registry.example.com/airgap")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/airgap", r.Prefix)
+	require.Len(t, r.Images, 1)
+	assert.Equal(t, "docker.io/bitnami/wordpress:6.2.2", r.Images[0].Source)
+	assert.Equal(t, "registry.example.com/airgap/bitnami/wordpress:6.2.2", r.Images[0].Target)
+
+	t.Run("JSON", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, r.Write(buff, ReportJSON))
+		newReport := &Report{}
+		require.NoError(t, json.Unmarshal(buff.Bytes(), newReport))
+		assert.Equal(t, r, newReport)
+	})
+	t.Run("CSV", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, r.Write(buff, ReportCSV))
+		lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "chart,name,source,target,arch,digest", lines[0])
+		assert.Equal(t, "wordpress,wordpress,docker.io/bitnami/wordpress:6.2.2,registry.example.com/airgap/bitnami/wordpress:6.2.2,"+
+			"linux/arm64,sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7", lines[2])
+	})
+	t.Run("kbld", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, r.Write(buff, ReportKbld))
+		assert.Equal(t, `apiVersion: kbld.k14s.io/v1alpha1
+kind: Config
+overrides:
+  - image: docker.io/bitnami/wordpress:6.2.2
+    newImage: registry.example.com/airgap/bitnami/wordpress:6.2.2
+    preresolved: true
+`, buff.String())
+	})
+	t.Run("kustomize", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, r.Write(buff, ReportKustomize))
+		assert.Equal(t, `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+images:
+  - name: docker.io/bitnami/wordpress
+    newName: registry.example.com/airgap/bitnami/wordpress
+    newTag: 6.2.2
+`, buff.String())
+
+		pinned, err := NewReport(lock, "registry.example.com:5000/airgap", WithTagStrategy(DigestTagStrategy),
+			WithDigests(map[string]digest.Digest{"docker.io/bitnami/wordpress:6.2.2": digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")}))
+		require.NoError(t, err)
+		buff.Reset()
+		require.NoError(t, pinned.Write(buff, ReportKustomize))
+		assert.Contains(t, buff.String(), `  - name: docker.io/bitnami/wordpress
+    newName: registry.example.com:5000/airgap/bitnami/wordpress
+    digest: sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f
+`)
+	})
+	t.Run("Format from file", func(t *testing.T) {
+		assert.Equal(t, ReportCSV, ReportFormatFromFile(filepath.Join("reports", "relocation.CSV")))
+		assert.Equal(t, ReportJSON, ReportFormatFromFile("relocation.json"))
+		assert.Equal(t, ReportJSON, ReportFormatFromFile("relocation"))
+	})
+	t.Run("Unsupported format", func(t *testing.T) {
+		assert.ErrorContains(t, r.Write(&bytes.Buffer{}, ReportFormat("xml")), "unsupported report format")
+	})
+}
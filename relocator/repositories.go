@@ -0,0 +1,189 @@
+package relocator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+)
+
+// RepositoryMap maps source repositories ("docker.io/bitnami/nginx") to the target repositories their images are
+// relocated to ("harbor.example.com/team-a/nginx"), instead of the ones derived from the relocation prefix
+type RepositoryMap map[string]string
+
+// ReadRepositoryMap reads a RepositoryMap from a YAML file mapping the source repositories to the target ones
+func ReadRepositoryMap(file string) (RepositoryMap, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository map: %w", err)
+	}
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse repository map %q: %w", file, err)
+	}
+	m := make(RepositoryMap)
+	for source, target := range entries {
+		if err := m.Add(source, target); err != nil {
+			return nil, fmt.Errorf("invalid repository map %q: %w", file, err)
+		}
+	}
+	return m, nil
+}
+
+// Add maps the source repository to the target one
+func (m RepositoryMap) Add(source string, target string) error {
+	src, err := name.NewRepository(source)
+	if err != nil {
+		return fmt.Errorf("invalid source repository %q: %v", source, err)
+	}
+	target = normalizeRelocateURL(strings.TrimSuffix(target, "/"))
+	if _, err := name.NewRepository(target); err != nil {
+		return fmt.Errorf("invalid target repository %q: %v", target, err)
+	}
+	m[src.Name()] = target
+	return nil
+}
+
+// RepositoryStrategy defines how the source repositories paths are mapped into the relocation prefix
+type RepositoryStrategy string
+
+const (
+	// DefaultRepositoryStrategy keeps the last two components of the source repository path (bitnami/nginx)
+	DefaultRepositoryStrategy RepositoryStrategy = "default"
+	// FullRepositoryStrategy keeps the full source repository path (team/apps/nginx)
+	FullRepositoryStrategy RepositoryStrategy = "full"
+	// FlatRepositoryStrategy keeps the last component of the source repository path (nginx), for registries
+	// not supporting nested repositories
+	FlatRepositoryStrategy RepositoryStrategy = "flat"
+	// HashRepositoryStrategy keeps the last component of the source repository path, prefixed with a hash of
+	// the source repository to avoid collisions between repositories with the same name (3f2a1b9c-nginx)
+	HashRepositoryStrategy RepositoryStrategy = "hash"
+)
+
+// RepositoryStrategies lists the supported repository strategies
+var RepositoryStrategies = []RepositoryStrategy{
+	DefaultRepositoryStrategy, FullRepositoryStrategy, FlatRepositoryStrategy, HashRepositoryStrategy,
+}
+
+// ParseRepositoryStrategy returns the RepositoryStrategy named s
+func ParseRepositoryStrategy(s string) (RepositoryStrategy, error) {
+	for _, strategy := range RepositoryStrategies {
+		if string(strategy) == s {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown repository strategy %q: use one of %v", s, RepositoryStrategies)
+}
+
+// targetPath returns the path of the target repository for the source repository, following the strategy
+func (s RepositoryStrategy) targetPath(repo name.Repository) string {
+	parts := strings.Split(repo.RepositoryStr(), "/")
+	last := parts[len(parts)-1]
+	switch s {
+	case FullRepositoryStrategy:
+		return repo.RepositoryStr()
+	case FlatRepositoryStrategy:
+		return last
+	case HashRepositoryStrategy:
+		sum := sha256.Sum256([]byte(repo.Name()))
+		return fmt.Sprintf("%s-%s", hex.EncodeToString(sum[:])[:8], last)
+	default:
+		if len(parts) > 1 {
+			return strings.Join(parts[len(parts)-2:], "/")
+		}
+		return last
+	}
+}
+
+// relocation defines how image references are relocated: into their mapped repositories, if any, or under prefix
+// following the repository strategy
+type relocation struct {
+	prefix       string
+	repositories RepositoryMap
+	strategy     RepositoryStrategy
+	// tag, if not empty, replaces the tags of the relocated images
+	tag string
+	// digests, keyed by the normalized source references, are the only identifiers of the relocated images
+	digests map[string]digest.Digest
+}
+
+// newRelocation returns the relocation into prefix configured by cfg for the chart chartName, version chartVersion
+func newRelocation(prefix string, cfg *RelocateConfig, chartName string, chartVersion string) (relocation, error) {
+	r := relocation{prefix: normalizeRelocateURL(prefix), repositories: cfg.Repositories, strategy: cfg.RepositoryStrategy}
+	switch cfg.TagStrategy {
+	case ChartTagStrategy:
+		r.tag = ChartTag(chartName, chartVersion)
+	case DigestTagStrategy:
+		if len(cfg.Digests) == 0 {
+			return r, fmt.Errorf("the %q tag strategy requires the digests of the images", cfg.TagStrategy)
+		}
+		r.digests = normalizeDigests(cfg.Digests)
+	}
+	return r, nil
+}
+
+// withDestinations returns the relocation mapping the repositories of the images with a destination into it,
+// taking precedence over the repository map
+func (r relocation) withDestinations(images imagelock.ImageList) (relocation, error) {
+	repositories := make(RepositoryMap, len(r.repositories))
+	for source, target := range r.repositories {
+		repositories[source] = target
+	}
+	found := false
+	for _, img := range images {
+		if img.Destination == "" {
+			continue
+		}
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			return r, fmt.Errorf("failed to parse image %q: %v", img.Image, err)
+		}
+		if err := repositories.Add(ref.Context().Name(), img.Destination); err != nil {
+			return r, fmt.Errorf("invalid destination for image %q: %w", img.Name, err)
+		}
+		found = true
+	}
+	if found {
+		r.repositories = repositories
+	}
+	return r, nil
+}
+
+// relocateURL returns the relocated image url, including its tag or digest if includeIdentifier is true
+func (r relocation) relocateURL(url string, includeIdentifier bool) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to relocate url: %v", err)
+	}
+	target, ok := r.repositories[ref.Context().Name()]
+	if !ok {
+		target = fmt.Sprintf("%s/%s", strings.TrimRight(r.prefix, "/"), r.strategy.targetPath(ref.Context()))
+	}
+	if includeIdentifier {
+		target += r.identifier(ref)
+	}
+	return target, nil
+}
+
+// identifier returns the tag or digest, including its separator, addressing the relocated image of ref
+func (r relocation) identifier(ref name.Reference) string {
+	if d, ok := r.digests[ref.Name()]; ok {
+		return "@" + d.String()
+	}
+	switch ref := ref.(type) {
+	case name.Digest:
+		return "@" + ref.DigestStr()
+	case name.Tag:
+		if r.tag != "" {
+			return ":" + r.tag
+		}
+		return ":" + ref.TagStr()
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package relocator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func TestReadRepositoryMap(t *testing.T) {
+	writeMap := func(data string) string {
+		file := filepath.Join(sb.TempFile(), "repo-map.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte(data), 0644))
+		return file
+	}
+	t.Run("Reads the map", func(t *testing.T) {
+		m, err := ReadRepositoryMap(writeMap(`
+docker.io/bitnami/nginx: harbor.example.com/team-a/web/nginx
+bitnami/redis: oci://harbor.example.com/team-b/cache/
+`))
+		require.NoError(t, err)
+		assert.Equal(t, RepositoryMap{
+			"index.docker.io/bitnami/nginx": "harbor.example.com/team-a/web/nginx",
+			"index.docker.io/bitnami/redis": "harbor.example.com/team-b/cache",
+		}, m)
+	})
+	t.Run("Validates the map", func(t *testing.T) {
+		_, err := ReadRepositoryMap(filepath.Join(sb.TempFile(), "missing.yaml"))
+		assert.ErrorContains(t, err, "failed to read repository map")
+		_, err = ReadRepositoryMap(writeMap("- docker.io/bitnami/nginx"))
+		assert.ErrorContains(t, err, "failed to parse repository map")
+		_, err = ReadRepositoryMap(writeMap("docker.io/bitnami/nginx: harbor.example.com/Team-A/nginx"))
+		assert.ErrorContains(t, err, "invalid target repository")
+	})
+}
+
+func TestRelocationWithRepositoryMap(t *testing.T) {
+	m := make(RepositoryMap)
+	require.NoError(t, m.Add("docker.io/bitnami/nginx", "harbor.example.com/team-a/web/nginx"))
+	r := relocation{prefix: "harbor.example.com/shared", repositories: m}
+	const digest = "sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f"
+
+	for url, expected := range map[string]string{
+		"bitnami/nginx:1.25.0":              "harbor.example.com/team-a/web/nginx:1.25.0",
+		"docker.io/bitnami/nginx@" + digest: "harbor.example.com/team-a/web/nginx@" + digest,
+		"docker.io/bitnami/redis:7.0.11":    "harbor.example.com/shared/bitnami/redis:7.0.11",
+	} {
+		relocated, err := r.relocateURL(url, true)
+		require.NoError(t, err)
+		assert.Equal(t, expected, relocated)
+	}
+
+	t.Run("Relocates the chart into the mapped repositories", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": "localhost"}))
+		chartDir := filepath.Join(dest, "chart1")
+
+		m := make(RepositoryMap)
+		require.NoError(t, m.Add("localhost/bitnami/wordpress", "harbor.example.com/cms/wordpress"))
+		require.NoError(t, RelocateChartDir(chartDir, "harbor.example.com/shared", WithRepositoryMap(m)))
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(t, err)
+		targets := make(map[string]string)
+		for _, img := range lock.Images {
+			targets[img.Name] = img.Image
+		}
+		assert.Equal(t, "harbor.example.com/cms/wordpress:6.2.2-debian-11-r11", targets["wordpress"])
+		assert.Equal(t, "harbor.example.com/shared/bitnami/apache-exporter:0.13.4-debian-11-r2", targets["apache-exporter"])
+
+		values, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(values), "registry: harbor.example.com\n")
+		assert.Contains(t, string(values), "repository: cms/wordpress\n")
+	})
+}
+
+func TestRepositoryStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy RepositoryStrategy
+		url      string
+		expected string
+	}{
+		{DefaultRepositoryStrategy, "docker.io/bitnami/nginx:1.25.0", "harbor.example.com/project/bitnami/nginx:1.25.0"},
+		{DefaultRepositoryStrategy, "registry.example.com/team/apps/nginx:1.25.0", "harbor.example.com/project/apps/nginx:1.25.0"},
+		{DefaultRepositoryStrategy, "nginx:1.25.0", "harbor.example.com/project/library/nginx:1.25.0"},
+		{FullRepositoryStrategy, "registry.example.com/team/apps/nginx:1.25.0", "harbor.example.com/project/team/apps/nginx:1.25.0"},
+		{FlatRepositoryStrategy, "registry.example.com/team/apps/nginx:1.25.0", "harbor.example.com/project/nginx:1.25.0"},
+		{HashRepositoryStrategy, "registry.example.com/team/apps/nginx:1.25.0", "harbor.example.com/project/0ad8aaff-nginx:1.25.0"},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			relocated, err := relocation{prefix: "harbor.example.com/project", strategy: tc.strategy}.relocateURL(tc.url, true)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, relocated)
+		})
+	}
+	t.Run("Avoids collisions when hashing", func(t *testing.T) {
+		r := relocation{prefix: "harbor.example.com/project", strategy: HashRepositoryStrategy}
+		a, err := r.relocateURL("registry.example.com/team-a/nginx:1.25.0", false)
+		require.NoError(t, err)
+		b, err := r.relocateURL("registry.example.com/team-b/nginx:1.25.0", false)
+		require.NoError(t, err)
+		assert.NotEqual(t, a, b)
+	})
+	t.Run("Parses the strategies", func(t *testing.T) {
+		for _, strategy := range RepositoryStrategies {
+			parsed, err := ParseRepositoryStrategy(string(strategy))
+			require.NoError(t, err)
+			assert.Equal(t, strategy, parsed)
+		}
+		_, err := ParseRepositoryStrategy("deep")
+		assert.ErrorContains(t, err, "unknown repository strategy")
+	})
+}
+
+func TestRelocationWithDestinations(t *testing.T) {
+	t.Run("Relocates the annotated images into their destinations", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": "localhost"}))
+		chartDir := filepath.Join(dest, "chart1")
+		chartFile := filepath.Join(chartDir, "Chart.yaml")
+		data, err := os.ReadFile(chartFile)
+		require.NoError(t, err)
+		annotated := strings.Replace(string(data), "image: localhost/bitnami/wordpress:6.2.2-debian-11-r11\n",
+			"image: localhost/bitnami/wordpress:6.2.2-debian-11-r11\n        destination: harbor.example.com/special/wordpress\n", 1)
+		require.NotEqual(t, string(data), annotated)
+		require.NoError(t, os.WriteFile(chartFile, []byte(annotated), 0644))
+
+		require.NoError(t, RelocateChartDir(chartDir, "harbor.example.com/shared"))
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(t, err)
+		targets := make(map[string]string)
+		for _, img := range lock.Images {
+			targets[img.Name] = img.Image
+		}
+		assert.Equal(t, "harbor.example.com/special/wordpress:6.2.2-debian-11-r11", targets["wordpress"])
+		assert.Equal(t, "harbor.example.com/shared/bitnami/apache-exporter:0.13.4-debian-11-r2", targets["apache-exporter"])
+
+		c, err := loader.Load(chartDir)
+		require.NoError(t, err)
+		assert.Contains(t, c.Metadata.Annotations["images"], "image: harbor.example.com/special/wordpress:6.2.2-debian-11-r11\n  destination: harbor.example.com/special/wordpress\n")
+
+		values, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(values), "repository: special/wordpress\n")
+	})
+	t.Run("Relocates the locked images into their destinations", func(t *testing.T) {
+		lock := imagelock.NewImagesLock()
+		lock.Images = imagelock.ImageList{
+			{Name: "nginx", Image: "docker.io/bitnami/nginx:1.25.0", Destination: "oci://harbor.example.com/edge/nginx"},
+			{Name: "redis", Image: "docker.io/bitnami/redis:7.0.11"},
+		}
+		_, err := RelocateLock(lock, "harbor.example.com/shared")
+		require.NoError(t, err)
+		assert.Equal(t, "harbor.example.com/edge/nginx:1.25.0", lock.Images[0].Image)
+		assert.Equal(t, "harbor.example.com/shared/bitnami/redis:7.0.11", lock.Images[1].Image)
+
+		lock.Images[1].Destination = "harbor.example.com/Invalid"
+		_, err = RelocateLock(lock, "harbor.example.com/shared")
+		assert.ErrorContains(t, err, `invalid destination for image "redis"`)
+	})
+}
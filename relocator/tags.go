@@ -0,0 +1,60 @@
+package relocator
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
+)
+
+// TagStrategy defines how the relocated images are addressed
+type TagStrategy string
+
+const (
+	// OriginalTagStrategy keeps the source tags of the images
+	OriginalTagStrategy TagStrategy = "original"
+	// DigestTagStrategy references the relocated images by digest only, so they are pushed untagged
+	DigestTagStrategy TagStrategy = "digest"
+	// ChartTagStrategy retags the relocated images with the chart name and version (mariadb-12.2.8)
+	ChartTagStrategy TagStrategy = "chart"
+)
+
+// maxTagLength is the maximum length of an OCI tag
+const maxTagLength = 128
+
+var invalidTagCharsRe = regexp.MustCompile(`[^\w.-]`)
+
+// TagStrategies lists the supported tag strategies
+var TagStrategies = []TagStrategy{OriginalTagStrategy, DigestTagStrategy, ChartTagStrategy}
+
+// ParseTagStrategy returns the TagStrategy named s
+func ParseTagStrategy(s string) (TagStrategy, error) {
+	for _, strategy := range TagStrategies {
+		if string(strategy) == s {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tag strategy %q: use one of %v", s, TagStrategies)
+}
+
+// ChartTag returns the tag the images of the chart are retagged with by the chart tag strategy. Characters not
+// allowed in tags, such as the "+" of semver build metadata, are replaced by "_"
+func ChartTag(chartName string, chartVersion string) string {
+	tag := invalidTagCharsRe.ReplaceAllString(fmt.Sprintf("%s-%s", chartName, chartVersion), "_")
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	return tag
+}
+
+// normalizeDigests returns digests keyed by the normalized image references
+func normalizeDigests(digests map[string]digest.Digest) map[string]digest.Digest {
+	normalized := make(map[string]digest.Digest)
+	for image, d := range digests {
+		if ref, err := name.ParseReference(image); err == nil {
+			normalized[ref.Name()] = d
+		}
+	}
+	return normalized
+}
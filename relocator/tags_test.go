@@ -0,0 +1,69 @@
+package relocator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
+)
+
+func TestTagStrategies(t *testing.T) {
+	const dgst = digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")
+
+	assert.Equal(t, "mariadb-12.2.8_build.1", ChartTag("mariadb", "12.2.8+build.1"))
+
+	t.Run("Retags the images", func(t *testing.T) {
+		r := relocation{prefix: "harbor.example.com/project", tag: "wordpress-1.0.0"}
+		for url, expected := range map[string]string{
+			"docker.io/bitnami/nginx:1.25.0":           "harbor.example.com/project/bitnami/nginx:wordpress-1.0.0",
+			"docker.io/bitnami/nginx@" + dgst.String(): "harbor.example.com/project/bitnami/nginx@" + dgst.String(),
+		} {
+			relocated, err := r.relocateURL(url, true)
+			require.NoError(t, err)
+			assert.Equal(t, expected, relocated)
+		}
+	})
+	t.Run("References the images by digest", func(t *testing.T) {
+		r := relocation{prefix: "harbor.example.com/project", digests: normalizeDigests(map[string]digest.Digest{"bitnami/nginx:1.25.0": dgst})}
+		relocated, err := r.relocateURL("docker.io/bitnami/nginx:1.25.0", true)
+		require.NoError(t, err)
+		assert.Equal(t, "harbor.example.com/project/bitnami/nginx@"+dgst.String(), relocated)
+	})
+	t.Run("Retags the chart images with the chart version", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": "localhost"}))
+		chartDir := filepath.Join(dest, "chart1")
+
+		require.NoError(t, RelocateChartDir(chartDir, "harbor.example.com/shared", WithTagStrategy(ChartTagStrategy)))
+
+		lock, err := imagelock.FromYAMLFile(filepath.Join(chartDir, "Images.lock"))
+		require.NoError(t, err)
+		for _, img := range lock.Images {
+			assert.Regexp(t, `^harbor\.example\.com/shared/.*:wordpress-1\.0\.0$`, img.Image)
+		}
+		values, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(values), "tag: wordpress-1.0.0\n")
+		assert.NotContains(t, string(values), "tag: 6.2.2-debian-11-r26")
+	})
+	t.Run("Requires the digests to reference the images by digest", func(t *testing.T) {
+		dest := sb.TempFile()
+		require.NoError(t, tu.RenderScenario("../testdata/scenarios/chart1", dest, map[string]interface{}{"ServerURL": "localhost"}))
+		err := RelocateChartDir(filepath.Join(dest, "chart1"), "harbor.example.com/shared", WithTagStrategy(DigestTagStrategy))
+		assert.ErrorContains(t, err, "requires the digests of the images")
+	})
+	t.Run("Parses the strategies", func(t *testing.T) {
+		for _, strategy := range TagStrategies {
+			parsed, err := ParseTagStrategy(string(strategy))
+			require.NoError(t, err)
+			assert.Equal(t, strategy, parsed)
+		}
+		_, err := ParseTagStrategy("latest")
+		assert.ErrorContains(t, err, "unknown tag strategy")
+	})
+}
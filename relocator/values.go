@@ -2,8 +2,12 @@ package relocator
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
 	cu "github.com/vmware-labs/distribution-tooling-for-helm/chartutils"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
 
 	"helm.sh/helm/v3/pkg/chartutil"
@@ -15,14 +19,17 @@ func RelocateValues(chartDir string, prefix string, opts ...cu.Option) (string,
 	if err != nil {
 		return "", fmt.Errorf("failed to relocate values: %w", err)
 	}
-	res, err := relocateValues(c, prefix)
+	res, err := relocateValues(c, relocation{prefix: prefix}, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to relocate values: %w", err)
 	}
 	return string(res.Data), nil
 }
 
-func relocateValuesData(valuesData []byte, prefix string) (*RelocationResult, error) {
+// relocateValuesData rewrites the image elements (registry, repository and tag maps) found in valuesData and,
+// guided by the chart annotated images, the other values referencing them. Images with an entry in digests
+// are referenced by that digest where the values allow it
+func relocateValuesData(valuesData []byte, r relocation, images imagelock.ImageList, digests map[string]digest.Digest) (*RelocationResult, error) {
 	valuesMap, err := chartutil.ReadValues(valuesData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse Helm chart values: %v", err)
@@ -31,14 +38,24 @@ func relocateValuesData(valuesData []byte, prefix string) (*RelocationResult, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to find Helm chart image elements from values.yaml: %v", err)
 	}
-	if len(imageElems) == 0 {
+	idx := newAnnotatedImagesIndex(images, digests)
+	data, err := findAnnotatedImageValues(map[string]interface{}(valuesMap), "$", idx, r)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error relocating: %v", err)
+	}
+	count := len(imageElems) + len(data)
+	if count == 0 {
 		return &RelocationResult{Data: valuesData, Count: 0}, nil
 	}
 
-	data := make(map[string]string, 0)
 	for _, e := range imageElems {
-		err := e.Relocate(prefix)
-		if err != nil {
+		// Look the image up by its tag, as the digest may be the one to replace
+		byTag := *e
+		byTag.Digest = ""
+		if d, ok := idx.digest(byTag.URL()); ok {
+			e.Digest = d.String()
+		}
+		if err := relocateImageElement(e, r); err != nil {
 			return nil, fmt.Errorf("unexpected error relocating: %v", err)
 		}
 		for k, v := range e.YamlReplaceMap() {
@@ -49,13 +66,225 @@ func relocateValuesData(valuesData []byte, prefix string) (*RelocationResult, er
 	if err != nil {
 		return nil, fmt.Errorf("unexpected error relocating: %v", err)
 	}
-	return &RelocationResult{Data: relocatedData, Count: len(imageElems)}, nil
+	return &RelocationResult{Data: relocatedData, Count: count}, nil
+}
+
+// annotatedImagesIndex allows looking up the values referencing the chart annotated images
+type annotatedImagesIndex struct {
+	// references contains the references of the images, as annotated
+	references map[string]struct{}
+	// images contains the normalized references of the images
+	images map[string]struct{}
+	// repositories contains the normalized repositories of the images
+	repositories map[string]struct{}
+	// digests contains the digests to pin the images to, keyed by their normalized references
+	digests map[string]digest.Digest
+}
+
+func newAnnotatedImagesIndex(images imagelock.ImageList, digests map[string]digest.Digest) *annotatedImagesIndex {
+	idx := &annotatedImagesIndex{
+		references: make(map[string]struct{}), images: make(map[string]struct{}), repositories: make(map[string]struct{}),
+	}
+	for _, img := range images {
+		idx.references[img.Image] = struct{}{}
+		ref, err := name.ParseReference(img.Image)
+		if err != nil {
+			continue
+		}
+		idx.images[ref.Name()] = struct{}{}
+		idx.repositories[ref.Context().Name()] = struct{}{}
+	}
+	idx.digests = normalizeDigests(digests)
+	return idx
+}
+
+// digest returns the digest to pin image to, if any
+func (idx *annotatedImagesIndex) digest(image string) (digest.Digest, bool) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", false
+	}
+	d, ok := idx.digests[ref.Name()]
+	return d, ok
+}
+
+// hasImage returns true if image is one of the annotated images, either referenced exactly as annotated or
+// by a full reference, including the registry and the tag or digest. Other values, such as a plain "nginx",
+// are not considered image references, even if they normalize to an annotated image
+func (idx *annotatedImagesIndex) hasImage(image string) bool {
+	if _, ok := idx.references[image]; ok {
+		return true
+	}
+	if !isFullImageReference(image) {
+		return false
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+	_, ok := idx.images[ref.Name()]
+	return ok
+}
+
+// isFullImageReference returns true if image explicitly includes its registry and its tag or digest
+func isFullImageReference(image string) bool {
+	return hasExplicitRegistry(image) && hasTagOrDigest(image)
+}
+
+// hasExplicitRegistry returns true if the first component of repository is a registry host, as docker
+// considers it: a host with a domain or a port, or localhost
+func hasExplicitRegistry(repository string) bool {
+	host, _, found := strings.Cut(repository, "/")
+	if !found {
+		return false
+	}
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
+
+// relocate returns the relocated image reference, referencing it by digest if pinned
+func (idx *annotatedImagesIndex) relocate(image string, r relocation) (string, error) {
+	d, pinned := idx.digest(image)
+	relocated, err := r.relocateURL(image, !pinned)
+	if err != nil {
+		return "", err
+	}
+	if pinned {
+		relocated = fmt.Sprintf("%s@%s", relocated, d)
+	}
+	return relocated, nil
+}
+
+// hasRepository returns true if repository, including its registry, is the repository of one of the
+// annotated images
+func (idx *annotatedImagesIndex) hasRepository(repository string) bool {
+	if !hasExplicitRegistry(repository) {
+		return false
+	}
+	repo, err := name.NewRepository(repository)
+	if err != nil {
+		return false
+	}
+	_, ok := idx.repositories[repo.Name()]
+	return ok
+}
+
+// findAnnotatedImageValues returns the relocated values, keyed by their YAML path, of the values referencing
+// the annotated images that are not image elements: full image references ("image: docker.io/bitnami/nginx:1.25.0")
+// and maps with a repository including the registry, and a tag or digest, but no registry key
+func findAnnotatedImageValues(v interface{}, id string, idx *annotatedImagesIndex, r relocation) (map[string]string, error) {
+	data := make(map[string]string)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return findAnnotatedImageValuesInMap(v, id, idx, r)
+	case []interface{}:
+		for i, child := range v {
+			childData, err := findAnnotatedImageValues(child, fmt.Sprintf("%s[%d]", id, i), idx, r)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range childData {
+				data[k] = v
+			}
+		}
+	case string:
+		if idx.hasImage(v) {
+			relocated, err := idx.relocate(v, r)
+			if err != nil {
+				return nil, err
+			}
+			data[id] = relocated
+		}
+	}
+	return data, nil
+}
+
+// findAnnotatedImageValuesInMap is the findAnnotatedImageValues counterpart for maps
+func findAnnotatedImageValuesInMap(m map[string]interface{}, id string, idx *annotatedImagesIndex, r relocation) (map[string]string, error) {
+	data := make(map[string]string)
+	if repository, ok := m["repository"].(string); ok && m["registry"] == nil && imageMapHasTagOrDigest(m) && idx.hasRepository(repository) {
+		if err := relocateRepositoryMap(m, repository, id, idx, r, data); err != nil {
+			return nil, err
+		}
+	}
+	for k, child := range m {
+		if _, isString := child.(string); isString && isImageElementKey(k) {
+			continue
+		}
+		childData, err := findAnnotatedImageValues(child, utils.YamlPathChild(id, k), idx, r)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range childData {
+			data[k] = v
+		}
+	}
+	return data, nil
+}
+
+// imageMapHasTagOrDigest returns true if the image map m sets its tag or digest
+func imageMapHasTagOrDigest(m map[string]interface{}) bool {
+	tag, _ := m["tag"].(string)
+	dgst, _ := m["digest"].(string)
+	return tag != "" || dgst != ""
+}
+
+// relocateRepositoryMap adds to data the relocated values of the map m, whose repository includes the registry
+func relocateRepositoryMap(m map[string]interface{}, repository string, id string, idx *annotatedImagesIndex, r relocation, data map[string]string) error {
+	relocated, err := r.relocateURL(repository, false)
+	if err != nil {
+		return err
+	}
+	data[fmt.Sprintf("%s.repository", id)] = relocated
+	tag, _ := m["tag"].(string)
+	if tag == "" {
+		return nil
+	}
+	if r.tag != "" {
+		data[fmt.Sprintf("%s.tag", id)] = r.tag
+	}
+	if _, hasDigest := m["digest"].(string); hasDigest {
+		if d, ok := idx.digest(fmt.Sprintf("%s:%s", repository, tag)); ok {
+			data[fmt.Sprintf("%s.digest", id)] = d.String()
+		}
+	}
+	return nil
+}
+
+// relocateImageElement relocates the registry and repository of the image element, retagging it if requested
+func relocateImageElement(e *cu.ValuesImageElement, r relocation) error {
+	newURL, err := r.relocateURL(e.URL(), false)
+	if err != nil {
+		return fmt.Errorf("failed to relocate")
+	}
+	newRef, err := name.ParseReference(newURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse relocated URL: %v", err)
+	}
+	e.Registry = newRef.Context().Registry.RegistryStr()
+	e.Repository = newRef.Context().RepositoryStr()
+	if e.Tag != "" && r.tag != "" {
+		e.Tag = r.tag
+	}
+	return nil
+}
+
+// isImageElementKey returns true if key is one of the keys of image elements, handled separately
+func isImageElementKey(key string) bool {
+	switch key {
+	case "registry", "repository", "tag", "digest":
+		return true
+	}
+	return false
 }
 
-func relocateValues(c *cu.Chart, prefix string) (*RelocationResult, error) {
+// relocateValues relocates the chart values.yaml, guided by the chart annotated images and the provided
+// parentImages, as subcharts annotations may not list the images their parents do
+func relocateValues(c *cu.Chart, r relocation, digests map[string]digest.Digest, parentImages ...*imagelock.ChartImage) (*RelocationResult, error) {
 	valuesFile := c.ValuesFile()
 	if valuesFile == nil {
 		return &RelocationResult{}, nil
 	}
-	return relocateValuesData(valuesFile.Data, prefix)
+	// Annotations errors are reported when relocating them
+	images, _ := c.GetAnnotatedImages()
+	return relocateValuesData(valuesFile.Data, r, append(images, parentImages...), digests)
 }
@@ -4,8 +4,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
 	tu "github.com/vmware-labs/distribution-tooling-for-helm/internal/testutil"
 )
 
@@ -30,3 +32,123 @@ func TestRelocateValues(t *testing.T) {
 
 	assert.Equal(t, expectedValues, newValues)
 }
+
+func TestRelocateAnnotatedImageValues(t *testing.T) {
+	images := imagelock.ImageList{
+		{Name: "wordpress", Image: "docker.io/bitnami/wordpress:6.2.2"},
+		{Name: "nginx", Image: "registry.example.com/web/nginx:1.25.0"},
+		{Name: "proxy", Image: "nginx:latest"},
+	}
+	values := `
+image:
+  registry: docker.io
+  repository: bitnami/wordpress
+  tag: 6.2.2
+sidecar:
+  image: registry.example.com/web/nginx:1.25.0
+proxy:
+  image:
+    repository: registry.example.com/web/nginx
+    tag: 1.25.0
+initContainers:
+  - name: init
+    image: docker.io/bitnami/wordpress:6.2.2
+unrelated:
+  image: docker.io/library/busybox:1.36
+  repository: docker.io/library/busybox
+plain:
+  name: nginx
+  release: nginx:1.0
+  cache:
+    repository: docker.io/library/nginx
+annotated:
+  image: nginx:latest
+app.kubernetes.io/sidecar:
+  image: registry.example.com/web/nginx:1.25.0
+`
+	expected := `
+image:
+  registry: harbor.example.com
+  repository: library/bitnami/wordpress
+  tag: 6.2.2
+sidecar:
+  image: harbor.example.com/library/web/nginx:1.25.0
+proxy:
+  image:
+    repository: harbor.example.com/library/web/nginx
+    tag: 1.25.0
+initContainers:
+  - name: init
+    image: harbor.example.com/library/bitnami/wordpress:6.2.2
+unrelated:
+  image: docker.io/library/busybox:1.36
+  repository: docker.io/library/busybox
+plain:
+  name: nginx
+  release: nginx:1.0
+  cache:
+    repository: docker.io/library/nginx
+annotated:
+  image: harbor.example.com/library/library/nginx:latest
+app.kubernetes.io/sidecar:
+  image: harbor.example.com/library/web/nginx:1.25.0
+`
+	res, err := relocateValuesData([]byte(values), relocation{prefix: "harbor.example.com/library"}, images, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 6, res.Count)
+
+	got, err := tu.NormalizeYAML(string(res.Data))
+	require.NoError(t, err)
+	want, err := tu.NormalizeYAML(expected)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestRelocateValuesPinningDigests(t *testing.T) {
+	images := imagelock.ImageList{
+		{Name: "wordpress", Image: "docker.io/bitnami/wordpress:6.2.2"},
+		{Name: "nginx", Image: "registry.example.com/web/nginx:1.25.0"},
+		{Name: "redis", Image: "docker.io/bitnami/redis:7.0.11"},
+	}
+	digests := map[string]digest.Digest{
+		"docker.io/bitnami/wordpress:6.2.2":     "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"registry.example.com/web/nginx:1.25.0": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		"docker.io/bitnami/redis:7.0.11":        "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+	}
+	values := `
+image:
+  registry: docker.io
+  repository: bitnami/wordpress
+  tag: 6.2.2
+  digest: ""
+sidecar:
+  image: registry.example.com/web/nginx:1.25.0
+cache:
+  image:
+    repository: docker.io/bitnami/redis
+    tag: 7.0.11
+    digest: ""
+`
+	expected := `
+image:
+  registry: harbor.example.com
+  repository: library/bitnami/wordpress
+  tag: 6.2.2
+  digest: sha256:1111111111111111111111111111111111111111111111111111111111111111
+sidecar:
+  image: harbor.example.com/library/web/nginx@sha256:2222222222222222222222222222222222222222222222222222222222222222
+cache:
+  image:
+    repository: harbor.example.com/library/bitnami/redis
+    tag: 7.0.11
+    digest: sha256:3333333333333333333333333333333333333333333333333333333333333333
+`
+	res, err := relocateValuesData([]byte(values), relocation{prefix: "harbor.example.com/library"}, images, digests)
+	require.NoError(t, err)
+
+	got, err := tu.NormalizeYAML(string(res.Data))
+	require.NoError(t, err)
+	want, err := tu.NormalizeYAML(expected)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
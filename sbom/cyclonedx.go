@@ -0,0 +1,95 @@
+package sbom
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	BOMRef     string        `json:"bom-ref"`
+	Type       string        `json:"type"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxDocument struct {
+	BOMFormat    string `json:"bomFormat"`
+	SpecVersion  string `json:"specVersion"`
+	SerialNumber string `json:"serialNumber"`
+	Version      int    `json:"version"`
+	Metadata     struct {
+		Timestamp string `json:"timestamp"`
+		Tools     []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"tools"`
+		Component cdxComponent `json:"component"`
+	} `json:"metadata"`
+	Components []cdxComponent `json:"components"`
+}
+
+func newCycloneDXDocument(lock *imagelock.ImagesLock, cfg *Config) *cdxDocument {
+	doc := &cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: fmt.Sprintf("urn:uuid:%s", uuid.New()),
+		Version:      1,
+		Components:   make([]cdxComponent, 0),
+	}
+	doc.Metadata.Timestamp = cfg.CreationTime.UTC().Format(time.RFC3339)
+	doc.Metadata.Tools = append(doc.Metadata.Tools, struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}{Name: cfg.ToolName, Version: cfg.ToolVersion})
+	doc.Metadata.Component = cdxComponent{
+		BOMRef:  fmt.Sprintf("chart:%s@%s", lock.Chart.Name, lock.Chart.Version),
+		Type:    "application",
+		Name:    lock.Chart.Name,
+		Version: lock.Chart.Version,
+		Properties: []cdxProperty{
+			{Name: "helm:appVersion", Value: lock.Chart.AppVersion},
+		},
+	}
+
+	// bom-ref values must be unique, so images shared by several charts are only listed once
+	done := make(map[string]struct{})
+	for _, img := range lock.Images {
+		for _, digest := range img.Digests {
+			purl := imagePURL(img.Image, digest)
+			if _, found := done[purl]; found {
+				continue
+			}
+			done[purl] = struct{}{}
+			doc.Components = append(doc.Components, cdxComponent{
+				BOMRef:  purl,
+				Type:    "container",
+				Name:    img.Name,
+				Version: digest.Digest.String(),
+				PURL:    purl,
+				Hashes:  []cdxHash{{Alg: "SHA-256", Content: digest.Digest.Encoded()}},
+				Properties: []cdxProperty{
+					{Name: "image", Value: img.Image},
+					{Name: "platform", Value: digest.Arch},
+					{Name: "helm:chart", Value: img.Chart},
+				},
+			})
+		}
+	}
+	return doc
+}
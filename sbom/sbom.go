@@ -0,0 +1,131 @@
+// Package sbom implements the generation of Software Bill of Materials documents
+// describing a wrapped Helm chart and its bundled images
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+// Format defines a SBOM document format
+type Format string
+
+const (
+	// SPDX defines the SPDX 2.3 JSON format
+	SPDX Format = "spdx"
+	// CycloneDX defines the CycloneDX 1.5 JSON format
+	CycloneDX Format = "cyclonedx"
+)
+
+// SupportedFormats returns the list of supported SBOM formats
+func SupportedFormats() []Format {
+	return []Format{SPDX, CycloneDX}
+}
+
+// ParseFormat returns the Format represented by str
+func ParseFormat(str string) (Format, error) {
+	for _, f := range SupportedFormats() {
+		if strings.EqualFold(string(f), str) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported SBOM format %q", str)
+}
+
+// FileName returns the default file name used to store documents in the format
+func (f Format) FileName() string {
+	switch f {
+	case CycloneDX:
+		return "sbom.cdx.json"
+	default:
+		return "sbom.spdx.json"
+	}
+}
+
+// Config defines the configuration used when generating SBOM documents
+type Config struct {
+	ToolName     string
+	ToolVersion  string
+	CreationTime time.Time
+}
+
+// Option defines a Config option
+type Option func(*Config)
+
+// WithToolVersion sets the version of the tool reported as the document creator
+func WithToolVersion(version string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.ToolVersion = version
+	}
+}
+
+// WithCreationTime sets the document creation timestamp
+func WithCreationTime(t time.Time) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.CreationTime = t
+	}
+}
+
+// NewConfig returns a new Config with default values
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{
+		ToolName:     "dt",
+		ToolVersion:  "unknown",
+		CreationTime: time.Now().UTC(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Write serializes the SBOM document in the provided format describing the chart and images in lock
+func Write(w io.Writer, lock *imagelock.ImagesLock, format Format, opts ...Option) error {
+	cfg := NewConfig(opts...)
+	var doc interface{}
+	switch format {
+	case SPDX:
+		doc = newSPDXDocument(lock, cfg)
+	case CycloneDX:
+		doc = newCycloneDXDocument(lock, cfg)
+	default:
+		return fmt.Errorf("unsupported SBOM format %q", format)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to serialize SBOM: %w", err)
+	}
+	return nil
+}
+
+// imagePURL returns the package URL for the image at the specified digest
+func imagePURL(image string, digest imagelock.DigestInfo) string {
+	repositoryURL := image
+	tag := ""
+	if ref, err := name.ParseReference(image); err == nil {
+		repositoryURL = ref.Context().Name()
+		if t, ok := ref.(name.Tag); ok {
+			tag = t.TagStr()
+		}
+	}
+	// purl qualifiers must be sorted lexicographically
+	qualifiers := make([]string, 0)
+	if digest.Arch != "" {
+		platform := strings.SplitN(digest.Arch, "/", 2)
+		qualifiers = append(qualifiers, fmt.Sprintf("arch=%s", platform[len(platform)-1]))
+	}
+	qualifiers = append(qualifiers, fmt.Sprintf("repository_url=%s", repositoryURL))
+	if tag != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("tag=%s", tag))
+	}
+	return fmt.Sprintf("pkg:oci/%s@%s?%s",
+		path.Base(repositoryURL), strings.Replace(digest.Digest.String(), ":", "%3A", 1), strings.Join(qualifiers, "&"))
+}
@@ -0,0 +1,86 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+func sampleLock() *imagelock.ImagesLock {
+	lock := imagelock.NewImagesLock()
+	lock.Chart.Name = "wordpress"
+	lock.Chart.Version = "1.0.0"
+	lock.Chart.AppVersion = "6.2.2"
+	lock.Images = imagelock.ImageList{
+		{
+			Name:  "wordpress",
+			Chart: "wordpress",
+			Image: "docker.io/bitnami/wordpress:6.2.2-debian-11-r11",
+			Digests: []imagelock.DigestInfo{
+				{Arch: "linux/amd64", Digest: digest.Digest("sha256:a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f")},
+				{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")},
+			},
+		},
+	}
+	return lock
+}
+
+func TestParseFormat(t *testing.T) {
+	for str, expected := range map[string]Format{"spdx": SPDX, "SPDX": SPDX, "cyclonedx": CycloneDX} {
+		f, err := ParseFormat(str)
+		require.NoError(t, err)
+		assert.Equal(t, expected, f)
+	}
+	_, err := ParseFormat("unknown")
+	assert.ErrorContains(t, err, `unsupported SBOM format "unknown"`)
+}
+
+func TestImagePURL(t *testing.T) {
+	d := imagelock.DigestInfo{Arch: "linux/arm64", Digest: digest.Digest("sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7")}
+	assert.Equal(t,
+		"pkg:oci/wordpress@sha256%3A1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7?arch=arm64&repository_url=index.docker.io/bitnami/wordpress&tag=6.2.2",
+		imagePURL("bitnami/wordpress:6.2.2", d),
+	)
+}
+
+func TestWrite(t *testing.T) {
+	creationTime := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("SPDX", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, Write(buff, sampleLock(), SPDX, WithToolVersion("1.2.3"), WithCreationTime(creationTime)))
+		doc := &spdxDocument{}
+		require.NoError(t, json.Unmarshal(buff.Bytes(), doc))
+
+		assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+		assert.Equal(t, "2023-08-01T10:00:00Z", doc.CreationInfo.Created)
+		assert.Equal(t, []string{"Tool: dt-1.2.3"}, doc.CreationInfo.Creators)
+		// The chart plus one package per image digest
+		require.Len(t, doc.Packages, 3)
+		assert.Equal(t, "wordpress", doc.Packages[0].Name)
+		assert.Equal(t, "1.0.0", doc.Packages[0].VersionInfo)
+		assert.Equal(t, "a410341508b8823774448bc457730e38d2f047497ffddf090553845493c62e0f", doc.Packages[1].Checksums[0].ChecksumValue)
+		assert.Len(t, doc.Relationships, 3)
+	})
+	t.Run("CycloneDX", func(t *testing.T) {
+		buff := &bytes.Buffer{}
+		require.NoError(t, Write(buff, sampleLock(), CycloneDX, WithCreationTime(creationTime)))
+		doc := &cdxDocument{}
+		require.NoError(t, json.Unmarshal(buff.Bytes(), doc))
+
+		assert.Equal(t, "CycloneDX", doc.BOMFormat)
+		assert.Equal(t, "wordpress", doc.Metadata.Component.Name)
+		require.Len(t, doc.Components, 2)
+		assert.Equal(t, "container", doc.Components[1].Type)
+		assert.Equal(t, "sha256:1e5991a54bc98871e61dd7f94697f86b5dc4e2b2560d5590ff292038a6434ba7", doc.Components[1].Version)
+	})
+	t.Run("Unsupported format", func(t *testing.T) {
+		assert.ErrorContains(t, Write(&bytes.Buffer{}, sampleLock(), Format("foo"), WithCreationTime(creationTime)), "unsupported SBOM format")
+	})
+}
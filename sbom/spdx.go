@@ -0,0 +1,116 @@
+package sbom
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+)
+
+var spdxInvalidIDChars = regexp.MustCompile(`[^a-zA-Z0-9.\-]+`)
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string `json:"spdxVersion"`
+	DataLicense       string `json:"dataLicense"`
+	SPDXID            string `json:"SPDXID"`
+	Name              string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo      struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages      []spdxPackage      `json:"packages"`
+	Relationships []spdxRelationship `json:"relationships"`
+}
+
+func spdxID(parts ...interface{}) string {
+	id := "SPDXRef"
+	for _, p := range parts {
+		id += "-" + spdxInvalidIDChars.ReplaceAllString(fmt.Sprint(p), "-")
+	}
+	return id
+}
+
+func newSPDXDocument(lock *imagelock.ImagesLock, cfg *Config) *spdxDocument {
+	docName := fmt.Sprintf("%s-%s", lock.Chart.Name, lock.Chart.Version)
+
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              docName,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s", docName, uuid.New()),
+		Packages:          make([]spdxPackage, 0),
+		Relationships:     make([]spdxRelationship, 0),
+	}
+	doc.CreationInfo.Created = cfg.CreationTime.UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{fmt.Sprintf("Tool: %s-%s", cfg.ToolName, cfg.ToolVersion)}
+
+	chartID := spdxID("Chart", lock.Chart.Name)
+	doc.Packages = append(doc.Packages, spdxPackage{
+		SPDXID:           chartID,
+		Name:             lock.Chart.Name,
+		VersionInfo:      lock.Chart.Version,
+		DownloadLocation: "NOASSERTION",
+		PrimaryPurpose:   "APPLICATION",
+		Comment:          fmt.Sprintf("Helm chart (appVersion %s)", lock.Chart.AppVersion),
+	})
+	doc.Relationships = append(doc.Relationships, spdxRelationship{
+		SPDXElementID: doc.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: chartID,
+	})
+
+	for i, img := range lock.Images {
+		for _, digest := range img.Digests {
+			pkgID := spdxID("Image", i, img.Chart, img.Name, digest.Arch)
+			doc.Packages = append(doc.Packages, spdxPackage{
+				SPDXID:           pkgID,
+				Name:             img.Name,
+				VersionInfo:      digest.Digest.String(),
+				DownloadLocation: "NOASSERTION",
+				PrimaryPurpose:   "CONTAINER",
+				Checksums: []spdxChecksum{
+					{Algorithm: "SHA256", ChecksumValue: digest.Digest.Encoded()},
+				},
+				ExternalRefs: []spdxExternalRef{
+					{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: imagePURL(img.Image, digest)},
+				},
+				Comment: fmt.Sprintf("Image %s (%s) used by Helm chart %q", img.Image, digest.Arch, img.Chart),
+			})
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID: chartID, RelationshipType: "CONTAINS", RelatedSPDXElement: pkgID,
+			})
+		}
+	}
+	return doc
+}
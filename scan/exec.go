@@ -0,0 +1,105 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runJSONCommand executes the provided binary and decodes its JSON output into out
+func runJSONCommand(ctx context.Context, out interface{}, binary string, args ...string) error {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to execute %q: %v: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		return fmt.Errorf("failed to parse %q output: %w", binary, err)
+	}
+	return nil
+}
+
+// TrivyScanner defines a Scanner implemented using the trivy command line tool
+type TrivyScanner struct {
+	Binary string
+}
+
+// NewTrivyScanner returns a new TrivyScanner
+func NewTrivyScanner() *TrivyScanner {
+	return &TrivyScanner{Binary: "trivy"}
+}
+
+// Name returns the scanner name
+func (s *TrivyScanner) Name() string {
+	return "trivy"
+}
+
+// Scan scans the image stored in the provided docker-archive tarball
+func (s *TrivyScanner) Scan(ctx context.Context, imageTar string) ([]Vulnerability, error) {
+	var report struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				Severity         string
+			}
+		}
+	}
+	if err := runJSONCommand(ctx, &report, s.Binary, "image", "--quiet", "--format", "json", "--input", imageTar); err != nil {
+		return nil, err
+	}
+	vulns := make([]Vulnerability, 0)
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			severity, _ := ParseSeverity(v.Severity)
+			vulns = append(vulns, Vulnerability{ID: v.VulnerabilityID, Package: v.PkgName, InstalledVersion: v.InstalledVersion, Severity: severity})
+		}
+	}
+	return vulns, nil
+}
+
+// GrypeScanner defines a Scanner implemented using the grype command line tool
+type GrypeScanner struct {
+	Binary string
+}
+
+// NewGrypeScanner returns a new GrypeScanner
+func NewGrypeScanner() *GrypeScanner {
+	return &GrypeScanner{Binary: "grype"}
+}
+
+// Name returns the scanner name
+func (s *GrypeScanner) Name() string {
+	return "grype"
+}
+
+// Scan scans the image stored in the provided docker-archive tarball
+func (s *GrypeScanner) Scan(ctx context.Context, imageTar string) ([]Vulnerability, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID       string `json:"id"`
+				Severity string `json:"severity"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := runJSONCommand(ctx, &report, s.Binary, fmt.Sprintf("docker-archive:%s", imageTar), "--quiet", "-o", "json"); err != nil {
+		return nil, err
+	}
+	vulns := make([]Vulnerability, 0)
+	for _, m := range report.Matches {
+		severity, _ := ParseSeverity(m.Vulnerability.Severity)
+		vulns = append(vulns, Vulnerability{ID: m.Vulnerability.ID, Package: m.Artifact.Name, InstalledVersion: m.Artifact.Version, Severity: severity})
+	}
+	return vulns, nil
+}
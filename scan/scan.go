@@ -0,0 +1,123 @@
+// Package scan implements a pluggable interface to scan container images
+// for vulnerabilities
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity defines the severity of a vulnerability
+type Severity int
+
+const (
+	// SeverityUnknown defines a vulnerability with an unknown severity
+	SeverityUnknown Severity = iota
+	// SeverityLow defines a low severity vulnerability
+	SeverityLow
+	// SeverityMedium defines a medium severity vulnerability
+	SeverityMedium
+	// SeverityHigh defines a high severity vulnerability
+	SeverityHigh
+	// SeverityCritical defines a critical severity vulnerability
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "UNKNOWN",
+	SeverityLow:      "LOW",
+	SeverityMedium:   "MEDIUM",
+	SeverityHigh:     "HIGH",
+	SeverityCritical: "CRITICAL",
+}
+
+// String returns the string representation of the Severity
+func (s Severity) String() string {
+	if str, ok := severityNames[s]; ok {
+		return str
+	}
+	return severityNames[SeverityUnknown]
+}
+
+// ParseSeverity returns the Severity represented by str
+func ParseSeverity(str string) (Severity, error) {
+	for s, name := range severityNames {
+		if strings.EqualFold(name, str) {
+			return s, nil
+		}
+	}
+	// Some scanners use "negligible" for the lowest level
+	if strings.EqualFold(str, "negligible") {
+		return SeverityLow, nil
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q", str)
+}
+
+// Vulnerability defines a vulnerability found in an image
+type Vulnerability struct {
+	ID               string
+	Package          string
+	InstalledVersion string
+	Severity         Severity
+}
+
+// Report defines the result of scanning an image
+type Report struct {
+	Image           string
+	Arch            string
+	Vulnerabilities []Vulnerability
+}
+
+// AtOrAbove returns the list of vulnerabilities with a severity equal or higher than threshold
+func (r *Report) AtOrAbove(threshold Severity) []Vulnerability {
+	res := make([]Vulnerability, 0)
+	for _, v := range r.Vulnerabilities {
+		if v.Severity >= threshold {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Summary returns a short text describing the number of vulnerabilities per severity
+func (r *Report) Summary() string {
+	counts := make(map[Severity]int)
+	for _, v := range r.Vulnerabilities {
+		counts[v.Severity]++
+	}
+	if len(counts) == 0 {
+		return "no vulnerabilities found"
+	}
+	severities := make([]Severity, 0, len(counts))
+	for s := range counts {
+		severities = append(severities, s)
+	}
+	sort.Slice(severities, func(i, j int) bool { return severities[i] > severities[j] })
+	parts := make([]string, 0, len(severities))
+	for _, s := range severities {
+		parts = append(parts, fmt.Sprintf("%s: %d", s, counts[s]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Scanner defines the interface implemented by vulnerability scanners
+type Scanner interface {
+	// Name returns the scanner name
+	Name() string
+	// Scan scans the image stored in the provided docker-archive tarball
+	Scan(ctx context.Context, imageTar string) ([]Vulnerability, error)
+}
+
+// New returns the Scanner registered with the provided name
+func New(name string) (Scanner, error) {
+	switch strings.ToLower(name) {
+	case "trivy":
+		return NewTrivyScanner(), nil
+	case "grype":
+		return NewGrypeScanner(), nil
+	default:
+		return nil, fmt.Errorf("unsupported scanner %q (supported: trivy, grype)", name)
+	}
+}
@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFakeScanner(t *testing.T, output string) string {
+	script := filepath.Join(t.TempDir(), "scanner")
+	data := fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\n", output)
+	require.NoError(t, os.WriteFile(script, []byte(data), 0755))
+	return script
+}
+
+func TestParseSeverity(t *testing.T) {
+	for str, expected := range map[string]Severity{
+		"critical": SeverityCritical, "HIGH": SeverityHigh, "Medium": SeverityMedium,
+		"low": SeverityLow, "Negligible": SeverityLow, "unknown": SeverityUnknown,
+	} {
+		s, err := ParseSeverity(str)
+		require.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+	_, err := ParseSeverity("terrible")
+	assert.ErrorContains(t, err, `unknown severity "terrible"`)
+}
+
+func TestReport(t *testing.T) {
+	r := &Report{Vulnerabilities: []Vulnerability{
+		{ID: "CVE-1", Severity: SeverityCritical},
+		{ID: "CVE-2", Severity: SeverityLow},
+		{ID: "CVE-3", Severity: SeverityHigh},
+		{ID: "CVE-4", Severity: SeverityHigh},
+	}}
+	assert.Len(t, r.AtOrAbove(SeverityHigh), 3)
+	assert.Len(t, r.AtOrAbove(SeverityCritical), 1)
+	assert.Equal(t, "CRITICAL: 1, HIGH: 2, LOW: 1", r.Summary())
+	assert.Equal(t, "no vulnerabilities found", (&Report{}).Summary())
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"trivy", "grype"} {
+		s, err := New(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, s.Name())
+	}
+	_, err := New("foo")
+	assert.ErrorContains(t, err, `unsupported scanner "foo"`)
+}
+
+func TestTrivyScanner(t *testing.T) {
+	s := NewTrivyScanner()
+	s.Binary = writeFakeScanner(t, `{"Results": [{"Vulnerabilities": [
+		{"VulnerabilityID": "CVE-2023-0001", "PkgName": "openssl", "InstalledVersion": "1.1.1", "Severity": "CRITICAL"},
+		{"VulnerabilityID": "CVE-2023-0002", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "LOW"}
+	]}]}`)
+	vulns, err := s.Scan(context.Background(), "image.tar")
+	require.NoError(t, err)
+	assert.Equal(t, []Vulnerability{
+		{ID: "CVE-2023-0001", Package: "openssl", InstalledVersion: "1.1.1", Severity: SeverityCritical},
+		{ID: "CVE-2023-0002", Package: "zlib", InstalledVersion: "1.2", Severity: SeverityLow},
+	}, vulns)
+
+	t.Run("Fails on malformed output", func(t *testing.T) {
+		s.Binary = writeFakeScanner(t, `not json`)
+		_, err := s.Scan(context.Background(), "image.tar")
+		assert.ErrorContains(t, err, "failed to parse")
+	})
+	t.Run("Fails if the binary fails", func(t *testing.T) {
+		s.Binary = filepath.Join(t.TempDir(), "missing")
+		_, err := s.Scan(context.Background(), "image.tar")
+		assert.ErrorContains(t, err, "failed to execute")
+	})
+}
+
+func TestGrypeScanner(t *testing.T) {
+	s := NewGrypeScanner()
+	s.Binary = writeFakeScanner(t, `{"matches": [
+		{"vulnerability": {"id": "CVE-2023-0003", "severity": "High"}, "artifact": {"name": "curl", "version": "7.0"}}
+	]}`)
+	vulns, err := s.Scan(context.Background(), "image.tar")
+	require.NoError(t, err)
+	assert.Equal(t, []Vulnerability{
+		{ID: "CVE-2023-0003", Package: "curl", InstalledVersion: "7.0", Severity: SeverityHigh},
+	}, vulns)
+}
@@ -0,0 +1,264 @@
+// Package signature implements the creation and verification of detached OpenPGP
+// signatures for wrapped Helm charts
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"        //nolint:staticcheck // Same implementation used by Helm provenance files
+	"golang.org/x/crypto/openpgp/packet" //nolint:staticcheck // Same implementation used by Helm provenance files
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// Extension is the extension appended to files to obtain their signature file
+const Extension = ".asc"
+
+// FileName returns the default detached signature file name for file
+func FileName(file string) string {
+	return file + Extension
+}
+
+// DefaultSecretKeyring returns the default location of the GnuPG secret keyring
+func DefaultSecretKeyring() string {
+	return defaultKeyring("secring.gpg")
+}
+
+// DefaultPublicKeyring returns the default location of the GnuPG public keyring
+func DefaultPublicKeyring() string {
+	return defaultKeyring("pubring.gpg")
+}
+
+func defaultKeyring(name string) string {
+	if dir := os.Getenv("GNUPGHOME"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".gnupg", name)
+	}
+	return filepath.Join(home, ".gnupg", name)
+}
+
+// Config defines the configuration used when signing and verifying files
+type Config struct {
+	// Keyring is the path to the keyring (secret keyring when signing, public when verifying)
+	Keyring string
+	// KeyName selects the signing key by user id substring or key id
+	KeyName string
+	// Passphrase decrypts the signing key, if protected
+	Passphrase []byte
+	// Fingerprint, if not empty, is the fingerprint of the only key accepted when verifying
+	Fingerprint string
+}
+
+// Option defines a Config option
+type Option func(*Config)
+
+// WithKeyring sets the keyring to use
+func WithKeyring(keyring string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Keyring = keyring
+	}
+}
+
+// WithKeyName selects the key used for signing
+func WithKeyName(name string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.KeyName = name
+	}
+}
+
+// WithPassphrase provides the passphrase protecting the signing key
+func WithPassphrase(passphrase []byte) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Passphrase = passphrase
+	}
+}
+
+// WithFingerprint pins the key signatures are verified with
+func WithFingerprint(fingerprint string) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Fingerprint = fingerprint
+	}
+}
+
+// NewConfig returns a new Config
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// readKeyring loads a keyring in either armored or binary format
+func readKeyring(file string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	if entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err == nil {
+		return entities, nil
+	}
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %q: %w", file, err)
+	}
+	return entities, nil
+}
+
+// matchesKey returns true if the entity is identified by name, either by
+// a substring of any of its user ids, its long key id or its fingerprint
+func matchesKey(e *openpgp.Entity, name string) bool {
+	if name == "" {
+		return true
+	}
+	// Short key ids, or any other partial ids, are too easy to collide
+	if strings.EqualFold(fmt.Sprintf("%016X", e.PrimaryKey.KeyId), strings.TrimPrefix(name, "0x")) || matchesFingerprint(e, name) {
+		return true
+	}
+	for id := range e.Identities {
+		if strings.Contains(id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFingerprint returns true if the primary key of the entity has the provided fingerprint, which
+// can include spaces and the 0x prefix, as gpg prints them
+func matchesFingerprint(e *openpgp.Entity, fingerprint string) bool {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(fingerprint, "0x"), " ", ""))
+	return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint) == fingerprint
+}
+
+func findSigningKey(entities openpgp.EntityList, name string) (*openpgp.Entity, error) {
+	candidates := make([]*openpgp.Entity, 0)
+	for _, e := range entities {
+		if e.PrivateKey != nil && matchesKey(e, name) {
+			candidates = append(candidates, e)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		if name == "" {
+			return nil, fmt.Errorf("no private keys found in keyring")
+		}
+		return nil, fmt.Errorf("private key %q not found in keyring", name)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("found %d private keys matching %q, please provide a more specific key name", len(candidates), name)
+	}
+}
+
+func decryptKey(e *openpgp.Entity, passphrase []byte) error {
+	if e.PrivateKey.Encrypted {
+		if err := e.PrivateKey.Decrypt(passphrase); err != nil {
+			return fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+	}
+	for _, sub := range e.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt(passphrase); err != nil {
+				return fmt.Errorf("failed to decrypt private subkey: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadSigner returns the decrypted signing key selected by cfg
+func loadSigner(cfg *Config) (*openpgp.Entity, error) {
+	if cfg.Keyring == "" {
+		cfg.Keyring = DefaultSecretKeyring()
+	}
+	entities, err := readKeyring(cfg.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := findSigningKey(entities, cfg.KeyName)
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptKey(signer, cfg.Passphrase); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// SignFile writes an armored detached signature of file into sigFile
+func SignFile(file string, sigFile string, opts ...Option) error {
+	signer, err := loadSigner(NewConfig(opts...))
+	if err != nil {
+		return err
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", file, err)
+	}
+	defer fh.Close()
+
+	buff := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(buff, signer, fh, &packet.Config{}); err != nil {
+		return fmt.Errorf("failed to sign %q: %w", file, err)
+	}
+	if err := os.WriteFile(sigFile, buff.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write signature to %q: %w", sigFile, err)
+	}
+	return nil
+}
+
+// SignChart writes the Helm provenance file of the packaged chart into provFile
+func SignChart(chartFile string, provFile string, opts ...Option) error {
+	signer, err := loadSigner(NewConfig(opts...))
+	if err != nil {
+		return err
+	}
+	prov, err := (&provenance.Signatory{Entity: signer}).ClearSign(chartFile)
+	if err != nil {
+		return fmt.Errorf("failed to sign Helm chart %q: %w", chartFile, err)
+	}
+	if err := os.WriteFile(provFile, []byte(prov), 0644); err != nil {
+		return fmt.Errorf("failed to write provenance file to %q: %w", provFile, err)
+	}
+	return nil
+}
+
+// VerifyFile checks the armored detached signature sigFile of file, and returns the signer identity
+func VerifyFile(file string, sigFile string, opts ...Option) (string, error) {
+	cfg := NewConfig(opts...)
+	if cfg.Keyring == "" {
+		cfg.Keyring = DefaultPublicKeyring()
+	}
+	entities, err := readKeyring(cfg.Keyring)
+	if err != nil {
+		return "", err
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %q: %w", file, err)
+	}
+	defer fh.Close()
+	sig, err := os.Open(sigFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to open signature: %w", err)
+	}
+	defer sig.Close()
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(entities, fh, sig)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+	if cfg.Fingerprint != "" && !matchesFingerprint(signer, cfg.Fingerprint) {
+		return "", fmt.Errorf("signed by key %X, expected key %s", signer.PrimaryKey.Fingerprint, cfg.Fingerprint)
+	}
+	for id := range signer.Identities {
+		return id, nil
+	}
+	return fmt.Sprintf("%X", signer.PrimaryKey.KeyId), nil
+}
@@ -0,0 +1,114 @@
+package signature
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/utils"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Same implementation used by Helm provenance files
+)
+
+// writeKeyrings generates a new key and returns the paths to the secret and public keyrings
+func writeKeyrings(t *testing.T, name string) (string, string) {
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	secring := filepath.Join(dir, "secring.gpg")
+	fh, err := os.Create(secring)
+	require.NoError(t, err)
+	require.NoError(t, e.SerializePrivate(fh, nil))
+	require.NoError(t, fh.Close())
+
+	pubring := filepath.Join(dir, "pubring.gpg")
+	fh, err = os.Create(pubring)
+	require.NoError(t, err)
+	require.NoError(t, e.Serialize(fh))
+	require.NoError(t, fh.Close())
+	return secring, pubring
+}
+
+func TestSignAndVerify(t *testing.T) {
+	secring, pubring := writeKeyrings(t, "dt")
+	_, otherPubring := writeKeyrings(t, "other")
+
+	file := filepath.Join(t.TempDir(), "chart.wrap.tgz")
+	require.NoError(t, os.WriteFile(file, []byte("wrap"), 0644))
+	sigFile := FileName(file)
+
+	require.NoError(t, SignFile(file, sigFile, WithKeyring(secring), WithKeyName("dt@example.com")))
+	require.FileExists(t, sigFile)
+
+	signer, err := VerifyFile(file, sigFile, WithKeyring(pubring))
+	require.NoError(t, err)
+	assert.Equal(t, "dt <dt@example.com>", signer)
+
+	t.Run("Fails with unknown signer", func(t *testing.T) {
+		_, err := VerifyFile(file, sigFile, WithKeyring(otherPubring))
+		assert.ErrorContains(t, err, "invalid signature")
+	})
+	t.Run("Verifies the signer fingerprint", func(t *testing.T) {
+		entities, err := readKeyring(pubring)
+		require.NoError(t, err)
+		fingerprint := fmt.Sprintf("%X", entities[0].PrimaryKey.Fingerprint)
+
+		signer, err := VerifyFile(file, sigFile, WithKeyring(pubring), WithFingerprint(fingerprint))
+		require.NoError(t, err)
+		assert.Equal(t, "dt <dt@example.com>", signer)
+		_, err = VerifyFile(file, sigFile, WithKeyring(pubring), WithFingerprint("0x"+strings.ToLower(fingerprint)))
+		require.NoError(t, err)
+
+		_, err = VerifyFile(file, sigFile, WithKeyring(pubring), WithFingerprint(strings.Repeat("0", len(fingerprint))))
+		assert.ErrorContains(t, err, "expected key")
+	})
+	t.Run("Fails with modified file", func(t *testing.T) {
+		modified := filepath.Join(t.TempDir(), "modified.wrap.tgz")
+		require.NoError(t, os.WriteFile(modified, []byte("modified"), 0644))
+		_, err := VerifyFile(modified, sigFile, WithKeyring(pubring))
+		assert.ErrorContains(t, err, "invalid signature")
+	})
+	t.Run("Fails with unknown key", func(t *testing.T) {
+		err := SignFile(file, sigFile, WithKeyring(secring), WithKeyName("missing"))
+		assert.ErrorContains(t, err, `private key "missing" not found in keyring`)
+	})
+	t.Run("Fails with missing keyring", func(t *testing.T) {
+		err := SignFile(file, sigFile, WithKeyring(filepath.Join(t.TempDir(), "missing")))
+		assert.ErrorContains(t, err, "failed to read keyring")
+	})
+}
+
+func TestSignChart(t *testing.T) {
+	secring, pubring := writeKeyrings(t, "dt")
+
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: test\nversion: 1.0.0\n"), 0644))
+	chartFile := filepath.Join(t.TempDir(), "test-1.0.0.tgz")
+	require.NoError(t, utils.Tar(chartDir, chartFile, utils.TarConfig{Prefix: "test"}))
+
+	provFile := chartFile + utils.ProvenanceExtension
+	require.NoError(t, SignChart(chartFile, provFile, WithKeyring(secring)))
+	identities, err := utils.VerifyChartProvenance(chartFile, provFile, pubring)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dt <dt@example.com>"}, identities)
+
+	assert.ErrorContains(t, SignChart(chartFile, provFile, WithKeyring(pubring)), "no private keys found")
+}
+
+func TestMatchesKey(t *testing.T) {
+	e, err := openpgp.NewEntity("dt", "", "dt@example.com", nil)
+	require.NoError(t, err)
+	keyID := fmt.Sprintf("%016X", e.PrimaryKey.KeyId)
+	fingerprint := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+
+	for _, name := range []string{"", "dt@example.com", keyID, "0x" + strings.ToLower(keyID), fingerprint, "0x" + fingerprint} {
+		assert.True(t, matchesKey(e, name), name)
+	}
+	for _, name := range []string{keyID[8:], keyID[1:], fingerprint[1:], "other@example.com"} {
+		assert.False(t, matchesKey(e, name), name)
+	}
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// RepositoryPushConfig defines the settings used when uploading charts to a classic Helm chart repository
+type RepositoryPushConfig struct {
+	// Username and Password, if not empty, are the basic auth credentials of the repository
+	Username string
+	Password string
+	// Transport, if not nil, is the HTTP transport used to access the repository
+	Transport http.RoundTripper
+}
+
+// IsChartRepositoryURL returns true if url points to a classic HTTP(S) Helm chart repository instead of an
+// OCI registry
+func IsChartRepositoryURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// UploadChart uploads the local chart tarFile, along with its provenance file if it exists, to the
+// ChartMuseum API (POST /api/charts) of the repository at repoURL
+func UploadChart(tarFile string, repoURL string, cfg RepositoryPushConfig) error {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	files := map[string]string{"chart": tarFile}
+	if FileExists(tarFile + ProvenanceExtension) {
+		files["prov"] = tarFile + ProvenanceExtension
+	}
+	for field, file := range files {
+		if err := addFormFile(w, field, file); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encode Helm chart: %w", err)
+	}
+
+	uploadURL := strings.TrimSuffix(repoURL, "/") + "/api/charts"
+	req, err := http.NewRequest(http.MethodPost, uploadURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to upload Helm chart: %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	client := &http.Client{Transport: cfg.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload Helm chart: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload Helm chart to %q: %s: %s", uploadURL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// addFormFile adds the contents of file to the multipart form field
+func addFormFile(w *multipart.Writer, field string, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", file, err)
+	}
+	part, err := w.CreateFormFile(field, filepath.Base(file))
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", file, err)
+	}
+	_, err = part.Write(data)
+	return err
+}
+
+// transportGetter is a Helm getter downloading http(s) URLs with a custom transport. As Helm does by
+// default, the basic auth credentials are only sent to the host of the chart repository
+type transportGetter struct {
+	client   *http.Client
+	host     string
+	username string
+	password string
+}
+
+// Get implements getter.Getter. The Helm options are ignored, as the getter is configured on creation
+func (g *transportGetter) Get(u string, _ ...getter.Option) (*bytes.Buffer, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if g.username != "" && req.URL.Host == g.host {
+		req.SetBasicAuth(g.username, g.password)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s : %s", u, resp.Status)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	return buf, nil
+}
+
+// repositoryGetters returns the Helm getters used to access the chart repository of fetchCfg, honoring
+// its transport
+func repositoryGetters(fetchCfg FetchConfig) (getter.Providers, error) {
+	u, err := url.Parse(fetchCfg.RepoURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart repository URL %q: %w", fetchCfg.RepoURL, err)
+	}
+	tr := fetchCfg.Transport
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+	g := &transportGetter{client: &http.Client{Transport: tr}, host: u.Host, username: fetchCfg.Username, password: fetchCfg.Password}
+	return getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New:     func(...getter.Option) (getter.Getter, error) { return g, nil },
+	}}, nil
+}
+
+// pullRepositoryChart downloads the chart chartName from the classic Helm chart repository of fetchCfg into
+// destDir, along with its provenance file if it exists
+func pullRepositoryChart(chartName string, version string, destDir string, fetchCfg FetchConfig) error {
+	getters, err := repositoryGetters(fetchCfg)
+	if err != nil {
+		return err
+	}
+	chartURL, err := repo.FindChartInAuthAndTLSAndPassRepoURL(fetchCfg.RepoURL, fetchCfg.Username, fetchCfg.Password,
+		chartName, version, "", "", "", false, false, getters)
+	if err != nil {
+		return err
+	}
+	settings := cli.New()
+	c := downloader.ChartDownloader{
+		Out:              io.Discard,
+		Keyring:          fetchCfg.Keyring,
+		Verify:           downloader.VerifyLater,
+		Getters:          getters,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	if fetchCfg.Keyring != "" {
+		c.Verify = downloader.VerifyAlways
+	}
+	_, _, err = c.DownloadTo(chartURL, version, destDir)
+	return err
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadChart(t *testing.T) {
+	uploaded := make(map[string]string)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized"}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/charts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for _, field := range []string{"chart", "prov"} {
+			f, _, err := r.FormFile(field)
+			if err != nil {
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			uploaded[field] = string(data)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	dir := sb.TempFile()
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	tarFile := filepath.Join(dir, "mariadb-12.2.8.tgz")
+	require.NoError(t, os.WriteFile(tarFile, []byte("chart"), 0644))
+	require.NoError(t, os.WriteFile(tarFile+ProvenanceExtension, []byte("provenance"), 0644))
+
+	assert.True(t, IsChartRepositoryURL(s.URL))
+	assert.False(t, IsChartRepositoryURL("oci://"+s.Listener.Addr().String()))
+
+	t.Run("Uploads the chart and its provenance file", func(t *testing.T) {
+		require.NoError(t, UploadChart(tarFile, s.URL+"/", RepositoryPushConfig{Username: "admin", Password: "secret"}))
+		assert.Equal(t, map[string]string{"chart": "chart", "prov": "provenance"}, uploaded)
+	})
+	t.Run("Reports the repository errors", func(t *testing.T) {
+		err := UploadChart(tarFile, s.URL, RepositoryPushConfig{Username: "admin", Password: "wrong"})
+		assert.ErrorContains(t, err, `401 Unauthorized: {"error":"unauthorized"}`)
+	})
+}
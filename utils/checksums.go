@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFileName is the name of the file containing the checksums of a wrapped chart files
+const ChecksumsFileName = "checksums.sha256"
+
+// FileSHA256 returns the hex encoded sha256 digest of the file
+func FileSHA256(file string) (string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listChecksumFiles returns the sorted list of regular files in dir, relative to it, excluding exclude
+func listChecksumFiles(dir string, exclude string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != exclude {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// WriteChecksums writes into dir/name the sha256 checksums of every file in dir,
+// using the format of the sha256sum tool
+func WriteChecksums(dir string, name string) error {
+	files, err := listChecksumFiles(dir, name)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	buff := &bytes.Buffer{}
+	for _, f := range files {
+		sum, err := FileSHA256(filepath.Join(dir, f))
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum of %q: %w", f, err)
+		}
+		fmt.Fprintf(buff, "%s  %s\n", sum, f)
+	}
+	return os.WriteFile(filepath.Join(dir, name), buff.Bytes(), 0644)
+}
+
+// ReadChecksums parses the checksums stored in file, returning a map of relative file names to sha256 checksums
+func ReadChecksums(file string) (map[string]string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, found := strings.Cut(line, "  ")
+		if !found {
+			return nil, fmt.Errorf("malformed checksum line %q", line)
+		}
+		checksums[name] = sum
+	}
+	return checksums, scanner.Err()
+}
+
+// VerifyChecksums checks the files in dir against the checksums stored in dir/name, reporting
+// modified, missing and unexpected files
+func VerifyChecksums(dir string, name string) error {
+	checksums, err := ReadChecksums(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to read checksums: %w", err)
+	}
+	files, err := listChecksumFiles(dir, name)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	var allErrors error
+	for _, f := range files {
+		expected, ok := checksums[f]
+		if !ok {
+			allErrors = errors.Join(allErrors, fmt.Errorf("unexpected file %q", f))
+			continue
+		}
+		delete(checksums, f)
+		sum, err := FileSHA256(filepath.Join(dir, f))
+		if err != nil {
+			allErrors = errors.Join(allErrors, fmt.Errorf("failed to calculate checksum of %q: %w", f, err))
+			continue
+		}
+		if sum != expected {
+			allErrors = errors.Join(allErrors, fmt.Errorf("checksum mismatch for %q", f))
+		}
+	}
+	missing := make([]string, 0, len(checksums))
+	for f := range checksums {
+		missing = append(missing, f)
+	}
+	sort.Strings(missing)
+	for _, f := range missing {
+		allErrors = errors.Join(allErrors, fmt.Errorf("missing file %q", f))
+	}
+	return allErrors
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksums(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "images"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: test\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "images", "image.tar"), []byte("image"), 0644))
+
+	require.NoError(t, WriteChecksums(dir, ChecksumsFileName))
+	checksums, err := ReadChecksums(filepath.Join(dir, ChecksumsFileName))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Chart.yaml":       "b4b785ee519ceb6a284f99c1ec3b7874e75a8aa8630b7516cb7ea1e49db99087",
+		"images/image.tar": "6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d",
+	}, checksums)
+	require.NoError(t, VerifyChecksums(dir, ChecksumsFileName))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "images", "image.tar"), []byte("corrupted"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "Chart.yaml")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra.txt"), []byte("extra"), 0644))
+
+	err = VerifyChecksums(dir, ChecksumsFileName)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `checksum mismatch for "images/image.tar"`)
+	assert.ErrorContains(t, err, `missing file "Chart.yaml"`)
+	assert.ErrorContains(t, err, `unexpected file "extra.txt"`)
+
+	assert.ErrorContains(t, VerifyChecksums(t.TempDir(), ChecksumsFileName), "failed to read checksums")
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// AvailableDiskSpace returns the bytes available to unprivileged users in the filesystem containing dir
+func AvailableDiskSpace(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, fmt.Errorf("failed to read filesystem stats of %q: %w", dir, err)
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// AvailableDiskSpace returns the bytes available to the current user in the volume containing dir
+func AvailableDiskSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, fmt.Errorf("invalid directory %q: %w", dir, err)
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return 0, fmt.Errorf("failed to read free disk space of %q: %w", dir, err)
+	}
+	return available, nil
+}
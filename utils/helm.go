@@ -1,28 +1,148 @@
 package utils
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/provenance"
 	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
-// FetchRemoteChart retrieves the specified chart
-func FetchRemoteChart(chartURL, version string, destDir string) (string, error) {
-	dir, err := os.MkdirTemp(destDir, "chart-*")
+// ProvenanceExtension is the extension of the Helm chart provenance files
+const ProvenanceExtension = ".prov"
+
+// FetchConfig defines the FetchRemoteChart opts
+type FetchConfig struct {
+	// Keyring, if not empty, makes the chart provenance file mandatory and verifies it against the keyring
+	Keyring string
+	// CredentialsFile, if not empty, provides registry credentials in the docker config format, taking
+	// precedence over the docker ones
+	CredentialsFile string
+	// Transport, if not nil, is the HTTP transport used to access OCI registries and chart repositories
+	Transport http.RoundTripper
+	// RepoURL, if not empty, is the classic Helm chart repository the chart is fetched from, the chart
+	// URL being its name in the repository
+	RepoURL string
+	// Username and Password, if not empty, are the basic auth credentials of RepoURL
+	Username string
+	Password string
+}
+
+// PushConfig defines the settings used when pushing charts
+type PushConfig struct {
+	// CredentialsFile, if not empty, provides registry credentials in the docker config format, taking
+	// precedence over the docker ones
+	CredentialsFile string
+	// Transport, if not nil, is the HTTP transport used to access OCI registries
+	Transport http.RoundTripper
+}
+
+// HelmRegistryConfig returns the registry credentials file "helm registry login" writes to. It honors
+// HELM_REGISTRY_CONFIG, which Helm sets from its --registry-config flag when running dt as a plugin
+func HelmRegistryConfig() string {
+	return cli.New().RegistryConfig
+}
+
+// WriteRegistryCredentialsFile writes into dir, in the docker config format, the credentials kc resolves for
+// the registry of chartURL, so Helm uses the same credentials as the images. It returns an empty string if
+// there are none
+func WriteRegistryCredentialsFile(dir string, chartURL string, kc authn.Keychain) (string, error) {
+	ref, err := name.ParseReference(strings.TrimPrefix(chartURL, "oci://"))
 	if err != nil {
-		return "", fmt.Errorf("failed to upload Helm chart: failed to create temp directory: %w", err)
+		return "", fmt.Errorf("failed to parse chart URL %q: %w", chartURL, err)
+	}
+	host := ref.Context().RegistryStr()
+	auth, err := kc.Resolve(ref.Context())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve credentials for %q: %w", host, err)
+	}
+	if auth == authn.Anonymous {
+		return "", nil
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve credentials for %q: %w", host, err)
+	}
+	entry := make(map[string]string)
+	if cfg.Username != "" {
+		entry["auth"] = base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+	} else if cfg.Auth != "" {
+		entry["auth"] = cfg.Auth
+	}
+	if cfg.IdentityToken != "" {
+		entry["identitytoken"] = cfg.IdentityToken
+	}
+	if len(entry) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(map[string]interface{}{"auths": map[string]interface{}{host: entry}})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode registry credentials: %w", err)
+	}
+	file := filepath.Join(dir, "registry-credentials.json")
+	// The file is removed by the caller once the chart is transferred
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write registry credentials: %w", err)
+	}
+	return file, nil
+}
+
+// newRegistryClient returns a Helm registry client using the credentials in credentialsFile, or in the Helm
+// registry configuration if empty, and the provided transport, if not nil
+func newRegistryClient(credentialsFile string, tr http.RoundTripper) (*registry.Client, error) {
+	if credentialsFile == "" {
+		credentialsFile = HelmRegistryConfig()
+	}
+	opts := []registry.ClientOption{registry.ClientOptCredentialsFile(credentialsFile)}
+	if tr != nil {
+		opts = append(opts, registry.ClientOptHTTPClient(&http.Client{Transport: tr}))
+	}
+	return registry.NewClient(opts...)
+}
+
+// PullChart downloads the specified chart archive into destDir, along with its provenance file if it exists,
+// and returns the archive path
+func PullChart(chartURL, version string, destDir string, fetchCfg FetchConfig) (string, error) {
+	downloadDir, err := os.MkdirTemp(destDir, "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	if fetchCfg.RepoURL != "" {
+		if err := pullRepositoryChart(chartURL, version, downloadDir, fetchCfg); err != nil {
+			return "", fmt.Errorf("failed to pull Helm chart: %w", err)
+		}
+		return downloadedArchive(downloadDir)
 	}
 
 	cfg := &action.Configuration{}
 	client := action.NewPullWithOpts(action.WithConfig(cfg))
 	client.Settings = cli.New()
-	client.DestDir = dir
-	client.Untar = true
-	reg, err := registry.NewClient()
+	client.DestDir = downloadDir
+	if fetchCfg.Keyring != "" {
+		client.Verify = true
+		client.Keyring = fetchCfg.Keyring
+	} else {
+		// Fetch the provenance file, if any, without verifying it
+		client.VerifyLater = true
+	}
+	reg, err := newRegistryClient(fetchCfg.CredentialsFile, fetchCfg.Transport)
 	if err != nil {
 		return "", fmt.Errorf("missing registry client: %w", err)
 	}
@@ -32,6 +152,34 @@ func FetchRemoteChart(chartURL, version string, destDir string) (string, error)
 	if err != nil {
 		return "", fmt.Errorf("failed to pull Helm chart: %w", err)
 	}
+	return downloadedArchive(downloadDir)
+}
+
+// downloadedArchive returns the chart archive downloaded into downloadDir
+func downloadedArchive(downloadDir string) (string, error) {
+	archives, err := filepath.Glob(filepath.Join(downloadDir, "*.tgz"))
+	if err != nil || len(archives) != 1 {
+		return "", fmt.Errorf("cannot find the downloaded Helm chart")
+	}
+	return archives[0], nil
+}
+
+// FetchRemoteChart retrieves the specified chart. If the chart has a provenance file, it is
+// also fetched and stored inside the chart directory
+func FetchRemoteChart(chartURL, version string, destDir string, fetchCfg FetchConfig) (string, error) {
+	dir, err := os.MkdirTemp(destDir, "chart-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload Helm chart: failed to create temp directory: %w", err)
+	}
+	archive, err := PullChart(chartURL, version, destDir, fetchCfg)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(filepath.Dir(archive))
+
+	if err := chartutil.ExpandFile(dir, archive); err != nil {
+		return "", fmt.Errorf("failed to untar Helm chart: %w", err)
+	}
 
 	charts, err := filepath.Glob(filepath.Join(dir, "*/Chart.yaml"))
 	if err != nil {
@@ -43,14 +191,44 @@ func FetchRemoteChart(chartURL, version string, destDir string) (string, error)
 	if len(charts) > 1 {
 		return "", fmt.Errorf("found multiple Helm charts")
 	}
-	return filepath.Dir(charts[0]), nil
+	chartDir := filepath.Dir(charts[0])
+
+	if provFile := archive + ProvenanceExtension; FileExists(provFile) {
+		data, err := os.ReadFile(provFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read provenance file: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(chartDir, filepath.Base(provFile)), data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write provenance file: %w", err)
+		}
+	}
+	return chartDir, nil
+}
+
+// VerifyChartProvenance verifies the packaged chart against its provenance file and
+// returns the identities that signed it
+func VerifyChartProvenance(chartFile string, provFile string, keyring string) ([]string, error) {
+	sig, err := provenance.NewFromKeyring(keyring, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keyring: %w", err)
+	}
+	ver, err := sig.Verify(chartFile, provFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify Helm chart provenance: %w", err)
+	}
+	identities := make([]string, 0, len(ver.SignedBy.Identities))
+	for name := range ver.SignedBy.Identities {
+		identities = append(identities, name)
+	}
+	sort.Strings(identities)
+	return identities, nil
 }
 
 // PushChart pushes the local chart tarFile to the remote URL provided
-func PushChart(tarFile string, pushChartURL string) error {
+func PushChart(tarFile string, pushChartURL string, pushCfg PushConfig) error {
 	cfg := &action.Configuration{}
 
-	reg, err := registry.NewClient()
+	reg, err := newRegistryClient(pushCfg.CredentialsFile, pushCfg.Transport)
 	if err != nil {
 		return fmt.Errorf("missing registry client: %w", err)
 	}
@@ -89,3 +267,78 @@ func RemoteChartExist(chartURL string, version string) bool {
 	_, err := showRemoteHelmChart(chartURL, version)
 	return err == nil
 }
+
+// IsRepositoryChartReference returns true if ref is a chart in a classic Helm repository added with
+// "helm repo add", in the REPO/NAME format
+func IsRepositoryChartReference(ref string) bool {
+	repoName, chartName, found := strings.Cut(ref, "/")
+	if !found || repoName == "" || chartName == "" || strings.Contains(chartName, "/") {
+		return false
+	}
+	f, err := repo.LoadFile(cli.New().RepositoryConfig)
+	if err != nil {
+		return false
+	}
+	return f.Has(repoName)
+}
+
+// BuildChartDependencies downloads the dependencies of the chart in chartDir into its charts/ directory,
+// as "helm dependency build" does, resolving them from Chart.yaml if there is no Chart.lock
+func BuildChartDependencies(chartDir string, fetchCfg FetchConfig) error {
+	settings := cli.New()
+	reg, err := newRegistryClient(fetchCfg.CredentialsFile, fetchCfg.Transport)
+	if err != nil {
+		return fmt.Errorf("missing registry client: %w", err)
+	}
+	man := &downloader.Manager{
+		Out:              io.Discard,
+		ChartPath:        chartDir,
+		Verify:           downloader.VerifyNever,
+		Getters:          getter.All(settings),
+		RegistryClient:   reg,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	if fetchCfg.Keyring != "" {
+		man.Verify = downloader.VerifyAlways
+		man.Keyring = fetchCfg.Keyring
+	}
+	if err := man.Build(); err != nil {
+		return fmt.Errorf("failed to build Helm chart dependencies: %w", err)
+	}
+	return nil
+}
+
+// ReleaseConfig defines how GetRelease accesses the cluster. The empty fields default to the settings Helm
+// passes to its plugins (HELM_NAMESPACE, KUBECONFIG and HELM_KUBECONTEXT), as "helm get" does
+type ReleaseConfig struct {
+	// Namespace, if not empty, is the namespace of the release instead of the one of the kubeconfig context
+	Namespace string
+	// KubeConfig, if not empty, is the kubeconfig file used instead of the default one
+	KubeConfig string
+	// KubeContext, if not empty, is the kubeconfig context used instead of the current one
+	KubeContext string
+}
+
+// GetRelease returns the latest revision of the Helm release deployed in the cluster, as "helm get" does
+func GetRelease(releaseName string, relCfg ReleaseConfig) (*release.Release, error) {
+	settings := cli.New()
+	if relCfg.Namespace != "" {
+		settings.SetNamespace(relCfg.Namespace)
+	}
+	if relCfg.KubeConfig != "" {
+		settings.KubeConfig = relCfg.KubeConfig
+	}
+	if relCfg.KubeContext != "" {
+		settings.KubeContext = relCfg.KubeContext
+	}
+	cfg := &action.Configuration{}
+	if err := cfg.Init(settings.RESTClientGetter(), settings.Namespace(), os.Getenv("HELM_DRIVER"), func(string, ...interface{}) {}); err != nil {
+		return nil, fmt.Errorf("failed to access the cluster: %w", err)
+	}
+	rel, err := action.NewGet(cfg).Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Helm release %q: %w", releaseName, err)
+	}
+	return rel, nil
+}
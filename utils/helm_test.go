@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestIsRepositoryChartReference(t *testing.T) {
+	dir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(t, err)
+	reposFile := filepath.Join(dir, "repositories.yaml")
+	f := repo.NewFile()
+	f.Add(&repo.Entry{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"})
+	require.NoError(t, f.WriteFile(reposFile, 0644))
+	t.Setenv("HELM_REPOSITORY_CONFIG", reposFile)
+
+	assert.True(t, IsRepositoryChartReference("bitnami/mariadb"))
+	for _, ref := range []string{"unknown/mariadb", "bitnami", "bitnami/", "bitnami/mariadb/extra", "/mariadb"} {
+		assert.False(t, IsRepositoryChartReference(ref), ref)
+	}
+}
+
+func TestWriteRegistryCredentialsFile(t *testing.T) {
+	dir, err := sb.Mkdir(sb.TempFile(), 0755)
+	require.NoError(t, err)
+
+	credentialsFile, err := WriteRegistryCredentialsFile(dir, "oci://example.com/charts", authn.NewMultiKeychain())
+	require.NoError(t, err)
+	assert.Empty(t, credentialsFile)
+
+	_, err = WriteRegistryCredentialsFile(dir, "oci://example.com/INVALID/charts", authn.NewMultiKeychain())
+	assert.ErrorContains(t, err, "failed to parse chart URL")
+}
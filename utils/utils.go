@@ -45,6 +45,19 @@ func rawYamlSet(n *yaml.Node, path string, value string) error {
 
 }
 
+// yamlPathKeyRe matches the keys that can be used in YAML paths without quoting
+var yamlPathKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// YamlPathChild returns the YAML path of the key child of the element at path, quoting the key if it
+// includes dots or other special characters
+func YamlPathChild(path string, key string) string {
+	if yamlPathKeyRe.MatchString(key) {
+		return fmt.Sprintf("%s.%s", path, key)
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key)
+	return fmt.Sprintf("%s['%s']", path, escaped)
+}
+
 // YamlFileSet sets the list of key-value specified in values in the YAML file.
 // The keys are in jsonpath format
 func YamlFileSet(file string, values map[string]string) error {
@@ -132,6 +132,24 @@ func TestYamlSet(t *testing.T) {
 	}
 }
 
+func TestYamlPathChild(t *testing.T) {
+	data := "plain:\n  image: a\napp.kubernetes.io/name:\n  image: b\nit's:\n  image: c\n"
+	for key, want := range map[string]string{
+		"plain":                  "plain:\n  image: new\napp.kubernetes.io/name:\n  image: b\nit's:\n  image: c\n",
+		"app.kubernetes.io/name": "plain:\n  image: a\napp.kubernetes.io/name:\n  image: new\nit's:\n  image: c\n",
+		"it's":                   "plain:\n  image: a\napp.kubernetes.io/name:\n  image: b\nit's:\n  image: new\n",
+	} {
+		path := YamlPathChild(YamlPathChild("$", key), "image")
+		got, err := YamlSet([]byte(data), map[string]string{path: "new"})
+		if err != nil {
+			t.Fatalf("failed to set %q: %v", path, err)
+		}
+		if string(got) != want {
+			t.Errorf("YamlSet(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
 func TestSafeWriteFile(t *testing.T) {
 	nonExistingFile := sb.TempFile()
 	sampleData := "hello world"
@@ -0,0 +1,84 @@
+// Package zarf implements the generation of Zarf package definitions from wrapped Helm charts
+package zarf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// PackageFileName is the name of the Zarf package definition file
+const PackageFileName = "zarf.yaml"
+
+// Package is a Zarf package definition
+type Package struct {
+	Kind       string      `yaml:"kind"`
+	Metadata   Metadata    `yaml:"metadata"`
+	Components []Component `yaml:"components"`
+}
+
+// Metadata describes a Zarf package
+type Metadata struct {
+	Name         string `yaml:"name"`
+	Description  string `yaml:"description,omitempty"`
+	Version      string `yaml:"version"`
+	Architecture string `yaml:"architecture"`
+}
+
+// Component is a Zarf package component
+type Component struct {
+	Name     string   `yaml:"name"`
+	Required bool     `yaml:"required"`
+	Charts   []Chart  `yaml:"charts"`
+	Images   []string `yaml:"images,omitempty"`
+}
+
+// Chart is a Helm chart deployed by a Zarf component
+type Chart struct {
+	Name      string `yaml:"name"`
+	Version   string `yaml:"version"`
+	Namespace string `yaml:"namespace"`
+	LocalPath string `yaml:"localPath"`
+}
+
+// NewPackage returns the Zarf package deploying the Helm chart packaged in chartFile, relative to the
+// package definition, into a namespace named after it. The package references the images of lock by the
+// digest of the given architecture, so Zarf bundles exactly the locked images
+func NewPackage(md *chart.Metadata, lock *imagelock.ImagesLock, chartFile string, arch string) (*Package, error) {
+	images := make([]string, 0)
+	for _, img := range lock.Images {
+		d, err := img.GetDigestForArch("linux/" + arch)
+		if err != nil {
+			return nil, fmt.Errorf("image %q is not available for the %q architecture", img.Image, arch)
+		}
+		// Images already pinned by digest keep their tag, if any
+		ref, _, _ := strings.Cut(img.Image, "@")
+		images = append(images, fmt.Sprintf("%s@%s", ref, d.Digest))
+	}
+	return &Package{
+		Kind:     "ZarfPackageConfig",
+		Metadata: Metadata{Name: md.Name, Description: md.Description, Version: md.Version, Architecture: arch},
+		Components: []Component{{
+			Name:     md.Name,
+			Required: true,
+			Charts:   []Chart{{Name: md.Name, Version: md.Version, Namespace: md.Name, LocalPath: chartFile}},
+			Images:   images,
+		}},
+	}, nil
+}
+
+// Write writes the package definition into file
+func (p *Package) Write(file string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to serialize Zarf package: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write Zarf package: %w", err)
+	}
+	return nil
+}
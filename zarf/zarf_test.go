@@ -0,0 +1,68 @@
+package zarf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-labs/distribution-tooling-for-helm/imagelock"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	amd64Digest = digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000001")
+	arm64Digest = digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000002")
+)
+
+func sampleLock() *imagelock.ImagesLock {
+	lock := imagelock.NewImagesLock()
+	lock.Images = imagelock.ImageList{
+		{Name: "mariadb", Chart: "mariadb", Image: "docker.io/bitnami/mariadb:11.0.2", Digests: []imagelock.DigestInfo{
+			{Digest: amd64Digest, Arch: "linux/amd64"},
+			{Digest: arm64Digest, Arch: "linux/arm64"},
+		}},
+		{Name: "exporter", Chart: "mariadb", Image: "docker.io/bitnami/exporter:1.0@" + amd64Digest.String(), Digests: []imagelock.DigestInfo{
+			{Digest: amd64Digest, Arch: "linux/amd64"},
+		}},
+	}
+	return lock
+}
+
+func TestNewPackage(t *testing.T) {
+	md := &chart.Metadata{Name: "mariadb", Version: "12.2.8", Description: "MariaDB"}
+	p, err := NewPackage(md, sampleLock(), "chart/mariadb-12.2.8.tgz", "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, &Package{
+		Kind:     "ZarfPackageConfig",
+		Metadata: Metadata{Name: "mariadb", Description: "MariaDB", Version: "12.2.8", Architecture: "amd64"},
+		Components: []Component{{
+			Name:     "mariadb",
+			Required: true,
+			Charts:   []Chart{{Name: "mariadb", Version: "12.2.8", Namespace: "mariadb", LocalPath: "chart/mariadb-12.2.8.tgz"}},
+			Images: []string{
+				"docker.io/bitnami/mariadb:11.0.2@" + amd64Digest.String(),
+				"docker.io/bitnami/exporter:1.0@" + amd64Digest.String(),
+			},
+		}},
+	}, p)
+
+	_, err = NewPackage(md, sampleLock(), "chart/mariadb-12.2.8.tgz", "arm64")
+	assert.ErrorContains(t, err, `image "docker.io/bitnami/exporter:1.0@`+amd64Digest.String()+`" is not available for the "arm64" architecture`)
+}
+
+func TestWrite(t *testing.T) {
+	p, err := NewPackage(&chart.Metadata{Name: "mariadb", Version: "12.2.8"}, sampleLock(), "chart.tgz", "amd64")
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), PackageFileName)
+	require.NoError(t, p.Write(file))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	read := &Package{}
+	require.NoError(t, yaml.Unmarshal(data, read))
+	assert.Equal(t, p, read)
+}